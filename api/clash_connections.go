@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+)
+
+// ConnectionInfo is one entry of the Clash GET /connections response — only
+// the fields needed to pick connections for closing (Debug API, profiler).
+type ConnectionInfo struct {
+	ID       string             `json:"id"`
+	Metadata ConnectionMetadata `json:"metadata"`
+	Upload   int64              `json:"upload"`
+	Download int64              `json:"download"`
+	Start    time.Time          `json:"start"`
+	// Chains goes leaf → root: the node first, the selector group last.
+	Chains []string `json:"chains"`
+	Rule   string   `json:"rule"`
+}
+
+// ConnectionMetadata is the subset of Clash connection metadata used by filters.
+type ConnectionMetadata struct {
+	Network         string `json:"network"`
+	Host            string `json:"host"`
+	DestinationIP   string `json:"destinationIP"`
+	DestinationPort string `json:"destinationPort"`
+	Process         string `json:"process"`
+	ProcessPath     string `json:"processPath"`
+}
+
+// ConnectionFilter selects connections to close. Empty fields match anything;
+// non-empty fields are ANDed. An empty filter matches every connection.
+type ConnectionFilter struct {
+	// Process matches the process name or the full process path (case-insensitive).
+	Process string `json:"process,omitempty"`
+	// Outbound matches any element of the chain — a node tag or a group tag —
+	// so "all connections via proxy-out" catches every node behind the selector.
+	Outbound string `json:"outbound,omitempty"`
+	// Host is a case-insensitive substring of the destination host or IP.
+	Host string `json:"host,omitempty"`
+}
+
+// IsEmpty reports whether the filter has no conditions (i.e. "close all").
+func (f ConnectionFilter) IsEmpty() bool {
+	return strings.TrimSpace(f.Process) == "" && strings.TrimSpace(f.Outbound) == "" && strings.TrimSpace(f.Host) == ""
+}
+
+// Match reports whether c satisfies every non-empty condition of the filter.
+func (f ConnectionFilter) Match(c ConnectionInfo) bool {
+	if p := strings.ToLower(strings.TrimSpace(f.Process)); p != "" {
+		if strings.ToLower(c.Metadata.Process) != p && strings.ToLower(c.Metadata.ProcessPath) != p {
+			return false
+		}
+	}
+	if ob := strings.TrimSpace(f.Outbound); ob != "" {
+		found := false
+		for _, tag := range c.Chains {
+			if tag == ob {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if h := strings.ToLower(strings.TrimSpace(f.Host)); h != "" {
+		if !strings.Contains(strings.ToLower(c.Metadata.Host), h) && !strings.Contains(strings.ToLower(c.Metadata.DestinationIP), h) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetConnections returns the currently open connections of the core. Returns ErrPlatformInterrupt when the system is sleeping or context is cancelled.
+func GetConnections(baseURL, token string) ([]ConnectionInfo, error) {
+	ctx, err := requestContext()
+	if err != nil {
+		return nil, err
+	}
+	writeLog(debuglog.LevelVerbose, "[%s] GET /connections request started.\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(httpRequestTimeoutSeconds)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "GET", baseURL+"/connections", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connections request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := getHTTPClient().Do(req)
+	defer func() {
+		if resp != nil {
+			debuglog.RunAndLog("GetConnections: close response body", resp.Body.Close)
+		}
+	}()
+	if err != nil {
+		writeLog(debuglog.LevelInfo, "[%s] Error executing connections request: %v\n", time.Now().Format("2006-01-02 15:04:05"), err)
+		return nil, classifyRequestError(err, "failed to execute connections request: %w")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connections response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		writeLog(debuglog.LevelInfo, "[%s] Unexpected status code for connections: %d, body: %s\n", time.Now().Format("2006-01-02 15:04:05"), resp.StatusCode, string(body))
+		return nil, fmt.Errorf("unexpected status code for connections: %d, body: %s", resp.StatusCode, string(body))
+	}
+	var snapshot struct {
+		Connections []ConnectionInfo `json:"connections"`
+	}
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse connections response: %w", err)
+	}
+	return snapshot.Connections, nil
+}
+
+// CloseConnection closes one connection by its Clash id (DELETE /connections/{id}).
+// Returns ErrPlatformInterrupt when the system is sleeping or context is cancelled.
+func CloseConnection(baseURL, token, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("connection id is empty")
+	}
+	return deleteConnections(baseURL+"/connections/"+url.PathEscape(id), token, "DELETE /connections/"+id)
+}
+
+// CloseAllConnections closes every open connection of the core (DELETE /connections).
+// Returns ErrPlatformInterrupt when the system is sleeping or context is cancelled.
+func CloseAllConnections(baseURL, token string) error {
+	return deleteConnections(baseURL+"/connections", token, "DELETE /connections")
+}
+
+// CloseConnectionsMatching closes the connections selected by f and returns how
+// many were closed. An empty filter is a single DELETE /connections instead of
+// one request per connection. Stops at the first failed close.
+func CloseConnectionsMatching(baseURL, token string, f ConnectionFilter) (int, error) {
+	conns, err := GetConnections(baseURL, token)
+	if err != nil {
+		return 0, err
+	}
+	if f.IsEmpty() {
+		if err := CloseAllConnections(baseURL, token); err != nil {
+			return 0, err
+		}
+		return len(conns), nil
+	}
+	closed := 0
+	for _, c := range conns {
+		if !f.Match(c) {
+			continue
+		}
+		if err := CloseConnection(baseURL, token, c.ID); err != nil {
+			return closed, err
+		}
+		closed++
+	}
+	return closed, nil
+}
+
+// deleteConnections sends one DELETE to the Clash connections endpoint. Clash
+// answers 204 No Content; 200 is accepted for forks that reply with a body.
+func deleteConnections(reqURL, token, what string) error {
+	ctx, err := requestContext()
+	if err != nil {
+		return err
+	}
+	writeLog(debuglog.LevelVerbose, "[%s] %s request started.\n", time.Now().Format("2006-01-02 15:04:05"), what)
+
+	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(httpRequestTimeoutSeconds)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "DELETE", reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create close-connection request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := getHTTPClient().Do(req)
+	defer func() {
+		if resp != nil {
+			debuglog.RunAndLog("deleteConnections: close response body", resp.Body.Close)
+		}
+	}()
+	if err != nil {
+		writeLog(debuglog.LevelInfo, "[%s] Error executing %s: %v\n", time.Now().Format("2006-01-02 15:04:05"), what, err)
+		return classifyRequestError(err, "failed to execute close-connection request: %w")
+	}
+
+	writeLog(debuglog.LevelVerbose, "[%s] %s response status: %d\n", time.Now().Format("2006-01-02 15:04:05"), what, resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		writeLog(debuglog.LevelInfo, "[%s] Unexpected status code for %s: %d, body: %s\n", time.Now().Format("2006-01-02 15:04:05"), what, resp.StatusCode, string(bodyBytes))
+		return fmt.Errorf("unexpected status code for close connection: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeClashConnections serves GET/DELETE /connections like the Clash API and
+// records which ids were closed.
+func fakeClashConnections(t *testing.T, body string) (*httptest.Server, func() []string, func() bool) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		closed   []string
+		allFired bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connections":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		case r.Method == http.MethodDelete && r.URL.Path == "/connections":
+			mu.Lock()
+			allFired = true
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/connections/"):
+			mu.Lock()
+			closed = append(closed, strings.TrimPrefix(r.URL.Path, "/connections/"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			out := append([]string(nil), closed...)
+			sort.Strings(out)
+			return out
+		}, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return allFired
+		}
+}
+
+const connectionsFixture = `{"connections":[
+ {"id":"a","chains":["JP-01","proxy-out"],"metadata":{"host":"www.youtube.com","process":"chrome.exe","processPath":"C:\\chrome.exe"}},
+ {"id":"b","chains":["direct-out"],"metadata":{"host":"ya.ru","process":"chrome.exe"}},
+ {"id":"c","chains":["NL-02","proxy-out"],"metadata":{"destinationIP":"1.1.1.1","process":"curl"}}
+]}`
+
+func TestGetConnections(t *testing.T) {
+	srv, _, _ := fakeClashConnections(t, connectionsFixture)
+	conns, err := GetConnections(srv.URL, "tok")
+	if err != nil {
+		t.Fatalf("GetConnections: %v", err)
+	}
+	if len(conns) != 3 || conns[0].ID != "a" || conns[0].Metadata.Process != "chrome.exe" {
+		t.Fatalf("unexpected connections: %+v", conns)
+	}
+}
+
+func TestCloseConnectionsMatching(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter ConnectionFilter
+		want   []string
+	}{
+		{"by group", ConnectionFilter{Outbound: "proxy-out"}, []string{"a", "c"}},
+		{"by node", ConnectionFilter{Outbound: "NL-02"}, []string{"c"}},
+		{"by process name", ConnectionFilter{Process: "CHROME.EXE"}, []string{"a", "b"}},
+		{"by process path", ConnectionFilter{Process: `C:\chrome.exe`}, []string{"a"}},
+		{"by host substring", ConnectionFilter{Host: "youtube"}, []string{"a"}},
+		{"by ip substring", ConnectionFilter{Host: "1.1.1"}, []string{"c"}},
+		{"anded", ConnectionFilter{Process: "chrome.exe", Outbound: "proxy-out"}, []string{"a"}},
+		{"no match", ConnectionFilter{Outbound: "nope"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, closed, all := fakeClashConnections(t, connectionsFixture)
+			n, err := CloseConnectionsMatching(srv.URL, "tok", tc.filter)
+			if err != nil {
+				t.Fatalf("CloseConnectionsMatching: %v", err)
+			}
+			got := closed()
+			if n != len(tc.want) || strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("closed %d %v, want %v", n, got, tc.want)
+			}
+			if all() {
+				t.Error("filtered close must not hit DELETE /connections")
+			}
+		})
+	}
+}
+
+func TestCloseConnectionsMatchingEmptyFilterClosesAll(t *testing.T) {
+	srv, closed, all := fakeClashConnections(t, connectionsFixture)
+	n, err := CloseConnectionsMatching(srv.URL, "tok", ConnectionFilter{})
+	if err != nil {
+		t.Fatalf("CloseConnectionsMatching: %v", err)
+	}
+	if !all() || n != 3 || len(closed()) != 0 {
+		t.Errorf("empty filter: all=%v n=%d per-id=%v", all(), n, closed())
+	}
+}
+
+func TestCloseConnectionErrors(t *testing.T) {
+	srv, _, _ := fakeClashConnections(t, connectionsFixture)
+	if err := CloseConnection(srv.URL, "tok", "  "); err == nil {
+		t.Error("empty id must fail")
+	}
+	if err := CloseConnection(srv.URL, "wrong", "a"); err == nil {
+		t.Error("401 must surface as an error")
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+)
+
+// TrafficSample is one frame of the Clash GET /traffic stream: bytes per
+// second through the core since the previous frame.
+type TrafficSample struct {
+	Up   int64 `json:"up"`
+	Down int64 `json:"down"`
+}
+
+// MemorySample is one frame of the Clash GET /memory stream.
+type MemorySample struct {
+	InUse   uint64 `json:"inuse"`
+	OSLimit uint64 `json:"oslimit"`
+}
+
+// streamHTTPClient is the client for long-lived Clash streams. Unlike
+// getHTTPClient it has no overall Timeout: a stream lives until the caller
+// cancels ctx, and http.Client.Timeout would cut it after 20 seconds.
+var streamHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: time.Duration(httpDialTimeoutSeconds) * time.Second,
+		}).DialContext,
+		IdleConnTimeout: httpIdleConnTimeoutSec * time.Second,
+	},
+}
+
+// StreamTraffic reads the Clash GET /traffic stream and calls onSample once per
+// frame (once a second). Blocks until ctx is cancelled or the stream ends;
+// returns nil on cancellation.
+func StreamTraffic(ctx context.Context, baseURL, token string, onSample func(TrafficSample)) error {
+	return streamFrames(ctx, baseURL+"/traffic", token, "GET /traffic", func(dec *json.Decoder) error {
+		var s TrafficSample
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		onSample(s)
+		return nil
+	})
+}
+
+// StreamMemory reads the Clash GET /memory stream and calls onSample once per
+// frame. Same lifetime rules as StreamTraffic.
+func StreamMemory(ctx context.Context, baseURL, token string, onSample func(MemorySample)) error {
+	return streamFrames(ctx, baseURL+"/memory", token, "GET /memory", func(dec *json.Decoder) error {
+		var s MemorySample
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		onSample(s)
+		return nil
+	})
+}
+
+// streamFrames opens a Clash JSON stream and feeds the decoder to next until it
+// fails. Clash writes one JSON object per frame without framing, so a single
+// json.Decoder over the body reads them in order.
+func streamFrames(ctx context.Context, reqURL, token, what string, next func(*json.Decoder) error) error {
+	if _, err := requestContext(); err != nil {
+		return err
+	}
+	writeLog(debuglog.LevelVerbose, "[%s] %s stream started.\n", time.Now().Format("2006-01-02 15:04:05"), what)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", what, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := streamHTTPClient.Do(req)
+	defer func() {
+		if resp != nil {
+			debuglog.RunAndLog("streamFrames: close response body", resp.Body.Close)
+		}
+	}()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		writeLog(debuglog.LevelInfo, "[%s] Error opening %s stream: %v\n", time.Now().Format("2006-01-02 15:04:05"), what, err)
+		return classifyRequestError(err, "failed to open stream: %w")
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		writeLog(debuglog.LevelInfo, "[%s] Unexpected status code for %s: %d, body: %s\n", time.Now().Format("2006-01-02 15:04:05"), what, resp.StatusCode, string(bodyBytes))
+		return fmt.Errorf("unexpected status code for %s: %d, body: %s", what, resp.StatusCode, string(bodyBytes))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		if err := next(dec); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("%s stream closed by the core", what)
+			}
+			return fmt.Errorf("failed to read %s stream: %w", what, err)
+		}
+	}
+}
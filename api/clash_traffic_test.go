@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamTraffic(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/traffic" || r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "{\"up\":%d,\"down\":%d}\n", i*10, i*100)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	var got []TrafficSample
+	err := StreamTraffic(context.Background(), srv.URL, "tok", func(s TrafficSample) { got = append(got, s) })
+	if err == nil {
+		t.Fatal("stream closed by the server must surface as an error")
+	}
+	if len(got) != 3 || got[2].Up != 30 || got[2].Down != 300 {
+		t.Fatalf("unexpected samples: %+v", got)
+	}
+}
+
+func TestStreamMemoryCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"inuse":1048576,"oslimit":0}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan MemorySample, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- StreamMemory(ctx, srv.URL, "tok", func(s MemorySample) { got <- s })
+	}()
+	select {
+	case s := <-got:
+		if s.InUse != 1<<20 {
+			t.Fatalf("inuse = %d", s.InUse)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no memory frame")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("cancelled stream must return nil, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop on cancel")
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/debugapi"
+	"singbox-launcher/core/events"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/process"
+	"singbox-launcher/internal/secretstore"
+)
+
+// Scriptable subcommands: `singbox-launcher <command> [flags]`. None of them
+// touches Fyne, so they run on machines without a display.
+//
+// A launcher already running on this machine (GUI or -headless) owns
+// state.json, config.json and the core, so build-config, update-subs, start,
+// stop and status go through its Debug API when it answers. Otherwise
+// build-config and update-subs run in-process on a headless controller;
+// start/stop have nothing to drive and fail with exitNotRunning.
+
+// Exit codes. exitNotRunning follows the LSB status convention (3 = program
+// is not running), so `status` can be used directly in shell conditions.
+const (
+	exitOK         = 0
+	exitFailure    = 1
+	exitUsage      = 2
+	exitNotRunning = 3
+)
+
+// instancePollTimeout — how long start/stop wait for the core to change state.
+var instancePollTimeout = 15 * time.Second
+
+// cliEnv — what a subcommand needs from the outside world; tests substitute it.
+type cliEnv struct {
+	out    io.Writer
+	errOut io.Writer
+	// execDir — the launcher install directory (bin/ and logs/ live under it).
+	execDir string
+	// instance finds a launcher running on this machine; nil — none answers.
+	instance func() *instanceClient
+	// secrets — why secretstore.Init left the sealed values locked; nil when
+	// they are open or encryption is off.
+	secrets error
+}
+
+type cliCommand struct {
+	name    string
+	summary string
+	run     func(env *cliEnv, args []string) int
+}
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{"build-config", "Rebuild config.json from state.json and the template, then run sing-box check", cmdBuildConfig},
+		{"update-subs", "Fetch all enabled subscriptions and rebuild config.json", cmdUpdateSubs},
+		{"check", "Validate config.json with sing-box check", cmdCheck},
+		{"start", "Start the core in the running launcher", cmdStart},
+		{"stop", "Stop the core in the running launcher", cmdStop},
+		{"status", "Show launcher and core state (exit 3 when the core is not running)", cmdStatus},
+		{"export-state", "Write state.json (canonical form) to stdout or -o FILE", cmdExportState},
+	}
+}
+
+func isCLICommand(name string) bool {
+	if name == "help" {
+		return true
+	}
+	for _, c := range cliCommands() {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runCLI executes one subcommand and returns the process exit code.
+func runCLI(name string, args []string) int {
+	env := &cliEnv{out: os.Stdout, errOut: os.Stderr}
+	if ex, err := os.Executable(); err == nil {
+		env.execDir = filepath.Dir(ex)
+	}
+	env.instance = func() *instanceClient { return findInstance(env.execDir) }
+	env.unlockSecrets()
+	return env.run(name, args)
+}
+
+// unlockSecrets loads the at-rest encryption key the way prepareController
+// does: from the system keyring or secretstore.PassphraseEnv. Without it the
+// Debug API token in settings.json stays sealed and state.Load fails.
+func (env *cliEnv) unlockSecrets() {
+	env.secrets = secretstore.Init(platform.GetBinDir(env.execDir))
+}
+
+func (env *cliEnv) run(name string, args []string) int {
+	for _, c := range cliCommands() {
+		if c.name == name {
+			// Every command but check reads settings.json or state.json: a
+			// locked store fails them up front with the unlock hint instead
+			// of a 401 from the running launcher.
+			if c.name != "check" && env.secrets != nil && secretstore.Locked() {
+				return env.fail(name, env.secrets)
+			}
+			return c.run(env, args)
+		}
+	}
+	env.printUsage()
+	if name == "help" {
+		return exitOK
+	}
+	return exitUsage
+}
+
+func (env *cliEnv) printUsage() {
+	fmt.Fprintln(env.errOut, "Usage: singbox-launcher <command> [flags]")
+	fmt.Fprintln(env.errOut, "       singbox-launcher -headless [-start]")
+	fmt.Fprintln(env.errOut)
+	fmt.Fprintln(env.errOut, "Commands:")
+	for _, c := range cliCommands() {
+		fmt.Fprintf(env.errOut, "  %-13s %s\n", c.name, c.summary)
+	}
+}
+
+// flags returns a FlagSet that reports errors instead of exiting.
+func (env *cliEnv) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.errOut)
+	return fs
+}
+
+// parse parses args and rejects positional leftovers; false → exitUsage.
+func (env *cliEnv) parse(fs *flag.FlagSet, args []string) bool {
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(env.errOut, "%s: unexpected argument %q\n", fs.Name(), fs.Arg(0))
+		return false
+	}
+	return true
+}
+
+func (env *cliEnv) fail(cmd string, err error) int {
+	fmt.Fprintf(env.errOut, "%s: %v\n", cmd, explainLocked(err))
+	return exitFailure
+}
+
+// explainLocked says how to unlock the secrets: outside the GUI there is
+// nowhere to type the passphrase.
+func explainLocked(err error) error {
+	if !errors.Is(err, secretstore.ErrLocked) {
+		return err
+	}
+	switch secretstore.CurrentStatus().Source {
+	case secretstore.SourcePassphrase:
+		return fmt.Errorf("secrets are encrypted with a passphrase; set %s to unlock them", secretstore.PassphraseEnv)
+	case secretstore.SourceKeyring:
+		return errors.New("secrets are encrypted with a key in the system keyring, which is locked or not available")
+	}
+	return err
+}
+
+func cmdBuildConfig(env *cliEnv, args []string) int {
+	fs := env.flags("build-config")
+	if !env.parse(fs, args) {
+		return exitUsage
+	}
+	if inst := env.instance(); inst != nil {
+		// The running launcher keeps the dirty markers: it rebuilds only
+		// when state, template or cache changed since its last build.
+		if err := inst.call(http.MethodPost, "/action/rebuild-config", nil); err != nil {
+			return env.fail("build-config", err)
+		}
+		fmt.Fprintln(env.out, "config.json rebuilt by the running launcher")
+		return exitOK
+	}
+	return runLocal(env, "build-config", func(ac *core.AppController) error {
+		// A fresh process has no dirty markers, so the build is forced.
+		return ac.RebuildConfigBy(audit.ActorCLI, true)
+	})
+}
+
+func cmdUpdateSubs(env *cliEnv, args []string) int {
+	fs := env.flags("update-subs")
+	if !env.parse(fs, args) {
+		return exitUsage
+	}
+	if inst := env.instance(); inst != nil {
+		if err := inst.call(http.MethodPost, "/action/update-subs", nil); err != nil {
+			return env.fail("update-subs", err)
+		}
+		fmt.Fprintln(env.out, "subscriptions refreshed by the running launcher")
+		return exitOK
+	}
+	return runLocal(env, "update-subs", func(ac *core.AppController) error {
+		res, err := ac.UpdateSubscriptionsBy(audit.ActorCLI)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(env.out, "subscriptions: %d/%d sources OK, %d nodes\n",
+			res.SucceededSources, res.TotalSources, res.NodesCount)
+		if res.FailedSources > 0 {
+			return fmt.Errorf("%d source(s) failed, see logs/%s", res.FailedSources, constants.MainLogFileName)
+		}
+		return nil
+	})
+}
+
+// runLocal runs fn on a headless controller of this process and reports the
+// config build it triggers: RebuildConfigIfDirty writes config.json even when
+// sing-box rejects it and reports that only through events.ConfigBuilt.
+func runLocal(env *cliEnv, cmd string, fn func(ac *core.AppController) error) int {
+	ac, err := core.NewHeadlessController()
+	if err != nil {
+		return env.fail(cmd, err)
+	}
+	defer ac.CloseHeadless()
+	prepareController(ac)
+	// One-shot: the auto-update loop must not start a sweep of its own.
+	ac.StateService.SetAutoUpdateEnabled(false)
+
+	var built *events.ConfigBuiltPayload
+	cancel := ac.EventBus.Subscribe(events.ConfigBuilt, func(ev events.Event) {
+		if p, ok := ev.Payload.(events.ConfigBuiltPayload); ok {
+			built = &p
+		}
+	})
+	defer cancel()
+
+	if err := fn(ac); err != nil {
+		return env.fail(cmd, err)
+	}
+	if built != nil {
+		for _, w := range built.Warnings {
+			fmt.Fprintf(env.errOut, "warning: %s\n", w)
+		}
+		if !built.OK {
+			return env.fail(cmd, errors.New("sing-box rejected the generated config.json"))
+		}
+		fmt.Fprintf(env.out, "config.json written: %s\n", ac.FileService.ConfigPath)
+	}
+	return exitOK
+}
+
+func cmdCheck(env *cliEnv, args []string) int {
+	fs := env.flags("check")
+	configPath := fs.String("c", "", "config to check (default: the launcher's config.json)")
+	if !env.parse(fs, args) {
+		return exitUsage
+	}
+	singbox := platform.ResolveSingboxExecPath(env.execDir,
+		filepath.Join(env.execDir, "bin", platform.GetExecutableNames()))
+	path := *configPath
+	if path == "" {
+		path = platform.GetConfigPath(env.execDir)
+	}
+	if err := core.CheckConfigFile(singbox, path); err != nil {
+		return env.fail("check", err)
+	}
+	fmt.Fprintf(env.out, "%s: OK\n", path)
+	return exitOK
+}
+
+func cmdStart(env *cliEnv, args []string) int {
+	return setCoreRunning(env, "start", args, true)
+}
+
+func cmdStop(env *cliEnv, args []string) int {
+	return setCoreRunning(env, "stop", args, false)
+}
+
+// setCoreRunning asks the running launcher to start/stop the core and waits
+// until its state confirms it: the action endpoints return before the core
+// is actually up or down.
+func setCoreRunning(env *cliEnv, cmd string, args []string, running bool) int {
+	fs := env.flags(cmd)
+	noWait := fs.Bool("no-wait", false, "return right after the request, without waiting for the core")
+	if !env.parse(fs, args) {
+		return exitUsage
+	}
+	inst := env.instance()
+	if inst == nil {
+		fmt.Fprintf(env.errOut, "%s: no launcher with the Debug API is running; run `singbox-launcher -headless -start` or enable the Debug API\n", cmd)
+		return exitNotRunning
+	}
+	if err := inst.call(http.MethodPost, "/action/"+cmd, nil); err != nil {
+		return env.fail(cmd, err)
+	}
+	if *noWait {
+		return exitOK
+	}
+	deadline := time.Now().Add(instancePollTimeout)
+	for {
+		var st instanceState
+		if err := inst.call(http.MethodGet, "/state", &st); err != nil {
+			return env.fail(cmd, err)
+		}
+		if st.Running == running {
+			fmt.Fprintf(env.out, "core %s\n", runningWord(running))
+			return exitOK
+		}
+		if time.Now().After(deadline) {
+			return env.fail(cmd, fmt.Errorf("core still %s after %s, see logs", runningWord(!running), instancePollTimeout))
+		}
+		time.Sleep(instancePollTimeout / 30)
+	}
+}
+
+func runningWord(running bool) string {
+	if running {
+		return "running"
+	}
+	return "stopped"
+}
+
+// instanceState — the part of GET /state the CLI reads.
+type instanceState struct {
+	Running        bool   `json:"running"`
+	ActiveProxy    string `json:"active_proxy"`
+	SelectedGroup  string `json:"selected_group"`
+	SingboxVersion string `json:"singbox_version"`
+}
+
+// statusReport — `status -json` output.
+type statusReport struct {
+	Launcher       string `json:"launcher"` // "running" | "unreachable"
+	CoreRunning    bool   `json:"core_running"`
+	CorePID        int    `json:"core_pid,omitempty"`
+	ActiveProxy    string `json:"active_proxy,omitempty"`
+	SelectedGroup  string `json:"selected_group,omitempty"`
+	SingboxVersion string `json:"singbox_version,omitempty"`
+	ConfigPath     string `json:"config_path"`
+	ConfigBuiltAt  string `json:"config_built_at,omitempty"`
+	StateFound     bool   `json:"state_found"`
+}
+
+func cmdStatus(env *cliEnv, args []string) int {
+	fs := env.flags("status")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if !env.parse(fs, args) {
+		return exitUsage
+	}
+	rep := statusReport{Launcher: "unreachable", ConfigPath: platform.GetConfigPath(env.execDir)}
+	if fi, err := os.Stat(rep.ConfigPath); err == nil {
+		rep.ConfigBuiltAt = fi.ModTime().UTC().Format(time.RFC3339)
+	}
+	if _, err := os.Stat(platform.GetWizardStatePath(env.execDir)); err == nil {
+		rep.StateFound = true
+	}
+	if inst := env.instance(); inst != nil {
+		var st instanceState
+		if err := inst.call(http.MethodGet, "/state", &st); err != nil {
+			return env.fail("status", err)
+		}
+		rep.Launcher = "running"
+		rep.CoreRunning = st.Running
+		rep.ActiveProxy = st.ActiveProxy
+		rep.SelectedGroup = st.SelectedGroup
+		rep.SingboxVersion = st.SingboxVersion
+	} else if pid, ok := findCoreProcess(); ok {
+		// No launcher to ask: a core process is all we can see.
+		rep.CoreRunning = true
+		rep.CorePID = pid
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(env.out)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	} else {
+		fmt.Fprintf(env.out, "launcher: %s\n", rep.Launcher)
+		coreLine := runningWord(rep.CoreRunning)
+		if rep.CorePID != 0 {
+			coreLine += fmt.Sprintf(" (pid %d, no launcher answering)", rep.CorePID)
+		}
+		fmt.Fprintf(env.out, "core:     %s\n", coreLine)
+		if rep.ActiveProxy != "" {
+			fmt.Fprintf(env.out, "proxy:    %s (group %s)\n", rep.ActiveProxy, rep.SelectedGroup)
+		}
+		built := "missing"
+		if rep.ConfigBuiltAt != "" {
+			built = "written " + rep.ConfigBuiltAt
+		}
+		fmt.Fprintf(env.out, "config:   %s (%s)\n", rep.ConfigPath, built)
+		if !rep.StateFound {
+			fmt.Fprintln(env.out, "state:    state.json not found")
+		}
+	}
+	if !rep.CoreRunning {
+		return exitNotRunning
+	}
+	return exitOK
+}
+
+// findCoreProcess looks for a sing-box process by name.
+func findCoreProcess() (int, bool) {
+	procs, err := process.GetProcesses()
+	if err != nil {
+		return 0, false
+	}
+	name := platform.GetProcessNameForCheck()
+	for _, p := range procs {
+		if strings.EqualFold(p.Name, name) {
+			return p.PID, true
+		}
+	}
+	return 0, false
+}
+
+func cmdExportState(env *cliEnv, args []string) int {
+	fs := env.flags("export-state")
+	outPath := fs.String("o", "", "write to FILE instead of stdout")
+	if !env.parse(fs, args) {
+		return exitUsage
+	}
+	st, err := state.Load(platform.GetWizardStatePath(env.execDir))
+	if err != nil {
+		return env.fail("export-state", err)
+	}
+	data, err := st.Export()
+	if err != nil {
+		return env.fail("export-state", err)
+	}
+	if *outPath == "" {
+		_, err = env.out.Write(data)
+	} else {
+		err = os.WriteFile(*outPath, data, 0o600)
+	}
+	if err != nil {
+		return env.fail("export-state", err)
+	}
+	return exitOK
+}
+
+// instanceClient talks to the Debug API of a launcher running on this machine.
+type instanceClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+// findInstance reads the Debug API port and token from bin/settings.json and
+// returns a client if the API answers /ping; nil otherwise. The Unix socket,
+// when it is on, is preferred over the TCP port.
+func findInstance(execDir string) *instanceClient {
+	settings := locale.LoadSettings(platform.GetBinDir(execDir))
+	if !settings.DebugAPIEnabled || settings.DebugAPIToken == "" {
+		return nil
+	}
+	port := settings.DebugAPIPort
+	if port == 0 {
+		port = debugapi.DefaultPort
+	}
+	c := &instanceClient{
+		base:  fmt.Sprintf("http://127.0.0.1:%d", port),
+		token: settings.DebugAPIToken,
+		// update-subs fetches every subscription before answering.
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+	ping := &http.Client{Timeout: 2 * time.Second}
+	if settings.DebugAPISocket && debugapi.SocketSupported {
+		sock := platform.GetDebugAPISocketPath(execDir)
+		transport := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		}}
+		c.base = "http://unix"
+		c.client.Transport = transport
+		ping.Transport = transport
+	} else if settings.DebugAPISocketOnly {
+		return nil
+	}
+	resp, err := ping.Get(c.base + "/ping")
+	if err != nil {
+		return nil
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return c
+}
+
+// call sends a request and decodes a 2xx JSON answer into out (may be nil).
+func (c *instanceClient) call(method, path string, out any) error {
+	req, err := http.NewRequest(method, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s %s: %s (HTTP %d)", method, path, e.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("%s %s: HTTP %d", method, path, resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(body, out)
+	}
+	return nil
+}
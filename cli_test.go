@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"net/url"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
+	"strconv"
+)
+
+// fakeInstance — Debug API of a "running launcher" with just the endpoints
+// the CLI calls.
+type fakeInstance struct {
+	mu      sync.Mutex
+	running bool
+	calls   []string
+}
+
+func (f *fakeInstance) start(t *testing.T) *instanceClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls = append(f.calls, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/state":
+			_ = json.NewEncoder(w).Encode(map[string]any{"running": f.running, "active_proxy": "jp-1", "selected_group": "proxy-out"})
+		case "/action/start":
+			f.running = true
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/action/stop":
+			f.running = false
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/action/rebuild-config":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"build: template not found"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &instanceClient{base: srv.URL, token: "tok", client: srv.Client()}
+}
+
+func testEnv(t *testing.T, inst *instanceClient) (*cliEnv, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	return &cliEnv{
+		out:      &out,
+		errOut:   &errOut,
+		execDir:  t.TempDir(),
+		instance: func() *instanceClient { return inst },
+	}, &out, &errOut
+}
+
+func TestCLIUsage(t *testing.T) {
+	env, _, errOut := testEnv(t, nil)
+	if code := env.run("help", nil); code != exitOK || !strings.Contains(errOut.String(), "export-state") {
+		t.Fatalf("help: code %d: %s", code, errOut)
+	}
+	if code := env.run("status", []string{"-bogus"}); code != exitUsage {
+		t.Fatalf("bad flag: code %d, want %d", code, exitUsage)
+	}
+	if code := env.run("export-state", []string{"extra"}); code != exitUsage {
+		t.Fatalf("positional arg: code %d, want %d", code, exitUsage)
+	}
+	if !isCLICommand("build-config") || isCLICommand("-start") {
+		t.Fatal("isCLICommand")
+	}
+}
+
+func TestCLIStartStopThroughInstance(t *testing.T) {
+	fi := &fakeInstance{}
+	env, out, errOut := testEnv(t, fi.start(t))
+	if code := env.run("start", nil); code != exitOK {
+		t.Fatalf("start: code %d: %s", code, errOut)
+	}
+	if !strings.Contains(out.String(), "core running") {
+		t.Fatalf("start output = %q", out)
+	}
+	if code := env.run("stop", nil); code != exitOK || fi.running {
+		t.Fatalf("stop: code %d running %v: %s", code, fi.running, errOut)
+	}
+	want := "POST /action/start"
+	if fi.calls[0] != want {
+		t.Fatalf("first call = %q, want %q", fi.calls[0], want)
+	}
+}
+
+func TestCLIStartWaitsTimeout(t *testing.T) {
+	old := instancePollTimeout
+	instancePollTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { instancePollTimeout = old })
+
+	// The action succeeds but the core never comes up.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/state" {
+			_, _ = w.Write([]byte(`{"running":false}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	env, _, errOut := testEnv(t, &instanceClient{base: srv.URL, client: srv.Client()})
+	if code := env.run("start", nil); code != exitFailure || !strings.Contains(errOut.String(), "still stopped") {
+		t.Fatalf("start: code %d: %s", code, errOut)
+	}
+}
+
+func TestCLIWithoutInstance(t *testing.T) {
+	env, _, errOut := testEnv(t, nil)
+	for _, cmd := range []string{"start", "stop"} {
+		if code := env.run(cmd, nil); code != exitNotRunning {
+			t.Fatalf("%s: code %d, want %d", cmd, code, exitNotRunning)
+		}
+	}
+	if !strings.Contains(errOut.String(), "-headless") {
+		t.Fatalf("no hint: %s", errOut)
+	}
+}
+
+func TestCLIStatus(t *testing.T) {
+	fi := &fakeInstance{running: true}
+	env, out, errOut := testEnv(t, fi.start(t))
+	if code := env.run("status", []string{"-json"}); code != exitOK {
+		t.Fatalf("status: code %d: %s", code, errOut)
+	}
+	var rep statusReport
+	if err := json.Unmarshal(out.Bytes(), &rep); err != nil {
+		t.Fatalf("status json: %v: %s", err, out)
+	}
+	if rep.Launcher != "running" || !rep.CoreRunning || rep.ActiveProxy != "jp-1" || rep.StateFound {
+		t.Fatalf("status = %+v", rep)
+	}
+
+	fi.running = false
+	out.Reset()
+	if code := env.run("status", nil); code != exitNotRunning || !strings.Contains(out.String(), "core:     stopped") {
+		t.Fatalf("status stopped: code %d: %s", code, out)
+	}
+}
+
+func TestCLIInstanceErrorIsReported(t *testing.T) {
+	env, _, errOut := testEnv(t, (&fakeInstance{}).start(t))
+	if code := env.run("build-config", nil); code != exitFailure {
+		t.Fatalf("build-config: code %d", code)
+	}
+	if !strings.Contains(errOut.String(), "template not found") {
+		t.Fatalf("error not surfaced: %s", errOut)
+	}
+}
+
+func TestCLIExportState(t *testing.T) {
+	env, out, errOut := testEnv(t, nil)
+	if code := env.run("export-state", nil); code != exitFailure {
+		t.Fatalf("missing state: code %d", code)
+	}
+
+	statePath := platform.GetWizardStatePath(env.execDir)
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	st := state.New()
+	st.Vars = []state.SettingVar{{Name: "tun", Value: "true"}}
+	if err := st.Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if code := env.run("export-state", nil); code != exitOK {
+		t.Fatalf("export: code %d: %s", code, errOut)
+	}
+	// The export is state.json as Load normalizes it — loadable as is.
+	exported := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(exported, out.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := state.Load(exported)
+	if err != nil {
+		t.Fatalf("exported state does not load: %v\n%s", err, out)
+	}
+	if len(got.Vars) != 1 || got.Vars[0].Value != "true" || !got.UpdatedAt.Equal(st.UpdatedAt.Truncate(time.Second)) {
+		t.Fatalf("exported state = %+v", got)
+	}
+
+	dst := filepath.Join(t.TempDir(), "out.json")
+	if code := env.run("export-state", []string{"-o", dst}); code != exitOK {
+		t.Fatalf("export -o: code %d: %s", code, errOut)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, out.Bytes()) {
+		t.Fatal("export -o differs from stdout export")
+	}
+}
+
+func TestCLICheckMissingConfig(t *testing.T) {
+	env, _, errOut := testEnv(t, nil)
+	if code := env.run("check", nil); code != exitFailure || !strings.Contains(errOut.String(), "config") {
+		t.Fatalf("check: code %d: %s", code, errOut)
+	}
+}
+
+// With at-rest encryption on, the CLI opens settings.json the way the GUI
+// does; still locked, it names the env var instead of sending the sealed
+// token and getting a 401.
+func TestCLISecretsEncrypted(t *testing.T) {
+	fi := &fakeInstance{}
+	inst := fi.start(t)
+	env, _, errOut := testEnv(t, nil)
+	env.instance = func() *instanceClient { return findInstance(env.execDir) }
+	binDir := platform.GetBinDir(env.execDir)
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = secretstore.Init(t.TempDir()) })
+	if err := secretstore.Enable(binDir, secretstore.SourcePassphrase, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(inst.base)
+	port, _ := strconv.Atoi(u.Port())
+	if err := locale.SaveSettings(binDir, locale.Settings{DebugAPIEnabled: true, DebugAPIPort: port, DebugAPIToken: "tok"}); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(binDir, "settings.json")); !strings.Contains(string(raw), secretstore.Prefix) {
+		t.Fatalf("token not sealed: %s", raw)
+	}
+
+	t.Setenv(secretstore.PassphraseEnv, "")
+	env.unlockSecrets()
+	for _, cmd := range []string{"start", "export-state"} {
+		errOut.Reset()
+		if code := env.run(cmd, nil); code != exitFailure || !strings.Contains(errOut.String(), secretstore.PassphraseEnv) {
+			t.Fatalf("%s while locked: code %d: %s", cmd, code, errOut)
+		}
+	}
+	if len(fi.calls) != 0 {
+		t.Fatalf("calls while locked: %v", fi.calls)
+	}
+
+	t.Setenv(secretstore.PassphraseEnv, "correct horse")
+	env.unlockSecrets()
+	if code := env.run("start", nil); code != exitOK || !fi.running {
+		t.Fatalf("start: code %d: %s", code, errOut)
+	}
+}
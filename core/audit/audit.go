@@ -0,0 +1,277 @@
+// Package audit — журнал действий с конфигурацией и управлением:
+// logs/audit.jsonl, по строке JSON на действие, только дозапись.
+//
+// Вопрос, на который он отвечает: кто поменял маршрутизацию — Конфигуратор,
+// скрипт через Debug API, автообновление или переключатель в трее. Поэтому у
+// каждой записи есть актор и краткое «было/стало», а не только факт вызова
+// (его уже пишет журнал Debug API, logs/debugapi_audit.jsonl).
+//
+// Пакет листовой: его импортируют core, core/services и core/debugapi.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// LogName — имя журнала в logs/.
+const LogName = "audit.jsonl"
+
+// logMaxSize — при превышении журнал уезжает в .1 (одна копия).
+const logMaxSize = 1 << 20
+
+// Actor — кто выполнил действие.
+type Actor string
+
+const (
+	// ActorUI — окно лаунчера (Конфигуратор, вкладки, диалоги).
+	ActorUI Actor = "ui"
+	// ActorTray — меню в трее.
+	ActorTray Actor = "tray"
+	// ActorScheduler — автоматика: автообновление подписок, автозапуск,
+	// сторож связности, фоновая сверка машин.
+	ActorScheduler Actor = "scheduler"
+	// ActorCLI — команды командной строки (cli.go) и сигнал остановки
+	// headless-процессу.
+	ActorCLI Actor = "cli"
+	// ActorSystem — лаунчер сам, как шаг другого действия: пересборка перед
+	// стартом и после обновления подписок, перезапуск после смены уровня
+	// логов.
+	ActorSystem Actor = "system"
+	// ActorAPI — Debug API без известного токена; с токеном — APIActor.
+	ActorAPI Actor = "api"
+)
+
+// APIActor — вызов Debug API с токеном name: "api:<name>".
+func APIActor(name string) Actor {
+	if name == "" {
+		return ActorAPI
+	}
+	return Actor(string(ActorAPI) + ":" + name)
+}
+
+// Kind — актор без имени токена: "api:grafana" → "api". Для фильтра.
+func (a Actor) Kind() Actor {
+	if i := strings.IndexByte(string(a), ':'); i >= 0 {
+		return a[:i]
+	}
+	return a
+}
+
+// ActorKinds — виды акторов в порядке показа фильтра.
+var ActorKinds = []Actor{ActorUI, ActorTray, ActorAPI, ActorScheduler, ActorCLI, ActorSystem}
+
+// Action — что сделано.
+type Action string
+
+const (
+	ActionStateChange   Action = "state_change"   // state.json изменён (StateService.ApplyDiff)
+	ActionConfigRebuild Action = "config_rebuild" // config.json пересобран
+	ActionStart         Action = "start"
+	ActionStop          Action = "stop"
+	ActionRestart       Action = "restart"
+	ActionProxySwitch   Action = "proxy_switch"
+	ActionRemoteDeploy  Action = "remote_deploy"
+	ActionSourceRefresh Action = "source_refresh" // один источник или "all"
+)
+
+// Actions — действия в порядке показа фильтра.
+var Actions = []Action{
+	ActionStateChange, ActionConfigRebuild, ActionStart, ActionStop, ActionRestart,
+	ActionProxySwitch, ActionRemoteDeploy, ActionSourceRefresh,
+}
+
+// Entry — одна запись журнала. Before/After — короткие человекочитаемые
+// сводки («3 sources, 12 rules», хеш config.json, имя прокси), не снимки.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Actor  Actor     `json:"actor"`
+	Action Action    `json:"action"`
+	// Target — над чем: группа прокси, id источника или машины, изменённые
+	// домены state.
+	Target string `json:"target,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WithErr — запись с текстом ошибки (nil ничего не меняет).
+func (e Entry) WithErr(err error) Entry {
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+type actorCtxKey struct{}
+
+// WithActor кладёт актора в контекст — для путей, где контекст уже есть.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, a)
+}
+
+// ActorFrom — актор из контекста; def, если его там нет.
+func ActorFrom(ctx context.Context, def Actor) Actor {
+	if ctx != nil {
+		if a, ok := ctx.Value(actorCtxKey{}).(Actor); ok && a != "" {
+			return a
+		}
+	}
+	return def
+}
+
+// Filter — отбор записей при чтении. Пустые поля не ограничивают.
+type Filter struct {
+	// Actor — вид актора (ActorAPI совпадает со всеми "api:<name>") или
+	// точное имя ("api:grafana").
+	Actor Actor
+	// Action — одно действие.
+	Action Action
+	// Since — не раньше.
+	Since time.Time
+	// Text — подстрока (без регистра) в target/before/after/error/actor.
+	Text string
+}
+
+// Match — подходит ли запись под фильтр.
+func (f Filter) Match(e Entry) bool {
+	if f.Actor != "" && e.Actor != f.Actor && e.Actor.Kind() != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if t := strings.ToLower(strings.TrimSpace(f.Text)); t != "" {
+		hay := strings.ToLower(strings.Join([]string{string(e.Actor), e.Target, e.Before, e.After, e.Error}, "\n"))
+		if !strings.Contains(hay, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Log — журнал в одном файле. nil — записи уходят только в debuglog.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+var (
+	logsMu sync.Mutex
+	logs   = map[string]*Log{}
+)
+
+// For — журнал logs/audit.jsonl под execDir. Один на путь: core и
+// services пишут в него из разных мест, а дозапись и ротация должны идти
+// под одним мьютексом. Пустой execDir — nil: Append пишет только в
+// debuglog, Read отдаёт пустой список.
+func For(execDir string) *Log {
+	if execDir == "" {
+		return nil
+	}
+	path := filepath.Join(platform.GetLogsDir(execDir), LogName)
+	logsMu.Lock()
+	defer logsMu.Unlock()
+	if l, ok := logs[path]; ok {
+		return l
+	}
+	l := &Log{path: path}
+	logs[path] = l
+	return l
+}
+
+// Path — путь файла журнала.
+func (l *Log) Path() string { return l.path }
+
+// Append дописывает запись и дублирует её в debuglog. Ошибка записи
+// действие не отменяет — она только логируется.
+func (l *Log) Append(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	debuglog.InfoLog("audit: %s by %s target=%q %q → %q err=%q", e.Action, e.Actor, e.Target, e.Before, e.After, e.Error)
+	if l == nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := appendLine(l.path, line); err != nil {
+		debuglog.WarnLog("audit: write %s: %v", l.path, err)
+	}
+}
+
+func appendLine(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Size()+int64(len(line)) > logMaxSize {
+		_ = os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, platform.DefaultFileMode)
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(append(line, '\n'))
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	return werr
+}
+
+// Read — последние limit подходящих записей, новые в конце; limit <= 0 —
+// все. Читает и ротированную копию .1, чтобы ротация не обрезала выборку
+// по фильтру посередине.
+func (l *Log) Read(f Filter, limit int) ([]Entry, error) {
+	if l == nil {
+		return []Entry{}, nil
+	}
+	l.mu.Lock()
+	old, oerr := os.ReadFile(l.path + ".1")
+	cur, err := os.ReadFile(l.path)
+	l.mu.Unlock()
+	if oerr != nil && !os.IsNotExist(oerr) {
+		return nil, oerr
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	out := parse(old, f, nil)
+	out = parse(cur, f, out)
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out, nil
+}
+
+// parse разбирает JSONL; битые строки (обрыв записи) пропускаются.
+func parse(data []byte, f Filter, out []Entry) []Entry {
+	if out == nil {
+		out = []Entry{}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil && e.Action != "" && f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
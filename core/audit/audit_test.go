@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogAppendReadFilter(t *testing.T) {
+	l := For(t.TempDir())
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	l.Append(Entry{Time: t0, Actor: ActorUI, Action: ActionStateChange, Target: "rules", Before: "3 rules", After: "4 rules"})
+	l.Append(Entry{Time: t0.Add(time.Minute), Actor: APIActor("grafana"), Action: ActionStart})
+	l.Append(Entry{Time: t0.Add(2 * time.Minute), Actor: ActorScheduler, Action: ActionSourceRefresh, Target: "sub-1"}.WithErr(errors.New("HTTP 503")))
+	l.Append(Entry{Time: t0.Add(3 * time.Minute), Actor: ActorTray, Action: ActionProxySwitch, Target: "proxy-out", Before: "nl-1", After: "de-2"})
+
+	// Обрыв записи не должен ронять чтение.
+	f, err := os.OpenFile(l.Path(), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"time":"2026-10-01T12:04:00Z","act`)
+	_ = f.Close()
+
+	for _, c := range []struct {
+		name string
+		f    Filter
+		want []Action
+	}{
+		{"all", Filter{}, []Action{ActionStateChange, ActionStart, ActionSourceRefresh, ActionProxySwitch}},
+		{"api kind", Filter{Actor: ActorAPI}, []Action{ActionStart}},
+		{"api exact", Filter{Actor: "api:grafana"}, []Action{ActionStart}},
+		{"api other token", Filter{Actor: "api:cron"}, nil},
+		{"action", Filter{Action: ActionProxySwitch}, []Action{ActionProxySwitch}},
+		{"since", Filter{Since: t0.Add(90 * time.Second)}, []Action{ActionSourceRefresh, ActionProxySwitch}},
+		{"text in error", Filter{Text: "503"}, []Action{ActionSourceRefresh}},
+		{"text in before", Filter{Text: "NL-1"}, []Action{ActionProxySwitch}},
+	} {
+		got, err := l.Read(c.f, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var acts []Action
+		for _, e := range got {
+			acts = append(acts, e.Action)
+		}
+		if strings.Join(actionNames(acts), ",") != strings.Join(actionNames(c.want), ",") {
+			t.Errorf("%s: %v, want %v", c.name, acts, c.want)
+		}
+	}
+	if got, _ := l.Read(Filter{}, 1); len(got) != 1 || got[0].Action != ActionProxySwitch {
+		t.Errorf("limit 1 = %+v", got)
+	}
+}
+
+func actionNames(a []Action) []string {
+	out := make([]string, len(a))
+	for i, x := range a {
+		out[i] = string(x)
+	}
+	return out
+}
+
+// Ротация: записи из .1 по-прежнему видны фильтру.
+func TestLogReadsRotatedCopy(t *testing.T) {
+	l := For(t.TempDir())
+	l.Append(Entry{Actor: ActorUI, Action: ActionStop})
+	if err := os.Rename(l.Path(), l.Path()+".1"); err != nil {
+		t.Fatal(err)
+	}
+	l.Append(Entry{Actor: ActorUI, Action: ActionStart})
+	got, err := l.Read(Filter{}, 0)
+	if err != nil || len(got) != 2 || got[0].Action != ActionStop || got[1].Action != ActionStart {
+		t.Errorf("Read = %+v (%v)", got, err)
+	}
+}
+
+func TestMissingLogIsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	for _, l := range []*Log{For(dir), For("")} {
+		got, err := l.Read(Filter{}, 0)
+		if err != nil || got == nil || len(got) != 0 {
+			t.Errorf("Read = %#v (%v)", got, err)
+		}
+	}
+	For("").Append(Entry{Actor: ActorUI, Action: ActionStart})
+	if For(dir) != For(dir) {
+		t.Error("For must return one Log per path")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "logs")); !os.IsNotExist(err) {
+		t.Errorf("reading created logs/: %v", err)
+	}
+}
+
+func TestActorFromContext(t *testing.T) {
+	if got := ActorFrom(context.Background(), ActorUI); got != ActorUI {
+		t.Errorf("default = %q", got)
+	}
+	ctx := WithActor(context.Background(), APIActor("cron"))
+	if got := ActorFrom(ctx, ActorUI); got != "api:cron" || got.Kind() != ActorAPI {
+		t.Errorf("from ctx = %q (kind %q)", got, got.Kind())
+	}
+	if APIActor("") != ActorAPI {
+		t.Error("APIActor(\"\") must be plain api")
+	}
+}
@@ -0,0 +1,203 @@
+package core
+
+// Журнал действий (core/audit, logs/audit.jsonl): кто менял состояние,
+// пересобирал конфиг, запускал и останавливал ядро, переключал прокси,
+// деплоил машины и обновлял источники. Актора передаёт точка входа — окно,
+// трей, Debug API, планировщик, CLI; сами действия его не угадывают.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/config"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/platform"
+)
+
+func (ac *AppController) audits() *audit.Log {
+	if ac.FileService == nil {
+		return nil
+	}
+	return audit.For(ac.FileService.ExecDir)
+}
+
+// RecordAudit дописывает запись в журнал действий. Без FileService запись
+// уходит только в debuglog.
+func (ac *AppController) RecordAudit(e audit.Entry) {
+	if ac == nil {
+		return
+	}
+	ac.audits().Append(e)
+}
+
+// AuditLog — последние limit записей журнала по фильтру, новые в конце.
+func (ac *AppController) AuditLog(f audit.Filter, limit int) ([]audit.Entry, error) {
+	return ac.audits().Read(f, limit)
+}
+
+// StateSnapshot — state.json с диска для «было» в ApplyStateChange; nil,
+// если его ещё нет или он не читается.
+func (ac *AppController) StateSnapshot() *state.State {
+	if ac == nil || ac.FileService == nil {
+		return nil
+	}
+	s, err := state.Load(platform.GetWizardStatePath(ac.FileService.ExecDir))
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// ApplyStateChange — state.json уже записан: разница с before переводится
+// в dirty-маркеры (StateService.ApplyDiff) и пишется в журнал. «Стало»
+// читается с диска, а не берётся из памяти вызывающего: так обе стороны
+// прошли одну нормализацию Load. Пустая разница — ни маркеров, ни записи.
+func (ac *AppController) ApplyStateChange(actor audit.Actor, before *state.State) state.Diff {
+	after := ac.StateSnapshot()
+	d := state.Compare(before, after)
+	if d.IsEmpty() {
+		return d
+	}
+	if ac.StateService != nil {
+		ac.StateService.ApplyDiff(d)
+	}
+	ac.RecordAudit(audit.Entry{
+		Actor:  actor,
+		Action: audit.ActionStateChange,
+		Target: strings.Join(d.Changed(), ","),
+		Before: state.Summary(before),
+		After:  state.Summary(after),
+	})
+	return d
+}
+
+// RebuildConfigBy — RebuildConfigIfDirty с записью в журнал. Пишется
+// пересборка, которая была (маркер горел или forced), и любая ошибка;
+// no-op при чистых маркерах — нет. Было/стало — хеш config.json.
+func (ac *AppController) RebuildConfigBy(actor audit.Actor, forced bool) error {
+	if ac == nil || ac.StateService == nil {
+		return nil
+	}
+	dirty := forced || ac.StateService.IsCacheStale() || ac.StateService.IsConfigStale()
+	before := ac.configDigest()
+	err := ac.rebuildConfig(forced)
+	if dirty || err != nil {
+		target := ""
+		if forced {
+			target = "forced"
+		}
+		ac.RecordAudit(audit.Entry{Actor: actor, Action: audit.ActionConfigRebuild, Target: target,
+			Before: before, After: ac.configDigest()}.WithErr(err))
+	}
+	return err
+}
+
+// configDigest — первые 12 символов sha256 config.json; "none", если
+// файла нет.
+func (ac *AppController) configDigest() string {
+	if ac.FileService == nil {
+		return ""
+	}
+	raw, err := os.ReadFile(ac.FileService.ConfigPath)
+	if err != nil {
+		return "none"
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// runStateWord — «было» для start/stop/restart.
+func (ac *AppController) runStateWord() string {
+	if ac.RunningState != nil && ac.RunningState.IsRunning() {
+		return "running"
+	}
+	return "stopped"
+}
+
+// recordRun пишет start/stop/restart. Команда асинхронная, поэтому «стало»
+// — запрошенное состояние, а не проверенное.
+func (ac *AppController) recordRun(actor audit.Actor, action audit.Action, after string) {
+	ac.RecordAudit(audit.Entry{Actor: actor, Action: action, Before: ac.runStateWord(), After: after})
+}
+
+// SwitchProxyBy переключает прокси в группе локального ядра и пишет это в
+// журнал: было — активный прокси до переключения.
+func (ac *AppController) SwitchProxyBy(actor audit.Actor, group, name string) error {
+	if ac.APIService == nil {
+		return fmt.Errorf("API service not initialized")
+	}
+	before := ac.GetActiveProxyName()
+	err := ac.APIService.SwitchProxy(group, name)
+	ac.RecordAudit(audit.Entry{Actor: actor, Action: audit.ActionProxySwitch, Target: group,
+		Before: before, After: name}.WithErr(err))
+	return err
+}
+
+// RecordProxySwitch — для путей, которые переключают через свой транспорт
+// (вкладка Servers с remote-override, Remote API).
+func (ac *AppController) RecordProxySwitch(actor audit.Actor, group, before, after string, err error) {
+	ac.RecordAudit(audit.Entry{Actor: actor, Action: audit.ActionProxySwitch, Target: group,
+		Before: before, After: after}.WithErr(err))
+}
+
+// RefreshSourceBy — обновление одного источника (fetch + meta + raw) с
+// записью в журнал.
+func (ac *AppController) RefreshSourceBy(actor audit.Actor, id string) (*state.Source, error) {
+	if ac.ConfigService == nil {
+		return nil, fmt.Errorf("config service not initialized")
+	}
+	var before *state.Source
+	if s := ac.StateSnapshot(); s != nil {
+		for i := range s.Connections.Sources {
+			if s.Connections.Sources[i].ID == id {
+				before = &s.Connections.Sources[i]
+			}
+		}
+	}
+	after, err := ac.ConfigService.RefreshSingleSubscription(id)
+	ac.RecordSourceRefresh(actor, id, before, after, err)
+	return after, err
+}
+
+// RecordSourceRefresh пишет обновление источника id; before/after — его
+// состояние до и после (nil — неизвестно).
+func (ac *AppController) RecordSourceRefresh(actor audit.Actor, id string, before, after *state.Source, err error) {
+	ac.RecordAudit(audit.Entry{Actor: actor, Action: audit.ActionSourceRefresh, Target: id,
+		Before: sourceSummary(before), After: sourceSummary(after)}.WithErr(err))
+}
+
+// UpdateSubscriptionsBy — обновление всех подписок (с пересборкой) и
+// запись в журнал под Target "all"; было/стало — хеш config.json.
+func (ac *AppController) UpdateSubscriptionsBy(actor audit.Actor) (*config.OutboundGenerationResult, error) {
+	if ac.ConfigService == nil {
+		return nil, fmt.Errorf("config service not initialized")
+	}
+	before := ac.configDigest()
+	res, err := ac.ConfigService.UpdateConfigFromSubscriptions()
+	ac.RecordAudit(audit.Entry{Actor: actor, Action: audit.ActionSourceRefresh, Target: "all",
+		Before: before, After: ac.configDigest()}.WithErr(err))
+	return res, err
+}
+
+// sourceSummary — «N nodes, ok/err, когда» из meta источника.
+func sourceSummary(src *state.Source) string {
+	if src == nil {
+		return ""
+	}
+	m := src.Meta
+	if m == nil {
+		return "never fetched"
+	}
+	out := fmt.Sprintf("%d nodes", m.NodesCountFetched)
+	if m.LastStatus != "" {
+		out += ", " + m.LastStatus
+	}
+	if m.LastFetchedAt != "" {
+		out += " at " + m.LastFetchedAt
+	}
+	return out
+}
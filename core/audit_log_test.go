@@ -0,0 +1,72 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/platform"
+)
+
+// ApplyStateChange: разница со снимком ставит только свои маркеры и пишет
+// одну запись с актором; повторный вызов без изменений — ни того, ни другого.
+func TestApplyStateChange(t *testing.T) {
+	dir := t.TempDir()
+	ac := &AppController{FileService: &services.FileService{ExecDir: dir}, StateService: services.NewStateService()}
+	statePath := platform.GetWizardStatePath(dir)
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.New().Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	before := ac.StateSnapshot()
+	next := state.New()
+	next.Vars = []state.SettingVar{{Name: "log_level", Value: "debug"}}
+	if err := next.Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	d := ac.ApplyStateChange(audit.APIActor("cron"), before)
+	if !d.VarsChanged || d.ProxiesChanged {
+		t.Errorf("diff = %+v", d)
+	}
+	if ac.StateService.IsCacheStale() || !ac.StateService.IsConfigStale() {
+		t.Errorf("markers: cache=%v config=%v", ac.StateService.IsCacheStale(), ac.StateService.IsConfigStale())
+	}
+	ac.ApplyStateChange(audit.ActorUI, ac.StateSnapshot())
+
+	got, err := ac.AuditLog(audit.Filter{Action: audit.ActionStateChange}, 0)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("AuditLog = %+v (%v)", got, err)
+	}
+	if e := got[0]; e.Actor != "api:cron" || e.Target != "vars" || e.After != state.Summary(next) {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+// stoppingBackend останавливает ядро на выходе, как classic.
+type stoppingBackend struct {
+	fakeBackend
+	rs *RunningState
+}
+
+func (b *stoppingBackend) OnAppExit() bool { b.rs.Set(false); return true }
+
+// Остановка ядра на выходе пишется от имени того, кто завершил лаунчер.
+func TestGracefulExitRecordsActor(t *testing.T) {
+	ac := &AppController{FileService: &services.FileService{ExecDir: t.TempDir()}}
+	ac.RunningState = &RunningState{controller: ac}
+	ac.RunningState.Set(true)
+	ac.setBackend(&stoppingBackend{rs: ac.RunningState})
+	ac.GracefulExit(audit.ActorCLI)
+	ac.GracefulExit(audit.ActorUI) // повтор — no-op
+
+	got, err := ac.AuditLog(audit.Filter{Action: audit.ActionStop}, 0)
+	if err != nil || len(got) != 1 || got[0].Actor != audit.ActorCLI || got[0].Target != "exit" {
+		t.Fatalf("AuditLog = %+v (%v)", got, err)
+	}
+}
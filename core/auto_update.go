@@ -3,6 +3,7 @@ package core
 import (
 	"time"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/events"
 	"singbox-launcher/core/state"
 	"singbox-launcher/internal/ctxutil"
@@ -175,7 +176,7 @@ func (ac *AppController) refreshSourceWithRetry(sourceID, trigger string) {
 		return
 	}
 	debuglog.DebugLog("Auto-update[%s]: refreshing source %s", trigger, sourceID)
-	_, err := ac.ConfigService.RefreshSingleSubscription(sourceID)
+	_, err := ac.RefreshSourceBy(audit.ActorScheduler, sourceID)
 	if err == nil {
 		ac.cancelRetryTimer(sourceID) // на success отменяем pending retry
 		return
@@ -204,7 +205,7 @@ func (ac *AppController) scheduleSourceRetry(sourceID string) {
 			return
 		}
 		debuglog.InfoLog("Auto-update[retry-15s]: refreshing source %s", sourceID)
-		if _, err := ac.ConfigService.RefreshSingleSubscription(sourceID); err != nil {
+		if _, err := ac.RefreshSourceBy(audit.ActorScheduler, sourceID); err != nil {
 			debuglog.WarnLog("Auto-update[retry-15s]: source %s still failing: %v "+
 				"(next attempt at next heartbeat or VPN event)", sourceID, err)
 		}
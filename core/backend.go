@@ -19,7 +19,7 @@ const (
 	// процессом через Monitor/Wait.
 	BackendClassic BackendMode = "classic"
 	// BackendDaemon — ядро живёт внутри долгоживущего демона `sing-box lxd`
-	// (launchd-служба на macOS); лаунчер управляет им по gRPC + admin REST,
+	// (launchd на macOS, systemd на Linux); лаунчер управляет им по gRPC + admin REST,
 	// смена конфига — in-process подмена инстанса без убийства процесса.
 	BackendDaemon BackendMode = "daemon"
 )
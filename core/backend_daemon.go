@@ -1,4 +1,4 @@
-//go:build darwin
+//go:build darwin || linux
 
 package core
 
@@ -25,7 +25,7 @@ import (
 )
 
 // DaemonBackend — движок daemon-режима: ядро живёт внутри долгоживущего
-// `sing-box lxd` (launchd-служба), лаунчер управляет им по admin REST
+// `sing-box lxd` (служба launchd на macOS, unit systemd на Linux), лаунчер управляет им по admin REST
 // (apply/start/stop) и наблюдает по gRPC daemon.StartedService (протокол
 // Android-линии). Смена конфига — in-process подмена инстанса в демоне:
 // без убийства процесса, без пароля, с валидацией и автооткатом на
@@ -293,6 +293,8 @@ func (b *DaemonBackend) applyCurrentConfig(caller string, forced bool) {
 	}
 	ac.RunningState.Set(true) // стрим статусов подтвердит
 	ac.StateService.ResetAutoUpdateFailedAttempts()
+	// Падения ядра демон переживает сам; правила стоят, пока не нажат Stop.
+	ac.armKillSwitch()
 	debuglog.InfoLog("daemon.%s: config applied, core is up", caller)
 	b.refreshUI()
 
@@ -320,6 +322,7 @@ func (b *DaemonBackend) StopVPN() {
 			return
 		}
 		ac.RunningState.Set(false)
+		ac.releaseKillSwitch(false)
 	}()
 }
 
@@ -335,6 +338,7 @@ func (b *DaemonBackend) OnAppExit() bool {
 		debuglog.WarnLog("daemon.OnAppExit: stop failed: %v", err)
 	}
 	b.ac.RunningState.Set(false)
+	b.ac.releaseKillSwitch(false)
 	return true
 }
 
@@ -666,5 +670,34 @@ func (t *daemonProxyTransport) Delay(proxyName string) (int64, error) {
 	return int64(resp.GetDelay()), nil
 }
 
+// CloseConnection implements services.ConnectionCloser через CloseConnection.
+func (t *daemonProxyTransport) CloseConnection(id string) error {
+	client, ctx, cancel, err := t.rpc()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if _, err := client.CloseConnection(ctx, &daemonpb.CloseConnectionRequest{Id: id}); err != nil {
+		return fmt.Errorf("daemon CloseConnection: %w", err)
+	}
+	return nil
+}
+
+// CloseAllConnections implements services.ConnectionCloser через CloseAllConnections.
+func (t *daemonProxyTransport) CloseAllConnections() error {
+	client, ctx, cancel, err := t.rpc()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if _, err := client.CloseAllConnections(ctx, &emptypb.Empty{}); err != nil {
+		return fmt.Errorf("daemon CloseAllConnections: %w", err)
+	}
+	return nil
+}
+
 // Убедимся на компиляции, что транспорт реализует интерфейс.
-var _ services.ProxyTransport = (*daemonProxyTransport)(nil)
+var (
+	_ services.ProxyTransport   = (*daemonProxyTransport)(nil)
+	_ services.ConnectionCloser = (*daemonProxyTransport)(nil)
+)
@@ -0,0 +1,29 @@
+//go:build darwin || linux
+
+package core
+
+import (
+	"context"
+
+	"singbox-launcher/core/netdiag"
+)
+
+// netQuality реализует netDiagSource для daemon-режима: StartNetworkQualityTest
+// через outbound демона. Зонд не нужен — демон сам умеет гнать тест через
+// любой свой outbound, не трогая маршрут пользователя.
+func (b *DaemonBackend) netQuality(ctx context.Context, t NetDiagTarget, opts netdiag.QualityOptions, onProgress func(netdiag.QualityResult)) (netdiag.QualityResult, error) {
+	client, err := b.grpcClient()
+	if err != nil {
+		return netdiag.QualityResult{Via: netdiag.ViaDaemon}, err
+	}
+	return netdiag.RunDaemonQuality(ctx, client, netdiag.ViaDaemon, t.Tag, opts, onProgress)
+}
+
+// netNAT реализует netDiagSource для daemon-режима: StartSTUNTest.
+func (b *DaemonBackend) netNAT(ctx context.Context, t NetDiagTarget, server string) (netdiag.NATResult, error) {
+	client, err := b.grpcClient()
+	if err != nil {
+		return netdiag.NATResult{Via: netdiag.ViaDaemon, Server: server}, err
+	}
+	return netdiag.RunDaemonNAT(ctx, client, netdiag.ViaDaemon, t.Tag, server)
+}
@@ -0,0 +1,41 @@
+//go:build darwin || linux
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	daemonpb "singbox-launcher/internal/daemonpb"
+)
+
+// streamCoreStats реализует coreStatsSource для daemon-режима: gRPC
+// SubscribeStatus отдаёт скорость и память одним кадром, Clash для этого не
+// нужен (в lxd-конфиге его может и не быть).
+func (b *DaemonBackend) streamCoreStats(ctx context.Context, onStats func(CoreStats)) error {
+	client, err := b.grpcClient()
+	if err != nil {
+		return err
+	}
+	// Interval — наносекунды, как и у прочих Subscribe*.
+	stream, err := client.SubscribeStatus(ctx, &daemonpb.SubscribeStatusRequest{Interval: int64(time.Second)})
+	if err != nil {
+		return fmt.Errorf("daemon SubscribeStatus: %w", err)
+	}
+	for {
+		st, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("daemon SubscribeStatus: %w", err)
+		}
+		onStats(CoreStats{
+			Up:     st.GetUplink(),
+			Down:   st.GetDownlink(),
+			Memory: st.GetMemory(),
+			At:     time.Now(),
+		})
+	}
+}
@@ -1,15 +1,15 @@
-//go:build !darwin
+//go:build !darwin && !linux
 
 package core
 
 import "fmt"
 
-// newDaemonBackend — заглушка: daemon-режим (sing-box lxd + launchd) пока
-// реализован только на macOS; на остальных платформах форк не предоставляет
-// установку службы (`--service` возвращает "not implemented").
+// newDaemonBackend — заглушка: daemon-режим (sing-box lxd) реализован для
+// macOS (launchd) и Linux (systemd); на Windows менеджера служб для демона
+// нет ни у форка, ни у лаунчера.
 func newDaemonBackend(_ *AppController) (CoreBackend, error) {
-	return nil, fmt.Errorf("daemon mode is only available on macOS")
+	return nil, fmt.Errorf("daemon mode is only available on macOS and Linux")
 }
 
-// notifyDaemonServiceAfterCoreUpdate — no-op вне macOS (службы демона нет).
+// notifyDaemonServiceAfterCoreUpdate — no-op без daemon-режима (службы нет).
 func (ac *AppController) notifyDaemonServiceAfterCoreUpdate() {}
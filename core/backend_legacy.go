@@ -35,6 +35,7 @@ func (b *LegacyBackend) RestartVPN() {
 // OnAppExit implements CoreBackend: classic всегда останавливает ядро при
 // выходе из лаунчера (как делал GracefulExit → StopSingBoxProcess).
 func (b *LegacyBackend) OnAppExit() bool {
+	b.ac.releaseKillSwitch(false) // дождаться: после выхода снять будет некому
 	b.ac.ProcessService.Stop()
 	return true
 }
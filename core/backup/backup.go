@@ -0,0 +1,513 @@
+// Package backup — переносимая резервная копия профиля лаунчера: настройки,
+// состояния визарда, кэши подписок, регистрации WARP, реестр машин с
+// ключами и локальные rule-set'ы в одном файле, зашифрованном паролем.
+//
+// Зачем: переезд на новый ноутбук был копированием bin/ руками — вместе с
+// ядрами, логами и собранным config.json под чужие пути, а с шифрованием
+// секретов (internal/secretstore) ещё и без ключа, которым они запечатаны.
+// Копия несёт только данные пользователя, секреты в ней открыты внутри
+// конверта, а при восстановлении запечатываются ключом получателя.
+//
+// Конверт — как у пакета машин (services/lxd_remote_bundle.go): scrypt →
+// AES-256-GCM, заголовок — associated data. Внутри — gzip JSON-payload со
+// своей версией. Состояния визарда при восстановлении проходят через
+// state.Parse, то есть копия старой версии лаунчера мигрирует теми же
+// загрузчиками, что и state.json на диске.
+//
+// Чего в копии нет: ядер, логов, шаблона визарда и собранных config.json —
+// это производные, они скачиваются и собираются заново.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
+)
+
+// Category — часть профиля, которую можно сохранить и восстановить отдельно.
+type Category string
+
+const (
+	// CategorySettings — bin/settings.json.
+	CategorySettings Category = "settings"
+	// CategoryStates — локальный state.json и именованные состояния визарда
+	// (bin/wizard_states/*.json).
+	CategoryStates Category = "states"
+	// CategoryWarp — регистрации WARP локального профиля (warp_accounts).
+	// Лежат внутри state.json, но восстанавливаются отдельно: можно вернуть
+	// старые состояния, не теряя регистрации, и наоборот.
+	CategoryWarp Category = "warp"
+	// CategorySubscriptions — тела подписок (bin/subscriptions/*.raw):
+	// конфиг собирается без сети сразу после восстановления.
+	CategorySubscriptions Category = "subscriptions"
+	// CategoryRemote — реестр машин, их клиентские пары, состояния и кэши
+	// (bin/wizard_states/remote/<id>/) и базовые профили.
+	CategoryRemote Category = "remote"
+	// CategoryRuleSets — локальные .srs (bin/rule-sets/).
+	CategoryRuleSets Category = "rule_sets"
+)
+
+// AllCategories — все категории в порядке показа.
+var AllCategories = []Category{
+	CategorySettings, CategoryStates, CategoryWarp,
+	CategorySubscriptions, CategoryRemote, CategoryRuleSets,
+}
+
+// ParseCategories разбирает список категорий; пусто — все.
+func ParseCategories(names []string) ([]Category, error) {
+	if len(names) == 0 {
+		return AllCategories, nil
+	}
+	seen := map[Category]bool{}
+	var out []Category
+	for _, n := range names {
+		c := Category(strings.ToLower(strings.TrimSpace(n)))
+		known := false
+		for _, k := range AllCategories {
+			known = known || k == c
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown category %q", n)
+		}
+		if !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// backupFormat — метка файла: чужой JSON (в том числе пакет машин)
+// отбивается до расшифровки.
+const backupFormat = "singbox-launcher/backup"
+
+// envelopeVersion — версия конверта; payloadVersion — раскладки внутри.
+const (
+	envelopeVersion = 1
+	payloadVersion  = 1
+)
+
+// MinPassphrase — минимальная длина пароля копии.
+const MinPassphrase = 8
+
+// Параметры scrypt — как у пакета машин (~100 мс на вход).
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// maxPayload — предел распакованного payload: gzip-бомба в чужом файле не
+// должна съесть память.
+const maxPayload = 512 << 20
+
+// Виртуальные пути payload'а: не файлы bin/ байт в байт, а данные, которые
+// восстанавливаются через свой владелец (SaveSettings, реестр, state).
+const (
+	settingsPath = "settings.json"
+	warpPath     = "warp_accounts.json"
+)
+
+var (
+	// ErrPassphrase — пароль не подошёл (или файл повреждён: GCM их не
+	// различает).
+	ErrPassphrase = errors.New("backup: wrong passphrase or damaged file")
+	// ErrNotBackup — файл не резервная копия лаунчера.
+	ErrNotBackup = errors.New("backup: not a launcher backup")
+)
+
+// envelope — файл копии. []byte в JSON — base64.
+type envelope struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// payload — расшифрованное содержимое.
+type payload struct {
+	Version         int           `json:"version"`
+	CreatedAt       string        `json:"created_at"`
+	LauncherVersion string        `json:"launcher_version,omitempty"`
+	OS              string        `json:"os,omitempty"`
+	Categories      []Category    `json:"categories"`
+	Files           []archiveFile `json:"files"`
+}
+
+// archiveFile — один файл: путь относительно bin/ через «/».
+type archiveFile struct {
+	Category Category `json:"category"`
+	Path     string   `json:"path"`
+	Mode     uint32   `json:"mode,omitempty"`
+	Data     []byte   `json:"data"`
+}
+
+// CategoryInfo — что лежит в копии по одной категории.
+type CategoryInfo struct {
+	Category Category `json:"category"`
+	Files    int      `json:"files"`
+	Bytes    int      `json:"bytes"`
+}
+
+// Manifest — описание копии без восстановления.
+type Manifest struct {
+	Version         int            `json:"version"`
+	CreatedAt       string         `json:"created_at"`
+	LauncherVersion string         `json:"launcher_version,omitempty"`
+	OS              string         `json:"os,omitempty"`
+	Categories      []CategoryInfo `json:"categories"`
+}
+
+// Create собирает копию выбранных категорий (пусто — все).
+func Create(execDir, launcherVersion, passphrase string, categories []Category) ([]byte, Manifest, error) {
+	if len(passphrase) < MinPassphrase {
+		return nil, Manifest{}, fmt.Errorf("backup: passphrase must be at least %d characters", MinPassphrase)
+	}
+	// Состояния и реестр на диске запечатаны ключом установки — без него
+	// копия унесла бы шум.
+	if secretstore.Locked() {
+		return nil, Manifest{}, secretstore.ErrLocked
+	}
+	if len(categories) == 0 {
+		categories = AllCategories
+	}
+	p := payload{
+		Version:         payloadVersion,
+		CreatedAt:       time.Now().UTC().Format(time.RFC3339),
+		LauncherVersion: launcherVersion,
+		OS:              runtime.GOOS,
+		Categories:      categories,
+	}
+	c := collector{execDir: execDir, binDir: platform.GetBinDir(execDir), p: &p}
+	for _, cat := range categories {
+		if err := c.collect(cat); err != nil {
+			return nil, Manifest{}, fmt.Errorf("backup: %s: %w", cat, err)
+		}
+	}
+
+	plain, err := json.Marshal(p)
+	if err != nil {
+		return nil, Manifest{}, fmt.Errorf("backup: %w", err)
+	}
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	if _, err := zw.Write(plain); err != nil {
+		return nil, Manifest{}, fmt.Errorf("backup: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, Manifest{}, fmt.Errorf("backup: %w", err)
+	}
+	env, err := seal(zipped.Bytes(), passphrase)
+	if err != nil {
+		return nil, Manifest{}, err
+	}
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, Manifest{}, fmt.Errorf("backup: %w", err)
+	}
+	m := p.manifest()
+	debuglog.InfoLog("backup: created %d files (%v)", len(p.Files), categories)
+	return out, m, nil
+}
+
+// Inspect открывает копию и описывает содержимое, ничего не трогая.
+func Inspect(raw []byte, passphrase string) (Manifest, error) {
+	p, err := open(raw, passphrase)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return p.manifest(), nil
+}
+
+func (p *payload) manifest() Manifest {
+	m := Manifest{Version: p.Version, CreatedAt: p.CreatedAt, LauncherVersion: p.LauncherVersion, OS: p.OS}
+	idx := map[Category]int{}
+	for _, c := range p.Categories {
+		if _, ok := idx[c]; !ok {
+			idx[c] = len(m.Categories)
+			m.Categories = append(m.Categories, CategoryInfo{Category: c})
+		}
+	}
+	for _, f := range p.Files {
+		if i, ok := idx[f.Category]; ok {
+			m.Categories[i].Files++
+			m.Categories[i].Bytes += len(f.Data)
+		}
+	}
+	return m
+}
+
+// collector собирает файлы одной копии.
+type collector struct {
+	execDir string
+	binDir  string
+	p       *payload
+}
+
+func (c *collector) add(cat Category, rel string, mode fs.FileMode, data []byte) {
+	c.p.Files = append(c.p.Files, archiveFile{Category: cat, Path: rel, Mode: uint32(mode.Perm()), Data: data})
+}
+
+func (c *collector) collect(cat Category) error {
+	switch cat {
+	case CategorySettings:
+		if _, err := os.Stat(filepath.Join(c.binDir, settingsPath)); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		// Через LoadSettings: секреты (токен Debug API, секрет демона) —
+		// открытыми.
+		data, err := json.MarshalIndent(locale.LoadSettings(c.binDir), "", "  ")
+		if err != nil {
+			return err
+		}
+		c.add(cat, settingsPath, platform.DefaultFileMode, data)
+		return nil
+
+	case CategoryStates:
+		return c.walk(cat, constants.WizardStatesDirName, false, isStateFile)
+
+	case CategoryWarp:
+		s, err := state.Load(platform.GetWizardStatePath(c.execDir))
+		if errors.Is(err, state.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if s.WarpAccounts == nil {
+			return nil
+		}
+		data, err := json.MarshalIndent(s.WarpAccounts, "", "  ")
+		if err != nil {
+			return err
+		}
+		c.add(cat, warpPath, 0o600, data)
+		return nil
+
+	case CategorySubscriptions:
+		return c.walk(cat, constants.SubscriptionsDirName, false, nil)
+
+	case CategoryRuleSets:
+		return c.walk(cat, constants.RuleSetsDirName, true, nil)
+
+	case CategoryRemote:
+		entries, err := services.NewRemoteRegistry(c.execDir).List()
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			data, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return err
+			}
+			c.add(cat, services.RemoteRegistryFileName, 0o600, data)
+		}
+		if err := c.walk(cat, services.RemoteIdentitiesDirName, true, nil); err != nil {
+			return err
+		}
+		if err := c.walk(cat, path.Join(constants.WizardStatesDirName, constants.BaseProfilesDirName), false, isStateFile); err != nil {
+			return err
+		}
+		// Собранный config.json машины — производная, Configure пересоберёт.
+		return c.walk(cat, path.Join(constants.WizardStatesDirName, constants.ConfigTargetRemote), true, func(rel string) bool {
+			return path.Base(rel) != constants.ConfigFileName
+		})
+	}
+	return fmt.Errorf("unknown category %q", cat)
+}
+
+// walk добавляет обычные файлы каталога relDir (путь в bin/); keep — фильтр
+// по пути в bin/, nil — все. Временные .tmp не берутся никогда.
+func (c *collector) walk(cat Category, relDir string, recursive bool, keep func(rel string) bool) error {
+	root := filepath.Join(c.binDir, filepath.FromSlash(relDir))
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if p != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		relOS, err := filepath.Rel(c.binDir, p)
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(relOS)
+		if keep != nil && !keep(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		switch {
+		case isStateFile(rel):
+			// Секреты профиля — открытыми: у получателя свой ключ.
+			if data, err = state.OpenSecretsRaw(data); err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+		case isRawCache(rel):
+			if data, err = state.OpenRawBody(data); err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+		}
+		c.add(cat, rel, info.Mode(), data)
+		return nil
+	})
+	return err
+}
+
+// isStateFile — файл состояния визарда (путь в bin/): *.json и снимки баз
+// под wizard_states/, кроме собранных config.json и каталогов кэшей машины.
+func isStateFile(rel string) bool {
+	if !strings.HasPrefix(rel, constants.WizardStatesDirName+"/") {
+		return false
+	}
+	for _, part := range strings.Split(path.Dir(rel), "/") {
+		if part == constants.SubscriptionsDirName || part == constants.RemoteRuleSetsDirName {
+			return false
+		}
+	}
+	base := path.Base(rel)
+	if base == constants.BaseProfileSnapshotFileName {
+		return true
+	}
+	return strings.HasSuffix(base, ".json") && base != constants.ConfigFileName
+}
+
+// isRawCache — тело подписки (путь в bin/): *.raw в каталоге subscriptions/,
+// локальном или машины.
+func isRawCache(rel string) bool {
+	return strings.HasSuffix(rel, ".raw") && path.Base(path.Dir(rel)) == constants.SubscriptionsDirName
+}
+
+// seal шифрует payload паролем.
+func seal(plain []byte, passphrase string) (envelope, error) {
+	env := envelope{
+		Format: backupFormat, Version: envelopeVersion,
+		KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP,
+		Salt: make([]byte, 16),
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return env, fmt.Errorf("backup: %w", err)
+	}
+	aead, err := newAEAD(passphrase, env)
+	if err != nil {
+		return env, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return env, fmt.Errorf("backup: %w", err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plain, associatedData(env))
+	return env, nil
+}
+
+// open проверяет формат, расшифровывает и распаковывает payload.
+func open(raw []byte, passphrase string) (*payload, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.Format != backupFormat {
+		return nil, ErrNotBackup
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("backup: file version %d is not supported by this launcher", env.Version)
+	}
+	// Параметры KDF читаются из файла — ограничены, чтобы чужой файл не
+	// заставил считать scrypt на гигабайты.
+	if env.KDF != "scrypt" || env.N < 2 || env.N > 1<<20 || env.R < 1 || env.R > 32 || env.P < 1 || env.P > 16 {
+		return nil, fmt.Errorf("backup: unsupported key derivation parameters")
+	}
+	aead, err := newAEAD(passphrase, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	zipped, err := aead.Open(nil, env.Nonce, env.Ciphertext, associatedData(env))
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	plain, err := io.ReadAll(io.LimitReader(zr, maxPayload+1))
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	if len(plain) > maxPayload {
+		return nil, fmt.Errorf("backup: payload is larger than %d MiB", maxPayload>>20)
+	}
+	var p payload
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	if p.Version < 1 || p.Version > payloadVersion {
+		return nil, fmt.Errorf("backup: made by a newer launcher (layout %d) — update this one first", p.Version)
+	}
+	sort.SliceStable(p.Files, func(i, j int) bool { return p.Files[i].Path < p.Files[j].Path })
+	return &p, nil
+}
+
+func newAEAD(passphrase string, env envelope) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), env.Salt, env.N, env.R, env.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// associatedData — заголовок конверта: подменить параметры KDF незаметно
+// нельзя.
+func associatedData(env envelope) []byte {
+	return fmt.Appendf(nil, "%s/%d/%s/%d/%d/%d", env.Format, env.Version, env.KDF, env.N, env.R, env.P)
+}
@@ -0,0 +1,215 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+const testPass = "correct horse"
+
+// seedProfile раскладывает в execDir по файлу каждой категории.
+func seedProfile(t *testing.T, execDir string) {
+	t.Helper()
+	bin := platform.GetBinDir(execDir)
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := locale.SaveSettings(bin, locale.Settings{Lang: "ru", DebugAPIToken: "TOKEN"}); err != nil {
+		t.Fatal(err)
+	}
+	s := state.New()
+	s.Connections.Sources = []state.Source{{ID: "a", Type: state.SourceTypeSubscription, Enabled: true, URL: "https://sub.example/sub?token=T"}}
+	s.WarpAccounts = &state.WarpAccountsSection{WG: &state.WarpWGAccount{PrivateKey: "OLDKEY", PeerPublic: "peer"}}
+	if err := os.MkdirAll(platform.GetWizardStatesDir(execDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(platform.GetWizardStatePath(execDir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(filepath.Join(platform.GetWizardStatesDir(execDir), "work.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(platform.GetRemoteMachineDir(execDir, "m1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(platform.GetWizardStatePathFor(execDir, constants.ConfigTargetRemote, "m1")); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"wizard_states/config.json":           "{}",
+		"wizard_states/remote/m1/config.json": "{}",
+		"subscriptions/a.raw":                 "vless://body",
+		"rule-sets/geoip-ru.srs":              "SRS",
+		"remote-daemons/m1/client_key.pem":    "KEY",
+	}
+	for rel, body := range files {
+		p := filepath.Join(bin, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := services.NewRemoteRegistry(execDir).RestoreEntries([]services.RemoteDaemon{
+		{ID: "m1", Name: "router", Addr: "10.0.0.1:9091", Secret: "SECRET"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Копия переносит профиль на чистую установку: настройки, состояния с
+// регистрациями WARP, кэши, rule-set'ы, реестр машин с ключами; собранные
+// config.json остаются позади.
+func TestCreateRestoreRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	seedProfile(t, src)
+	raw, m, err := Create(src, "v1.0.0", testPass, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Categories) != len(AllCategories) {
+		t.Fatalf("manifest = %+v", m)
+	}
+	for _, ci := range m.Categories {
+		if ci.Files == 0 {
+			t.Errorf("category %s is empty", ci.Category)
+		}
+	}
+	if _, err := Inspect(raw, "wrong horse"); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	if _, err := Inspect([]byte(`{"format":"singbox-launcher/remote-bundle"}`), testPass); !errors.Is(err, ErrNotBackup) {
+		t.Fatalf("foreign file: %v", err)
+	}
+
+	dst := t.TempDir()
+	report, err := Restore(dst, raw, testPass, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 0 || report.SavedTo != "" {
+		t.Fatalf("report = %+v", report)
+	}
+	bin := platform.GetBinDir(dst)
+	if st := locale.LoadSettings(bin); st.Lang != "ru" || st.DebugAPIToken != "TOKEN" {
+		t.Errorf("settings = %+v", st)
+	}
+	s, err := state.Load(platform.GetWizardStatePath(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Connections.Sources[0].URL != "https://sub.example/sub?token=T" || s.WarpAccounts == nil || s.WarpAccounts.WG.PrivateKey != "OLDKEY" {
+		t.Errorf("state = %+v %+v", s.Connections.Sources, s.WarpAccounts)
+	}
+	for _, rel := range []string{"wizard_states/work.json", "wizard_states/remote/m1/state.json", "subscriptions/a.raw", "rule-sets/geoip-ru.srs"} {
+		if _, err := os.Stat(filepath.Join(bin, filepath.FromSlash(rel))); err != nil {
+			t.Errorf("%s: %v", rel, err)
+		}
+	}
+	for _, rel := range []string{"wizard_states/config.json", "wizard_states/remote/m1/config.json"} {
+		if _, err := os.Stat(filepath.Join(bin, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+			t.Errorf("%s restored: %v", rel, err)
+		}
+	}
+	list, err := services.NewRemoteRegistry(dst).List()
+	if err != nil || len(list) != 1 || list[0].ID != "m1" || list[0].Secret != "SECRET" {
+		t.Fatalf("registry = %+v, %v", list, err)
+	}
+	info, err := os.Stat(filepath.Join(bin, "remote-daemons", "m1", "client_key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("client key mode = %v", info.Mode().Perm())
+	}
+}
+
+// Выборочное восстановление: только состояния — регистрации WARP и кэши
+// остаются текущими, а перезаписанный state.json сохранён рядом.
+func TestRestoreSelective(t *testing.T) {
+	dir := t.TempDir()
+	seedProfile(t, dir)
+	raw, _, err := Create(dir, "v1.0.0", testPass, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statePath := platform.GetWizardStatePath(dir)
+	s, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ParserConfig.ParserConfig.Proxies[0].Source = "https://sub.example/changed"
+	s.WarpAccounts.WG.PrivateKey = "NEWKEY"
+	if err := s.Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	rawPath := filepath.Join(platform.GetSubscriptionsDir(dir), "a.raw")
+	if err := os.WriteFile(rawPath, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Restore(dir, raw, testPass, []Category{CategoryStates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Restored(CategoryStates) || report.Restored(CategoryWarp) || report.SavedTo == "" {
+		t.Fatalf("report = %+v", report)
+	}
+	got, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Connections.Sources[0].URL != "https://sub.example/sub?token=T" || got.WarpAccounts.WG.PrivateKey != "NEWKEY" {
+		t.Errorf("state = %+v %+v", got.Connections.Sources, got.WarpAccounts.WG)
+	}
+	if body, _ := os.ReadFile(rawPath); string(body) != "changed" {
+		t.Errorf("subscription body restored: %q", body)
+	}
+	kept, err := state.Load(filepath.Join(report.SavedTo, "wizard_states", "state.json"))
+	if err != nil || kept.Connections.Sources[0].URL != "https://sub.example/changed" {
+		t.Errorf("saved copy = %v", err)
+	}
+
+	// Теперь только WARP: регистрация из копии — в текущий профиль.
+	if _, err := Restore(dir, raw, testPass, []Category{CategoryWarp}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := state.Load(statePath); got.WarpAccounts.WG.PrivateKey != "OLDKEY" {
+		t.Errorf("warp = %+v", got.WarpAccounts.WG)
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	cases := []struct {
+		cat  Category
+		rel  string
+		want bool
+	}{
+		{CategoryStates, "wizard_states/state.json", true},
+		{CategoryStates, "wizard_states/config.json", false},
+		{CategoryStates, "wizard_states/remote/m1/state.json", false},
+		{CategorySubscriptions, "subscriptions/a.raw", true},
+		{CategorySubscriptions, "subscriptions/../settings.json", false},
+		{CategoryRuleSets, "../../etc/passwd", false},
+		{CategoryRuleSets, "/etc/passwd", false},
+		{CategoryRemote, "remote-daemons/m1/client_key.pem", true},
+		{CategoryRemote, "sing-box", false},
+		{CategorySettings, "settings.json", true},
+	}
+	for _, c := range cases {
+		if got := checkPath(c.cat, c.rel) == nil; got != c.want {
+			t.Errorf("checkPath(%s, %q) = %v, want %v", c.cat, c.rel, got, c.want)
+		}
+	}
+}
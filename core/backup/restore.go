@@ -0,0 +1,340 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
+)
+
+// localStatePath — state.json локального профиля (путь в bin/).
+var localStatePath = constants.WizardStatesDirName + "/" + constants.WizardStateFileName
+
+// CategoryResult — сколько файлов восстановлено по категории.
+type CategoryResult struct {
+	Category Category `json:"category"`
+	Files    int      `json:"files"`
+}
+
+// Report — итог восстановления.
+type Report struct {
+	Categories []CategoryResult `json:"categories"`
+	// Errors — файлы, которые не восстановились (битое состояние, сбой
+	// записи); остальные восстановлены.
+	Errors []string `json:"errors,omitempty"`
+	// Warnings — восстановлено, но есть что поправить руками.
+	Warnings []string `json:"warnings,omitempty"`
+	// SavedTo — каталог с перезаписанными файлами; пусто — ничего не
+	// перезаписано.
+	SavedTo string `json:"saved_to,omitempty"`
+}
+
+// Restored — восстановлена ли категория хоть одним файлом.
+func (r Report) Restored(c Category) bool {
+	for _, cr := range r.Categories {
+		if cr.Category == c && cr.Files > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore раскладывает выбранные категории копии (пусто — все, что в ней
+// есть) в bin/.
+//
+// Восстановление сливает, а не заменяет: файлы, которых в копии нет,
+// остаются; машины реестра с другими ID — тоже. Каждый перезаписанный файл
+// сначала копируется в bin/restore-backups/<время>/.
+//
+// Ошибка возвращается, только если копия не открылась целиком (не тот
+// формат, не тот пароль, нет ключа секретов). Сбой на одном файле — строка
+// Report.Errors: остальные восстанавливаются.
+func Restore(execDir string, raw []byte, passphrase string, categories []Category) (Report, error) {
+	var report Report
+	if secretstore.Locked() {
+		return report, secretstore.ErrLocked
+	}
+	p, err := open(raw, passphrase)
+	if err != nil {
+		return report, err
+	}
+	want := map[Category]bool{}
+	if len(categories) == 0 {
+		categories = p.Categories
+	}
+	for _, c := range categories {
+		want[c] = true
+	}
+
+	r := restorer{
+		execDir: execDir,
+		binDir:  platform.GetBinDir(execDir),
+		saveDir: filepath.Join(platform.GetRestoreBackupsDir(execDir), time.Now().Format("20060102-150405")),
+		report:  &report,
+		counts:  map[Category]int{},
+	}
+
+	var warp *state.WarpAccountsSection
+	var registry []services.RemoteDaemon
+	var settings *locale.Settings
+	stateRestored := false
+	for _, f := range p.Files {
+		if !want[f.Category] {
+			continue
+		}
+		if err := checkPath(f.Category, f.Path); err != nil {
+			r.fail(f.Path, err)
+			continue
+		}
+		switch {
+		case f.Category == CategoryWarp:
+			warp = new(state.WarpAccountsSection)
+			if err := json.Unmarshal(f.Data, warp); err != nil {
+				warp = nil
+				r.fail(f.Path, err)
+			}
+		case f.Category == CategorySettings:
+			settings = new(locale.Settings)
+			if err := json.Unmarshal(f.Data, settings); err != nil {
+				settings = nil
+				r.fail(f.Path, err)
+			}
+		case f.Path == services.RemoteRegistryFileName:
+			if err := json.Unmarshal(f.Data, &registry); err != nil {
+				registry = nil
+				r.fail(f.Path, err)
+			}
+		case isStateFile(f.Path):
+			data, err := r.stateFile(f.Path, f.Data, want[CategoryWarp])
+			if err != nil {
+				r.fail(f.Path, err)
+				continue
+			}
+			if f.Path == localStatePath {
+				stateRestored = true
+			}
+			r.write(f.Category, f.Path, data, fs.FileMode(f.Mode))
+		case isRawCache(f.Path):
+			// В копии тело открытое; на диске — под ключом этой установки.
+			data, err := state.SealRawBody(f.Data)
+			if err != nil {
+				r.fail(f.Path, err)
+				continue
+			}
+			r.write(f.Category, f.Path, data, fs.FileMode(f.Mode))
+		default:
+			r.write(f.Category, f.Path, f.Data, fs.FileMode(f.Mode))
+		}
+	}
+
+	// Регистрации WARP — в локальный профиль: в тот, что пришёл из копии,
+	// или в текущий, если состояния не восстанавливались.
+	if warp != nil {
+		r.restoreWarp(warp, stateRestored)
+	}
+	if registry != nil {
+		r.restoreRegistry(registry)
+	}
+	if settings != nil {
+		r.backupExisting(settingsPath)
+		if err := locale.SaveSettings(r.binDir, *settings); err != nil {
+			r.fail(settingsPath, err)
+		} else {
+			r.counts[CategorySettings]++
+		}
+	}
+
+	for _, c := range AllCategories {
+		if want[c] {
+			report.Categories = append(report.Categories, CategoryResult{Category: c, Files: r.counts[c]})
+		}
+	}
+	if r.saved {
+		report.SavedTo = r.saveDir
+	}
+	debuglog.InfoLog("backup: restored %v from a copy of %s (%d errors)", report.Categories, p.CreatedAt, len(report.Errors))
+	return report, nil
+}
+
+// restorer — состояние одного восстановления.
+type restorer struct {
+	execDir string
+	binDir  string
+	saveDir string
+	saved   bool
+	report  *Report
+	counts  map[Category]int
+}
+
+func (r *restorer) fail(rel string, err error) {
+	r.report.Errors = append(r.report.Errors, fmt.Sprintf("%s: %v", rel, err))
+}
+
+// stateFile прогоняет состояние через state.Parse (миграция старых
+// раскладок) и кодирует текущей раскладкой, запечатав секреты ключом этой
+// установки. Регистрации WARP локального профиля, если их не просили
+// восстановить, остаются текущими.
+func (r *restorer) stateFile(rel string, data []byte, withWarp bool) ([]byte, error) {
+	s, err := state.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if rel == localStatePath && !withWarp {
+		cur, err := state.Load(r.target(rel))
+		switch {
+		case err == nil:
+			s.WarpAccounts = cur.WarpAccounts
+		case !errors.Is(err, state.ErrNotFound):
+			return nil, fmt.Errorf("keep current WARP registrations: %w", err)
+		}
+	}
+	return s.Encode()
+}
+
+func (r *restorer) restoreWarp(warp *state.WarpAccountsSection, stateRestored bool) {
+	target := r.target(localStatePath)
+	s, err := state.Load(target)
+	if errors.Is(err, state.ErrNotFound) {
+		r.report.Warnings = append(r.report.Warnings,
+			"WARP registrations were not restored: there is no local profile yet — restore the states too or open the wizard first")
+		return
+	}
+	if err != nil {
+		r.fail(warpPath, err)
+		return
+	}
+	s.WarpAccounts = warp
+	data, err := s.Encode()
+	if err != nil {
+		r.fail(warpPath, err)
+		return
+	}
+	// Только что восстановленный state.json уже сохранён в копии прежних
+	// файлов — второй раз его туда класть незачем.
+	if !stateRestored {
+		r.backupExisting(localStatePath)
+	}
+	if err := writeAtomic(target, data, platform.DefaultFileMode); err != nil {
+		r.fail(warpPath, err)
+		return
+	}
+	r.counts[CategoryWarp]++
+}
+
+func (r *restorer) restoreRegistry(entries []services.RemoteDaemon) {
+	r.backupExisting(services.RemoteRegistryFileName)
+	if err := services.NewRemoteRegistry(r.execDir).RestoreEntries(entries); err != nil {
+		r.fail(services.RemoteRegistryFileName, err)
+		return
+	}
+	r.counts[CategoryRemote]++
+	for _, e := range entries {
+		// Путь к SSH-ключу — путь на компьютере, где делали копию.
+		if rt := e.ConnectRoute; rt != nil && rt.Kind == services.RouteSSH && rt.SSHKeyFile != "" {
+			if _, err := os.Stat(rt.SSHKeyFile); err != nil {
+				r.report.Warnings = append(r.report.Warnings,
+					fmt.Sprintf("%s: ssh key %s not found on this computer — fix the route", e.Name, rt.SSHKeyFile))
+			}
+		}
+	}
+}
+
+// write кладёт файл, сохранив прежний.
+func (r *restorer) write(cat Category, rel string, data []byte, mode fs.FileMode) {
+	if mode == 0 {
+		mode = platform.DefaultFileMode
+	}
+	r.backupExisting(rel)
+	if err := writeAtomic(r.target(rel), data, mode); err != nil {
+		r.fail(rel, err)
+		return
+	}
+	r.counts[cat]++
+}
+
+// backupExisting копирует файл, который сейчас будет перезаписан, в
+// каталог этого восстановления.
+func (r *restorer) backupExisting(rel string) {
+	src := r.target(rel)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			debuglog.WarnLog("backup: keep %s before restore: %v", rel, err)
+		}
+		return
+	}
+	mode := platform.DefaultFileMode
+	if info, err := os.Stat(src); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := writeAtomic(filepath.Join(r.saveDir, filepath.FromSlash(rel)), data, mode); err != nil {
+		debuglog.WarnLog("backup: keep %s before restore: %v", rel, err)
+		return
+	}
+	r.saved = true
+}
+
+func (r *restorer) target(rel string) string {
+	return filepath.Join(r.binDir, filepath.FromSlash(rel))
+}
+
+// checkPath — путь из копии лежит там, где его категории место. Файл
+// приходит извне: «../» или чужой каталог не должны дать записать что
+// угодно куда угодно.
+func checkPath(cat Category, rel string) error {
+	if rel == "" || path.IsAbs(rel) || strings.Contains(rel, `\`) || path.Clean(rel) != rel || strings.HasPrefix(rel, "../") || rel == ".." {
+		return fmt.Errorf("unsafe path")
+	}
+	under := func(dir string) bool { return strings.HasPrefix(rel, dir+"/") }
+	ok := false
+	switch cat {
+	case CategorySettings:
+		ok = rel == settingsPath
+	case CategoryWarp:
+		ok = rel == warpPath
+	case CategoryStates:
+		ok = path.Dir(rel) == constants.WizardStatesDirName && isStateFile(rel)
+	case CategorySubscriptions:
+		ok = under(constants.SubscriptionsDirName)
+	case CategoryRuleSets:
+		ok = under(constants.RuleSetsDirName)
+	case CategoryRemote:
+		ok = rel == services.RemoteRegistryFileName ||
+			under(services.RemoteIdentitiesDirName) ||
+			under(path.Join(constants.WizardStatesDirName, constants.BaseProfilesDirName)) ||
+			under(path.Join(constants.WizardStatesDirName, constants.ConfigTargetRemote))
+	}
+	if !ok {
+		return fmt.Errorf("not a %s file", cat)
+	}
+	return nil
+}
+
+// writeAtomic — tmp + rename: обрыв записи не оставит полфайла.
+func writeAtomic(target string, data []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), platform.DefaultDirMode); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -60,6 +60,10 @@ type RouteConfig struct {
 	// OmitDefaultDomainResolver — true → ключ default_domain_resolver удаляется
 	// из секции (даже если был в шаблоне).
 	OmitDefaultDomainResolver bool
+	// DefaultMark — route.default_mark для исходящих соединений ядра: по
+	// нему kill switch (core/killswitch) отличает трафик ядра от утечки.
+	// 0 — не трогать; метку, уже заданную шаблоном, не перезаписываем.
+	DefaultMark uint32
 }
 
 // MergeRouteSection накладывает custom-rules + SRS rule_sets поверх
@@ -146,6 +150,10 @@ func MergeRouteSection(raw json.RawMessage, cfg RouteConfig) (json.RawMessage, e
 		route["final"] = cfg.FinalOutbound
 	}
 
+	if _, set := route["default_mark"]; !set && cfg.DefaultMark != 0 {
+		route["default_mark"] = cfg.DefaultMark
+	}
+
 	if cfg.OmitDefaultDomainResolver {
 		delete(route, "default_domain_resolver")
 	} else if s := strings.TrimSpace(cfg.DefaultDomainResolver); s != "" {
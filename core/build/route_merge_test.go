@@ -555,3 +555,26 @@ func TestMergeRouteSection_FormattedOutputValid(t *testing.T) {
 		t.Errorf("output not valid JSON: %s", got)
 	}
 }
+
+// TestMergeRouteSection_DefaultMark — метка kill switch ставится, только
+// если шаблон не задал свою.
+func TestMergeRouteSection_DefaultMark(t *testing.T) {
+	out, err := MergeRouteSection(json.RawMessage(`{"final":"proxy-out"}`), RouteConfig{DefaultMark: 0x2f5a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unmarshalRoute(t, out)["default_mark"]; got != float64(0x2f5a) {
+		t.Errorf("default_mark = %v", got)
+	}
+	out, err = MergeRouteSection(json.RawMessage(`{"default_mark":7}`), RouteConfig{DefaultMark: 0x2f5a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unmarshalRoute(t, out)["default_mark"]; got != float64(7) {
+		t.Errorf("template mark overwritten: %v", got)
+	}
+	out, _ = MergeRouteSection(json.RawMessage(`{}`), RouteConfig{})
+	if _, set := unmarshalRoute(t, out)["default_mark"]; set {
+		t.Errorf("mark emitted without kill switch: %s", out)
+	}
+}
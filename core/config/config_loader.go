@@ -3,7 +3,9 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/muhammadmuzzammil1998/jsonc"
@@ -120,6 +122,116 @@ func ConfigHasTun(configPath string) (bool, error) {
 	return false, nil
 }
 
+// ProxyInbound is the local core's SOCKS-capable inbound as a client sees it.
+type ProxyInbound struct {
+	// Addr is host:port to dial; a wildcard listen is turned into loopback.
+	Addr     string
+	Username string
+	Password string
+}
+
+// LocalProxyInbound finds the inbound other local programs can tunnel through:
+// the template's "proxy-in", otherwise the first mixed/socks inbound. Used by
+// remote machines whose control channel goes through the local core.
+func LocalProxyInbound(configPath string) (ProxyInbound, error) {
+	cleanData, err := getConfigJSON(configPath)
+	if err != nil {
+		return ProxyInbound{}, err
+	}
+	var config struct {
+		Inbounds []struct {
+			Type       string `json:"type"`
+			Tag        string `json:"tag"`
+			Listen     string `json:"listen"`
+			ListenPort int    `json:"listen_port"`
+			Users      []struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+			} `json:"users"`
+		} `json:"inbounds"`
+	}
+	if err := json.Unmarshal(cleanData, &config); err != nil {
+		return ProxyInbound{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+	found := -1
+	for i, in := range config.Inbounds {
+		if in.Type != "mixed" && in.Type != "socks" {
+			continue
+		}
+		if in.Tag == "proxy-in" {
+			found = i
+			break
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return ProxyInbound{}, fmt.Errorf("config has no mixed or socks inbound (enable proxy-in in the wizard)")
+	}
+	in := config.Inbounds[found]
+	if in.ListenPort == 0 {
+		return ProxyInbound{}, fmt.Errorf("inbound %q has no listen_port", in.Tag)
+	}
+	host := strings.TrimSpace(in.Listen)
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	out := ProxyInbound{Addr: net.JoinHostPort(host, strconv.Itoa(in.ListenPort))}
+	if len(in.Users) > 0 {
+		out.Username, out.Password = in.Users[0].Username, in.Users[0].Password
+	}
+	return out, nil
+}
+
+// CoreEgress is what the kill switch needs to know about the core's own
+// traffic: the routing mark on its dials and the TUN interfaces it owns.
+type CoreEgress struct {
+	// DefaultMark is route.default_mark; 0 means the config sets none.
+	DefaultMark uint32
+	// TunInterfaces lists interface_name of tun inbounds; a tun inbound
+	// without a name is reported as "" (the core picks tunN itself).
+	TunInterfaces []string
+}
+
+// GetCoreEgress reads route.default_mark and the tun inbounds from config.json.
+// The mark may be a number or a string ("0x2f5a").
+func GetCoreEgress(configPath string) (CoreEgress, error) {
+	cleanData, err := getConfigJSON(configPath)
+	if err != nil {
+		return CoreEgress{}, err
+	}
+	var config struct {
+		Inbounds []struct {
+			Type          string `json:"type"`
+			InterfaceName string `json:"interface_name"`
+		} `json:"inbounds"`
+		Route struct {
+			DefaultMark json.RawMessage `json:"default_mark"`
+		} `json:"route"`
+	}
+	if err := json.Unmarshal(cleanData, &config); err != nil {
+		return CoreEgress{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+	var out CoreEgress
+	if raw := strings.Trim(strings.TrimSpace(string(config.Route.DefaultMark)), `"`); raw != "" && raw != "null" {
+		mark, err := strconv.ParseUint(raw, 0, 32)
+		if err != nil {
+			return CoreEgress{}, fmt.Errorf("route.default_mark %q: %w", raw, err)
+		}
+		out.DefaultMark = uint32(mark)
+	}
+	for _, in := range config.Inbounds {
+		if in.Type == "tun" {
+			out.TunInterfaces = append(out.TunInterfaces, strings.TrimSpace(in.InterfaceName))
+		}
+	}
+	return out, nil
+}
+
 // ExperimentalCacheFileFromSection parses experimental.cache_file from the JSON value of the top-level
 // "experimental" key (not the full config). Returns whether removal should be attempted and the path string
 // from JSON (may be relative to the sing-box working directory, typically bin/).
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGetCoreEgress(t *testing.T) {
+	write := func(body string) string {
+		p := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	e, err := GetCoreEgress(write(`{
+		"inbounds":[{"type":"tun","interface_name":"singbox-tun0"},{"type":"mixed"},{"type":"tun"}],
+		"route":{"default_mark":12122}
+	}`))
+	if err != nil || e.DefaultMark != 12122 || !slices.Equal(e.TunInterfaces, []string{"singbox-tun0", ""}) {
+		t.Fatalf("got %+v, %v", e, err)
+	}
+
+	e, err = GetCoreEgress(write(`{"route":{"default_mark":"0x2f5a"}}`))
+	if err != nil || e.DefaultMark != 0x2f5a || len(e.TunInterfaces) != 0 {
+		t.Fatalf("string mark: got %+v, %v", e, err)
+	}
+
+	e, err = GetCoreEgress(write(`{"route":{}}`))
+	if err != nil || e.DefaultMark != 0 {
+		t.Fatalf("no mark: got %+v, %v", e, err)
+	}
+
+	if _, err := GetCoreEgress(write(`{"route":{"default_mark":"nope"}}`)); err == nil {
+		t.Error("bad mark must fail")
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalProxyInbound(t *testing.T) {
+	write := func(body string) string {
+		p := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// proxy-in важнее первого попавшегося socks; 0.0.0.0 → loopback.
+	in, err := LocalProxyInbound(write(`{"inbounds":[
+		{"type":"socks","tag":"lan","listen":"192.168.1.2","listen_port":1080},
+		// шаблонный
+		{"type":"mixed","tag":"proxy-in","listen":"0.0.0.0","listen_port":7890,
+		 "users":[{"username":"u","password":"p"}]}
+	]}`))
+	if err != nil || in != (ProxyInbound{Addr: "127.0.0.1:7890", Username: "u", Password: "p"}) {
+		t.Fatalf("got %+v, %v", in, err)
+	}
+
+	in, err = LocalProxyInbound(write(`{"inbounds":[{"type":"tun"},{"type":"socks","listen_port":1080}]}`))
+	if err != nil || in.Addr != "127.0.0.1:1080" || in.Username != "" {
+		t.Fatalf("fallback: got %+v, %v", in, err)
+	}
+
+	if _, err := LocalProxyInbound(write(`{"inbounds":[{"type":"tun"}]}`)); err == nil {
+		t.Error("config without a proxy inbound must fail")
+	}
+}
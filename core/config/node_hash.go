@@ -97,18 +97,48 @@ func NodeIdentityHash(node *ParsedNode) string {
 		return ""
 	}
 
-	for field := range nodeHashIgnoredFields {
-		delete(obj, field)
+	hash, err := outboundObjectHash(obj)
+	if err != nil {
+		debuglog.DebugLog("NodeIdentityHash: cannot canonicalize node %q: %v", node.Tag, err)
+		return ""
 	}
+	return hash
+}
 
-	canonical, err := marshalCanonicalJSON(obj)
+// OutboundIdentityHash — та же идентичность, но для объекта, уже лежащего в
+// config.json (outbounds[] или endpoints[]). Генератор пишет узел в конфиг
+// ровно тем JSON, по которому считается NodeIdentityHash, поэтому для узла
+// подписки хеши совпадают, а ручные outbound'ы и группы получают свой —
+// стабильный, пока не меняется их содержимое.
+//
+// Объект не изменяется. "" — объект не сериализуется.
+func OutboundIdentityHash(obj map[string]interface{}) string {
+	if obj == nil {
+		return ""
+	}
+	clone := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		clone[k] = v
+	}
+	hash, err := outboundObjectHash(clone)
 	if err != nil {
-		debuglog.DebugLog("NodeIdentityHash: cannot canonicalize node %q: %v", node.Tag, err)
 		return ""
 	}
+	return hash
+}
 
+// outboundObjectHash удаляет из obj поля вне идентичности и хеширует
+// канонический JSON остатка. obj изменяется.
+func outboundObjectHash(obj map[string]interface{}) (string, error) {
+	for field := range nodeHashIgnoredFields {
+		delete(obj, field)
+	}
+	canonical, err := marshalCanonicalJSON(obj)
+	if err != nil {
+		return "", err
+	}
 	sum := sha256.Sum256(canonical)
-	return hex.EncodeToString(sum[:])
+	return hex.EncodeToString(sum[:]), nil
 }
 
 // decodeEmittedOutbound strips the generator's wrapping and decodes the object.
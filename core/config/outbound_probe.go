@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Временный «зонд» для диагностики узла в classic-режиме.
+//
+// Classic-ядро не умеет гонять тест через произвольный outbound: маршрут
+// определяется правилами конфига, и выбрать узел можно только переключив
+// селектор — то есть уведя через него весь трафик пользователя. Поэтому рядом
+// с основным ядром поднимается второй, одноразовый sing-box: единственный
+// mixed-inbound на loopback и только тот outbound, который тестируется (с его
+// detour-цепочкой и составом группы). Основное ядро при этом не трогается и
+// может быть вообще не запущено.
+
+// ProbeInboundTag — тег inbound'а временного зонда.
+const ProbeInboundTag = "netdiag-in"
+
+// probeDNSTag — локальный резолвер зонда. DNS основного конфига не
+// переносится: его правила ссылаются на rule-set'ы и outbound'ы, которых в
+// зонде нет, а для резолва адресов серверов достаточно системного.
+const probeDNSTag = "netdiag-local"
+
+// OutboundIdentityHashByTag — OutboundIdentityHash для outbound'а (или
+// endpoint'а WireGuard) с тегом tag из config.json. "" — тега нет.
+func OutboundIdentityHashByTag(configPath, tag string) string {
+	root, err := loadConfigRootMap(configPath)
+	if err != nil {
+		return ""
+	}
+	obj, _, ok := findOutboundOrEndpoint(root, tag)
+	if !ok {
+		return ""
+	}
+	return OutboundIdentityHash(obj)
+}
+
+// BuildProbeConfig собирает конфиг временного зонда для тега tag из
+// config.json: mixed-inbound на 127.0.0.1:listenPort и всё, без чего outbound
+// не поднимется, — узлы detour-цепочки и участники группы (рекурсивно).
+// route.final указывает на tag, так что любой запрос через inbound уходит
+// ровно через тестируемый узел.
+func BuildProbeConfig(configPath, tag string, listenPort int) ([]byte, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return nil, fmt.Errorf("empty outbound tag")
+	}
+	root, err := loadConfigRootMap(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := findOutboundOrEndpoint(root, tag); !ok {
+		return nil, fmt.Errorf("outbound with tag %q not found", tag)
+	}
+
+	var outbounds, endpoints []interface{}
+	seen := map[string]bool{}
+	var visit func(t string) error
+	visit = func(t string) error {
+		if t == "" || seen[t] {
+			return nil
+		}
+		seen[t] = true
+		obj, section, ok := findOutboundOrEndpoint(root, t)
+		if !ok {
+			return fmt.Errorf("outbound %q references missing %q", tag, t)
+		}
+		obj = probeSanitize(obj)
+		if section == "endpoints" {
+			endpoints = append(endpoints, obj)
+		} else {
+			outbounds = append(outbounds, obj)
+		}
+		if detour, _ := obj["detour"].(string); detour != "" {
+			if err := visit(detour); err != nil {
+				return err
+			}
+		}
+		if members, ok := obj["outbounds"].([]interface{}); ok {
+			for _, m := range members {
+				if s, ok := m.(string); ok {
+					if err := visit(s); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(tag); err != nil {
+		return nil, err
+	}
+
+	cfg := map[string]interface{}{
+		"log": map[string]interface{}{"level": "warn", "timestamp": true},
+		"dns": map[string]interface{}{
+			"servers": []interface{}{map[string]interface{}{"type": "local", "tag": probeDNSTag}},
+		},
+		"inbounds": []interface{}{map[string]interface{}{
+			"type":        "mixed",
+			"tag":         ProbeInboundTag,
+			"listen":      "127.0.0.1",
+			"listen_port": listenPort,
+		}},
+		"outbounds": outbounds,
+		"route": map[string]interface{}{
+			"final":                   tag,
+			"default_domain_resolver": probeDNSTag,
+			// Основное ядро с TUN (auto_route) иначе завернуло бы соединения
+			// зонда к серверу в себя, и тест мерил бы двойной хоп через
+			// текущий узел пользователя. Привязка к физическому интерфейсу —
+			// тот же приём, которым sing-box спасается от петли сам.
+			"auto_detect_interface": true,
+		},
+	}
+	if len(endpoints) > 0 {
+		cfg["endpoints"] = endpoints
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// findOutboundOrEndpoint ищет тег сначала в outbounds[], затем в endpoints[]
+// (WireGuard-узлы с sing-box 1.11 живут там). Второе значение — секция.
+func findOutboundOrEndpoint(root map[string]interface{}, tag string) (map[string]interface{}, string, bool) {
+	for _, section := range []string{"outbounds", "endpoints"} {
+		if obj, err := findTaggedInRoot(root, tag, section, "%q"); err == nil {
+			return obj, section, true
+		}
+	}
+	return nil, "", false
+}
+
+// probeSanitize — копия outbound'а без ссылок на то, чего в зонде нет:
+// domain_resolver указывает на DNS-сервер основного конфига. Резолв адреса
+// сервера берёт на себя route.default_domain_resolver зонда.
+func probeSanitize(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		out[k] = v
+	}
+	delete(out, "domain_resolver")
+	return out
+}
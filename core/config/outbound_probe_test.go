@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProbeTestConfig(t *testing.T) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.json")
+	body := []byte(`{
+		// jsonc-комментарий, как в настоящем config.json
+		"outbounds":[
+			{"type":"vless","tag":"jump","uuid":"550e8400-e29b-41d4-a716-446655440000","server":"j.example.com","server_port":443,"domain_resolver":"dns-remote"},
+			{"type":"vless","tag":"n1","uuid":"650e8400-e29b-41d4-a716-446655440001","server":"a.example.com","server_port":443,"detour":"jump"},
+			{"type":"selector","tag":"proxy-out","outbounds":["n1","wg"]},
+			{"type":"direct","tag":"direct"},
+			{"type":"vless","tag":"unrelated","uuid":"750e8400-e29b-41d4-a716-446655440002","server":"u.example.com","server_port":443}
+		],
+		"endpoints":[{"type":"wireguard","tag":"wg","address":["10.0.0.2/32"],"private_key":"k"}],
+		"route":{"final":"proxy-out","rules":[{"rule_set":"geosite-ru","outbound":"direct"}]}
+	}`)
+	if err := os.WriteFile(p, body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBuildProbeConfig_ClosureAndRoute(t *testing.T) {
+	p := writeProbeTestConfig(t)
+	raw, err := BuildProbeConfig(p, "proxy-out", 40123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Inbounds []struct {
+			Type       string `json:"type"`
+			Listen     string `json:"listen"`
+			ListenPort int    `json:"listen_port"`
+		} `json:"inbounds"`
+		Outbounds []map[string]interface{} `json:"outbounds"`
+		Endpoints []map[string]interface{} `json:"endpoints"`
+		Route     map[string]interface{}   `json:"route"`
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Inbounds) != 1 || cfg.Inbounds[0].Type != "mixed" || cfg.Inbounds[0].Listen != "127.0.0.1" || cfg.Inbounds[0].ListenPort != 40123 {
+		t.Fatalf("inbound = %+v", cfg.Inbounds)
+	}
+	tags := map[string]map[string]interface{}{}
+	for _, o := range cfg.Outbounds {
+		tags[o["tag"].(string)] = o
+	}
+	for _, want := range []string{"proxy-out", "n1", "jump"} {
+		if tags[want] == nil {
+			t.Fatalf("outbound %q missing from probe: %v", want, tags)
+		}
+	}
+	if tags["unrelated"] != nil || tags["direct"] != nil {
+		t.Fatalf("probe carries outbounds it does not need: %v", tags)
+	}
+	if _, ok := tags["jump"]["domain_resolver"]; ok {
+		t.Fatal("domain_resolver must be stripped: the probe has no such DNS server")
+	}
+	if len(cfg.Endpoints) != 1 || cfg.Endpoints[0]["tag"] != "wg" {
+		t.Fatalf("endpoints = %v", cfg.Endpoints)
+	}
+	if cfg.Route["final"] != "proxy-out" {
+		t.Fatalf("route.final = %v", cfg.Route["final"])
+	}
+	if cfg.Route["auto_detect_interface"] != true {
+		t.Fatal("probe must bypass the main core's TUN")
+	}
+	if _, ok := cfg.Route["rules"]; ok {
+		t.Fatal("main config rules must not leak into the probe")
+	}
+}
+
+func TestBuildProbeConfig_MissingTag(t *testing.T) {
+	p := writeProbeTestConfig(t)
+	if _, err := BuildProbeConfig(p, "nope", 1); err == nil {
+		t.Fatal("expected error for unknown tag")
+	}
+}
+
+func TestOutboundIdentityHashByTag(t *testing.T) {
+	p := writeProbeTestConfig(t)
+	h := OutboundIdentityHashByTag(p, "n1")
+	if h == "" {
+		t.Fatal("empty hash for existing outbound")
+	}
+	// detour и tag не входят в идентичность — тот же узел под другим именем
+	// и с другим джампом обязан совпасть.
+	same := OutboundIdentityHash(map[string]interface{}{
+		"type": "vless", "tag": "renamed", "uuid": "650e8400-e29b-41d4-a716-446655440001",
+		"server": "a.example.com", "server_port": float64(443),
+	})
+	if h != same {
+		t.Fatalf("hash changed with tag/detour: %s vs %s", h, same)
+	}
+	if OutboundIdentityHashByTag(p, "wg") == "" {
+		t.Fatal("endpoints must be hashable too")
+	}
+	if OutboundIdentityHashByTag(p, "nope") != "" {
+		t.Fatal("unknown tag must hash to empty")
+	}
+}
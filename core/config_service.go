@@ -11,9 +11,11 @@ import (
 
 	"github.com/muhammadmuzzammil1998/jsonc"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/build"
 	"singbox-launcher/core/config"
 	"singbox-launcher/core/config/subscription"
+	"singbox-launcher/core/events"
 	"singbox-launcher/core/services"
 	"singbox-launcher/core/state"
 	"singbox-launcher/core/template"
@@ -93,8 +95,9 @@ func (svc *ConfigService) RunParserProcess() {
 		ac.ParserMutex.Unlock()
 	}()
 
-	// Call internal parser to update configuration
-	_, err := svc.UpdateConfigFromSubscriptions()
+	// Call internal parser to update configuration. RunParserProcess —
+	// кнопка Update и Ctrl+U окна, отсюда и актор журнала.
+	_, err := ac.UpdateSubscriptionsBy(audit.ActorUI)
 
 	// SPEC 045 фаза 9: финальный success-toast эмитит сам
 	// UpdateConfigFromSubscriptions (после rebuild). Здесь обрабатываем
@@ -189,8 +192,30 @@ func (svc *ConfigService) GenerateOutboundsFromParserConfig(
 // Per-source merge (preserve nodes from failed sources, refresh succeeded
 // ones) — отдельный TODO; пока — all-or-nothing.
 //
-// Возвращает per-source result (counts) для toast-сообщения.
+// Возвращает per-source result (counts) для toast-сообщения. Итог прогона
+// публикуется в EventBus как events.SubscriptionsRefreshed.
 func (svc *ConfigService) UpdateConfigFromSubscriptions() (*config.OutboundGenerationResult, error) {
+	result, err := svc.updateConfigFromSubscriptions()
+	svc.publishSubscriptionsRefreshed(result, err)
+	return result, err
+}
+
+// publishSubscriptionsRefreshed — nil-safe относительно EventBus.
+func (svc *ConfigService) publishSubscriptionsRefreshed(result *config.OutboundGenerationResult, err error) {
+	if svc.ac.EventBus == nil {
+		return
+	}
+	p := events.SubscriptionsRefreshedPayload{OK: err == nil, Error: err}
+	if result != nil {
+		p.TotalSources = result.TotalSources
+		p.SucceededSources = result.SucceededSources
+		p.FailedSources = result.FailedSources
+		p.Nodes = result.NodesCount + result.EndpointsCount
+	}
+	svc.ac.EventBus.Publish(events.Event{Kind: events.SubscriptionsRefreshed, Payload: p})
+}
+
+func (svc *ConfigService) updateConfigFromSubscriptions() (*config.OutboundGenerationResult, error) {
 	ac := svc.ac
 	execDir := ac.FileService.ExecDir
 
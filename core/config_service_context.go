@@ -13,6 +13,7 @@ import (
 
 	"singbox-launcher/core/build"
 	"singbox-launcher/core/config"
+	"singbox-launcher/core/killswitch"
 	"singbox-launcher/core/state"
 	"singbox-launcher/core/template"
 	"singbox-launcher/internal/debuglog"
@@ -55,6 +56,9 @@ func (ac *AppController) buildContextFromState(s *state.State, cache *build.Pars
 	// DNS scalars из state (могут жить в DNSOptions или vars; см. dnsConfigFromUpdate).
 	ctx.DNS = dnsConfigForUpdate(s)
 	ctx.Route = routeConfigForUpdate(s)
+	if s.KillSwitch != nil && s.KillSwitch.Enabled {
+		ctx.Route.DefaultMark = killswitch.DefaultMark
+	}
 	// SPEC 045 фаза 9: execDir нужен MergeRouteSection-у для резолва путей
 	// SRS файлов (bin/rule-sets/<tag>.srs). Без этого convertRuleSetToLocalRequired
 	// не может проверить наличие файла и валит build с «empty execDir».
@@ -12,6 +12,7 @@ package core
 //   - RefreshSourceInPlace намеренно НЕ берёт SubscriptionMu (не пишет state.json).
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -46,10 +47,29 @@ import (
 //     которых больше нет в state;
 //   - Persist state.json через `state.Save` (atomic).
 func refreshSubscriptionsMetaAndCache(s *state.State, execDir string) {
+	ac := GetController()
+	progress := func(p float64, msg string) {
+		if ac != nil && ac.UIService != nil && ac.UIService.UpdateParserProgressFunc != nil {
+			ac.UIService.UpdateParserProgressFunc(p, msg)
+		}
+	}
+	refreshSubscriptionsMetaAndCacheFor(s, execDir, constants.ConfigTargetLocal, "", progress)
+}
+
+// refreshSubscriptionsMetaAndCacheFor — тело refreshSubscriptionsMetaAndCache
+// для произвольной машины: каталог тел, GC и state.json берутся её
+// (SPEC 098 layout). progress может быть nil — у удалённой машины нет
+// прогресс-бара: локальный принадлежит Update'у этой машины.
+//
+// Возвращает, сколько включённых подписок было и сколько из них скачались.
+func refreshSubscriptionsMetaAndCacheFor(s *state.State, execDir, target, machineID string, progress func(float64, string)) (total, fetched int) {
 	if s == nil {
-		return
+		return 0, 0
 	}
-	subsDir := platform.GetSubscriptionsDir(execDir)
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	subsDir := platform.GetSubscriptionsDirFor(execDir, target, machineID)
 
 	dirty := false
 
@@ -61,12 +81,7 @@ func refreshSubscriptionsMetaAndCache(s *state.State, execDir string) {
 		}
 	}
 
-	ac := GetController()
-	progress := func(p float64, msg string) {
-		if ac != nil && ac.UIService != nil && ac.UIService.UpdateParserProgressFunc != nil {
-			ac.UIService.UpdateParserProgressFunc(p, msg)
-		}
-	}
+	total = enabledCount
 
 	idx := 0
 	for i := range s.Connections.Sources {
@@ -86,6 +101,9 @@ func refreshSubscriptionsMetaAndCache(s *state.State, execDir string) {
 		if refreshOneSubscriptionSource(src, s.Connections.Defaults, subsDir) {
 			dirty = true
 		}
+		if src.Meta != nil && src.Meta.LastStatus == "ok" {
+			fetched++
+		}
 
 		// SPEC 094 D4: чистка отметок о выключенных нодах. Выполняется ТОЛЬКО
 		// здесь — на пути успешного сетевого обновления: на прогоне из кэша
@@ -121,18 +139,62 @@ func refreshSubscriptionsMetaAndCache(s *state.State, execDir string) {
 	//
 	// SPEC 098: и множество, и каталог — локальные. У удалённых машин свои
 	// каталоги тел подписок внутри их директорий.
-	knownIDs := collectAllStageSourceIDs(execDir, constants.ConfigTargetLocal, "")
+	knownIDs := collectAllStageSourceIDs(execDir, target, machineID)
 	if _, gcErr := state.DeleteOrphans(subsDir, knownIDs); gcErr != nil {
 		debuglog.WarnLog("refreshSubscriptionsMetaAndCache: DeleteOrphans: %v", gcErr)
 	}
 
 	// Persist state с обновлённой meta. Best-effort.
 	if dirty {
-		statePath := platform.GetWizardStatePath(execDir)
+		statePath := platform.GetWizardStatePathFor(execDir, target, machineID)
 		if err := s.Save(statePath); err != nil {
 			debuglog.WarnLog("refreshSubscriptionsMetaAndCache: state.Save: %v", err)
 		}
 	}
+	return total, fetched
+}
+
+// RefreshRemoteSubscriptions обновляет подписки удалённой машины: тела в её
+// subscriptions/ и meta в её state.json — шаг refresh-subscriptions прогона
+// по парку.
+//
+// Конфиг машины НЕ пересобирается: сборка remote-конфига пока живёт только в
+// визарде (Configure → Save), и до неё Deploy отправит прежний config.json.
+// Ошибка — только если профиля нет или не скачалась ни одна подписка: у
+// локального Update та же устойчивость per-source, упавший источник остаётся
+// на прежнем теле.
+func (ac *AppController) RefreshRemoteSubscriptions(machineID string) (string, error) {
+	execDir := ac.FileService.ExecDir
+	statePath := platform.GetWizardStatePathFor(execDir, constants.ConfigTargetRemote, machineID)
+	s, err := state.Load(statePath)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return "", fmt.Errorf("machine %q has no saved profile yet — run Configure first", machineID)
+		}
+		return "", err
+	}
+	total, fetched := refreshSubscriptionsMetaAndCacheFor(s, execDir, constants.ConfigTargetRemote, machineID, nil)
+	if total > 0 && fetched == 0 {
+		return "", fmt.Errorf("none of %d subscription(s) could be fetched", total)
+	}
+	msg := fmt.Sprintf("%d of %d subscription(s) fetched", fetched, total)
+	// Политика машины может требовать сверки и повторного деплоя сразу после
+	// обновления (DeployPolicy.RedeployOnRefresh). Синхронно: прогон по
+	// парку ждёт шаг целиком, и следующий шаг не должен застать деплой в
+	// процессе.
+	if ac.RemoteDrift != nil {
+		rep, enabled, driftErr := ac.RemoteDrift.AfterRefresh(machineID)
+		switch {
+		case !enabled:
+		case driftErr != nil:
+			msg += "; drift check: " + driftErr.Error()
+		case rep.RedeployErr != "":
+			msg += "; redeploy failed: " + rep.RedeployErr
+		case rep.Redeployed:
+			msg += "; redeployed"
+		}
+	}
+	return msg, nil
 }
 
 // collectAllStageSourceIDs возвращает объединение Source.ID'ов из state-файлов
@@ -13,12 +13,12 @@ import (
 
 	"singbox-launcher/internal/debuglog"
 
-	"fyne.io/fyne/v2"
-
 	"singbox-launcher/api"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/config"
 	"singbox-launcher/core/config/subscription"
 	"singbox-launcher/core/events"
+	"singbox-launcher/core/netdiag"
 	"singbox-launcher/core/services"
 	"singbox-launcher/core/uiservice"
 	"singbox-launcher/internal/constants"
@@ -109,6 +109,26 @@ type AppController struct {
 	naiveSupportCache   *naiveSupportVerdict
 	naiveSupportCacheMu sync.Mutex
 
+	// --- Side-by-side core versions (bin/cores/, core_versions.go) ---
+	// coreStoreMu сериализует установку, переключение, удаление и откат:
+	// все они переписывают bin/sing-box и bin/cores/cores.json.
+	// coreProbeCache — `sing-box version` каждой версии по (mtime, size).
+	coreStoreMu    sync.Mutex
+	coreProbeCache map[string]*coreProbe
+
+	// --- Launcher self-update (launcher_update.go) ---
+	// launcherUpdateMu — одна подготовка обновления за раз (TryLock: вторая
+	// получает ErrLauncherUpdateBusy, а не ждёт).
+	launcherUpdateMu sync.Mutex
+
+	// --- Core supervision (supervision.go) ---
+	// Политика перезапусков профиля, проверка живости, флаги перезапуска.
+	supervision supervisionState
+
+	// --- Kill switch (killswitch.go) ---
+	// Правила файрвола, блокирующие трафик мимо ядра, и желаемое состояние.
+	killSwitch killSwitchState
+
 	// --- Auto-update per-source retry timers (SPEC 052 phase 8 event model) ---
 	// Map source.ID → pending retry timer. Один retry на 15 секунд после
 	// failed fetch; следующая попытка — на следующем heartbeat'е (1ч) или
@@ -132,6 +152,27 @@ type AppController struct {
 	// поэтому ожидание lock'а не блокирует main thread; пользователь
 	// видит spinner на per-source Refresh кнопке до освобождения.
 	SubscriptionMu sync.Mutex
+
+	// CoreStats — скорость и память ядра для графика на вкладке Local и
+	// подсказки в трее. Наполняется runCoreStatsLoop из активного бэкенда.
+	CoreStats *CoreStatsMonitor
+
+	// Watchdog — сторож связности selector-групп (state.watchdog):
+	// переключает группу с мёртвого узла на живой. См. core/watchdog.go.
+	Watchdog *ConnectivityWatchdog
+
+	// NetDiag — история тестов качества канала и NAT по узлам
+	// (bin/netdiag_results.json).
+	NetDiag *netdiag.Store
+
+	// RemoteDrift — фоновая сверка удалённых машин с задеплоенным на них
+	// конфигом и повторный деплой по их политике (одна на процесс: её
+	// отчёты читают и список машин, и Debug API).
+	RemoteDrift *services.DriftReconciler
+
+	// RemoteLogs — накопленные логи машин, которые сейчас смотрят (панель
+	// логов машины и Debug API видят одну историю).
+	RemoteLogs *services.RemoteLogHub
 }
 
 // RunningState - structure for tracking the VPN's running state.
@@ -199,6 +240,26 @@ func (*AppController) GetURLBytes(ctx context.Context, url string, timeout time.
 // This function should be called only once at application startup (typically in main.go).
 // It sets the global singleton instance that can be accessed via GetController().
 func NewAppController(appIconData, greyIconData, greenIconData, redIconData []byte) (*AppController, error) {
+	return newAppController(func(ac *AppController) (*uiservice.UIService, error) {
+		return uiservice.NewUIService(
+			appIconData, greyIconData, greenIconData, redIconData,
+			func() bool { return ac.RunningState.IsRunning() },
+			ac.FileService.SingboxPath,
+			func() { ac.UpdateUI() },
+		)
+	})
+}
+
+// NewHeadlessController — тот же контроллер без Fyne: UIService остаётся nil,
+// окно и трей не создаются, дисплей не нужен. Все UI-хуки ядра уже
+// проверяют UIService на nil (hasUI), так что supervisor, auto-update и
+// Debug API работают как в GUI. Используется -headless и CLI-подкомандами.
+func NewHeadlessController() (*AppController, error) {
+	return newAppController(nil)
+}
+
+// newAppController — общая сборка; newUI == nil означает headless.
+func newAppController(newUI func(ac *AppController) (*uiservice.UIService, error)) (*AppController, error) {
 	ac := &AppController{}
 	locale.CreateHTTPClientFunc = CreateHTTPClient
 
@@ -219,22 +280,19 @@ func NewAppController(appIconData, greyIconData, greenIconData, redIconData []by
 	ac.RunningState = &RunningState{controller: ac}
 	ac.RunningState.Set(false)
 
-	// Initialize UIService
-	uiService, err := uiservice.NewUIService(
-		appIconData, greyIconData, greenIconData, redIconData,
-		func() bool { return ac.RunningState.IsRunning() },
-		ac.FileService.SingboxPath,
-		func() { ac.UpdateUI() },
-	)
-	if err != nil {
-		return nil, fmt.Errorf("NewAppController: cannot create UIService: %w", err)
+	// Initialize UIService (skipped in headless mode)
+	if newUI != nil {
+		uiService, err := newUI(ac)
+		if err != nil {
+			return nil, fmt.Errorf("NewAppController: cannot create UIService: %w", err)
+		}
+		ac.UIService = uiService
 	}
-	ac.UIService = uiService
 	ac.ConsecutiveCrashAttempts = 0
 	ac.ProcessService = NewProcessService(ac)
 	ac.ConfigService = NewConfigService(ac)
 	// Активный движок ядра. По умолчанию — классический spawn; daemon-режим
-	// (macOS) поднимается ниже из settings.json после инициализации сервисов.
+	// (macOS, Linux) поднимается ниже из settings.json после инициализации сервисов.
 	ac.backend = NewLegacyBackend(ac)
 
 	// SPEC 044 feature-probe: генератор outbound'ов деградирует naive-ноды
@@ -249,8 +307,10 @@ func NewAppController(appIconData, greyIconData, greenIconData, redIconData []by
 	subscription.NodeIdentityHashFunc = config.NodeIdentityHash
 
 	// Устанавливаем callback для проверки обновлений при открытии окна
-	ac.UIService.OnWindowShown = func() {
-		ac.ShowUpdatePopupIfAvailable()
+	if ac.UIService != nil {
+		ac.UIService.OnWindowShown = func() {
+			ac.ShowUpdatePopupIfAvailable()
+		}
 	}
 
 	// Initialize APIService
@@ -306,8 +366,9 @@ func NewAppController(appIconData, greyIconData, greenIconData, redIconData []by
 	ac.EventBus = events.NewMemoryBus()
 	ac.StateService = services.NewStateService()
 	ac.StateService.EventBus = ac.EventBus
+	ac.APIService.EventBus = ac.EventBus
 
-	// Daemon-режим (macOS): если включён в settings.json — заменяет
+	// Daemon-режим (macOS, Linux): если включён в settings.json — заменяет
 	// LegacyBackend, установленный выше. Требует готовых FileService и
 	// APIService (транспорт-override ставится в конструкторе).
 	ac.initBackendFromSettings()
@@ -319,6 +380,19 @@ func NewAppController(appIconData, greyIconData, greenIconData, redIconData []by
 	}
 	go ac.startAutoUpdateLoop()
 
+	ac.CoreStats = newCoreStatsMonitor()
+	go ac.runCoreStatsLoop()
+
+	ac.Watchdog = newConnectivityWatchdog()
+	go ac.runConnectivityWatchdog()
+
+	ac.NetDiag = netdiag.NewStore(ac.FileService.ExecDir)
+
+	ac.RemoteDrift = services.NewDriftReconciler(services.NewRemoteRegistry(ac.FileService.ExecDir))
+	ac.RemoteDrift.EventBus = ac.EventBus
+	go ac.RemoteDrift.Run(ac.ctx)
+	ac.RemoteLogs = services.NewRemoteLogHub(services.NewRemoteRegistry(ac.FileService.ExecDir))
+
 	// Set global singleton instance
 	instanceOnce.Do(func() {
 		instance = ac
@@ -334,22 +408,6 @@ func (ac *AppController) UpdateUI() {
 	}
 }
 
-// GetApplication returns the Fyne application instance.
-func (ac *AppController) GetApplication() fyne.App {
-	if ac.hasUI() {
-		return ac.UIService.Application
-	}
-	return nil
-}
-
-// GetMainWindow returns the main window instance.
-func (ac *AppController) GetMainWindow() fyne.Window {
-	if ac.hasUI() {
-		return ac.UIService.MainWindow
-	}
-	return nil
-}
-
 // hasUI проверяет, доступен ли UI для обновлений (MainWindow)
 func (ac *AppController) hasUI() bool {
 	return ac.UIService != nil && ac.UIService.MainWindow != nil
@@ -357,14 +415,19 @@ func (ac *AppController) hasUI() bool {
 
 // GracefulExit performs a graceful shutdown of the application.
 //
-// Two call sites reach this: the tray "Quit" item (and the dashboard Exit
-// button), and main() after Application.Run() returns. exitOnce makes the
-// second call a no-op instead of a second full teardown.
-func (ac *AppController) GracefulExit() {
-	ac.exitOnce.Do(ac.gracefulExit)
+// Call sites: the tray "Quit" item and the dashboard Exit button, a signal
+// to the headless process, RestartLauncher, and main() after
+// Application.Run() returns. exitOnce makes every call after the first a
+// no-op instead of a second full teardown.
+//
+// actor — кто завершает лаунчер (трей, окно, сигнал headless-процессу):
+// остановка ядра на выходе пишется в журнал действий от его имени. При
+// повторном вызове побеждает первый.
+func (ac *AppController) GracefulExit(actor audit.Actor) {
+	ac.exitOnce.Do(func() { ac.gracefulExit(actor) })
 }
 
-func (ac *AppController) gracefulExit() {
+func (ac *AppController) gracefulExit(actor audit.Actor) {
 	// Cancel context to signal all goroutines to stop
 	if ac.cancelFunc != nil {
 		ac.cancelFunc()
@@ -380,9 +443,13 @@ func (ac *AppController) gracefulExit() {
 	// оставить его работать (выход из лаунчера ≠ выключение VPN).
 	waitForStop := true
 	if b := ac.Backend(); b != nil {
+		before := ac.runStateWord()
 		waitForStop = b.OnAppExit()
+		if waitForStop && before == "running" {
+			ac.RecordAudit(audit.Entry{Actor: actor, Action: audit.ActionStop, Target: "exit", Before: before, After: "stopped"})
+		}
 	} else {
-		StopSingBoxProcess()
+		StopSingBoxProcess(actor)
 	}
 
 	if runtime.GOOS == "darwin" {
@@ -620,13 +687,15 @@ func (ac *AppController) GetSelectedIndex() int {
 }
 
 // StartSingBoxProcess launches the sing-box process.
+// actor — кто запускает, для журнала действий (audit_log.go).
 // skipRunningCheck: если true, пропускает проверку на уже запущенный процесс (для автоперезапуска)
 // Note: ProcessService must be initialized in NewAppController. This is a wrapper for backward compatibility.
-func StartSingBoxProcess(skipRunningCheck ...bool) {
+func StartSingBoxProcess(actor audit.Actor, skipRunningCheck ...bool) {
 	ac := GetController()
 	if ac == nil {
 		return
 	}
+	ac.recordRun(actor, audit.ActionStart, "starting")
 	if ac.ProcessService == nil {
 		debuglog.WarnLog("StartSingBoxProcess: ProcessService is nil, this should not happen. Initializing...")
 		ac.ProcessService = NewProcessService(ac)
@@ -639,12 +708,14 @@ func StartSingBoxProcess(skipRunningCheck ...bool) {
 }
 
 // StopSingBoxProcess is the unified function to stop the sing-box process.
+// actor — кто останавливает, для журнала действий (audit_log.go).
 // Note: ProcessService must be initialized in NewAppController. This is a wrapper for backward compatibility.
-func StopSingBoxProcess() {
+func StopSingBoxProcess(actor audit.Actor) {
 	ac := GetController()
 	if ac == nil {
 		return
 	}
+	ac.recordRun(actor, audit.ActionStop, "stopped")
 	if ac.ProcessService == nil {
 		debuglog.WarnLog("StopSingBoxProcess: ProcessService is nil, this should not happen. Initializing...")
 		ac.ProcessService = NewProcessService(ac)
@@ -658,12 +729,13 @@ func StopSingBoxProcess() {
 
 // KillSingBoxForRestart applies a fresh config through the active backend:
 // classic — kill so the monitor restarts the process; daemon — in-process
-// apply without killing anything.
-func KillSingBoxForRestart() {
+// apply without killing anything. actor — для журнала действий.
+func KillSingBoxForRestart(actor audit.Actor) {
 	ac := GetController()
 	if ac == nil || ac.ProcessService == nil {
 		return
 	}
+	ac.recordRun(actor, audit.ActionRestart, "restarting")
 	if b := ac.Backend(); b != nil {
 		b.RestartVPN()
 		return
@@ -705,7 +777,8 @@ func CheckIfSingBoxRunningAtStartUtil() {
 }
 
 // CleanupStaleTunAtStartUtil runs Win7 ghost-TUN cleanup on launcher startup when
-// sing-box is not already running (SPEC 065).
+// sing-box is not already running (SPEC 065), and picks up kill-switch rules a
+// previous session left in place.
 func CleanupStaleTunAtStartUtil() {
 	ac := GetController()
 	if ac == nil || ac.ProcessService == nil {
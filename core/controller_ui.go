@@ -0,0 +1,24 @@
+//go:build !headless
+
+package core
+
+import "fyne.io/fyne/v2"
+
+// Аксессоры с типами Fyne. Headless-сборка (тег headless) их не содержит:
+// ни ui, ни что-либо ещё с Fyne в ней не компилируется.
+
+// GetApplication returns the Fyne application instance.
+func (ac *AppController) GetApplication() fyne.App {
+	if ac.hasUI() {
+		return ac.UIService.Application
+	}
+	return nil
+}
+
+// GetMainWindow returns the main window instance.
+func (ac *AppController) GetMainWindow() fyne.Window {
+	if ac.hasUI() {
+		return ac.UIService.MainWindow
+	}
+	return nil
+}
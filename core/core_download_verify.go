@@ -0,0 +1,339 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// Integrity of downloaded cores.
+//
+// Before anything reaches installBinary the archive's SHA-256 must match what
+// GitHub itself publishes for the release:
+//
+//   - the asset `digest` the releases API returns ("sha256:<hex>");
+//   - the release checksum file (checksums.txt, SHA256SUMS, <asset>.sha256…),
+//     and — when the fork publishes `<checksums>.sig` and a key is pinned in
+//     constants.CoreReleaseSigningKey — its ed25519 signature.
+//
+// Both come straight from GitHub (never through a mirror) and must agree when
+// both exist. A mirror (ghproxy) is only ever tried when a digest is known, and
+// its bytes are refused unless they match it: a mirror is a convenience for
+// slow networks, not a source of trust.
+//
+// The verified hash of the installed binary is recorded next to it
+// (bin/core_integrity.json) so GetInstalledCoreVersion notices a binary that
+// was swapped afterwards.
+
+// ErrDownloadIntegrity — a downloaded file does not match its published digest.
+var ErrDownloadIntegrity = errors.New("download integrity check failed")
+
+// ErrCoreIntegrity — the installed core no longer matches the binary the
+// launcher verified and installed.
+var ErrCoreIntegrity = errors.New("sing-box binary does not match the verified install")
+
+// coreIntegrityFile — record of the verified install, next to the binary.
+const coreIntegrityFile = "core_integrity.json"
+
+// maxChecksumFileSize — checksum and signature files are a few KB; anything
+// bigger is not one.
+const maxChecksumFileSize = 1 << 20
+
+// Verification levels, strongest first (CoreIntegrityRecord.VerifiedBy).
+const (
+	verifiedBySignature = "signature"
+	verifiedByChecksums = "checksums"
+	verifiedByDigest    = "github-digest"
+	verifiedByNone      = "none"
+)
+
+// CoreIntegrityRecord — what was verified and installed.
+type CoreIntegrityRecord struct {
+	Version       string    `json:"version"`
+	Asset         string    `json:"asset"`
+	ArchiveSHA256 string    `json:"archive_sha256"`
+	BinarySHA256  string    `json:"binary_sha256"`
+	VerifiedBy    string    `json:"verified_by"`
+	InstalledAt   time.Time `json:"installed_at"`
+}
+
+// assetDigest — the expected archive hash and how it was established.
+type assetDigest struct {
+	SHA256     string // lowercase hex; "" — nothing published
+	VerifiedBy string
+}
+
+// checksumAssetNames — release assets recognized as a checksum file, in
+// preference order; "<asset>.sha256" is checked separately.
+var checksumAssetNames = []string{"checksums.txt", "sha256sums.txt", "sha256sums", "checksums.sha256"}
+
+// resolveAssetDigest establishes the expected SHA-256 of asset from the
+// release published on GitHub. An error means the release contradicts itself
+// (or its signature is bad) and nothing from it may be installed.
+func (ac *AppController) resolveAssetDigest(ctx context.Context, release *ReleaseInfo, asset *Asset) (assetDigest, error) {
+	var d assetDigest
+	if hexSum, ok := parseGitHubDigest(asset.Digest); ok {
+		d = assetDigest{SHA256: hexSum, VerifiedBy: verifiedByDigest}
+	}
+
+	sums, sumsName := findChecksumAsset(release.Assets, asset.Name)
+	if sums == nil {
+		if d.SHA256 == "" {
+			d.VerifiedBy = verifiedByNone
+		}
+		return d, nil
+	}
+	body, err := fetchReleaseFile(ctx, sums.BrowserDownloadURL)
+	if err != nil {
+		// The API digest alone is enough; without it a missing checksum file
+		// is as good as none.
+		debuglog.WarnLog("resolveAssetDigest: %s: %v", sumsName, err)
+		if d.SHA256 == "" {
+			d.VerifiedBy = verifiedByNone
+		}
+		return d, nil
+	}
+	fromFile, ok := parseChecksumFile(body, asset.Name)
+	if !ok {
+		return d, fmt.Errorf("%w: %s has no entry for %s", ErrDownloadIntegrity, sumsName, asset.Name)
+	}
+	if d.SHA256 != "" && d.SHA256 != fromFile {
+		return d, fmt.Errorf("%w: GitHub digest %s and %s entry %s disagree for %s",
+			ErrDownloadIntegrity, d.SHA256, sumsName, fromFile, asset.Name)
+	}
+	d = assetDigest{SHA256: fromFile, VerifiedBy: verifiedByChecksums}
+
+	sig := findAsset(release.Assets, sumsName+".sig")
+	if sig == nil {
+		return d, nil
+	}
+	if constants.CoreReleaseSigningKey == "" {
+		debuglog.WarnLog("resolveAssetDigest: %s is signed, but no release key is pinned — checked by digest only", sumsName)
+		return d, nil
+	}
+	sigBody, err := fetchReleaseFile(ctx, sig.BrowserDownloadURL)
+	if err != nil {
+		return d, fmt.Errorf("%w: %s: %v", ErrDownloadIntegrity, sig.Name, err)
+	}
+	if err := verifyChecksumSignature(body, sigBody, constants.CoreReleaseSigningKey); err != nil {
+		return d, fmt.Errorf("%w: %s: %v", ErrDownloadIntegrity, sig.Name, err)
+	}
+	d.VerifiedBy = verifiedBySignature
+	return d, nil
+}
+
+// parseGitHubDigest — "sha256:<hex>" from the releases API.
+func parseGitHubDigest(s string) (string, bool) {
+	hexSum, ok := strings.CutPrefix(strings.TrimSpace(s), "sha256:")
+	if !ok {
+		return "", false
+	}
+	return normalizeSHA256(hexSum)
+}
+
+func normalizeSHA256(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// findChecksumAsset — the checksum file covering name: "<name>.sha256" first
+// (it can only be about this asset), then a release-wide list.
+func findChecksumAsset(assets []Asset, name string) (*Asset, string) {
+	if a := findAsset(assets, name+".sha256"); a != nil {
+		return a, a.Name
+	}
+	for _, want := range checksumAssetNames {
+		for i := range assets {
+			if strings.EqualFold(assets[i].Name, want) || strings.HasSuffix(strings.ToLower(assets[i].Name), "_"+want) {
+				return &assets[i], assets[i].Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+func findAsset(assets []Asset, name string) *Asset {
+	for i := range assets {
+		if assets[i].Name == name {
+			return &assets[i]
+		}
+	}
+	return nil
+}
+
+// parseChecksumFile finds name in sha256sum output ("<hex>  <name>", "<hex>
+// *<name>" for binary mode) or a bare "<hex>" single-asset file.
+func parseChecksumFile(body []byte, name string) (string, bool) {
+	lines := strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+	var bare []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			bare = append(bare, fields[0])
+			continue
+		}
+		file := strings.TrimPrefix(fields[len(fields)-1], "*")
+		if file == name || filepath.Base(file) == name {
+			return normalizeSHA256(fields[0])
+		}
+	}
+	if len(bare) == 1 {
+		return normalizeSHA256(bare[0])
+	}
+	return "", false
+}
+
+// verifyChecksumSignature checks an ed25519 signature (raw 64 bytes or
+// base64) over the checksum file against the pinned base64 public key.
+func verifyChecksumSignature(sums, sig []byte, pinnedKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pinnedKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("pinned release key is malformed")
+	}
+	raw := sig
+	if len(raw) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return fmt.Errorf("signature is malformed")
+		}
+		raw = decoded
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), sums, raw) {
+		return fmt.Errorf("signature does not match the pinned release key")
+	}
+	return nil
+}
+
+// fetchReleaseFile downloads a small release asset straight from GitHub.
+func fetchReleaseFile(ctx context.Context, url string) ([]byte, error) {
+	client := CreateHTTPClient(NetworkRequestTimeout)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "LxBox/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer debuglog.RunAndLog("fetchReleaseFile: close response body", resp.Body.Close)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxChecksumFileSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxChecksumFileSize)
+	}
+	return body, nil
+}
+
+// fileSHA256 — lowercase hex SHA-256 of a file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer debuglog.RunAndLog(fmt.Sprintf("fileSHA256: close %s", path), f.Close)
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkFileSHA256 compares a downloaded file with the expected digest.
+func checkFileSHA256(path, want string) error {
+	got, err := fileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadIntegrity, err)
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s has SHA-256 %s, expected %s", ErrDownloadIntegrity, filepath.Base(path), got, want)
+	}
+	return nil
+}
+
+// coreIntegrityPath — the record next to the bundled binary.
+func coreIntegrityPath(binaryPath string) string {
+	return filepath.Join(filepath.Dir(binaryPath), coreIntegrityFile)
+}
+
+// writeCoreIntegrity records the verified install of binaryPath.
+func writeCoreIntegrity(binaryPath string, rec CoreIntegrityRecord) error {
+	sum, err := fileSHA256(binaryPath)
+	if err != nil {
+		return err
+	}
+	rec.BinarySHA256 = sum
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(coreIntegrityPath(binaryPath), data, platform.DefaultFileMode)
+}
+
+// readCoreIntegrity — the install record of binaryPath, if there is a
+// readable one.
+func readCoreIntegrity(binaryPath string) (CoreIntegrityRecord, bool) {
+	var rec CoreIntegrityRecord
+	data, err := os.ReadFile(coreIntegrityPath(binaryPath))
+	if err != nil {
+		return rec, false
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return CoreIntegrityRecord{}, false
+	}
+	return rec, true
+}
+
+// checkCoreIntegrity compares binaryPath with its install record. No record
+// (installed by hand, or before verification existed) is not an error.
+func checkCoreIntegrity(binaryPath string) error {
+	data, err := os.ReadFile(coreIntegrityPath(binaryPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var rec CoreIntegrityRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.BinarySHA256 == "" {
+		return fmt.Errorf("%w: %s is unreadable", ErrCoreIntegrity, coreIntegrityFile)
+	}
+	sum, err := fileSHA256(binaryPath)
+	if err != nil {
+		return err
+	}
+	if sum != rec.BinarySHA256 {
+		return fmt.Errorf("%w: SHA-256 %s, installed v%s was %s (reinstall the core, or delete bin/%s if you replaced it on purpose)",
+			ErrCoreIntegrity, sum, rec.Version, rec.BinarySHA256, coreIntegrityFile)
+	}
+	return nil
+}
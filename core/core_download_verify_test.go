@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	a := sha256Hex([]byte("a"))
+	b := sha256Hex([]byte("b"))
+	sums := []byte(a + "  sing-box-1.0-linux-amd64.tar.gz\r\n" + b + " *dist/sing-box-1.0-windows-amd64.zip\n")
+
+	if got, ok := parseChecksumFile(sums, "sing-box-1.0-linux-amd64.tar.gz"); !ok || got != a {
+		t.Errorf("text mode entry: %q, %v", got, ok)
+	}
+	if got, ok := parseChecksumFile(sums, "sing-box-1.0-windows-amd64.zip"); !ok || got != b {
+		t.Errorf("binary mode entry with a path: %q, %v", got, ok)
+	}
+	if _, ok := parseChecksumFile(sums, "sing-box-1.0-darwin-arm64.tar.gz"); ok {
+		t.Error("a missing asset must not match")
+	}
+	// <asset>.sha256 — одна строка без имени.
+	if got, ok := parseChecksumFile([]byte(a+"\n"), "anything.zip"); !ok || got != a {
+		t.Errorf("bare digest: %q, %v", got, ok)
+	}
+	if _, ok := parseChecksumFile([]byte("nothex  x.zip\n"), "x.zip"); ok {
+		t.Error("a malformed digest must not match")
+	}
+
+	if got, ok := parseGitHubDigest("sha256:" + a); !ok || got != a {
+		t.Errorf("GitHub digest: %q, %v", got, ok)
+	}
+	if _, ok := parseGitHubDigest("sha512:" + a); ok {
+		t.Error("only sha256 digests are understood")
+	}
+}
+
+func TestVerifyChecksumSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+	sums := []byte(sha256Hex([]byte("a")) + "  core.zip\n")
+	sig := ed25519.Sign(priv, sums)
+
+	if err := verifyChecksumSignature(sums, sig, key); err != nil {
+		t.Errorf("raw signature: %v", err)
+	}
+	if err := verifyChecksumSignature(sums, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), key); err != nil {
+		t.Errorf("base64 signature: %v", err)
+	}
+	if err := verifyChecksumSignature(append(sums, 'x'), sig, key); err == nil {
+		t.Error("a modified checksum file must fail")
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := verifyChecksumSignature(sums, sig, base64.StdEncoding.EncodeToString(otherPub)); err == nil {
+		t.Error("a signature by another key must fail")
+	}
+}
+
+// Дайджест API и файл контрольных сумм — два независимых источника с
+// GitHub: расхождение значит, что релизу верить нельзя.
+func TestResolveAssetDigest(t *testing.T) {
+	archive := []byte("core archive")
+	good := sha256Hex(archive)
+	bad := sha256Hex([]byte("something else"))
+	sums := map[string]string{
+		"/good.txt": good + "  core.zip\n",
+		"/bad.txt":  bad + "  core.zip\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := sums[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	ac := &AppController{}
+	ctx := context.Background()
+	release := func(sumsPath string) *ReleaseInfo {
+		r := &ReleaseInfo{Assets: []Asset{{Name: "core.zip", Digest: "sha256:" + good}}}
+		if sumsPath != "" {
+			r.Assets = append(r.Assets, Asset{Name: "checksums.txt", BrowserDownloadURL: srv.URL + sumsPath})
+		}
+		return r
+	}
+
+	r := release("")
+	if d, err := ac.resolveAssetDigest(ctx, r, &r.Assets[0]); err != nil || d.SHA256 != good || d.VerifiedBy != verifiedByDigest {
+		t.Errorf("API digest only: %+v, %v", d, err)
+	}
+	r = release("/good.txt")
+	if d, err := ac.resolveAssetDigest(ctx, r, &r.Assets[0]); err != nil || d.SHA256 != good || d.VerifiedBy != verifiedByChecksums {
+		t.Errorf("agreeing sources: %+v, %v", d, err)
+	}
+	r = release("/bad.txt")
+	if _, err := ac.resolveAssetDigest(ctx, r, &r.Assets[0]); !errors.Is(err, ErrDownloadIntegrity) {
+		t.Errorf("disagreeing sources: err = %v", err)
+	}
+	r = &ReleaseInfo{Assets: []Asset{{Name: "core.zip"}}}
+	if d, err := ac.resolveAssetDigest(ctx, r, &r.Assets[0]); err != nil || d.SHA256 != "" || d.VerifiedBy != verifiedByNone {
+		t.Errorf("nothing published: %+v, %v", d, err)
+	}
+}
+
+func TestDownloadFileRejectsWrongBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+
+	ac := &AppController{}
+	dest := filepath.Join(t.TempDir(), "core.zip")
+	progress := make(chan DownloadProgress, 64)
+	go func() {
+		for range progress {
+		}
+	}()
+	defer close(progress)
+
+	err := ac.downloadFile(context.Background(), srv.URL+"/core.zip", dest, progress, sha256Hex([]byte("original")))
+	if !errors.Is(err, ErrDownloadIntegrity) {
+		t.Fatalf("wrong bytes: err = %v", err)
+	}
+	if err := ac.downloadFile(context.Background(), srv.URL+"/core.zip", dest, progress, sha256Hex([]byte("tampered"))); err != nil {
+		t.Fatalf("matching bytes: %v", err)
+	}
+}
+
+func TestCoreIntegrityRecord(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "sing-box")
+	if err := os.WriteFile(bin, []byte("verified build"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCoreIntegrity(bin); err != nil {
+		t.Fatalf("no record is not an error: %v", err)
+	}
+	if err := writeCoreIntegrity(bin, CoreIntegrityRecord{Version: "1.0", VerifiedBy: verifiedByChecksums, InstalledAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCoreIntegrity(bin); err != nil {
+		t.Fatalf("untouched binary: %v", err)
+	}
+	if err := os.WriteFile(bin, []byte("swapped build"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCoreIntegrity(bin); !errors.Is(err, ErrCoreIntegrity) {
+		t.Fatalf("swapped binary: err = %v", err)
+	}
+}
@@ -41,6 +41,10 @@ func coreReleaseRepoFor(_, _ string) string {
 type ReleaseInfo struct {
 	TagName string  `json:"tag_name"`
 	Assets  []Asset `json:"assets"`
+	// Prerelease / Draft matter only for the launcher's own releases
+	// (launcher_update.go picks a release by channel).
+	Prerelease bool `json:"prerelease"`
+	Draft      bool `json:"draft"`
 }
 
 // Asset contains information about release asset
@@ -48,6 +52,9 @@ type Asset struct {
 	Name               string `json:"name"`
 	BrowserDownloadURL string `json:"browser_download_url"`
 	Size               int64  `json:"size"`
+	// Digest — "sha256:<hex>" GitHub computes for the asset on upload; empty
+	// on releases older than the field (core_download_verify.go).
+	Digest string `json:"digest"`
 }
 
 // DownloadProgress contains information about download progress
@@ -62,6 +69,10 @@ type DownloadProgress struct {
 // Per SPEC 046, the launcher pins constants.RequiredCoreVersion (the sing-box-lx
 // fork tag) for every platform, including Windows 7 (windows/386).
 //
+// The release lands in the core store (bin/cores/<version>/, core_versions.go)
+// and is then activated, so the core it replaces stays installed as the
+// fallback for the trial period.
+//
 // Callers always pass "" — the explicit-version path is kept only for tests
 // and forced reinstall flows that target a specific tag.
 func (ac *AppController) DownloadCore(ctx context.Context, version string, progressChan chan DownloadProgress) {
@@ -71,81 +82,117 @@ func (ac *AppController) DownloadCore(ctx context.Context, version string, progr
 		version = constants.RequiredCoreVersion
 	}
 
+	if err := ac.downloadCoreToStore(ctx, version, progressChan); err != nil {
+		return
+	}
+
+	// 7. Activate: copy into bin/ (the path everything else runs).
+	progressChan <- DownloadProgress{Progress: 95, Message: "Activating...", Status: "extracting"}
+	ac.coreStoreMu.Lock()
+	if _, err := ac.adoptBundledCoreLocked(); err != nil {
+		debuglog.WarnLog("DownloadCore: %v", err)
+	}
+	err := ac.activateCoreLocked(version, true)
+	ac.coreStoreMu.Unlock()
+	if err != nil {
+		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Installation failed: %v", err), Status: "error", Error: fmt.Errorf("DownloadCore: %w", err)}
+		return
+	}
+
+	// 7.5. Daemon-режим: установленная launchd-служба держит СТАРЫЙ бинарь в
+	// памяти (plist указывает на тот же путь bin/sing-box, но замена файла не
+	// перезапускает процесс). Привилегированных вызовов у лаунчера нет —
+	// показываем диалог с готовой sudo-командой kickstart (терминальная
+	// модель); до её выполнения демон работает на старой версии ядра.
+	ac.notifyDaemonServiceAfterCoreUpdate()
+
+	// 8. Done! activateCoreLocked invalidated the session version cache, so
+	// the dashboard shows the freshly installed core without a restart.
+	progressChan <- DownloadProgress{Progress: 100, Message: fmt.Sprintf("sing-box v%s installed successfully!", version), Status: "done"}
+}
+
+// downloadCoreToStore downloads, verifies and extracts a release into
+// bin/cores/<version>/ without touching the active core. Failures are
+// reported into progressChan and returned.
+func (ac *AppController) downloadCoreToStore(ctx context.Context, version string, progressChan chan DownloadProgress) error {
+	fail := func(msg string, err error) error {
+		progressChan <- DownloadProgress{Progress: 0, Message: msg, Status: "error", Error: err}
+		return err
+	}
+
 	// 1. Get release information
 	progressChan <- DownloadProgress{Progress: 5, Message: "Getting release information...", Status: "downloading"}
 	release, err := ac.getReleaseInfo(ctx, version)
 	if err != nil {
-		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Failed to get release info: %v", err), Status: "error", Error: err}
-		return
+		return fail(fmt.Sprintf("Failed to get release info: %v", err), err)
 	}
 
 	// 2. Find correct asset for platform
 	progressChan <- DownloadProgress{Progress: 10, Message: "Finding platform asset...", Status: "downloading"}
 	asset, err := ac.findPlatformAsset(release.Assets)
 	if err != nil {
-		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Failed to find platform asset: %v", err), Status: "error", Error: fmt.Errorf("DownloadCore: %w", err)}
-		return
+		return fail(fmt.Sprintf("Failed to find platform asset: %v", err), fmt.Errorf("DownloadCore: %w", err))
 	}
 
-	// 3. Create temporary directory
-	tempDir := filepath.Join(ac.FileService.ExecDir, "temp")
-	if err := os.MkdirAll(tempDir, platform.DefaultDirMode); err != nil {
-		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Failed to create temp dir: %v", err), Status: "error", Error: fmt.Errorf("DownloadCore: failed to create temp dir: %w", err)}
-		return
+	// 2.5. Establish the expected archive hash from what GitHub publishes —
+	// before a single byte is downloaded, so a mirror can't vouch for itself.
+	progressChan <- DownloadProgress{Progress: 12, Message: "Checking release checksums...", Status: "downloading"}
+	digest, err := ac.resolveAssetDigest(ctx, release, asset)
+	if err != nil {
+		return fail(fmt.Sprintf("Release verification failed: %v", err), fmt.Errorf("DownloadCore: %w", err))
+	}
+
+	// 3. Create temporary directory. Its own one: DownloadCore and
+	// InstallCoreVersion may run at the same time.
+	tempRoot := filepath.Join(ac.FileService.ExecDir, "temp")
+	if err := os.MkdirAll(tempRoot, platform.DefaultDirMode); err != nil {
+		return fail(fmt.Sprintf("Failed to create temp dir: %v", err), fmt.Errorf("DownloadCore: failed to create temp dir: %w", err))
+	}
+	tempDir, err := os.MkdirTemp(tempRoot, "core-")
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to create temp dir: %v", err), fmt.Errorf("DownloadCore: failed to create temp dir: %w", err))
 	}
 	defer func() {
 		if err := os.RemoveAll(tempDir); err != nil {
 			debuglog.WarnLog("DownloadCore: failed to remove temp dir %s: %v", tempDir, err)
 		}
+		// Removes temp/ only once nothing else is using it.
+		_ = os.Remove(tempRoot)
 	}()
 
 	// 4. Download archive
 	archivePath := filepath.Join(tempDir, asset.Name)
 	progressChan <- DownloadProgress{Progress: 15, Message: fmt.Sprintf("Downloading %s...", asset.Name), Status: "downloading"}
-	if err := ac.downloadFile(ctx, asset.BrowserDownloadURL, archivePath, progressChan); err != nil {
-		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Download failed: %v", err), Status: "error", Error: fmt.Errorf("DownloadCore: %w", err)}
-		return
+	if err := ac.downloadFile(ctx, asset.BrowserDownloadURL, archivePath, progressChan, digest.SHA256); err != nil {
+		return fail(fmt.Sprintf("Download failed: %v", err), fmt.Errorf("DownloadCore: %w", err))
 	}
 
 	// 5. Extract archive
 	progressChan <- DownloadProgress{Progress: 80, Message: "Extracting archive...", Status: "extracting"}
 	binaryPath, companionPaths, err := ac.extractArchive(archivePath, tempDir)
 	if err != nil {
-		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Extraction failed: %v", err), Status: "error", Error: fmt.Errorf("DownloadCore: %w", err)}
-		return
+		return fail(fmt.Sprintf("Extraction failed: %v", err), fmt.Errorf("DownloadCore: %w", err))
 	}
 
-	// 6. Copy binary to target directory
+	// 6. Put the binary and its companion libraries (libcronet.* — the naive
+	// outbound in purego core builds loads it from the executable's
+	// directory, SPEC 044) into the store together with the install record.
 	progressChan <- DownloadProgress{Progress: 90, Message: "Installing binary...", Status: "extracting"}
-	if err := ac.installBinary(binaryPath, ac.FileService.SingboxBundledPath); err != nil {
-		progressChan <- DownloadProgress{Progress: 0, Message: fmt.Sprintf("Installation failed: %v", err), Status: "error", Error: fmt.Errorf("DownloadCore: %w", err)}
-		return
-	}
-
-	// 6.5. Install companion libraries (libcronet.*) next to the binary. The
-	// naive outbound in purego core builds loads libcronet at runtime from the
-	// executable's directory (SPEC 044) — without it every naive node fails
-	// `sing-box check`. Non-fatal: the core itself works without the library,
-	// only naive nodes need it.
-	binDir := filepath.Dir(ac.FileService.SingboxBundledPath)
-	for _, libPath := range companionPaths {
-		destPath := filepath.Join(binDir, filepath.Base(libPath))
-		if err := ac.installBinary(libPath, destPath); err != nil {
-			debuglog.WarnLog("DownloadCore: failed to install companion library %s: %v — naive outbounds won't work", filepath.Base(libPath), err)
-		}
+	archiveSum := digest.SHA256
+	if archiveSum == "" {
+		archiveSum, _ = fileSHA256(archivePath)
+	}
+	ac.coreStoreMu.Lock()
+	err = ac.stashCoreLocked(version, binaryPath, companionPaths, CoreIntegrityRecord{
+		Asset: asset.Name, ArchiveSHA256: archiveSum,
+		VerifiedBy: digest.VerifiedBy, InstalledAt: time.Now(),
+	})
+	ac.coreStoreMu.Unlock()
+	if err != nil {
+		return fail(fmt.Sprintf("Installation failed: %v", err), fmt.Errorf("DownloadCore: %w", err))
 	}
-
-	// 6.7. Daemon-режим: установленная launchd-служба держит СТАРЫЙ бинарь в
-	// памяти (plist указывает на тот же путь bin/sing-box, но замена файла не
-	// перезапускает процесс). Привилегированных вызовов у лаунчера нет —
-	// показываем диалог с готовой sudo-командой kickstart (терминальная
-	// модель); до её выполнения демон работает на старой версии ядра.
-	ac.notifyDaemonServiceAfterCoreUpdate()
-
-	// 7. Done! Invalidate the session version cache so the dashboard shows
-	// the freshly installed core without a launcher restart.
-	ac.InvalidateInstalledCoreVersionCache()
-	progressChan <- DownloadProgress{Progress: 100, Message: fmt.Sprintf("sing-box v%s installed successfully!", version), Status: "done"}
+	debuglog.InfoLog("DownloadCore: stored %s as %s (verified by %s)", asset.Name, version, digest.VerifiedBy)
+	return nil
 }
 
 // getReleaseInfo gets release information from the fork's GitHub releases.
@@ -201,37 +248,113 @@ func (ac *AppController) getReleaseInfoFromGitHub(ctx context.Context, version s
 	return &release, nil
 }
 
+// FetchCoreBinaryFor downloads a core release for another platform and returns
+// the extracted sing-box binary. Used by the remote SSH bootstrap
+// (services.CoreBinaryFetcher): version is the machine's pinned core, "" —
+// the fork version the launcher itself runs (constants.RequiredCoreVersion).
+// Nothing is installed locally.
+func (ac *AppController) FetchCoreBinaryFor(ctx context.Context, version, goos, goarch string) ([]byte, error) {
+	if version == "" {
+		version = constants.RequiredCoreVersion
+	}
+	suffix := singboxAssetSuffixFor(goos, goarch)
+	if suffix == "" {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: no sing-box-lx build for %s/%s", goos, goarch)
+	}
+	release, err := ac.getReleaseInfo(ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: %w", err)
+	}
+	var asset *Asset
+	for i := range release.Assets {
+		if strings.Contains(release.Assets[i].Name, suffix) {
+			asset = &release.Assets[i]
+			break
+		}
+	}
+	if asset == nil {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: asset *%s not found in v%s", suffix, version)
+	}
+	// The binary goes onto another machine as root — it gets the same
+	// verification as a local install.
+	digest, err := ac.resolveAssetDigest(ctx, release, asset)
+	if err != nil {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: %w", err)
+	}
+
+	// Own temp dir: DownloadCore wipes <exec>/temp when it finishes, and the
+	// two may run at the same time.
+	tempDir, err := os.MkdirTemp("", "singbox-remote-core-")
+	if err != nil {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			debuglog.WarnLog("FetchCoreBinaryFor: failed to remove temp dir %s: %v", tempDir, err)
+		}
+	}()
+
+	// downloadFile reports progress into the channel unconditionally.
+	progress := make(chan DownloadProgress, 16)
+	drained := make(chan struct{})
+	go func() {
+		for range progress {
+		}
+		close(drained)
+	}()
+	archivePath := filepath.Join(tempDir, asset.Name)
+	err = ac.downloadFile(ctx, asset.BrowserDownloadURL, archivePath, progress, digest.SHA256)
+	close(progress)
+	<-drained
+	if err != nil {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: %w", err)
+	}
+
+	binaryPath, _, err := ac.extractArchive(archivePath, tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("FetchCoreBinaryFor: %w", err)
+	}
+	return os.ReadFile(binaryPath)
+}
+
 // SingboxAssetSuffix returns the asset filename suffix for current platform (e.g. "windows-amd64.zip").
 // Used for UI hints when user downloads manually.
 func SingboxAssetSuffix() string {
-	switch runtime.GOOS {
+	return singboxAssetSuffixFor(runtime.GOOS, runtime.GOARCH)
+}
+
+// singboxAssetSuffixFor is SingboxAssetSuffix for an arbitrary platform — the
+// remote SSH bootstrap installs the core on a machine whose GOOS/GOARCH differ
+// from the launcher's.
+func singboxAssetSuffixFor(goos, goarch string) string {
+	switch goos {
 	case "windows":
-		if runtime.GOARCH == "amd64" {
+		if goarch == "amd64" {
 			return "windows-amd64.zip"
 		}
-		if runtime.GOARCH == "arm64" {
+		if goarch == "arm64" {
 			return "windows-arm64.zip"
 		}
-		if runtime.GOARCH == "386" {
+		if goarch == "386" {
 			return "windows-386-legacy-windows-7.zip"
 		}
 		return ""
 	case "linux":
-		if runtime.GOARCH == "amd64" {
+		if goarch == "amd64" {
 			return "linux-amd64.tar.gz"
 		}
-		if runtime.GOARCH == "arm64" {
+		if goarch == "arm64" {
 			return "linux-arm64.tar.gz"
 		}
-		if runtime.GOARCH == "arm" {
+		if goarch == "arm" {
 			return "linux-armv7.tar.gz"
 		}
 		return ""
 	case "darwin":
-		if runtime.GOARCH == "amd64" {
+		if goarch == "amd64" {
 			return "darwin-amd64.tar.gz"
 		}
-		if runtime.GOARCH == "arm64" {
+		if goarch == "arm64" {
 			return "darwin-arm64.tar.gz"
 		}
 		return ""
@@ -256,15 +379,24 @@ func (ac *AppController) findPlatformAsset(assets []Asset) (*Asset, error) {
 	return nil, fmt.Errorf("findPlatformAsset: asset not found for platform %s/%s", runtime.GOOS, runtime.GOARCH)
 }
 
-// downloadFile downloads a file with progress tracking (with a GitHub mirror fallback)
-func (ac *AppController) downloadFile(ctx context.Context, url, destPath string, progressChan chan DownloadProgress) error {
+// downloadFile downloads a file with progress tracking (with a GitHub mirror
+// fallback). wantSHA256 is the digest GitHub publishes for the file; every
+// source's bytes must match it. Without a digest only the original URL is
+// used: a mirror's bytes could not be checked against anything.
+func (ac *AppController) downloadFile(ctx context.Context, url, destPath string, progressChan chan DownloadProgress, wantSHA256 string) error {
 	// Try to download from original URL
 	err := ac.downloadFileFromURL(ctx, url, destPath, progressChan)
+	if err == nil && wantSHA256 != "" {
+		err = checkFileSHA256(destPath, wantSHA256)
+	}
 	if err == nil {
 		return nil
 	}
+	if wantSHA256 == "" {
+		return fmt.Errorf("downloadFile: %w (no published digest, mirrors not tried)", err)
+	}
 
-	debuglog.InfoLog("downloadFile: failed to download from original URL, trying mirrors...")
+	debuglog.InfoLog("downloadFile: failed to download from original URL (%v), trying mirrors...", err)
 
 	// If that didn't work, try GitHub mirrors
 	mirrors := []string{
@@ -273,11 +405,14 @@ func (ac *AppController) downloadFile(ctx context.Context, url, destPath string,
 
 	for _, mirrorURL := range mirrors {
 		debuglog.DebugLog("downloadFile: trying mirror: %s", mirrorURL)
-		err := ac.downloadFileFromURL(ctx, mirrorURL, destPath, progressChan)
+		err = ac.downloadFileFromURL(ctx, mirrorURL, destPath, progressChan)
+		if err == nil {
+			err = checkFileSHA256(destPath, wantSHA256)
+		}
 		if err == nil {
 			return nil
 		}
-		debuglog.DebugLog("downloadFile: mirror failed: %v", err)
+		debuglog.WarnLog("downloadFile: mirror refused: %v", err)
 	}
 
 	return fmt.Errorf("downloadFile: all download sources failed, last error: %w", err)
@@ -28,3 +28,22 @@ func TestCoreReleaseRepoFor(t *testing.T) {
 		t.Errorf("SingboxCoreRepo = %q, want the fork", constants.SingboxCoreRepo)
 	}
 }
+
+// The SSH bootstrap picks the asset for the MACHINE's platform, not the
+// launcher's: a Windows launcher installs the linux-armv7 core on a router.
+func TestSingboxAssetSuffixFor(t *testing.T) {
+	cases := []struct{ goos, goarch, want string }{
+		{"linux", "amd64", "linux-amd64.tar.gz"},
+		{"linux", "arm64", "linux-arm64.tar.gz"},
+		{"linux", "arm", "linux-armv7.tar.gz"},
+		{"linux", "mips", ""},
+		{"windows", "386", "windows-386-legacy-windows-7.zip"},
+		{"darwin", "arm64", "darwin-arm64.tar.gz"},
+		{"freebsd", "amd64", ""},
+	}
+	for _, c := range cases {
+		if got := singboxAssetSuffixFor(c.goos, c.goarch); got != c.want {
+			t.Errorf("singboxAssetSuffixFor(%q,%q) = %q, want %q", c.goos, c.goarch, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"singbox-launcher/api"
+	"singbox-launcher/internal/ctxutil"
+	"singbox-launcher/internal/debuglog"
+)
+
+// CoreStats — мгновенная сводка ядра: скорость и занятая память.
+type CoreStats struct {
+	// Up/Down — байт в секунду через ядро.
+	Up   int64
+	Down int64
+	// Memory — память ядра в байтах; 0 — источник её ещё не прислал.
+	Memory uint64
+	At     time.Time
+}
+
+// coreStatsSource — бэкенд, умеющий стримить сводку ядра. Classic читает
+// Clash /traffic + /memory, daemon — gRPC SubscribeStatus; UI видит один и тот
+// же поток кадров, какой бы движок ни работал.
+type coreStatsSource interface {
+	// streamCoreStats блокируется до отмены ctx или обрыва источника.
+	streamCoreStats(ctx context.Context, onStats func(CoreStats)) error
+}
+
+const (
+	// coreStatsHistory — сколько секундных кадров держит график (2 минуты).
+	coreStatsHistory = 120
+	// coreStatsStale — кадр старше этого считается потерянным: ядро
+	// остановилось или стрим оборвался, и показывать прошлую скорость — ложь.
+	coreStatsStale = 3 * time.Second
+	// memBucket — шаг усреднения памяти для детектора роста. Посекундные
+	// значения пилят вверх-вниз вместе со сборщиком мусора, поминутные — нет.
+	memBucket = time.Minute
+	// memGrowthBuckets — сколько минут подряд память должна расти, чтобы это
+	// сочли утечкой, а не прогревом кэшей.
+	memGrowthBuckets = 10
+	// memGrowthMinRatio — во сколько раз память должна вырасти за окно.
+	memGrowthMinRatio = 1.5
+)
+
+// CoreStatsMonitor держит историю сводки ядра для графика на вкладке Local,
+// подсказки в трее и предупреждения о росте памяти.
+type CoreStatsMonitor struct {
+	mu      sync.Mutex
+	history []CoreStats
+	// memBuckets — средняя память по минутам, последние memGrowthBuckets.
+	memBuckets []uint64
+	bucketSum  uint64
+	bucketN    uint64
+	bucketAt   time.Time
+	growing    bool
+
+	subs   map[int]func(CoreStats)
+	nextID int
+}
+
+func newCoreStatsMonitor() *CoreStatsMonitor {
+	return &CoreStatsMonitor{subs: make(map[int]func(CoreStats))}
+}
+
+// Subscribe регистрирует колбэк на каждый кадр. Колбэк вызывается из
+// горутины стрима — UI оборачивает обновление в fyne.Do сам.
+func (m *CoreStatsMonitor) Subscribe(fn func(CoreStats)) (cancel func()) {
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.subs[id] = fn
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		delete(m.subs, id)
+		m.mu.Unlock()
+	}
+}
+
+// History — копия последних кадров, старые первыми.
+func (m *CoreStatsMonitor) History() []CoreStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]CoreStats(nil), m.history...)
+}
+
+// Latest — последний кадр. ok=false, если кадров нет или последний устарел.
+func (m *CoreStatsMonitor) Latest() (CoreStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.history) == 0 {
+		return CoreStats{}, false
+	}
+	last := m.history[len(m.history)-1]
+	return last, time.Since(last.At) < coreStatsStale
+}
+
+// MemoryGrowing — память ядра растёт без остановки последние
+// memGrowthBuckets минут и выросла хотя бы в memGrowthMinRatio раз.
+func (m *CoreStatsMonitor) MemoryGrowing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.growing
+}
+
+// push добавляет кадр, пересчитывает детектор и раздаёт кадр подписчикам.
+func (m *CoreStatsMonitor) push(s CoreStats) {
+	m.mu.Lock()
+	m.history = append(m.history, s)
+	if len(m.history) > coreStatsHistory {
+		m.history = m.history[len(m.history)-coreStatsHistory:]
+	}
+	becameGrowing := false
+	if s.Memory > 0 {
+		if m.bucketAt.IsZero() {
+			m.bucketAt = s.At
+		}
+		m.bucketSum += s.Memory
+		m.bucketN++
+		if s.At.Sub(m.bucketAt) >= memBucket {
+			m.memBuckets = append(m.memBuckets, m.bucketSum/m.bucketN)
+			if len(m.memBuckets) > memGrowthBuckets {
+				m.memBuckets = m.memBuckets[len(m.memBuckets)-memGrowthBuckets:]
+			}
+			m.bucketSum, m.bucketN, m.bucketAt = 0, 0, s.At
+			was := m.growing
+			m.growing = memoryGrowing(m.memBuckets)
+			becameGrowing = m.growing && !was
+		}
+	}
+	subs := make([]func(CoreStats), 0, len(m.subs))
+	for _, fn := range m.subs {
+		subs = append(subs, fn)
+	}
+	buckets := append([]uint64(nil), m.memBuckets...)
+	m.mu.Unlock()
+
+	if becameGrowing {
+		debuglog.WarnLog("core stats: core memory has been growing for %d minutes (%d → %d MB)",
+			len(buckets), buckets[0]>>20, buckets[len(buckets)-1]>>20)
+	}
+	for _, fn := range subs {
+		fn(s)
+	}
+}
+
+// reset забывает историю: ядро остановилось или сменился движок, и старые
+// кадры описывают уже не то ядро. Детектор памяти начинает с нуля — после
+// перезапуска ядра прошлый рост ничего не значит.
+func (m *CoreStatsMonitor) reset() {
+	m.mu.Lock()
+	m.history = nil
+	m.memBuckets = nil
+	m.bucketSum, m.bucketN, m.bucketAt = 0, 0, time.Time{}
+	m.growing = false
+	m.mu.Unlock()
+}
+
+// FormatRate — скорость для подписей: «1.2 MB/s». Единицы латиницей, как и
+// везде в лаунчере (профайлер, окно хоста): эти суффиксы не локализуются.
+func FormatRate(bytesPerSec int64) string {
+	return FormatSize(uint64(max(bytesPerSec, 0))) + "/s"
+}
+
+// FormatSize — объём в человеческом виде: «85.3 MB».
+func FormatSize(n uint64) string {
+	const unit = 1024
+	switch {
+	case n >= unit*unit*unit:
+		return fmt.Sprintf("%.2f GB", float64(n)/(unit*unit*unit))
+	case n >= unit*unit:
+		return fmt.Sprintf("%.1f MB", float64(n)/(unit*unit))
+	case n >= unit:
+		return fmt.Sprintf("%.0f KB", float64(n)/unit)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// trayTooltip — подсказка трея по кадру сводки.
+func trayTooltip(s CoreStats) string {
+	return fmt.Sprintf("Singbox Launcher\n↑ %s  ↓ %s", FormatRate(s.Up), FormatRate(s.Down))
+}
+
+// memoryGrowing — каждое поминутное значение больше предыдущего, окно
+// заполнено, и рост за окно не меньше memGrowthMinRatio.
+func memoryGrowing(buckets []uint64) bool {
+	if len(buckets) < memGrowthBuckets {
+		return false
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return false
+		}
+	}
+	return float64(buckets[len(buckets)-1]) >= float64(buckets[0])*memGrowthMinRatio
+}
+
+// runCoreStatsLoop держит подписку на сводку активного бэкенда, пока жив
+// ac.ctx. Подписка живёт, только пока VPN запущен; смена движка или остановка
+// ядра рвут её, и цикл подписывается заново на то, что есть сейчас.
+func (ac *AppController) runCoreStatsLoop() {
+	backoff := time.Second
+	for {
+		if ac.ctx.Err() != nil {
+			return
+		}
+		b := ac.Backend()
+		src, ok := b.(coreStatsSource)
+		if !ok || !ac.RunningState.IsRunning() {
+			if ctxutil.SleepWithContext(ac.ctx, 2*time.Second) != nil {
+				return
+			}
+			continue
+		}
+
+		sctx, cancel := context.WithCancel(ac.ctx)
+		go func() {
+			// Сторож: подписка описывает конкретный бэкенд и живое ядро.
+			for ctxutil.SleepWithContext(sctx, time.Second) == nil {
+				if ac.Backend() != b || !ac.RunningState.IsRunning() {
+					cancel()
+					return
+				}
+			}
+		}()
+		received := false
+		err := src.streamCoreStats(sctx, func(s CoreStats) {
+			received = true
+			ac.CoreStats.push(s)
+			if ac.UIService != nil {
+				ac.UIService.SetTrayTooltip(trayTooltip(s))
+			}
+		})
+		cancel()
+		ac.CoreStats.reset()
+		if ac.UIService != nil {
+			ac.UIService.SetTrayTooltip("")
+		}
+		if err != nil && ac.ctx.Err() == nil {
+			debuglog.DebugLog("core stats: stream unavailable: %v", err)
+		}
+		if received {
+			backoff = time.Second
+		}
+		if ctxutil.SleepWithContext(ac.ctx, backoff) != nil {
+			return
+		}
+		if backoff < 10*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// streamCoreStats реализует coreStatsSource для classic: Clash /traffic даёт
+// кадры раз в секунду, /memory читается параллельно и подмешивается в них.
+func (b *LegacyBackend) streamCoreStats(ctx context.Context, onStats func(CoreStats)) error {
+	if b.ac.APIService == nil {
+		return nil
+	}
+	baseURL, token, enabled := b.ac.APIService.GetClashAPIConfig()
+	if !enabled || baseURL == "" {
+		// Без clash_api графику взять данные неоткуда. Ждём отмены, а не
+		// возвращаемся сразу — иначе цикл крутился бы вхолостую.
+		<-ctx.Done()
+		return nil
+	}
+	return streamClashStats(ctx, baseURL, token, onStats)
+}
+
+// streamClashStats — общий путь Clash HTTP: им пользуется и classic, и daemon
+// с сохранённым Clash-эндпоинтом.
+func streamClashStats(ctx context.Context, baseURL, token string, onStats func(CoreStats)) error {
+	var (
+		memMu sync.Mutex
+		mem   uint64
+	)
+	memCtx, cancelMem := context.WithCancel(ctx)
+	defer cancelMem()
+	go func() {
+		// /memory не критичен: его нет у части сборок, и график скорости без
+		// него работает. Ошибку только логируем.
+		if err := api.StreamMemory(memCtx, baseURL, token, func(s api.MemorySample) {
+			memMu.Lock()
+			mem = s.InUse
+			memMu.Unlock()
+		}); err != nil {
+			debuglog.DebugLog("core stats: memory stream: %v", err)
+		}
+	}()
+	return api.StreamTraffic(ctx, baseURL, token, func(s api.TrafficSample) {
+		memMu.Lock()
+		m := mem
+		memMu.Unlock()
+		onStats(CoreStats{Up: s.Up, Down: s.Down, Memory: m, At: time.Now()})
+	})
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryGrowing(t *testing.T) {
+	rising := []uint64{100, 110, 120, 130, 140, 150, 160, 170, 180, 190}
+	cases := []struct {
+		name    string
+		buckets []uint64
+		want    bool
+	}{
+		{"window not full", rising[:9], false},
+		{"monotonic and +90%", rising, true},
+		{"one dip", []uint64{100, 110, 120, 130, 125, 150, 160, 170, 180, 190}, false},
+		{"plateau", []uint64{100, 110, 120, 130, 130, 150, 160, 170, 180, 190}, false},
+		{"monotonic but small", []uint64{100, 101, 102, 103, 104, 105, 106, 107, 108, 109}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := memoryGrowing(tc.buckets); got != tc.want {
+				t.Errorf("memoryGrowing(%v) = %v, want %v", tc.buckets, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCoreStatsMonitorPushAndReset(t *testing.T) {
+	m := newCoreStatsMonitor()
+	var seen int
+	cancel := m.Subscribe(func(CoreStats) { seen++ })
+
+	start := time.Now().Add(-time.Hour)
+	// Память растёт каждую минуту: 11 минут секундных кадров закрывают 10
+	// поминутных корзин, и детектор срабатывает.
+	for i := 0; i <= 11*60; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		m.push(CoreStats{Up: 1, Down: 2, Memory: uint64(100+i) << 20, At: at})
+	}
+	if got := len(m.History()); got != coreStatsHistory {
+		t.Errorf("history len = %d, want %d", got, coreStatsHistory)
+	}
+	if !m.MemoryGrowing() {
+		t.Error("steady growth must be detected")
+	}
+	if _, ok := m.Latest(); ok {
+		t.Error("frames from an hour ago must be stale")
+	}
+	cancel()
+	before := seen
+	m.push(CoreStats{At: time.Now()})
+	if seen != before {
+		t.Error("cancelled subscriber must not be called")
+	}
+	if _, ok := m.Latest(); !ok {
+		t.Error("fresh frame must be reported as latest")
+	}
+
+	m.reset()
+	if len(m.History()) != 0 || m.MemoryGrowing() {
+		t.Error("reset must drop history and the growth verdict")
+	}
+}
@@ -30,25 +30,41 @@ func (ac *AppController) GetInstalledCoreVersion() (string, error) {
 	if _, err := os.Stat(ac.FileService.SingboxPath); os.IsNotExist(err) {
 		return "", fmt.Errorf("sing-box not found at %s", ac.FileService.SingboxPath)
 	}
+	// Ядро, поставленное лаунчером, сверяется с записью о проверенной
+	// установке ДО запуска: подменённый бинарь не должен исполняться даже
+	// ради `version`. Ядро из PATH (Linux) — чужое, записи о нём нет.
+	if ac.FileService.SingboxPath == ac.FileService.SingboxBundledPath {
+		if err := checkCoreIntegrity(ac.FileService.SingboxPath); err != nil {
+			debuglog.ErrorLog("GetInstalledCoreVersion: %v", err)
+			return "", err
+		}
+	}
+
+	version, _, err := coreBinaryVersion(ac.FileService.SingboxPath)
+	if err != nil {
+		debuglog.WarnLog("GetInstalledCoreVersion: %v", err)
+		return "", err
+	}
+	ac.installedCoreVersionCache = version
+	return version, nil
+}
+
+var coreVersionOutputRegex = regexp.MustCompile(`sing-box version\s+(\S+)`)
 
-	cmd := exec.Command(ac.FileService.SingboxPath, "version")
+// coreBinaryVersion запускает `sing-box version` у конкретного бинаря:
+// версия и полный вывод (из него же берутся build tags).
+func coreBinaryVersion(path string) (string, string, error) {
+	cmd := exec.Command(path, "version")
 	platform.PrepareCommand(cmd)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		debuglog.WarnLog("GetInstalledCoreVersion: command failed: %v, output: %q", err, string(output))
-		return "", fmt.Errorf("failed to get version: %w", err)
+		return "", string(output), fmt.Errorf("failed to get version: %w (output: %q)", err, string(output))
 	}
-
 	outputStr := strings.TrimSpace(string(output))
-	versionRegex := regexp.MustCompile(`sing-box version\s+(\S+)`)
-	matches := versionRegex.FindStringSubmatch(outputStr)
-	if len(matches) > 1 {
-		ac.installedCoreVersionCache = matches[1]
-		return ac.installedCoreVersionCache, nil
+	if matches := coreVersionOutputRegex.FindStringSubmatch(outputStr); len(matches) > 1 {
+		return matches[1], outputStr, nil
 	}
-
-	debuglog.WarnLog("GetInstalledCoreVersion: unable to parse version from output: %q", outputStr)
-	return "", fmt.Errorf("unable to parse version from output: %s", outputStr)
+	return "", outputStr, fmt.Errorf("unable to parse version from output: %s", outputStr)
 }
 
 // GetCoreBinaryPath возвращает путь к бинарнику sing-box для отображения.
@@ -64,7 +80,19 @@ func (ac *AppController) GetCoreBinaryPath() string {
 // GetLatestLauncherVersion получает последнюю версию лаунчера из GitHub.
 // (Sing-box версия не проверяется — она пиннится через constants.RequiredCoreVersion;
 // см. SPEC 046.)
+//
+// В канале prerelease (Settings.LauncherUpdateChannel) версия берётся из
+// списка релизов вместе с pre-release сборками; зеркала там нет.
 func (ac *AppController) GetLatestLauncherVersion() (string, error) {
+	if channel, _ := ac.launcherUpdateSettings(); channel == LauncherChannelPrerelease {
+		ctx, cancel := context.WithTimeout(context.Background(), NetworkRequestTimeout)
+		defer cancel()
+		rel, err := fetchLauncherRelease(ctx, channel)
+		if err != nil {
+			return "", err
+		}
+		return rel.TagName, nil
+	}
 	sources := []struct {
 		name string
 		url  string
@@ -104,7 +132,8 @@ func (ac *AppController) SetCachedLauncherVersion(version string) {
 
 // CheckLauncherVersionOnStartup выполняет разовую проверку версии лаунчера при старте.
 // Проверка всегда выполняется и сохраняет результат в кеш. Попап с обновлением
-// показывается при первом отображении окна (через OnWindowShown).
+// показывается при первом отображении окна (через OnWindowShown). С
+// Settings.LauncherUpdateAuto обновление сразу готовится (launcher_update.go).
 func (ac *AppController) CheckLauncherVersionOnStartup() {
 	if ac.StateService == nil {
 		return
@@ -129,6 +158,12 @@ func (ac *AppController) CheckLauncherVersionOnStartup() {
 
 		ac.SetCachedLauncherVersion(latest)
 		debuglog.InfoLog("CheckLauncherVersionOnStartup: Successfully cached launcher version %s", latest)
+
+		// Автообновление включено — версия готовится в фоне и встанет на
+		// следующем старте (попап предложит перезапуск).
+		if _, auto := ac.launcherUpdateSettings(); auto && ac.ctx != nil {
+			ac.autoStageLauncherUpdate(ac.ctx)
+		}
 	}()
 }
 
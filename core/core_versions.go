@@ -0,0 +1,635 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/ctxutil"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/dialogs"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+// Несколько версий ядра рядом: bin/cores/<version>/.
+//
+// Раньше в bin/ жил один sing-box, и попробовать кандидат в релиз значило
+// заменить рабочее ядро. Теперь каждая скачанная версия лежит в своём
+// каталоге хранилища — с компаньонами (libcronet) и записью о проверенной
+// установке (core_integrity.json). Активная по-прежнему bin/sing-box:
+// переключение копирует выбранную версию туда, поэтому запуск, служба
+// демона (plist/unit смотрят на bin/sing-box), сверка целостности и
+// capability-пробы о хранилище не знают.
+//
+// A/B-откат. Переключение на другую версию открывает пробный период:
+// прежняя помнится как Previous, пока новая не проработает
+// stabilityThreshold. Если новая отвергает config.json, который прежняя
+// принимает, или падает в пробный период — лаунчер сам возвращает прежнюю
+// и говорит об этом. Если конфиг отвергает и прежняя, виноват конфиг, а не
+// ядро: отката нет.
+//
+// Хранилище — только про ядро, которое ставит лаунчер. Ядро из PATH (Linux,
+// SingboxPath != SingboxBundledPath) не переключается и не откатывается.
+//
+// Удалённые машины выбирают версию сами: RemoteDaemon.CoreVersion задаётся
+// при SSH-bootstrap, и FetchCoreBinaryFor качает этот тег под их платформу.
+
+// coreSelectionFile — какая версия активна и что было до неё:
+// bin/cores/cores.json.
+const coreSelectionFile = "cores.json"
+
+var (
+	// ErrCoreVersionInvalid — строка не похожа на тег ядра.
+	ErrCoreVersionInvalid = errors.New("invalid core version")
+	// ErrCoreVersionNotInstalled — такой версии нет в хранилище.
+	ErrCoreVersionNotInstalled = errors.New("core version is not installed")
+	// ErrCoreVersionActive — активную версию удалить нельзя.
+	ErrCoreVersionActive = errors.New("core version is active")
+)
+
+// coreVersionRe — тег форка без "v" (1.14.0-lx.26). Он же имя каталога,
+// поэтому без разделителей пути.
+var coreVersionRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._+-]{0,63}$`)
+
+// NormalizeCoreVersion приводит ввод пользователя к тегу хранилища: без
+// пробелов и ведущего "v".
+func NormalizeCoreVersion(v string) (string, error) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if !coreVersionRe.MatchString(v) || strings.Contains(v, "..") {
+		return "", fmt.Errorf("%w: %q", ErrCoreVersionInvalid, v)
+	}
+	return v, nil
+}
+
+// coreSelection — содержимое cores.json.
+type coreSelection struct {
+	Active   string `json:"active,omitempty"`
+	Previous string `json:"previous,omitempty"`
+	// Trial — Active ещё не проработала stabilityThreshold; до этого
+	// Previous — страховка для отката.
+	Trial bool `json:"trial,omitempty"`
+	// Fallback* — последний автоматический откат (RFC3339 в FallbackAt).
+	FallbackFrom   string `json:"fallback_from,omitempty"`
+	FallbackTo     string `json:"fallback_to,omitempty"`
+	FallbackReason string `json:"fallback_reason,omitempty"`
+	FallbackAt     string `json:"fallback_at,omitempty"`
+}
+
+// CoreVersionInfo — установленная версия для окна версий и Debug API.
+type CoreVersionInfo struct {
+	Version string
+	// Active — стоит в bin/sing-box; Previous — на неё откатимся;
+	// Pinned — constants.RequiredCoreVersion этой сборки лаунчера;
+	// Trial — Active в пробном периоде.
+	Active   bool
+	Previous bool
+	Pinned   bool
+	Trial    bool
+	// VerifiedBy / InstalledAt — из записи о проверенной установке.
+	VerifiedBy  string
+	InstalledAt time.Time
+	// Tags — build tags из `sing-box version`; NaiveSupported/NaiveReason —
+	// тот же вердикт, что CoreSupportsNaive, но для этой версии.
+	Tags           []string
+	NaiveSupported bool
+	NaiveReason    string
+}
+
+// CoreFallbackInfo — последний автоматический откат.
+type CoreFallbackInfo struct {
+	From   string
+	To     string
+	Reason string
+	At     time.Time
+}
+
+// coreProbe — кеш `sing-box version` одной версии по (mtime, size).
+type coreProbe struct {
+	binMtime    time.Time
+	binSize     int64
+	tags        []string
+	naive       bool
+	naiveReason string
+}
+
+func (ac *AppController) coreStoreDir() string {
+	return filepath.Join(filepath.Dir(ac.FileService.SingboxBundledPath), constants.CoreStoreDirName)
+}
+
+func (ac *AppController) coreStoreBinary(version string) string {
+	return filepath.Join(ac.coreStoreDir(), version, filepath.Base(ac.FileService.SingboxBundledPath))
+}
+
+// CoreStoreInUse — запускается ли ядро, которое ставит лаунчер. На Linux
+// sing-box из PATH главнее bin/, и переключение версий на него не влияет.
+func (ac *AppController) CoreStoreInUse() bool {
+	return ac.FileService != nil && ac.FileService.SingboxPath == ac.FileService.SingboxBundledPath
+}
+
+func (ac *AppController) loadCoreSelection() coreSelection {
+	var sel coreSelection
+	data, err := os.ReadFile(filepath.Join(ac.coreStoreDir(), coreSelectionFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debuglog.WarnLog("loadCoreSelection: %v", err)
+		}
+		return sel
+	}
+	if err := json.Unmarshal(data, &sel); err != nil {
+		debuglog.WarnLog("loadCoreSelection: %s is unreadable (%v); starting over", coreSelectionFile, err)
+		return coreSelection{}
+	}
+	return sel
+}
+
+func (ac *AppController) saveCoreSelection(sel coreSelection) error {
+	if err := os.MkdirAll(ac.coreStoreDir(), platform.DefaultDirMode); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sel, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteConfig(filepath.Join(ac.coreStoreDir(), coreSelectionFile), data)
+}
+
+// ListCoreVersions — установленные версии, новые первыми. Ядро, стоящее в
+// bin/ без хранилища (поставлено до него или вручную), при этом попадает в
+// хранилище — иначе первое же переключение затёрло бы его.
+func (ac *AppController) ListCoreVersions() ([]CoreVersionInfo, error) {
+	ac.coreStoreMu.Lock()
+	defer ac.coreStoreMu.Unlock()
+	if _, err := ac.adoptBundledCoreLocked(); err != nil {
+		debuglog.WarnLog("ListCoreVersions: %v", err)
+	}
+	sel := ac.loadCoreSelection()
+
+	entries, err := os.ReadDir(ac.coreStoreDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []CoreVersionInfo
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		bin := ac.coreStoreBinary(name)
+		if _, err := os.Stat(bin); err != nil {
+			continue
+		}
+		info := CoreVersionInfo{
+			Version:  name,
+			Active:   name == sel.Active,
+			Previous: name == sel.Previous,
+			Pinned:   name == constants.RequiredCoreVersion,
+		}
+		info.Trial = info.Active && sel.Trial
+		if rec, ok := readCoreIntegrity(bin); ok {
+			info.VerifiedBy = rec.VerifiedBy
+			info.InstalledAt = rec.InstalledAt
+		}
+		p := ac.probeCoreLocked(bin)
+		info.Tags, info.NaiveSupported, info.NaiveReason = p.tags, p.naive, p.naiveReason
+		out = append(out, info)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if c := CompareVersions(out[i].Version, out[j].Version); c != 0 {
+			return c > 0
+		}
+		return out[i].Version > out[j].Version
+	})
+	return out, nil
+}
+
+// LastCoreFallback — последний автоматический откат, если он был.
+func (ac *AppController) LastCoreFallback() (CoreFallbackInfo, bool) {
+	ac.coreStoreMu.Lock()
+	sel := ac.loadCoreSelection()
+	ac.coreStoreMu.Unlock()
+	if sel.FallbackFrom == "" {
+		return CoreFallbackInfo{}, false
+	}
+	at, _ := time.Parse(time.RFC3339, sel.FallbackAt)
+	return CoreFallbackInfo{From: sel.FallbackFrom, To: sel.FallbackTo, Reason: sel.FallbackReason, At: at}, true
+}
+
+// probeCoreLocked — build tags и вердикт naive для бинаря версии. Неудачная
+// проба не ошибка: версия в списке остаётся, просто без capability.
+func (ac *AppController) probeCoreLocked(bin string) *coreProbe {
+	st, err := os.Stat(bin)
+	if err != nil {
+		return &coreProbe{naive: true}
+	}
+	if p := ac.coreProbeCache[bin]; p != nil && p.binMtime.Equal(st.ModTime()) && p.binSize == st.Size() {
+		return p
+	}
+	p := &coreProbe{binMtime: st.ModTime(), binSize: st.Size(), naive: true}
+	if _, out, err := coreBinaryVersion(bin); err == nil {
+		p.tags = versionTags(out)
+		p.naive, p.naiveReason = naiveVerdictFromVersionOutput(out, cronetLibAvailable(bin))
+	} else {
+		debuglog.WarnLog("probeCore: %s: %v", bin, err)
+	}
+	if ac.coreProbeCache == nil {
+		ac.coreProbeCache = map[string]*coreProbe{}
+	}
+	ac.coreProbeCache[bin] = p
+	return p
+}
+
+// versionTags — build tags из вывода `sing-box version`.
+func versionTags(out string) []string {
+	m := versionTagsRegex.FindStringSubmatch(out)
+	if m == nil {
+		return nil
+	}
+	return strings.Split(m[1], ",")
+}
+
+// CoreTagsDiff — чем набор тегов version отличается от активной: что в ней
+// есть сверх активной и чего нет. Для окна версий: «+with_naive_outbound».
+func CoreTagsDiff(version, active []string) (added, removed []string) {
+	in := func(list []string, t string) bool {
+		for _, x := range list {
+			if x == t {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range version {
+		if !in(active, t) {
+			added = append(added, t)
+		}
+	}
+	for _, t := range active {
+		if !in(version, t) {
+			removed = append(removed, t)
+		}
+	}
+	return added, removed
+}
+
+// adoptBundledCoreLocked кладёт в хранилище ядро, которое стоит в bin/ без
+// него. Возвращает активную версию ("" — ядра нет).
+func (ac *AppController) adoptBundledCoreLocked() (string, error) {
+	bundled := ac.FileService.SingboxBundledPath
+	if _, err := os.Stat(bundled); err != nil {
+		return "", nil
+	}
+	sel := ac.loadCoreSelection()
+	if sel.Active != "" {
+		if _, err := os.Stat(ac.coreStoreBinary(sel.Active)); err == nil {
+			return sel.Active, nil
+		}
+	}
+	// Подменённый бинарь в хранилище не берём: откатиться на него хуже,
+	// чем не откатиться вовсе.
+	if err := checkCoreIntegrity(bundled); err != nil {
+		return "", fmt.Errorf("adopt %s: %w", bundled, err)
+	}
+	raw, _, err := coreBinaryVersion(bundled)
+	if err != nil {
+		return "", fmt.Errorf("adopt %s: %w", bundled, err)
+	}
+	version, err := NormalizeCoreVersion(raw)
+	if err != nil {
+		return "", fmt.Errorf("adopt %s: %w", bundled, err)
+	}
+	if _, err := os.Stat(ac.coreStoreBinary(version)); err != nil {
+		var companions []string
+		if lib := filepath.Join(filepath.Dir(bundled), cronetLibName()); fileExists(lib) {
+			companions = append(companions, lib)
+		}
+		rec, ok := readCoreIntegrity(bundled)
+		if !ok {
+			rec = CoreIntegrityRecord{VerifiedBy: verifiedByNone, InstalledAt: time.Now()}
+		}
+		if err := ac.stashCoreLocked(version, bundled, companions, rec); err != nil {
+			return "", fmt.Errorf("adopt %s: %w", bundled, err)
+		}
+	}
+	sel.Active = version
+	if err := ac.saveCoreSelection(sel); err != nil {
+		return "", err
+	}
+	debuglog.InfoLog("adoptBundledCore: sing-box %s from bin/ is now in the core store", version)
+	return version, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// stashCoreLocked кладёт бинарь и компаньоны в bin/cores/<version>/.
+// Собирается во временном каталоге и переименовывается целиком: оборванная
+// установка не оставляет версию без libcronet или без записи.
+func (ac *AppController) stashCoreLocked(version, binary string, companions []string, rec CoreIntegrityRecord) error {
+	final := filepath.Join(ac.coreStoreDir(), version)
+	staging := filepath.Join(ac.coreStoreDir(), "."+version+".partial")
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(staging, platform.DefaultDirMode); err != nil {
+		return err
+	}
+	bin := filepath.Join(staging, filepath.Base(ac.FileService.SingboxBundledPath))
+	if err := ac.installBinary(binary, bin); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	for _, c := range companions {
+		if err := ac.installBinary(c, filepath.Join(staging, filepath.Base(c))); err != nil {
+			debuglog.WarnLog("stashCore: companion %s: %v — naive outbounds of %s won't work", filepath.Base(c), err, version)
+		}
+	}
+	rec.Version = version
+	if err := writeCoreIntegrity(bin, rec); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	if err := os.RemoveAll(final); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+	return os.Rename(staging, final)
+}
+
+// activateCoreLocked копирует версию в bin/sing-box. trial — открыть
+// пробный период с прежней активной как страховкой (ручное переключение и
+// обновление); откат сам пробу не открывает.
+func (ac *AppController) activateCoreLocked(version string, trial bool) error {
+	bin := ac.coreStoreBinary(version)
+	if _, err := os.Stat(bin); err != nil {
+		return fmt.Errorf("%w: %s", ErrCoreVersionNotInstalled, version)
+	}
+	if err := checkCoreIntegrity(bin); err != nil {
+		return err
+	}
+	rec, _ := readCoreIntegrity(bin)
+	rec.Version = version
+
+	bundled := ac.FileService.SingboxBundledPath
+	// Запись прежней версии уходит первой: не дописанная новая не должна
+	// выдать свежий бинарь за подменённый.
+	if err := os.Remove(coreIntegrityPath(bundled)); err != nil && !os.IsNotExist(err) {
+		debuglog.WarnLog("activateCore: failed to remove old integrity record: %v", err)
+	}
+	if err := ac.installBinary(bin, bundled); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(filepath.Dir(bin))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == filepath.Base(bin) || name == coreIntegrityFile {
+			continue
+		}
+		if err := ac.installBinary(filepath.Join(filepath.Dir(bin), name), filepath.Join(filepath.Dir(bundled), name)); err != nil {
+			debuglog.WarnLog("activateCore: companion %s: %v", name, err)
+		}
+	}
+	if err := writeCoreIntegrity(bundled, rec); err != nil {
+		debuglog.WarnLog("activateCore: failed to record integrity of %s: %v", bundled, err)
+	}
+
+	sel := ac.loadCoreSelection()
+	if sel.Active != version {
+		sel.Previous = sel.Active
+		sel.Trial = trial && sel.Previous != ""
+	}
+	sel.Active = version
+	if err := ac.saveCoreSelection(sel); err != nil {
+		return err
+	}
+	ac.InvalidateInstalledCoreVersionCache()
+	return nil
+}
+
+// InstallCoreVersion скачивает версию в хранилище, не трогая активную.
+// Закрывает progressChan, как DownloadCore.
+func (ac *AppController) InstallCoreVersion(ctx context.Context, version string, progressChan chan DownloadProgress) {
+	defer close(progressChan)
+	v, err := NormalizeCoreVersion(version)
+	if err != nil {
+		progressChan <- DownloadProgress{Progress: 0, Message: err.Error(), Status: "error", Error: err}
+		return
+	}
+	if err := ac.downloadCoreToStore(ctx, v, progressChan); err != nil {
+		return
+	}
+	progressChan <- DownloadProgress{Progress: 100, Message: fmt.Sprintf("sing-box v%s downloaded", v), Status: "done"}
+}
+
+// ActivateCoreVersion делает установленную версию активной. Работающее ядро
+// перезапускается уже на ней; прежняя остаётся страховкой на пробный
+// период.
+func (ac *AppController) ActivateCoreVersion(version string) error {
+	v, err := NormalizeCoreVersion(version)
+	if err != nil {
+		return err
+	}
+	ac.coreStoreMu.Lock()
+	if _, err := ac.adoptBundledCoreLocked(); err != nil {
+		debuglog.WarnLog("ActivateCoreVersion: %v", err)
+	}
+	err = ac.activateCoreLocked(v, true)
+	ac.coreStoreMu.Unlock()
+	if err != nil {
+		return err
+	}
+	debuglog.InfoLog("ActivateCoreVersion: sing-box %s is now active", v)
+	ac.notifyDaemonServiceAfterCoreUpdate()
+	if ac.RunningState != nil && ac.RunningState.IsRunning() {
+		if b := ac.Backend(); b != nil {
+			b.RestartVPN()
+		} else if ac.ProcessService != nil {
+			ac.ProcessService.KillForRestart()
+		}
+	}
+	return nil
+}
+
+// RemoveCoreVersion удаляет версию из хранилища. Активную — нельзя;
+// удалённая прежняя снимает страховку пробного периода.
+func (ac *AppController) RemoveCoreVersion(version string) error {
+	v, err := NormalizeCoreVersion(version)
+	if err != nil {
+		return err
+	}
+	ac.coreStoreMu.Lock()
+	defer ac.coreStoreMu.Unlock()
+	sel := ac.loadCoreSelection()
+	if v == sel.Active {
+		return fmt.Errorf("%w: %s (switch to another version first)", ErrCoreVersionActive, v)
+	}
+	dir := filepath.Join(ac.coreStoreDir(), v)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%w: %s", ErrCoreVersionNotInstalled, v)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	delete(ac.coreProbeCache, ac.coreStoreBinary(v))
+	if sel.Previous == v {
+		sel.Previous, sel.Trial = "", false
+		return ac.saveCoreSelection(sel)
+	}
+	return nil
+}
+
+// fallbackCoreLocked возвращает прежнюю версию вместо пробной.
+func (ac *AppController) fallbackCoreLocked(reason string) (from, to string, ok bool) {
+	if !ac.CoreStoreInUse() {
+		return "", "", false
+	}
+	sel := ac.loadCoreSelection()
+	if !sel.Trial || sel.Previous == "" {
+		return "", "", false
+	}
+	from, to = sel.Active, sel.Previous
+	if err := ac.activateCoreLocked(to, false); err != nil {
+		debuglog.ErrorLog("fallbackCore: cannot return to sing-box %s: %v", to, err)
+		return "", "", false
+	}
+	sel = ac.loadCoreSelection()
+	sel.FallbackFrom, sel.FallbackTo, sel.FallbackReason = from, to, reason
+	sel.FallbackAt = time.Now().UTC().Format(time.RFC3339)
+	if err := ac.saveCoreSelection(sel); err != nil {
+		debuglog.WarnLog("fallbackCore: %v", err)
+	}
+	debuglog.WarnLog("fallbackCore: sing-box %s → %s: %s", from, to, reason)
+	return from, to, true
+}
+
+func (ac *AppController) notifyCoreFallback(from, to, reason string) {
+	if !ac.hasUI() {
+		return
+	}
+	dialogs.ShowInfo(ac.UIService.MainWindow, locale.T("core.versions.fallback_title"),
+		locale.Tf("core.versions.fallback_message", from, to, reason))
+	if ac.UIService.CoreVersionsChangedFunc != nil {
+		ac.UIService.CoreVersionsChangedFunc()
+	}
+}
+
+// fallbackCoreOnCheckFailure — пробная версия отвергла config.json. Если
+// прежняя его принимает, виновато ядро: откатываемся, и конфиг валиден.
+// Если отвергает и прежняя — виноват конфиг, ядро не трогаем.
+func (ac *AppController) fallbackCoreOnCheckFailure(configPath string, checkErr error) bool {
+	ac.coreStoreMu.Lock()
+	sel := ac.loadCoreSelection()
+	if !ac.CoreStoreInUse() || !sel.Trial || sel.Previous == "" {
+		ac.coreStoreMu.Unlock()
+		return false
+	}
+	prevBin := ac.coreStoreBinary(sel.Previous)
+	// validateConfigViaSingBox молча пропускает отсутствующий бинарь — а
+	// «пропустил» тут значило бы «принял».
+	if !fileExists(prevBin) || validateConfigViaSingBox(prevBin, configPath) != nil {
+		ac.coreStoreMu.Unlock()
+		return false
+	}
+	reason := "sing-box check: " + firstLine(checkErr.Error())
+	from, to, ok := ac.fallbackCoreLocked(reason)
+	ac.coreStoreMu.Unlock()
+	if ok {
+		ac.notifyCoreFallback(from, to, reason)
+	}
+	return ok
+}
+
+// fallbackCoreAfterCrash — ядро упало, не доработав пробный период. Зовётся
+// из Monitor / onPrivilegedScriptExited под CmdMutex: сам его не берёт и
+// ядро не запускает — перезапуск за вызывающим.
+func (ac *AppController) fallbackCoreAfterCrash(exitErr error) bool {
+	reason := "exited during the trial period"
+	if exitErr != nil {
+		reason = fmt.Sprintf("exited during the trial period: %v", exitErr)
+	}
+	ac.coreStoreMu.Lock()
+	from, to, ok := ac.fallbackCoreLocked(reason)
+	ac.coreStoreMu.Unlock()
+	if ok {
+		ac.notifyCoreFallback(from, to, reason)
+	}
+	return ok
+}
+
+// watchCoreTrial закрывает пробный период, если запуск pid проработал
+// stabilityThreshold. Зовётся после каждого успешного запуска; pid отличает
+// этот запуск от перезапусков после него.
+func (ac *AppController) watchCoreTrial(pid int) {
+	ac.coreStoreMu.Lock()
+	trial := ac.CoreStoreInUse() && ac.loadCoreSelection().Trial
+	ac.coreStoreMu.Unlock()
+	if !trial {
+		return
+	}
+	ctx := ac.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go func() {
+		if ctxutil.SleepWithContext(ctx, stabilityThreshold) != nil {
+			return
+		}
+		ac.CmdMutex.Lock()
+		same := ac.RunningState != nil && ac.RunningState.IsRunning() && ac.currentCorePIDLocked() == pid
+		ac.CmdMutex.Unlock()
+		if same {
+			ac.confirmCoreTrial()
+		}
+	}()
+}
+
+// confirmCoreTrial — пробная версия доказала, что работает: откат выключен,
+// прежняя остаётся в списке для ручного переключения.
+func (ac *AppController) confirmCoreTrial() {
+	ac.coreStoreMu.Lock()
+	defer ac.coreStoreMu.Unlock()
+	sel := ac.loadCoreSelection()
+	if !sel.Trial {
+		return
+	}
+	sel.Trial = false
+	if err := ac.saveCoreSelection(sel); err != nil {
+		debuglog.WarnLog("confirmCoreTrial: %v", err)
+		return
+	}
+	debuglog.InfoLog("confirmCoreTrial: sing-box %s ran for %v; trial over", sel.Active, stabilityThreshold)
+}
+
+// currentCorePIDLocked — PID, по которому следим за текущим запуском.
+func (ac *AppController) currentCorePIDLocked() int {
+	if ac.SingboxPrivilegedMode {
+		return ac.SingboxPrivilegedPID
+	}
+	if ac.SingboxCmd != nil && ac.SingboxCmd.Process != nil {
+		return ac.SingboxCmd.Process.Pid
+	}
+	return 0
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"singbox-launcher/core/services"
+)
+
+func TestNormalizeCoreVersion(t *testing.T) {
+	for in, want := range map[string]string{
+		"1.14.0-lx.26":    "1.14.0-lx.26",
+		" v1.13.2 ":       "1.13.2",
+		"1.12.0+build.7":  "1.12.0+build.7",
+		"1.14.0-beta.1_x": "1.14.0-beta.1_x",
+	} {
+		got, err := NormalizeCoreVersion(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeCoreVersion(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	// Версия — имя каталога в bin/cores/: разделители пути и «..» недопустимы.
+	for _, in := range []string{"", "v", "../1.0", "1.0/..", `1.0\x`, "1..0", ".hidden", "1.0 rc"} {
+		if _, err := NormalizeCoreVersion(in); !errors.Is(err, ErrCoreVersionInvalid) {
+			t.Errorf("NormalizeCoreVersion(%q): err = %v, want ErrCoreVersionInvalid", in, err)
+		}
+	}
+}
+
+func TestCoreTagsDiff(t *testing.T) {
+	added, removed := CoreTagsDiff(
+		[]string{"with_gvisor", "with_naive_outbound", "with_xhttp"},
+		[]string{"with_gvisor", "with_xhttp", "with_awg"})
+	if !slices.Equal(added, []string{"with_naive_outbound"}) || !slices.Equal(removed, []string{"with_awg"}) {
+		t.Errorf("added %v, removed %v", added, removed)
+	}
+	if a, r := CoreTagsDiff([]string{"x"}, []string{"x"}); a != nil || r != nil {
+		t.Errorf("same tags: added %v, removed %v", a, r)
+	}
+}
+
+// fakeCore пишет скрипт, который отвечает на `version` как sing-box, а на
+// `check` — кодом checkExit.
+func fakeCore(t *testing.T, path, version string, checkExit int) {
+	t.Helper()
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = version ]; then printf 'sing-box version " + version + "\\n\\nTags: with_gvisor,with_xhttp\\n'; exit 0; fi\n" +
+		"if [ \"$1\" = check ]; then echo 'FATAL config rejected' >&2; exit " + strconv.Itoa(checkExit) + "; fi\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newCoreStoreController(t *testing.T) *AppController {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake core is a shell script")
+	}
+	execDir := t.TempDir()
+	bundled := filepath.Join(execDir, "bin", "sing-box")
+	return &AppController{FileService: &services.FileService{
+		ExecDir: execDir, SingboxPath: bundled, SingboxBundledPath: bundled,
+	}}
+}
+
+func TestCoreVersionsAdoptSwitchRemove(t *testing.T) {
+	ac := newCoreStoreController(t)
+	bundled := ac.FileService.SingboxBundledPath
+	fakeCore(t, bundled, "1.13.0-lx.1", 0)
+
+	// Ядро, стоявшее в bin/ до хранилища, попадает в него при первом списке.
+	list, err := ac.ListCoreVersions()
+	if err != nil || len(list) != 1 || list[0].Version != "1.13.0-lx.1" || !list[0].Active {
+		t.Fatalf("adopted list = %+v, %v", list, err)
+	}
+	if !slices.Equal(list[0].Tags, []string{"with_gvisor", "with_xhttp"}) {
+		t.Errorf("tags = %v", list[0].Tags)
+	}
+
+	src := filepath.Join(t.TempDir(), "sing-box")
+	fakeCore(t, src, "1.14.0-lx.2", 0)
+	ac.coreStoreMu.Lock()
+	err = ac.stashCoreLocked("1.14.0-lx.2", src, nil, CoreIntegrityRecord{VerifiedBy: "digest", InstalledAt: time.Now()})
+	if err == nil {
+		err = ac.activateCoreLocked("1.14.0-lx.2", true)
+	}
+	ac.coreStoreMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := coreBinaryVersion(bundled); err != nil || v != "1.14.0-lx.2" {
+		t.Fatalf("bin/sing-box after switch = %q, %v", v, err)
+	}
+	if err := checkCoreIntegrity(bundled); err != nil {
+		t.Errorf("switched core fails its integrity record: %v", err)
+	}
+	sel := ac.loadCoreSelection()
+	if sel.Active != "1.14.0-lx.2" || sel.Previous != "1.13.0-lx.1" || !sel.Trial {
+		t.Errorf("selection after switch = %+v", sel)
+	}
+
+	if err := ac.RemoveCoreVersion("1.14.0-lx.2"); !errors.Is(err, ErrCoreVersionActive) {
+		t.Errorf("removing the active version: %v", err)
+	}
+	if err := ac.RemoveCoreVersion("9.9.9"); !errors.Is(err, ErrCoreVersionNotInstalled) {
+		t.Errorf("removing a missing version: %v", err)
+	}
+	// Удалённая прежняя снимает страховку: откатываться больше не на что.
+	if err := ac.RemoveCoreVersion("v1.13.0-lx.1"); err != nil {
+		t.Fatal(err)
+	}
+	if sel := ac.loadCoreSelection(); sel.Previous != "" || sel.Trial {
+		t.Errorf("selection after removing the previous version = %+v", sel)
+	}
+}
+
+func TestCoreVersionsFallback(t *testing.T) {
+	ac := newCoreStoreController(t)
+	bundled := ac.FileService.SingboxBundledPath
+	fakeCore(t, bundled, "1.13.0-lx.1", 0)
+	if _, err := ac.ListCoreVersions(); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "sing-box")
+	fakeCore(t, src, "1.14.0-lx.2", 1)
+	ac.coreStoreMu.Lock()
+	err := ac.stashCoreLocked("1.14.0-lx.2", src, nil, CoreIntegrityRecord{})
+	if err == nil {
+		err = ac.activateCoreLocked("1.14.0-lx.2", true)
+	}
+	ac.coreStoreMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Прежняя версия принимает конфиг, новая — нет: виновато ядро.
+	if !ac.fallbackCoreOnCheckFailure(filepath.Join(t.TempDir(), "config.json"), errors.New("FATAL config rejected\nmore")) {
+		t.Fatal("no fallback although the previous core accepts the config")
+	}
+	if v, _, _ := coreBinaryVersion(bundled); v != "1.13.0-lx.1" {
+		t.Errorf("bin/sing-box after fallback = %q", v)
+	}
+	fb, ok := ac.LastCoreFallback()
+	if !ok || fb.From != "1.14.0-lx.2" || fb.To != "1.13.0-lx.1" || fb.Reason != "sing-box check: FATAL config rejected" {
+		t.Errorf("last fallback = %+v, %v", fb, ok)
+	}
+	// Откат не открывает новую пробу: второго отката не бывает.
+	if ac.fallbackCoreAfterCrash(nil) {
+		t.Error("fallback out of a non-trial version")
+	}
+
+	// Ядро из PATH хранилищу не подчиняется.
+	ac.FileService.SingboxPath = "/usr/bin/sing-box"
+	ac.coreStoreMu.Lock()
+	err = ac.activateCoreLocked("1.14.0-lx.2", true)
+	ac.coreStoreMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac.fallbackCoreAfterCrash(errors.New("exit status 1")) {
+		t.Error("fallback of a core that isn't launched from bin/")
+	}
+}
@@ -0,0 +1,293 @@
+//go:build linux
+
+package core
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Управление systemd-службой демона `sing-box lxd`. У форка на Linux нет
+// `--service=install` (возвращает "not implemented"), поэтому unit-файл и
+// скрипт установки генерирует лаунчер: он пишет их в bin/daemon/systemd/ и
+// показывает одну команду — `sudo sh …/install.sh` (system) или
+// `sh …/install.sh` (user). Скрипт можно прочитать до запуска; привилегий
+// сам лаунчер по-прежнему не берёт (та же терминальная модель, что на macOS,
+// только без Terminal.app — команды копируются).
+//
+// System-служба запускает КОПИЮ ядра из /usr/local/lib/sing-box-lxd: root
+// не должен исполнять бинарь из каталога, доступного пользователю на запись
+// (иначе любой процесс пользователя получал бы root при следующем рестарте).
+// User-служба (`systemctl --user`) работает с правами пользователя и берёт
+// ядро лаунчера как есть; TUN ей недоступен (нет CAP_NET_ADMIN).
+
+const (
+	// daemonSystemdUnitName — имя unit'а в обоих scope.
+	daemonSystemdUnitName = "sing-box-lxd.service"
+	// daemonSystemUnitDir / daemonSystemStateDir / daemonSystemBinary —
+	// раскладка system-службы (FHS: unit администратора, state в /var/lib,
+	// собственная копия ядра в /usr/local/lib).
+	daemonSystemUnitDir  = "/etc/systemd/system"
+	daemonSystemStateDir = "/var/lib/sing-box-lxd"
+	daemonSystemBinary   = "/usr/local/lib/sing-box-lxd/sing-box"
+
+	// daemonFallbackRuntimeDir — каталог рантайм-файлов демона, используемый
+	// ТОЛЬКО когда /admin/info недоступен (демон старой сборки). Обычный путь
+	// — state_dir из паспорта демона; user-служба паспорт отдаёт всегда.
+	daemonFallbackRuntimeDir = daemonSystemStateDir
+
+	// daemonListenPortFirst / daemonListenPortScan — диапазон, в котором
+	// install ищет свободный loopback-порт (тот же старт, что у launchd-
+	// установщика форка).
+	daemonListenPortFirst = 19091
+	daemonListenPortScan  = 50
+)
+
+// daemonServiceScopes — systemd умеет и системный, и пользовательский unit.
+var daemonServiceScopes = []string{DaemonScopeSystem, DaemonScopeUser}
+
+// systemdLayout — пути службы выбранного scope. Строится на каждое действие:
+// scope и путь к ядру могли смениться с прошлого раза.
+type systemdLayout struct {
+	user bool
+	// unitPath — куда устанавливается unit.
+	unitPath string
+	// stateDir — дом демона (`--state-dir`): daemon.json, ключи, last-good.
+	stateDir string
+	// source — ядро лаунчера; binary — что запускает ExecStart (для system —
+	// копия source, для user — сам source).
+	source string
+	binary string
+}
+
+func (ac *AppController) systemdLayout() systemdLayout {
+	source := ac.FileService.SingboxPath
+	if ac.DaemonServiceScope() != DaemonScopeUser {
+		return systemdLayout{
+			unitPath: filepath.Join(daemonSystemUnitDir, daemonSystemdUnitName),
+			stateDir: daemonSystemStateDir,
+			source:   source,
+			binary:   daemonSystemBinary,
+		}
+	}
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return systemdLayout{
+		user:     true,
+		unitPath: filepath.Join(configHome, "systemd", "user", daemonSystemdUnitName),
+		stateDir: filepath.Join(stateHome, "sing-box-lxd"),
+		source:   source,
+		binary:   source,
+	}
+}
+
+// systemctl — systemctl нужного менеджера (без sudo: его добавляет caller).
+func (l systemdLayout) systemctl() string {
+	if l.user {
+		return "systemctl --user"
+	}
+	return "systemctl"
+}
+
+// sudo — префикс для привилегированных команд scope.
+func (l systemdLayout) sudo() string {
+	if l.user {
+		return ""
+	}
+	return "sudo "
+}
+
+// execArgs — командная строка демона; её же исполняет ExecStart unit'а.
+func (l systemdLayout) execArgs() []string {
+	return []string{l.binary, "lxd", "--state-dir", l.stateDir}
+}
+
+// unit генерирует unit-файл службы. Restart=on-failure — аналог KeepAlive
+// launchd: упавший демон поднимается, а `systemctl stop` остаётся стопом.
+func (l systemdLayout) unit() string {
+	quoted := make([]string, 0, 4)
+	for _, arg := range l.execArgs() {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+	wantedBy := "multi-user.target"
+	if l.user {
+		wantedBy = "default.target"
+	}
+	return fmt.Sprintf(`# Generated by singbox-launcher. Reinstall from the launcher instead of editing.
+[Unit]
+Description=sing-box lxd daemon (singbox-launcher)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s
+Restart=on-failure
+RestartSec=2
+LimitNOFILE=1048576
+
+[Install]
+WantedBy=%s
+`, strings.Join(quoted, " "), wantedBy)
+}
+
+// installScript генерирует скрипт установки. Повторный запуск — штатный
+// путь обновления: daemon.json (адрес, секрет, доверенные клиенты рядом)
+// не перезаписывается, копия ядра и unit обновляются, служба рестартует.
+// Секрет рождается на целевой машине из /dev/urandom — лаунчер его не знает
+// (модель владения: секретом владеет демон).
+func (l systemdLayout) installScript(unitSource, listen string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Generated by singbox-launcher: installs sing-box lxd as a systemd ")
+	if l.user {
+		b.WriteString("user service.\n")
+	} else {
+		b.WriteString("system service. Run it with sudo.\n")
+	}
+	b.WriteString("set -eu\n")
+	if !l.user {
+		fmt.Fprintf(&b, "install -D -m 0755 %s %s\n", shellQuote(l.source), shellQuote(l.binary))
+	}
+	stateDir := shellQuote(l.stateDir)
+	daemonJSON := shellQuote(filepath.Join(l.stateDir, "daemon.json"))
+	fmt.Fprintf(&b, "mkdir -p %s\nchmod 0700 %s\n", stateDir, stateDir)
+	fmt.Fprintf(&b, "if [ ! -f %s ]; then\n", daemonJSON)
+	b.WriteString("\tsecret=$(od -An -N24 -tx1 /dev/urandom | tr -d ' \\n')\n")
+	fmt.Fprintf(&b, "\t(umask 077; printf '{\"listen\":\"%%s\",\"tls\":true,\"secret\":\"%%s\"}\\n' %s \"$secret\" > %s)\n",
+		shellQuote(listen), daemonJSON)
+	b.WriteString("fi\n")
+	fmt.Fprintf(&b, "mkdir -p %s\n", shellQuote(filepath.Dir(l.unitPath)))
+	fmt.Fprintf(&b, "install -m 0644 %s %s\n", shellQuote(unitSource), shellQuote(l.unitPath))
+	systemctl := l.systemctl()
+	fmt.Fprintf(&b, "%s daemon-reload\n", systemctl)
+	fmt.Fprintf(&b, "%s enable %s\n", systemctl, daemonSystemdUnitName)
+	fmt.Fprintf(&b, "%s restart %s\n", systemctl, daemonSystemdUnitName)
+	// Приглашение — последней строкой вывода, как у launchd-установщика.
+	b.WriteString("sleep 1\n")
+	fmt.Fprintf(&b, "%s lxd client add --state-dir %s --name singbox-launcher\n", shellQuote(l.binary), stateDir)
+	return b.String()
+}
+
+// daemonSystemdDir — куда лаунчер кладёт сгенерированные unit и скрипт.
+func daemonSystemdDir(execDir string) string {
+	return filepath.Join(DaemonIdentityDir(execDir), "systemd")
+}
+
+// daemonServiceInstalled — unit выбранного scope установлен.
+func (ac *AppController) daemonServiceInstalled() bool {
+	_, err := os.Stat(ac.systemdLayout().unitPath)
+	return err == nil
+}
+
+// DaemonShowSecretCommand — команда просмотра Bearer-секрета в daemon.json
+// службы (для справки у поля секрета: секретом владеет демон, лаунчер его
+// не хранит).
+func (ac *AppController) DaemonShowSecretCommand() string {
+	l := ac.systemdLayout()
+	return l.sudo() + "grep '\"secret\"' " + shellQuote(filepath.Join(l.stateDir, "daemon.json"))
+}
+
+// DaemonKickstartCommand — команда перезапуска службы после обновления
+// ядра. System-служба исполняет свою копию, поэтому сначала её обновляем.
+func (ac *AppController) DaemonKickstartCommand() string {
+	l := ac.systemdLayout()
+	if l.user {
+		return "systemctl --user restart " + daemonSystemdUnitName
+	}
+	return fmt.Sprintf("sudo sh -c %s", shellQuote(fmt.Sprintf("install -m 0755 %s %s && systemctl restart %s",
+		shellQuote(l.source), shellQuote(l.binary), daemonSystemdUnitName)))
+}
+
+// DaemonRepairCommand — команда пере-сопряжения: `lxd client add` по
+// state-dir службы печатает свежее одноразовое приглашение. State-dir
+// передаётся явно: в отличие от launchd, искать установленную службу
+// форку на Linux негде.
+func (ac *AppController) DaemonRepairCommand() string {
+	l := ac.systemdLayout()
+	return fmt.Sprintf("%s%s lxd client add --state-dir %s --name singbox-launcher",
+		l.sudo(), shellQuote(l.binary), shellQuote(l.stateDir))
+}
+
+// DaemonInstallCommand генерирует unit и скрипт установки в
+// bin/daemon/systemd/ и возвращает команду их запуска. Адрес демона — первый
+// свободный loopback-порт с 19091 (на момент генерации); у существующей
+// установки скрипт сохраняет её daemon.json, и фактический адрес, как и на
+// macOS, лаунчер узнаёт из приглашения при сопряжении.
+func (ac *AppController) DaemonInstallCommand() (string, error) {
+	if ac.FileService.SingboxPath == "" {
+		return "", fmt.Errorf("sing-box path is unknown")
+	}
+	l := ac.systemdLayout()
+	dir := daemonSystemdDir(ac.FileService.ExecDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("daemon install: %w", err)
+	}
+	unitSource := filepath.Join(dir, daemonSystemdUnitName)
+	if err := os.WriteFile(unitSource, []byte(l.unit()), 0o644); err != nil {
+		return "", fmt.Errorf("daemon install: write unit: %w", err)
+	}
+	script := filepath.Join(dir, "install.sh")
+	if err := os.WriteFile(script, []byte(l.installScript(unitSource, freeDaemonListen())), 0o755); err != nil {
+		return "", fmt.Errorf("daemon install: write script: %w", err)
+	}
+	return l.sudo() + "sh " + shellQuote(script), nil
+}
+
+// DaemonUninstallCommand собирает команду удаления службы. purge=true —
+// вместе с state-dir (ключи, доверенные клиенты, last-good).
+func (ac *AppController) DaemonUninstallCommand(purge bool) string {
+	l := ac.systemdLayout()
+	systemctl := l.systemctl()
+	steps := []string{
+		fmt.Sprintf("%s disable --now %s", systemctl, daemonSystemdUnitName),
+		"rm -f " + shellQuote(l.unitPath),
+		systemctl + " daemon-reload",
+	}
+	if !l.user {
+		steps = append(steps, "rm -rf "+shellQuote(filepath.Dir(l.binary)))
+	}
+	if purge {
+		steps = append(steps, "rm -rf "+shellQuote(l.stateDir))
+	}
+	script := strings.Join(steps, "; ")
+	if l.user {
+		return script
+	}
+	return "sudo sh -c " + shellQuote(script)
+}
+
+// freeDaemonListen — первый свободный loopback-порт диапазона; если занято
+// всё — стартовый (install всё равно не затрёт чужой daemon.json).
+func freeDaemonListen() string {
+	for port := daemonListenPortFirst; port < daemonListenPortFirst+daemonListenPortScan; port++ {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			continue
+		}
+		_ = ln.Close()
+		return addr
+	}
+	return daemonDefaultListen
+}
+
+// systemdQuote заключает аргумент ExecStart в двойные кавычки по правилам
+// systemd.syntax(7): экранируются `\` и `"`, а `%` удваивается (спецификаторы).
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "%", "%%")
+	return `"` + s + `"`
+}
@@ -0,0 +1,332 @@
+//go:build linux
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+// Stand-in демона: тестовый бинарь, перезапущенный через обёртку bin/sing-box,
+// отвечает на `lxd --help` и `lxd --state-dir DIR` — ровно те вызовы, что
+// делают CoreSupportsLxd и ExecStart unit'а. Admin REST — минимальный
+// (status/info/apply/stop, Bearer из daemon.json); gRPC-плоскости нет, её
+// supervisor'ы DaemonBackend переживают штатным reconnect-циклом.
+const standInEnv = "LXD_STANDIN"
+
+func TestLxdStandInProcess(t *testing.T) {
+	if os.Getenv(standInEnv) != "1" {
+		t.Skip("helper process for the lxd stand-in")
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	if len(args) == 2 && args[0] == "lxd" && args[1] == "--help" {
+		os.Stdout.WriteString("Usage: sing-box lxd [flags]\n  --state-dir string   daemon home\n")
+		os.Exit(0)
+	}
+	if len(args) != 3 || args[0] != "lxd" || args[1] != "--state-dir" {
+		os.Stderr.WriteString("stand-in: unexpected args " + strings.Join(args, " ") + "\n")
+		os.Exit(2)
+	}
+	os.Exit(runLxdStandIn(args[2]))
+}
+
+func runLxdStandIn(stateDir string) int {
+	data, err := os.ReadFile(filepath.Join(stateDir, "daemon.json"))
+	if err != nil {
+		return 1
+	}
+	var cfg struct {
+		Listen string `json:"listen"`
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return 1
+	}
+	var mu sync.Mutex
+	status := "idle"
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("GET /admin/status", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		reply(w, map[string]any{"status": status})
+	})
+	mux.HandleFunc("GET /admin/info", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"version": "stand-in", "state_dir": stateDir, "listen": cfg.Listen, "pid": os.Getpid()})
+	})
+	mux.HandleFunc("POST /admin/apply", func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			reply(w, map[string]any{"error": "config: " + err.Error()})
+			return
+		}
+		if err := os.WriteFile(filepath.Join(stateDir, "active.json"), body, 0o600); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		status = "started"
+		mu.Unlock()
+		reply(w, map[string]any{"ok": true})
+	})
+	mux.HandleFunc("POST /admin/stop", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status = "idle"
+		mu.Unlock()
+		reply(w, map[string]any{"ok": true})
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+cfg.Secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	srv := &http.Server{Handler: handler}
+	go func() { _ = srv.Serve(ln) }()
+	<-ctx.Done()
+	_ = srv.Close()
+	return 0
+}
+
+// newStandInController — контроллер с ядром-обёрткой, которая запускает
+// stand-in, и user-scope systemd в временных XDG-каталогах.
+func newStandInController(t *testing.T) *AppController {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(root, "state"))
+	t.Setenv(standInEnv, "1")
+
+	execDir := filepath.Join(root, "app")
+	binDir := platform.GetBinDir(execDir)
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wrapper := filepath.Join(binDir, "sing-box")
+	script := "#!/bin/sh\nexec " + shellQuote(os.Args[0]) + " -test.run='^TestLxdStandInProcess$' -- \"$@\"\n"
+	if err := os.WriteFile(wrapper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &AppController{
+		FileService:  &services.FileService{ExecDir: execDir, SingboxPath: wrapper},
+		RunningState: &RunningState{},
+	}
+}
+
+func TestSystemdDaemonAgainstStandIn(t *testing.T) {
+	ac := newStandInController(t)
+	if err := ac.SetDaemonServiceScope(DaemonScopeUser); err != nil {
+		t.Fatal(err)
+	}
+	l := ac.systemdLayout()
+	if !l.user || l.binary != ac.FileService.SingboxPath || !strings.HasPrefix(l.stateDir, os.Getenv("XDG_STATE_HOME")) {
+		t.Fatalf("user layout = %+v", l)
+	}
+	if !ac.CoreSupportsLxd() {
+		t.Fatal("stand-in core is not detected as lxd-capable")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	const secret = "stand-in-secret"
+	if err := os.MkdirAll(l.stateDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	daemonJSON := `{"listen":"` + addr + `","tls":false,"secret":"` + secret + `"}`
+	if err := os.WriteFile(filepath.Join(l.stateDir, "daemon.json"), []byte(daemonJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Что делает systemd: кладём unit и запускаем его ExecStart.
+	if err := os.MkdirAll(filepath.Dir(l.unitPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(l.unitPath, []byte(l.unit()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := l.execArgs()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start stand-in: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		_ = cmd.Wait()
+	})
+
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	st := locale.LoadSettings(binDir)
+	st.DaemonAddress = addr
+	st.DaemonSecret = secret
+	st.DaemonStopVPNOnExit = true
+	if err := locale.SaveSettings(binDir, st); err != nil {
+		t.Fatal(err)
+	}
+
+	var snap DaemonUIStatus
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		snap = ac.DaemonStatusSnapshot()
+		if snap.Reachable || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !snap.Reachable || snap.CoreStatus != "idle" || snap.StateDir != l.stateDir {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if !snap.ServiceInstalled || snap.ServiceScope != DaemonScopeUser || !snap.CoreSupportsLxd {
+		t.Fatalf("service fields = %+v", snap)
+	}
+
+	backend, err := newDaemonBackend(ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ac.setBackend(backend)
+	t.Cleanup(backend.Close)
+	b := backend.(*DaemonBackend)
+
+	config := []byte(`{"experimental":{"cache_file":{"enabled":true},"clash_api":{"external_controller":"127.0.0.1:9090"}}}`)
+	prepared, err := prepareConfigForDaemon(config, snap.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.admin.Apply(prepared); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	active, err := os.ReadFile(filepath.Join(l.stateDir, "active.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(active), "clash_api") || !strings.Contains(string(active), filepath.Join(l.stateDir, "cache.db")) {
+		t.Fatalf("daemon got %s", active)
+	}
+	if info, err := b.admin.Status(); err != nil || info.Status != "started" {
+		t.Fatalf("status after apply = %+v, %v", info, err)
+	}
+
+	// DaemonStopVPNOnExit: выход лаунчера гасит ядро, но не службу.
+	if !b.OnAppExit() {
+		t.Fatal("OnAppExit did not stop the core")
+	}
+	if info, err := b.admin.Status(); err != nil || info.Status != "idle" {
+		t.Fatalf("status after exit = %+v, %v", info, err)
+	}
+}
+
+func TestSystemdCommands(t *testing.T) {
+	ac := newStandInController(t)
+	source := ac.FileService.SingboxPath
+
+	// System scope (по умолчанию): sudo, копия ядра, /etc и /var/lib.
+	install, err := ac.DaemonInstallCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := daemonSystemdDir(ac.FileService.ExecDir)
+	scriptPath := filepath.Join(dir, "install.sh")
+	if install != "sudo sh "+shellQuote(scriptPath) {
+		t.Fatalf("install = %q", install)
+	}
+	script, err := os.ReadFile(scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("sh", "-n", scriptPath).CombinedOutput(); err != nil {
+		t.Fatalf("install script is not valid sh: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"install -D -m 0755 " + shellQuote(source) + " '" + daemonSystemBinary + "'",
+		"if [ ! -f '" + daemonSystemStateDir + "/daemon.json' ]",
+		`"tls":true`,
+		"install -m 0644 " + shellQuote(filepath.Join(dir, daemonSystemdUnitName)) + " '/etc/systemd/system/sing-box-lxd.service'",
+		"systemctl enable sing-box-lxd.service",
+		"'" + daemonSystemBinary + "' lxd client add --state-dir '" + daemonSystemStateDir + "'",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("install script lacks %q:\n%s", want, script)
+		}
+	}
+	unit, err := os.ReadFile(filepath.Join(dir, daemonSystemdUnitName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), `ExecStart="`+daemonSystemBinary+`" "lxd" "--state-dir" "`+daemonSystemStateDir+`"`) ||
+		!strings.Contains(string(unit), "WantedBy=multi-user.target") {
+		t.Fatalf("system unit:\n%s", unit)
+	}
+	if got := ac.DaemonUninstallCommand(true); !strings.HasPrefix(got, "sudo sh -c ") || !strings.Contains(got, "rm -rf '\\''"+daemonSystemStateDir) {
+		t.Errorf("uninstall --purge = %q", got)
+	}
+	if got := ac.DaemonUninstallCommand(false); strings.Contains(got, daemonSystemStateDir) {
+		t.Errorf("uninstall without purge touches state: %q", got)
+	}
+	if got := ac.DaemonKickstartCommand(); !strings.Contains(got, daemonSystemBinary) || !strings.Contains(got, "systemctl restart") {
+		t.Errorf("kickstart = %q", got)
+	}
+
+	// User scope: без sudo, ядро лаунчера как есть, XDG-каталоги.
+	if err := ac.SetDaemonServiceScope(DaemonScopeUser); err != nil {
+		t.Fatal(err)
+	}
+	if install, err = ac.DaemonInstallCommand(); err != nil || strings.HasPrefix(install, "sudo") {
+		t.Fatalf("user install = %q, %v", install, err)
+	}
+	script, _ = os.ReadFile(scriptPath)
+	if strings.Contains(string(script), daemonSystemBinary) || !strings.Contains(string(script), "systemctl --user enable") {
+		t.Errorf("user install script:\n%s", script)
+	}
+	for _, got := range []string{ac.DaemonRepairCommand(), ac.DaemonKickstartCommand(), ac.DaemonUninstallCommand(true), ac.DaemonShowSecretCommand()} {
+		if strings.Contains(got, "sudo") {
+			t.Errorf("user-scope command needs sudo: %q", got)
+		}
+	}
+
+	if err := ac.SetDaemonServiceScope("cluster"); err == nil {
+		t.Error("unknown scope accepted")
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	if got := systemdQuote(`/opt/my "apps"/50%\x`); got != `"/opt/my \"apps\"/50%%\\x"` {
+		t.Errorf("systemdQuote = %s", got)
+	}
+}
@@ -0,0 +1,379 @@
+// Package debugapi — доступ: именованные токены с областями (scopes),
+// unix-сокет рядом с TCP и журнал изменяющих вызовов.
+//
+// Основной токен (settings.json → debug_api_token) по-прежнему может всё.
+// Именованные токены видят только свои области; область запроса выводится
+// из метода и пути (requiredScopes), а не из таблицы endpoints(), чтобы новая
+// группа не могла случайно оказаться доступной всем.
+package debugapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// Scope — область доступа именованного токена.
+type Scope string
+
+const (
+	// ScopeRead — GET всего, кроме трафика и удалённых машин.
+	ScopeRead Scope = "read"
+	// ScopeTraffic — Traffic Profiler и соединения (/traffic/*, /connections,
+	// поток /events с темой traffic), чтение и управление.
+	ScopeTraffic Scope = "traffic"
+	// ScopeActions — /action/* (start/stop/update/ping/rebuild) и версии ядра.
+	ScopeActions Scope = "actions"
+	// ScopeStateWrite — изменения состояния, настроек и политики присмотра.
+	ScopeStateWrite Scope = "state_write"
+	// ScopeRemote — /remote/*, /daemon/*, /grpc/* целиком и тема drift
+	// потока /events.
+	ScopeRemote Scope = "remote"
+)
+
+// scopePrimary — только основной токен. Именованному его не выдать
+// (ParseScopes его не знает): /backup/* несёт настройки с секретами
+// токенов и ключи удалённых машин, а restore переписывает сами токены.
+const scopePrimary Scope = "primary"
+
+// AllScopes — все области в порядке показа.
+var AllScopes = []Scope{ScopeRead, ScopeTraffic, ScopeActions, ScopeStateWrite, ScopeRemote}
+
+// PrimaryTokenName — имя основного токена в журнале и манифесте.
+const PrimaryTokenName = "primary"
+
+// auditLogMaxSize — при превышении журнал уезжает в .1 (одна копия).
+const auditLogMaxSize = 1 << 20
+
+var tokenNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,31}$`)
+
+// Token — именованный токен с областями.
+type Token struct {
+	Name   string
+	Secret string
+	Scopes []Scope
+}
+
+// ParseScopes проверяет имена областей; повторы схлопываются.
+func ParseScopes(names []string) ([]Scope, error) {
+	seen := map[Scope]bool{}
+	var out []Scope
+	for _, n := range names {
+		sc := Scope(strings.ToLower(strings.TrimSpace(n)))
+		known := false
+		for _, k := range AllScopes {
+			known = known || k == sc
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown scope %q", n)
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			out = append(out, sc)
+		}
+	}
+	return out, nil
+}
+
+// ValidTokenName — имя токена для журнала: буквы, цифры, . _ -, до 32.
+func ValidTokenName(name string) bool {
+	return tokenNameRe.MatchString(name) && name != PrimaryTokenName
+}
+
+// Options — параметры NewWithOptions.
+type Options struct {
+	// Port — TCP-порт на 127.0.0.1; 0 = DefaultPort.
+	Port int
+	// NoTCP — не слушать TCP вовсе (только SocketPath).
+	NoTCP bool
+	// SocketPath — unix-сокет (права 0600 в каталоге 0700); пусто — нет.
+	SocketPath string
+	// Token — основной токен, все области.
+	Token string
+	// Tokens — именованные токены.
+	Tokens []Token
+	// AuditPath — JSONL-журнал изменяющих вызовов; пусто — только debuglog.
+	AuditPath string
+}
+
+// caller — чей токен пришёл в запросе.
+type caller struct {
+	name   string
+	scopes map[Scope]bool
+	all    bool
+}
+
+func (c *caller) has(sc Scope) bool { return c.all || c.scopes[sc] }
+
+func (c *caller) scopeList() []Scope {
+	if c.all {
+		return AllScopes
+	}
+	out := []Scope{}
+	for _, sc := range AllScopes {
+		if c.scopes[sc] {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+type callerCtxKey struct{}
+
+// CallerName — имя токена, с которым пришёл запрос ("primary" для
+// основного); пусто вне Debug API.
+func CallerName(ctx context.Context) string {
+	if c, ok := ctx.Value(callerCtxKey{}).(*caller); ok {
+		return c.name
+	}
+	return ""
+}
+
+// ActorFacade — необязательное расширение фасада: копия, изменения через
+// которую журнал действий (core/audit) запишет на данного актора.
+type ActorFacade interface {
+	WithActor(actor audit.Actor) ControllerFacade
+}
+
+// facadeFor — фасад для изменяющего вызова: с актором "api:<токен>", если
+// фасад это умеет.
+func (s *Server) facadeFor(r *http.Request) ControllerFacade {
+	if af, ok := s.facade.(ActorFacade); ok {
+		if c := callerFrom(r); c != nil {
+			return af.WithActor(audit.APIActor(c.name))
+		}
+	}
+	return s.facade
+}
+
+// actorOf — актор журнала действий для запроса.
+func actorOf(r *http.Request) audit.Actor {
+	return audit.ActorFrom(r.Context(), audit.ActorAPI)
+}
+
+func callerFrom(r *http.Request) *caller {
+	c, _ := r.Context().Value(callerCtxKey{}).(*caller)
+	return c
+}
+
+// buildCallers — основной токен и именованные; одинаковые секреты и имена
+// — ошибка конфигурации, а не «победил первый».
+func buildCallers(primary string, tokens []Token) (map[string]*caller, error) {
+	out := map[string]*caller{primary: {name: PrimaryTokenName, all: true}}
+	names := map[string]bool{}
+	for _, t := range tokens {
+		if !ValidTokenName(t.Name) {
+			return nil, fmt.Errorf("debugapi: invalid token name %q", t.Name)
+		}
+		if strings.TrimSpace(t.Secret) == "" {
+			return nil, fmt.Errorf("debugapi: token %q has no secret", t.Name)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("debugapi: duplicate token name %q", t.Name)
+		}
+		if _, dup := out[t.Secret]; dup {
+			return nil, fmt.Errorf("debugapi: token %q reuses another token's secret", t.Name)
+		}
+		names[t.Name] = true
+		c := &caller{name: t.Name, scopes: map[Scope]bool{}}
+		for _, sc := range t.Scopes {
+			c.scopes[sc] = true
+		}
+		out[t.Secret] = c
+	}
+	return out, nil
+}
+
+// lookupCaller сравнивает с каждым токеном за постоянное время и не
+// выходит на первом совпадении: время ответа не зависит от того, какой
+// токен подошёл.
+func (s *Server) lookupCaller(got string) *caller {
+	var found *caller
+	for secret, c := range s.callers {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1 {
+			found = c
+		}
+	}
+	return found
+}
+
+// requiredScope — область, которой должен обладать токен для запроса.
+// Пусто — хватает любого действительного токена (манифест, /help).
+func requiredScope(r *http.Request) Scope {
+	p := r.URL.Path
+	switch {
+	case p == "/" || p == "/help":
+		return ""
+	case pathIn(p, "/backup"):
+		return scopePrimary
+	case pathIn(p, "/remote", "/daemon", "/grpc"):
+		return ScopeRemote
+	case pathIn(p, "/traffic", "/connections"):
+		return ScopeTraffic
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return ScopeRead
+	case pathIn(p, "/action", "/core/versions"):
+		return ScopeActions
+	default:
+		return ScopeStateWrite
+	}
+}
+
+// pathIn — p равен одному из префиксов или лежит под ним.
+func pathIn(p string, prefixes ...string) bool {
+	for _, pre := range prefixes {
+		if p == pre || strings.HasPrefix(p, pre+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredScopes — все области запроса: одна из requiredScope, а поток
+// /events собирает их по темам (eventsScopes).
+func requiredScopes(r *http.Request) []Scope {
+	if r.URL.Path == "/events" {
+		return eventsScopes(r)
+	}
+	if sc := requiredScope(r); sc != "" {
+		return []Scope{sc}
+	}
+	return nil
+}
+
+// eventsScopes — области потока /events по темам ?topics=: traffic —
+// ScopeTraffic, drift (данные удалённых машин) — ScopeRemote, остальные —
+// ScopeRead. Потоку без ?topics= хватает ScopeRead: traffic в нём нет, а
+// drift parseEventFilter оставляет только токенам с ScopeRemote.
+func eventsScopes(r *http.Request) []Scope {
+	raw := strings.TrimSpace(r.URL.Query().Get("topics"))
+	if raw == "" {
+		return []Scope{ScopeRead}
+	}
+	var out []Scope
+	for _, t := range strings.Split(raw, ",") {
+		var sc Scope
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "":
+			continue
+		case topicTraffic:
+			sc = ScopeTraffic
+		case topicDrift:
+			sc = ScopeRemote
+		default:
+			sc = ScopeRead
+		}
+		if !slices.Contains(out, sc) {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+func isMutating(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+// statusRecorder запоминает код ответа для журнала.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// AuditEntry — строка журнала изменяющих вызовов.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Token  string    `json:"token"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Status int       `json:"status"`
+	// Via — "tcp" или "unix".
+	Via string `json:"via"`
+}
+
+// audit пишет изменяющий вызов в debuglog и журнал. Отказы авторизации
+// тоже пишутся: попытка токена выйти за свои области видна потом.
+func (s *Server) audit(e AuditEntry) {
+	debuglog.InfoLog("debugapi: %s %s by %q via %s → %d", e.Method, e.Path, e.Token, e.Via, e.Status)
+	if s.auditPath == "" {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	if err := appendAuditLog(s.auditPath, line); err != nil {
+		debuglog.WarnLog("debugapi: write %s: %v", s.auditPath, err)
+	}
+}
+
+func appendAuditLog(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Size()+int64(len(line)) > auditLogMaxSize {
+		_ = os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, platform.DefaultFileMode)
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(append(line, '\n'))
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	return werr
+}
+
+// via — через какой слушатель пришёл запрос.
+func via(r *http.Request) string {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(interface{ Network() string }); ok && addr.Network() == "unix" {
+		return "unix"
+	}
+	return "tcp"
+}
+
+// scopeNames — области строками, для манифеста.
+func scopeNames(scopes []Scope) []string {
+	out := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		out = append(out, string(sc))
+	}
+	return out
+}
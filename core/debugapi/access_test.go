@@ -0,0 +1,242 @@
+package debugapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func tokenDo(t *testing.T, client *http.Client, method, url, token string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func readAudit(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e AuditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Именованный токен ходит только в свои области; изменяющие вызовы и
+// отказы попадают в журнал под его именем.
+func TestScopedTokens(t *testing.T) {
+	port := freeLocalPort(t)
+	auditPath := filepath.Join(t.TempDir(), "logs", "debugapi_audit.jsonl")
+	s, err := NewWithOptions(&fakeFacade{}, Options{
+		Port:  port,
+		Token: "primary-token",
+		Tokens: []Token{
+			{Name: "grafana", Secret: "read-token", Scopes: []Scope{ScopeRead}},
+			{Name: "cron", Secret: "actions-token", Scopes: []Scope{ScopeActions}},
+		},
+		AuditPath: auditPath,
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+	base := "http://127.0.0.1:" + itoa(port)
+	c := http.DefaultClient
+
+	for _, tc := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/state", "read-token", 200},
+		{"GET", "/traffic/status", "read-token", 403},
+		{"POST", "/action/start", "read-token", 403},
+		{"POST", "/action/start", "actions-token", 200},
+		{"GET", "/state", "actions-token", 403},
+		{"PATCH", "/state/vars", "actions-token", 403},
+		{"POST", "/action/stop", "primary-token", 200},
+		{"GET", "/state", "nobody", 401},
+	} {
+		if got, body := tokenDo(t, c, tc.method, base+tc.path, tc.token); got != tc.want {
+			t.Errorf("%s %s with %s: %d %s, want %d", tc.method, tc.path, tc.token, got, body, tc.want)
+		}
+	}
+
+	// Поток /events не кончается: проверяется только, что отказа нет.
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", base+"/events?topics=state", nil)
+	req.Header.Set("Authorization", "Bearer read-token")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		t.Error("/events?topics=state: forbidden for a read token")
+	}
+	cancel()
+	_ = resp.Body.Close()
+
+	_, body := tokenDo(t, c, "GET", base+"/", "read-token")
+	var m struct {
+		Token struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		} `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(body), &m); err != nil || m.Token.Name != "grafana" || len(m.Token.Scopes) != 1 {
+		t.Errorf("manifest token = %+v (%v)", m.Token, err)
+	}
+
+	got := map[string]int{}
+	for _, e := range readAudit(t, auditPath) {
+		got[e.Token+" "+e.Method+" "+e.Path] = e.Status
+		if e.Via != "tcp" {
+			t.Errorf("via = %q", e.Via)
+		}
+	}
+	want := map[string]int{
+		"grafana POST /action/start":  403,
+		"grafana GET /traffic/status": 403,
+		"cron POST /action/start":     200,
+		"cron GET /state":             403,
+		"cron PATCH /state/vars":      403,
+		"primary POST /action/stop":   200,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("audit %q = %d, want %d (all: %v)", k, got[k], v, got)
+		}
+	}
+	if _, ok := got["grafana GET /state"]; ok {
+		t.Error("reads must not be audited")
+	}
+}
+
+func TestRequiredScopes(t *testing.T) {
+	cases := []struct {
+		method, target string
+		want           []Scope
+	}{
+		{"GET", "/", nil},
+		{"GET", "/help", nil},
+		{"GET", "/state/full", []Scope{ScopeRead}},
+		{"PATCH", "/state/rules", []Scope{ScopeStateWrite}},
+		{"PATCH", "/settings/user-agent", []Scope{ScopeStateWrite}},
+		{"PUT", "/supervision/policy", []Scope{ScopeStateWrite}},
+		{"POST", "/backup/create", []Scope{scopePrimary}},
+		{"POST", "/backup/restore", []Scope{scopePrimary}},
+		{"POST", "/action/update-subs", []Scope{ScopeActions}},
+		{"POST", "/core/versions/1.13.0/activate", []Scope{ScopeActions}},
+		{"GET", "/core/versions", []Scope{ScopeRead}},
+		{"GET", "/traffic/live", []Scope{ScopeTraffic}},
+		{"DELETE", "/connections/abc", []Scope{ScopeTraffic}},
+		{"GET", "/events", []Scope{ScopeRead}},
+		{"GET", "/events?topics=traffic", []Scope{ScopeTraffic}},
+		{"GET", "/events?topics=vpn,traffic", []Scope{ScopeRead, ScopeTraffic}},
+		{"GET", "/events?topics=drift", []Scope{ScopeRemote}},
+		{"GET", "/events?topics=traffic,drift,vpn", []Scope{ScopeTraffic, ScopeRemote, ScopeRead}},
+		{"GET", "/events?topics=vpn,state", []Scope{ScopeRead}},
+		{"GET", "/remote/machines", []Scope{ScopeRemote}},
+		{"POST", "/daemon/pair", []Scope{ScopeRemote}},
+		{"GET", "/grpc/methods", []Scope{ScopeRemote}},
+		{"GET", "/remotely", []Scope{ScopeRead}},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest(c.method, "http://x"+c.target, nil)
+		if got := requiredScopes(r); !slices.Equal(got, c.want) {
+			t.Errorf("%s %s = %v, want %v", c.method, c.target, got, c.want)
+		}
+	}
+}
+
+func TestBuildCallersRejectsClashes(t *testing.T) {
+	for _, tokens := range [][]Token{
+		{{Name: "a", Secret: "x"}, {Name: "a", Secret: "y"}},
+		{{Name: "a", Secret: "primary"}},
+		{{Name: "primary", Secret: "y"}},
+		{{Name: "bad name", Secret: "y"}},
+		{{Name: "a", Secret: " "}},
+	} {
+		if _, err := buildCallers("primary", tokens); err == nil {
+			t.Errorf("buildCallers(%+v) accepted", tokens)
+		}
+	}
+	if _, err := ParseScopes([]string{"read", "admin"}); err == nil {
+		t.Error("unknown scope accepted")
+	}
+}
+
+// Только сокет: права 0600, TCP нет, запросы идут и пишутся как "unix".
+func TestSocketOnly(t *testing.T) {
+	if !SocketSupported {
+		t.Skip("no unix socket on this platform")
+	}
+	// Короткий путь: sun_path ограничен ~104 байтами, а t.TempDir на macOS
+	// длинный.
+	dir, err := os.MkdirTemp("", "dapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "run", "debug-api.sock")
+	auditPath := filepath.Join(dir, "audit.jsonl")
+	s, err := NewWithOptions(&fakeFacade{}, Options{NoTCP: true, SocketPath: sock, Token: "tok", AuditPath: auditPath})
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	s.Start()
+	if s.Addr() != "" || s.SocketPath() != sock {
+		t.Errorf("Addr = %q, SocketPath = %q", s.Addr(), s.SocketPath())
+	}
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v", fi.Mode().Perm())
+	}
+	c := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	if code, body := tokenDo(t, c, "POST", "http://unix/action/start", "tok"); code != 200 {
+		t.Fatalf("over socket: %d %s", code, body)
+	}
+	if e := readAudit(t, auditPath); len(e) != 1 || e[0].Via != "unix" || e[0].Token != PrimaryTokenName {
+		t.Errorf("audit = %+v", e)
+	}
+
+	// Второй сервер на живом сокете — ошибка, а не перехват.
+	if _, err := NewWithOptions(&fakeFacade{}, Options{NoTCP: true, SocketPath: sock, Token: "tok"}); err == nil {
+		t.Error("second listener on a live socket")
+	}
+	s.Stop()
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket left after Stop: %v", err)
+	}
+}
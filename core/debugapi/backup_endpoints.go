@@ -0,0 +1,146 @@
+// Package debugapi — резервная копия профиля лаунчера (core/backup):
+// создание, описание и выборочное восстановление для скриптов.
+//
+// Группа включается wiring'ом через EnableBackup; фасад — сам контроллер.
+// Копия — JSON-конверт, поэтому ходит в теле запроса и ответа как есть:
+// ответ /backup/create можно сохранить в файл и отдать в /backup/restore.
+package debugapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/backup"
+	"singbox-launcher/internal/secretstore"
+)
+
+// backupBodyLimit — свой лимит тела (не decodeJSONBody с его 1 MiB): в
+// копии тела подписок и .srs, это мегабайты.
+const backupBodyLimit = 256 << 20
+
+// BackupFacade — что группе нужно от контроллера.
+type BackupFacade interface {
+	CreateBackup(passphrase string, categories []backup.Category) ([]byte, backup.Manifest, error)
+	InspectBackup(raw []byte, passphrase string) (backup.Manifest, error)
+	// RestoreBackup — actor попадает в журнал действий.
+	RestoreBackup(actor audit.Actor, raw []byte, passphrase string, categories []backup.Category) (backup.Report, error)
+}
+
+// EnableBackup turns the /backup endpoint group on. Call before Start.
+func (s *Server) EnableBackup(f BackupFacade) { s.backup = f }
+
+// backupEndpoints — таблица группы /backup.
+func (s *Server) backupEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{"POST", "/backup/create", true, "Encrypted backup of the launcher profile (categories: settings, states, warp, subscriptions, remote, rule_sets; empty = all)", s.handleBackupCreate},
+		{"POST", "/backup/inspect", true, "Describe a backup without restoring it", s.handleBackupInspect},
+		{"POST", "/backup/restore", true, "Restore chosen categories of a backup (empty = everything in it)", s.handleBackupRestore},
+	}
+}
+
+// backupRequest — общее тело группы.
+type backupRequest struct {
+	Archive    json.RawMessage `json:"archive"`
+	Passphrase string          `json:"passphrase"`
+	Categories []string        `json:"categories"`
+}
+
+func decodeBackupRequest(w http.ResponseWriter, r *http.Request) (backupRequest, []backup.Category, bool) {
+	var req backupRequest
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return req, nil, false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, backupBodyLimit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return req, nil, false
+	}
+	var cats []backup.Category
+	if len(req.Categories) > 0 {
+		var err error
+		if cats, err = backup.ParseCategories(req.Categories); err != nil {
+			writeFieldError(w, fieldErr("categories", "%v", err))
+			return req, nil, false
+		}
+	}
+	return req, cats, true
+}
+
+// handleBackupCreate — POST {passphrase, categories?}. Ответ — сам файл
+// копии.
+func (s *Server) handleBackupCreate(w http.ResponseWriter, r *http.Request) {
+	req, cats, ok := decodeBackupRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Archive) > 0 {
+		writeFieldError(w, fieldErr("archive", "is not accepted here"))
+		return
+	}
+	if len(req.Passphrase) < backup.MinPassphrase {
+		writeFieldError(w, fieldErr("passphrase", "must be at least %d characters", backup.MinPassphrase))
+		return
+	}
+	raw, _, err := s.backup.CreateBackup(req.Passphrase, cats)
+	if err != nil {
+		writeBackupError(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, json.RawMessage(raw))
+}
+
+// handleBackupInspect — POST {archive, passphrase}: что внутри копии.
+func (s *Server) handleBackupInspect(w http.ResponseWriter, r *http.Request) {
+	req, _, ok := decodeBackupRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Archive) == 0 {
+		writeFieldError(w, fieldErr("archive", "is required"))
+		return
+	}
+	m, err := s.backup.InspectBackup(req.Archive, req.Passphrase)
+	if err != nil {
+		writeBackupError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handleBackupRestore — POST {archive, passphrase, categories?}. Сбой на
+// одном файле — строка errors отчёта, а не ошибка запроса.
+func (s *Server) handleBackupRestore(w http.ResponseWriter, r *http.Request) {
+	req, cats, ok := decodeBackupRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Archive) == 0 {
+		writeFieldError(w, fieldErr("archive", "is required"))
+		return
+	}
+	report, err := s.backup.RestoreBackup(actorOf(r), req.Archive, req.Passphrase, cats)
+	if err != nil {
+		writeBackupError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// writeBackupError — пароль и чужой файл — ошибки полей (422), секреты
+// установки заблокированы — 409, остальное — fallback.
+func writeBackupError(w http.ResponseWriter, err error, fallback int) {
+	switch {
+	case errors.Is(err, backup.ErrPassphrase):
+		writeFieldError(w, fieldErr("passphrase", "%v", err))
+	case errors.Is(err, backup.ErrNotBackup):
+		writeFieldError(w, fieldErr("archive", "%v", err))
+	case errors.Is(err, secretstore.ErrLocked):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+	default:
+		writeJSON(w, fallback, map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,126 @@
+package debugapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/backup"
+	"singbox-launcher/internal/platform"
+	"strings"
+)
+
+// dirBackup — BackupFacade над core/backup в каталоге теста.
+type dirBackup struct{ execDir string }
+
+func (d dirBackup) CreateBackup(passphrase string, cats []backup.Category) ([]byte, backup.Manifest, error) {
+	return backup.Create(d.execDir, "test", passphrase, cats)
+}
+func (d dirBackup) InspectBackup(raw []byte, passphrase string) (backup.Manifest, error) {
+	return backup.Inspect(raw, passphrase)
+}
+func (d dirBackup) RestoreBackup(_ audit.Actor, raw []byte, passphrase string, cats []backup.Category) (backup.Report, error) {
+	return backup.Restore(d.execDir, raw, passphrase, cats)
+}
+
+// Ответ /backup/create — файл, который /backup/inspect и /backup/restore
+// принимают как есть.
+func TestBackupGroupContract(t *testing.T) {
+	execDir := t.TempDir()
+	rawPath := filepath.Join(platform.GetSubscriptionsDir(execDir), "a.raw")
+	if err := os.MkdirAll(filepath.Dir(rawPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rawPath, []byte("body"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	port := freeLocalPort(t)
+	s, err := New(&fakeFacade{}, port, "remote-test-token")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.EnableBackup(dirBackup{execDir})
+	s.Start()
+	t.Cleanup(s.Stop)
+	base := "http://127.0.0.1:" + itoa(port)
+
+	for _, c := range []struct {
+		path string
+		body any
+		want int
+	}{
+		{"/backup/create", map[string]any{"passphrase": "short"}, http.StatusUnprocessableEntity},
+		{"/backup/create", map[string]any{"passphrase": "correct horse", "categories": []string{"logs"}}, http.StatusUnprocessableEntity},
+		{"/backup/inspect", map[string]any{"passphrase": "correct horse"}, http.StatusUnprocessableEntity},
+		{"/backup/restore", map[string]any{"archive": map[string]any{"format": "x"}, "passphrase": "correct horse"}, http.StatusUnprocessableEntity},
+	} {
+		if resp, body := authDo(t, http.MethodPost, base+c.path, c.body); resp.StatusCode != c.want {
+			t.Errorf("%s: %d %s, want %d", c.path, resp.StatusCode, body, c.want)
+		}
+	}
+
+	resp, archive := authDo(t, http.MethodPost, base+"/backup/create",
+		map[string]any{"passphrase": "correct horse", "categories": []string{"subscriptions"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create: %d %s", resp.StatusCode, archive)
+	}
+	if resp, body := authDo(t, http.MethodPost, base+"/backup/inspect",
+		map[string]any{"archive": json.RawMessage(archive), "passphrase": "wrong horse"}); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("wrong passphrase: %d %s", resp.StatusCode, body)
+	}
+	_, body := authDo(t, http.MethodPost, base+"/backup/inspect",
+		map[string]any{"archive": json.RawMessage(archive), "passphrase": "correct horse"})
+	var m backup.Manifest
+	if err := json.Unmarshal(body, &m); err != nil || len(m.Categories) != 1 || m.Categories[0].Files != 1 {
+		t.Fatalf("inspect = %s (%v)", body, err)
+	}
+
+	if err := os.Remove(rawPath); err != nil {
+		t.Fatal(err)
+	}
+	resp, body = authDo(t, http.MethodPost, base+"/backup/restore",
+		map[string]any{"archive": json.RawMessage(archive), "passphrase": "correct horse"})
+	var report backup.Report
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &report) != nil || !report.Restored(backup.CategorySubscriptions) {
+		t.Fatalf("restore: %d %s", resp.StatusCode, body)
+	}
+	if got, _ := os.ReadFile(rawPath); string(got) != "body" {
+		t.Errorf("restored body = %q", got)
+	}
+}
+
+// Копия настроек несёт секреты всех токенов: именованному токену, даже с
+// state_write, /backup/* закрыт.
+func TestBackupPrimaryTokenOnly(t *testing.T) {
+	port := freeLocalPort(t)
+	s, err := NewWithOptions(&fakeFacade{}, Options{
+		Port:   port,
+		Token:  "primary-token",
+		Tokens: []Token{{Name: "editor", Secret: "write-token", Scopes: []Scope{ScopeStateWrite}}},
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	s.EnableBackup(dirBackup{t.TempDir()})
+	s.Start()
+	t.Cleanup(s.Stop)
+	base := "http://127.0.0.1:" + itoa(port)
+
+	body := `{"passphrase":"correct horse","categories":["settings"]}`
+	for token, want := range map[string]int{"write-token": http.StatusForbidden, "primary-token": http.StatusOK} {
+		req, _ := http.NewRequest(http.MethodPost, base+"/backup/create", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: %d, want %d", token, resp.StatusCode, want)
+		}
+	}
+}
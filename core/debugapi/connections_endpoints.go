@@ -0,0 +1,62 @@
+package debugapi
+
+import (
+	"net/http"
+	"strings"
+
+	"singbox-launcher/api"
+)
+
+// handleConnections — GET: open connections of the local core; DELETE: close
+// all of them, or only those matching ?process=&outbound=&host= (ANDed, see
+// api.ConnectionFilter). The response carries how many were closed; -1 means
+// the engine closed everything without reporting a count (daemon gRPC).
+func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		conns, err := s.facade.ListConnections()
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+			return
+		}
+		if conns == nil {
+			conns = []api.ConnectionInfo{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"connections": conns})
+
+	case http.MethodDelete:
+		q := r.URL.Query()
+		f := api.ConnectionFilter{
+			Process:  strings.TrimSpace(q.Get("process")),
+			Outbound: strings.TrimSpace(q.Get("outbound")),
+			Host:     strings.TrimSpace(q.Get("host")),
+		}
+		n, err := s.facade.CloseConnections(f)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "closed": n, "filter": f})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or DELETE required"})
+	}
+}
+
+// handleConnectionByID — DELETE: close one connection of the local core.
+func (s *Server) handleConnectionByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "DELETE required"})
+		return
+	}
+	id := strings.TrimSpace(pathParam(r, "conn_id"))
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "conn_id is empty"})
+		return
+	}
+	if err := s.facade.CloseConnection(id); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
@@ -0,0 +1,70 @@
+package debugapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"singbox-launcher/api"
+)
+
+func TestConnectionsEndpoints(t *testing.T) {
+	port := freeLocalPort(t)
+	ff := &fakeFacade{conns: []api.ConnectionInfo{
+		{ID: "a", Chains: []string{"JP-01", "proxy-out"}, Metadata: api.ConnectionMetadata{Process: "chrome"}},
+		{ID: "b", Chains: []string{"direct-out"}, Metadata: api.ConnectionMetadata{Process: "chrome"}},
+	}}
+	s, err := New(ff, port, "tok")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+	base := "http://127.0.0.1:" + itoa(port)
+
+	do := func(method, path string) (int, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(method, base+path, nil)
+		req.Header.Set("Authorization", "Bearer tok")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	if code, body := do("GET", "/connections"); code != 200 || len(body["connections"].([]any)) != 2 {
+		t.Fatalf("GET /connections: %d %v", code, body)
+	}
+
+	code, body := do("DELETE", "/connections?outbound=proxy-out&process=chrome")
+	if code != 200 || body["closed"] != float64(1) {
+		t.Fatalf("DELETE filtered: %d %v", code, body)
+	}
+	if ff.closedFilter == nil || ff.closedFilter.Outbound != "proxy-out" || ff.closedFilter.Process != "chrome" {
+		t.Errorf("filter not passed through: %+v", ff.closedFilter)
+	}
+
+	if code, body := do("DELETE", "/connections"); code != 200 || body["closed"] != float64(2) {
+		t.Fatalf("DELETE all: %d %v", code, body)
+	}
+	if !ff.closedFilter.IsEmpty() {
+		t.Errorf("close-all must pass an empty filter, got %+v", ff.closedFilter)
+	}
+
+	if code, _ := do("DELETE", "/connections/a"); code != 200 || len(ff.closedIDs) != 1 || ff.closedIDs[0] != "a" {
+		t.Fatalf("DELETE by id: %d %v", code, ff.closedIDs)
+	}
+	if code, _ := do("GET", "/connections/a"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET by id: %d, want 405", code)
+	}
+
+	ff.connsErr = errors.New("core stopped")
+	if code, _ := do("DELETE", "/connections/a"); code != http.StatusBadGateway {
+		t.Errorf("close error: %d, want 502", code)
+	}
+}
@@ -0,0 +1,168 @@
+// Package debugapi — версии ядра рядом друг с другом (core/core_versions.go).
+//
+// Группа включается wiring'ом через EnableCoreVersions: фасад реализует core
+// (обратный импорт замкнул бы цикл), поэтому типы здесь свои — зеркала
+// core.CoreVersionInfo / core.CoreFallbackInfo. capabilities.core_versions в
+// манифесте говорит агенту, есть ли группа в этой сессии.
+package debugapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Ошибки фасада версий ядра. Адаптер в core переводит в них свои ошибки
+// (errors.Is), обработчики — в HTTP-статусы.
+var (
+	// ErrCoreVersionInvalid — строка версии не похожа на тег релиза (422).
+	ErrCoreVersionInvalid = errors.New("invalid core version")
+	// ErrCoreVersionNotInstalled — версии нет в хранилище (404).
+	ErrCoreVersionNotInstalled = errors.New("core version is not installed")
+	// ErrCoreVersionActive — активную версию удалить нельзя (409).
+	ErrCoreVersionActive = errors.New("core version is active")
+)
+
+// CoreVersionView — одна установленная версия ядра.
+type CoreVersionView struct {
+	Version     string   `json:"version"`
+	Active      bool     `json:"active"`
+	Previous    bool     `json:"previous"`
+	Pinned      bool     `json:"pinned"`
+	Trial       bool     `json:"trial"`
+	VerifiedBy  string   `json:"verified_by,omitempty"`
+	InstalledAt string   `json:"installed_at,omitempty"`
+	Tags        []string `json:"tags"`
+	// NaiveSupported / NaiveReason — поддержка naive в ЭТОЙ версии (у сборок
+	// без with_naive_outbound или без libcronet рядом её нет).
+	NaiveSupported bool   `json:"naive_supported"`
+	NaiveReason    string `json:"naive_reason,omitempty"`
+}
+
+// CoreFallbackView — последний автоматический откат на предыдущее ядро.
+type CoreFallbackView struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+	At     string `json:"at,omitempty"`
+}
+
+// CoreVersionsFacade — что группе нужно от хранилища версий ядра.
+type CoreVersionsFacade interface {
+	List() ([]CoreVersionView, error)
+	// StoreInUse — false, если ядро запускается не из bin/ (например, из
+	// PATH): тогда переключение версий ни на что не влияет.
+	StoreInUse() bool
+	// LastFallback — nil, если откатов не было.
+	LastFallback() *CoreFallbackView
+	// Install скачивает версию в хранилище, не активируя её. Синхронно:
+	// ответ приходит после проверки дайджеста и распаковки.
+	Install(ctx context.Context, version string) error
+	// Activate делает версию активной (с пробным периодом и откатом) и
+	// перезапускает ядро, если оно работает.
+	Activate(version string) error
+	Remove(version string) error
+}
+
+// EnableCoreVersions turns the /core/versions endpoint group on. Call before Start.
+func (s *Server) EnableCoreVersions(f CoreVersionsFacade) { s.coreVersions = f }
+
+// coreVersionsEndpoints — таблица группы /core/versions.
+func (s *Server) coreVersionsEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{"GET/POST", "/core/versions", true, "Installed core versions, active/previous, last fallback / install a version", s.handleCoreVersions},
+		{"POST", "/core/versions/{version}/activate", true, "Switch to an installed core version (trial with automatic fallback)", s.handleCoreVersionActivate},
+		{"DELETE", "/core/versions/{version}", true, "Remove an installed core version (not the active one)", s.handleCoreVersionByName},
+	}
+}
+
+func (s *Server) handleCoreVersions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.coreVersions.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		if list == nil {
+			list = []CoreVersionView{}
+		}
+		resp := map[string]any{
+			"versions":      list,
+			"store_in_use":  s.coreVersions.StoreInUse(),
+			"last_fallback": s.coreVersions.LastFallback(),
+		}
+		for _, v := range list {
+			if v.Active {
+				resp["active"] = v.Version
+				resp["trial"] = v.Trial
+			}
+			if v.Previous {
+				resp["previous"] = v.Version
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost:
+		var req struct {
+			Version string `json:"version"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		version := strings.TrimSpace(req.Version)
+		if version == "" {
+			writeFieldError(w, fieldErr("version", "version is required"))
+			return
+		}
+		if err := s.coreVersions.Install(r.Context(), version); err != nil {
+			writeCoreVersionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{"installed": version})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or POST required"})
+	}
+}
+
+func (s *Server) handleCoreVersionActivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	version := pathParam(r, "version")
+	if err := s.coreVersions.Activate(version); err != nil {
+		writeCoreVersionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"active": version})
+}
+
+func (s *Server) handleCoreVersionByName(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "DELETE required"})
+		return
+	}
+	version := pathParam(r, "version")
+	if err := s.coreVersions.Remove(version); err != nil {
+		writeCoreVersionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"removed": version})
+}
+
+// writeCoreVersionError — ошибка фасада → статус. Всё, что не распознано
+// (сеть, дайджест, запуск ядра), — 502: причина вне самого запроса.
+func writeCoreVersionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrCoreVersionInvalid):
+		writeFieldError(w, fieldErr("version", "%s", err.Error()))
+	case errors.Is(err, ErrCoreVersionNotInstalled):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+	case errors.Is(err, ErrCoreVersionActive):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,115 @@
+package debugapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// fakeCoreVersions — CoreVersionsFacade над списком в памяти.
+type fakeCoreVersions struct {
+	versions []CoreVersionView
+}
+
+func (f *fakeCoreVersions) List() ([]CoreVersionView, error) { return f.versions, nil }
+func (f *fakeCoreVersions) StoreInUse() bool                 { return true }
+func (f *fakeCoreVersions) LastFallback() *CoreFallbackView {
+	return &CoreFallbackView{From: "1.14.0-lx.2", To: "1.13.0-lx.1", Reason: "sing-box check: FATAL"}
+}
+func (f *fakeCoreVersions) find(v string) int {
+	for i, x := range f.versions {
+		if x.Version == v {
+			return i
+		}
+	}
+	return -1
+}
+func (f *fakeCoreVersions) Install(_ context.Context, v string) error {
+	if v == "bad/tag" {
+		return fmt.Errorf("%w: %q", ErrCoreVersionInvalid, v)
+	}
+	f.versions = append(f.versions, CoreVersionView{Version: v, Tags: []string{}})
+	return nil
+}
+func (f *fakeCoreVersions) Activate(v string) error {
+	i := f.find(v)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrCoreVersionNotInstalled, v)
+	}
+	for j := range f.versions {
+		f.versions[j].Previous = f.versions[j].Active
+		f.versions[j].Active = j == i
+	}
+	f.versions[i].Trial = true
+	return nil
+}
+func (f *fakeCoreVersions) Remove(v string) error {
+	i := f.find(v)
+	switch {
+	case i < 0:
+		return fmt.Errorf("%w: %s", ErrCoreVersionNotInstalled, v)
+	case f.versions[i].Active:
+		return fmt.Errorf("%w: %s", ErrCoreVersionActive, v)
+	}
+	f.versions = append(f.versions[:i], f.versions[i+1:]...)
+	return nil
+}
+
+func TestCoreVersionsGroupContract(t *testing.T) {
+	fv := &fakeCoreVersions{versions: []CoreVersionView{{Version: "1.13.0-lx.1", Active: true, Tags: []string{}}}}
+	port := freeLocalPort(t)
+	s, err := New(&fakeFacade{}, port, "remote-test-token")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.EnableCoreVersions(fv)
+	s.Start()
+	t.Cleanup(s.Stop)
+	base := "http://127.0.0.1:" + itoa(port)
+
+	_, body := authDo(t, http.MethodGet, base+"/", nil)
+	var manifest struct {
+		Capabilities map[string]bool `json:"capabilities"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil || !manifest.Capabilities["core_versions"] {
+		t.Fatalf("capabilities = %v, %v", manifest.Capabilities, err)
+	}
+
+	for _, c := range []struct {
+		method, path string
+		body         any
+		want         int
+	}{
+		{http.MethodPost, "/core/versions", map[string]string{"version": "1.14.0-lx.2"}, http.StatusCreated},
+		{http.MethodPost, "/core/versions", map[string]string{"version": "bad/tag"}, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/core/versions", map[string]string{}, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/core/versions/9.9.9/activate", nil, http.StatusNotFound},
+		{http.MethodPost, "/core/versions/1.14.0-lx.2/activate", nil, http.StatusOK},
+		{http.MethodDelete, "/core/versions/1.14.0-lx.2", nil, http.StatusConflict},
+		{http.MethodDelete, "/core/versions/1.13.0-lx.1", nil, http.StatusOK},
+		{http.MethodGet, "/core/versions/1.13.0-lx.1", nil, http.StatusMethodNotAllowed},
+	} {
+		resp, body := authDo(t, c.method, base+c.path, c.body)
+		if resp.StatusCode != c.want {
+			t.Errorf("%s %s: %d %s, want %d", c.method, c.path, resp.StatusCode, body, c.want)
+		}
+	}
+
+	_, body = authDo(t, http.MethodGet, base+"/core/versions", nil)
+	var got struct {
+		Versions     []CoreVersionView `json:"versions"`
+		Active       string            `json:"active"`
+		Trial        bool              `json:"trial"`
+		StoreInUse   bool              `json:"store_in_use"`
+		LastFallback *CoreFallbackView `json:"last_fallback"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("list parse: %v (%s)", err, body)
+	}
+	if len(got.Versions) != 1 || got.Active != "1.14.0-lx.2" || !got.Trial || !got.StoreInUse ||
+		got.LastFallback == nil || got.LastFallback.To != "1.13.0-lx.1" {
+		t.Errorf("list = %s", body)
+	}
+}
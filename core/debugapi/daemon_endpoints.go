@@ -1,13 +1,15 @@
 // Package debugapi — SPEC 100 §3.6: локальный демон `sing-box lxd`.
 //
 // Интерфейс кросс-платформенный, но фасад существует только там, где есть
-// демонный движок (darwin): wiring других платформ просто не зовёт
+// демонный движок (darwin, linux): wiring других платформ просто не зовёт
 // EnableDaemon, и группа не регистрируется — её нет ни в роутинге, ни в /help,
 // а capabilities.daemon в манифесте стоит false.
 package debugapi
 
 import (
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"google.golang.org/grpc"
@@ -27,6 +29,11 @@ type DaemonStatus struct {
 	InterruptedApply bool   `json:"interrupted_apply"`
 	DaemonVersion    string `json:"daemon_version,omitempty"`
 	StateDir         string `json:"state_dir,omitempty"`
+
+	// ServiceScope — system | user; ServiceScopes — что допускает платформа
+	// (у launchd только system, у systemd оба).
+	ServiceScope  string   `json:"service_scope,omitempty"`
+	ServiceScopes []string `json:"service_scopes,omitempty"`
 }
 
 // DaemonCommands — готовые sudo-команды для терминала оператора. API их
@@ -49,6 +56,9 @@ type DaemonFacade interface {
 	Unpair() error
 	SetAddress(addr string) error
 	SetSecret(secret string) error
+	// SetServiceScope выбирает размещение службы (system | user) — от него
+	// зависят команды из Commands.
+	SetServiceScope(scope string) error
 	Commands() DaemonCommands
 
 	// EngineMode — активный движок ядра: "classic" | "daemon".
@@ -72,7 +82,7 @@ func (s *Server) daemonEndpoints() []apiEndpoint {
 		{"GET", "/daemon/status", true, "Local daemon: pairing, service, core status", s.handleDaemonStatus},
 		{"POST", "/daemon/pair", true, "Pair with the local daemon (invite)", s.handleDaemonPair},
 		{"POST", "/daemon/unpair", true, "Forget local pairing (keys, pin, secret)", s.handleDaemonUnpair},
-		{"PATCH", "/daemon/settings", true, "Set daemon address and/or secret", s.handleDaemonSettings},
+		{"PATCH", "/daemon/settings", true, "Set daemon address, secret and/or service scope", s.handleDaemonSettings},
 		{"GET/POST", "/daemon/engine", true, "Get / switch core engine (classic|daemon)", s.handleDaemonEngine},
 		{"GET", "/daemon/commands", true, "Ready-to-run sudo commands (API never executes them)", s.handleDaemonCommands},
 		{"POST", "/daemon/raw/rest", true, "Raw admin-REST call to the local daemon", s.handleDaemonRawREST},
@@ -139,13 +149,23 @@ func (s *Server) handleDaemonSettings(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Addr   *string `json:"addr"`
 		Secret *string `json:"secret"`
+		Scope  *string `json:"scope"`
 	}
 	if err := decodeJSONBody(r, &req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
 		return
 	}
-	if req.Addr == nil && req.Secret == nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "nothing to update: pass addr and/or secret"})
+	if req.Addr == nil && req.Secret == nil && req.Scope == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "nothing to update: pass addr, secret and/or scope"})
+		return
+	}
+	// Scope проверяется до любых изменений: частично применённый PATCH
+	// оставил бы настройки в состоянии, которого никто не просил.
+	if req.Scope != nil && !slices.Contains(s.daemon.Status().ServiceScopes, *req.Scope) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error": "unsupported service scope " + strconv.Quote(*req.Scope),
+			"field": "scope",
+		})
 		return
 	}
 	if req.Addr != nil {
@@ -160,6 +180,12 @@ func (s *Server) handleDaemonSettings(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if req.Scope != nil {
+		if err := s.daemon.SetServiceScope(*req.Scope); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "status": s.daemon.Status()})
 }
 
@@ -15,15 +15,21 @@ type fakeDaemonFacade struct {
 	mode      string
 	switchErr error
 	paired    bool
+	scope     string
 }
 
 func (f *fakeDaemonFacade) Status() DaemonStatus {
-	return DaemonStatus{Paired: f.paired, Address: "127.0.0.1:19091", CoreSupportsLxd: true}
+	return DaemonStatus{Paired: f.paired, Address: "127.0.0.1:19091", CoreSupportsLxd: true,
+		ServiceScope: f.scope, ServiceScopes: []string{"system", "user"}}
 }
 func (f *fakeDaemonFacade) Pair(invite, secret string) error { return nil }
 func (f *fakeDaemonFacade) Unpair() error                    { return nil }
 func (f *fakeDaemonFacade) SetAddress(addr string) error     { return nil }
 func (f *fakeDaemonFacade) SetSecret(secret string) error    { return nil }
+func (f *fakeDaemonFacade) SetServiceScope(scope string) error {
+	f.scope = scope
+	return nil
+}
 func (f *fakeDaemonFacade) Commands() DaemonCommands {
 	return DaemonCommands{Install: "sudo sing-box lxd --service=install"}
 }
@@ -79,6 +85,17 @@ func TestDaemonGroupContract(t *testing.T) {
 		t.Errorf("status = %+v", st)
 	}
 
+	// Scope службы: неизвестный — 422 с полем и без изменений; известный —
+	// применяется.
+	resp, _ = authDo(t, http.MethodPatch, base+"/daemon/settings", map[string]any{"addr": "127.0.0.1:1", "scope": "cluster"})
+	if resp.StatusCode != 422 || fd.scope != "" {
+		t.Errorf("bad scope: %d (scope %q), want 422 and no change", resp.StatusCode, fd.scope)
+	}
+	resp, body = authDo(t, http.MethodPatch, base+"/daemon/settings", map[string]any{"scope": "user"})
+	if resp.StatusCode != 200 || fd.scope != "user" {
+		t.Errorf("scope: %d (%s), scope %q", resp.StatusCode, body, fd.scope)
+	}
+
 	// Движок: невалидный режим — 400; валидный — переключает.
 	resp, _ = authDo(t, http.MethodPost, base+"/daemon/engine", map[string]any{"mode": "warp9"})
 	if resp.StatusCode != 400 {
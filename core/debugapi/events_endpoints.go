@@ -0,0 +1,339 @@
+package debugapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"singbox-launcher/api"
+	"singbox-launcher/core/events"
+	tprof "singbox-launcher/internal/traffic"
+)
+
+// GET /events — Server-Sent Events stream, so scripts and dashboards react
+// to the launcher instead of polling /state, /proxies and /traffic/live.
+//
+// Every frame is
+//
+//	id: <seq>
+//	event: <topic>
+//	data: {"topic":…,"at":…,"data":{…},"dropped":N}
+//
+// Topics:
+//
+//	state          events.StateChanged    — state.json saved
+//	config         events.ConfigBuilt     — config.json rebuilt (or failed)
+//	vpn            events.VpnStateChanged — core started / stopped
+//	proxy          events.ProxySwitched   — node selected in a group
+//	subscriptions  events.SubscriptionsRefreshed — refresh finished
+//	drift          events.RemoteDriftChecked — remote machine checked against its deploy
+//	traffic        TrafficEvent of the local profiler (same shape as /traffic/live)
+//
+// The first frame (topic "ready") lists the subscribed topics: anything
+// published after it is delivered.
+//
+// ?topics= (comma-separated) narrows the stream; default is every topic
+// except traffic, which is high-volume and has to be asked for, and except
+// drift for a token without the remote scope. Explicit traffic needs the
+// traffic scope, explicit drift the remote one (eventsScopes). ?group=
+// filters proxy events; ?process=, ?outbound=, ?host= filter traffic with
+// the same semantics as DELETE /connections.
+//
+// The bus delivers synchronously in the publisher's goroutine, so handlers
+// here only enqueue: a slow client drops frames (counted in "dropped" of the
+// next frame that gets through) instead of stalling the launcher.
+
+const (
+	topicState         = "state"
+	topicConfig        = "config"
+	topicVPN           = "vpn"
+	topicProxy         = "proxy"
+	topicSubscriptions = "subscriptions"
+	topicDrift         = "drift"
+	topicTraffic       = "traffic"
+)
+
+// eventTopics — bus kind of every topic; traffic comes from the profiler
+// and has no kind.
+var eventTopics = map[string]events.EventKind{
+	topicState:         events.StateChanged,
+	topicConfig:        events.ConfigBuilt,
+	topicVPN:           events.VpnStateChanged,
+	topicProxy:         events.ProxySwitched,
+	topicSubscriptions: events.SubscriptionsRefreshed,
+	topicDrift:         events.RemoteDriftChecked,
+}
+
+const (
+	// eventStreamBuffer — frames queued per client before dropping.
+	eventStreamBuffer = 256
+	// eventStreamKeepalive — comment line interval: keeps proxies and
+	// idle-timeouts of HTTP clients from closing a quiet stream.
+	eventStreamKeepalive = 15 * time.Second
+)
+
+// streamFrame — one SSE frame before encoding.
+type streamFrame struct {
+	Topic   string    `json:"topic"`
+	At      time.Time `json:"at"`
+	Data    any       `json:"data"`
+	Dropped int       `json:"dropped,omitempty"`
+}
+
+// eventFilter — parsed query of GET /events.
+type eventFilter struct {
+	topics  map[string]bool
+	group   string
+	traffic api.ConnectionFilter
+}
+
+func parseEventFilter(r *http.Request) (eventFilter, error) {
+	q := r.URL.Query()
+	f := eventFilter{
+		topics: map[string]bool{},
+		group:  strings.TrimSpace(q.Get("group")),
+		traffic: api.ConnectionFilter{
+			Process:  strings.TrimSpace(q.Get("process")),
+			Outbound: strings.TrimSpace(q.Get("outbound")),
+			Host:     strings.TrimSpace(q.Get("host")),
+		},
+	}
+	raw := strings.TrimSpace(q.Get("topics"))
+	if raw == "" {
+		// drift несёт данные удалённых машин (/remote/* — ScopeRemote):
+		// по умолчанию он только у тех, кому они и так открыты.
+		c := callerFrom(r)
+		for t := range eventTopics {
+			if t == topicDrift && c != nil && !c.has(ScopeRemote) {
+				continue
+			}
+			f.topics[t] = true
+		}
+		return f, nil
+	}
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if _, ok := eventTopics[t]; !ok && t != topicTraffic {
+			return f, fmt.Errorf("unknown topic %q (known: %s)", t, strings.Join(knownTopics(), ","))
+		}
+		f.topics[t] = true
+	}
+	if len(f.topics) == 0 {
+		return f, fmt.Errorf("topics= is empty")
+	}
+	return f, nil
+}
+
+func knownTopics() []string {
+	out := []string{topicTraffic}
+	for t := range eventTopics {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// matchTraffic — ConnectionFilter semantics (see api.ConnectionFilter.Match)
+// applied to a profiler event.
+func (f eventFilter) matchTraffic(e tprof.TrafficEvent) bool {
+	if p := strings.ToLower(f.traffic.Process); p != "" {
+		if strings.ToLower(e.ProcessName) != p && strings.ToLower(e.ProcessPath) != p {
+			return false
+		}
+	}
+	if ob := f.traffic.Outbound; ob != "" {
+		found := false
+		for _, tag := range e.OutboundChain {
+			if tag == ob {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if h := strings.ToLower(f.traffic.Host); h != "" {
+		if !strings.Contains(strings.ToLower(e.Domain), h) && !strings.Contains(strings.ToLower(e.IP), h) {
+			return false
+		}
+	}
+	return true
+}
+
+// eventPayloadJSON — bus payload in API shape: snake_case keys and errors
+// as strings (error values marshal to {}).
+func eventPayloadJSON(ev events.Event) any {
+	switch p := ev.Payload.(type) {
+	case events.StateChangedPayload:
+		return map[string]any{"changed": p.Changed}
+	case events.ConfigBuiltPayload:
+		out := map[string]any{"ok": p.OK}
+		if p.Error != nil {
+			out["error"] = p.Error.Error()
+		}
+		if len(p.Warnings) > 0 {
+			out["warnings"] = p.Warnings
+		}
+		return out
+	case events.VpnStateChangedPayload:
+		return map[string]any{"running": p.Running}
+	case events.ProxySwitchedPayload:
+		return map[string]any{"group": p.Group, "proxy": p.Proxy, "remote": p.Remote}
+	case events.SubscriptionsRefreshedPayload:
+		out := map[string]any{
+			"ok":                p.OK,
+			"total_sources":     p.TotalSources,
+			"succeeded_sources": p.SucceededSources,
+			"failed_sources":    p.FailedSources,
+			"nodes":             p.Nodes,
+		}
+		if p.Error != nil {
+			out["error"] = p.Error.Error()
+		}
+		return out
+	case events.RemoteDriftCheckedPayload:
+		out := map[string]any{"machine_id": p.MachineID, "state": p.State, "redeployed": p.Redeployed}
+		if p.Error != "" {
+			out["error"] = p.Error
+		}
+		return out
+	default:
+		return map[string]any{}
+	}
+}
+
+// eventQueue — per-client frame queue with drop accounting.
+type eventQueue struct {
+	ch      chan streamFrame
+	mu      sync.Mutex
+	dropped int
+}
+
+func (q *eventQueue) push(f streamFrame) {
+	select {
+	case q.ch <- f:
+	default:
+		q.mu.Lock()
+		q.dropped++
+		q.mu.Unlock()
+	}
+}
+
+// takeDropped returns and resets the drop counter.
+func (q *eventQueue) takeDropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := q.dropped
+	q.dropped = 0
+	return n
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	f, err := parseEventFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "streaming unsupported"})
+		return
+	}
+
+	q := &eventQueue{ch: make(chan streamFrame, eventStreamBuffer)}
+	if bus := s.facade.EventBus(); bus != nil {
+		for topic, kind := range eventTopics {
+			if !f.topics[topic] {
+				continue
+			}
+			cancel := bus.Subscribe(kind, func(ev events.Event) {
+				if p, ok := ev.Payload.(events.ProxySwitchedPayload); ok && f.group != "" && p.Group != f.group {
+					return
+				}
+				q.push(streamFrame{Topic: topic, At: time.Now(), Data: eventPayloadJSON(ev)})
+			})
+			defer cancel()
+		}
+	}
+	if f.topics[topicTraffic] {
+		ch, unsub := tprof.GetInstance().Subscribe()
+		defer unsub()
+		go func() {
+			for {
+				select {
+				case e := <-ch:
+					if f.matchTraffic(e) {
+						q.push(streamFrame{Topic: topicTraffic, At: e.TS, Data: e})
+					}
+				case <-r.Context().Done():
+					return
+				case <-s.streamsDone:
+					return
+				}
+			}
+		}()
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	// First frame: the stream is subscribed — events from now on will arrive.
+	topics := make([]string, 0, len(f.topics))
+	for t := range f.topics {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	var seq uint64
+	if writeSSE(w, &seq, streamFrame{Topic: "ready", At: time.Now(), Data: map[string]any{"topics": topics}}) != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventStreamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case fr := <-q.ch:
+			fr.Dropped = q.takeDropped()
+			if writeSSE(w, &seq, fr) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		}
+	}
+}
+
+// writeSSE encodes one frame. JSON never contains a raw newline, so one
+// data: line is enough.
+func writeSSE(w http.ResponseWriter, seq *uint64, fr streamFrame) error {
+	data, err := json.Marshal(fr)
+	if err != nil {
+		return err
+	}
+	*seq++
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", *seq, fr.Topic, data)
+	return err
+}
@@ -0,0 +1,237 @@
+package debugapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"singbox-launcher/core/events"
+	tprof "singbox-launcher/internal/traffic"
+)
+
+// sseReader reads frames of a GET /events response.
+type sseReader struct {
+	t  *testing.T
+	sc *bufio.Scanner
+}
+
+// next returns the next frame (skipping keepalive comments).
+func (r *sseReader) next() streamFrame {
+	r.t.Helper()
+	var event, data string
+	for r.sc.Scan() {
+		line := r.sc.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				continue
+			}
+			var fr struct {
+				Topic   string          `json:"topic"`
+				Data    json.RawMessage `json:"data"`
+				Dropped int             `json:"dropped"`
+			}
+			if err := json.Unmarshal([]byte(data), &fr); err != nil {
+				r.t.Fatalf("frame %q: %v", data, err)
+			}
+			if fr.Topic != event {
+				r.t.Fatalf("event line %q != topic %q", event, fr.Topic)
+			}
+			var payload map[string]any
+			_ = json.Unmarshal(fr.Data, &payload)
+			return streamFrame{Topic: fr.Topic, Data: payload, Dropped: fr.Dropped}
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	r.t.Fatalf("stream ended: %v", r.sc.Err())
+	return streamFrame{}
+}
+
+func openEventStream(t *testing.T, base, query string) (*sseReader, func()) {
+	t.Helper()
+	r, _, closeStream := openEventStreamAs(t, base, query, "tok")
+	return r, closeStream
+}
+
+// openEventStreamAs opens the stream with the given token and returns the
+// ready frame too.
+func openEventStreamAs(t *testing.T, base, query, token string) (*sseReader, streamFrame, func()) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", base+"/events"+query, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		t.Fatalf("GET /events%s: status %d", query, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	r := &sseReader{t: t, sc: bufio.NewScanner(resp.Body)}
+	ready := r.next()
+	if ready.Topic != "ready" {
+		t.Fatalf("first frame = %+v, want ready", ready)
+	}
+	return r, ready, func() { _ = resp.Body.Close() }
+}
+
+func TestEventsStreamBusTopicsAndFilters(t *testing.T) {
+	port := freeLocalPort(t)
+	bus := events.NewMemoryBus()
+	s, err := New(&fakeFacade{bus: bus}, port, "tok")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+	base := "http://127.0.0.1:" + itoa(port)
+
+	r, closeStream := openEventStream(t, base, "?topics=proxy,subscriptions,config&group=proxy-out")
+	defer closeStream()
+
+	// Not subscribed: vpn; filtered out: another group.
+	bus.Publish(events.Event{Kind: events.VpnStateChanged, Payload: events.VpnStateChangedPayload{Running: true}})
+	bus.Publish(events.Event{Kind: events.ProxySwitched, Payload: events.ProxySwitchedPayload{Group: "other", Proxy: "x"}})
+	bus.Publish(events.Event{Kind: events.ProxySwitched, Payload: events.ProxySwitchedPayload{Group: "proxy-out", Proxy: "JP-01"}})
+	bus.Publish(events.Event{Kind: events.SubscriptionsRefreshed, Payload: events.SubscriptionsRefreshedPayload{
+		OK: false, Error: errors.New("all sources failed"), TotalSources: 2, FailedSources: 2,
+	}})
+	bus.Publish(events.Event{Kind: events.ConfigBuilt, Payload: events.ConfigBuiltPayload{OK: true}})
+
+	fr := r.next()
+	if p := fr.Data.(map[string]any); fr.Topic != "proxy" || p["proxy"] != "JP-01" || p["group"] != "proxy-out" {
+		t.Fatalf("proxy frame = %+v", fr)
+	}
+	fr = r.next()
+	if p := fr.Data.(map[string]any); fr.Topic != "subscriptions" || p["ok"] != false || p["error"] != "all sources failed" || p["failed_sources"] != float64(2) {
+		t.Fatalf("subscriptions frame = %+v", fr)
+	}
+	if fr = r.next(); fr.Topic != "config" || fr.Data.(map[string]any)["ok"] != true {
+		t.Fatalf("config frame = %+v", fr)
+	}
+}
+
+func TestEventsStreamTraffic(t *testing.T) {
+	port := freeLocalPort(t)
+	s, err := New(&fakeFacade{}, port, "tok")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+	base := "http://127.0.0.1:" + itoa(port)
+
+	r, closeStream := openEventStream(t, base, "?topics=traffic&outbound=proxy-out&host=example")
+	defer closeStream()
+
+	p := tprof.GetInstance()
+	p.PushEvent(tprof.TrafficEvent{TS: time.Now(), Domain: "example.com", OutboundChain: []string{"direct-out"}})
+	p.PushEvent(tprof.TrafficEvent{TS: time.Now(), Domain: "other.org", OutboundChain: []string{"JP-01", "proxy-out"}})
+	p.PushEvent(tprof.TrafficEvent{TS: time.Now(), Domain: "api.example.com", OutboundChain: []string{"JP-01", "proxy-out"}})
+
+	fr := r.next()
+	if fr.Topic != "traffic" || fr.Data.(map[string]any)["Domain"] != "api.example.com" {
+		t.Fatalf("traffic frame = %+v", fr)
+	}
+}
+
+// drift несёт данные удалённых машин: токену без remote его не выдать ни
+// явно, ни в потоке по умолчанию.
+func TestEventsStreamDriftNeedsRemoteScope(t *testing.T) {
+	port := freeLocalPort(t)
+	bus := events.NewMemoryBus()
+	s, err := NewWithOptions(&fakeFacade{bus: bus}, Options{
+		Port:  port,
+		Token: "tok",
+		Tokens: []Token{
+			{Name: "grafana", Secret: "read-token", Scopes: []Scope{ScopeRead}},
+			{Name: "fleet", Secret: "remote-token", Scopes: []Scope{ScopeRead, ScopeRemote}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+	base := "http://127.0.0.1:" + itoa(port)
+
+	for _, q := range []string{"?topics=drift", "?topics=vpn,drift"} {
+		if got, body := tokenDo(t, http.DefaultClient, "GET", base+"/events"+q, "read-token"); got != http.StatusForbidden || !strings.Contains(body, `"scope":"remote"`) {
+			t.Errorf("/events%s with a read token: %d %s, want 403 remote", q, got, body)
+		}
+	}
+
+	readyTopics := func(fr streamFrame) []any {
+		topics, _ := fr.Data.(map[string]any)["topics"].([]any)
+		return topics
+	}
+	r, ready, closeRead := openEventStreamAs(t, base, "", "read-token")
+	defer closeRead()
+	if topics := readyTopics(ready); len(topics) == 0 || slices.Contains(topics, any(topicDrift)) {
+		t.Errorf("default topics for a read token = %v, want no drift", topics)
+	}
+	_, ready, closeRemote := openEventStreamAs(t, base, "", "remote-token")
+	defer closeRemote()
+	if topics := readyTopics(ready); !slices.Contains(topics, any(topicDrift)) {
+		t.Errorf("default topics for a remote token = %v, want drift", topics)
+	}
+
+	// Событие drift до read-токена не доходит: следующий кадр — vpn.
+	bus.Publish(events.Event{Kind: events.RemoteDriftChecked, Payload: events.RemoteDriftCheckedPayload{}})
+	bus.Publish(events.Event{Kind: events.VpnStateChanged, Payload: events.VpnStateChangedPayload{Running: true}})
+	if fr := r.next(); fr.Topic != "vpn" {
+		t.Errorf("read token got %+v, want the vpn frame", fr)
+	}
+}
+
+func TestEventsStreamRejectsUnknownTopic(t *testing.T) {
+	port := freeLocalPort(t)
+	s, err := New(&fakeFacade{}, port, "tok")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:"+itoa(port)+"/events?topics=proxy,nope", nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestEventsStreamEndsOnStop(t *testing.T) {
+	port := freeLocalPort(t)
+	s, err := New(&fakeFacade{bus: events.NewMemoryBus()}, port, "tok")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.Start()
+	r, closeStream := openEventStream(t, "http://127.0.0.1:"+itoa(port), "")
+	defer closeStream()
+
+	start := time.Now()
+	s.Stop()
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("Stop waited %s on an open stream", d)
+	}
+	if r.sc.Scan() && r.sc.Text() != "" {
+		t.Fatalf("unexpected data after Stop: %q", r.sc.Text())
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package debugapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SocketSupported — есть ли здесь unix-сокет Debug API.
+const SocketSupported = true
+
+// maxSocketPath — sun_path: 104 байта на macOS, 108 на Linux, с нулём.
+const maxSocketPath = 103
+
+// listenSocket поднимает unix-сокет с правами 0600 в каталоге 0700:
+// подключиться может только владелец (и root). Оставшийся от упавшего
+// процесса файл удаляется, живой — ошибка «занято».
+func listenSocket(path string) (net.Listener, error) {
+	if len(path) > maxSocketPath {
+		return nil, fmt.Errorf("debugapi: socket path is longer than %d bytes: %s", maxSocketPath, path)
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("debugapi: socket dir: %w", err)
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("debugapi: socket dir: %w", err)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("debugapi: %s exists and is not a socket", path)
+		}
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("debugapi: %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("debugapi: remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("debugapi: socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("debugapi: listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("debugapi: socket mode: %w", err)
+	}
+	return ln, nil
+}
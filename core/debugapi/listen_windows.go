@@ -0,0 +1,17 @@
+//go:build windows
+
+package debugapi
+
+import (
+	"errors"
+	"net"
+)
+
+// SocketSupported — на Windows права файла сокет не защищают (и в Win7
+// AF_UNIX нет), поэтому только TCP.
+const SocketSupported = false
+
+// ErrSocketUnsupported — unix-сокет запрошен на Windows.
+var ErrSocketUnsupported = errors.New("debugapi: unix socket is not supported on Windows")
+
+func listenSocket(string) (net.Listener, error) { return nil, ErrSocketUnsupported }
@@ -0,0 +1,292 @@
+// Package debugapi — global outbounds (state.Connections.Outbounds) as stored,
+// with their SPEC 057/058 ref/updates.
+//
+//	GET    /state/outbounds        — entries as stored (thin referenced entries)
+//	PATCH  /state/outbounds        — body {mode: replace|append, outbounds}
+//	GET    /state/outbounds/{tag}  — one entry
+//	PUT    /state/outbounds/{tag}  — create or replace one entry
+//	DELETE /state/outbounds/{tag}  — remove (refused for template-required tags)
+//
+// GET /state/outbounds/resolved (state_endpoints.go) is the merged view; the
+// literal path wins over {tag}, so an outbound tagged "resolved" is reachable
+// through the list endpoints only.
+//
+// Schema (validateOutbounds) follows the Configurator's model:
+//   - direct entry (ref "") carries its body inline, type is required;
+//   - referenced entry (ref "#TEMPLATE#" or a preset id) stores no body — the
+//     tag must exist in the template / the preset must exist, and user edits go
+//     into updates[] with ref "#USER#";
+//   - updates[].ref is "#USER#" or a preset id, "#USER#" at most once and last.
+package debugapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"singbox-launcher/core/config/configtypes"
+	"singbox-launcher/core/state"
+	"singbox-launcher/core/template"
+)
+
+// validateOutbound checks one entry; field is the JSON path used in errors.
+func validateOutbound(ob configtypes.OutboundConfig, field string, td *template.TemplateData) *fieldError {
+	if strings.TrimSpace(ob.Tag) == "" {
+		return fieldErr(field+".tag", "tag is empty")
+	}
+	if !state.ValidOutboundEntryRef(ob.Ref) {
+		return fieldErr(field+".ref", "invalid ref %q (want \"\", %q or a preset id)", ob.Ref, configtypes.RefTemplate)
+	}
+	switch ob.Ref {
+	case "":
+		if strings.TrimSpace(ob.Type) == "" {
+			return fieldErr(field+".type", "direct outbound %q needs a type", ob.Tag)
+		}
+	default:
+		if ob.Type != "" || ob.Options != nil || ob.Filters != nil || ob.AddOutbounds != nil || ob.PreferredDefault != nil {
+			return fieldErr(field, "referenced outbound %q stores no body; put edits into updates[] with ref %q",
+				ob.Tag, configtypes.RefUser)
+		}
+		if ob.Ref == configtypes.RefTemplate {
+			found := false
+			for _, t := range td.GlobalOutbounds() {
+				if t.Tag == ob.Tag {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fieldErr(field+".ref", "template has no outbound %q", ob.Tag)
+			}
+		} else if !templateHasPreset(td, ob.Ref) {
+			return fieldErr(field+".ref", "template has no preset %q", ob.Ref)
+		}
+	}
+	for i, u := range ob.Updates {
+		uf := fmt.Sprintf("%s.updates[%d]", field, i)
+		if !state.ValidOutboundUpdateRef(u.Ref) {
+			return fieldErr(uf+".ref", "invalid ref %q (want %q or a preset id)", u.Ref, configtypes.RefUser)
+		}
+		if u.Ref == configtypes.RefUser && i != len(ob.Updates)-1 {
+			return fieldErr(uf+".ref", "%q patch must be the last update", configtypes.RefUser)
+		}
+		if len(u.Patch) == 0 {
+			return fieldErr(uf+".patch", "patch is empty")
+		}
+	}
+	return nil
+}
+
+func templateHasPreset(td *template.TemplateData, id string) bool {
+	if td == nil {
+		return false
+	}
+	for i := range td.Presets {
+		if td.Presets[i].ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOutbounds checks a whole list: every entry plus unique tags.
+func validateOutbounds(obs []configtypes.OutboundConfig, td *template.TemplateData) *fieldError {
+	seen := make(map[string]bool, len(obs))
+	for i, ob := range obs {
+		field := fmt.Sprintf("outbounds[%d]", i)
+		if fe := validateOutbound(ob, field, td); fe != nil {
+			return fe
+		}
+		if seen[ob.Tag] {
+			return fieldErr(field+".tag", "duplicate tag %q", ob.Tag)
+		}
+		seen[ob.Tag] = true
+	}
+	return nil
+}
+
+// patchOutboundsReq — body for PATCH /state/outbounds (same modes as
+// PATCH /state/rules).
+type patchOutboundsReq struct {
+	Mode      string                       `json:"mode"`
+	Outbounds []configtypes.OutboundConfig `json:"outbounds"`
+}
+
+func (s *Server) handleStateOutbounds(w http.ResponseWriter, r *http.Request) {
+	s.stateOutboundsWith(w, r, s.localStateAccess(r))
+}
+
+func (s *Server) stateOutboundsWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
+	switch r.Method {
+	case http.MethodGet:
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"outbounds": st.Connections.Outbounds})
+
+	case http.MethodPatch:
+		var req patchOutboundsReq
+		if err := decodeJSONBody(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if req.Mode != "replace" && req.Mode != "append" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "mode must be 'replace' or 'append'"})
+			return
+		}
+		td, err := s.facade.LoadTemplate()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "load template: " + err.Error()})
+			return
+		}
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+			return
+		}
+		next := req.Outbounds
+		if req.Mode == "append" {
+			next = append(append([]configtypes.OutboundConfig(nil), st.Connections.Outbounds...), req.Outbounds...)
+		}
+		if next == nil {
+			next = []configtypes.OutboundConfig{}
+		}
+		if fe := validateOutbounds(next, td); fe != nil {
+			writeFieldError(w, fe)
+			return
+		}
+		if fe := checkRequiredKept(st.Connections.Outbounds, next, td); fe != nil {
+			writeFieldError(w, fe)
+			return
+		}
+		before := len(st.Connections.Outbounds)
+		st.Connections.Outbounds = next
+		if err := saveConnections(acc, st); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok":           true,
+			"diff_summary": []string{fmt.Sprintf("outbounds: %s, %d → %d entries", req.Mode, before, len(next))},
+		})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or PATCH required"})
+	}
+}
+
+// checkRequiredKept — template-required outbounds can be edited but not
+// removed (the Configurator disables Del for them). Only tags present before
+// the edit count: a state that already lacks one isn't blocked from edits.
+func checkRequiredKept(before, next []configtypes.OutboundConfig, td *template.TemplateData) *fieldError {
+	have := make(map[string]bool, len(next))
+	for _, ob := range next {
+		have[ob.Tag] = true
+	}
+	required := td.RequiredOutboundTags()
+	for _, ob := range before {
+		if required[ob.Tag] && !have[ob.Tag] {
+			return fieldErr("outbounds", "outbound %q is required by the template", ob.Tag)
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleStateOutboundByTag(w http.ResponseWriter, r *http.Request) {
+	s.stateOutboundByTagWith(w, r, s.localStateAccess(r))
+}
+
+func (s *Server) stateOutboundByTagWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
+	tag := pathParam(r, "tag")
+	switch r.Method {
+	case http.MethodGet:
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		for _, ob := range st.Connections.Outbounds {
+			if ob.Tag == tag {
+				writeJSON(w, http.StatusOK, ob)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown outbound %q", tag)})
+
+	case http.MethodPut, http.MethodDelete:
+		var ob configtypes.OutboundConfig
+		if r.Method == http.MethodPut {
+			if err := decodeJSONBody(r, &ob); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+				return
+			}
+			if ob.Tag == "" {
+				ob.Tag = tag
+			} else if ob.Tag != tag {
+				writeFieldError(w, fieldErr("tag", "body tag %q differs from path tag %q", ob.Tag, tag))
+				return
+			}
+		}
+		td, err := s.facade.LoadTemplate()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "load template: " + err.Error()})
+			return
+		}
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+			return
+		}
+		next := make([]configtypes.OutboundConfig, 0, len(st.Connections.Outbounds)+1)
+		found := false
+		for _, cur := range st.Connections.Outbounds {
+			if cur.Tag != tag {
+				next = append(next, cur)
+				continue
+			}
+			found = true
+			if r.Method == http.MethodPut {
+				next = append(next, ob) // keep the position
+			}
+		}
+		verb := "delete"
+		if r.Method == http.MethodPut {
+			verb = "replace"
+			if !found {
+				verb = "add"
+				next = append(next, ob)
+			}
+			if fe := validateOutbound(ob, "outbound", td); fe != nil {
+				writeFieldError(w, fe)
+				return
+			}
+		} else {
+			if !found {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown outbound %q", tag)})
+				return
+			}
+			if fe := checkRequiredKept(st.Connections.Outbounds, next, td); fe != nil {
+				writeFieldError(w, fe)
+				return
+			}
+		}
+		st.Connections.Outbounds = next
+		if err := saveConnections(acc, st); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok":           true,
+			"diff_summary": []string{fmt.Sprintf("outbounds: %s %q", verb, tag)},
+		})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET, PUT or DELETE required"})
+	}
+}
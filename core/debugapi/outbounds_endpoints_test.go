@@ -0,0 +1,121 @@
+package debugapi
+
+import (
+	"testing"
+
+	"singbox-launcher/core/config/configtypes"
+	"singbox-launcher/core/state"
+	"singbox-launcher/core/template"
+)
+
+// outboundsTemplate — template with one required global outbound and one preset.
+func outboundsTemplate() *template.TemplateData {
+	return &template.TemplateData{
+		ParserConfig: `{"ParserConfig":{"outbounds":[
+			{"tag":"proxy-out","type":"selector","required":true},
+			{"tag":"auto-proxy-out","type":"urltest"}
+		]}}`,
+		Presets: []template.Preset{{ID: "ru-direct"}},
+	}
+}
+
+func outboundsState() *state.State {
+	st := state.New()
+	st.Connections.Outbounds = []configtypes.OutboundConfig{
+		{Tag: "proxy-out", Ref: configtypes.RefTemplate},
+		{Tag: "my-out", Type: "selector", AddOutbounds: []string{"direct-out"}},
+	}
+	return st
+}
+
+func TestStateOutboundsPatchValidates(t *testing.T) {
+	cases := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantField string
+		wantCount int
+	}{
+		{"append_direct", `{"mode":"append","outbounds":[{"tag":"x-out","type":"urltest"}]}`, 200, "", 3},
+		{"append_template_ref_with_user_patch", `{"mode":"append","outbounds":[{"tag":"auto-proxy-out","ref":"#TEMPLATE#","updates":[{"ref":"ru-direct","patch":{"comment":"a"}},{"ref":"#USER#","patch":{"filters":{"tag":"!/ru/i"}}}]}]}`, 200, "", 3},
+		{"append_preset_ref", `{"mode":"append","outbounds":[{"tag":"ru-out","ref":"ru-direct"}]}`, 200, "", 3},
+		{"duplicate_tag", `{"mode":"append","outbounds":[{"tag":"my-out","type":"selector"}]}`, 422, "outbounds[2].tag", 0},
+		{"direct_without_type", `{"mode":"append","outbounds":[{"tag":"x-out"}]}`, 422, "outbounds[2].type", 0},
+		{"ref_user_on_entry", `{"mode":"append","outbounds":[{"tag":"x-out","ref":"#USER#"}]}`, 422, "outbounds[2].ref", 0},
+		{"unknown_template_tag", `{"mode":"append","outbounds":[{"tag":"nope","ref":"#TEMPLATE#"}]}`, 422, "outbounds[2].ref", 0},
+		{"unknown_preset", `{"mode":"append","outbounds":[{"tag":"x-out","ref":"nope"}]}`, 422, "outbounds[2].ref", 0},
+		{"referenced_with_body", `{"mode":"append","outbounds":[{"tag":"auto-proxy-out","ref":"#TEMPLATE#","type":"urltest"}]}`, 422, "outbounds[2]", 0},
+		{"user_patch_not_last", `{"mode":"append","outbounds":[{"tag":"auto-proxy-out","ref":"#TEMPLATE#","updates":[{"ref":"#USER#","patch":{"a":1}},{"ref":"ru-direct","patch":{"b":2}}]}]}`, 422, "outbounds[2].updates[0].ref", 0},
+		{"template_ref_in_update", `{"mode":"append","outbounds":[{"tag":"x-out","type":"selector","updates":[{"ref":"#TEMPLATE#","patch":{"a":1}}]}]}`, 422, "outbounds[2].updates[0].ref", 0},
+		{"replace_drops_required", `{"mode":"replace","outbounds":[{"tag":"my-out","type":"selector"}]}`, 422, "outbounds", 0},
+		{"replace_keeps_required", `{"mode":"replace","outbounds":[{"tag":"proxy-out","ref":"#TEMPLATE#"}]}`, 200, "", 1},
+		{"bad_mode", `{"mode":"merge","outbounds":[]}`, 400, "", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ff := &fakeFacade{stateValue: outboundsState(), templateValue: outboundsTemplate()}
+			base, _ := newTestServer(t, ff)
+			var got map[string]any
+			status, raw := doJSON(t, authedReq(t, "PATCH", base+"/state/outbounds", []byte(c.body)), &got)
+			if status != c.wantCode {
+				t.Fatalf("status %d, want %d: %s", status, c.wantCode, raw)
+			}
+			if c.wantField != "" && got["field"] != c.wantField {
+				t.Fatalf("field = %v, want %s", got["field"], c.wantField)
+			}
+			if c.wantCode != 200 {
+				if ff.savedState != nil {
+					t.Fatal("state saved on a rejected patch")
+				}
+				return
+			}
+			if n := len(ff.savedState.Connections.Outbounds); n != c.wantCount {
+				t.Fatalf("outbounds = %d, want %d", n, c.wantCount)
+			}
+			if n := len(ff.savedState.ParserConfig.ParserConfig.Outbounds); n != c.wantCount {
+				t.Fatalf("legacy outbounds = %d, want %d", n, c.wantCount)
+			}
+		})
+	}
+}
+
+func TestStateOutboundByTag(t *testing.T) {
+	ff := &fakeFacade{stateValue: outboundsState(), templateValue: outboundsTemplate()}
+	base, _ := newTestServer(t, ff)
+
+	var ob configtypes.OutboundConfig
+	if status, raw := doJSON(t, authedReq(t, "GET", base+"/state/outbounds/proxy-out", nil), &ob); status != 200 || ob.Ref != configtypes.RefTemplate {
+		t.Fatalf("GET: status %d: %s", status, raw)
+	}
+
+	// Replace keeps the position; the path supplies the tag.
+	body := `{"ref":"#TEMPLATE#","updates":[{"ref":"#USER#","patch":{"filters":{"tag":"/jp/i"}}}]}`
+	if status, raw := doJSON(t, authedReq(t, "PUT", base+"/state/outbounds/proxy-out", []byte(body)), nil); status != 200 {
+		t.Fatalf("PUT replace: status %d: %s", status, raw)
+	}
+	obs := ff.savedState.Connections.Outbounds
+	if len(obs) != 2 || obs[0].Tag != "proxy-out" || len(obs[0].Updates) != 1 {
+		t.Fatalf("after replace = %+v", obs)
+	}
+
+	if status, raw := doJSON(t, authedReq(t, "PUT", base+"/state/outbounds/new-out", []byte(`{"type":"selector"}`)), nil); status != 200 {
+		t.Fatalf("PUT add: status %d: %s", status, raw)
+	}
+	if obs := ff.savedState.Connections.Outbounds; len(obs) != 3 || obs[2].Tag != "new-out" {
+		t.Fatalf("after add = %+v", obs)
+	}
+
+	var got map[string]any
+	if status, _ := doJSON(t, authedReq(t, "PUT", base+"/state/outbounds/new-out", []byte(`{"tag":"other","type":"selector"}`)), &got); status != 422 || got["field"] != "tag" {
+		t.Fatalf("PUT tag mismatch: status %d field %v", status, got["field"])
+	}
+	if status, _ := doJSON(t, authedReq(t, "DELETE", base+"/state/outbounds/proxy-out", nil), nil); status != 422 {
+		t.Fatalf("DELETE required: status %d, want 422", status)
+	}
+	if status, raw := doJSON(t, authedReq(t, "DELETE", base+"/state/outbounds/my-out", nil), nil); status != 200 {
+		t.Fatalf("DELETE: status %d: %s", status, raw)
+	}
+	if status, _ := doJSON(t, authedReq(t, "GET", base+"/state/outbounds/my-out", nil), nil); status != 404 {
+		t.Fatalf("GET deleted: status %d, want 404", status)
+	}
+}
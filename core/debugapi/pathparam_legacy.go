@@ -20,6 +20,11 @@ func pathParam(r *http.Request, name string) string {
 		"name":    "resources",
 		"conn_id": "connections",
 		"key":     "clients",
+		"profile": "profiles",
+
+		"source_id": "sources",
+		"tag":       "outbounds",
+		"version":   "versions",
 	}[name]
 	if !ok {
 		return ""
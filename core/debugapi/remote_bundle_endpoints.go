@@ -0,0 +1,135 @@
+// Package debugapi — перенос машин между лаунчерами: зашифрованный пакет
+// с записями реестра, клиентскими парами и состоянием визарда
+// (services/lxd_remote_bundle.go).
+package debugapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"singbox-launcher/core/services"
+)
+
+// bundleMachineView — строка отчёта импорта.
+type bundleMachineView struct {
+	Name       string   `json:"name"`
+	Addr       string   `json:"addr"`
+	ID         string   `json:"id,omitempty"`
+	Status     string   `json:"status"` // imported | replaced | skipped | failed
+	ConflictID string   `json:"conflict_id,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// handleRemoteFleetExport — POST {machines?, passphrase}: пакет выбранных
+// машин (пусто — все). Ответ — сам файл пакета, его можно сохранить как
+// есть и отдать в /remote/fleet/import.
+func (s *Server) handleRemoteFleetExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	var req struct {
+		Machines   []string `json:"machines"`
+		Passphrase string   `json:"passphrase"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	if len(req.Passphrase) < services.MinBundlePassphrase {
+		writeFieldError(w, fieldErr("passphrase", "must be at least %d characters", services.MinBundlePassphrase))
+		return
+	}
+	ids := req.Machines
+	if len(ids) == 0 {
+		list, err := s.remote.Registry.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		for _, d := range list {
+			ids = append(ids, d.ID)
+		}
+	}
+	bundle, err := s.remote.Registry.ExportBundle(ids, req.Passphrase)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "unknown id") {
+			code = http.StatusNotFound
+		}
+		writeJSON(w, code, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, json.RawMessage(bundle))
+}
+
+// handleRemoteFleetImport — POST {bundle, passphrase, on_conflict?}.
+// on_conflict: skip (по умолчанию) | replace. Как у fleet/run, сбой на
+// машине — строка отчёта, а не ошибка запроса.
+func (s *Server) handleRemoteFleetImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	var req struct {
+		Bundle     json.RawMessage `json:"bundle"`
+		Passphrase string          `json:"passphrase"`
+		OnConflict string          `json:"on_conflict"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	if len(req.Bundle) == 0 {
+		writeFieldError(w, fieldErr("bundle", "is required"))
+		return
+	}
+	policy, err := services.ParseBundleConflict(req.OnConflict)
+	if err != nil {
+		writeFieldError(w, fieldErr("on_conflict", "%v", err))
+		return
+	}
+	report, err := s.remote.Registry.ImportBundle(req.Bundle, req.Passphrase, policy)
+	if err != nil {
+		if errors.Is(err, services.ErrBundlePassphrase) {
+			writeFieldError(w, fieldErr("passphrase", "%v", err))
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	machines := make([]bundleMachineView, 0, len(report.Machines))
+	for _, m := range report.Machines {
+		v := bundleMachineView{
+			Name: m.Name, Addr: m.Addr, ID: m.ID, ConflictID: m.ConflictID,
+			Warnings: m.Warnings, Error: m.Err, Status: "imported",
+		}
+		switch {
+		case m.Err != "":
+			v.Status = "failed"
+		case m.Skipped:
+			v.Status = "skipped"
+		case m.Replaced:
+			v.Status = "replaced"
+		}
+		if m.Replaced {
+			s.remote.Pool.Invalidate(m.ConflictID)
+		}
+		machines = append(machines, v)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"machines":       machines,
+		"profiles_added": nonNilStrings(report.ProfilesAdded),
+		"profiles_kept":  nonNilStrings(report.ProfilesKept),
+	})
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,188 @@
+// Package debugapi — сертификаты сопряжения: срок, ротация клиентской
+// пары, перепиновка сервера и отзыв лаунчера по всему парку
+// (services/lxd_remote_certs.go).
+package debugapi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/services"
+)
+
+// certClientView — доверенный сертификат из списка демона.
+type certClientView struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	EnrolledAt  string `json:"enrolled_at,omitempty"`
+	Self        bool   `json:"self"`
+}
+
+// handleRemoteCerts — GET: наш сертификат, сертификат сервера и список
+// доверенных клиентов демона. Ходит по сети (рукопожатие + список).
+func (s *Server) handleRemoteCerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	st, err := s.remote.Registry.CertStatus(r.Context(), id)
+	if err != nil {
+		writeJSON(w, remoteCallStatus(err), map[string]any{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	out := map[string]any{
+		"client": map[string]any{
+			"name":         st.ClientName,
+			"fingerprint":  st.ClientFingerprint,
+			"not_after":    rfc3339OrEmpty(st.ClientNotAfter),
+			"expires_soon": st.ClientExpiresSoon(now),
+		},
+		"server": map[string]any{
+			"pinned":       st.PinnedServer,
+			"presented":    st.PresentedServer,
+			"mismatch":     st.ServerMismatch(),
+			"not_after":    rfc3339OrEmpty(st.ServerNotAfter),
+			"expires_soon": st.ServerExpiresSoon(now),
+			"error":        st.ServerErr,
+		},
+		"warning_days":        int(services.CertExpiryWarning / (24 * time.Hour)),
+		"clients_unsupported": st.ClientsUnsupported,
+	}
+	if !st.ClientsUnsupported {
+		clients := make([]certClientView, 0, len(st.Clients))
+		for _, c := range st.Clients {
+			clients = append(clients, certClientView{
+				Name: c.Name, Fingerprint: c.Fingerprint,
+				EnrolledAt: rfc3339OrEmpty(c.EnrolledAt), Self: c.Fingerprint == st.ClientFingerprint,
+			})
+		}
+		out["clients"] = clients
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleRemoteCertsRotate — POST {invite?}: новая клиентская пара.
+// invite нужен только демону без /admin/clients (иначе 409 с подсказкой).
+// retire_hint в ответе — старый сертификат остался доверенным, команда
+// для самой машины.
+func (s *Server) handleRemoteCertsRotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Invite string `json:"invite"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	// Старый транспорт держит канал со старым ключом — после ротации он
+	// упрётся в отзыв.
+	s.remote.Pool.Invalidate(id)
+	res, err := s.remote.Registry.RotateClientCert(id, req.Invite)
+	if err != nil {
+		writeJSON(w, remoteCallStatus(err), map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":              true,
+		"machine":         machineViewOf(res.Machine),
+		"old_fingerprint": res.OldFingerprint,
+		"new_fingerprint": res.NewFingerprint,
+		"via_invite":      res.ViaInvite,
+		"retire_hint":     emptyToNil(res.RetireHint),
+	})
+}
+
+// handleRemoteCertsRePin — POST {fingerprint}: новый пин сервера.
+// Применяется, только если сервер предъявляет ровно этот отпечаток;
+// подтверждать его — дело вызывающего (GET …/certs показывает presented).
+func (s *Server) handleRemoteCertsRePin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Fingerprint) == "" {
+		writeFieldError(w, fieldErr("fingerprint", "is required"))
+		return
+	}
+	s.remote.Pool.Invalidate(id)
+	if err := s.remote.Registry.RePin(r.Context(), id, req.Fingerprint); err != nil {
+		writeJSON(w, remoteCallStatus(err), map[string]any{"error": err.Error()})
+		return
+	}
+	d, _, _ := s.remote.Registry.Get(id)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "machine": machineViewOf(d)})
+}
+
+// handleRemoteFleetRevoke — POST {device?}: отзыв лаунчера на всех
+// машинах. Без device — ЭТОТ лаунчер (и его локальные ключи). Как и у
+// fleet/run, сбой на машине — не ошибка запроса: он в строке результата.
+func (s *Server) handleRemoteFleetRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	var req struct {
+		Device string `json:"device"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	res, err := s.remote.Registry.RevokeLauncher(req.Device)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Device) == "" {
+		for _, x := range res {
+			s.remote.Pool.Invalidate(x.ID)
+		}
+	}
+	machines := make([]map[string]any, 0, len(res))
+	for _, x := range res {
+		removed := x.Removed
+		if removed == nil {
+			removed = []string{}
+		}
+		machines = append(machines, map[string]any{
+			"id": x.ID, "name": x.Name, "removed": removed,
+			"hint": emptyToNil(x.Hint), "error": emptyToNil(x.Err),
+		})
+	}
+	device := strings.TrimSpace(req.Device)
+	if device == "" {
+		device = services.LauncherDeviceName()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"device": device, "machines": machines})
+}
+
+func rfc3339OrEmpty(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
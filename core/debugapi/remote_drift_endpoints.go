@@ -0,0 +1,151 @@
+// Package debugapi — сверка машины с задеплоенным на неё конфигом и
+// политика повторного деплоя. Тела — services.DriftReconciler и
+// RemoteRegistry (lxd_remote_drift.go), те же, что у строки списка машин и
+// окна правки машины в UI.
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"singbox-launcher/core/services"
+)
+
+// driftReportView — отчёт сверки в форме API.
+type driftReportView struct {
+	Checked     bool                `json:"checked"`
+	CheckedAt   string              `json:"checked_at,omitempty"`
+	State       string              `json:"state,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	BuiltSHA    string              `json:"built_sha,omitempty"`
+	DeployedSHA string              `json:"deployed_sha,omitempty"`
+	ActiveSHA   string              `json:"active_sha,omitempty"`
+	RolledBack  bool                `json:"rolled_back,omitempty"`
+	Resources   []resourceDriftView `json:"resources,omitempty"`
+	Redeployed  bool                `json:"redeployed,omitempty"`
+	RedeployErr string              `json:"redeploy_error,omitempty"`
+}
+
+type resourceDriftView struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func driftReportViewOf(rep services.DriftReport) driftReportView {
+	v := driftReportView{
+		Checked:     true,
+		CheckedAt:   rep.CheckedAt.UTC().Format(time.RFC3339),
+		State:       string(rep.State),
+		Error:       rep.Err,
+		BuiltSHA:    rep.BuiltSHA,
+		DeployedSHA: rep.DeployedSHA,
+		ActiveSHA:   rep.ActiveSHA,
+		RolledBack:  rep.RolledBack,
+		Redeployed:  rep.Redeployed,
+		RedeployErr: rep.RedeployErr,
+	}
+	for _, res := range rep.Resources {
+		v.Resources = append(v.Resources, resourceDriftView{Name: res.Name, Status: res.Status})
+	}
+	return v
+}
+
+// handleRemoteDrift — GET: последний отчёт сверки ({"checked": false}, если
+// машину ещё не сверяли или после отчёта был Deploy).
+func (s *Server) handleRemoteDrift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	rep, ok := s.remote.Drift.Report(id)
+	if !ok {
+		writeJSON(w, http.StatusOK, driftReportView{})
+		return
+	}
+	writeJSON(w, http.StatusOK, driftReportViewOf(rep))
+}
+
+// handleRemoteDriftCheck — POST {redeploy?}: сверить сейчас. redeploy не
+// задан — решает политика машины (auto_redeploy).
+//
+//	404 — конфиг машины ещё не собран
+//	409 — сверка этой машины уже идёт
+func (s *Server) handleRemoteDriftCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Redeploy *bool `json:"redeploy"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSONBody(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+	}
+	d, _, err := s.remote.Registry.Get(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	redeploy := d.Policy().AutoRedeploy
+	if req.Redeploy != nil {
+		redeploy = *req.Redeploy
+	}
+	rep, err := s.remote.Drift.ReconcileBy(actorOf(r), id, redeploy)
+	if err != nil {
+		if errors.Is(err, services.ErrDriftCheckBusy) {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+			return
+		}
+		writeRemoteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, driftReportViewOf(rep))
+}
+
+// handleRemoteDeployPolicy — GET / PUT политики наблюдения за машиной.
+// PUT заменяет политику целиком; {} выключает наблюдение.
+func (s *Server) handleRemoteDeployPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		d, _, err := s.remote.Registry.Get(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, d.Policy())
+	case http.MethodPut:
+		var p services.DeployPolicy
+		if err := decodeJSONBody(r, &p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if err := services.ValidateDeployPolicy(p); err != nil {
+			writeFieldError(w, fieldErr("check_every", "%s", err.Error()))
+			return
+		}
+		if err := s.remote.Registry.SetDeployPolicy(id, p); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		d, _, _ := s.remote.Registry.Get(id)
+		writeJSON(w, http.StatusOK, d.Policy())
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or PUT required"})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/lxdclient"
 	"singbox-launcher/internal/platform"
 )
@@ -48,6 +49,23 @@ type RemoteAPI struct {
 	UIConnect    func(id string) error
 	UIDisconnect func() error
 	UIState      func() (id, name string, active bool, err error)
+
+	// FleetRefresh — шаг refresh-subscriptions прогона по парку: обновить
+	// подписки машины (пайплайн подписок живёт в core). nil = шаг
+	// недоступен, запрос с ним получает 422.
+	FleetRefresh func(id string) (string, error)
+
+	// Drift — фоновая сверка машин (общая с UI). nil — EnableRemote
+	// заводит свою над Registry: ручная сверка работает и без планировщика.
+	Drift *services.DriftReconciler
+
+	// Logs — накопленные логи машин (общие с панелью логов UI). nil —
+	// EnableRemote заводит свой хаб над Registry.
+	Logs *services.RemoteLogHub
+
+	// CoreFetch — загрузчик ядра под платформу машины для SSH-bootstrap.
+	// nil — bootstrap только проверяет уже установленное ядро.
+	CoreFetch services.CoreBinaryFetcher
 }
 
 // ErrUIUnavailable — UI-override недоступен: лаунчер работает headless или
@@ -63,8 +81,22 @@ func (s *Server) remoteEndpoints() []apiEndpoint {
 		{"GET/POST", "/remote/machines", true, "List machines / pair a new one (invite)", s.handleRemoteMachines},
 		{"GET/PATCH/DELETE", "/remote/machines/{id}", true, "Get / update / remove a machine", s.handleRemoteMachineByID},
 		{"POST", "/remote/machines/{id}/repair", true, "Re-pair with a fresh invite (new client key)", s.handleRemoteRepair},
+		{"GET/PUT", "/remote/machines/{id}/route", true, "Get / replace how the machine's control channel is reached", s.handleRemoteRoute},
+		{"GET", "/remote/machines/{id}/certs", true, "Client and server certificates, expiry, daemon's trusted clients", s.handleRemoteCerts},
+		{"POST", "/remote/machines/{id}/certs/rotate", true, "Rotate the client certificate (new key, retire the old one)", s.handleRemoteCertsRotate},
+		{"POST", "/remote/machines/{id}/certs/repin", true, "Re-pin the server certificate to the one it presents now", s.handleRemoteCertsRePin},
+		{"POST", "/remote/bootstrap/ssh", true, "Install the daemon over SSH and pair with it", s.handleRemoteSSHBootstrap},
 		{"POST", "/remote/machines/{id}/profile/copy-from", true, "Copy wizard profile from another machine", s.handleRemoteProfileCopyFrom},
 
+		// Базовые профили: общие настройки, от которых наследуют машины.
+		{"GET", "/remote/profiles", true, "List base profiles and the machines inheriting them", s.handleRemoteProfiles},
+		{"GET/PUT/DELETE", "/remote/profiles/{profile}", true, "Get / create-or-replace / delete a base profile (state JSON)", s.handleRemoteProfileByName},
+		{"GET", "/remote/machines/{id}/profile", true, "Machine's base profile, staleness and its own overrides", s.handleRemoteMachineProfile},
+		{"POST", "/remote/machines/{id}/profile/inherit", true, "Make the machine inherit a base profile", s.handleRemoteMachineProfileInherit},
+		{"POST", "/remote/machines/{id}/profile/detach", true, "Stop inheriting; keep the current state as a plain copy", s.handleRemoteMachineProfileDetach},
+		{"POST", "/remote/machines/{id}/profile/sync", true, "Rebase the machine's state onto the current base", s.handleRemoteMachineProfileSync},
+		{"POST", "/remote/machines/{id}/profile/publish", true, "Save the machine's state as a base profile", s.handleRemoteMachineProfilePublish},
+
 		// Здоровье, ядро, конфиг, деплой.
 		{"GET", "/remote/machines/{id}/health", true, "Reachability + core status + config SHAs", s.handleRemoteHealth},
 		{"POST", "/remote/machines/{id}/core/start", true, "Start the machine's core", s.handleRemoteCoreStart},
@@ -73,6 +105,9 @@ func (s *Server) remoteEndpoints() []apiEndpoint {
 		{"GET", "/remote/machines/{id}/config/active", true, "Running config fetched from the machine", s.handleRemoteConfigActive},
 		{"GET", "/remote/machines/{id}/config/built", true, "Locally built config of the machine", s.handleRemoteConfigBuilt},
 		{"POST", "/remote/machines/{id}/deploy", true, "Deploy resources + config to the machine", s.handleRemoteDeploy},
+		{"GET", "/remote/machines/{id}/drift", true, "Last drift check: built vs deployed vs running config", s.handleRemoteDrift},
+		{"POST", "/remote/machines/{id}/drift/check", true, "Check drift now (optionally redeploy)", s.handleRemoteDriftCheck},
+		{"GET/PUT", "/remote/machines/{id}/deploy-policy", true, "Get / replace the machine's drift check and redeploy policy", s.handleRemoteDeployPolicy},
 
 		// Профиль машины (wizard state) — зеркала /state/*.
 		{"GET", "/remote/machines/{id}/state/full", true, "Machine's full wizard state JSON", s.handleRemoteStateFull},
@@ -80,6 +115,13 @@ func (s *Server) remoteEndpoints() []apiEndpoint {
 		{"GET/PATCH", "/remote/machines/{id}/state/dns", true, "Get / replace machine's dns_options", s.handleRemoteStateDNS},
 		{"GET/PATCH", "/remote/machines/{id}/state/dns/rules", true, "Get / replace machine's USER dns rules (text)", s.handleRemoteStateDNSRules},
 		{"GET", "/remote/machines/{id}/state/outbounds/resolved", true, "Machine's resolved outbounds", s.handleRemoteStateOutboundsResolved},
+		{"GET/POST", "/remote/machines/{id}/state/sources", true, "List / add machine's connection sources", s.handleRemoteStateSources},
+		{"GET/PATCH/DELETE", "/remote/machines/{id}/state/sources/{source_id}", true, "Get / merge-update / delete a machine's source", s.handleRemoteStateSourceByID},
+		{"POST", "/remote/machines/{id}/state/sources/{source_id}/enable", true, "Enable a machine's source", s.handleRemoteStateSourceEnable},
+		{"POST", "/remote/machines/{id}/state/sources/{source_id}/disable", true, "Disable a machine's source", s.handleRemoteStateSourceDisable},
+		{"GET/PATCH", "/remote/machines/{id}/state/outbounds", true, "Get / replace|append machine's global outbounds", s.handleRemoteStateOutbounds},
+		{"GET/PUT/DELETE", "/remote/machines/{id}/state/outbounds/{tag}", true, "Get / create-or-replace / delete machine's outbound", s.handleRemoteStateOutboundByTag},
+		{"GET/PATCH", "/remote/machines/{id}/state/vars", true, "Get / set machine's template vars", s.handleRemoteStateVars},
 
 		// Наблюдаемость (gRPC StartedService + admin REST хоста).
 		{"GET", "/remote/machines/{id}/groups", true, "Selector group tags of the machine's core", s.handleRemoteGroups},
@@ -94,6 +136,7 @@ func (s *Server) remoteEndpoints() []apiEndpoint {
 		{"DELETE", "/remote/machines/{id}/connections/{conn_id}", true, "Close one connection", s.handleRemoteConnectionByID},
 		{"GET", "/remote/machines/{id}/dns/queries", true, "DNS queries window (?duration=&max=)", s.handleRemoteDNSQueries},
 		{"GET", "/remote/machines/{id}/logs", true, "Core log window (?duration=&max=)", s.handleRemoteLogs},
+		{"GET", "/remote/machines/{id}/logs/history", true, "Buffered machine log: core, launcher and api streams (?stream=&level=&q=&re=&since=&until=&max=&format=text)", s.handleRemoteLogHistory},
 		{"GET", "/remote/machines/{id}/host", true, "Host telemetry (CPU, memory, disks)", s.handleRemoteHost},
 		{"GET", "/remote/machines/{id}/host/interfaces", true, "Host network interfaces + counters", s.handleRemoteHostInterfaces},
 		{"GET", "/remote/machines/{id}/clients", true, "LAN clients directory of the machine", s.handleRemoteClients},
@@ -109,6 +152,12 @@ func (s *Server) remoteEndpoints() []apiEndpoint {
 		{"POST", "/remote/machines/{id}/raw/rest", true, "Raw admin-REST call to the machine's daemon", s.handleRemoteRawREST},
 		{"POST", "/remote/machines/{id}/raw/grpc", true, "Raw daemon.* gRPC call to the machine", s.handleRemoteRawGRPC},
 
+		// Парк: одна операция над многими машинами разом.
+		{"POST", "/remote/fleet/run", true, "Run ops on many machines (bounded concurrency, result matrix)", s.handleRemoteFleetRun},
+		{"POST", "/remote/fleet/revoke", true, "Revoke this (or another) launcher's certificates on all machines", s.handleRemoteFleetRevoke},
+		{"POST", "/remote/fleet/export", true, "Export machines with their keys and wizard state as an encrypted bundle", s.handleRemoteFleetExport},
+		{"POST", "/remote/fleet/import", true, "Import an encrypted machine bundle (on_conflict: skip | replace)", s.handleRemoteFleetImport},
+
 		// UI-override: перевод вкладки Servers лаунчера на машину (SPEC 100
 		// §3.8) — то же, что кнопки Connect/Disconnect вкладки Remote.
 		{"GET", "/remote/ui", true, "Which machine the launcher UI is connected to", s.handleRemoteUIState},
@@ -134,6 +183,8 @@ func (s *Server) remoteMachineID(w http.ResponseWriter, r *http.Request) (string
 		writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown machine %q", id)})
 		return "", false
 	}
+	// Поток api истории машины: вызов виден в её панели логов.
+	s.remote.Logs.Note(id, services.RemoteLogAPI, debuglog.LevelInfo, r.Method+" "+r.URL.RequestURI())
 	return id, true
 }
 
@@ -141,6 +192,7 @@ func (s *Server) remoteMachineID(w http.ResponseWriter, r *http.Request) (string
 //
 //	422 — демон отклонил конфиг валидацией (ApplyError.Rejected)
 //	409 — ресурс занят живой ссылкой (ResourceError.InUse)
+//	409 — базовый профиль новее собранного конфига (ErrProfileRebuildNeeded)
 //	404 — built-конфига ещё нет (ErrBuiltConfigMissing)
 //	504 — таймаут вызова
 //	502 — машина недоступна (сеть/пин/отказ канала)
@@ -157,6 +209,14 @@ func remoteCallStatus(err error) int {
 	if errors.Is(err, services.ErrBuiltConfigMissing) {
 		return http.StatusNotFound
 	}
+	if errors.Is(err, services.ErrProfileRebuildNeeded) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, lxdclient.ErrClientsUnsupported) {
+		// Демон не правит список клиентов по сети: нужно приглашение или
+		// CLI на машине — состояние, а не сбой запроса.
+		return http.StatusConflict
+	}
 	if errors.Is(err, context.DeadlineExceeded) {
 		return http.StatusGatewayTimeout
 	}
@@ -211,7 +271,15 @@ type machineView struct {
 	GOOS              string `json:"goos,omitempty"`
 	GOARCH            string `json:"goarch,omitempty"`
 	StateDir          string `json:"state_dir,omitempty"`
-	AddedAt           string `json:"added_at,omitempty"`
+	BaseProfile       string `json:"base_profile,omitempty"`
+	DeployedSHA       string `json:"deployed_sha,omitempty"`
+	DeployedAt        string `json:"deployed_at,omitempty"`
+	// CoreVersion — закреплённая версия ядра; пусто — версия сборки.
+	CoreVersion string `json:"core_version,omitempty"`
+	// Route — описание маршрута (RemoteRoute.Describe); сам маршрут —
+	// /remote/machines/{id}/route.
+	Route   string `json:"route"`
+	AddedAt string `json:"added_at,omitempty"`
 }
 
 func machineViewOf(d services.RemoteDaemon) machineView {
@@ -219,7 +287,10 @@ func machineViewOf(d services.RemoteDaemon) machineView {
 		ID: d.ID, Name: d.Name, Addr: d.Addr,
 		ServerFingerprint: d.ServerFingerprint, Secret: d.Secret,
 		GOOS: d.GOOS, GOARCH: d.GOARCH,
-		StateDir: d.StateDir, AddedAt: d.AddedAt,
+		StateDir: d.StateDir, BaseProfile: d.BaseProfile,
+		DeployedSHA: d.DeployedSHA, DeployedAt: d.DeployedAt,
+		CoreVersion: d.CoreVersion,
+		Route:       d.Route().Describe(), AddedAt: d.AddedAt,
 	}
 }
 
@@ -244,11 +315,21 @@ func (s *Server) handleRemoteMachines(w http.ResponseWriter, r *http.Request) {
 			Name   string `json:"name"`
 			Addr   string `json:"addr"`
 			Secret string `json:"secret"`
+			// Route — машина за NAT недоступна напрямую уже на enroll.
+			Route *services.RemoteRoute `json:"route"`
 		}
 		if err := decodeJSONBody(r, &req); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
 			return
 		}
+		var route services.RemoteRoute
+		if req.Route != nil {
+			if err := services.ValidateRemoteRoute(*req.Route); err != nil {
+				writeFieldError(w, fieldErr("route", "%s", err.Error()))
+				return
+			}
+			route = *req.Route
+		}
 		if strings.TrimSpace(req.Invite) == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invite is required (addr#fingerprint#code)"})
 			return
@@ -260,7 +341,7 @@ func (s *Server) handleRemoteMachines(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": perr.Error()})
 			return
 		}
-		entry, err := s.remote.Registry.PairWithAddr(req.Invite, req.Name, req.Addr, req.Secret)
+		entry, err := s.remote.Registry.PairVia(req.Invite, req.Name, req.Addr, req.Secret, route)
 		if err != nil {
 			// Код приглашения одноразовый: неудача сети означает, что он мог
 			// сгореть — предупреждаем, чтобы агент не долбил повторами.
@@ -475,6 +556,7 @@ func (s *Server) handleRemoteHealth(w http.ResponseWriter, r *http.Request) {
 		"active_sha":        emptyToNil(h.ActiveSHA),
 		"last_good_sha":     emptyToNil(h.LastGoodSHA),
 		"interrupted_apply": h.InterruptedApply,
+		"route":             h.Route,
 	})
 }
 
@@ -584,7 +666,7 @@ func (s *Server) handleRemoteDeploy(w http.ResponseWriter, r *http.Request) {
 			config = []byte(req.Config)
 		}
 	}
-	res, err := s.remote.Registry.Deploy(id, config)
+	res, err := s.remote.Registry.DeployBy(actorOf(r), id, config)
 	if err != nil {
 		writeRemoteError(w, err)
 		return
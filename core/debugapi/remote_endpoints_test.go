@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -390,3 +391,345 @@ func TestRemoteMachineStateMirror(t *testing.T) {
 		t.Errorf("local rules leaked machine rules: %d entries", len(rules.Rules))
 	}
 }
+
+func TestRemoteFleetRun(t *testing.T) {
+	var applied []byte
+	daemon := httptest.NewServer(fakeDaemonMux(&applied))
+	defer daemon.Close()
+	addr := strings.TrimPrefix(daemon.URL, "http://")
+
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", addr)
+
+	// Ошибки запроса — 422 с указанием на поле.
+	for _, c := range []struct {
+		body  map[string]any
+		field string
+	}{
+		{map[string]any{"ops": []string{"reboot"}}, "ops"},
+		{map[string]any{"ops": []string{}}, "ops"},
+		{map[string]any{"ops": []string{"deploy"}, "machines": []string{"ghost"}}, "machines"},
+		{map[string]any{"ops": []string{"deploy"}, "concurrency": 99}, "concurrency"},
+		// Обновлялка подписок не подключена (в проде её даёт core).
+		{map[string]any{"ops": []string{"refresh-subscriptions"}}, "ops"},
+	} {
+		resp, body := authDo(t, http.MethodPost, base+"/remote/fleet/run", c.body)
+		var e struct {
+			Field string `json:"field"`
+		}
+		_ = json.Unmarshal(body, &e)
+		if resp.StatusCode != 422 || e.Field != c.field {
+			t.Errorf("%v: status %d field %q (%s), want 422 on %q", c.body, resp.StatusCode, e.Field, body, c.field)
+		}
+	}
+
+	// Без собранного конфига шаг падает, но запрос — 200: сбой машины — данные.
+	resp, body := authDo(t, http.MethodPost, base+"/remote/fleet/run",
+		map[string]any{"ops": []string{"deploy"}})
+	if resp.StatusCode != 200 {
+		t.Fatalf("fleet run: status %d (%s)", resp.StatusCode, body)
+	}
+	var run struct {
+		OK      bool           `json:"ok"`
+		Summary map[string]int `json:"summary"`
+		Results []struct {
+			ID    string `json:"id"`
+			OK    bool   `json:"ok"`
+			Steps []struct {
+				Op     string `json:"op"`
+				Status string `json:"status"`
+				Error  string `json:"error"`
+				Detail string `json:"detail"`
+			} `json:"steps"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &run); err != nil {
+		t.Fatalf("fleet parse: %v", err)
+	}
+	if run.OK || run.Summary["failed"] != 1 || len(run.Results) != 1 ||
+		run.Results[0].Steps[0].Status != "failed" || !strings.Contains(run.Results[0].Steps[0].Error, "Configure") {
+		t.Errorf("fleet w/o built config = %s", body)
+	}
+
+	cfgPath := platform.GetRemoteConfigPathFor(execDir, "router")
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o755); err != nil {
+		t.Fatalf("mkdir machine dir: %v", err)
+	}
+	if err := os.WriteFile(cfgPath, []byte(`{"log":{"level":"warn"}}`), 0o644); err != nil {
+		t.Fatalf("write built config: %v", err)
+	}
+	resp, body = authDo(t, http.MethodPost, base+"/remote/fleet/run",
+		map[string]any{"machines": []string{"router"}, "ops": []string{"deploy"}, "stop_on_failure": true})
+	if resp.StatusCode != 200 {
+		t.Fatalf("fleet run: status %d (%s)", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, &run); err != nil {
+		t.Fatalf("fleet parse: %v", err)
+	}
+	if !run.OK || run.Summary["ok"] != 1 || run.Results[0].Steps[0].Op != "deploy" || run.Results[0].Steps[0].Status != "ok" {
+		t.Errorf("fleet deploy = %s", body)
+	}
+	if !bytes.Contains(applied, []byte(`"warn"`)) {
+		t.Errorf("daemon received %q, want the built config", applied)
+	}
+}
+
+func TestRemoteBaseProfiles(t *testing.T) {
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", "127.0.0.1:1")
+
+	profile := json.RawMessage(`{"meta":{"version":6},"connections":{"sources":[]},"rules":[],"vars":[{"name":"tun","value":"false"}],"dns_options":{}}`)
+	if resp, body := authDo(t, http.MethodPut, base+"/remote/profiles/home", profile); resp.StatusCode != 200 {
+		t.Fatalf("PUT profile: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, body := authDo(t, http.MethodPut, base+"/remote/profiles/bad", json.RawMessage(`{"version":4}`)); resp.StatusCode != 422 {
+		t.Errorf("PUT legacy profile: %d (%s), want 422", resp.StatusCode, body)
+	}
+	if resp, _ := authDo(t, http.MethodPost, base+"/remote/machines/router/profile/inherit", map[string]any{"name": "../x"}); resp.StatusCode != 422 {
+		t.Errorf("inherit with an unsafe name: %d, want 422", resp.StatusCode)
+	}
+	if resp, body := authDo(t, http.MethodPost, base+"/remote/machines/router/profile/inherit", map[string]any{"name": "home"}); resp.StatusCode != 200 {
+		t.Fatalf("inherit: %d (%s)", resp.StatusCode, body)
+	}
+
+	// Машина без настроек получила копию базы и ничем от неё не отличается.
+	resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/profile", nil)
+	var st struct {
+		Name        string         `json:"name"`
+		BaseChanged bool           `json:"base_changed"`
+		Overlay     map[string]any `json:"overlay"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("profile status: %d (%s)", resp.StatusCode, body)
+	}
+	if st.Name != "home" || st.BaseChanged || len(st.Overlay) != 0 {
+		t.Errorf("status = %+v, want home, fresh, no overrides", st)
+	}
+
+	resp, body = authDo(t, http.MethodGet, base+"/remote/profiles", nil)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"machines":["router"]`) {
+		t.Errorf("list: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, _ := authDo(t, http.MethodDelete, base+"/remote/profiles/home", nil); resp.StatusCode != 409 {
+		t.Errorf("DELETE inherited profile: %d, want 409", resp.StatusCode)
+	}
+	if resp, _ := authDo(t, http.MethodPost, base+"/remote/machines/router/profile/detach", nil); resp.StatusCode != 200 {
+		t.Errorf("detach: %d", resp.StatusCode)
+	}
+	if resp, _ := authDo(t, http.MethodDelete, base+"/remote/profiles/home", nil); resp.StatusCode != 200 {
+		t.Errorf("DELETE after detach: %d", resp.StatusCode)
+	}
+	if resp, _ := authDo(t, http.MethodGet, base+"/remote/profiles/home", nil); resp.StatusCode != 404 {
+		t.Errorf("GET deleted profile: %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestRemoteDriftAndDeployPolicy(t *testing.T) {
+	daemon := httptest.NewServer(fakeDaemonMux(nil))
+	defer daemon.Close()
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", strings.TrimPrefix(daemon.URL, "http://"))
+
+	if resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/drift", nil); resp.StatusCode != 200 ||
+		!strings.Contains(string(body), `"checked":false`) {
+		t.Errorf("drift before any check: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, _ := authDo(t, http.MethodPost, base+"/remote/machines/router/drift/check", nil); resp.StatusCode != 404 {
+		t.Errorf("check without a built config: %d, want 404", resp.StatusCode)
+	}
+
+	cfg := platform.GetRemoteConfigPathFor(execDir, "router")
+	if err := os.MkdirAll(filepath.Dir(cfg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg, []byte(`{"log":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := authDo(t, http.MethodPost, base+"/remote/machines/router/deploy", nil); resp.StatusCode != 200 {
+		t.Fatalf("deploy: %d (%s)", resp.StatusCode, body)
+	}
+
+	// Фейк-демон продолжает отчитываться чужим хешем — это и есть дрейф.
+	resp, body := authDo(t, http.MethodPost, base+"/remote/machines/router/drift/check", map[string]any{"redeploy": false})
+	var rep struct {
+		State       string `json:"state"`
+		ActiveSHA   string `json:"active_sha"`
+		DeployedSHA string `json:"deployed_sha"`
+	}
+	if err := json.Unmarshal(body, &rep); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("check: %d (%s)", resp.StatusCode, body)
+	}
+	if rep.State != "drifted" || rep.ActiveSHA != "aaa" || rep.DeployedSHA == "" {
+		t.Errorf("report = %+v, want drifted from the deployed sha", rep)
+	}
+	if _, body := authDo(t, http.MethodGet, base+"/remote/machines/router/drift", nil); !strings.Contains(string(body), `"state":"drifted"`) {
+		t.Errorf("stored report: %s", body)
+	}
+
+	if resp, _ := authDo(t, http.MethodPut, base+"/remote/machines/router/deploy-policy", map[string]any{"check_every": "10s"}); resp.StatusCode != 422 {
+		t.Errorf("policy with a 10s period: %d, want 422", resp.StatusCode)
+	}
+	if resp, body := authDo(t, http.MethodPut, base+"/remote/machines/router/deploy-policy",
+		map[string]any{"check_every": "15m", "auto_redeploy": true}); resp.StatusCode != 200 {
+		t.Fatalf("PUT policy: %d (%s)", resp.StatusCode, body)
+	}
+	if _, body := authDo(t, http.MethodGet, base+"/remote/machines/router/deploy-policy", nil); !strings.Contains(string(body), `"check_every":"15m"`) ||
+		!strings.Contains(string(body), `"auto_redeploy":true`) {
+		t.Errorf("GET policy: %s", body)
+	}
+	if _, body := authDo(t, http.MethodGet, base+"/remote/machines/router", nil); !strings.Contains(string(body), `"deployed_sha"`) {
+		t.Errorf("machine view lacks deployed_sha: %s", body)
+	}
+}
+
+// Сам bootstrap проверяется в services на sshd в процессе; здесь — что
+// ручка отсекает неполный запрос до похода в сеть и что недоступный хост —
+// ошибка, а не «машина добавлена».
+func TestRemoteSSHBootstrapValidation(t *testing.T) {
+	base, _, _ := newRemoteTestServer(t)
+	cases := []struct {
+		body  map[string]any
+		field string
+	}{
+		{map[string]any{"user": "root"}, "host"},
+		{map[string]any{"host": "vps.example"}, "user"},
+		{map[string]any{"host": "vps.example", "user": "root", "listen_port": 70000}, "listen_port"},
+	}
+	for _, c := range cases {
+		resp, body := authDo(t, http.MethodPost, base+"/remote/bootstrap/ssh", c.body)
+		if resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(body), c.field) {
+			t.Errorf("%v: %d (%s), want 422 on %s", c.body, resp.StatusCode, body, c.field)
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	_ = ln.Close()
+	resp, body := authDo(t, http.MethodPost, base+"/remote/bootstrap/ssh", map[string]any{
+		"host": closed, "user": "root", "use_agent": true, "host_key_sha256": "SHA256:x",
+	})
+	if resp.StatusCode == http.StatusOK {
+		t.Fatalf("bootstrap against a closed port succeeded: %s", body)
+	}
+	if resp, body = authDo(t, http.MethodGet, base+"/remote/machines", nil); !strings.Contains(string(body), `"machines":[]`) {
+		t.Errorf("machines after a failed bootstrap: %d (%s)", resp.StatusCode, body)
+	}
+}
+
+func TestRemoteRoute(t *testing.T) {
+	daemon := httptest.NewServer(fakeDaemonMux(new([]byte)))
+	defer daemon.Close()
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", strings.TrimPrefix(daemon.URL, "http://"))
+
+	if resp, body := authDo(t, http.MethodPut, base+"/remote/machines/router/route",
+		map[string]any{"kind": "ssh", "ssh_host": "jump"}); resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(body), `"route"`) {
+		t.Fatalf("incomplete ssh route: %d (%s), want 422", resp.StatusCode, body)
+	}
+
+	// Прокси на закрытом порту: машина недоступна, и видно, каким путём.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	_ = ln.Close()
+	if resp, body := authDo(t, http.MethodPut, base+"/remote/machines/router/route",
+		map[string]any{"kind": "socks5", "proxy_url": closed}); resp.StatusCode != 200 || !strings.Contains(string(body), `"describe":"socks5 `+closed+`"`) {
+		t.Fatalf("put socks5 route: %d (%s)", resp.StatusCode, body)
+	}
+	_, body := authDo(t, http.MethodGet, base+"/remote/machines/router/health", nil)
+	if !strings.Contains(string(body), `"reachable":false`) || !strings.Contains(string(body), `"route":"socks5 `+closed+`"`) {
+		t.Errorf("health via a dead proxy: %s", body)
+	}
+	if _, body = authDo(t, http.MethodGet, base+"/remote/machines/router", nil); !strings.Contains(string(body), `"route":"socks5 `) {
+		t.Errorf("machine view lacks the route: %s", body)
+	}
+
+	authDo(t, http.MethodPut, base+"/remote/machines/router/route", map[string]any{"kind": "direct"})
+	if _, body = authDo(t, http.MethodGet, base+"/remote/machines/router/health", nil); !strings.Contains(string(body), `"reachable":true`) || !strings.Contains(string(body), `"route":"direct"`) {
+		t.Errorf("health after going direct: %s", body)
+	}
+}
+
+// Сертификаты plain-h2c машины: своих нет — статус пустой, ротировать
+// нечего, а отзыв по парку честно говорит об этом в строке машины.
+func TestRemoteCerts(t *testing.T) {
+	daemon := httptest.NewServer(fakeDaemonMux(new([]byte)))
+	defer daemon.Close()
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", strings.TrimPrefix(daemon.URL, "http://"))
+
+	resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/certs", nil)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"warning_days":30`) || !strings.Contains(string(body), `"fingerprint":""`) {
+		t.Fatalf("certs: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, body = authDo(t, http.MethodPost, base+"/remote/machines/router/certs/rotate", nil); resp.StatusCode == 200 || !strings.Contains(string(body), "plain h2c") {
+		t.Fatalf("rotate on plain h2c: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, body = authDo(t, http.MethodPost, base+"/remote/machines/router/certs/repin", map[string]any{}); resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(body), `"fingerprint"`) {
+		t.Fatalf("repin without fingerprint: %d (%s)", resp.StatusCode, body)
+	}
+	resp, body = authDo(t, http.MethodPost, base+"/remote/fleet/revoke", map[string]any{"device": "singbox-launcher-lost"})
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"device":"singbox-launcher-lost"`) || !strings.Contains(string(body), "plain h2c") {
+		t.Fatalf("fleet revoke: %d (%s)", resp.StatusCode, body)
+	}
+}
+
+func TestRemoteFleetBundle(t *testing.T) {
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", "127.0.0.1:9")
+
+	if resp, body := authDo(t, http.MethodPost, base+"/remote/fleet/export", map[string]any{"passphrase": "short"}); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("short passphrase: %d (%s)", resp.StatusCode, body)
+	}
+	resp, bundle := authDo(t, http.MethodPost, base+"/remote/fleet/export", map[string]any{"passphrase": "correct horse"})
+	if resp.StatusCode != 200 || !strings.Contains(string(bundle), `"ciphertext"`) {
+		t.Fatalf("export: %d (%s)", resp.StatusCode, bundle)
+	}
+	if resp, body := authDo(t, http.MethodPost, base+"/remote/fleet/import",
+		map[string]any{"bundle": json.RawMessage(bundle), "passphrase": "wrong horse"}); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("wrong passphrase: %d (%s)", resp.StatusCode, body)
+	}
+	// Та же машина уже есть — по умолчанию пропуск.
+	resp, body := authDo(t, http.MethodPost, base+"/remote/fleet/import",
+		map[string]any{"bundle": json.RawMessage(bundle), "passphrase": "correct horse"})
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"status":"skipped"`) || !strings.Contains(string(body), `"conflict_id":"router"`) {
+		t.Fatalf("import skip: %d (%s)", resp.StatusCode, body)
+	}
+	resp, body = authDo(t, http.MethodPost, base+"/remote/fleet/import",
+		map[string]any{"bundle": json.RawMessage(bundle), "passphrase": "correct horse", "on_conflict": "replace"})
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"status":"replaced"`) {
+		t.Fatalf("import replace: %d (%s)", resp.StatusCode, body)
+	}
+}
+
+func TestRemoteLogHistory(t *testing.T) {
+	base, execDir, _ := newRemoteTestServer(t)
+	seedMachine(t, execDir, "router", "127.0.0.1:9")
+
+	if resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/logs/history?re=(", nil); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("bad regex: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/logs/history?stream=kernel", nil); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("bad stream: %d (%s)", resp.StatusCode, body)
+	}
+	// Первый вызов начинает историю; второй уже видит себя в потоке api.
+	if resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/logs/history", nil); resp.StatusCode != 200 || !strings.Contains(string(body), `"following_since"`) {
+		t.Fatalf("first call: %d (%s)", resp.StatusCode, body)
+	}
+	resp, body := authDo(t, http.MethodGet, base+"/remote/machines/router/logs/history?stream=api&q=HISTORY", nil)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `"stream":"api"`) || !strings.Contains(string(body), "logs/history") {
+		t.Fatalf("api stream: %d (%s)", resp.StatusCode, body)
+	}
+	resp, body = authDo(t, http.MethodGet, base+"/remote/machines/router/logs/history?stream=api&format=text", nil)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "[api] INFO  GET /remote/machines/router/logs/history") {
+		t.Fatalf("text format: %d (%s)", resp.StatusCode, body)
+	}
+	if resp, _ := authDo(t, http.MethodGet, base+"/remote/machines/ghost/logs/history", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown machine: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,118 @@
+// Package debugapi — прогон по парку машин: одна операция (или цепочка) над
+// многими записями реестра разом, с ограниченной параллельностью и матрицей
+// «машина × шаг» в ответе. Тело — services.RemoteRegistry.RunFleet, тот же,
+// что у окна Fleet в UI.
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+
+	"singbox-launcher/core/services"
+)
+
+// fleetStepView — клетка матрицы.
+type fleetStepView struct {
+	Op         string `json:"op"`
+	Status     string `json:"status"` // ok | failed | skipped
+	Error      string `json:"error,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// fleetMachineView — строка матрицы.
+type fleetMachineView struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	OK    bool            `json:"ok"`
+	Steps []fleetStepView `json:"steps"`
+}
+
+// handleRemoteFleetRun — POST /remote/fleet/run.
+//
+// Синхронный: ответ приходит, когда все машины отработали (или пропущены по
+// stop_on_failure). Сбой на машине — не ошибка запроса: ответ 200, а что
+// где упало — в матрице; ok верхнего уровня = все машины прошли.
+func (s *Server) handleRemoteFleetRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	var req struct {
+		Machines      []string `json:"machines"`
+		Ops           []string `json:"ops"`
+		Concurrency   int      `json:"concurrency"`
+		StopOnFailure bool     `json:"stop_on_failure"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	ops := make([]services.FleetOp, 0, len(req.Ops))
+	for _, raw := range req.Ops {
+		op, ok := services.ParseFleetOp(raw)
+		if !ok {
+			writeFieldError(w, fieldErr("ops", "unknown operation %q (known: %v)", raw, services.FleetOps))
+			return
+		}
+		ops = append(ops, op)
+	}
+
+	// Подписки машины — это её state.json: обновление идёт под тем же
+	// per-machine мьютексом, что PATCH /remote/machines/{id}/state/*, иначе
+	// meta, записанная обновлением, затёрла бы параллельную правку.
+	var refresh services.FleetRefreshFunc
+	if s.remote.FleetRefresh != nil {
+		refresh = func(id string) (string, error) {
+			mu := s.machineMutex(id)
+			mu.Lock()
+			defer mu.Unlock()
+			return s.remote.FleetRefresh(id)
+		}
+	}
+
+	results, err := s.remote.Registry.RunFleet(r.Context(), services.FleetRequest{
+		IDs:           req.Machines,
+		Ops:           ops,
+		Concurrency:   req.Concurrency,
+		StopOnFailure: req.StopOnFailure,
+	}, refresh, nil)
+	if err != nil {
+		var ve *services.FleetValidationError
+		switch {
+		case errors.As(err, &ve):
+			writeFieldError(w, fieldErr(ve.Field, "%s", ve.Msg))
+		case errors.Is(err, services.ErrFleetRefreshUnavailable):
+			writeFieldError(w, fieldErr("ops", "%s", err.Error()))
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		}
+		return
+	}
+	out := make([]fleetMachineView, 0, len(results))
+	okCount, failed, skipped := 0, 0, 0
+	for _, res := range results {
+		mv := fleetMachineView{ID: res.ID, Name: res.Name, OK: res.OK, Steps: make([]fleetStepView, 0, len(res.Steps))}
+		for _, st := range res.Steps {
+			mv.Steps = append(mv.Steps, fleetStepView{
+				Op: string(st.Op), Status: st.Status,
+				Error: st.Error, Detail: st.Detail,
+				DurationMs: st.Duration.Milliseconds(),
+			})
+		}
+		switch {
+		case res.OK:
+			okCount++
+		case res.Started():
+			failed++
+		default:
+			skipped++
+		}
+		out = append(out, mv)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":      failed == 0 && skipped == 0,
+		"summary": map[string]int{"ok": okCount, "failed": failed, "skipped": skipped},
+		"results": out,
+	})
+}
@@ -0,0 +1,130 @@
+// Package debugapi — накопленный лог машины: история трёх потоков (ядро,
+// лаунчер, Debug API) с фильтрами (services/lxd_remote_logs.go).
+package debugapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/services"
+)
+
+// remoteLogLineView — строка истории в ответе.
+type remoteLogLineView struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// handleRemoteLogHistory — GET: история лога машины под фильтром.
+//
+//	stream  core,launcher,api (через запятую; по умолчанию все)
+//	level   error | warn | info | debug | trace — самый подробный уровень
+//	q       подстрока без учёта регистра
+//	re      регулярное выражение (RE2)
+//	since   RFC3339 или длительность назад от сейчас (15m)
+//	until   RFC3339
+//	max     сколько самых новых строк (по умолчанию 500, не больше 5000)
+//	format  text — тот же текст, что «Сохранить…» в панели логов
+//
+// История копится, пока машину кто-то смотрит: первый вызов начинает её
+// (following_since), и она живёт services.RemoteLogLinger после последнего.
+func (s *Server) handleRemoteLogHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	filter, maxLines, ferr := remoteLogFilterParams(r)
+	if ferr != nil {
+		writeFieldError(w, ferr)
+		return
+	}
+	buf, since, err := s.remote.Logs.Keep(id, services.RemoteLogLinger)
+	if err != nil {
+		writeRemoteError(w, err)
+		return
+	}
+	entries, truncated := buf.Query(filter, maxLines)
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = services.WriteRemoteLog(w, entries)
+		return
+	}
+	lines := make([]remoteLogLineView, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, remoteLogLineView{
+			Seq: e.Seq, Time: e.Time, Stream: string(e.Stream),
+			Level: services.RemoteLogLevelName(e.Level), Message: e.Message,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"following_since": since,
+		"lines":           lines,
+		"truncated":       truncated,
+	})
+}
+
+func remoteLogFilterParams(r *http.Request) (services.RemoteLogFilter, int, *fieldError) {
+	q := r.URL.Query()
+	var f services.RemoteLogFilter
+	if v := q.Get("stream"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			st, ok := services.ParseRemoteLogStream(part)
+			if !ok {
+				return f, 0, fieldErr("stream", "unknown stream %q (core | launcher | api)", strings.TrimSpace(part))
+			}
+			f.Streams = append(f.Streams, st)
+		}
+	}
+	if v := q.Get("level"); v != "" {
+		l, ok := services.ParseRemoteLogLevel(v)
+		if !ok {
+			return f, 0, fieldErr("level", "unknown level %q (error | warn | info | debug | trace)", v)
+		}
+		f.Level = l
+	}
+	f.Contains = q.Get("q")
+	if v := q.Get("re"); v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return f, 0, fieldErr("re", "%v", err)
+		}
+		f.Regex = re
+	}
+	if v := q.Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			f.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			f.Since = t
+		} else {
+			return f, 0, fieldErr("since", "want RFC3339 time or a positive duration, got %q", v)
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, 0, fieldErr("until", "want RFC3339 time, got %q", v)
+		}
+		f.Until = t
+	}
+	maxLines := 500
+	if v := q.Get("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return f, 0, fieldErr("max", "must be a positive integer")
+		}
+		maxLines = min(n, services.RemoteLogHistory)
+	}
+	return f, maxLines, nil
+}
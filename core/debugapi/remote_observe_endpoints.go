@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/services"
 )
 
@@ -103,7 +104,10 @@ func (s *Server) handleRemoteProxySwitch(w http.ResponseWriter, r *http.Request)
 	if !ok {
 		return
 	}
-	if err := t.SwitchProxy(req.Group, req.Name); err != nil {
+	err := t.SwitchProxy(req.Group, req.Name)
+	audit.For(s.remote.ExecDir).Append(audit.Entry{Actor: actorOf(r), Action: audit.ActionProxySwitch,
+		Target: id + ": " + req.Group, After: req.Name}.WithErr(err))
+	if err != nil {
 		writeRemoteError(w, err)
 		return
 	}
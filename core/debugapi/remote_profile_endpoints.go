@@ -0,0 +1,232 @@
+// Package debugapi — базовые профили машин: общие настройки, от которых
+// наследуют несколько машин, и привязка машины к профилю. Тела —
+// services.RemoteRegistry (lxd_remote_profiles.go), те же, что у окна
+// машины в UI.
+package debugapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/services"
+	corestate "singbox-launcher/core/state"
+)
+
+// baseProfileBodyLimit — предел тела PUT профиля: state.json с сотней
+// источников и правил укладывается с запасом.
+const baseProfileBodyLimit = 4 << 20
+
+// baseProfileView — строка списка профилей.
+type baseProfileView struct {
+	Name      string   `json:"name"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	Machines  []string `json:"machines"`
+}
+
+// baseProfileStatusView — положение машины относительно профиля.
+type baseProfileStatusView struct {
+	Name          string                   `json:"name"`
+	BaseChanged   bool                     `json:"base_changed"`
+	RebuildNeeded bool                     `json:"rebuild_needed"`
+	Overlay       corestate.ProfileOverlay `json:"overlay"`
+}
+
+// writeProfileError классифицирует ошибку операций с профилями.
+//
+//	404 — профиля нет
+//	409 — профиль наследуют машины (удаление)
+//	422 — имя профиля или содержимое state не годится
+//	500 — всё остальное
+func writeProfileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrBaseProfileNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+	case errors.Is(err, services.ErrBaseProfileInUse):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid profile name"):
+		writeFieldError(w, fieldErr("name", "%s", err.Error()))
+	case strings.HasPrefix(err.Error(), "profile "):
+		// corestate: не v6 state / не объект / не JSON.
+		writeFieldError(w, fieldErr("state", "%s", err.Error()))
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+	}
+}
+
+// handleRemoteProfiles — GET: список базовых профилей с наследниками.
+func (s *Server) handleRemoteProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	list, err := s.remote.Registry.ListBaseProfiles()
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	out := make([]baseProfileView, 0, len(list))
+	for _, p := range list {
+		v := baseProfileView{Name: p.Name, Machines: p.Machines}
+		if v.Machines == nil {
+			v.Machines = []string{}
+		}
+		if !p.UpdatedAt.IsZero() {
+			v.UpdatedAt = p.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		out = append(out, v)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profiles": out})
+}
+
+// handleRemoteProfileByName — GET (state.json профиля) / PUT (создать или
+// заменить целиком; тело — state.json) / DELETE.
+func (s *Server) handleRemoteProfileByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(pathParam(r, "profile"))
+	switch r.Method {
+	case http.MethodGet:
+		raw, err := s.remote.Registry.GetBaseProfile(name)
+		if err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(raw)
+	case http.MethodPut:
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, baseProfileBodyLimit))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if err := s.remote.Registry.PutBaseProfile(name, raw); err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	case http.MethodDelete:
+		if err := s.remote.Registry.DeleteBaseProfile(name); err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET, PUT or DELETE required"})
+	}
+}
+
+// handleRemoteMachineProfile — GET: профиль машины, ушла ли база вперёд и
+// отличия машины от базы.
+func (s *Server) handleRemoteMachineProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	st, err := s.remote.Registry.BaseProfileStatus(id)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, baseProfileStatusView{
+		Name: st.Name, BaseChanged: st.BaseChanged,
+		RebuildNeeded: st.RebuildNeeded, Overlay: st.Overlay,
+	})
+}
+
+// handleRemoteMachineProfileInherit — POST {name}: привязать машину к
+// профилю. state.json настроенной машины не меняется — всё, чем она
+// отличается от базы, становится её отличиями.
+func (s *Server) handleRemoteMachineProfileInherit(w http.ResponseWriter, r *http.Request) {
+	s.machineProfileNamed(w, r, s.remote.Registry.InheritBaseProfile)
+}
+
+// handleRemoteMachineProfilePublish — POST {name}: опубликовать состояние
+// машины как профиль (создать или перезаписать).
+func (s *Server) handleRemoteMachineProfilePublish(w http.ResponseWriter, r *http.Request) {
+	s.machineProfileNamed(w, r, func(id, name string) error {
+		return s.remote.Registry.SaveBaseProfileFrom(name, id)
+	})
+}
+
+// handleRemoteMachineProfileDetach — POST: отвязать машину; её state.json
+// остаётся самостоятельной копией.
+func (s *Server) handleRemoteMachineProfileDetach(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	mu := s.machineMutex(id)
+	mu.Lock()
+	defer mu.Unlock()
+	if err := s.remote.Registry.DetachBaseProfile(id); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handleRemoteMachineProfileSync — POST: материализовать машину от текущей
+// базы (то же, что происходит при открытии Configure). Конфиг это не
+// пересобирает: сборка удалённой машины есть только в визарде.
+func (s *Server) handleRemoteMachineProfileSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	mu := s.machineMutex(id)
+	mu.Lock()
+	defer mu.Unlock()
+	changed, err := s.remote.Registry.SyncBaseProfile(id)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "changed": changed})
+}
+
+// machineProfileNamed — общий каркас POST {name} над машиной: state.json
+// машины читается/пишется под её мьютексом, как у PATCH state/*.
+func (s *Server) machineProfileNamed(w http.ResponseWriter, r *http.Request, op func(id, name string) error) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if err := services.ValidateBaseProfileName(name); err != nil {
+		writeFieldError(w, fieldErr("name", "%s", err.Error()))
+		return
+	}
+	mu := s.machineMutex(id)
+	mu.Lock()
+	defer mu.Unlock()
+	if err := op(id, name); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
@@ -0,0 +1,59 @@
+// Package debugapi — путь до управляющего канала машины
+// (services.RemoteRoute): напрямую, SSH jump-хост, SOCKS5/HTTP-прокси или
+// локальное ядро.
+package debugapi
+
+import (
+	"net/http"
+
+	"singbox-launcher/core/services"
+)
+
+// handleRemoteRoute — GET / PUT {kind, ssh_host?, ssh_user?, ssh_key_file?,
+// ssh_use_agent?, ssh_host_key_sha256?, proxy_url?}. PUT {"kind":"direct"}
+// снимает маршрут. После смены открытые клиенты машины сбрасываются —
+// следующий вызов пойдёт уже новым путём.
+func (s *Server) handleRemoteRoute(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.remoteMachineID(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		d, _, err := s.remote.Registry.Get(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, routeViewOf(d.Route()))
+	case http.MethodPut:
+		var rt services.RemoteRoute
+		if err := decodeJSONBody(r, &rt); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if err := services.ValidateRemoteRoute(rt); err != nil {
+			writeFieldError(w, fieldErr("route", "%s", err.Error()))
+			return
+		}
+		if err := s.remote.Registry.SetRoute(id, rt); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		s.remote.Pool.Invalidate(id)
+		d, _, _ := s.remote.Registry.Get(id)
+		writeJSON(w, http.StatusOK, routeViewOf(d.Route()))
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or PUT required"})
+	}
+}
+
+// routeView — маршрут и его описание (то же, что Health.route).
+type routeView struct {
+	services.RemoteRoute
+	Describe string `json:"describe"`
+}
+
+func routeViewOf(rt services.RemoteRoute) routeView {
+	return routeView{RemoteRoute: rt, Describe: rt.Describe()}
+}
@@ -0,0 +1,89 @@
+// Package debugapi — добавление машины через SSH: лаунчер сам ставит демон
+// и сопрягается (services.RemoteRegistry.BootstrapSSH). То же, что вкладка
+// «Через SSH» окна добавления машины в UI.
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"singbox-launcher/core/services"
+)
+
+// handleRemoteSSHBootstrap — POST {host, user, key_file?, key_passphrase?,
+// use_agent?, host_key_sha256?, known_hosts_file?, name?, listen_port?,
+// addr?, core_version?}. Синхронный: может качать ядро под платформу
+// машины — минуты. core_version закрепляется за машиной (пусто — версия
+// сборки лаунчера).
+//
+//	422 — не заполнены host/user или порт вне диапазона
+//	409 — ключ хоста не подтверждён; в ответе host_key_sha256 — что
+//	      предъявил сервер (сверить и повторить с ним как с пином)
+func (s *Server) handleRemoteSSHBootstrap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	var req struct {
+		Host           string `json:"host"`
+		User           string `json:"user"`
+		KeyFile        string `json:"key_file"`
+		KeyPassphrase  string `json:"key_passphrase"`
+		UseAgent       bool   `json:"use_agent"`
+		HostKeySHA256  string `json:"host_key_sha256"`
+		KnownHostsFile string `json:"known_hosts_file"`
+		Name           string `json:"name"`
+		ListenPort     int    `json:"listen_port"`
+		Addr           string `json:"addr"`
+		CoreVersion    string `json:"core_version"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+		return
+	}
+	switch {
+	case strings.TrimSpace(req.Host) == "":
+		writeFieldError(w, fieldErr("host", "is required"))
+		return
+	case strings.TrimSpace(req.User) == "":
+		writeFieldError(w, fieldErr("user", "is required"))
+		return
+	case req.ListenPort < 0 || req.ListenPort > 65535:
+		writeFieldError(w, fieldErr("listen_port", "must be 1-65535 (0 = %d)", services.SSHBootstrapDefaultPort))
+		return
+	}
+
+	res, err := s.remote.Registry.BootstrapSSH(r.Context(), services.SSHBootstrapRequest{
+		Host:           req.Host,
+		User:           req.User,
+		KeyFile:        req.KeyFile,
+		KeyPassphrase:  req.KeyPassphrase,
+		UseAgent:       req.UseAgent,
+		HostKeySHA256:  req.HostKeySHA256,
+		KnownHostsFile: req.KnownHostsFile,
+		Name:           req.Name,
+		ListenPort:     req.ListenPort,
+		Addr:           req.Addr,
+		CoreVersion:    strings.TrimPrefix(strings.TrimSpace(req.CoreVersion), "v"),
+	}, s.remote.CoreFetch, nil)
+	if err != nil {
+		var hkErr *services.SSHHostKeyError
+		if errors.As(err, &hkErr) {
+			writeJSON(w, http.StatusConflict, map[string]any{
+				"error":           err.Error(),
+				"host_key_sha256": hkErr.Fingerprint,
+				"mismatch":        hkErr.Mismatch,
+			})
+			return
+		}
+		writeJSON(w, remoteCallStatus(err), map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":            true,
+		"machine":       machineViewOf(res.Machine),
+		"core_version":  res.CoreVersion,
+		"core_uploaded": res.CoreUploaded,
+	})
+}
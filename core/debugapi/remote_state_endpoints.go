@@ -65,3 +65,45 @@ func (s *Server) handleRemoteStateOutboundsResolved(w http.ResponseWriter, r *ht
 		s.stateOutboundsResolvedWith(w, r, s.machineStateAccess(id))
 	}
 }
+
+func (s *Server) handleRemoteStateSources(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateSourcesWith(w, r, s.machineStateAccess(id))
+	}
+}
+
+func (s *Server) handleRemoteStateSourceByID(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateSourceByIDWith(w, r, s.machineStateAccess(id))
+	}
+}
+
+func (s *Server) handleRemoteStateSourceEnable(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateSourceSetEnabledWith(w, r, s.machineStateAccess(id), true)
+	}
+}
+
+func (s *Server) handleRemoteStateSourceDisable(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateSourceSetEnabledWith(w, r, s.machineStateAccess(id), false)
+	}
+}
+
+func (s *Server) handleRemoteStateOutbounds(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateOutboundsWith(w, r, s.machineStateAccess(id))
+	}
+}
+
+func (s *Server) handleRemoteStateOutboundByTag(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateOutboundByTagWith(w, r, s.machineStateAccess(id))
+	}
+}
+
+func (s *Server) handleRemoteStateVars(w http.ResponseWriter, r *http.Request) {
+	if id, ok := s.remoteMachineID(w, r); ok {
+		s.stateVarsWith(w, r, s.machineStateAccess(id))
+	}
+}
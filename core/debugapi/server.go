@@ -1,24 +1,25 @@
 // Package debugapi exposes a small, localhost-only HTTP surface for tools
 // (scripts, automation, other GUIs) to introspect and nudge the launcher.
-// Modeled on LxBox's spec 031 Debug API but trimmed to the most essential
-// endpoints — we intentionally omit the CRUD surface for rules/subs/settings
-// since the desktop already has a full wizard for those and the extra
-// surface is disproportionate to the use case.
+// Modeled on LxBox's spec 031 Debug API. State edits (rules, dns, sources,
+// outbounds, template vars) go through the same Save + dirty-marker path as
+// the Configurator, so provisioning scripts never hand-edit state.json.
 //
 // Safety posture:
 //   - Bind strictly to 127.0.0.1. No 0.0.0.0 / no LAN. Users who want
-//     remote access must adb-forward or ssh-tunnel.
+//     remote access must adb-forward or ssh-tunnel. Optionally (or instead)
+//     a Unix socket that only the owner can open (access.go).
 //   - Off by default. User explicitly enables in Diagnostics tab. First
 //     enable generates a random bearer token; it's shown in the UI with
 //     a Copy button and persisted in bin/settings.json.
 //   - All endpoints require "Authorization: Bearer <token>". No CORS.
+//     Named tokens are limited to their scopes; mutating calls are logged
+//     with the token name.
 //   - Action endpoints (state-mutating) are POST only.
 package debugapi
 
 import (
 	"context"
 	"crypto/rand"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -30,6 +31,9 @@ import (
 	"time"
 
 	"singbox-launcher/api"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/events"
+	"singbox-launcher/core/services"
 	"singbox-launcher/core/state"
 	"singbox-launcher/core/template"
 	"singbox-launcher/internal/debuglog"
@@ -85,15 +89,39 @@ type ControllerFacade interface {
 	LoadTemplate() (*template.TemplateData, error)
 	ApplyLogLevelAndReload(level string) error
 	ReadCurrentLogLevel() (string, bool, error)
+
+	// Connections of the LOCAL core (GET/DELETE /connections). Listing needs
+	// the Clash API; closing goes through the active engine's transport, so
+	// it works in daemon mode too. CloseConnections with an empty filter
+	// closes everything and returns -1 when the engine can't count them.
+	ListConnections() ([]api.ConnectionInfo, error)
+	CloseConnection(id string) error
+	CloseConnections(f api.ConnectionFilter) (int, error)
+
+	// RefreshSource — per-source Refresh of the Configurator: fetch + meta +
+	// raw cache for one subscription, saved to state.json. A failed fetch is
+	// recorded in the returned source's Meta, not returned as an error.
+	RefreshSource(id string) (*state.Source, error)
+
+	// EventBus — typed launcher events for GET /events. nil = the stream
+	// carries traffic events only.
+	EventBus() events.Bus
 }
 
 // Server owns the listener, shutdown context, and auth config.
 type Server struct {
 	mu       sync.Mutex
 	listener net.Listener
-	httpSrv  *http.Server
-	token    string
-	facade   ControllerFacade
+	// socket — unix-сокет (access.go); nil = не слушаем.
+	socket  net.Listener
+	httpSrv *http.Server
+	// callers — секрет токена → кто это и что ему можно (access.go).
+	callers map[string]*caller
+	facade  ControllerFacade
+
+	// auditPath — журнал изменяющих вызовов; auditMu сериализует дозапись.
+	auditPath string
+	auditMu   sync.Mutex
 
 	// stateMu serializes load-modify-save cycles of the PATCH /state/*
 	// handlers; without it two concurrent PATCHes lose one side's edit.
@@ -107,16 +135,37 @@ type Server struct {
 	// daemon — local lxd-daemon API group (SPEC 100). Non-nil only on
 	// platforms where the daemon engine exists (darwin wiring).
 	daemon DaemonFacade
+	// coreVersions — установленные версии ядра (core_versions_endpoints.go).
+	// nil = группа выключена.
+	coreVersions CoreVersionsFacade
+	// supervision — присмотр за ядром (supervision_endpoints.go).
+	// nil = группа выключена.
+	supervision SupervisionFacade
+	// backup — резервная копия профиля (backup_endpoints.go).
+	// nil = группа выключена.
+	backup BackupFacade
 
 	// machineMu — per-machine mutexes for PATCH /remote/machines/{id}/state/*
 	// load-modify-save cycles. Per machine, not global: two agents patching
 	// two different machines must not queue behind each other.
 	machineMuMu sync.Mutex
 	machineMu   map[string]*sync.Mutex
+
+	// streamsDone closes on Shutdown: long-lived GET /events responses never
+	// go idle, so Shutdown alone would wait out its deadline on them.
+	streamsDone chan struct{}
 }
 
 // EnableRemote turns the /remote/* endpoint group on. Call before Start.
-func (s *Server) EnableRemote(r *RemoteAPI) { s.remote = r }
+func (s *Server) EnableRemote(r *RemoteAPI) {
+	if r != nil && r.Drift == nil && r.Registry != nil {
+		r.Drift = services.NewDriftReconciler(r.Registry)
+	}
+	if r != nil && r.Logs == nil && r.Registry != nil {
+		r.Logs = services.NewRemoteLogHub(r.Registry)
+	}
+	s.remote = r
+}
 
 // EnableDaemon turns the /daemon/* endpoint group on. Call before Start.
 // Wiring passes the facade only on platforms with a daemon engine (darwin).
@@ -145,32 +194,63 @@ func (s *Server) capabilities() map[string]bool {
 		"remote":   s.remote != nil,
 		"daemon":   s.daemon != nil,
 		"raw_grpc": s.remote != nil || s.daemon != nil,
+
+		"core_versions": s.coreVersions != nil,
+		"supervision":   s.supervision != nil,
+		"backup":        s.backup != nil,
 	}
 }
 
 // New constructs a Server bound to 127.0.0.1:port.
 // token must be non-empty; callers generate/persist it.
 func New(facade ControllerFacade, port int, token string) (*Server, error) {
+	return NewWithOptions(facade, Options{Port: port, Token: token})
+}
+
+// NewWithOptions is New with a Unix socket, named tokens and the audit log
+// (access.go). At least one listener is required.
+func NewWithOptions(facade ControllerFacade, opts Options) (*Server, error) {
 	if facade == nil {
 		return nil, errors.New("debugapi: nil facade")
 	}
-	if strings.TrimSpace(token) == "" {
+	if strings.TrimSpace(opts.Token) == "" {
 		return nil, errors.New("debugapi: empty token")
 	}
+	if opts.NoTCP && opts.SocketPath == "" {
+		return nil, errors.New("debugapi: neither TCP nor a socket to listen on")
+	}
+	callers, err := buildCallers(opts.Token, opts.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	port := opts.Port
 	if port <= 0 {
 		port = DefaultPort
 	}
 
-	addr := fmt.Sprintf("127.0.0.1:%d", port)
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		return nil, fmt.Errorf("debugapi: listen on %s: %w", addr, err)
+	var ln, sock net.Listener
+	if !opts.NoTCP {
+		addr := fmt.Sprintf("127.0.0.1:%d", port)
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return nil, fmt.Errorf("debugapi: listen on %s: %w", addr, err)
+		}
+	}
+	if opts.SocketPath != "" {
+		if sock, err = listenSocket(opts.SocketPath); err != nil {
+			if ln != nil {
+				_ = ln.Close()
+			}
+			return nil, err
+		}
 	}
 
 	s := &Server{
-		listener: ln,
-		token:    token,
-		facade:   facade,
+		listener:    ln,
+		socket:      sock,
+		callers:     callers,
+		facade:      facade,
+		auditPath:   opts.AuditPath,
+		streamsDone: make(chan struct{}),
 	}
 	// Handler намеренно НЕ собирается здесь: между New и Start wiring может
 	// включить опциональные группы (EnableRemote/EnableDaemon, SPEC 100), а
@@ -178,6 +258,7 @@ func New(facade ControllerFacade, port int, token string) (*Server, error) {
 	s.httpSrv = &http.Server{
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	s.httpSrv.RegisterOnShutdown(func() { close(s.streamsDone) })
 	return s, nil
 }
 
@@ -186,20 +267,25 @@ func (s *Server) Start() {
 	// Snapshot under the mutex: Stop() nils out s.httpSrv, and the Serve
 	// goroutine must not race that write (nil deref would crash the app).
 	s.mu.Lock()
-	srv, ln := s.httpSrv, s.listener
+	srv := s.httpSrv
 	if srv != nil && srv.Handler == nil {
 		srv.Handler = s.routes()
 	}
 	s.mu.Unlock()
-	if srv == nil || ln == nil {
+	if srv == nil {
 		return
 	}
-	go func() {
-		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			debuglog.WarnLog("debugapi: Serve: %v", err)
+	for _, ln := range []net.Listener{s.listener, s.socket} {
+		if ln == nil {
+			continue
 		}
-	}()
-	debuglog.InfoLog("debugapi: listening on %s", ln.Addr())
+		go func(ln net.Listener) {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				debuglog.WarnLog("debugapi: Serve: %v", err)
+			}
+		}(ln)
+		debuglog.InfoLog("debugapi: listening on %s", ln.Addr())
+	}
 }
 
 // Stop gracefully shuts the server down (5s deadline).
@@ -257,6 +343,16 @@ func (s *Server) endpoints() []apiEndpoint {
 		{"GET", "/state/outbounds/resolved", true, "Resolved outbound tags", s.handleStateOutboundsResolved},
 		{"GET/PATCH", "/state/log-level", true, "Get / set sing-box log.level (restarts core)", s.handleStateLogLevel},
 
+		// Connection sources, global outbounds and template vars (Configurator parity).
+		{"GET/POST", "/state/sources", true, "List / add connection sources", s.handleStateSources},
+		{"GET/PATCH/DELETE", "/state/sources/{source_id}", true, "Get / merge-update / delete a source", s.handleStateSourceByID},
+		{"POST", "/state/sources/{source_id}/enable", true, "Enable a source", s.handleStateSourceEnable},
+		{"POST", "/state/sources/{source_id}/disable", true, "Disable a source", s.handleStateSourceDisable},
+		{"POST", "/state/sources/{source_id}/refresh", true, "Fetch one subscription now", s.handleStateSourceRefresh},
+		{"GET/PATCH", "/state/outbounds", true, "Get / replace|append global outbounds (ref/updates as stored)", s.handleStateOutbounds},
+		{"GET/PUT/DELETE", "/state/outbounds/{tag}", true, "Get / create-or-replace / delete one global outbound", s.handleStateOutboundByTag},
+		{"GET/PATCH", "/state/vars", true, "Get / set template vars (null resets to default)", s.handleStateVars},
+
 		// bin/settings.json — launcher-level preferences (subscription UA, etc).
 		{"GET/PATCH", "/settings/user-agent", true, "Get / set subscription User-Agent", s.handleSettingsUserAgent},
 
@@ -270,6 +366,13 @@ func (s *Server) endpoints() []apiEndpoint {
 		{"POST", "/traffic/stop", true, "Stop traffic capture", s.handleTrafficStop},
 		{"POST", "/traffic/clear", true, "Clear captured traffic", s.handleTrafficClear},
 		{"GET/POST", "/traffic/verbose", true, "Get / toggle verbose capture", s.handleTrafficVerbose},
+
+		// Local core connections: list, close all / by filter, close one.
+		{"GET/DELETE", "/connections", true, "List connections / close all or by ?process=&outbound=&host=", s.handleConnections},
+		{"DELETE", "/connections/{conn_id}", true, "Close one connection", s.handleConnectionByID},
+
+		// Push instead of polling: launcher events + traffic as Server-Sent Events.
+		{"GET", "/events", true, "SSE stream; ?topics=state,config,vpn,proxy,subscriptions,traffic&group=&process=&outbound=&host=", s.handleEvents},
 	}
 	// SPEC 100: optional groups — registered (and therefore documented in
 	// / and /help) only when the wiring enabled them.
@@ -279,6 +382,15 @@ func (s *Server) endpoints() []apiEndpoint {
 	if s.daemon != nil {
 		eps = append(eps, s.daemonEndpoints()...)
 	}
+	if s.coreVersions != nil {
+		eps = append(eps, s.coreVersionsEndpoints()...)
+	}
+	if s.supervision != nil {
+		eps = append(eps, s.supervisionEndpoints()...)
+	}
+	if s.backup != nil {
+		eps = append(eps, s.backupEndpoints()...)
+	}
 	if s.remote != nil || s.daemon != nil {
 		eps = append(eps, apiEndpoint{"GET", "/grpc/methods", true,
 			"Discovery: daemon.* gRPC methods for raw calls", s.handleGRPCMethods})
@@ -335,10 +447,21 @@ func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
 		"docs":         DocsURL(launcher),
 		"hint":         APIHint,
 		"capabilities": s.capabilities(),
+		"token":        s.callerView(r),
 		"endpoints":    endpointViews(s.endpoints()),
 	})
 }
 
+// callerView — имя и области токена запроса: агент сразу видит, что ему
+// можно.
+func (s *Server) callerView(r *http.Request) map[string]any {
+	c := callerFrom(r)
+	if c == nil {
+		return nil
+	}
+	return map[string]any{"name": c.name, "scopes": scopeNames(c.scopeList())}
+}
+
 // handleHelp serves GET /help — just the endpoint list, for an agent to
 // discover the surface and take it from there.
 func (s *Server) handleHelp(w http.ResponseWriter, _ *http.Request) {
@@ -353,6 +476,10 @@ func (s *Server) handleHelp(w http.ResponseWriter, _ *http.Request) {
 // token bytes by timing the 401 response. On a real loopback interface this
 // leak is theoretical, but ConstantTimeCompare costs nothing and removes the
 // class of bug outright.
+//
+// A named token outside its scopes gets 403 with the scope it lacks. Every
+// mutating call (and every refused one) goes to the audit log under the
+// token's name.
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h := r.Header.Get("Authorization")
@@ -361,12 +488,33 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
 			return
 		}
-		got := strings.TrimSpace(h[len(prefix):])
-		if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+		c := s.lookupCaller(strings.TrimSpace(h[len(prefix):]))
+		if c == nil {
 			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
 			return
 		}
-		next.ServeHTTP(w, r)
+		entry := AuditEntry{Time: time.Now(), Token: c.name, Method: r.Method, Path: r.URL.Path, Via: via(r)}
+		for _, sc := range requiredScopes(r) {
+			if !c.has(sc) {
+				writeJSON(w, http.StatusForbidden, map[string]any{"error": "forbidden", "scope": sc})
+				entry.Status = http.StatusForbidden
+				s.audit(entry)
+				return
+			}
+		}
+		ctx := context.WithValue(r.Context(), callerCtxKey{}, c)
+		r = r.WithContext(audit.WithActor(ctx, audit.APIActor(c.name)))
+		if !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		entry.Status = rec.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		s.audit(entry)
 	})
 }
 
@@ -398,7 +546,7 @@ func (s *Server) handleUpdateSubs(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
 		return
 	}
-	if err := s.facade.UpdateSubscriptions(); err != nil {
+	if err := s.facadeFor(r).UpdateSubscriptions(); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 		return
 	}
@@ -410,7 +558,7 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
 		return
 	}
-	if err := s.facade.StartSingBox(); err != nil {
+	if err := s.facadeFor(r).StartSingBox(); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 		return
 	}
@@ -434,7 +582,7 @@ func (s *Server) handleRebuildConfig(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
 		return
 	}
-	if err := s.facade.RebuildConfigIfDirty(); err != nil {
+	if err := s.facadeFor(r).RebuildConfigIfDirty(); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 		return
 	}
@@ -458,7 +606,7 @@ func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
 		return
 	}
-	if err := s.facade.StopSingBox(); err != nil {
+	if err := s.facadeFor(r).StopSingBox(); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 		return
 	}
@@ -466,7 +614,7 @@ func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 }
 
 // Addr returns the literal "127.0.0.1:N" the server is bound to — useful
-// for building a pastable example URL in the UI.
+// for building a pastable example URL in the UI. Empty in socket-only mode.
 func (s *Server) Addr() string {
 	if s.listener == nil {
 		return ""
@@ -474,6 +622,14 @@ func (s *Server) Addr() string {
 	return s.listener.Addr().String()
 }
 
+// SocketPath returns the Unix socket path, or "" when there is none.
+func (s *Server) SocketPath() string {
+	if s.socket == nil {
+		return ""
+	}
+	return s.socket.Addr().String()
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
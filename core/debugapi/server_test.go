@@ -1,6 +1,7 @@
 package debugapi
 
 import (
+	"errors"
 	"io"
 	"net"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"singbox-launcher/api"
+	"singbox-launcher/core/events"
 	"singbox-launcher/core/state"
 	"singbox-launcher/core/template"
 )
@@ -36,6 +38,34 @@ type fakeFacade struct {
 	logLevelErr   error
 	applyLevelErr error
 	appliedLevel  string
+
+	// connections surface
+	conns        []api.ConnectionInfo
+	connsErr     error
+	closedIDs    []string
+	closedFilter *api.ConnectionFilter
+
+	// GET /events
+	bus events.Bus
+
+	// POST /state/sources/{id}/refresh
+	refreshedIDs []string
+	refreshErr   error
+}
+
+func (f *fakeFacade) EventBus() events.Bus { return f.bus }
+
+func (f *fakeFacade) RefreshSource(id string) (*state.Source, error) {
+	f.refreshedIDs = append(f.refreshedIDs, id)
+	if f.refreshErr != nil {
+		return nil, f.refreshErr
+	}
+	src := f.stateValue.FindSource(id)
+	if src == nil {
+		return nil, errors.New("source not found")
+	}
+	src.Meta = &state.SubscriptionMeta{LastStatus: "ok"}
+	return src, nil
 }
 
 func (f *fakeFacade) IsRunning() bool                     { return f.running }
@@ -91,6 +121,32 @@ func (f *fakeFacade) ReadCurrentLogLevel() (string, bool, error) {
 	return f.logLevel, f.logLevelSet, f.logLevelErr
 }
 
+func (f *fakeFacade) ListConnections() ([]api.ConnectionInfo, error) {
+	return f.conns, f.connsErr
+}
+
+func (f *fakeFacade) CloseConnection(id string) error {
+	if f.connsErr != nil {
+		return f.connsErr
+	}
+	f.closedIDs = append(f.closedIDs, id)
+	return nil
+}
+
+func (f *fakeFacade) CloseConnections(filter api.ConnectionFilter) (int, error) {
+	if f.connsErr != nil {
+		return 0, f.connsErr
+	}
+	f.closedFilter = &filter
+	n := 0
+	for _, c := range f.conns {
+		if filter.Match(c) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 // freeLocalPort binds :0 then closes, returning the port. Good enough for
 // server-under-test tests on a dev box.
 func freeLocalPort(t *testing.T) int {
@@ -183,9 +183,9 @@ func TestSnapshot_CorruptJSON(t *testing.T) {
 	}
 }
 
-// TestSnapshot_NoRedaction — намеренный pin: секреты НЕ маскируются.
-// Если кто-то решит «добавить редакцию для безопасности» — тест упадёт.
-func TestSnapshot_NoRedaction(t *testing.T) {
+// TestSnapshot_Redacted — секреты в ответе замаскированы: снапшот
+// прикладывают к баг-репортам.
+func TestSnapshot_Redacted(t *testing.T) {
 	execDir := snapshotLayout(t)
 	cfg := `{"experimental":{"clash_api":{"secret":"deadbeef-secret"}},"outbounds":[{"type":"vless","password":"my-vless-pass","uuid":"abcd-uuid"}]}`
 	writeSnapshotFile(t, execDir, "config", []byte(cfg))
@@ -197,12 +197,12 @@ func TestSnapshot_NoRedaction(t *testing.T) {
 	}
 	got := string(resp.Files["config"])
 	for _, secret := range []string{"deadbeef-secret", "my-vless-pass", "abcd-uuid"} {
-		if !strings.Contains(got, secret) {
-			t.Errorf("expected raw secret %q in response, got: %s", secret, got)
+		if strings.Contains(got, secret) {
+			t.Errorf("secret %q must be redacted, got: %s", secret, got)
 		}
 	}
-	if strings.Contains(got, "REDACTED") {
-		t.Errorf("response must NOT contain 'REDACTED' marker (no-redaction policy): %s", got)
+	if !strings.Contains(got, `"type":"vless"`) {
+		t.Errorf("non-secret fields must survive: %s", got)
 	}
 }
 
@@ -0,0 +1,448 @@
+// Package debugapi — connection sources CRUD (state.Connections.Sources).
+//
+//	GET    /state/sources                      — all sources
+//	POST   /state/sources                      — add one (id generated if empty)
+//	GET    /state/sources/{source_id}          — one source
+//	PATCH  /state/sources/{source_id}          — merge top-level fields (null = clear)
+//	DELETE /state/sources/{source_id}          — remove
+//	POST   /state/sources/{source_id}/enable   — Enabled = true
+//	POST   /state/sources/{source_id}/disable  — Enabled = false
+//	POST   /state/sources/{source_id}/refresh  — fetch one subscription now
+//
+// Same contract as state_endpoints.go: validate → Save → respond, config.json
+// is not rebuilt (POST /action/rebuild-config). The local Save is
+// facade.SaveState — the dirty markers flip exactly as after the
+// Configurator's Save.
+//
+// Handlers edit Connections (canonical) and regenerate the legacy view before
+// Save: Save syncs legacy → canonical, so an edit made on Connections alone
+// would be overwritten by the stale legacy view (see saveConnections).
+package debugapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"singbox-launcher/core/config/subscription"
+	"singbox-launcher/core/state"
+)
+
+// fieldError — semantic validation failure (422) naming the offending field.
+type fieldError struct {
+	field string
+	msg   string
+}
+
+func (e *fieldError) Error() string { return e.msg }
+
+func fieldErr(field, format string, args ...any) *fieldError {
+	return &fieldError{field: field, msg: fmt.Sprintf(format, args...)}
+}
+
+func writeFieldError(w http.ResponseWriter, fe *fieldError) {
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": fe.msg, "field": fe.field})
+}
+
+// saveConnections — Save after an edit of st.Connections. The legacy view is
+// rebuilt first: Save derives Connections from it (syncConnectionsFromLegacy),
+// and the view loaded with st still describes the pre-edit sources.
+func saveConnections(acc stateAccess, st *state.State) error {
+	st.SyncLegacyFromConnections()
+	return acc.save(st)
+}
+
+// subscriptionOnlyFields — set fields a server source can't keep: its legacy
+// round-trip doesn't carry them, so accepting them would mean a silent drop on
+// Save.
+func subscriptionOnlyFields(src *state.Source) []string {
+	var out []string
+	if src.URL != "" {
+		out = append(out, "url")
+	}
+	if len(src.Skip) > 0 {
+		out = append(out, "skip")
+	}
+	if !src.Tag.IsZero() {
+		out = append(out, "tag")
+	}
+	if len(src.Outbounds) > 0 {
+		out = append(out, "outbounds")
+	}
+	if src.ExposeGroupTagsToGlobal {
+		out = append(out, "expose_group_tags_to_global")
+	}
+	if src.Update != nil {
+		out = append(out, "update")
+	}
+	if src.MaxNodes != 0 {
+		out = append(out, "max_nodes")
+	}
+	if len(src.DisabledNodes) > 0 {
+		out = append(out, "disabled_nodes")
+	}
+	return out
+}
+
+// validateSource checks one source against the schema and against the other
+// sources of the state (same id excluded). Duplicate URL/URI is rejected:
+// Save matches sources by URL/URI, so two of them would collapse into one id.
+func validateSource(src *state.Source, all []state.Source) *fieldError {
+	switch src.Type {
+	case state.SourceTypeSubscription:
+		if !subscription.IsSubscriptionURL(src.URL) {
+			return fieldErr("url", "subscription needs an http(s) url, got %q", src.URL)
+		}
+		if src.URI != "" || len(src.ConfigJSON) > 0 {
+			return fieldErr("uri", "uri/config_json apply to type=server only")
+		}
+		if src.MaxNodes < 0 {
+			return fieldErr("max_nodes", "max_nodes must be >= 0")
+		}
+		if src.Update != nil && src.Update.IntervalHours < 0 {
+			return fieldErr("update.interval_hours", "interval_hours must be >= 0")
+		}
+		for i, ob := range src.Outbounds {
+			if strings.TrimSpace(ob.Tag) == "" {
+				return fieldErr(fmt.Sprintf("outbounds[%d].tag", i), "outbound tag is empty")
+			}
+		}
+	case state.SourceTypeServer:
+		if src.URI == "" && len(src.ConfigJSON) == 0 {
+			return fieldErr("uri", "server needs a uri or config_json")
+		}
+		if src.URI != "" && !subscription.IsDirectLink(src.URI) {
+			return fieldErr("uri", "unsupported share link %q", src.URI)
+		}
+		if len(src.ConfigJSON) > 0 {
+			var obj map[string]any
+			if err := json.Unmarshal(src.ConfigJSON, &obj); err != nil || obj == nil {
+				return fieldErr("config_json", "config_json must be a JSON object")
+			}
+		}
+		if extra := subscriptionOnlyFields(src); len(extra) > 0 {
+			return fieldErr(extra[0], "%s applies to type=subscription only", extra[0])
+		}
+	default:
+		return fieldErr("type", "type must be %q or %q, got %q",
+			state.SourceTypeSubscription, state.SourceTypeServer, src.Type)
+	}
+	if src.DetourTag != "" && src.DetourNodeHash != "" {
+		return fieldErr("detour_tag", "detour_tag and detour_node_hash are mutually exclusive")
+	}
+	for _, o := range all {
+		if o.ID == src.ID {
+			continue
+		}
+		if src.Type == state.SourceTypeSubscription && o.Type == src.Type && o.URL == src.URL {
+			return fieldErr("url", "subscription %s already uses this url", o.ID)
+		}
+		if src.Type == state.SourceTypeServer && o.Type == src.Type && src.URI != "" && o.URI == src.URI {
+			return fieldErr("uri", "server %s already uses this uri", o.ID)
+		}
+	}
+	return nil
+}
+
+// normalizeSource trims what users paste with stray whitespace.
+func normalizeSource(src *state.Source) {
+	src.URL = strings.TrimSpace(src.URL)
+	src.URI = strings.TrimSpace(src.URI)
+	src.Label = strings.TrimSpace(src.Label)
+}
+
+func (s *Server) handleStateSources(w http.ResponseWriter, r *http.Request) {
+	s.stateSourcesWith(w, r, s.localStateAccess(r))
+}
+
+func (s *Server) stateSourcesWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
+	switch r.Method {
+	case http.MethodGet:
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"sources": st.Connections.Sources})
+
+	case http.MethodPost:
+		var src state.Source
+		if err := decodeJSONBody(r, &src); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if src.Meta != nil {
+			writeFieldError(w, fieldErr("meta", "meta is written by refresh, not by clients"))
+			return
+		}
+		normalizeSource(&src)
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+			return
+		}
+		if src.ID == "" {
+			src.ID = state.MakeULID()
+		} else if st.FindSource(src.ID) != nil {
+			writeFieldError(w, fieldErr("id", "source %s already exists", src.ID))
+			return
+		}
+		if fe := validateSource(&src, st.Connections.Sources); fe != nil {
+			writeFieldError(w, fe)
+			return
+		}
+		st.Connections.Sources = append(st.Connections.Sources, src)
+		if err := saveConnections(acc, st); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{
+			"ok":           true,
+			"source":       src,
+			"diff_summary": []string{fmt.Sprintf("sources: add %s %s", src.Type, src.ID)},
+		})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or POST required"})
+	}
+}
+
+func (s *Server) handleStateSourceByID(w http.ResponseWriter, r *http.Request) {
+	s.stateSourceByIDWith(w, r, s.localStateAccess(r))
+}
+
+func (s *Server) stateSourceByIDWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
+	id := strings.TrimSpace(pathParam(r, "source_id"))
+	switch r.Method {
+	case http.MethodGet:
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		src := st.FindSource(id)
+		if src == nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown source %q", id)})
+			return
+		}
+		writeJSON(w, http.StatusOK, src)
+
+	case http.MethodPatch:
+		var patch map[string]json.RawMessage
+		if err := decodeJSONBody(r, &patch); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if len(patch) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "empty patch"})
+			return
+		}
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+			return
+		}
+		cur := st.FindSource(id)
+		if cur == nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown source %q", id)})
+			return
+		}
+		next, fe, err := mergeSourcePatch(*cur, patch)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if fe == nil {
+			normalizeSource(&next)
+			fe = validateSource(&next, st.Connections.Sources)
+		}
+		if fe != nil {
+			writeFieldError(w, fe)
+			return
+		}
+		*cur = next
+		if err := saveConnections(acc, st); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+			return
+		}
+		keys := make([]string, 0, len(patch))
+		for k := range patch {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok":           true,
+			"source":       next,
+			"diff_summary": []string{fmt.Sprintf("sources: patch %s (%s)", id, strings.Join(keys, ", "))},
+		})
+
+	case http.MethodDelete:
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+			return
+		}
+		kept := st.Connections.Sources[:0]
+		found := false
+		for _, src := range st.Connections.Sources {
+			if src.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, src)
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown source %q", id)})
+			return
+		}
+		st.Connections.Sources = kept
+		if err := saveConnections(acc, st); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ok":           true,
+			"diff_summary": []string{fmt.Sprintf("sources: delete %s", id)},
+		})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET, PATCH or DELETE required"})
+	}
+}
+
+// mergeSourcePatch applies a top-level JSON merge: keys present in patch
+// replace the source's, null clears them. id and type are immutable (delete +
+// add to change a type); meta belongs to refresh.
+func mergeSourcePatch(cur state.Source, patch map[string]json.RawMessage) (state.Source, *fieldError, error) {
+	for _, k := range []string{"id", "type"} {
+		raw, ok := patch[k]
+		if !ok {
+			continue
+		}
+		var v string
+		_ = json.Unmarshal(raw, &v)
+		if (k == "id" && v != cur.ID) || (k == "type" && v != string(cur.Type)) {
+			return cur, fieldErr(k, "%s is immutable", k), nil
+		}
+	}
+	if _, ok := patch["meta"]; ok {
+		return cur, fieldErr("meta", "meta is written by refresh, not by clients"), nil
+	}
+	data, err := json.Marshal(cur)
+	if err != nil {
+		return cur, nil, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return cur, nil, err
+	}
+	for k, v := range patch {
+		if string(v) == "null" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	data, err = json.Marshal(merged)
+	if err != nil {
+		return cur, nil, err
+	}
+	var next state.Source
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&next); err != nil {
+		return cur, nil, err
+	}
+	return next, nil, nil
+}
+
+func (s *Server) handleStateSourceEnable(w http.ResponseWriter, r *http.Request) {
+	s.stateSourceSetEnabledWith(w, r, s.localStateAccess(r), true)
+}
+
+func (s *Server) handleStateSourceDisable(w http.ResponseWriter, r *http.Request) {
+	s.stateSourceSetEnabledWith(w, r, s.localStateAccess(r), false)
+}
+
+func (s *Server) stateSourceSetEnabledWith(w http.ResponseWriter, r *http.Request, acc stateAccess, enabled bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id := strings.TrimSpace(pathParam(r, "source_id"))
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	st, err := acc.load()
+	if err != nil {
+		writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+		return
+	}
+	src := st.FindSource(id)
+	if src == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown source %q", id)})
+		return
+	}
+	if src.Enabled == enabled {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "diff_summary": []string{}})
+		return
+	}
+	src.Enabled = enabled
+	if err := saveConnections(acc, st); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+		return
+	}
+	verb := "disable"
+	if enabled {
+		verb = "enable"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":           true,
+		"diff_summary": []string{fmt.Sprintf("sources: %s %s", verb, id)},
+	})
+}
+
+// handleStateSourceRefresh — POST /state/sources/{source_id}/refresh: the
+// per-row Refresh of the Configurator (fetch + meta + raw cache for one
+// subscription). Local only — a machine profile's subscriptions are fetched
+// by its own build. stateMu is held so a concurrent PATCH doesn't interleave
+// with the refresh's own load-save.
+func (s *Server) handleStateSourceRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "POST required"})
+		return
+	}
+	id := strings.TrimSpace(pathParam(r, "source_id"))
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	st, err := s.facade.LoadState()
+	if err != nil {
+		writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+		return
+	}
+	src := st.FindSource(id)
+	if src == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": fmt.Sprintf("unknown source %q", id)})
+		return
+	}
+	if src.Type != state.SourceTypeSubscription {
+		writeFieldError(w, fieldErr("type", "only subscriptions can be refreshed (source %s is %s)", id, src.Type))
+		return
+	}
+	// A failed fetch is not an error here: it lands in source.meta
+	// (last_status/last_error), as in the Configurator row.
+	updated, err := s.facadeFor(r).RefreshSource(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "source": updated})
+}
@@ -0,0 +1,179 @@
+package debugapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"singbox-launcher/core/state"
+)
+
+func sourcesState() *state.State {
+	st := state.New()
+	st.Connections.Sources = []state.Source{
+		{ID: "sub-1", Type: state.SourceTypeSubscription, Enabled: true, URL: "https://example.invalid/sub", MaxNodes: 5},
+		{ID: "srv-1", Type: state.SourceTypeServer, Enabled: true, URI: "vless://u@h:443#a", Label: "a"},
+	}
+	return st
+}
+
+func TestStateSourcesAddValidates(t *testing.T) {
+	ff := &fakeFacade{stateValue: sourcesState()}
+	base, _ := newTestServer(t, ff)
+
+	cases := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantField string
+	}{
+		{"subscription", `{"type":"subscription","enabled":true,"url":" https://example.invalid/new "}`, 201, ""},
+		{"server", `{"type":"server","enabled":true,"uri":"trojan://p@h2:443#b"}`, 201, ""},
+		{"manual_json", `{"type":"server","enabled":true,"config_json":{"type":"socks","server":"h","server_port":1080}}`, 201, ""},
+		{"duplicate_url", `{"type":"subscription","url":"https://example.invalid/sub"}`, 422, "url"},
+		{"bad_type", `{"type":"file","url":"https://x"}`, 422, "type"},
+		{"server_with_skip", `{"type":"server","uri":"ss://x@h:1#c","skip":[{"tag":"x"}]}`, 422, "skip"},
+		{"server_without_link", `{"type":"server"}`, 422, "uri"},
+		{"meta_is_readonly", `{"type":"subscription","url":"https://example.invalid/m","meta":{"last_status":"ok"}}`, 422, "meta"},
+		{"existing_id", `{"id":"sub-1","type":"subscription","url":"https://example.invalid/x"}`, 422, "id"},
+		{"unknown_field", `{"type":"subscription","url":"https://example.invalid/y","urls":[]}`, 400, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got map[string]any
+			status, raw := doJSON(t, authedReq(t, "POST", base+"/state/sources", []byte(c.body)), &got)
+			if status != c.wantCode {
+				t.Fatalf("status %d, want %d: %s", status, c.wantCode, raw)
+			}
+			if c.wantField != "" && got["field"] != c.wantField {
+				t.Fatalf("field = %v, want %s", got["field"], c.wantField)
+			}
+		})
+	}
+
+	srcs := ff.savedState.Connections.Sources
+	if len(srcs) != 5 {
+		t.Fatalf("sources = %d, want 5", len(srcs))
+	}
+	if srcs[2].ID == "" || srcs[2].URL != "https://example.invalid/new" {
+		t.Fatalf("added subscription = %+v", srcs[2])
+	}
+	// Legacy view is rebuilt, so Save's legacy → canonical sync keeps the edit.
+	if n := len(ff.savedState.ParserConfig.ParserConfig.Proxies); n != 5 {
+		t.Fatalf("legacy proxies = %d, want 5", n)
+	}
+}
+
+func TestStateSourceByIDPatchEnableDelete(t *testing.T) {
+	ff := &fakeFacade{stateValue: sourcesState()}
+	base, _ := newTestServer(t, ff)
+
+	var got map[string]any
+	status, raw := doJSON(t, authedReq(t, "PATCH", base+"/state/sources/sub-1",
+		[]byte(`{"label":"Main","max_nodes":null,"update":{"interval_hours":6}}`)), &got)
+	if status != 200 {
+		t.Fatalf("PATCH: status %d: %s", status, raw)
+	}
+	src := ff.savedState.FindSource("sub-1")
+	if src.Label != "Main" || src.MaxNodes != 0 || src.Update == nil || src.Update.IntervalHours != 6 || src.URL == "" {
+		t.Fatalf("patched source = %+v", src)
+	}
+
+	for body, field := range map[string]string{
+		`{"type":"server"}`:         "type",
+		`{"id":"other"}`:            "id",
+		`{"url":"ftp://x"}`:         "url",
+		`{"meta":{}}`:               "meta",
+		`{"uri":"vless://u@h:443"}`: "uri",
+	} {
+		got = nil
+		if status, raw := doJSON(t, authedReq(t, "PATCH", base+"/state/sources/sub-1", []byte(body)), &got); status != 422 || got["field"] != field {
+			t.Fatalf("PATCH %s: status %d field %v, want 422 %s: %s", body, status, got["field"], field, raw)
+		}
+	}
+
+	if status, raw := doJSON(t, authedReq(t, "POST", base+"/state/sources/srv-1/disable", nil), nil); status != 200 {
+		t.Fatalf("disable: status %d: %s", status, raw)
+	}
+	if ff.savedState.FindSource("srv-1").Enabled {
+		t.Fatal("srv-1 still enabled")
+	}
+	if !ff.savedState.ParserConfig.ParserConfig.Proxies[1].Disabled {
+		t.Fatal("legacy view not synced on disable")
+	}
+
+	if status, raw := doJSON(t, authedReq(t, "DELETE", base+"/state/sources/srv-1", nil), nil); status != 200 {
+		t.Fatalf("DELETE: status %d: %s", status, raw)
+	}
+	if ff.savedState.FindSource("srv-1") != nil {
+		t.Fatal("srv-1 not deleted")
+	}
+	for _, m := range []string{"GET", "DELETE"} {
+		if status, _ := doJSON(t, authedReq(t, m, base+"/state/sources/srv-1", nil), nil); status != 404 {
+			t.Fatalf("%s deleted source: status %d, want 404", m, status)
+		}
+	}
+}
+
+func TestStateSourceRefresh(t *testing.T) {
+	ff := &fakeFacade{stateValue: sourcesState()}
+	base, _ := newTestServer(t, ff)
+
+	var got map[string]any
+	if status, raw := doJSON(t, authedReq(t, "POST", base+"/state/sources/srv-1/refresh", nil), &got); status != 422 {
+		t.Fatalf("refresh server: status %d, want 422: %s", status, raw)
+	}
+	if status, _ := doJSON(t, authedReq(t, "POST", base+"/state/sources/nope/refresh", nil), nil); status != 404 {
+		t.Fatalf("refresh unknown: status %d, want 404", status)
+	}
+	status, raw := doJSON(t, authedReq(t, "POST", base+"/state/sources/sub-1/refresh", nil), &got)
+	if status != 200 || !strings.Contains(string(raw), `"last_status":"ok"`) {
+		t.Fatalf("refresh: status %d: %s", status, raw)
+	}
+	if len(ff.refreshedIDs) != 1 || ff.refreshedIDs[0] != "sub-1" {
+		t.Fatalf("refreshed = %v", ff.refreshedIDs)
+	}
+}
+
+// Через настоящий state.Save: правка Connections не должна перетираться
+// legacy-view, загруженным вместе со state, а отметки выключенных нод —
+// теряться на круге Load → Save.
+func TestStateSourcesSurviveRealSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st := sourcesState()
+	st.Connections.Sources[0].DisabledNodes = map[string]int64{"aaaabbbbccccdddd": 1754400000}
+	if err := st.Save(path); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	acc := stateAccess{
+		load: func() (*state.State, error) { return state.Load(path) },
+		save: func(s *state.State) error { return s.Save(path) },
+		mu:   &sync.Mutex{},
+	}
+	s := &Server{}
+
+	req := httptest.NewRequest("POST", "/state/sources",
+		strings.NewReader(`{"id":"sub-2","type":"subscription","enabled":true,"url":"https://example.invalid/two","label":"Two"}`))
+	rec := httptest.NewRecorder()
+	s.stateSourcesWith(rec, req, acc)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body.String())
+	}
+
+	got, err := state.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got.Connections.Sources) != 3 {
+		t.Fatalf("sources = %+v", got.Connections.Sources)
+	}
+	if two := got.FindSource("sub-2"); two == nil || two.Label != "Two" {
+		t.Fatalf("added source lost its id/label: %+v", got.Connections.Sources)
+	}
+	if one := got.FindSource("sub-1"); one == nil || one.MaxNodes != 5 || len(one.DisabledNodes) != 1 {
+		t.Fatalf("untouched source changed: %+v", one)
+	}
+}
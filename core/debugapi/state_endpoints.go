@@ -48,16 +48,17 @@ type stateAccess struct {
 }
 
 // localStateAccess — доступ к state.json локального визарда через facade
-// (Save взводит dirty-маркеры StateService — SPEC 050 invariant 3).
-func (s *Server) localStateAccess() stateAccess {
-	return stateAccess{load: s.facade.LoadState, save: s.facade.SaveState, mu: &s.stateMu}
+// (Save взводит dirty-маркеры StateService — SPEC 050 invariant 3 — и
+// пишет изменение в журнал действий на токен запроса).
+func (s *Server) localStateAccess(r *http.Request) stateAccess {
+	return stateAccess{load: s.facade.LoadState, save: s.facadeFor(r).SaveState, mu: &s.stateMu}
 }
 
 // handleStateFull — GET /state/full. Returns the full in-memory State as
 // JSON. We marshal via State directly so the response includes ALL
 // post-SPEC-060 fields (Rules, DNS, Connections.Outbounds with Ref/Updates).
 func (s *Server) handleStateFull(w http.ResponseWriter, r *http.Request) {
-	s.stateFullWith(w, r, s.localStateAccess())
+	s.stateFullWith(w, r, s.localStateAccess(r))
 }
 
 func (s *Server) stateFullWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
@@ -91,7 +92,7 @@ type patchRulesReq struct {
 }
 
 func (s *Server) handleStateRules(w http.ResponseWriter, r *http.Request) {
-	s.stateRulesWith(w, r, s.localStateAccess())
+	s.stateRulesWith(w, r, s.localStateAccess(r))
 }
 
 func (s *Server) stateRulesWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
@@ -159,7 +160,7 @@ func (s *Server) stateRulesWith(w http.ResponseWriter, r *http.Request, acc stat
 // We don't merge — mirrors PUT /state/dns/servers semantics from SPEC 050.
 // Callers wanting field-level edits should GET → mutate → PATCH.
 func (s *Server) handleStateDNS(w http.ResponseWriter, r *http.Request) {
-	s.stateDNSWith(w, r, s.localStateAccess())
+	s.stateDNSWith(w, r, s.localStateAccess(r))
 }
 
 func (s *Server) stateDNSWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
@@ -267,7 +268,7 @@ type patchDNSRulesReq struct {
 //
 // SPEC 050 контракт: bad JSON → 400, парсинг текста → 422, save → 200.
 func (s *Server) handleStateDNSRules(w http.ResponseWriter, r *http.Request) {
-	s.stateDNSRulesWith(w, r, s.localStateAccess())
+	s.stateDNSRulesWith(w, r, s.localStateAccess(r))
 }
 
 func (s *Server) stateDNSRulesWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
@@ -366,7 +367,7 @@ func (s *Server) stateDNSRulesWith(w http.ResponseWriter, r *http.Request, acc s
 // view. Template load failures map to 500 (no usable template = nothing
 // meaningful to resolve against).
 func (s *Server) handleStateOutboundsResolved(w http.ResponseWriter, r *http.Request) {
-	s.stateOutboundsResolvedWith(w, r, s.localStateAccess())
+	s.stateOutboundsResolvedWith(w, r, s.localStateAccess(r))
 }
 
 func (s *Server) stateOutboundsResolvedWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
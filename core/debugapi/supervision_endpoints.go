@@ -0,0 +1,146 @@
+// Package debugapi — присмотр за ядром (core/supervision.go): политика
+// перезапусков профиля, проверка живости и журнал решений.
+//
+// Группа включается wiring'ом через EnableSupervision; фасад реализует core.
+// Политика — это state.SupervisionPolicy как есть (тот же JSON, что в
+// state.json), остальное — зеркала типов core. capabilities.supervision в
+// манифесте говорит агенту, есть ли группа в этой сессии.
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/state"
+)
+
+// ErrSupervisionNoProfile — state.json ещё нет, политику некуда сохранить
+// (409).
+var ErrSupervisionNoProfile = errors.New("no profile state yet")
+
+// SupervisionView — политика профиля и как она сейчас действует.
+type SupervisionView struct {
+	// Policy — state.supervision как сохранено; null — встроенная.
+	Policy *state.SupervisionPolicy `json:"policy"`
+	// Effective — та же схема с подставленными встроенными значениями;
+	// probe null — проверка выключена.
+	Effective state.SupervisionPolicy `json:"effective"`
+	Attempts  int                     `json:"attempts"`
+	Running   bool                    `json:"running"`
+	// Supported — false в daemon-режиме: перезапусками ведает демон.
+	Supported bool                  `json:"supported"`
+	LastProbe *SupervisionProbeView `json:"last_probe"`
+}
+
+// SupervisionProbeView — последний результат проверки живости.
+type SupervisionProbeView struct {
+	At       string `json:"at"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Failures int    `json:"failures"`
+}
+
+// SupervisionEventView — одна запись журнала присмотра.
+type SupervisionEventView struct {
+	At          string `json:"at"`
+	Kind        string `json:"kind"`
+	Attempt     int    `json:"attempt,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+	DelayMs     int64  `json:"delay_ms,omitempty"`
+	PID         int    `json:"pid,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+}
+
+// SupervisionFacade — что группе нужно от присмотра за ядром.
+type SupervisionFacade interface {
+	Status() SupervisionView
+	// SetPolicy сохраняет политику (nil — вернуть встроенную). Валидацию
+	// делает обработчик до вызова; actor попадает в журнал действий.
+	SetPolicy(actor audit.Actor, p *state.SupervisionPolicy) error
+	// Log — последние limit событий, старые первыми.
+	Log(limit int) ([]SupervisionEventView, error)
+}
+
+// EnableSupervision turns the /supervision endpoint group on. Call before Start.
+func (s *Server) EnableSupervision(f SupervisionFacade) { s.supervision = f }
+
+// supervisionEndpoints — таблица группы /supervision.
+func (s *Server) supervisionEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{"GET", "/supervision", true, "Core supervision: policy, effective values, crash counter, last health probe", s.handleSupervision},
+		{"PUT/DELETE", "/supervision/policy", true, "Replace the profile's supervision policy / reset it to built-in defaults", s.handleSupervisionPolicy},
+		{"GET", "/supervision/log", true, "Supervision decisions log (?limit=N, default 100)", s.handleSupervisionLog},
+	}
+}
+
+func (s *Server) handleSupervision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.supervision.Status())
+}
+
+func (s *Server) handleSupervisionPolicy(w http.ResponseWriter, r *http.Request) {
+	var p *state.SupervisionPolicy
+	switch r.Method {
+	case http.MethodPut:
+		p = &state.SupervisionPolicy{}
+		if err := decodeJSONBody(r, p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if err := p.Validate(); err != nil {
+			var fe *state.SupervisionFieldError
+			if errors.As(err, &fe) {
+				writeFieldError(w, fieldErr(fe.Field, "%s", fe.Message))
+				return
+			}
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+			return
+		}
+	case http.MethodDelete:
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "PUT or DELETE required"})
+		return
+	}
+	if err := s.supervision.SetPolicy(actorOf(r), p); err != nil {
+		if errors.Is(err, ErrSupervisionNoProfile) {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.supervision.Status())
+}
+
+func (s *Server) handleSupervisionLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET required"})
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeFieldError(w, fieldErr("limit", "limit must be a positive integer"))
+			return
+		}
+		if n > 1000 {
+			n = 1000
+		}
+		limit = n
+	}
+	events, err := s.supervision.Log(limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	if events == nil {
+		events = []SupervisionEventView{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": events, "count": len(events)})
+}
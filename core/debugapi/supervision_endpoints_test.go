@@ -0,0 +1,102 @@
+package debugapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/state"
+)
+
+// fakeSupervision — SupervisionFacade над политикой в памяти.
+type fakeSupervision struct {
+	policy  *state.SupervisionPolicy
+	noState bool
+}
+
+func (f *fakeSupervision) Status() SupervisionView {
+	eff := state.SupervisionPolicy{MaxAttempts: 3}
+	if f.policy != nil && f.policy.MaxAttempts > 0 {
+		eff.MaxAttempts = f.policy.MaxAttempts
+	}
+	return SupervisionView{Policy: f.policy, Effective: eff, Supported: true}
+}
+func (f *fakeSupervision) SetPolicy(_ audit.Actor, p *state.SupervisionPolicy) error {
+	if f.noState {
+		return ErrSupervisionNoProfile
+	}
+	f.policy = p
+	return nil
+}
+func (f *fakeSupervision) Log(limit int) ([]SupervisionEventView, error) {
+	events := []SupervisionEventView{{Kind: "crash", Attempt: 1}, {Kind: "restart", Attempt: 1, DelayMs: 2000}}
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, nil
+}
+
+func TestSupervisionGroupContract(t *testing.T) {
+	fs := &fakeSupervision{}
+	port := freeLocalPort(t)
+	s, err := New(&fakeFacade{}, port, "remote-test-token")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.EnableSupervision(fs)
+	s.Start()
+	t.Cleanup(s.Stop)
+	base := "http://127.0.0.1:" + itoa(port)
+
+	_, body := authDo(t, http.MethodGet, base+"/", nil)
+	var manifest struct {
+		Capabilities map[string]bool `json:"capabilities"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil || !manifest.Capabilities["supervision"] {
+		t.Fatalf("capabilities = %v, %v", manifest.Capabilities, err)
+	}
+
+	for _, c := range []struct {
+		method, path string
+		body         any
+		want         int
+	}{
+		{http.MethodGet, "/supervision", nil, http.StatusOK},
+		{http.MethodPut, "/supervision/policy", map[string]any{"max_attempts": 0, "backoff_multiplier": 0.2}, http.StatusUnprocessableEntity},
+		{http.MethodPut, "/supervision/policy", map[string]any{"probe": map[string]any{"kind": "icmp"}}, http.StatusUnprocessableEntity},
+		{http.MethodPut, "/supervision/policy", map[string]any{"max_attempts": 5, "probe": map[string]any{"kind": "clash_api"}}, http.StatusOK},
+		{http.MethodPost, "/supervision/policy", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/supervision/log?limit=0", nil, http.StatusUnprocessableEntity},
+	} {
+		resp, body := authDo(t, c.method, base+c.path, c.body)
+		if resp.StatusCode != c.want {
+			t.Errorf("%s %s: %d %s, want %d", c.method, c.path, resp.StatusCode, body, c.want)
+		}
+	}
+	if fs.policy == nil || fs.policy.MaxAttempts != 5 || fs.policy.Probe == nil {
+		t.Errorf("policy = %+v", fs.policy)
+	}
+
+	_, body = authDo(t, http.MethodGet, base+"/supervision", nil)
+	var view SupervisionView
+	if err := json.Unmarshal(body, &view); err != nil || view.Effective.MaxAttempts != 5 || view.Policy == nil {
+		t.Errorf("status = %s (%v)", body, err)
+	}
+
+	_, body = authDo(t, http.MethodGet, base+"/supervision/log?limit=1", nil)
+	var log struct {
+		Events []SupervisionEventView `json:"events"`
+	}
+	if err := json.Unmarshal(body, &log); err != nil || len(log.Events) != 1 || log.Events[0].Kind != "restart" {
+		t.Errorf("log = %s (%v)", body, err)
+	}
+
+	if resp, body := authDo(t, http.MethodDelete, base+"/supervision/policy", nil); resp.StatusCode != http.StatusOK || fs.policy != nil {
+		t.Errorf("DELETE: %d %s, policy %+v", resp.StatusCode, body, fs.policy)
+	}
+	fs.noState = true
+	if resp, body := authDo(t, http.MethodPut, base+"/supervision/policy", map[string]any{}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("no state: %d %s", resp.StatusCode, body)
+	}
+}
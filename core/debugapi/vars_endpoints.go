@@ -0,0 +1,176 @@
+// Package debugapi — template vars (state.Vars, the Configurator's Settings
+// tab).
+//
+//	GET   /state/vars  — {vars: {name: value}, declared: [{name, type, options}]}
+//	PATCH /state/vars  — body {vars: {name: "value" | null}}; null drops the
+//	                     override, the template default applies again
+//
+// Names are checked against the template's declared vars; bool vars take
+// "true"/"false", enum vars one of their options. Vars fixed by the template
+// (wizard_ui "fix") are refused — the Settings tab doesn't edit them either.
+package debugapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"singbox-launcher/core/state"
+	"singbox-launcher/core/template"
+)
+
+// patchVarsReq — body for PATCH /state/vars.
+type patchVarsReq struct {
+	Vars map[string]*string `json:"vars"`
+}
+
+// declaredVar — a template var as listed by GET /state/vars.
+type declaredVar struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Title   string   `json:"title,omitempty"`
+	Options []string `json:"options,omitempty"`
+	Fixed   bool     `json:"fixed,omitempty"`
+}
+
+func declaredVars(td *template.TemplateData) []declaredVar {
+	var out []declaredVar
+	for _, v := range td.Vars {
+		if v.Separator || v.Name == "" {
+			continue
+		}
+		out = append(out, declaredVar{
+			Name:    v.Name,
+			Type:    v.Type,
+			Title:   v.Title,
+			Options: v.Options,
+			Fixed:   v.WizardUI == "fix",
+		})
+	}
+	return out
+}
+
+// validateVar checks one value against the template declaration.
+func validateVar(td *template.TemplateData, name, value string) *fieldError {
+	field := "vars." + name
+	v, ok := template.VarByName(td.Vars, name)
+	if !ok {
+		return fieldErr(field, "template declares no var %q", name)
+	}
+	if v.WizardUI == "fix" {
+		return fieldErr(field, "var %q is fixed by the template", name)
+	}
+	switch v.Type {
+	case "bool":
+		if value != "true" && value != "false" {
+			return fieldErr(field, "bool var %q takes \"true\" or \"false\", got %q", name, value)
+		}
+	case "enum":
+		if len(v.Options) == 0 {
+			break
+		}
+		for _, o := range v.Options {
+			if o == value {
+				return nil
+			}
+		}
+		return fieldErr(field, "var %q takes one of %s, got %q", name, strings.Join(v.Options, ", "), value)
+	}
+	return nil
+}
+
+func (s *Server) handleStateVars(w http.ResponseWriter, r *http.Request) {
+	s.stateVarsWith(w, r, s.localStateAccess(r))
+}
+
+func (s *Server) stateVarsWith(w http.ResponseWriter, r *http.Request, acc stateAccess) {
+	switch r.Method {
+	case http.MethodGet:
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": err.Error()})
+			return
+		}
+		vars := make(map[string]string, len(st.Vars))
+		for _, v := range st.Vars {
+			vars[v.Name] = v.Value
+		}
+		resp := map[string]any{"vars": vars}
+		// The declaration list is a convenience: without a template the
+		// overrides are still worth returning.
+		if td, err := s.facade.LoadTemplate(); err == nil {
+			resp["declared"] = declaredVars(td)
+		}
+		writeJSON(w, http.StatusOK, resp)
+
+	case http.MethodPatch:
+		var req patchVarsReq
+		if err := decodeJSONBody(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if len(req.Vars) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "vars is empty"})
+			return
+		}
+		td, err := s.facade.LoadTemplate()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "load template: " + err.Error()})
+			return
+		}
+		names := make([]string, 0, len(req.Vars))
+		for name := range req.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if val := req.Vars[name]; val != nil {
+				if fe := validateVar(td, name, *val); fe != nil {
+					writeFieldError(w, fe)
+					return
+				}
+			}
+		}
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		st, err := acc.load()
+		if err != nil {
+			writeJSON(w, stateErrStatus(err), map[string]any{"error": "load state: " + err.Error()})
+			return
+		}
+		var diff []string
+		for _, name := range names {
+			diff = append(diff, setStateVar(st, name, req.Vars[name]))
+		}
+		if err := acc.save(st); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "save state: " + err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "diff_summary": diff})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "GET or PATCH required"})
+	}
+}
+
+// setStateVar sets (value != nil) or drops one override, keeping the order of
+// the rest; returns the diff line.
+func setStateVar(st *state.State, name string, value *string) string {
+	for i := range st.Vars {
+		if st.Vars[i].Name != name {
+			continue
+		}
+		if value == nil {
+			st.Vars = append(st.Vars[:i], st.Vars[i+1:]...)
+			return fmt.Sprintf("vars: %s reset to template default", name)
+		}
+		st.Vars[i].Value = *value
+		return fmt.Sprintf("vars: %s = %q", name, *value)
+	}
+	if value == nil {
+		return fmt.Sprintf("vars: %s already default", name)
+	}
+	st.Vars = append(st.Vars, state.SettingVar{Name: name, Value: *value})
+	return fmt.Sprintf("vars: %s = %q", name, *value)
+}
@@ -0,0 +1,67 @@
+package debugapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"singbox-launcher/core/state"
+	"singbox-launcher/core/template"
+)
+
+func varsTemplate(t *testing.T) *template.TemplateData {
+	t.Helper()
+	var vars []template.TemplateVar
+	raw := `[
+		{"name":"tun","type":"bool","wizard_ui":"edit"},
+		{"separator":true},
+		{"name":"log_level","type":"enum","options":["warn","info","debug"],"wizard_ui":"edit"},
+		{"name":"dns_final","type":"text","wizard_ui":"edit"},
+		{"name":"api_port","type":"text","wizard_ui":"fix"}
+	]`
+	if err := json.Unmarshal([]byte(raw), &vars); err != nil {
+		t.Fatalf("vars: %v", err)
+	}
+	return &template.TemplateData{Vars: vars}
+}
+
+func TestStateVarsGetAndPatch(t *testing.T) {
+	st := state.New()
+	st.Vars = []state.SettingVar{{Name: "tun", Value: "true"}, {Name: "dns_final", Value: "local"}}
+	ff := &fakeFacade{stateValue: st, templateValue: varsTemplate(t)}
+	base, _ := newTestServer(t, ff)
+
+	var got struct {
+		Vars     map[string]string `json:"vars"`
+		Declared []declaredVar     `json:"declared"`
+	}
+	if status, raw := doJSON(t, authedReq(t, "GET", base+"/state/vars", nil), &got); status != 200 {
+		t.Fatalf("GET: status %d: %s", status, raw)
+	}
+	if got.Vars["tun"] != "true" || len(got.Declared) != 4 || !got.Declared[3].Fixed || len(got.Declared[1].Options) != 3 {
+		t.Fatalf("GET = %+v", got)
+	}
+
+	for body, field := range map[string]string{
+		`{"vars":{"tun":"yes"}}`:         "vars.tun",
+		`{"vars":{"log_level":"trace"}}`: "vars.log_level",
+		`{"vars":{"nope":"1"}}`:          "vars.nope",
+		`{"vars":{"api_port":"1"}}`:      "vars.api_port",
+	} {
+		var e map[string]any
+		if status, raw := doJSON(t, authedReq(t, "PATCH", base+"/state/vars", []byte(body)), &e); status != 422 || e["field"] != field {
+			t.Fatalf("PATCH %s: status %d: %s", body, status, raw)
+		}
+	}
+	if ff.savedState != nil {
+		t.Fatal("state saved on a rejected patch")
+	}
+
+	body := `{"vars":{"tun":"false","log_level":"debug","dns_final":null}}`
+	if status, raw := doJSON(t, authedReq(t, "PATCH", base+"/state/vars", []byte(body)), nil); status != 200 {
+		t.Fatalf("PATCH: status %d: %s", status, raw)
+	}
+	want := []state.SettingVar{{Name: "tun", Value: "false"}, {Name: "log_level", Value: "debug"}}
+	if gotVars := ff.savedState.Vars; len(gotVars) != 2 || gotVars[0] != want[0] || gotVars[1] != want[1] {
+		t.Fatalf("vars = %+v, want %+v", gotVars, want)
+	}
+}
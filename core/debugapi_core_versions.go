@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/debugapi"
+)
+
+// debugAPICoreVersions — адаптер хранилища версий ядра к
+// debugapi.CoreVersionsFacade: свои типы и ошибки у debugapi из-за цикла
+// импортов, здесь они переводятся туда и обратно.
+type debugAPICoreVersions struct {
+	ac *AppController
+}
+
+func (f *debugAPICoreVersions) List() ([]debugapi.CoreVersionView, error) {
+	list, err := f.ac.ListCoreVersions()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]debugapi.CoreVersionView, 0, len(list))
+	for _, v := range list {
+		view := debugapi.CoreVersionView{
+			Version:        v.Version,
+			Active:         v.Active,
+			Previous:       v.Previous,
+			Pinned:         v.Pinned,
+			Trial:          v.Trial,
+			VerifiedBy:     v.VerifiedBy,
+			Tags:           v.Tags,
+			NaiveSupported: v.NaiveSupported,
+			NaiveReason:    v.NaiveReason,
+		}
+		if view.Tags == nil {
+			view.Tags = []string{}
+		}
+		if !v.InstalledAt.IsZero() {
+			view.InstalledAt = v.InstalledAt.UTC().Format(time.RFC3339)
+		}
+		out = append(out, view)
+	}
+	return out, nil
+}
+
+func (f *debugAPICoreVersions) StoreInUse() bool { return f.ac.CoreStoreInUse() }
+
+func (f *debugAPICoreVersions) LastFallback() *debugapi.CoreFallbackView {
+	fb, ok := f.ac.LastCoreFallback()
+	if !ok {
+		return nil
+	}
+	view := &debugapi.CoreFallbackView{From: fb.From, To: fb.To, Reason: fb.Reason}
+	if !fb.At.IsZero() {
+		view.At = fb.At.UTC().Format(time.RFC3339)
+	}
+	return view
+}
+
+// Install ждёт конца загрузки: прогресс API не нужен, важен итог — последняя
+// ошибка из канала.
+func (f *debugAPICoreVersions) Install(ctx context.Context, version string) error {
+	progress := make(chan DownloadProgress, 16)
+	go f.ac.InstallCoreVersion(ctx, version, progress)
+	var last error
+	for p := range progress {
+		if p.Error != nil {
+			last = p.Error
+		}
+	}
+	return coreVersionsAPIError(last)
+}
+
+func (f *debugAPICoreVersions) Activate(version string) error {
+	return coreVersionsAPIError(f.ac.ActivateCoreVersion(version))
+}
+
+func (f *debugAPICoreVersions) Remove(version string) error {
+	return coreVersionsAPIError(f.ac.RemoveCoreVersion(version))
+}
+
+// coreVersionsAPIError подменяет ошибки хранилища сентинелами debugapi,
+// сохраняя текст (в нём имя версии и подсказка).
+func coreVersionsAPIError(err error) error {
+	for _, m := range []struct{ core, api error }{
+		{ErrCoreVersionInvalid, debugapi.ErrCoreVersionInvalid},
+		{ErrCoreVersionNotInstalled, debugapi.ErrCoreVersionNotInstalled},
+		{ErrCoreVersionActive, debugapi.ErrCoreVersionActive},
+	} {
+		if errors.Is(err, m.core) {
+			return fmt.Errorf("%w%s", m.api, strings.TrimPrefix(err.Error(), m.core.Error()))
+		}
+	}
+	return err
+}
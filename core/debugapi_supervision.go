@@ -0,0 +1,64 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/debugapi"
+	"singbox-launcher/core/state"
+)
+
+// debugAPISupervision — адаптер присмотра за ядром к
+// debugapi.SupervisionFacade.
+type debugAPISupervision struct {
+	ac *AppController
+}
+
+func (f *debugAPISupervision) Status() debugapi.SupervisionView {
+	st := f.ac.SupervisionStatus()
+	view := debugapi.SupervisionView{
+		Policy:    st.Policy,
+		Effective: st.Effective.Policy(),
+		Attempts:  st.Attempts,
+		Running:   st.Running,
+		Supported: st.Supported,
+	}
+	if lp := st.LastProbe; lp != nil {
+		view.LastProbe = &debugapi.SupervisionProbeView{
+			At: lp.Time.UTC().Format(time.RFC3339), OK: lp.OK, Error: lp.Error, Failures: lp.Failures,
+		}
+	}
+	return view
+}
+
+func (f *debugAPISupervision) SetPolicy(actor audit.Actor, p *state.SupervisionPolicy) error {
+	err := f.ac.SetSupervisionPolicy(actor, p)
+	if errors.Is(err, ErrSupervisionNoProfile) {
+		return fmt.Errorf("%w%s", debugapi.ErrSupervisionNoProfile,
+			strings.TrimPrefix(err.Error(), "supervision: no profile state yet"))
+	}
+	return err
+}
+
+func (f *debugAPISupervision) Log(limit int) ([]debugapi.SupervisionEventView, error) {
+	events, err := f.ac.SupervisionLog(limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]debugapi.SupervisionEventView, 0, len(events))
+	for _, ev := range events {
+		out = append(out, debugapi.SupervisionEventView{
+			At:          ev.Time.UTC().Format(time.RFC3339),
+			Kind:        ev.Kind,
+			Attempt:     ev.Attempt,
+			MaxAttempts: ev.MaxAttempts,
+			DelayMs:     ev.DelayMs,
+			PID:         ev.PID,
+			Detail:      ev.Detail,
+		})
+	}
+	return out, nil
+}
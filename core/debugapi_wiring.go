@@ -2,15 +2,21 @@ package core
 
 import (
 	"errors"
+	"path/filepath"
 	"time"
 
 	"singbox-launcher/api"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/debugapi"
+	"singbox-launcher/core/events"
 	"singbox-launcher/core/services"
 	"singbox-launcher/core/state"
 	"singbox-launcher/core/template"
 	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
 	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
 )
 
 // debugAPIFacade adapts *AppController to debugapi.ControllerFacade.
@@ -18,6 +24,14 @@ import (
 // debugapi to avoid an import cycle.
 type debugAPIFacade struct {
 	ac *AppController
+	// actor — кому журнал действий припишет изменения (WithActor).
+	actor audit.Actor
+}
+
+// WithActor — копия фасада для одного запроса: изменения через неё
+// журнал действий запишет на токен запроса (debugapi.ActorFacade).
+func (f *debugAPIFacade) WithActor(actor audit.Actor) debugapi.ControllerFacade {
+	return &debugAPIFacade{ac: f.ac, actor: actor}
 }
 
 func (f *debugAPIFacade) IsRunning() bool {
@@ -75,12 +89,12 @@ func (f *debugAPIFacade) GetLastUpdateSucceededAt() time.Time {
 }
 
 func (f *debugAPIFacade) StartSingBox() error {
-	StartSingBoxProcess()
+	StartSingBoxProcess(f.actor)
 	return nil
 }
 
 func (f *debugAPIFacade) StopSingBox() error {
-	StopSingBoxProcess()
+	StopSingBoxProcess(f.actor)
 	return nil
 }
 
@@ -95,7 +109,7 @@ func (f *debugAPIFacade) PingAllProxies() error {
 }
 
 func (f *debugAPIFacade) RebuildConfigIfDirty() error {
-	return f.ac.RebuildConfigIfDirty()
+	return f.ac.RebuildConfigBy(f.actor, false)
 }
 
 // LoadState reads state.json from the canonical wizard path. Returns
@@ -118,9 +132,11 @@ func (f *debugAPIFacade) SaveState(s *state.State) error {
 		return errors.New("nil state")
 	}
 	path := platform.GetWizardStatePath(f.ac.FileService.ExecDir)
+	before := f.ac.StateSnapshot()
 	if err := s.Save(path); err != nil {
 		return err
 	}
+	f.ac.ApplyStateChange(f.actor, before)
 	if f.ac.StateService != nil {
 		// SPEC 050 invariant 3: any external mutation flips both dirty
 		// markers (better-safe — we don't classify rules-vs-template
@@ -153,6 +169,55 @@ func (f *debugAPIFacade) ReadCurrentLogLevel() (string, bool, error) {
 	return ReadCurrentLogLevelFromState(f.ac)
 }
 
+// ListConnections reads GET /connections of the local core. Needs the Clash
+// API: in daemon mode without a Clash endpoint there's no one-shot listing
+// (connections come as a gRPC stream), so the call fails with a clear error.
+func (f *debugAPIFacade) ListConnections() ([]api.ConnectionInfo, error) {
+	if f.ac.APIService == nil {
+		return nil, errors.New("API service not initialized")
+	}
+	base, tok, ok := f.ac.DaemonClashEndpoint()
+	if !ok {
+		if f.ac.BackendMode() == BackendDaemon {
+			return nil, errors.New("listing connections needs the Clash API, which the daemon engine does not expose")
+		}
+		var enabled bool
+		base, tok, enabled = f.ac.APIService.GetClashAPIConfig()
+		if !enabled {
+			return nil, errors.New("clash_api is disabled")
+		}
+	}
+	return api.GetConnections(base, tok)
+}
+
+// CloseConnection closes one connection through the active engine's transport.
+func (f *debugAPIFacade) CloseConnection(id string) error {
+	if f.ac.APIService == nil {
+		return errors.New("API service not initialized")
+	}
+	return f.ac.APIService.CloseConnections([]string{id})
+}
+
+// CloseConnections closes the connections matching filter (all when empty).
+func (f *debugAPIFacade) CloseConnections(filter api.ConnectionFilter) (int, error) {
+	if f.ac.APIService == nil {
+		return 0, errors.New("API service not initialized")
+	}
+	return f.ac.APIService.CloseConnectionsMatching(filter)
+}
+
+// RefreshSource — the Configurator's per-row Refresh (SPEC 052 phase 7).
+func (f *debugAPIFacade) RefreshSource(id string) (*state.Source, error) {
+	if f.ac.ConfigService == nil {
+		return nil, errors.New("config service not initialized")
+	}
+	return f.ac.RefreshSourceBy(f.actor, id)
+}
+
+func (f *debugAPIFacade) EventBus() events.Bus {
+	return f.ac.EventBus
+}
+
 func (f *debugAPIFacade) UpdateSubscriptions() error {
 	if f.ac.ConfigService == nil {
 		return errors.New("config service not initialized")
@@ -160,7 +225,7 @@ func (f *debugAPIFacade) UpdateSubscriptions() error {
 	// Run synchronously so the HTTP caller learns success/failure in-band.
 	// Per-source counts are exposed to humans through toasts, not through
 	// the JSON action contract — discard the result here.
-	_, err := f.ac.ConfigService.UpdateConfigFromSubscriptions()
+	_, err := f.ac.UpdateSubscriptionsBy(f.actor)
 	return err
 }
 
@@ -175,12 +240,25 @@ var (
 
 // StartDebugAPI binds the debug-API server on 127.0.0.1:port with the given
 // bearer token. Safe to call more than once — subsequent calls restart.
+// The Unix socket and the named tokens come from bin/settings.json;
+// mutating calls are logged to logs/debugapi_audit.jsonl.
 func (ac *AppController) StartDebugAPI(port int, token string) error {
 	if debugAPIServer != nil {
 		debugAPIServer.Stop()
 		debugAPIServer = nil
 	}
-	s, err := debugapi.New(&debugAPIFacade{ac: ac}, port, token)
+	opts := debugapi.Options{Port: port, Token: token}
+	if ac.FileService != nil {
+		execDir := ac.FileService.ExecDir
+		st := locale.LoadSettings(platform.GetBinDir(execDir))
+		opts.AuditPath = filepath.Join(platform.GetLogsDir(execDir), constants.DebugAPIAuditLogFileName)
+		if st.DebugAPISocket && debugapi.SocketSupported {
+			opts.SocketPath = platform.GetDebugAPISocketPath(execDir)
+			opts.NoTCP = st.DebugAPISocketOnly
+		}
+		opts.Tokens = debugAPINamedTokens(st.DebugAPITokens)
+	}
+	s, err := debugapi.NewWithOptions(&debugAPIFacade{ac: ac, actor: audit.ActorAPI}, opts)
 	if err != nil {
 		return err
 	}
@@ -218,6 +296,10 @@ func (ac *AppController) StartDebugAPI(port int, token string) error {
 				id, name, active := ac.UIService.LxdOverrideStateFunc()
 				return id, name, active, nil
 			},
+			FleetRefresh: ac.RefreshRemoteSubscriptions,
+			Drift:        ac.RemoteDrift,
+			Logs:         ac.RemoteLogs,
+			CoreFetch:    ac.FetchCoreBinaryFor,
 		})
 	}
 	// SPEC 100: local-daemon group — только на платформах с демонным движком
@@ -225,6 +307,13 @@ func (ac *AppController) StartDebugAPI(port int, token string) error {
 	if df := ac.debugAPIDaemonFacade(); df != nil {
 		s.EnableDaemon(df)
 	}
+	// Версии ядра рядом друг с другом: хранилище в bin/cores/ есть везде,
+	// где лаунчер сам ставит ядро.
+	if ac.FileService != nil {
+		s.EnableCoreVersions(&debugAPICoreVersions{ac: ac})
+		s.EnableSupervision(&debugAPISupervision{ac: ac})
+		s.EnableBackup(ac)
+	}
 	debugAPIServer = s
 	debugAPIServer.Start()
 	return nil
@@ -244,9 +333,45 @@ func (ac *AppController) StopDebugAPI() {
 
 // DebugAPIAddr returns the bound "127.0.0.1:port" string if running,
 // otherwise empty. Useful for the UI to show a copyable example URL.
+// Empty in socket-only mode too — use DebugAPIRunning for "is it on".
 func (ac *AppController) DebugAPIAddr() string {
 	if debugAPIServer == nil {
 		return ""
 	}
 	return debugAPIServer.Addr()
 }
+
+// DebugAPISocketPath returns the Unix socket the server listens on, or "".
+func (ac *AppController) DebugAPISocketPath() string {
+	if debugAPIServer == nil {
+		return ""
+	}
+	return debugAPIServer.SocketPath()
+}
+
+// DebugAPIRunning reports whether the debug-API server is up (TCP, socket
+// or both).
+func (ac *AppController) DebugAPIRunning() bool {
+	return debugAPIServer != nil
+}
+
+// debugAPINamedTokens переводит токены из settings.json в debugapi.Token.
+// Запечатанный (секреты заблокированы) или с неизвестной областью токен
+// пропускается с предупреждением: один испорченный токен не должен
+// выключать API целиком.
+func debugAPINamedTokens(in []locale.DebugAPINamedToken) []debugapi.Token {
+	var out []debugapi.Token
+	for _, t := range in {
+		if secretstore.IsSealed(t.Token) {
+			debuglog.WarnLog("debug-api: token %q is encrypted and the secrets are locked, skipped", t.Name)
+			continue
+		}
+		scopes, err := debugapi.ParseScopes(t.Scopes)
+		if err != nil {
+			debuglog.WarnLog("debug-api: token %q: %v, skipped", t.Name, err)
+			continue
+		}
+		out = append(out, debugapi.Token{Name: t.Name, Secret: t.Token, Scopes: scopes})
+	}
+	return out
+}
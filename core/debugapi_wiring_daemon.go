@@ -1,4 +1,4 @@
-//go:build darwin
+//go:build darwin || linux
 
 package core
 
@@ -20,7 +20,7 @@ type debugAPIDaemonWiring struct {
 	ac *AppController
 }
 
-// debugAPIDaemonFacade — darwin-реализация. Другие платформы (см. стаб)
+// debugAPIDaemonFacade — реализация для macOS и Linux. Остальные (см. стаб)
 // возвращают nil, и группа /daemon/* не регистрируется.
 func (ac *AppController) debugAPIDaemonFacade() debugapi.DaemonFacade {
 	return &debugAPIDaemonWiring{ac: ac}
@@ -31,6 +31,8 @@ func (f *debugAPIDaemonWiring) Status() debugapi.DaemonStatus {
 	return debugapi.DaemonStatus{
 		CoreSupportsLxd:  st.CoreSupportsLxd,
 		ServiceInstalled: st.ServiceInstalled,
+		ServiceScope:     st.ServiceScope,
+		ServiceScopes:    f.ac.DaemonServiceScopes(),
 		Paired:           st.Paired,
 		Address:          st.Address,
 		Reachable:        st.Reachable,
@@ -52,6 +54,10 @@ func (f *debugAPIDaemonWiring) SetAddress(addr string) error { return f.ac.SetDa
 
 func (f *debugAPIDaemonWiring) SetSecret(secret string) error { return f.ac.SetDaemonSecret(secret) }
 
+func (f *debugAPIDaemonWiring) SetServiceScope(scope string) error {
+	return f.ac.SetDaemonServiceScope(scope)
+}
+
 func (f *debugAPIDaemonWiring) Commands() debugapi.DaemonCommands {
 	install, err := f.ac.DaemonInstallCommand()
 	if err != nil {
@@ -51,6 +51,18 @@ const (
 	// VpnStateChanged — sing-box перешёл из/в running-состояние.
 	// Payload: VpnStateChangedPayload.
 	VpnStateChanged
+
+	// ProxySwitched — в группе выбран другой узел (вкладка Servers, Debug
+	// API, автовозврат сохранённого выбора). Payload: ProxySwitchedPayload.
+	ProxySwitched
+
+	// SubscriptionsRefreshed — прогон обновления подписок закончился,
+	// удачно или нет. Payload: SubscriptionsRefreshedPayload.
+	SubscriptionsRefreshed
+
+	// RemoteDriftChecked — удалённая машина сверена с задеплоенным на неё
+	// конфигом (services.DriftReconciler). Payload: RemoteDriftCheckedPayload.
+	RemoteDriftChecked
 )
 
 // String — человеко-читаемое имя для логов и тестов.
@@ -63,6 +75,12 @@ func (k EventKind) String() string {
 		return "ConfigBuilt"
 	case VpnStateChanged:
 		return "VpnStateChanged"
+	case ProxySwitched:
+		return "ProxySwitched"
+	case SubscriptionsRefreshed:
+		return "SubscriptionsRefreshed"
+	case RemoteDriftChecked:
+		return "RemoteDriftChecked"
 	default:
 		return "Unknown"
 	}
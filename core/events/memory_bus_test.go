@@ -199,6 +199,8 @@ func TestEventKindString(t *testing.T) {
 		{StateChanged, "StateChanged"},
 		{ConfigBuilt, "ConfigBuilt"},
 		{VpnStateChanged, "VpnStateChanged"},
+		{ProxySwitched, "ProxySwitched"},
+		{SubscriptionsRefreshed, "SubscriptionsRefreshed"},
 		{EventKind(9999), "Unknown"},
 	}
 	for _, c := range cases {
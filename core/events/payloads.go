@@ -33,3 +33,35 @@ type ConfigBuiltPayload struct {
 type VpnStateChangedPayload struct {
 	Running bool
 }
+
+// ProxySwitchedPayload сопровождает Kind ProxySwitched.
+type ProxySwitchedPayload struct {
+	Group string
+	Proxy string
+	// Remote — переключение ушло в ядро удалённой машины, а не в своё.
+	Remote bool
+}
+
+// SubscriptionsRefreshedPayload сопровождает Kind SubscriptionsRefreshed.
+type SubscriptionsRefreshedPayload struct {
+	// OK — кэш подписок обновлён (даже если часть источников упала).
+	OK bool
+	// Error — заполнено при OK == false.
+	Error error
+	// Счётчики источников и узлов прогона; нули, если до парсинга не дошло.
+	TotalSources     int
+	SucceededSources int
+	FailedSources    int
+	Nodes            int
+}
+
+// RemoteDriftCheckedPayload сопровождает Kind RemoteDriftChecked.
+type RemoteDriftCheckedPayload struct {
+	MachineID string
+	// State — in-sync | pending | drifted | unknown (services.DriftState).
+	State string
+	// Redeployed — сверка задеплоила машину заново по её политике.
+	Redeployed bool
+	// Error — почему сверка не удалась или повторный деплой упал.
+	Error string
+}
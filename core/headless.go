@@ -0,0 +1,41 @@
+package core
+
+import (
+	"fmt"
+	"os"
+
+	"singbox-launcher/api"
+	"singbox-launcher/internal/debuglog"
+)
+
+// CheckConfigFile прогоняет `sing-box check` по configPath.
+//
+// В отличие от шага валидации в RebuildConfigIfDirty (там отсутствие ядра —
+// graceful skip, чтобы старые установки без bundled binary не падали), здесь
+// нет ни config.json, ни ядра — это ошибка: CLI `check` должен честно падать,
+// а не молча проходить.
+func CheckConfigFile(singboxPath, configPath string) error {
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if _, err := os.Stat(singboxPath); err != nil {
+		return fmt.Errorf("sing-box: %w", err)
+	}
+	return validateConfigViaSingBox(singboxPath, configPath)
+}
+
+// CloseHeadless завершает одноразовую CLI-команду: гасит фоновые циклы
+// (auto-update, core stats) и закрывает логи.
+//
+// Ядро не трогает — в отличие от GracefulExit: одноразовая команда его не
+// запускала, а останавливать sing-box, запущенный другим процессом, нельзя.
+func (ac *AppController) CloseHeadless() {
+	if ac.cancelFunc != nil {
+		ac.cancelFunc()
+	}
+	debuglog.InfoLog("CloseHeadless: background loops stopped")
+	if ac.FileService != nil {
+		api.SetAPILogFile(nil)
+		ac.FileService.CloseLogFiles()
+	}
+}
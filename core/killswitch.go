@@ -0,0 +1,350 @@
+package core
+
+// Kill switch (state.kill_switch): пока VPN должен работать, системный
+// файрвол выпускает наружу только соединения ядра, его TUN и локальную сеть
+// (правила — core/killswitch). Ставится при старте ядра в обоих движках,
+// переживает падения, перезапуски и отказ присмотра от перезапусков —
+// трафик не уходит мимо VPN, пока пользователь явно не нажал Stop (или
+// «Разблокировать» в окне kill switch).
+//
+// Желаемое состояние (want) меняется синхронно в месте решения, а
+// применяет его reconcileKillSwitch под op-мьютексом: Stop и следом Start
+// (смена версии ядра и т.п.) не перепутают порядок, даже если файрвол
+// ставится через диалог pkexec. Маркер bin/killswitch.active переживает
+// лаунчер: после его падения правила остаются в ядре ОС, и на следующем
+// старте лаунчер знает, что трафик заблокирован.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/config"
+	"singbox-launcher/core/killswitch"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/dialogs"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+const killSwitchMarkerName = "killswitch.active"
+
+// Причины включения kill switch (KillSwitchStatus.Reason).
+const (
+	KillSwitchReasonStart           = "start"
+	KillSwitchReasonPreviousSession = "previous_session"
+)
+
+// killSwitchState — поле контроллера.
+type killSwitchState struct {
+	// op сериализует обращения к файрволу; mu защищает поля ниже.
+	op sync.Mutex
+	mu sync.Mutex
+	fw killswitch.Firewall
+
+	want    bool
+	armed   bool
+	since   time.Time
+	reason  string
+	lastErr string
+}
+
+// KillSwitchStatus — kill switch для окна и логов.
+type KillSwitchStatus struct {
+	Policy *state.KillSwitchPolicy
+	// Supported — платформа умеет kill switch (сейчас только Linux).
+	Supported bool
+	// Armed — правила стоят: трафик мимо ядра заблокирован.
+	Armed  bool
+	Since  time.Time
+	Reason string
+	// LastError — почему последняя попытка поставить или снять правила
+	// не удалась.
+	LastError string
+}
+
+func (ac *AppController) killSwitchFirewall() killswitch.Firewall {
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.fw == nil {
+		ks.fw = killswitch.New()
+	}
+	return ks.fw
+}
+
+func (ac *AppController) killSwitchMarkerPath() string {
+	return filepath.Join(platform.GetBinDir(ac.FileService.ExecDir), killSwitchMarkerName)
+}
+
+// KillSwitchPolicy — сохранённая политика профиля (nil — kill switch не
+// настроен).
+func (ac *AppController) KillSwitchPolicy() *state.KillSwitchPolicy {
+	if ac.FileService == nil {
+		return nil
+	}
+	s, err := state.Load(platform.GetWizardStatePath(ac.FileService.ExecDir))
+	if err != nil {
+		if !errors.Is(err, state.ErrNotFound) {
+			debuglog.WarnLog("killswitch: load state: %v", err)
+		}
+		return nil
+	}
+	return s.KillSwitch
+}
+
+// KillSwitchStatus — политика и текущее состояние правил.
+func (ac *AppController) KillSwitchStatus() KillSwitchStatus {
+	st := KillSwitchStatus{Policy: ac.KillSwitchPolicy(), Supported: killswitch.Supported}
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	st.Armed, st.Since, st.Reason, st.LastError = ks.armed, ks.since, ks.reason, ks.lastErr
+	ks.mu.Unlock()
+	return st
+}
+
+// SetKillSwitchPolicy сохраняет политику в state.json (load-mutate-save).
+// Метку ядра (route.default_mark) ставит сборка конфига, поэтому конфиг
+// помечается устаревшим: включение действует со следующего старта VPN.
+// Выключение снимает стоящие правила сразу, правка исключений — применяет.
+func (ac *AppController) SetKillSwitchPolicy(actor audit.Actor, p *state.KillSwitchPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if ac.FileService == nil {
+		return errors.New("core: no controller")
+	}
+	statePath := platform.GetWizardStatePath(ac.FileService.ExecDir)
+	s, err := state.Load(statePath)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return ErrSupervisionNoProfile
+		}
+		return fmt.Errorf("load state: %w", err)
+	}
+	before := ac.StateSnapshot()
+	s.KillSwitch = p
+	if err := s.Save(statePath); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	ac.ApplyStateChange(actor, before)
+	if ac.StateService != nil {
+		ac.StateService.MarkConfigStale()
+	}
+
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	armed := ks.armed
+	if p == nil || !p.Enabled {
+		ks.want = false
+	}
+	ks.mu.Unlock()
+	if armed {
+		return ac.reconcileKillSwitch(p != nil && p.Enabled)
+	}
+	return nil
+}
+
+// armKillSwitch — VPN поднят (или поднимается): включить kill switch, если
+// он включён в профиле. Вызывается из путей старта обоих движков.
+func (ac *AppController) armKillSwitch() {
+	if p := ac.KillSwitchPolicy(); p == nil || !p.Enabled {
+		return
+	}
+	if !killswitch.Supported {
+		debuglog.DebugLog("killswitch: enabled in the profile but not supported on this platform")
+		return
+	}
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	ks.want = true
+	ks.reason = KillSwitchReasonStart
+	ks.mu.Unlock()
+	go func() {
+		if err := ac.reconcileKillSwitch(false); err != nil {
+			ac.notifyKillSwitch(locale.Tf("core.killswitch.arm_failed", err))
+		}
+	}()
+}
+
+// releaseKillSwitch — пользователь остановил VPN: снять kill switch.
+// async=false — дождаться снятия (выход из лаунчера).
+func (ac *AppController) releaseKillSwitch(async bool) {
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	ks.want = false
+	armed := ks.armed
+	ks.mu.Unlock()
+	if !armed {
+		return
+	}
+	run := func() {
+		if err := ac.reconcileKillSwitch(false); err != nil {
+			ac.notifyKillSwitch(locale.Tf("core.killswitch.release_failed", err))
+		}
+	}
+	if async {
+		go run()
+		return
+	}
+	run()
+}
+
+// ReleaseKillSwitch снимает правила по просьбе пользователя (кнопка
+// «Разблокировать»), не трогая ядро. Следующий старт VPN поставит их снова.
+func (ac *AppController) ReleaseKillSwitch() error {
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	ks.want = false
+	ks.mu.Unlock()
+	return ac.reconcileKillSwitch(false)
+}
+
+// holdKillSwitch — ядро упало или присмотр сдался: правила остаются.
+// Если поставить их при старте не удалось, пробуем ещё раз — но не после
+// ошибки (отказ в диалоге pkexec не должен повторяться на каждом падении).
+func (ac *AppController) holdKillSwitch(gaveUp bool) {
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	want, armed, failed := ks.want, ks.armed, ks.lastErr != ""
+	ks.mu.Unlock()
+	if !want {
+		return
+	}
+	if !armed && !failed {
+		go func() { _ = ac.reconcileKillSwitch(false) }()
+	}
+	if gaveUp && armed {
+		ac.notifyKillSwitch(locale.T("core.killswitch.holding"))
+	}
+}
+
+// reconcileKillSwitch приводит правила к want. reapply — переставить
+// стоящие правила (правка исключений).
+func (ac *AppController) reconcileKillSwitch(reapply bool) error {
+	ks := &ac.killSwitch
+	ks.op.Lock()
+	defer ks.op.Unlock()
+	ks.mu.Lock()
+	want, armed := ks.want, ks.armed
+	ks.mu.Unlock()
+	if want == armed && !(want && reapply) {
+		return nil
+	}
+
+	fw := ac.killSwitchFirewall()
+	var err error
+	if want {
+		var rules killswitch.Rules
+		if rules, err = ac.killSwitchRules(); err == nil {
+			err = fw.Apply(rules)
+		}
+	} else {
+		err = fw.Remove()
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if err != nil {
+		ks.lastErr = err.Error()
+		op := "release"
+		if want {
+			op = "arm"
+		}
+		debuglog.WarnLog("killswitch: %s: %v", op, err)
+		return err
+	}
+	ks.lastErr = ""
+	if want {
+		if !ks.armed {
+			ks.since = time.Now()
+		}
+		ks.armed = true
+		if werr := os.WriteFile(ac.killSwitchMarkerPath(), []byte(ks.since.UTC().Format(time.RFC3339)+"\n"), 0o644); werr != nil {
+			debuglog.WarnLog("killswitch: write marker: %v", werr)
+		}
+		debuglog.InfoLog("killswitch: armed (%s)", ks.reason)
+		return nil
+	}
+	ks.armed, ks.since, ks.reason = false, time.Time{}, ""
+	if rerr := os.Remove(ac.killSwitchMarkerPath()); rerr != nil && !os.IsNotExist(rerr) {
+		debuglog.WarnLog("killswitch: remove marker: %v", rerr)
+	}
+	debuglog.InfoLog("killswitch: released")
+	return nil
+}
+
+// killSwitchRules — правила из политики профиля и config.json ядра: метка
+// исходящих соединений и TUN-интерфейсы.
+func (ac *AppController) killSwitchRules() (killswitch.Rules, error) {
+	p := ac.KillSwitchPolicy()
+	if p == nil {
+		p = &state.KillSwitchPolicy{}
+	}
+	egress, err := config.GetCoreEgress(ac.FileService.ConfigPath)
+	if err != nil {
+		return killswitch.Rules{}, err
+	}
+	if egress.DefaultMark == 0 {
+		return killswitch.Rules{}, fmt.Errorf("%w: restart the VPN so the config is rebuilt with the kill switch mark", killswitch.ErrNoMark)
+	}
+	allow, err := killswitch.ParseAllow(p.AllowCIDRs)
+	if err != nil {
+		return killswitch.Rules{}, err
+	}
+	return killswitch.Rules{
+		Mark:          egress.DefaultMark,
+		TunInterfaces: egress.TunInterfaces,
+		AllowLAN:      !p.BlockLAN,
+		Allow:         allow,
+	}, nil
+}
+
+// adoptKillSwitchAtStart — правила прошлой сессии (лаунчер упал с
+// включённым VPN) остались в системе: считаем их своими, чтобы окно
+// показало блокировку, а Stop или «Разблокировать» её сняли. Маркер без
+// правил (машину перезагрузили — таблица пропала, файл остался) удаляется:
+// иначе следующий старт VPN счёл бы правила стоящими и не поставил их.
+func (ac *AppController) adoptKillSwitchAtStart() {
+	if ac.FileService == nil {
+		return
+	}
+	data, err := os.ReadFile(ac.killSwitchMarkerPath())
+	if err != nil {
+		return
+	}
+	since, _ := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	active, err := ac.killSwitchFirewall().Active()
+	if err != nil {
+		// Спросить файрвол не вышло (без root nft таблиц не покажет).
+		// Перезагрузку таблица не переживает: маркер старше загрузки
+		// точно устарел, иначе верим ему.
+		boot, berr := killswitch.BootTime()
+		active = berr != nil || since.IsZero() || !since.Before(boot)
+		debuglog.DebugLog("killswitch: cannot query the firewall (%v), marker since %s, boot %s", err, since.Format(time.RFC3339), boot.Format(time.RFC3339))
+	}
+	if !active {
+		if rerr := os.Remove(ac.killSwitchMarkerPath()); rerr != nil && !os.IsNotExist(rerr) {
+			debuglog.WarnLog("killswitch: remove stale marker: %v", rerr)
+		}
+		debuglog.InfoLog("killswitch: the marker from %s has no rules behind it (reboot?), dropped", since.Format(time.RFC3339))
+		return
+	}
+	ks := &ac.killSwitch
+	ks.mu.Lock()
+	ks.want, ks.armed, ks.since, ks.reason = true, true, since, KillSwitchReasonPreviousSession
+	ks.mu.Unlock()
+	debuglog.WarnLog("killswitch: rules from the previous session are still active (since %s)", since.Format(time.RFC3339))
+}
+
+func (ac *AppController) notifyKillSwitch(msg string) {
+	if ac.hasUI() && ac.UIService.Application != nil {
+		dialogs.ShowAutoHideInfo(ac.UIService.Application, ac.UIService.MainWindow, locale.T("core.killswitch.notify_title"), msg)
+	}
+}
@@ -0,0 +1,113 @@
+//go:build linux
+
+package killswitch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported — kill switch реализован на этой платформе.
+const Supported = true
+
+// New возвращает nftables-файрвол. Без root правила ставятся через pkexec
+// (системный диалог polkit): лаунчер обычно работает от пользователя, а
+// ядру хватает capabilities.
+func New() Firewall { return nftFirewall{} }
+
+type nftFirewall struct{}
+
+func (nftFirewall) Apply(r Rules) error {
+	script, err := NftablesScript(r)
+	if err != nil {
+		return err
+	}
+	return runNft(script)
+}
+
+func (nftFirewall) Remove() error { return runNft(NftablesRemoveScript()) }
+
+// Active спрашивает nft напрямую, без pkexec: ради проверки на старте
+// диалог пароля не показываем. Без root nft таблицы не покажет — это
+// ошибка, а не «правил нет».
+func (nftFirewall) Active() (bool, error) {
+	nft, err := nftPath()
+	if err != nil {
+		return false, err
+	}
+	cmd := exec.Command(nft, "list", "table", "inet", TableName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "No such file or directory") {
+			return false, nil
+		}
+		if msg != "" {
+			return false, fmt.Errorf("nft: %s", msg)
+		}
+		return false, fmt.Errorf("nft: %w", err)
+	}
+	return true, nil
+}
+
+// BootTime — время загрузки системы (/proc/stat, btime). Таблицы nftables
+// перезагрузку не переживают.
+func BootTime() (time.Time, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("/proc/stat: btime: %w", err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, errors.New("/proc/stat: no btime")
+}
+
+func nftPath() (string, error) {
+	nft, err := exec.LookPath("nft")
+	if err != nil {
+		nft = "/usr/sbin/nft"
+		if _, statErr := os.Stat(nft); statErr != nil {
+			return "", fmt.Errorf("nft not found (install nftables): %w", err)
+		}
+	}
+	return nft, nil
+}
+
+func runNft(script string) error {
+	nft, err := nftPath()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(nft, "-f", "-")
+	if os.Geteuid() != 0 {
+		pkexec, err := exec.LookPath("pkexec")
+		if err != nil {
+			return fmt.Errorf("the kill switch needs root: run the launcher as root or install polkit (pkexec)")
+		}
+		cmd = exec.Command(pkexec, nft, "-f", "-")
+	}
+	cmd.Stdin = strings.NewReader(script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("nft: %s", msg)
+		}
+		return fmt.Errorf("nft: %w", err)
+	}
+	return nil
+}
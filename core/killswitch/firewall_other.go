@@ -0,0 +1,22 @@
+//go:build !linux
+
+package killswitch
+
+import "time"
+
+// Supported — kill switch реализован на этой платформе.
+const Supported = false
+
+// New возвращает файрвол, который на этой платформе ничего не умеет.
+func New() Firewall { return unsupportedFirewall{} }
+
+type unsupportedFirewall struct{}
+
+func (unsupportedFirewall) Apply(Rules) error { return ErrUnsupported }
+func (unsupportedFirewall) Remove() error     { return nil }
+
+// Active — правил здесь быть не может.
+func (unsupportedFirewall) Active() (bool, error) { return false, nil }
+
+// BootTime здесь не нужен: правил, переживающих перезагрузку, нет.
+func BootTime() (time.Time, error) { return time.Time{}, ErrUnsupported }
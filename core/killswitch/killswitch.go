@@ -0,0 +1,143 @@
+// Package killswitch — правила системного файрвола для kill switch: пока VPN
+// должен работать, наружу выходят только соединения самого ядра (по метке
+// route.default_mark), трафик через его TUN-интерфейсы, loopback и, по
+// желанию, локальная сеть. Всё остальное отбрасывается — в том числе пока
+// упавшее ядро ждёт перезапуска.
+//
+// Пакет — лист: генератор правил чистый и тестируется без root, а
+// применение спрятано за Firewall (nftables на Linux, на остальных
+// платформах — ErrUnsupported). Когда ставить и снимать правила, решает
+// core (core/killswitch.go).
+package killswitch
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// DefaultMark — route.default_mark, который сборка конфига ставит ядру при
+// включённом kill switch (если шаблон не задал свою метку). Не пересекается
+// с метками auto_redirect самого sing-box (0x2023/0x2024).
+const DefaultMark uint32 = 0x2f5a
+
+// TableName — таблица nftables, целиком принадлежащая kill switch: снять
+// защиту — удалить таблицу, чужие правила не затрагиваются.
+const TableName = "singbox_launcher_killswitch"
+
+// ErrUnsupported — на этой платформе kill switch не реализован.
+var ErrUnsupported = errors.New("kill switch is not supported on this platform")
+
+// ErrNoMark — у ядра нет метки исходящих соединений: без неё правила
+// отрезали бы и само ядро.
+var ErrNoMark = errors.New("config.json has no route.default_mark")
+
+// Rules — что разрешено, пока kill switch включён.
+type Rules struct {
+	// Mark — route.default_mark ядра; обязателен.
+	Mark uint32
+	// TunInterfaces — интерфейсы TUN ядра; "" — имя выбирает ядро
+	// (tunN), разрешается шаблон tun*.
+	TunInterfaces []string
+	// AllowLAN — разрешить частные, link-local и multicast-адреса.
+	AllowLAN bool
+	// Allow — дополнительные разрешённые адреса назначения.
+	Allow []netip.Prefix
+}
+
+var (
+	lanV4 = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "224.0.0.0/4", "255.255.255.255/32"}
+	lanV6 = []string{"fc00::/7", "fe80::/10", "ff00::/8"}
+)
+
+// ParseAllow разбирает адреса исключений: CIDR или одиночный адрес.
+func ParseAllow(list []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(s); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR", s)
+		}
+		out = append(out, netip.PrefixFrom(a, a.BitLen()))
+	}
+	return out, nil
+}
+
+// NftablesScript — скрипт для `nft -f -`, атомарно заменяющий таблицу kill
+// switch. Повторное применение безопасно: таблица сначала создаётся (если её
+// нет) и удаляется в той же транзакции.
+func NftablesScript(r Rules) (string, error) {
+	if r.Mark == 0 {
+		return "", ErrNoMark
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "table inet %s\ndelete table inet %s\n", TableName, TableName)
+	fmt.Fprintf(&b, "table inet %s {\n", TableName)
+	b.WriteString("\tchain output {\n")
+	b.WriteString("\t\ttype filter hook output priority filter; policy drop;\n")
+	b.WriteString("\t\toifname \"lo\" accept\n")
+	fmt.Fprintf(&b, "\t\tmeta mark 0x%08x accept\n", r.Mark)
+
+	seen := map[string]bool{}
+	for _, name := range r.TunInterfaces {
+		if name == "" {
+			name = "tun*"
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		fmt.Fprintf(&b, "\t\toifname %q accept\n", name)
+	}
+
+	// Без DHCP и обнаружения соседей машина теряет адрес и шлюз — тогда не
+	// поднимется и само ядро.
+	b.WriteString("\t\tudp sport 68 udp dport 67 accept\n")
+	b.WriteString("\t\tudp sport 546 udp dport 547 accept\n")
+	b.WriteString("\t\ticmpv6 type { nd-router-solicit, nd-neighbor-solicit, nd-neighbor-advert } accept\n")
+
+	var v4, v6 []string
+	if r.AllowLAN {
+		v4 = append(v4, lanV4...)
+		v6 = append(v6, lanV6...)
+	}
+	for _, p := range r.Allow {
+		if p.Addr().Is4() {
+			v4 = append(v4, p.String())
+		} else {
+			v6 = append(v6, p.String())
+		}
+	}
+	if len(v4) > 0 {
+		fmt.Fprintf(&b, "\t\tip daddr { %s } accept\n", strings.Join(v4, ", "))
+	}
+	if len(v6) > 0 {
+		fmt.Fprintf(&b, "\t\tip6 daddr { %s } accept\n", strings.Join(v6, ", "))
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String(), nil
+}
+
+// NftablesRemoveScript — скрипт, снимающий kill switch; безопасен и когда
+// таблицы нет.
+func NftablesRemoveScript() string {
+	return fmt.Sprintf("table inet %s\ndelete table inet %s\n", TableName, TableName)
+}
+
+// Firewall ставит и снимает правила kill switch в системе.
+type Firewall interface {
+	Apply(r Rules) error
+	Remove() error
+	// Active — стоят ли правила сейчас. Ошибка — проверить не удалось
+	// (например, нет прав читать таблицы).
+	Active() (bool, error)
+}
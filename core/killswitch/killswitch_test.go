@@ -0,0 +1,58 @@
+package killswitch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNftablesScript(t *testing.T) {
+	allow, err := ParseAllow([]string{"203.0.113.7", "2001:db8::/32", " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NftablesScript(Rules{Mark: DefaultMark, TunInterfaces: []string{"singbox-tun0", "", ""}, AllowLAN: true, Allow: allow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `table inet singbox_launcher_killswitch
+delete table inet singbox_launcher_killswitch
+table inet singbox_launcher_killswitch {
+	chain output {
+		type filter hook output priority filter; policy drop;
+		oifname "lo" accept
+		meta mark 0x00002f5a accept
+		oifname "singbox-tun0" accept
+		oifname "tun*" accept
+		udp sport 68 udp dport 67 accept
+		udp sport 546 udp dport 547 accept
+		icmpv6 type { nd-router-solicit, nd-neighbor-solicit, nd-neighbor-advert } accept
+		ip daddr { 10.0.0.0/8, 172.16.0.0/12, 192.168.0.0/16, 169.254.0.0/16, 224.0.0.0/4, 255.255.255.255/32, 203.0.113.7/32 } accept
+		ip6 daddr { fc00::/7, fe80::/10, ff00::/8, 2001:db8::/32 } accept
+	}
+}
+`
+	if got != want {
+		t.Errorf("script:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Без исключений остаются только ядро, TUN, loopback и служебный минимум.
+func TestNftablesScriptNoLAN(t *testing.T) {
+	got, err := NftablesScript(Rules{Mark: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "daddr") || strings.Contains(got, "oifname \"tun") {
+		t.Errorf("unexpected exceptions:\n%s", got)
+	}
+	if !strings.Contains(got, "meta mark 0x00000007 accept") {
+		t.Errorf("mark rule missing:\n%s", got)
+	}
+	if _, err := NftablesScript(Rules{}); !errors.Is(err, ErrNoMark) {
+		t.Errorf("no mark: err = %v", err)
+	}
+	if _, err := ParseAllow([]string{"example.com"}); err == nil {
+		t.Error("host name accepted as CIDR")
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/killswitch"
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/platform"
+)
+
+type fakeFirewall struct {
+	applied []killswitch.Rules
+	removed int
+	// active — таблица стоит в системе (Active).
+	active bool
+}
+
+func (f *fakeFirewall) Apply(r killswitch.Rules) error { f.applied = append(f.applied, r); return nil }
+func (f *fakeFirewall) Remove() error                  { f.removed++; return nil }
+func (f *fakeFirewall) Active() (bool, error)          { return f.active, nil }
+
+func newKillSwitchTestController(t *testing.T, dir, configBody string) (*AppController, *fakeFirewall) {
+	t.Helper()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(configBody), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(platform.GetBinDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ac := &AppController{FileService: &services.FileService{ExecDir: dir, ConfigPath: configPath}}
+	fw := &fakeFirewall{}
+	ac.killSwitch.fw = fw
+	return ac, fw
+}
+
+// Правила ставятся с меткой и TUN из config.json, переживают падение и
+// новую сессию лаунчера (маркер) и снимаются только явным снятием.
+func TestKillSwitchArmHoldRelease(t *testing.T) {
+	dir := t.TempDir()
+	const cfg = `{"inbounds":[{"type":"tun","interface_name":"singbox-tun0"}],"route":{"default_mark":12122}}`
+	ac, fw := newKillSwitchTestController(t, dir, cfg)
+
+	statePath := platform.GetWizardStatePath(dir)
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.New().Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.SetKillSwitchPolicy(audit.ActorUI, &state.KillSwitchPolicy{Enabled: true, BlockLAN: true, AllowCIDRs: []string{"203.0.113.7"}}); err != nil {
+		t.Fatalf("set policy: %v", err)
+	}
+
+	ac.killSwitch.want = true
+	if err := ac.reconcileKillSwitch(false); err != nil {
+		t.Fatalf("arm: %v", err)
+	}
+	if len(fw.applied) != 1 {
+		t.Fatalf("applied %d times", len(fw.applied))
+	}
+	r := fw.applied[0]
+	if r.Mark != 12122 || !slices.Equal(r.TunInterfaces, []string{"singbox-tun0"}) || r.AllowLAN || len(r.Allow) != 1 {
+		t.Errorf("rules = %+v", r)
+	}
+
+	ac.holdKillSwitch(true)
+	if st := ac.KillSwitchStatus(); !st.Armed || fw.removed != 0 || len(fw.applied) != 1 {
+		t.Errorf("crash changed the rules: %+v applied=%d removed=%d", st, len(fw.applied), fw.removed)
+	}
+
+	// Лаунчер перезапустился — правила прошлой сессии подхвачены.
+	next, fw2 := newKillSwitchTestController(t, dir, cfg)
+	fw2.active = true
+	next.adoptKillSwitchAtStart()
+	if st := next.KillSwitchStatus(); !st.Armed || st.Reason != KillSwitchReasonPreviousSession {
+		t.Fatalf("not adopted: %+v", st)
+	}
+	next.releaseKillSwitch(false)
+	if st := next.KillSwitchStatus(); st.Armed || fw2.removed != 1 {
+		t.Errorf("not released: %+v removed=%d", st, fw2.removed)
+	}
+	if _, err := os.Stat(next.killSwitchMarkerPath()); !os.IsNotExist(err) {
+		t.Errorf("marker left behind: %v", err)
+	}
+}
+
+// Без метки в конфиге правила не ставятся (иначе отрезали бы само ядро),
+// и падение ядра не повторяет неудачную попытку.
+func TestKillSwitchNeedsMark(t *testing.T) {
+	ac, fw := newKillSwitchTestController(t, t.TempDir(), `{"route":{}}`)
+	ac.killSwitch.want = true
+	if err := ac.reconcileKillSwitch(false); !errors.Is(err, killswitch.ErrNoMark) {
+		t.Fatalf("err = %v", err)
+	}
+	ac.holdKillSwitch(false)
+	if st := ac.KillSwitchStatus(); st.Armed || st.LastError == "" || len(fw.applied) != 0 {
+		t.Errorf("status = %+v applied=%d", st, len(fw.applied))
+	}
+}
+
+// После перезагрузки маркер остался, а таблицы нет: маркер не подхватывается
+// и удаляется, следующий старт VPN ставит правила заново.
+func TestKillSwitchStaleMarkerAfterReboot(t *testing.T) {
+	const cfg = `{"route":{"default_mark":12122}}`
+	ac, fw := newKillSwitchTestController(t, t.TempDir(), cfg)
+	if err := os.WriteFile(ac.killSwitchMarkerPath(), []byte("2026-01-02T03:04:05Z\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ac.adoptKillSwitchAtStart()
+	if st := ac.KillSwitchStatus(); st.Armed {
+		t.Fatalf("stale marker adopted: %+v", st)
+	}
+	if _, err := os.Stat(ac.killSwitchMarkerPath()); !os.IsNotExist(err) {
+		t.Errorf("stale marker left behind: %v", err)
+	}
+
+	ac.killSwitch.want = true
+	if err := ac.reconcileKillSwitch(false); err != nil {
+		t.Fatalf("arm: %v", err)
+	}
+	if st := ac.KillSwitchStatus(); !st.Armed || len(fw.applied) != 1 {
+		t.Errorf("rules not re-applied: %+v applied=%d", st, len(fw.applied))
+	}
+}
@@ -0,0 +1,542 @@
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+// Самообновление лаунчера.
+//
+// Раньше CheckLauncherVersionOnStartup только показывал попап, а скачать и
+// распаковать новую версию пользователь должен был сам. Теперь три шага, и
+// каждый переживает перезапуск:
+//
+//  1. CheckLauncherUpdate — релиз выбранного канала (stable — /releases/latest,
+//     prerelease — новейший из /releases) новее constants.AppVersion, и в нём
+//     есть ассет под эту платформу (имена — как в .github/workflows/ci.yml).
+//  2. StageLauncherUpdate — ассет скачивается и проверяется по тем же
+//     правилам, что ядро (core_download_verify.go: digest GitHub и
+//     checksums.txt; без опубликованного хэша — отказ), распаковывается и
+//     кладётся РЯДОМ с запущенным бинарём: <exe>.new или <App>.app.new.
+//     Что и откуда подготовлено — bin/launcher_update.json.
+//  3. ApplyPendingLauncherUpdate (launcher_update_apply.go) на следующем
+//     старте меняет бинари местами; прежний остаётся <exe>.old для отката.
+//
+// Запущенный бинарь не трогается: Windows не даёт его перезаписать, а подмена
+// на лету оставила бы процесс с кодом одной версии и данными другой.
+
+// Каналы обновления (Settings.LauncherUpdateChannel).
+const (
+	LauncherChannelStable     = "stable"
+	LauncherChannelPrerelease = "prerelease"
+)
+
+var (
+	// ErrLauncherUpdateUnsupported — для этой сборки самообновления нет
+	// (Linux, dev-сборка, macOS вне .app, в релизе нет ассета платформы).
+	ErrLauncherUpdateUnsupported = errors.New("launcher self-update is not available")
+	// ErrLauncherUpdateBusy — обновление уже готовится.
+	ErrLauncherUpdateBusy = errors.New("launcher update is already in progress")
+)
+
+// launcherReleasesAPI — релизы лаунчера; тесты подменяют на httptest.
+var launcherReleasesAPI = "https://api.github.com/repos/Leadaxe/singbox-launcher/releases"
+
+// maxLauncherArchiveUnpacked — предел распакованного архива: лаунчер с
+// ресурсами — десятки мегабайт, больше — не наш архив.
+const maxLauncherArchiveUnpacked = 512 << 20
+
+// LauncherRelease — найденное обновление лаунчера.
+type LauncherRelease struct {
+	Version    string // тег релиза, с «v»
+	Prerelease bool
+	Asset      string
+	Size       int64
+
+	release *ReleaseInfo
+	asset   *Asset
+}
+
+// LauncherUpdateState — что показать в настройках: канал, подготовленное или
+// применённое обновление, последний откат.
+type LauncherUpdateState struct {
+	Channel string
+	Auto    bool
+	// Unsupported — почему самообновление недоступно; пусто — доступно.
+	Unsupported string
+	Busy        bool
+	// Phase/Version/FromVersion/Reason — из bin/launcher_update.json.
+	Phase       string
+	Version     string
+	FromVersion string
+	VerifiedBy  string
+	Reason      string
+	// CanRollback — рядом лежит прежний бинарь (<exe>.old).
+	CanRollback bool
+}
+
+// NormalizeLauncherChannel — неизвестный канал считается stable.
+func NormalizeLauncherChannel(s string) string {
+	if strings.EqualFold(strings.TrimSpace(s), LauncherChannelPrerelease) {
+		return LauncherChannelPrerelease
+	}
+	return LauncherChannelStable
+}
+
+func (ac *AppController) launcherUpdateSettings() (channel string, auto bool) {
+	if ac.FileService == nil {
+		return LauncherChannelStable, false
+	}
+	st := locale.LoadSettings(platform.GetBinDir(ac.FileService.ExecDir))
+	return NormalizeLauncherChannel(st.LauncherUpdateChannel), st.LauncherUpdateAuto
+}
+
+// currentLauncherTarget — что заменит обновление у запущенного процесса.
+func currentLauncherTarget() (launcherTarget, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return launcherTarget{}, err
+	}
+	if p, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = p
+	}
+	return launcherTargetFor(exe), nil
+}
+
+// launcherUpdateUnsupported — почему эта сборка не обновляет себя сама;
+// nil — обновляет.
+func launcherUpdateUnsupported(version, goos, goarch string, t launcherTarget) error {
+	if !launcherVersionIsRelease(version) {
+		return fmt.Errorf("%w: development build %s", ErrLauncherUpdateUnsupported, version)
+	}
+	if goos == "darwin" && !t.Bundle {
+		return fmt.Errorf("%w: not running from an .app bundle", ErrLauncherUpdateUnsupported)
+	}
+	_, err := launcherAssetName(version, goos, goarch, t.Path)
+	return err
+}
+
+// launcherVersionIsRelease — версия из тега релиза (vX.Y.Z…), а не
+// локальная сборка («v-local-test»): её заменять нечем и незачем.
+func launcherVersionIsRelease(v string) bool {
+	v = strings.TrimPrefix(v, "v")
+	return v != "" && v[0] >= '0' && v[0] <= '9'
+}
+
+// launcherAssetName — ассет релиза для платформы, как его называет ci.yml.
+// Catalina-сборка узнаётся по имени запущенного бандла.
+func launcherAssetName(version, goos, goarch, targetPath string) (string, error) {
+	prefix := "singbox-launcher-" + version
+	switch goos {
+	case "windows":
+		switch goarch {
+		case "amd64":
+			return prefix + "-win64.zip", nil
+		case "386":
+			return prefix + "-win7-32.zip", nil
+		}
+	case "darwin":
+		if strings.Contains(strings.ToLower(filepath.Base(targetPath)), "catalina") {
+			return prefix + "-macos-catalina.zip", nil
+		}
+		return prefix + "-macos.zip", nil
+	}
+	return "", fmt.Errorf("%w: no release asset for %s/%s", ErrLauncherUpdateUnsupported, goos, goarch)
+}
+
+// fetchLauncherRelease — релиз канала: stable — /releases/latest (GitHub
+// сам пропускает pre-release и черновики), prerelease — новейший по версии
+// из последних релизов, черновики пропускаются.
+func fetchLauncherRelease(ctx context.Context, channel string) (*ReleaseInfo, error) {
+	if channel != LauncherChannelPrerelease {
+		body, err := fetchReleaseFile(ctx, launcherReleasesAPI+"/latest")
+		if err != nil {
+			return nil, fmt.Errorf("launcher releases: %w", err)
+		}
+		var rel ReleaseInfo
+		if err := json.Unmarshal(body, &rel); err != nil {
+			return nil, fmt.Errorf("launcher releases: %w", err)
+		}
+		if rel.TagName == "" {
+			return nil, fmt.Errorf("launcher releases: no tag in the latest release")
+		}
+		return &rel, nil
+	}
+	body, err := fetchReleaseFile(ctx, launcherReleasesAPI+"?per_page=30")
+	if err != nil {
+		return nil, fmt.Errorf("launcher releases: %w", err)
+	}
+	var list []ReleaseInfo
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("launcher releases: %w", err)
+	}
+	var best *ReleaseInfo
+	for i := range list {
+		r := &list[i]
+		if r.Draft || !launcherVersionIsRelease(r.TagName) {
+			continue
+		}
+		if best == nil || CompareVersions(strings.TrimPrefix(r.TagName, "v"), strings.TrimPrefix(best.TagName, "v")) > 0 {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("launcher releases: nothing published")
+	}
+	return best, nil
+}
+
+// CheckLauncherUpdate ищет обновление в канале из настроек. nil без ошибки —
+// запущена самая новая версия.
+func (ac *AppController) CheckLauncherUpdate(ctx context.Context) (*LauncherRelease, error) {
+	t, err := currentLauncherTarget()
+	if err != nil {
+		return nil, err
+	}
+	channel, _ := ac.launcherUpdateSettings()
+	return checkLauncherUpdate(ctx, channel, constants.AppVersion, runtime.GOOS, runtime.GOARCH, t)
+}
+
+func checkLauncherUpdate(ctx context.Context, channel, current, goos, goarch string, t launcherTarget) (*LauncherRelease, error) {
+	if err := launcherUpdateUnsupported(current, goos, goarch, t); err != nil {
+		return nil, err
+	}
+	rel, err := fetchLauncherRelease(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	if CompareVersions(strings.TrimPrefix(current, "v"), strings.TrimPrefix(rel.TagName, "v")) >= 0 {
+		return nil, nil
+	}
+	name, err := launcherAssetName(rel.TagName, goos, goarch, t.Path)
+	if err != nil {
+		return nil, err
+	}
+	asset := findAsset(rel.Assets, name)
+	if asset == nil {
+		return nil, fmt.Errorf("%w: release %s has no %s", ErrLauncherUpdateUnsupported, rel.TagName, name)
+	}
+	return &LauncherRelease{
+		Version:    rel.TagName,
+		Prerelease: rel.Prerelease,
+		Asset:      asset.Name,
+		Size:       asset.Size,
+		release:    rel,
+		asset:      asset,
+	}, nil
+}
+
+// StageLauncherUpdate скачивает, проверяет и кладёт рядом с лаунчером
+// версию rel; заменит она запущенную на следующем старте. Прогресс — как у
+// загрузки ядра; канал закрывается по завершении.
+func (ac *AppController) StageLauncherUpdate(ctx context.Context, rel *LauncherRelease, progressChan chan DownloadProgress) {
+	defer close(progressChan)
+	t, err := currentLauncherTarget()
+	if err == nil {
+		err = ac.stageLauncherUpdate(ctx, t, rel, progressChan)
+	}
+	if err != nil {
+		debuglog.WarnLog("StageLauncherUpdate: %v", err)
+		progressChan <- DownloadProgress{Progress: 0, Message: err.Error(), Status: "error", Error: err}
+		return
+	}
+	progressChan <- DownloadProgress{Progress: 100, Message: fmt.Sprintf("Launcher %s is ready, restart to install", rel.Version), Status: "done"}
+}
+
+func (ac *AppController) stageLauncherUpdate(ctx context.Context, t launcherTarget, rel *LauncherRelease, progressChan chan DownloadProgress) error {
+	if rel == nil || rel.release == nil || rel.asset == nil {
+		return fmt.Errorf("no launcher release to install")
+	}
+	if !ac.launcherUpdateMu.TryLock() {
+		return ErrLauncherUpdateBusy
+	}
+	defer ac.launcherUpdateMu.Unlock()
+
+	if rec, ok := readLauncherUpdate(t); ok && rec.Phase == LauncherUpdateStaged && rec.Version == rel.Version {
+		if _, err := os.Stat(t.staged()); err == nil {
+			return nil
+		}
+	}
+
+	progressChan <- DownloadProgress{Progress: 2, Message: "Checking the release checksums...", Status: "downloading"}
+	digest, err := ac.resolveAssetDigest(ctx, rel.release, rel.asset)
+	if err != nil {
+		return err
+	}
+	// Ядро без опубликованного хэша ставится с предупреждением, лаунчер —
+	// нет: он сам и есть то, что проверяет всё остальное.
+	if digest.SHA256 == "" {
+		return fmt.Errorf("%w: %s publishes no checksum for %s", ErrDownloadIntegrity, rel.Version, rel.Asset)
+	}
+
+	// Временный каталог — рядом с целью: готовая версия переезжает на место
+	// через rename, а он работает только в пределах одного тома.
+	tmp, err := os.MkdirTemp(filepath.Dir(t.Path), ".launcher-update-")
+	if err != nil {
+		return fmt.Errorf("cannot write next to the launcher: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	archive := filepath.Join(tmp, rel.Asset)
+	if err := ac.downloadFile(ctx, rel.asset.BrowserDownloadURL, archive, progressChan, digest.SHA256); err != nil {
+		return err
+	}
+	progressChan <- DownloadProgress{Progress: 90, Message: "Extracting...", Status: "extracting"}
+	unpacked := filepath.Join(tmp, "x")
+	if err := extractLauncherZip(archive, unpacked); err != nil {
+		return err
+	}
+	payload, err := findLauncherPayload(unpacked, t)
+	if err != nil {
+		return err
+	}
+
+	staged := t.staged()
+	if err := os.RemoveAll(staged); err != nil {
+		return err
+	}
+	if err := os.Rename(payload, staged); err != nil {
+		return fmt.Errorf("stage %s: %w", staged, err)
+	}
+	if err := platform.ChmodExecutable(t.execIn(staged)); err != nil {
+		debuglog.WarnLog("StageLauncherUpdate: chmod %s: %v", t.execIn(staged), err)
+	}
+	sum, err := fileSHA256(t.execIn(staged))
+	if err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+	rec := launcherUpdateRecord{
+		Phase:         LauncherUpdateStaged,
+		Version:       rel.Version,
+		FromVersion:   constants.AppVersion,
+		Asset:         rel.Asset,
+		ArchiveSHA256: digest.SHA256,
+		VerifiedBy:    digest.VerifiedBy,
+		PayloadSHA256: sum,
+		StagedAt:      time.Now().UTC(),
+	}
+	if rel.Prerelease {
+		rec.Channel = LauncherChannelPrerelease
+	} else {
+		rec.Channel = LauncherChannelStable
+	}
+	if err := writeLauncherUpdate(t, rec); err != nil {
+		_ = os.RemoveAll(staged)
+		return err
+	}
+	debuglog.InfoLog("StageLauncherUpdate: %s staged at %s (verified by %s)", rel.Version, staged, digest.VerifiedBy)
+	return nil
+}
+
+// extractLauncherZip распаковывает архив релиза целиком. Пути с «..»,
+// абсолютные и ссылки наружу отклоняются: архив проверен по хэшу, но
+// распаковка всё равно не должна уметь писать за пределы dest.
+func extractLauncherZip(archive, dest string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", filepath.Base(archive), err)
+	}
+	defer debuglog.RunAndLog("extractLauncherZip: close zip reader", r.Close)
+
+	var total int64
+	for _, f := range r.File {
+		name := path.Clean(strings.ReplaceAll(f.Name, `\`, "/"))
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%w: unsafe path %q in the archive", ErrDownloadIntegrity, f.Name)
+		}
+		out := filepath.Join(dest, filepath.FromSlash(name))
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(out, 0o755); err != nil {
+				return err
+			}
+			continue
+		case mode&os.ModeSymlink != 0:
+			link, err := readZipEntry(f, 4096)
+			if err != nil {
+				return err
+			}
+			target := path.Clean(path.Join(path.Dir(name), string(link)))
+			if path.IsAbs(string(link)) || target == ".." || strings.HasPrefix(target, "../") {
+				return fmt.Errorf("%w: symlink %q points outside the archive", ErrDownloadIntegrity, f.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+				return err
+			}
+			if err := os.Symlink(string(link), out); err != nil {
+				return err
+			}
+			continue
+		}
+		total += int64(f.UncompressedSize64)
+		if total > maxLauncherArchiveUnpacked {
+			return fmt.Errorf("%w: archive unpacks to more than %d bytes", ErrDownloadIntegrity, maxLauncherArchiveUnpacked)
+		}
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		if err := writeZipEntry(f, out, mode.Perm()|0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZipEntry(f *zip.File, limit int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer debuglog.RunAndLog("extractLauncherZip: close zip entry", rc.Close)
+	return io.ReadAll(io.LimitReader(rc, limit))
+}
+
+func writeZipEntry(f *zip.File, out string, perm os.FileMode) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer debuglog.RunAndLog("extractLauncherZip: close zip entry", rc.Close)
+	w, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// findLauncherPayload — что в распакованном архиве заменит t: бандл .app, в
+// котором есть исполняемый файл того же относительного пути, или (Windows)
+// единственный .exe. Имя в архиве может отличаться от запущенного
+// (singbox-launcher-win7-32.exe): ставится под именем запущенного.
+func findLauncherPayload(dir string, t launcherTarget) (string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if t.Bundle {
+			if d.IsDir() && strings.HasSuffix(d.Name(), ".app") {
+				if fi, err := os.Stat(t.execIn(p)); err == nil && fi.Mode().IsRegular() {
+					found = append(found, p)
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && strings.EqualFold(filepath.Ext(d.Name()), filepath.Ext(t.Path)) {
+			found = append(found, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(found) != 1 {
+		return "", fmt.Errorf("%w: expected one launcher %s in the archive, found %d",
+			ErrDownloadIntegrity, filepath.Base(t.Path), len(found))
+	}
+	return found[0], nil
+}
+
+// LauncherUpdateStatus — состояние самообновления для настроек и попапа.
+func (ac *AppController) LauncherUpdateStatus() LauncherUpdateState {
+	st := LauncherUpdateState{}
+	st.Channel, st.Auto = ac.launcherUpdateSettings()
+	t, err := currentLauncherTarget()
+	if err != nil {
+		st.Unsupported = err.Error()
+		return st
+	}
+	if err := launcherUpdateUnsupported(constants.AppVersion, runtime.GOOS, runtime.GOARCH, t); err != nil {
+		st.Unsupported = err.Error()
+	}
+	if ac.launcherUpdateMu.TryLock() {
+		ac.launcherUpdateMu.Unlock()
+	} else {
+		st.Busy = true
+	}
+	if rec, ok := readLauncherUpdate(t); ok {
+		st.Phase, st.Version, st.FromVersion = rec.Phase, rec.Version, rec.FromVersion
+		st.VerifiedBy, st.Reason = rec.VerifiedBy, rec.Reason
+	}
+	if _, err := os.Stat(t.previous()); err == nil {
+		st.CanRollback = true
+	}
+	return st
+}
+
+// RequestLauncherRollback просит следующий старт вернуть прежнюю версию
+// (<exe>.old). Сам откат — в ApplyPendingLauncherUpdate: запущенный бинарь
+// заменять нельзя.
+func (ac *AppController) RequestLauncherRollback() error {
+	t, err := currentLauncherTarget()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(t.previous()); err != nil {
+		return fmt.Errorf("no previous launcher version to roll back to")
+	}
+	rec, _ := readLauncherUpdate(t)
+	rec.Phase, rec.Version = LauncherUpdateRollback, constants.AppVersion
+	return writeLauncherUpdate(t, rec)
+}
+
+// RestartLauncher запускает новый экземпляр с теми же аргументами (он и
+// применит подготовленное обновление) и завершает текущий.
+func (ac *AppController) RestartLauncher() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := RelaunchLauncher(exe); err != nil {
+		return err
+	}
+	go ac.GracefulExit(audit.ActorSystem)
+	return nil
+}
+
+// autoStageLauncherUpdate — фоновая подготовка после проверки версии на
+// старте (Settings.LauncherUpdateAuto). Версию, с которой уже был откат,
+// повторно не ставит: её вернёт только кнопка.
+func (ac *AppController) autoStageLauncherUpdate(ctx context.Context) {
+	rel, err := ac.CheckLauncherUpdate(ctx)
+	if err != nil || rel == nil {
+		if err != nil && !errors.Is(err, ErrLauncherUpdateUnsupported) {
+			debuglog.WarnLog("autoStageLauncherUpdate: %v", err)
+		}
+		return
+	}
+	if st := ac.LauncherUpdateStatus(); st.Phase == LauncherUpdateRolledBack && st.Version == rel.Version {
+		debuglog.InfoLog("autoStageLauncherUpdate: %s was rolled back before, not staging it again", rel.Version)
+		return
+	}
+	progress := make(chan DownloadProgress, 16)
+	go ac.StageLauncherUpdate(ctx, rel, progress)
+	for p := range progress {
+		if p.Status == "done" {
+			debuglog.InfoLog("autoStageLauncherUpdate: %s staged, it installs on the next start", rel.Version)
+		}
+	}
+}
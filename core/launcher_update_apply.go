@@ -0,0 +1,424 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+)
+
+// Применение и откат подготовленного обновления (launcher_update.go).
+//
+// Всё здесь выполняется в самом начале main, до контроллера и окна: пока
+// ничего не открыто, бинари можно менять местами, а новая версия стартует
+// уже в заменённом файле. После замены запись переходит в «applied» и
+// считает старты новой версии; ConfirmLauncherUpdate через полминуты
+// нормальной работы подтверждает её. Не дожила до подтверждения
+// maxUnconfirmedLauncherStarts раз подряд — следующий старт возвращает
+// прежний бинарь и резервную копию bin/.
+
+// launcherUpdateFile — запись о подготовленном/применённом обновлении, bin/.
+const launcherUpdateFile = "launcher_update.json"
+
+// launcherBackupDirName — копия настроек bin/ перед заменой: новая версия
+// может мигрировать их в формат, которого прежняя не поймёт.
+const launcherBackupDirName = "update-backup"
+
+// launcherBackupMaxFile — файлы крупнее в копию не идут: это кэши и
+// скачанное, их пересоздаст любая версия.
+const launcherBackupMaxFile = 8 << 20
+
+// maxUnconfirmedLauncherStarts — сколько стартов новой версии без
+// подтверждения терпится до отката.
+const maxUnconfirmedLauncherStarts = 2
+
+// launcherConfirmDelay — сколько новая версия должна проработать, чтобы
+// считаться рабочей.
+const launcherConfirmDelay = 30 * time.Second
+
+// Фазы записи bin/launcher_update.json (LauncherUpdateState.Phase).
+const (
+	LauncherUpdateStaged     = "staged"      // <target>.new ждёт старта
+	LauncherUpdateApplied    = "applied"     // заменено, ждёт подтверждения
+	LauncherUpdateConfirmed  = "confirmed"   // новая версия работает
+	LauncherUpdateRollback   = "rollback"    // пользователь попросил откат
+	LauncherUpdateRolledBack = "rolled_back" // прежняя версия возвращена
+)
+
+// launcherBackupSkipDirs — каталоги bin/, которые в копию не идут: ядра,
+// rule-sets и кэши подписок скачиваются заново, сама копия — тем более.
+var launcherBackupSkipDirs = map[string]bool{
+	launcherBackupDirName:           true,
+	constants.CoreStoreDirName:      true,
+	constants.RuleSetsDirName:       true,
+	constants.LogsDirName:           true,
+	constants.SubscriptionsDirName:  true,
+	constants.RestoreBackupsDirName: true,
+}
+
+type launcherUpdateRecord struct {
+	Phase         string    `json:"phase"`
+	Version       string    `json:"version"`
+	FromVersion   string    `json:"from_version,omitempty"`
+	Channel       string    `json:"channel,omitempty"`
+	Asset         string    `json:"asset,omitempty"`
+	ArchiveSHA256 string    `json:"archive_sha256,omitempty"`
+	VerifiedBy    string    `json:"verified_by,omitempty"`
+	PayloadSHA256 string    `json:"payload_sha256,omitempty"`
+	StagedAt      time.Time `json:"staged_at,omitempty"`
+	AppliedAt     time.Time `json:"applied_at,omitempty"`
+	// Starts — старты новой версии без подтверждения.
+	Starts int    `json:"starts,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// launcherTarget — что меняется при обновлении. На Windows это сам .exe, на
+// macOS — весь бандл .app: ресурсы и Info.plist версии должны совпадать с
+// бинарём. DataDir — каталог с bin/ и logs/ (FileService.ExecDir); на macOS
+// он внутри бандла, и при замене его содержимое переезжает в новый.
+type launcherTarget struct {
+	Path    string
+	Exec    string
+	DataDir string
+	Bundle  bool
+}
+
+func launcherTargetFor(exe string) launcherTarget {
+	dir := filepath.Dir(exe)
+	t := launcherTarget{Path: exe, Exec: exe, DataDir: dir}
+	if filepath.Base(dir) == "MacOS" && filepath.Base(filepath.Dir(dir)) == "Contents" {
+		if app := filepath.Dir(filepath.Dir(dir)); strings.HasSuffix(app, ".app") {
+			t.Path, t.Bundle = app, true
+		}
+	}
+	return t
+}
+
+func (t launcherTarget) staged() string   { return t.Path + ".new" }
+func (t launcherTarget) previous() string { return t.Path + ".old" }
+func (t launcherTarget) failed() string   { return t.Path + ".failed" }
+
+// execIn — исполняемый файл в копии цели root (бандл или сам файл).
+func (t launcherTarget) execIn(root string) string {
+	return t.relocate(t.Exec, root)
+}
+
+// dataDirIn — каталог данных в копии цели root.
+func (t launcherTarget) dataDirIn(root string) string {
+	return t.relocate(t.DataDir, root)
+}
+
+func (t launcherTarget) relocate(p, root string) string {
+	if !t.Bundle {
+		if p == t.Path {
+			return root
+		}
+		return p
+	}
+	rel, err := filepath.Rel(t.Path, p)
+	if err != nil {
+		return p
+	}
+	return filepath.Join(root, rel)
+}
+
+func (t launcherTarget) binDir() string {
+	return filepath.Join(t.DataDir, constants.BinDirName)
+}
+
+func readLauncherUpdate(t launcherTarget) (launcherUpdateRecord, bool) {
+	var rec launcherUpdateRecord
+	data, err := os.ReadFile(filepath.Join(t.binDir(), launcherUpdateFile))
+	if err != nil {
+		return rec, false
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		debuglog.WarnLog("launcher update: %s: %v", launcherUpdateFile, err)
+		return rec, false
+	}
+	return rec, true
+}
+
+func writeLauncherUpdate(t launcherTarget, rec launcherUpdateRecord) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.binDir(), 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(t.binDir(), launcherUpdateFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ApplyPendingLauncherUpdate применяет подготовленное обновление или откат.
+// Возвращает путь бинаря, который надо запустить вместо текущего процесса
+// (RelaunchLauncher), или "" — продолжать как обычно. Вызывается из main до
+// создания контроллера; CLI-подкоманды его не вызывают.
+func ApplyPendingLauncherUpdate() string {
+	t, err := currentLauncherTarget()
+	if err != nil {
+		return ""
+	}
+	relaunch, err := applyPendingLauncherUpdate(t, constants.AppVersion)
+	if err != nil {
+		debuglog.ErrorLog("launcher update: %v", err)
+	}
+	return relaunch
+}
+
+func applyPendingLauncherUpdate(t launcherTarget, running string) (string, error) {
+	rec, ok := readLauncherUpdate(t)
+	if !ok {
+		return "", nil
+	}
+	switch rec.Phase {
+	case LauncherUpdateStaged:
+		return applyStagedLauncher(t, rec, running)
+	case LauncherUpdateApplied:
+		// Прежний бинарь вернули руками — считать нечего.
+		if rec.Version != running {
+			return "", nil
+		}
+		rec.Starts++
+		if rec.Starts > maxUnconfirmedLauncherStarts {
+			return rollbackLauncher(t, rec,
+				fmt.Sprintf("%s did not keep running for %s, %d starts in a row", rec.Version, launcherConfirmDelay, rec.Starts-1))
+		}
+		return "", writeLauncherUpdate(t, rec)
+	case LauncherUpdateRollback:
+		return rollbackLauncher(t, rec, "requested by the user")
+	}
+	return "", nil
+}
+
+func applyStagedLauncher(t launcherTarget, rec launcherUpdateRecord, running string) (string, error) {
+	staged := t.staged()
+	// Подготовленная версия пролежала до старта неизвестно сколько: хэш
+	// проверяется ещё раз, подменённая или недописанная не ставится. Так же
+	// отбрасывается версия не новее запущенной (лаунчер обновили руками).
+	err := fmt.Errorf("no payload checksum recorded")
+	if rec.PayloadSHA256 != "" {
+		err = checkFileSHA256(t.execIn(staged), rec.PayloadSHA256)
+	}
+	if err == nil && launcherVersionIsRelease(running) &&
+		CompareVersions(strings.TrimPrefix(running, "v"), strings.TrimPrefix(rec.Version, "v")) >= 0 {
+		err = fmt.Errorf("%s is already running", running)
+	}
+	if err != nil {
+		_ = os.RemoveAll(staged)
+		_ = os.Remove(filepath.Join(t.binDir(), launcherUpdateFile))
+		return "", fmt.Errorf("staged %s discarded: %w", rec.Version, err)
+	}
+	if err := backupLauncherData(t.binDir()); err != nil {
+		return "", fmt.Errorf("backup of bin/ before %s failed, update postponed: %w", rec.Version, err)
+	}
+
+	prev := t.previous()
+	if err := os.RemoveAll(prev); err != nil {
+		return "", err
+	}
+	if err := os.Rename(t.Path, prev); err != nil {
+		return "", fmt.Errorf("move %s aside: %w", t.Path, err)
+	}
+	if err := os.Rename(staged, t.Path); err != nil {
+		if rerr := os.Rename(prev, t.Path); rerr != nil {
+			return "", fmt.Errorf("install %s: %v; restoring the previous launcher failed too: %w", rec.Version, err, rerr)
+		}
+		return "", fmt.Errorf("install %s: %w", rec.Version, err)
+	}
+	if t.Bundle {
+		if err := moveLauncherRuntime(t.dataDirIn(prev), t.DataDir); err != nil {
+			debuglog.ErrorLog("launcher update: carrying data into the new bundle: %v", err)
+		}
+	}
+
+	rec.Phase, rec.AppliedAt, rec.Starts, rec.Reason = LauncherUpdateApplied, time.Now().UTC(), 0, ""
+	if err := writeLauncherUpdate(t, rec); err != nil {
+		return "", err
+	}
+	debuglog.InfoLog("launcher update: %s installed over %s, previous kept at %s", rec.Version, rec.FromVersion, prev)
+	return t.Exec, nil
+}
+
+func rollbackLauncher(t launcherTarget, rec launcherUpdateRecord, reason string) (string, error) {
+	prev := t.previous()
+	if _, err := os.Stat(prev); err != nil {
+		rec.Phase, rec.Reason = LauncherUpdateConfirmed, "rollback impossible: no previous launcher"
+		return "", writeLauncherUpdate(t, rec)
+	}
+	failed := t.failed()
+	if err := os.RemoveAll(failed); err != nil {
+		return "", err
+	}
+	if t.Bundle {
+		if err := moveLauncherRuntime(t.DataDir, t.dataDirIn(prev)); err != nil {
+			debuglog.ErrorLog("launcher rollback: carrying data into the previous bundle: %v", err)
+		}
+	}
+	if err := os.Rename(t.Path, failed); err != nil {
+		return "", fmt.Errorf("move %s aside: %w", t.Path, err)
+	}
+	if err := os.Rename(prev, t.Path); err != nil {
+		if rerr := os.Rename(failed, t.Path); rerr != nil {
+			return "", fmt.Errorf("restore the previous launcher: %v; putting back %s failed too: %w", err, rec.Version, rerr)
+		}
+		return "", fmt.Errorf("restore the previous launcher: %w", err)
+	}
+	if err := restoreLauncherData(t.binDir()); err != nil {
+		debuglog.ErrorLog("launcher rollback: restoring bin/ backup: %v", err)
+	}
+	rec.Phase, rec.Reason = LauncherUpdateRolledBack, reason
+	if err := writeLauncherUpdate(t, rec); err != nil {
+		return "", err
+	}
+	debuglog.WarnLog("launcher update: rolled back from %s (%s)", rec.Version, reason)
+	return t.Exec, nil
+}
+
+// backupLauncherData копирует небольшие файлы bin/ в bin/update-backup/,
+// заменяя прежнюю копию.
+func backupLauncherData(binDir string) error {
+	dst := filepath.Join(binDir, launcherBackupDirName)
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if _, err := os.Stat(binDir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(binDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(binDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() {
+			if launcherBackupSkipDirs[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || rel == launcherUpdateFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > launcherBackupMaxFile {
+			return err
+		}
+		return copyLauncherFile(p, filepath.Join(dst, rel), info.Mode().Perm())
+	})
+}
+
+// restoreLauncherData возвращает файлы из bin/update-backup/ на место.
+// Файлы, которых в копии нет (созданные новой версией), остаются: прежняя
+// версия их просто не читает.
+func restoreLauncherData(binDir string) error {
+	src := filepath.Join(binDir, launcherBackupDirName)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyLauncherFile(p, filepath.Join(binDir, rel), info.Mode().Perm())
+	})
+}
+
+func copyLauncherFile(src, dst string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer debuglog.RunAndLog("copyLauncherFile: close "+src, in.Close)
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// moveLauncherRuntime переносит из from в to всё, чего в to нет: на macOS
+// bin/, logs/ и прочее созданное лаунчером лежит внутри бандла рядом с
+// бинарём, а бинарь и ресурсы новой версии уже на месте.
+func moveLauncherRuntime(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		dst := filepath.Join(to, e.Name())
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(from, e.Name()), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfirmLauncherUpdate — новая версия проработала launcherConfirmDelay:
+// отката при следующих стартах не будет. Планируется из main (GUI и
+// headless) сразу после старта.
+func (ac *AppController) ConfirmLauncherUpdate() {
+	time.AfterFunc(launcherConfirmDelay, func() {
+		t, err := currentLauncherTarget()
+		if err != nil {
+			return
+		}
+		confirmLauncherUpdate(t, constants.AppVersion)
+	})
+}
+
+func confirmLauncherUpdate(t launcherTarget, running string) {
+	rec, ok := readLauncherUpdate(t)
+	if !ok || rec.Phase != LauncherUpdateApplied || rec.Version != running {
+		return
+	}
+	rec.Phase, rec.Starts = LauncherUpdateConfirmed, 0
+	if err := writeLauncherUpdate(t, rec); err != nil {
+		debuglog.WarnLog("ConfirmLauncherUpdate: %v", err)
+		return
+	}
+	debuglog.InfoLog("ConfirmLauncherUpdate: %s confirmed", rec.Version)
+}
+
+// RelaunchLauncher запускает exe с аргументами текущего процесса. Выходить
+// из текущего — забота вызывающего.
+func RelaunchLauncher(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %s: %w", exe, err)
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLauncherAssetName(t *testing.T) {
+	for _, c := range []struct{ goos, goarch, target, want string }{
+		{"windows", "amd64", `C:\LxBox\singbox-launcher.exe`, "singbox-launcher-v1.2.3-win64.zip"},
+		{"windows", "386", `C:\LxBox\singbox-launcher.exe`, "singbox-launcher-v1.2.3-win7-32.zip"},
+		{"darwin", "arm64", "/Applications/singbox-launcher.app", "singbox-launcher-v1.2.3-macos.zip"},
+		{"darwin", "amd64", "/Applications/singbox-launcher-macos-catalina.app", "singbox-launcher-v1.2.3-macos-catalina.zip"},
+	} {
+		got, err := launcherAssetName("v1.2.3", c.goos, c.goarch, c.target)
+		if err != nil || got != c.want {
+			t.Errorf("%s/%s: %q, %v; want %q", c.goos, c.goarch, got, err, c.want)
+		}
+	}
+	if _, err := launcherAssetName("v1.2.3", "linux", "amd64", "/opt/lxbox/singbox-launcher"); !errors.Is(err, ErrLauncherUpdateUnsupported) {
+		t.Errorf("linux: %v", err)
+	}
+	if err := launcherUpdateUnsupported("v-local-test", "windows", "amd64", launcherTarget{}); !errors.Is(err, ErrLauncherUpdateUnsupported) {
+		t.Errorf("dev build: %v", err)
+	}
+	mac := launcherTargetFor("/Applications/singbox-launcher.app/Contents/MacOS/singbox-launcher")
+	if !mac.Bundle || mac.Path != "/Applications/singbox-launcher.app" ||
+		mac.execIn(mac.staged()) != "/Applications/singbox-launcher.app.new/Contents/MacOS/singbox-launcher" {
+		t.Errorf("macOS target = %+v", mac)
+	}
+}
+
+// launcherReleaseServer — GitHub releases API на httptest: релизы, ассет и
+// checksums.txt в формате ci.yml («./<имя>»).
+type launcherReleaseServer struct {
+	*httptest.Server
+	archive  []byte
+	sums     string
+	releases []ReleaseInfo
+}
+
+func newLauncherReleaseServer(t *testing.T, payload []byte, payloadName string) *launcherReleaseServer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("dist/" + payloadName)
+	if err == nil {
+		_, err = w.Write(payload)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &launcherReleaseServer{archive: buf.Bytes()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		for _, rel := range s.releases {
+			if !rel.Prerelease && !rel.Draft {
+				_ = json.NewEncoder(w).Encode(rel)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/releases", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(s.releases)
+	})
+	mux.HandleFunc("/download/asset.zip", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(s.archive) })
+	mux.HandleFunc("/download/checksums.txt", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(s.sums)) })
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	old := launcherReleasesAPI
+	launcherReleasesAPI = s.URL + "/releases"
+	t.Cleanup(func() { launcherReleasesAPI = old })
+	return s
+}
+
+// release — релиз tag с ассетом win64 и checksums.txt.
+func (s *launcherReleaseServer) release(tag string, prerelease bool) ReleaseInfo {
+	name, _ := launcherAssetName(tag, "windows", "amd64", "")
+	sum := sha256.Sum256(s.archive)
+	s.sums = hex.EncodeToString(sum[:]) + "  ./" + name + "\n"
+	return ReleaseInfo{TagName: tag, Prerelease: prerelease, Assets: []Asset{
+		{Name: name, BrowserDownloadURL: s.URL + "/download/asset.zip", Size: int64(len(s.archive))},
+		{Name: "checksums.txt", BrowserDownloadURL: s.URL + "/download/checksums.txt"},
+	}}
+}
+
+func stageForTest(t *testing.T, ac *AppController, target launcherTarget, rel *LauncherRelease) error {
+	t.Helper()
+	progress := make(chan DownloadProgress)
+	go func() {
+		for range progress {
+		}
+	}()
+	defer close(progress)
+	return ac.stageLauncherUpdate(context.Background(), target, rel, progress)
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLauncherUpdateStageApplyRollback(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "singbox-launcher")
+	if err := os.WriteFile(exe, []byte("launcher v1.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	target := launcherTargetFor(exe)
+	bin := filepath.Join(dir, "bin")
+	settings := filepath.Join(bin, "settings.json")
+	_ = os.MkdirAll(filepath.Join(bin, "cores", "1.13.0"), 0o755)
+	_ = os.WriteFile(settings, []byte(`{"lang":"en"}`), 0o644)
+	_ = os.WriteFile(filepath.Join(bin, "cores", "1.13.0", "sing-box"), []byte("core"), 0o755)
+
+	srv := newLauncherReleaseServer(t, []byte("launcher v1.1.0"), "singbox-launcher")
+	srv.releases = []ReleaseInfo{srv.release("v1.1.0", false)}
+	ctx := context.Background()
+
+	if rel, err := checkLauncherUpdate(ctx, LauncherChannelStable, "v1.1.0", "windows", "amd64", target); err != nil || rel != nil {
+		t.Fatalf("up to date: %+v, %v", rel, err)
+	}
+	rel, err := checkLauncherUpdate(ctx, LauncherChannelStable, "v1.0.0", "windows", "amd64", target)
+	if err != nil || rel == nil || rel.Version != "v1.1.0" {
+		t.Fatalf("check: %+v, %v", rel, err)
+	}
+
+	ac := &AppController{}
+	if err := stageForTest(t, ac, target, rel); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, target.staged()); got != "launcher v1.1.0" {
+		t.Fatalf("staged = %q", got)
+	}
+	if got := readString(t, exe); got != "launcher v1.0.0" {
+		t.Fatalf("running binary touched by staging: %q", got)
+	}
+	rec, ok := readLauncherUpdate(target)
+	if !ok || rec.Phase != LauncherUpdateStaged || rec.VerifiedBy != verifiedByChecksums || rec.Version != "v1.1.0" {
+		t.Fatalf("record after staging = %+v", rec)
+	}
+
+	// Следующий старт: замена, прежний бинарь — .old, копия bin/ без ядер.
+	relaunch, err := applyPendingLauncherUpdate(target, "v1.0.0")
+	if err != nil || relaunch != exe {
+		t.Fatalf("apply: %q, %v", relaunch, err)
+	}
+	if readString(t, exe) != "launcher v1.1.0" || readString(t, target.previous()) != "launcher v1.0.0" {
+		t.Fatal("binaries not swapped")
+	}
+	backup := filepath.Join(bin, launcherBackupDirName)
+	if readString(t, filepath.Join(backup, "settings.json")) != `{"lang":"en"}` {
+		t.Error("settings.json not backed up")
+	}
+	if _, err := os.Stat(filepath.Join(backup, "cores")); !os.IsNotExist(err) {
+		t.Error("core store copied into the backup")
+	}
+
+	// Новая версия мигрирует настройки и дважды не доживает до подтверждения.
+	_ = os.WriteFile(settings, []byte(`{"lang":"en","v":2}`), 0o644)
+	for i := 0; i < maxUnconfirmedLauncherStarts; i++ {
+		if relaunch, err := applyPendingLauncherUpdate(target, "v1.1.0"); err != nil || relaunch != "" {
+			t.Fatalf("start %d: %q, %v", i+1, relaunch, err)
+		}
+	}
+	relaunch, err = applyPendingLauncherUpdate(target, "v1.1.0")
+	if err != nil || relaunch != exe {
+		t.Fatalf("rollback: %q, %v", relaunch, err)
+	}
+	if readString(t, exe) != "launcher v1.0.0" || readString(t, target.failed()) != "launcher v1.1.0" {
+		t.Fatal("previous binary not restored")
+	}
+	if got := readString(t, settings); got != `{"lang":"en"}` {
+		t.Errorf("settings.json after rollback = %s", got)
+	}
+	if rec, _ := readLauncherUpdate(target); rec.Phase != LauncherUpdateRolledBack || rec.Reason == "" {
+		t.Errorf("record after rollback = %+v", rec)
+	}
+	// Старая версия стартует спокойно: запись о ней не считает её старты.
+	if relaunch, err := applyPendingLauncherUpdate(target, "v1.0.0"); err != nil || relaunch != "" {
+		t.Errorf("start after rollback: %q, %v", relaunch, err)
+	}
+}
+
+func TestLauncherUpdateConfirmAndTamper(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "singbox-launcher")
+	_ = os.WriteFile(exe, []byte("launcher v1.0.0"), 0o755)
+	target := launcherTargetFor(exe)
+	srv := newLauncherReleaseServer(t, []byte("launcher v1.2.0-rc"), "singbox-launcher")
+	srv.releases = []ReleaseInfo{srv.release("v1.1.0", false), srv.release("v1.2.0-3-gabc-prerelease", true)}
+	ctx := context.Background()
+
+	rel, err := checkLauncherUpdate(ctx, LauncherChannelPrerelease, "v1.0.0", "windows", "amd64", target)
+	if err != nil || rel == nil || rel.Version != "v1.2.0-3-gabc-prerelease" || !rel.Prerelease {
+		t.Fatalf("prerelease channel: %+v, %v", rel, err)
+	}
+	ac := &AppController{}
+	if err := stageForTest(t, ac, target, rel); err != nil {
+		t.Fatal(err)
+	}
+	// Подменённая после подготовки версия не ставится.
+	_ = os.WriteFile(target.staged(), []byte("tampered"), 0o755)
+	if relaunch, err := applyPendingLauncherUpdate(target, "v1.0.0"); err == nil || relaunch != "" {
+		t.Fatalf("tampered payload applied: %q, %v", relaunch, err)
+	}
+	if _, err := os.Stat(target.staged()); !os.IsNotExist(err) {
+		t.Error("tampered payload left in place")
+	}
+
+	if err := stageForTest(t, ac, target, rel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyPendingLauncherUpdate(target, "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	confirmLauncherUpdate(target, rel.Version)
+	if rec, _ := readLauncherUpdate(target); rec.Phase != LauncherUpdateConfirmed {
+		t.Fatalf("record after confirm = %+v", rec)
+	}
+	for i := 0; i <= maxUnconfirmedLauncherStarts; i++ {
+		if relaunch, _ := applyPendingLauncherUpdate(target, rel.Version); relaunch != "" {
+			t.Fatal("confirmed version rolled back")
+		}
+	}
+
+	// Без опубликованного хэша лаунчер не ставится.
+	srv.releases[0].Assets = srv.releases[0].Assets[:1]
+	rel, err = checkLauncherUpdate(ctx, LauncherChannelStable, "v1.0.0", "windows", "amd64", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stageForTest(t, ac, target, rel); !errors.Is(err, ErrDownloadIntegrity) {
+		t.Errorf("staging without a checksum: %v", err)
+	}
+}
+
+func TestExtractLauncherZipRejectsEscapes(t *testing.T) {
+	for _, name := range []string{"../evil", "a/../../evil", "/abs/evil"} {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte("x"))
+		_ = zw.Close()
+		archive := filepath.Join(t.TempDir(), "a.zip")
+		_ = os.WriteFile(archive, buf.Bytes(), 0o644)
+		if err := extractLauncherZip(archive, filepath.Join(t.TempDir(), "out")); !errors.Is(err, ErrDownloadIntegrity) {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"strings"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/state"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/platform"
@@ -82,7 +83,7 @@ func ApplyLogLevelAndReloadCore(ac *AppController, level string) error {
 		return fmt.Errorf("rebuild config: %w", err)
 	}
 	if ac.RunningState != nil && ac.RunningState.IsRunning() {
-		KillSingBoxForRestart()
+		KillSingBoxForRestart(audit.ActorSystem)
 		debuglog.InfoLog("core: requested sing-box restart for log_level=%s", level)
 	}
 	return nil
@@ -0,0 +1,108 @@
+package netdiag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	daemonpb "singbox-launcher/internal/daemonpb"
+)
+
+// QualityFromProto раскладывает кадр StartNetworkQualityTest. Ёмкость демон
+// шлёт в бит/с (как networkQuality), задержку — idle latency в мс.
+func QualityFromProto(p *daemonpb.NetworkQualityTestProgress, via Via) QualityResult {
+	if p == nil {
+		return QualityResult{At: time.Now(), Via: via}
+	}
+	return QualityResult{
+		At:          time.Now(),
+		Via:         via,
+		LatencyMs:   float64(p.GetIdleLatencyMs()),
+		DownloadBps: p.GetDownloadCapacity(),
+		UploadBps:   p.GetUploadCapacity(),
+		DownloadRPM: p.GetDownloadRPM(),
+		UploadRPM:   p.GetUploadRPM(),
+		Error:       p.GetError(),
+	}
+}
+
+// NATFromProto раскладывает кадр StartSTUNTest.
+func NATFromProto(p *daemonpb.STUNTestProgress, via Via, server string) NATResult {
+	if p == nil {
+		return NATResult{At: time.Now(), Via: via, Server: server}
+	}
+	return NATResult{
+		At:               time.Now(),
+		Via:              via,
+		Server:           server,
+		UDPReachable:     p.GetExternalAddr() != "",
+		ExternalAddr:     p.GetExternalAddr(),
+		LatencyMs:        float64(p.GetLatencyMs()),
+		Mapping:          NATBehavior(p.GetNatMapping()),
+		Filtering:        NATBehavior(p.GetNatFiltering()),
+		NATTypeSupported: p.GetNatTypeSupported(),
+		Error:            p.GetError(),
+	}
+}
+
+// RunDaemonQuality гоняет StartNetworkQualityTest через outbound tag демона
+// client и ждёт финального кадра. Промежуточные кадры уходят в onProgress.
+func RunDaemonQuality(ctx context.Context, client daemonpb.StartedServiceClient, via Via, tag string, opts QualityOptions, onProgress func(QualityResult)) (QualityResult, error) {
+	opts = opts.withDefaults()
+	stream, err := client.StartNetworkQualityTest(ctx, &daemonpb.NetworkQualityTestRequest{
+		ConfigURL:         opts.ConfigURL,
+		OutboundTag:       tag,
+		MaxRuntimeSeconds: int32(2 * opts.Duration / time.Second),
+	})
+	if err != nil {
+		return QualityResult{At: time.Now(), Via: via}, fmt.Errorf("StartNetworkQualityTest: %w", err)
+	}
+	var last QualityResult
+	for {
+		p, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) && !last.At.IsZero() {
+				// Сервер закрыл стрим без IsFinal — берём последнее, что есть.
+				return last, nil
+			}
+			return last, fmt.Errorf("StartNetworkQualityTest: %w", err)
+		}
+		last = QualityFromProto(p, via)
+		if p.GetIsFinal() {
+			if last.Error != "" && last.DownloadBps == 0 && last.UploadBps == 0 && last.LatencyMs == 0 {
+				return last, errors.New(last.Error)
+			}
+			return last, nil
+		}
+		if onProgress != nil {
+			onProgress(last)
+		}
+	}
+}
+
+// RunDaemonNAT гоняет StartSTUNTest через outbound tag демона client.
+func RunDaemonNAT(ctx context.Context, client daemonpb.StartedServiceClient, via Via, tag, server string) (NATResult, error) {
+	stream, err := client.StartSTUNTest(ctx, &daemonpb.STUNTestRequest{Server: server, OutboundTag: tag})
+	if err != nil {
+		return NATResult{At: time.Now(), Via: via, Server: server}, fmt.Errorf("StartSTUNTest: %w", err)
+	}
+	last := NATResult{At: time.Now(), Via: via, Server: server}
+	for {
+		p, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) && last.ExternalAddr != "" {
+				return last, nil
+			}
+			return last, fmt.Errorf("StartSTUNTest: %w", err)
+		}
+		last = NATFromProto(p, via, server)
+		if p.GetIsFinal() {
+			if last.Error != "" && !last.UDPReachable {
+				return last, errors.New(last.Error)
+			}
+			return last, nil
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package netdiag
+
+import (
+	"testing"
+
+	daemonpb "singbox-launcher/internal/daemonpb"
+)
+
+func TestQualityFromProto(t *testing.T) {
+	r := QualityFromProto(&daemonpb.NetworkQualityTestProgress{
+		DownloadCapacity: 95_000_000,
+		UploadCapacity:   20_000_000,
+		DownloadRPM:      800,
+		IdleLatencyMs:    42,
+		IsFinal:          true,
+	}, ViaDaemon)
+	if r.Via != ViaDaemon || r.LatencyMs != 42 || r.DownloadBps != 95_000_000 || r.UploadBps != 20_000_000 || r.DownloadRPM != 800 {
+		t.Fatalf("got %+v", r)
+	}
+	if FormatBitrate(r.DownloadBps) != "95.0 Mbit/s" {
+		t.Fatalf("FormatBitrate = %q", FormatBitrate(r.DownloadBps))
+	}
+}
+
+func TestNATFromProto(t *testing.T) {
+	r := NATFromProto(&daemonpb.STUNTestProgress{
+		ExternalAddr:     "203.0.113.7:40000",
+		LatencyMs:        31,
+		NatMapping:       int32(NATEndpointIndependent),
+		NatFiltering:     int32(NATAddressDependent),
+		NatTypeSupported: true,
+	}, ViaRemote, "stun.example.com:3478")
+	if !r.UDPReachable || r.NATType() != "restricted cone" || r.Server != "stun.example.com:3478" {
+		t.Fatalf("got %+v (%s)", r, r.NATType())
+	}
+	sym := NATResult{NATTypeSupported: true, Mapping: NATAddressPortDependent, Filtering: NATAddressPortDependent}
+	if sym.NATType() != "symmetric" {
+		t.Fatalf("NAT type = %q", sym.NATType())
+	}
+}
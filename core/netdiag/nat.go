@@ -0,0 +1,189 @@
+package netdiag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/stun"
+)
+
+// STUN-транзакция: попыток и ожидание ответа на каждую. Отрицательный
+// результат теста фильтрации — это как раз отсутствие ответа, поэтому
+// ожидание короткое: иначе весь тест NAT растягивался бы на полминуты.
+//
+// Ожидание — переменная, чтобы тесты отрицательных исходов не ждали секундами.
+const stunAttempts = 3
+
+var stunWaitPerSend = time.Second
+
+// CHANGE-REQUEST (RFC 5780 §7.2): попросить сервер ответить с другого IP
+// и/или порта.
+const (
+	changeIP   = 0x04
+	changePort = 0x02
+)
+
+type changeRequest byte
+
+func (c changeRequest) AddTo(m *stun.Message) error {
+	m.Add(stun.AttrChangeRequest, []byte{0, 0, 0, byte(c)})
+	return nil
+}
+
+// errNoResponse — сервер не ответил ни на одну попытку.
+var errNoResponse = errors.New("no STUN response")
+
+// ProbeNAT определяет внешний адрес, достижимость UDP и поведение NAT
+// (RFC 5780 §4.3–4.4) через pc. Каким путём pc доходит до сервера — через
+// узел (UDP ASSOCIATE зонда) или напрямую — решает вызывающий; один pc на
+// весь тест обязателен, иначе каждый запрос получал бы свой маппинг.
+//
+// Тип NAT определяется, только если сервер сообщает второй адрес
+// (OTHER-ADDRESS, либо CHANGED-ADDRESS у серверов RFC 3489). Публичные
+// серверы вроде stun.l.google.com его не сообщают — тогда результат содержит
+// адрес и задержку, а NATTypeSupported=false.
+func ProbeNAT(ctx context.Context, pc net.PacketConn, server string) (NATResult, error) {
+	res := NATResult{At: time.Now(), Server: server}
+	primary, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return res, fmt.Errorf("resolve STUN server %s: %w", server, err)
+	}
+
+	start := time.Now()
+	resp, err := stunTransaction(ctx, pc, primary)
+	if err != nil {
+		return res, fmt.Errorf("STUN %s: %w", server, err)
+	}
+	res.UDPReachable = true
+	res.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
+	mapped1, err := mappedAddress(resp)
+	if err != nil {
+		return res, fmt.Errorf("STUN %s: %w", server, err)
+	}
+	res.ExternalAddr = mapped1.String()
+
+	other, ok := otherAddress(resp)
+	if !ok || other.IP.Equal(primary.IP) {
+		return res, nil
+	}
+	res.NATTypeSupported = true
+
+	// Mapping: тот же маппинг для другого IP сервера → endpoint-independent;
+	// иначе сравниваем два запроса на другой IP с разными портами.
+	mapped2, err := mappedVia(ctx, pc, &net.UDPAddr{IP: other.IP, Port: primary.Port})
+	switch {
+	case err != nil:
+		res.Mapping = NATUnknown
+	case sameAddr(mapped1, mapped2):
+		res.Mapping = NATEndpointIndependent
+	default:
+		mapped3, err := mappedVia(ctx, pc, other)
+		switch {
+		case err != nil:
+			res.Mapping = NATUnknown
+		case sameAddr(mapped2, mapped3):
+			res.Mapping = NATAddressDependent
+		default:
+			res.Mapping = NATAddressPortDependent
+		}
+	}
+
+	// Filtering: пропустит ли NAT ответ с чужого IP, с чужого порта.
+	if _, err := stunTransaction(ctx, pc, primary, changeRequest(changeIP|changePort)); err == nil {
+		res.Filtering = NATEndpointIndependent
+	} else if ctx.Err() != nil {
+		return res, ctx.Err()
+	} else if _, err := stunTransaction(ctx, pc, primary, changeRequest(changePort)); err == nil {
+		res.Filtering = NATAddressDependent
+	} else if ctx.Err() != nil {
+		return res, ctx.Err()
+	} else {
+		res.Filtering = NATAddressPortDependent
+	}
+	return res, nil
+}
+
+func mappedVia(ctx context.Context, pc net.PacketConn, to *net.UDPAddr) (*net.UDPAddr, error) {
+	resp, err := stunTransaction(ctx, pc, to)
+	if err != nil {
+		return nil, err
+	}
+	return mappedAddress(resp)
+}
+
+// stunTransaction шлёт Binding Request на to и ждёт ответ с тем же
+// transaction ID — от любого адреса: при CHANGE-REQUEST сервер отвечает как
+// раз не с того, на который слали.
+func stunTransaction(ctx context.Context, pc net.PacketConn, to net.Addr, extra ...stun.Setter) (*stun.Message, error) {
+	setters := append([]stun.Setter{stun.TransactionID, stun.BindingRequest}, extra...)
+	req, err := stun.Build(setters...)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	for attempt := 0; attempt < stunAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := pc.WriteTo(req.Raw, to); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(stunWaitPerSend)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		_ = pc.SetReadDeadline(deadline)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			msg := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+			if msg.Decode() != nil || msg.TransactionID != req.TransactionID {
+				// Запоздавший ответ на прошлую попытку или мусор.
+				continue
+			}
+			if msg.Type.Class == stun.ClassErrorResponse {
+				return nil, fmt.Errorf("STUN error response")
+			}
+			return msg, nil
+		}
+	}
+	return nil, errNoResponse
+}
+
+// mappedAddress — XOR-MAPPED-ADDRESS, а у серверов RFC 3489 — MAPPED-ADDRESS.
+func mappedAddress(m *stun.Message) (*net.UDPAddr, error) {
+	var xor stun.XORMappedAddress
+	if err := xor.GetFrom(m); err == nil {
+		return &net.UDPAddr{IP: xor.IP, Port: xor.Port}, nil
+	}
+	var plain stun.MappedAddress
+	if err := plain.GetFrom(m); err == nil {
+		return &net.UDPAddr{IP: plain.IP, Port: plain.Port}, nil
+	}
+	return nil, fmt.Errorf("response has no mapped address")
+}
+
+// otherAddress — второй адрес сервера для тестов RFC 5780.
+func otherAddress(m *stun.Message) (*net.UDPAddr, bool) {
+	var other stun.MappedAddress
+	if err := other.GetFromAs(m, stun.AttrOtherAddress); err == nil {
+		return &net.UDPAddr{IP: other.IP, Port: other.Port}, true
+	}
+	if err := other.GetFromAs(m, stun.AttrChangedAddress); err == nil {
+		return &net.UDPAddr{IP: other.IP, Port: other.Port}, true
+	}
+	return nil, false
+}
+
+func sameAddr(a, b *net.UDPAddr) bool {
+	return a.IP.Equal(b.IP) && a.Port == b.Port
+}
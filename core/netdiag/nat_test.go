@@ -0,0 +1,205 @@
+package netdiag
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/txthinking/socks5"
+)
+
+// fakeSTUN — STUN-сервер на четырёх сокетах (два IP × два порта), как того
+// требует RFC 5780. Отвечает XOR-MAPPED-ADDRESS и OTHER-ADDRESS, CHANGE-REQUEST
+// исполняет, если не выставлен ignoreChange.
+type fakeSTUN struct {
+	// socks[ip][port]
+	socks        [2][2]*net.UDPConn
+	rfc5780      bool
+	ignoreChange bool
+}
+
+func (f *fakeSTUN) primary() string { return f.socks[0][0].LocalAddr().String() }
+
+func (f *fakeSTUN) serve(ip, port int) {
+	conn := f.socks[ip][port]
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if req.Decode() != nil {
+			continue
+		}
+		outIP, outPort := ip, port
+		if raw, err := req.Get(stun.AttrChangeRequest); err == nil && len(raw) == 4 {
+			if f.ignoreChange {
+				continue
+			}
+			if raw[3]&changeIP != 0 {
+				outIP = 1 - ip
+			}
+			if raw[3]&changePort != 0 {
+				outPort = 1 - port
+			}
+		}
+		setters := []stun.Setter{
+			stun.NewTransactionIDSetter(req.TransactionID),
+			stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: from.IP, Port: from.Port},
+		}
+		if f.rfc5780 {
+			other := f.socks[1][1].LocalAddr().(*net.UDPAddr)
+			setters = append(setters, &stun.OtherAddress{IP: other.IP, Port: other.Port})
+		}
+		resp := stun.MustBuild(setters...)
+		_, _ = f.socks[outIP][outPort].WriteToUDP(resp.Raw, from)
+	}
+}
+
+// newFakeSTUN поднимает сервер. Для RFC 5780 нужен второй loopback-адрес
+// 127.0.0.2 с теми же портами; где его нет (macOS без alias), тест пропускается.
+func newFakeSTUN(t *testing.T, rfc5780 bool) *fakeSTUN {
+	t.Helper()
+	f := &fakeSTUN{rfc5780: rfc5780}
+	listen := func(ip string, port int) *net.UDPConn {
+		c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip), Port: port})
+		if err != nil {
+			if ip == "127.0.0.2" {
+				t.Skipf("second loopback address unavailable: %v", err)
+			}
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+	f.socks[0][0] = listen("127.0.0.1", 0)
+	f.socks[0][1] = listen("127.0.0.1", 0)
+	if rfc5780 {
+		f.socks[1][0] = listen("127.0.0.2", f.socks[0][0].LocalAddr().(*net.UDPAddr).Port)
+		f.socks[1][1] = listen("127.0.0.2", f.socks[0][1].LocalAddr().(*net.UDPAddr).Port)
+	}
+	for ip := range f.socks {
+		for port := range f.socks[ip] {
+			if f.socks[ip][port] != nil {
+				go f.serve(ip, port)
+			}
+		}
+	}
+	return f
+}
+
+func shortSTUNWait(t *testing.T) {
+	old := stunWaitPerSend
+	stunWaitPerSend = 100 * time.Millisecond
+	t.Cleanup(func() { stunWaitPerSend = old })
+}
+
+func listenLocalUDP(t *testing.T) *net.UDPConn {
+	t.Helper()
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+	return pc
+}
+
+func TestProbeNAT_PlainServer(t *testing.T) {
+	srv := newFakeSTUN(t, false)
+	pc := listenLocalUDP(t)
+	res, err := ProbeNAT(context.Background(), pc, srv.primary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.UDPReachable || res.ExternalAddr != pc.LocalAddr().String() {
+		t.Fatalf("result = %+v, want external %s", res, pc.LocalAddr())
+	}
+	if res.NATTypeSupported || res.NATType() != "" {
+		t.Fatal("server without OTHER-ADDRESS must not yield a NAT type")
+	}
+}
+
+func TestProbeNAT_RFC5780NoNAT(t *testing.T) {
+	srv := newFakeSTUN(t, true)
+	res, err := ProbeNAT(context.Background(), listenLocalUDP(t), srv.primary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.NATTypeSupported || res.Mapping != NATEndpointIndependent || res.Filtering != NATEndpointIndependent {
+		t.Fatalf("result = %+v", res)
+	}
+	if res.NATType() != "full cone" {
+		t.Fatalf("NAT type = %q", res.NATType())
+	}
+}
+
+func TestProbeNAT_FilteringWhenChangedRepliesAreLost(t *testing.T) {
+	shortSTUNWait(t)
+	srv := newFakeSTUN(t, true)
+	srv.ignoreChange = true
+	res, err := ProbeNAT(context.Background(), listenLocalUDP(t), srv.primary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mapping != NATEndpointIndependent || res.Filtering != NATAddressPortDependent {
+		t.Fatalf("result = %+v", res)
+	}
+	if res.NATType() != "port-restricted cone" {
+		t.Fatalf("NAT type = %q", res.NATType())
+	}
+}
+
+func TestProbeNAT_Unreachable(t *testing.T) {
+	shortSTUNWait(t)
+	// Сокет, который никто не читает: ответа не будет.
+	dead := listenLocalUDP(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	res, err := ProbeNAT(ctx, listenLocalUDP(t), dead.LocalAddr().String())
+	if err == nil || res.UDPReachable {
+		t.Fatalf("want unreachable, got %+v err=%v", res, err)
+	}
+}
+
+func TestDialSOCKS5UDP_ThroughRelay(t *testing.T) {
+	srv := newFakeSTUN(t, false)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	proxy, err := socks5.NewClassicServer(addr, "127.0.0.1", "", "", 0, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = proxy.ListenAndServe(nil) }()
+	t.Cleanup(func() { _ = proxy.Shutdown() })
+
+	var pc net.PacketConn
+	for i := 0; i < 50; i++ {
+		if pc, err = DialSOCKS5UDP(addr, 2*time.Second); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	res, err := ProbeNAT(context.Background(), pc, srv.primary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Внешний адрес — сокет релея, а не наш: трафик действительно шёл через
+	// прокси.
+	if !res.UDPReachable || res.ExternalAddr == "" || res.ExternalAddr == pc.LocalAddr().String() {
+		t.Fatalf("result = %+v (local %s)", res, pc.LocalAddr())
+	}
+}
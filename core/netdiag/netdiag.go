@@ -0,0 +1,181 @@
+// Package netdiag — диагностика качества канала через конкретный узел:
+// задержка, джиттер, потери и пропускная способность, а также тип NAT и
+// достижимость UDP.
+//
+// Пакет не знает, КАК трафик попадает в узел. Измерения принимают готовый
+// *http.Client и net.PacketConn, а уж что за ними — временный зонд classic-
+// режима (mixed-inbound второго sing-box), прямое соединение или что-то ещё,
+// решает вызывающий (core). Для daemon-режима и удалённой машины измеряет сам
+// демон (StartNetworkQualityTest / StartSTUNTest), и здесь только разбор его
+// кадров в те же структуры — чтобы результаты разных движков лежали рядом и
+// сравнивались.
+//
+// Все адреса серверов настраиваемые: тест можно направить на собственный
+// сервер в локальной сети (generate_204 + отдача/приём файла + STUN), чтобы
+// мерить сам узел, а не дорогу до чужого CDN.
+package netdiag
+
+import (
+	"fmt"
+	"time"
+)
+
+// Via — чем выполнялось измерение.
+type Via string
+
+const (
+	// ViaClassic — временный зонд рядом с classic-ядром, мерит лаунчер.
+	ViaClassic Via = "classic"
+	// ViaDaemon — локальный демон sing-box lxd.
+	ViaDaemon Via = "daemon"
+	// ViaRemote — демон удалённой машины.
+	ViaRemote Via = "remote"
+)
+
+// QualityOptions — параметры теста качества. Нулевые поля — дефолты.
+type QualityOptions struct {
+	// ProbeURL — адрес для замера задержки; ждём любой ответ, тело не читаем.
+	ProbeURL string
+	// Probes — сколько замеров задержки сделать.
+	Probes int
+	// DownloadURL / UploadURL — откуда качать и куда отправлять для замера
+	// пропускной способности. "-" — направление не проверять.
+	DownloadURL string
+	UploadURL   string
+	// Duration — предел на каждое направление.
+	Duration time.Duration
+	// ConfigURL — конфиг networkQuality-сервера для демона; "" — его дефолт.
+	// Лаунчерские замеры его не используют.
+	ConfigURL string
+}
+
+// Дефолты QualityOptions.
+const (
+	DefaultProbeURL    = "https://www.google.com/generate_204"
+	DefaultDownloadURL = "https://speed.cloudflare.com/__down?bytes=50000000"
+	DefaultUploadURL   = "https://speed.cloudflare.com/__up"
+	DefaultProbes      = 10
+	DefaultDuration    = 10 * time.Second
+	// SkipDirection — значение DownloadURL/UploadURL, выключающее направление.
+	SkipDirection = "-"
+)
+
+// withDefaults заполняет пустые поля.
+func (o QualityOptions) withDefaults() QualityOptions {
+	if o.ProbeURL == "" {
+		o.ProbeURL = DefaultProbeURL
+	}
+	if o.Probes <= 0 {
+		o.Probes = DefaultProbes
+	}
+	if o.DownloadURL == "" {
+		o.DownloadURL = DefaultDownloadURL
+	}
+	if o.UploadURL == "" {
+		o.UploadURL = DefaultUploadURL
+	}
+	if o.Duration <= 0 {
+		o.Duration = DefaultDuration
+	}
+	return o
+}
+
+// QualityResult — итог (или промежуточный кадр) теста качества. Нулевое
+// значение метрики — «не измерялось»: у демона нет джиттера и потерь, у
+// лаунчерского замера — RPM.
+type QualityResult struct {
+	At  time.Time `json:"at"`
+	Via Via       `json:"via"`
+	// LatencyMs — медиана RTT запросов к ProbeURL поверх уже открытого
+	// соединения (у демона — idle latency).
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	// JitterMs — средний модуль разности соседних RTT (RFC 3550, без
+	// сглаживания).
+	JitterMs    float64 `json:"jitter_ms,omitempty"`
+	LossPercent float64 `json:"loss_percent,omitempty"`
+	// DownloadBps / UploadBps — бит в секунду.
+	DownloadBps int64 `json:"download_bps,omitempty"`
+	UploadBps   int64 `json:"upload_bps,omitempty"`
+	// DownloadRPM / UploadRPM — responsiveness под нагрузкой (только демон).
+	DownloadRPM int32  `json:"download_rpm,omitempty"`
+	UploadRPM   int32  `json:"upload_rpm,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// NATBehavior — поведение NAT по RFC 5780. Значения совпадают с полями
+// natMapping/natFiltering демона, поэтому его кадры переносятся как есть.
+type NATBehavior int32
+
+const (
+	NATUnknown NATBehavior = iota
+	NATEndpointIndependent
+	NATAddressDependent
+	NATAddressPortDependent
+)
+
+func (b NATBehavior) String() string {
+	switch b {
+	case NATEndpointIndependent:
+		return "endpoint-independent"
+	case NATAddressDependent:
+		return "address-dependent"
+	case NATAddressPortDependent:
+		return "address-and-port-dependent"
+	default:
+		return "unknown"
+	}
+}
+
+// NATResult — итог теста STUN/NAT.
+type NATResult struct {
+	At     time.Time `json:"at"`
+	Via    Via       `json:"via"`
+	Server string    `json:"server"`
+	// UDPReachable — STUN-сервер ответил хотя бы раз: UDP через узел ходит.
+	UDPReachable bool        `json:"udp_reachable"`
+	ExternalAddr string      `json:"external_addr,omitempty"`
+	LatencyMs    float64     `json:"latency_ms,omitempty"`
+	Mapping      NATBehavior `json:"mapping,omitempty"`
+	Filtering    NATBehavior `json:"filtering,omitempty"`
+	// NATTypeSupported — сервер поддерживает RFC 5780 (OTHER-ADDRESS), и
+	// Mapping/Filtering осмысленны. Без него известен только внешний адрес.
+	NATTypeSupported bool   `json:"nat_type_supported,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// NATType — классическое имя типа NAT по паре mapping/filtering; "" — не
+// определён.
+func (r NATResult) NATType() string {
+	if !r.NATTypeSupported || r.Mapping == NATUnknown {
+		return ""
+	}
+	if r.Mapping != NATEndpointIndependent {
+		return "symmetric"
+	}
+	switch r.Filtering {
+	case NATEndpointIndependent:
+		return "full cone"
+	case NATAddressDependent:
+		return "restricted cone"
+	case NATAddressPortDependent:
+		return "port-restricted cone"
+	default:
+		return ""
+	}
+}
+
+// FormatBitrate — «87.4 Mbit/s». Единицы латиницей, как и прочие скорости
+// лаунчера; биты, а не байты — так пропускную способность публикуют все
+// speedtest'ы, и сравнивать с ними проще.
+func FormatBitrate(bps int64) string {
+	switch {
+	case bps >= 1_000_000_000:
+		return fmt.Sprintf("%.2f Gbit/s", float64(bps)/1e9)
+	case bps >= 1_000_000:
+		return fmt.Sprintf("%.1f Mbit/s", float64(bps)/1e6)
+	case bps >= 1_000:
+		return fmt.Sprintf("%.0f kbit/s", float64(bps)/1e3)
+	default:
+		return fmt.Sprintf("%d bit/s", bps)
+	}
+}
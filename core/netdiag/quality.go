@@ -0,0 +1,215 @@
+package netdiag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// probeTimeout — предел одного замера задержки. Узел, который не ответил на
+// generate_204 за 5 секунд, для интерактивного трафика всё равно мёртв.
+const probeTimeout = 5 * time.Second
+
+// MeasureQuality мерит задержку, джиттер, потери и пропускную способность
+// клиентом client (его транспорт и определяет, через какой узел идёт тест).
+//
+// onProgress получает промежуточный результат после каждого этапа; nil —
+// не нужен. Ошибка возвращается, только если не удалось вообще ничего:
+// частичный результат (задержка есть, отдача не принята сервером) — это
+// результат с заполненным Error.
+func MeasureQuality(ctx context.Context, client *http.Client, opts QualityOptions, onProgress func(QualityResult)) (QualityResult, error) {
+	opts = opts.withDefaults()
+	res := QualityResult{At: time.Now()}
+	report := func() {
+		if onProgress != nil {
+			onProgress(res)
+		}
+	}
+
+	rtts, failed, lastErr := measureLatency(ctx, client, opts)
+	if len(rtts) == 0 {
+		if lastErr == nil {
+			lastErr = ctx.Err()
+		}
+		return res, fmt.Errorf("latency probe %s: %w", opts.ProbeURL, lastErr)
+	}
+	res.LatencyMs, res.JitterMs = latencyStats(rtts)
+	res.LossPercent = 100 * float64(failed) / float64(opts.Probes)
+	report()
+
+	var errs []error
+	if opts.DownloadURL != SkipDirection {
+		bps, err := measureDownload(ctx, client, opts.DownloadURL, opts.Duration)
+		res.DownloadBps = bps
+		if err != nil {
+			errs = append(errs, fmt.Errorf("download: %w", err))
+		}
+		report()
+	}
+	if opts.UploadURL != SkipDirection {
+		bps, err := measureUpload(ctx, client, opts.UploadURL, opts.Duration)
+		res.UploadBps = bps
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upload: %w", err))
+		}
+		report()
+	}
+	if err := errors.Join(errs...); err != nil {
+		res.Error = err.Error()
+	}
+	return res, nil
+}
+
+// measureLatency делает opts.Probes последовательных запросов к ProbeURL.
+//
+// Первый запрос — прогревочный и в статистику не идёт: в нём TCP/TLS
+// рукопожатие и (у зонда) установка соединения до узла, то есть он мерит
+// настройку канала, а не его задержку.
+func measureLatency(ctx context.Context, client *http.Client, opts QualityOptions) (rtts []time.Duration, failed int, lastErr error) {
+	if _, err := probeOnce(ctx, client, opts.ProbeURL); err != nil {
+		lastErr = err
+	}
+	for i := 0; i < opts.Probes; i++ {
+		if ctx.Err() != nil {
+			return rtts, failed, ctx.Err()
+		}
+		rtt, err := probeOnce(ctx, client, opts.ProbeURL)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		rtts = append(rtts, rtt)
+	}
+	return rtts, failed, lastErr
+}
+
+// probeOnce — один запрос; RTT до заголовков ответа. Тело дочитывается, чтобы
+// соединение вернулось в пул и следующий замер не платил за рукопожатие.
+func probeOnce(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return rtt, nil
+}
+
+// latencyStats — медиана и джиттер в миллисекундах. Медиана, а не среднее:
+// один замер, попавший на ретрансмит, иначе перекашивал бы всю цифру.
+func latencyStats(rtts []time.Duration) (medianMs, jitterMs float64) {
+	if len(rtts) == 0 {
+		return 0, 0
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	var diffSum float64
+	for i := 1; i < len(rtts); i++ {
+		diffSum += math.Abs(ms(rtts[i]) - ms(rtts[i-1]))
+	}
+	if len(rtts) > 1 {
+		jitterMs = diffSum / float64(len(rtts)-1)
+	}
+	sorted := append([]time.Duration(nil), rtts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		medianMs = (ms(sorted[mid-1]) + ms(sorted[mid])) / 2
+	} else {
+		medianMs = ms(sorted[mid])
+	}
+	return medianMs, jitterMs
+}
+
+// measureDownload качает url не дольше limit и возвращает бит/с. Обрыв по
+// limit — штатное окончание замера, а не ошибка: файл нарочно берётся больше,
+// чем успеет прийти.
+func measureDownload(ctx context.Context, client *http.Client, url string, limit time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	elapsed := time.Since(start)
+	if err != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return bitrate(n, elapsed), err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("empty response body")
+	}
+	return bitrate(n, elapsed), nil
+}
+
+// measureUpload отправляет поток нулей на url, пока не выйдет limit, и
+// возвращает бит/с по объёму, который транспорт успел забрать.
+func measureUpload(ctx context.Context, client *http.Client, url string, limit time.Duration) (int64, error) {
+	body := &uploadBody{until: time.Now().Add(limit)}
+	ctx, cancel := context.WithTimeout(ctx, limit+probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	start := time.Now()
+	resp, err := client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		return bitrate(body.sent, elapsed), err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return bitrate(body.sent, elapsed), nil
+}
+
+// uploadBody — тело без Content-Length: отдаёт нули до момента until.
+type uploadBody struct {
+	until time.Time
+	sent  int64
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	if !time.Now().Before(b.until) {
+		return 0, io.EOF
+	}
+	clear(p)
+	b.sent += int64(len(p))
+	return len(p), nil
+}
+
+func bitrate(bytes int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(bytes*8) / elapsed.Seconds())
+}
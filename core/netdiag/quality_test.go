@@ -0,0 +1,110 @@
+package netdiag
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStandInServer — локальный «стенд» в роли сервера теста: generate_204,
+// отдача потока и приём тела. Ровно то, что пользователь поднимает у себя,
+// чтобы мерить узел без чужого CDN.
+func newStandInServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate_204", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 64<<10)
+		for i := 0; i < 64; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/up", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMeasureQuality_StandInServer(t *testing.T) {
+	srv := newStandInServer(t)
+	var frames int
+	res, err := MeasureQuality(context.Background(), srv.Client(), QualityOptions{
+		ProbeURL:    srv.URL + "/generate_204",
+		Probes:      5,
+		DownloadURL: srv.URL + "/down",
+		UploadURL:   srv.URL + "/up",
+		Duration:    300 * time.Millisecond,
+	}, func(QualityResult) { frames++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Error != "" {
+		t.Fatalf("unexpected partial error: %s", res.Error)
+	}
+	if res.LatencyMs <= 0 || res.LossPercent != 0 {
+		t.Fatalf("latency=%v loss=%v", res.LatencyMs, res.LossPercent)
+	}
+	if res.DownloadBps <= 0 || res.UploadBps <= 0 {
+		t.Fatalf("throughput down=%d up=%d", res.DownloadBps, res.UploadBps)
+	}
+	if frames != 3 {
+		t.Fatalf("progress frames = %d, want 3 (latency, download, upload)", frames)
+	}
+}
+
+func TestMeasureQuality_SkipDirectionsAndPartialError(t *testing.T) {
+	srv := newStandInServer(t)
+	res, err := MeasureQuality(context.Background(), srv.Client(), QualityOptions{
+		ProbeURL:    srv.URL + "/generate_204",
+		Probes:      2,
+		DownloadURL: srv.URL + "/broken",
+		UploadURL:   SkipDirection,
+		Duration:    200 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UploadBps != 0 {
+		t.Fatal("skipped direction must stay unmeasured")
+	}
+	if !strings.Contains(res.Error, "download") {
+		t.Fatalf("download failure must land in Error, got %q", res.Error)
+	}
+}
+
+func TestMeasureQuality_AllProbesFail(t *testing.T) {
+	srv := newStandInServer(t)
+	_, err := MeasureQuality(context.Background(), srv.Client(), QualityOptions{
+		ProbeURL: srv.URL + "/broken",
+		Probes:   2,
+	}, nil)
+	if err == nil {
+		t.Fatal("expected error when no latency probe succeeded")
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	median, jitter := latencyStats([]time.Duration{10 * ms, 30 * ms, 20 * ms, 200 * ms})
+	if median != 25 {
+		t.Fatalf("median = %v, want 25", median)
+	}
+	// |30-10| + |20-30| + |200-20| = 210, / 3.
+	if jitter != 70 {
+		t.Fatalf("jitter = %v, want 70", jitter)
+	}
+}
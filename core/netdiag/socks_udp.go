@@ -0,0 +1,110 @@
+package netdiag
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/txthinking/socks5"
+)
+
+// socksPacketConn — net.PacketConn поверх SOCKS5 UDP ASSOCIATE.
+//
+// Client.Dial("udp", …) из txthinking/socks5 привязывает ассоциацию к одному
+// адресату, а тесту NAT нужно слать с ОДНОГО внешнего маппинга на разные
+// адреса сервера. Поэтому ассоциация открывается вручную, а заголовок SOCKS5
+// дописывается к каждой датаграмме здесь.
+type socksPacketConn struct {
+	// ctrl — управляющее TCP-соединение: ассоциация живёт, пока оно открыто.
+	ctrl  net.Conn
+	udp   *net.UDPConn
+	relay *net.UDPAddr
+}
+
+// DialSOCKS5UDP открывает UDP-ассоциацию через SOCKS5-прокси proxyAddr
+// (mixed-inbound зонда) и возвращает PacketConn, который шлёт через неё.
+func DialSOCKS5UDP(proxyAddr string, timeout time.Duration) (net.PacketConn, error) {
+	client, err := socks5.NewClient(proxyAddr, "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Negotiate(nil); err != nil {
+		closeQuietly(client.TCPConn)
+		return nil, fmt.Errorf("socks5 negotiate %s: %w", proxyAddr, err)
+	}
+	_ = client.TCPConn.SetDeadline(time.Now().Add(timeout))
+	reply, err := client.Request(socks5.NewRequest(socks5.CmdUDP, socks5.ATYPIPv4, []byte{0, 0, 0, 0}, []byte{0, 0}))
+	if err != nil {
+		closeQuietly(client.TCPConn)
+		return nil, fmt.Errorf("socks5 UDP associate %s: %w", proxyAddr, err)
+	}
+	_ = client.TCPConn.SetDeadline(time.Time{})
+
+	relay, err := net.ResolveUDPAddr("udp", reply.Address())
+	if err != nil {
+		closeQuietly(client.TCPConn)
+		return nil, fmt.Errorf("socks5 relay address %q: %w", reply.Address(), err)
+	}
+	// 0.0.0.0 в BND.ADDR — «тот же хост, что и прокси» (так отвечает
+	// sing-box, слушающий на всех интерфейсах).
+	if relay.IP.IsUnspecified() {
+		host, _, _ := net.SplitHostPort(proxyAddr)
+		relay.IP = net.ParseIP(host)
+	}
+	udp, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		closeQuietly(client.TCPConn)
+		return nil, err
+	}
+	return &socksPacketConn{ctrl: client.TCPConn, udp: udp, relay: relay}, nil
+}
+
+func (c *socksPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	atyp, host, port, err := socks5.ParseAddress(addr.String())
+	if err != nil {
+		return 0, err
+	}
+	d := socks5.NewDatagram(atyp, host, port, p)
+	if _, err := c.udp.WriteToUDP(d.Bytes(), c.relay); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *socksPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := c.udp.ReadFromUDP(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		d, err := socks5.NewDatagramFromBytes(buf[:n])
+		if err != nil {
+			// Не SOCKS-кадр — не от релея; ждём дальше.
+			continue
+		}
+		from, _ := net.ResolveUDPAddr("udp", d.Address())
+		return copy(p, d.Data), from, nil
+	}
+}
+
+func (c *socksPacketConn) Close() error {
+	err := c.udp.Close()
+	closeQuietly(c.ctrl)
+	return err
+}
+
+func (c *socksPacketConn) LocalAddr() net.Addr                { return c.udp.LocalAddr() }
+func (c *socksPacketConn) SetDeadline(t time.Time) error      { return c.udp.SetDeadline(t) }
+func (c *socksPacketConn) SetReadDeadline(t time.Time) error  { return c.udp.SetReadDeadline(t) }
+func (c *socksPacketConn) SetWriteDeadline(t time.Time) error { return c.udp.SetWriteDeadline(t) }
+
+// closeQuietly закрывает управляющее соединение клиента. Client.Negotiate
+// кладёт в TCPConn результат DialTCP и при ошибке дозвона — это
+// типизированный nil *net.TCPConn, который обычная проверка на nil не ловит.
+func closeQuietly(c net.Conn) {
+	if tc, ok := c.(*net.TCPConn); c == nil || (ok && tc == nil) {
+		return
+	}
+	_ = c.Close()
+}
@@ -0,0 +1,205 @@
+package netdiag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"singbox-launcher/internal/platform"
+)
+
+// storeFile — результаты тестов, <execDir>/bin/netdiag_results.json.
+const storeFile = "netdiag_results.json"
+
+// historyPerNode — сколько последних прогонов каждого вида хранить на узел.
+// Для сравнения «до/после» и «вечер/утро» хватает; дальше файл только растёт.
+const historyPerNode = 10
+
+// Record — история тестов одного узла.
+//
+// Ключ — идентичность узла (config.OutboundIdentityHash), а не тег: тег
+// меняется от переименования у провайдера и от tag_prefix, и история тогда
+// отрывалась бы от узла. Tag — последнее имя, под которым узел тестировали,
+// для отображения.
+type Record struct {
+	Key string `json:"key"`
+	Tag string `json:"tag"`
+	// Machine — ID удалённой машины, через которую шёл тест; "" — локально.
+	// Тот же узел с роутера и с ноутбука — разные измерения.
+	Machine string          `json:"machine,omitempty"`
+	Quality []QualityResult `json:"quality,omitempty"`
+	NAT     []NATResult     `json:"nat,omitempty"`
+}
+
+// LatestQuality — последний прогон теста качества без ошибки целиком.
+func (r Record) LatestQuality() (QualityResult, bool) {
+	for i := len(r.Quality) - 1; i >= 0; i-- {
+		if r.Quality[i].LatencyMs > 0 || r.Quality[i].DownloadBps > 0 {
+			return r.Quality[i], true
+		}
+	}
+	return QualityResult{}, false
+}
+
+// LatestNAT — последний прогон теста NAT.
+func (r Record) LatestNAT() (NATResult, bool) {
+	if len(r.NAT) == 0 {
+		return NATResult{}, false
+	}
+	return r.NAT[len(r.NAT)-1], true
+}
+
+// NodeKey — ключ Record. Узел без идентичности (hash == "") хранится по
+// тегу: история у него отвяжется при переименовании, но лучше так, чем
+// схлопнуть все такие узлы в один ключ "".
+func NodeKey(hash, tag, machine string) string {
+	key := hash
+	if key == "" {
+		key = "tag:" + tag
+	}
+	if machine != "" {
+		key = machine + "/" + key
+	}
+	return key
+}
+
+// Store — результаты тестов по узлам, в файле в bin/.
+type Store struct {
+	execDir string
+
+	mu     sync.Mutex
+	loaded bool
+	data   map[string]*Record
+}
+
+// NewStore создаёт хранилище, живущее в <execDir>/bin/. Файл читается лениво.
+func NewStore(execDir string) *Store {
+	return &Store{execDir: execDir}
+}
+
+func (s *Store) path() string {
+	return filepath.Join(platform.GetBinDir(s.execDir), storeFile)
+}
+
+// AddQuality дописывает прогон теста качества и сохраняет файл.
+func (s *Store) AddQuality(key, tag, machine string, r QualityResult) error {
+	return s.update(key, tag, machine, func(rec *Record) {
+		rec.Quality = append(rec.Quality, r)
+		if len(rec.Quality) > historyPerNode {
+			rec.Quality = rec.Quality[len(rec.Quality)-historyPerNode:]
+		}
+	})
+}
+
+// AddNAT дописывает прогон теста NAT и сохраняет файл.
+func (s *Store) AddNAT(key, tag, machine string, r NATResult) error {
+	return s.update(key, tag, machine, func(rec *Record) {
+		rec.NAT = append(rec.NAT, r)
+		if len(rec.NAT) > historyPerNode {
+			rec.NAT = rec.NAT[len(rec.NAT)-historyPerNode:]
+		}
+	})
+}
+
+// Get — история узла по ключу.
+func (s *Store) Get(key string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	rec, ok := s.data[key]
+	if !ok {
+		return Record{}, false
+	}
+	return cloneRecord(rec), true
+}
+
+// All — все узлы, по тегу.
+func (s *Store) All() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	out := make([]Record, 0, len(s.data))
+	for _, rec := range s.data {
+		out = append(out, cloneRecord(rec))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tag != out[j].Tag {
+			return out[i].Tag < out[j].Tag
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func (s *Store) update(key, tag, machine string, mutate func(*Record)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	rec, ok := s.data[key]
+	if !ok {
+		rec = &Record{Key: key}
+		s.data[key] = rec
+	}
+	rec.Tag = tag
+	rec.Machine = machine
+	mutate(rec)
+	return s.saveLocked()
+}
+
+// loadLocked читает файл при первом обращении. Битый файл не фатален:
+// история тестов — не та ценность, ради которой стоит отказывать в новом
+// тесте; он будет перезаписан при следующем сохранении.
+func (s *Store) loadLocked() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.data = make(map[string]*Record)
+	raw, err := os.ReadFile(s.path())
+	if err != nil {
+		return
+	}
+	var list []*Record
+	if json.Unmarshal(raw, &list) != nil {
+		return
+	}
+	for _, rec := range list {
+		if rec != nil && rec.Key != "" {
+			s.data[rec.Key] = rec
+		}
+	}
+}
+
+func (s *Store) saveLocked() error {
+	binDir := platform.GetBinDir(s.execDir)
+	if err := os.MkdirAll(binDir, platform.DefaultDirMode); err != nil {
+		return fmt.Errorf("netdiag store: mkdir: %w", err)
+	}
+	list := make([]*Record, 0, len(s.data))
+	for _, rec := range s.data {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path() + ".tmp"
+	if err := os.WriteFile(tmp, raw, platform.DefaultFileMode); err != nil {
+		return fmt.Errorf("netdiag store: write: %w", err)
+	}
+	if err := os.Rename(tmp, s.path()); err != nil {
+		return fmt.Errorf("netdiag store: rename: %w", err)
+	}
+	return nil
+}
+
+func cloneRecord(rec *Record) Record {
+	out := *rec
+	out.Quality = append([]QualityResult(nil), rec.Quality...)
+	out.NAT = append([]NATResult(nil), rec.NAT...)
+	return out
+}
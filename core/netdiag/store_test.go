@@ -0,0 +1,59 @@
+package netdiag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_HistoryPerNodePersistsAndCaps(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	key := NodeKey("abc", "node-1", "")
+	for i := 0; i < historyPerNode+3; i++ {
+		if err := s.AddQuality(key, "node-1", "", QualityResult{At: time.Unix(int64(i), 0), LatencyMs: float64(i + 1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddNAT(key, "renamed", "", NATResult{Server: "stun:1", UDPReachable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewStore(dir)
+	rec, ok := reopened.Get(key)
+	if !ok {
+		t.Fatal("record not persisted")
+	}
+	if len(rec.Quality) != historyPerNode {
+		t.Fatalf("history = %d, want cap %d", len(rec.Quality), historyPerNode)
+	}
+	last, _ := rec.LatestQuality()
+	if last.LatencyMs != float64(historyPerNode+3) {
+		t.Fatalf("latest latency = %v", last.LatencyMs)
+	}
+	if rec.Tag != "renamed" {
+		t.Fatalf("tag = %q: record must follow the node's latest name", rec.Tag)
+	}
+	if nat, ok := rec.LatestNAT(); !ok || !nat.UDPReachable {
+		t.Fatal("NAT result lost")
+	}
+}
+
+func TestNodeKey(t *testing.T) {
+	if NodeKey("h", "t", "") != "h" {
+		t.Fatal("hash must be the key")
+	}
+	if NodeKey("", "t", "") != "tag:t" {
+		t.Fatal("nodes without identity fall back to tag")
+	}
+	if NodeKey("h", "t", "router") != "router/h" {
+		t.Fatal("remote machines measure separately")
+	}
+}
+
+func TestLatestQuality_SkipsFailedRuns(t *testing.T) {
+	rec := Record{Quality: []QualityResult{{LatencyMs: 40}, {Error: "timeout"}}}
+	got, ok := rec.LatestQuality()
+	if !ok || got.LatencyMs != 40 {
+		t.Fatalf("got %+v ok=%v", got, ok)
+	}
+}
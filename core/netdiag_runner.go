@@ -0,0 +1,298 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"singbox-launcher/core/config"
+	"singbox-launcher/core/netdiag"
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// NetDiagTarget — какой узел и через чьё ядро тестировать.
+type NetDiagTarget struct {
+	// Tag — outbound (узел или группа) в конфиге ядра.
+	Tag string
+	// ConfigPath — config.json ядра, которому принадлежит Tag: по нему
+	// считается идентичность узла для истории, а classic строит зонд.
+	ConfigPath string
+	// Machine и Remote — удалённая машина; пустые — своё ядро.
+	Machine string
+	Remote  *services.LxdRemoteTransport
+}
+
+// netDiagSource — бэкенд, умеющий прогнать тесты через свой outbound.
+// Classic поднимает временный зонд, daemon просит демона.
+type netDiagSource interface {
+	netQuality(ctx context.Context, t NetDiagTarget, opts netdiag.QualityOptions, onProgress func(netdiag.QualityResult)) (netdiag.QualityResult, error)
+	netNAT(ctx context.Context, t NetDiagTarget, server string) (netdiag.NATResult, error)
+}
+
+// errNetDiagUnsupported — у активного бэкенда нет диагностики.
+var errNetDiagUnsupported = errors.New("network diagnostics are not supported by the current core backend")
+
+// RunNetQuality прогоняет тест качества канала через t.Tag и дописывает
+// результат в историю узла — и удачный, и неудачный: провал узла в 10 утра
+// тоже повод для сравнения.
+func (ac *AppController) RunNetQuality(ctx context.Context, t NetDiagTarget, opts netdiag.QualityOptions, onProgress func(netdiag.QualityResult)) (netdiag.QualityResult, error) {
+	var (
+		res netdiag.QualityResult
+		err error
+	)
+	switch {
+	case t.Remote != nil:
+		res, err = t.Remote.NetworkQualityTest(ctx, t.Tag, opts, onProgress)
+	default:
+		src, ok := ac.Backend().(netDiagSource)
+		if !ok {
+			return netdiag.QualityResult{}, errNetDiagUnsupported
+		}
+		res, err = src.netQuality(ctx, t, opts, onProgress)
+	}
+	if err != nil && res.Error == "" {
+		res.Error = err.Error()
+	}
+	if res.At.IsZero() {
+		res.At = time.Now()
+	}
+	if ctx.Err() == nil {
+		ac.saveNetDiag(t, func(key string) error { return ac.NetDiag.AddQuality(key, t.Tag, t.Machine, res) })
+	}
+	return res, err
+}
+
+// RunNATTest прогоняет тест STUN/NAT через t.Tag и дописывает результат в
+// историю узла.
+func (ac *AppController) RunNATTest(ctx context.Context, t NetDiagTarget, server string) (netdiag.NATResult, error) {
+	var (
+		res netdiag.NATResult
+		err error
+	)
+	switch {
+	case t.Remote != nil:
+		res, err = t.Remote.STUNTest(ctx, t.Tag, server)
+	default:
+		src, ok := ac.Backend().(netDiagSource)
+		if !ok {
+			return netdiag.NATResult{}, errNetDiagUnsupported
+		}
+		res, err = src.netNAT(ctx, t, server)
+	}
+	if err != nil && res.Error == "" {
+		res.Error = err.Error()
+	}
+	if res.At.IsZero() {
+		res.At = time.Now()
+	}
+	if ctx.Err() == nil {
+		ac.saveNetDiag(t, func(key string) error { return ac.NetDiag.AddNAT(key, t.Tag, t.Machine, res) })
+	}
+	return res, err
+}
+
+// NetDiagKey — ключ истории узла цели (см. netdiag.NodeKey).
+func NetDiagKey(t NetDiagTarget) string {
+	return netdiag.NodeKey(config.OutboundIdentityHashByTag(t.ConfigPath, t.Tag), t.Tag, t.Machine)
+}
+
+func (ac *AppController) saveNetDiag(t NetDiagTarget, add func(key string) error) {
+	if ac.NetDiag == nil {
+		return
+	}
+	if err := add(NetDiagKey(t)); err != nil {
+		debuglog.WarnLog("netdiag: save result for %q: %v", t.Tag, err)
+	}
+}
+
+// --- classic: временный зонд ----------------------------------------------
+
+// netDiagProbeStartTimeout — сколько ждать, пока зонд начнёт слушать порт.
+// sing-box стартует за доли секунды, но на Windows первый запуск бинарника
+// проходит проверку антивирусом.
+const netDiagProbeStartTimeout = 15 * time.Second
+
+// netQuality реализует netDiagSource для classic: тест идёт через mixed-
+// inbound временного зонда (см. config.BuildProbeConfig), мерит лаунчер.
+func (b *LegacyBackend) netQuality(ctx context.Context, t NetDiagTarget, opts netdiag.QualityOptions, onProgress func(netdiag.QualityResult)) (netdiag.QualityResult, error) {
+	addr, stop, err := b.ac.startNetDiagProbe(ctx, t)
+	if err != nil {
+		return netdiag.QualityResult{Via: netdiag.ViaClassic}, err
+	}
+	defer stop()
+
+	// socks5 (а не HTTP CONNECT) — чтобы имена резолвил узел, а не лаунчер:
+	// иначе DNS-ответ провайдера мог бы увести тест не на тот сервер.
+	transport := &http.Transport{
+		Proxy:               http.ProxyURL(&url.URL{Scheme: "socks5", Host: addr}),
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: 2,
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	res, err := netdiag.MeasureQuality(ctx, client, opts, func(r netdiag.QualityResult) {
+		r.Via = netdiag.ViaClassic
+		if onProgress != nil {
+			onProgress(r)
+		}
+	})
+	res.Via = netdiag.ViaClassic
+	return res, err
+}
+
+// netNAT реализует netDiagSource для classic: STUN через UDP ASSOCIATE
+// временного зонда.
+func (b *LegacyBackend) netNAT(ctx context.Context, t NetDiagTarget, server string) (netdiag.NATResult, error) {
+	addr, stop, err := b.ac.startNetDiagProbe(ctx, t)
+	if err != nil {
+		return netdiag.NATResult{Via: netdiag.ViaClassic, Server: server}, err
+	}
+	defer stop()
+
+	pc, err := netdiag.DialSOCKS5UDP(addr, 5*time.Second)
+	if err != nil {
+		return netdiag.NATResult{Via: netdiag.ViaClassic, Server: server}, err
+	}
+	defer func() { _ = pc.Close() }()
+	res, err := netdiag.ProbeNAT(ctx, pc, server)
+	res.Via = netdiag.ViaClassic
+	return res, err
+}
+
+// startNetDiagProbe запускает временный sing-box с конфигом зонда для t.Tag
+// и ждёт, пока он начнёт слушать. stop гасит процесс и убирает временную
+// папку; вызывать обязательно.
+func (ac *AppController) startNetDiagProbe(ctx context.Context, t NetDiagTarget) (addr string, stop func(), err error) {
+	if ac.FileService == nil || ac.FileService.SingboxPath == "" {
+		return "", nil, fmt.Errorf("sing-box binary is not available")
+	}
+	if _, err := os.Stat(ac.FileService.SingboxPath); err != nil {
+		return "", nil, fmt.Errorf("sing-box binary is not available: %w", err)
+	}
+	cfgPath := t.ConfigPath
+	if cfgPath == "" {
+		cfgPath = ac.FileService.ConfigPath
+	}
+	port, err := freeLoopbackPort()
+	if err != nil {
+		return "", nil, err
+	}
+	cfg, err := config.BuildProbeConfig(cfgPath, t.Tag, port)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "singbox-netdiag-")
+	if err != nil {
+		return "", nil, err
+	}
+	probePath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(probePath, cfg, 0o600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	// Рабочая папка — временная: cache_file и прочие артефакты зонда не
+	// должны попасть в bin/ рядом с файлами основного ядра.
+	cmd := exec.Command(ac.FileService.SingboxPath, "run", "-c", probePath)
+	cmd.Dir = dir
+	platform.PrepareCommand(cmd)
+	var output probeOutput
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("start sing-box probe: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	stop = func() {
+		if cmd.Process != nil {
+			_ = cmd.Process.Kill()
+		}
+		<-exited
+		_ = os.RemoveAll(dir)
+	}
+
+	addr = net.JoinHostPort("127.0.0.1", fmt.Sprint(port))
+	deadline := time.Now().Add(netDiagProbeStartTimeout)
+	for {
+		select {
+		case <-exited:
+			stop()
+			return "", nil, fmt.Errorf("sing-box probe exited: %s", output.tail())
+		case <-ctx.Done():
+			stop()
+			return "", nil, ctx.Err()
+		default:
+		}
+		conn, dialErr := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+		if dialErr == nil {
+			_ = conn.Close()
+			debuglog.DebugLog("netdiag: probe for %q listening on %s", t.Tag, addr)
+			return addr, stop, nil
+		}
+		if time.Now().After(deadline) {
+			stop()
+			return "", nil, fmt.Errorf("sing-box probe did not start in %s: %s", netDiagProbeStartTimeout, output.tail())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// freeLoopbackPort — свободный TCP-порт на loopback. Между закрытием
+// listener'а и стартом зонда порт теоретически может занять кто-то ещё; тогда
+// зонд упадёт на bind, и это будет видно в ошибке.
+func freeLoopbackPort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = ln.Close() }()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// probeOutput — вывод зонда для текста ошибки. Пишут две горутины exec
+// (stdout и stderr), отсюда mutex.
+type probeOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *probeOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// Держим только хвост: зонд живёт секунды, но лог уровня warn от
+	// проблемного узла может сыпать строками на каждую попытку.
+	if o.buf.Len() > 16<<10 {
+		o.buf.Next(o.buf.Len() - 8<<10)
+	}
+	return o.buf.Write(p)
+}
+
+// tail — последняя непустая строка вывода: в ней sing-box пишет причину.
+func (o *probeOutput) tail() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	lines := strings.Split(strings.TrimSpace(stripANSI(o.buf.String())), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if last == "" {
+		return "no output"
+	}
+	return last
+}
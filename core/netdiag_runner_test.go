@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"singbox-launcher/core/netdiag"
+)
+
+// fakeNetDiagBackend реализует netDiagSource без сети.
+type fakeNetDiagBackend struct {
+	fakeBackend
+	quality netdiag.QualityResult
+	err     error
+	gotTag  string
+}
+
+func (f *fakeNetDiagBackend) netQuality(_ context.Context, t NetDiagTarget, _ netdiag.QualityOptions, onProgress func(netdiag.QualityResult)) (netdiag.QualityResult, error) {
+	f.gotTag = t.Tag
+	if onProgress != nil {
+		onProgress(f.quality)
+	}
+	return f.quality, f.err
+}
+
+func (f *fakeNetDiagBackend) netNAT(_ context.Context, t NetDiagTarget, server string) (netdiag.NATResult, error) {
+	f.gotTag = t.Tag
+	return netdiag.NATResult{Server: server, UDPReachable: true}, f.err
+}
+
+func writeNetDiagConfig(t *testing.T) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.json")
+	body := `{"outbounds":[{"type":"vless","tag":"n1","uuid":"550e8400-e29b-41d4-a716-446655440000","server":"a.example.com","server_port":443}]}`
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRunNetQuality_StoresByNodeIdentity(t *testing.T) {
+	ac := &AppController{NetDiag: netdiag.NewStore(t.TempDir())}
+	fb := &fakeNetDiagBackend{quality: netdiag.QualityResult{LatencyMs: 33, Via: netdiag.ViaClassic}}
+	ac.setBackend(fb)
+	target := NetDiagTarget{Tag: "n1", ConfigPath: writeNetDiagConfig(t)}
+
+	frames := 0
+	if _, err := ac.RunNetQuality(context.Background(), target, netdiag.QualityOptions{}, func(netdiag.QualityResult) { frames++ }); err != nil {
+		t.Fatal(err)
+	}
+	if fb.gotTag != "n1" || frames != 1 {
+		t.Fatalf("tag=%q frames=%d", fb.gotTag, frames)
+	}
+	key := NetDiagKey(target)
+	if key == "tag:n1" {
+		t.Fatal("outbound present in config must be keyed by its identity hash")
+	}
+	rec, ok := ac.NetDiag.Get(key)
+	if !ok || len(rec.Quality) != 1 || rec.Quality[0].LatencyMs != 33 {
+		t.Fatalf("record = %+v ok=%v", rec, ok)
+	}
+}
+
+func TestRunNATTest_FailureIsRecorded(t *testing.T) {
+	ac := &AppController{NetDiag: netdiag.NewStore(t.TempDir())}
+	ac.setBackend(&fakeNetDiagBackend{err: errors.New("udp blocked")})
+	target := NetDiagTarget{Tag: "n1", ConfigPath: writeNetDiagConfig(t)}
+
+	if _, err := ac.RunNATTest(context.Background(), target, "stun.example.com:3478"); err == nil {
+		t.Fatal("expected error")
+	}
+	rec, _ := ac.NetDiag.Get(NetDiagKey(target))
+	if len(rec.NAT) != 1 || rec.NAT[0].Error != "udp blocked" {
+		t.Fatalf("failed run must be kept for comparison: %+v", rec.NAT)
+	}
+}
+
+func TestRunNetQuality_UnsupportedBackend(t *testing.T) {
+	ac := &AppController{}
+	ac.setBackend(&fakeBackend{mode: BackendClassic})
+	if _, err := ac.RunNetQuality(context.Background(), NetDiagTarget{Tag: "x"}, netdiag.QualityOptions{}, nil); !errors.Is(err, errNetDiagUnsupported) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"singbox-launcher/core/config"
-	"singbox-launcher/internal/ctxutil"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/dialogs"
 	"singbox-launcher/internal/locale"
@@ -20,11 +19,13 @@ import (
 )
 
 const (
-	// restartAttempts is the maximum number of consecutive crash restart attempts
+	// restartAttempts is the default maximum number of consecutive crash
+	// restart attempts (state.supervision.max_attempts overrides it)
 	restartAttempts = 3
 
-	// stabilityThreshold is the duration a process must run without crashing
-	// before the crash counter is reset
+	// stabilityThreshold is the default duration a process must run without
+	// crashing before the crash counter is reset (state.supervision.stability_sec
+	// overrides it); the core-version trial always uses this value
 	stabilityThreshold = 180 * time.Second
 
 	// gracefulShutdownTimeout is the maximum time to wait for graceful shutdown
@@ -78,6 +79,9 @@ func triggerGhostTunCleanup() {
 // Пропускаем, если sing-box уже запущен — иначе снесём активный адаптер.
 // Не блокирует UI (фоновая goroutine, без задержки — stale уже давно мёртвые).
 func (svc *ProcessService) CleanupStaleTunAtStart() {
+	// Kill switch прошлой сессии остаётся в силе до явного Stop — забираем
+	// его под управление до любых решений про адаптеры.
+	svc.ac.adoptKillSwitchAtStart()
 	if found, pid := svc.isSingBoxProcessRunning(); found {
 		debuglog.WarnLog("CleanupStaleTunAtStart: skipped (sing-box already running PID=%d)", pid)
 		return
@@ -229,8 +233,11 @@ func (svc *ProcessService) Start(skipRunningCheck ...bool) {
 	ac.RunningState.Set(true)
 	ac.StoppedByUser = false
 	ac.StateService.ResetAutoUpdateFailedAttempts() // Reset so auto-update can retry after successful Start
+	ac.armKillSwitch()
 	// Add log with PID
 	debuglog.DebugLog("startSingBox: Sing-Box started. PID=%d", ac.SingboxCmd.Process.Pid)
+	ac.watchCoreTrial(ac.SingboxCmd.Process.Pid)
+	ac.watchCoreHealth(ac.SingboxCmd.Process.Pid)
 
 	// Start auto-loading proxies after sing-box is running
 	go func() {
@@ -290,6 +297,9 @@ func (svc *ProcessService) startSingBoxPrivileged() error {
 		ac.StoppedByUser = false
 		ac.StateService.ResetAutoUpdateFailedAttempts() // Reset so auto-update can retry after successful Start
 		ac.CmdMutex.Unlock()
+		ac.armKillSwitch()
+		ac.watchCoreTrial(scriptPID)
+		ac.watchCoreHealth(scriptPID)
 		_ = os.WriteFile(pidFilePath, []byte(fmt.Sprintf("%d\n%d", scriptPID, singboxPID)), platform.DefaultFileMode)
 		debuglog.DebugLog("startSingBox: Sing-Box started with privileges (script PID=%d, sing-box PID=%d).", scriptPID, singboxPID)
 		platform.WaitForPrivilegedExit(scriptPID)
@@ -317,6 +327,7 @@ func (svc *ProcessService) onPrivilegedScriptExited() {
 	if !ac.SingboxPrivilegedMode {
 		return
 	}
+	exitedPID := ac.SingboxPrivilegedPID
 	ac.SingboxPrivilegedMode = false
 	ac.SingboxPrivilegedPID = 0
 	ac.SingboxPrivilegedSingboxPID = 0
@@ -325,8 +336,19 @@ func (svc *ProcessService) onPrivilegedScriptExited() {
 
 	// SPEC 070: shared crash/restart decision. Privileged путь не имеет err
 	// (скрипт ждёт sing-box; cmd.Wait отсутствует) → cleanExit=false, поэтому
-	// actionClean здесь не возникает.
-	action, newAttempts := decideCrashAction(ac.StoppedByUser, ac.RestartRequestedByUser, false, ac.ConsecutiveCrashAttempts, restartAttempts)
+	// actionClean здесь не возникает. Лимит и паузы — из политики профиля
+	// (supervision.go).
+	eff := ac.SupervisionSettings()
+	crashDetail := "exited"
+	if ac.takeHealthRestartLocked() {
+		crashDetail = "killed by health probe"
+	}
+	action, newAttempts := decideCrashAction(ac.StoppedByUser, ac.RestartRequestedByUser, false, ac.ConsecutiveCrashAttempts, eff.MaxAttempts)
+	// Упала версия ядра в пробный период — сначала откат на прежнюю
+	// (core_versions.go), перезапуск уже на ней с чистым счётчиком.
+	if (action == actionCrashRestart || action == actionMaxAttempts) && ac.fallbackCoreAfterCrash(nil) {
+		action, newAttempts = actionCrashRestart, 1
+	}
 	ac.ConsecutiveCrashAttempts = newAttempts
 	switch action {
 	case actionStoppedByUser:
@@ -346,35 +368,29 @@ func (svc *ProcessService) onPrivilegedScriptExited() {
 		return
 	case actionMaxAttempts:
 		debuglog.DebugLog("onPrivilegedScriptExited: Max restart attempts reached.")
+		ac.recordGiveUpLocked(eff, exitedPID, crashDetail)
+		ac.holdKillSwitch(true)
 		if ac.UIService != nil && ac.UIService.MainWindow != nil {
-			dialogs.ShowError(ac.UIService.MainWindow, fmt.Errorf("%s", locale.Tf("error.restart_failed", restartAttempts)))
+			dialogs.ShowError(ac.UIService.MainWindow, fmt.Errorf("%s", locale.Tf("error.restart_failed", eff.MaxAttempts)))
 		}
 		return
 	}
 	// action == actionCrashRestart
-	debuglog.WarnLog("onPrivilegedScriptExited: Sing-Box exited, auto-restart (attempt %d/%d)", ac.ConsecutiveCrashAttempts, restartAttempts)
+	ac.holdKillSwitch(false)
+	delay := ac.planCrashRestartLocked(eff, exitedPID, crashDetail)
+	debuglog.WarnLog("onPrivilegedScriptExited: Sing-Box exited, auto-restart in %v (attempt %d/%d)", delay, ac.ConsecutiveCrashAttempts, eff.MaxAttempts)
 	if ac.UIService != nil && ac.UIService.Application != nil && ac.UIService.MainWindow != nil {
-		dialogs.ShowAutoHideInfo(ac.UIService.Application, ac.UIService.MainWindow, locale.T("core.crash_title"), locale.Tf("core.crash_restarting", ac.ConsecutiveCrashAttempts, restartAttempts))
+		dialogs.ShowAutoHideInfo(ac.UIService.Application, ac.UIService.MainWindow, locale.T("core.crash_title"), locale.Tf("core.crash_restarting", ac.ConsecutiveCrashAttempts, eff.MaxAttempts))
 	}
 	ac.CmdMutex.Unlock()
-	<-time.After(2 * time.Second)
+	if !ac.waitCrashRestart(delay) {
+		ac.CmdMutex.Lock()
+		return
+	}
 	svc.Start(true)
 	ac.CmdMutex.Lock()
 	if ac.RunningState.IsRunning() {
-		currentAttemptCount := ac.ConsecutiveCrashAttempts
-		go func() {
-			if ctxutil.SleepWithContext(ac.ctx, stabilityThreshold) != nil {
-				return
-			}
-			ac.CmdMutex.Lock()
-			defer ac.CmdMutex.Unlock()
-			if ac.RunningState.IsRunning() && ac.ConsecutiveCrashAttempts == currentAttemptCount {
-				ac.ConsecutiveCrashAttempts = 0
-				if ac.UIService != nil && ac.UIService.UpdateCoreStatusFunc != nil {
-					ac.UIService.UpdateCoreStatusFunc()
-				}
-			}
-		}()
+		ac.scheduleCrashCounterReset(eff)
 	}
 }
 
@@ -401,7 +417,16 @@ func (svc *ProcessService) Monitor(cmdToMonitor *exec.Cmd) {
 	// SPEC 070: shared crash/restart decision (steps 2-5). PID-check (step 1)
 	// stays above; err==nil graceful-exit is fed via cleanExit so the decision
 	// lives in one place but Monitor keeps its per-branch RunningState placement.
-	action, newAttempts := decideCrashAction(ac.StoppedByUser, ac.RestartRequestedByUser, err == nil, ac.ConsecutiveCrashAttempts, restartAttempts)
+	// A process killed by the health probe (supervision.go) is a crash even if
+	// it exited with code 0; the attempt limit and delays come from the
+	// profile's supervision policy.
+	eff := ac.SupervisionSettings()
+	healthRestart := ac.takeHealthRestartLocked()
+	crashDetail := fmt.Sprint(err)
+	if healthRestart {
+		crashDetail = "killed by health probe"
+	}
+	action, newAttempts := decideCrashAction(ac.StoppedByUser, ac.RestartRequestedByUser, err == nil && !healthRestart, ac.ConsecutiveCrashAttempts, eff.MaxAttempts)
 
 	// 2. Then StoppedByUser (did user stop it?)
 	if action == actionStoppedByUser {
@@ -436,29 +461,44 @@ func (svc *ProcessService) Monitor(cmdToMonitor *exec.Cmd) {
 		debuglog.InfoLog("monitorSingBox: Sing-Box exited gracefully (exit code 0).")
 		ac.ConsecutiveCrashAttempts = newAttempts
 		ac.RunningState.Set(false)
+		// Ядро вышло само, а не по Stop: VPN всё ещё должен работать.
+		ac.holdKillSwitch(false)
 		return
 	}
 
 	// 5. Crash → restart with delay and attempt limit
 	ac.RunningState.Set(false)
+	// Упала версия ядра в пробный период — сначала откат на прежнюю
+	// (core_versions.go), перезапуск уже на ней с чистым счётчиком.
+	if ac.fallbackCoreAfterCrash(err) {
+		action, newAttempts = actionCrashRestart, 1
+	}
 	ac.ConsecutiveCrashAttempts = newAttempts
 
 	if action == actionMaxAttempts {
-		debuglog.DebugLog("monitorSingBox: Maximum restart attempts (%d) reached. Stopping auto-restart.", restartAttempts)
+		debuglog.DebugLog("monitorSingBox: Maximum restart attempts (%d) reached. Stopping auto-restart.", eff.MaxAttempts)
+		ac.recordGiveUpLocked(eff, monitoredPID, crashDetail)
+		ac.holdKillSwitch(true)
 		if ac.UIService != nil && ac.UIService.MainWindow != nil {
-			dialogs.ShowError(ac.UIService.MainWindow, fmt.Errorf("%s", locale.Tf("error.restart_failed", restartAttempts)))
+			dialogs.ShowError(ac.UIService.MainWindow, fmt.Errorf("%s", locale.Tf("error.restart_failed", eff.MaxAttempts)))
 		}
 		return
 	}
 
 	// action == actionCrashRestart
-	debuglog.WarnLog("monitorSingBox: Sing-Box crashed: %v, attempting auto-restart (attempt %d/%d)", err, ac.ConsecutiveCrashAttempts, restartAttempts)
+	ac.holdKillSwitch(false)
+	delay := ac.planCrashRestartLocked(eff, monitoredPID, crashDetail)
+	debuglog.WarnLog("monitorSingBox: Sing-Box crashed: %s, auto-restart in %v (attempt %d/%d)", crashDetail, delay, ac.ConsecutiveCrashAttempts, eff.MaxAttempts)
 	if ac.UIService != nil && ac.UIService.Application != nil && ac.UIService.MainWindow != nil {
-		dialogs.ShowAutoHideInfo(ac.UIService.Application, ac.UIService.MainWindow, locale.T("core.crash_title"), locale.Tf("core.crash_restarting", ac.ConsecutiveCrashAttempts, restartAttempts))
+		dialogs.ShowAutoHideInfo(ac.UIService.Application, ac.UIService.MainWindow, locale.T("core.crash_title"), locale.Tf("core.crash_restarting", ac.ConsecutiveCrashAttempts, eff.MaxAttempts))
 	}
 
 	ac.CmdMutex.Unlock()
-	<-time.After(2 * time.Second)
+	if !ac.waitCrashRestart(delay) {
+		debuglog.InfoLog("monitorSingBox: Auto-restart cancelled (core stopped or started during the delay).")
+		ac.CmdMutex.Lock()
+		return
+	}
 	// SPEC 065 hotfix (v0.9.9.1): cleanup phantom singbox-tun adapter from
 	// the just-crashed sing-box BEFORE auto-restart. Без этого хука каждый
 	// retry создаёт новый адаптер (singbox-tun0 → tun1 → tun2) потому что
@@ -471,22 +511,7 @@ func (svc *ProcessService) Monitor(cmdToMonitor *exec.Cmd) {
 
 	if ac.RunningState.IsRunning() {
 		debuglog.InfoLog("monitorSingBox: Sing-Box restarted successfully.")
-		currentAttemptCount := ac.ConsecutiveCrashAttempts
-		go func() {
-			if ctxutil.SleepWithContext(ac.ctx, stabilityThreshold) != nil {
-				debuglog.InfoLog("monitorSingBox: Stability check cancelled (context cancelled)")
-				return
-			}
-			ac.CmdMutex.Lock()
-			defer ac.CmdMutex.Unlock()
-			if ac.RunningState.IsRunning() && ac.ConsecutiveCrashAttempts == currentAttemptCount {
-				debuglog.DebugLog("monitorSingBox: Process has been stable for %v. Resetting crash counter from %d to 0.", stabilityThreshold, ac.ConsecutiveCrashAttempts)
-				ac.ConsecutiveCrashAttempts = 0
-				if ac.UIService != nil && ac.UIService.UpdateCoreStatusFunc != nil {
-					ac.UIService.UpdateCoreStatusFunc()
-				}
-			}
-		}()
+		ac.scheduleCrashCounterReset(eff)
 	} else {
 		debuglog.DebugLog("monitorSingBox: Restart attempt %d failed.", ac.ConsecutiveCrashAttempts)
 	}
@@ -501,6 +526,10 @@ func (svc *ProcessService) Stop() {
 	// This ensures the monitor sees the flag even if the process exits very quickly
 	ac.StoppedByUser = true
 	ac.ConsecutiveCrashAttempts = 0
+	ac.supervision.pending = false // pending auto-restart (supervision.go) must not bring it back
+	// Stop — единственный (кроме кнопки в окне) способ снять kill switch;
+	// снимаем и когда ядро уже не работает (присмотр сдался).
+	ac.releaseKillSwitch(true)
 
 	if !ac.RunningState.IsRunning() {
 		ac.StoppedByUser = false
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/backup"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+)
+
+// Резервная копия профиля (core/backup) со стороны контроллера: версия
+// лаунчера в манифест, а после восстановления — всё, что держит прочитанные
+// файлы в памяти, узнаёт о новых.
+
+// CreateBackup собирает зашифрованную копию выбранных категорий (пусто —
+// все).
+func (ac *AppController) CreateBackup(passphrase string, categories []backup.Category) ([]byte, backup.Manifest, error) {
+	return backup.Create(ac.FileService.ExecDir, constants.AppVersion, passphrase, categories)
+}
+
+// InspectBackup описывает копию, ничего не восстанавливая.
+func (ac *AppController) InspectBackup(raw []byte, passphrase string) (backup.Manifest, error) {
+	return backup.Inspect(raw, passphrase)
+}
+
+// RestoreBackup восстанавливает выбранные категории (пусто — все, что есть
+// в копии).
+//
+// Конфиг после восстановления помечается устаревшим: следующий Start или
+// Update пересоберёт его из новых состояний и кэшей. Настройки (язык, токен
+// Debug API) вступают в силу после перезапуска лаунчера. Смена state.json
+// пишется в журнал действий от имени actor.
+func (ac *AppController) RestoreBackup(actor audit.Actor, raw []byte, passphrase string, categories []backup.Category) (backup.Report, error) {
+	before := ac.StateSnapshot()
+	report, err := backup.Restore(ac.FileService.ExecDir, raw, passphrase, categories)
+	if err != nil {
+		return report, err
+	}
+	if ac.StateService != nil {
+		if report.Restored(backup.CategoryStates) || report.Restored(backup.CategoryWarp) {
+			ac.StateService.MarkCacheStale()
+			ac.StateService.MarkConfigStale()
+		} else if report.Restored(backup.CategorySubscriptions) || report.Restored(backup.CategoryRuleSets) {
+			ac.StateService.MarkConfigStale()
+		}
+	}
+	if report.Restored(backup.CategoryStates) {
+		ac.ApplyStateChange(actor, before)
+	}
+	// Машины с теми же ID могли получить другие адреса и ключи — открытые
+	// транспорты Debug API к ним больше не годятся.
+	if report.Restored(backup.CategoryRemote) && debugAPIRemotePool != nil {
+		debugAPIRemotePool.CloseAll()
+	}
+	if ac.UIService != nil && ac.UIService.UpdateConfigStatusFunc != nil {
+		ac.UIService.UpdateConfigStatusFunc()
+	}
+	debuglog.InfoLog("backup: restore finished, previous files kept in %q", report.SavedTo)
+	return report, nil
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/build"
 	"singbox-launcher/core/events"
 	"singbox-launcher/core/services"
@@ -95,8 +96,15 @@ func stripANSI(s string) string {
 // Возвращает:
 //   - nil — успех (или nothing-to-do);
 //   - error — fatal на этапе сборки/записи.
+//
+// Пересборка пишется в журнал действий под актором system — как шаг
+// старта, обновления или перезапуска; точки входа, у которых есть свой
+// актор (окно, Debug API, CLI), зовут RebuildConfigBy.
 func (ac *AppController) RebuildConfigIfDirty(forced ...bool) error {
-	isForced := len(forced) > 0 && forced[0]
+	return ac.RebuildConfigBy(audit.ActorSystem, len(forced) > 0 && forced[0])
+}
+
+func (ac *AppController) rebuildConfig(isForced bool) error {
 	if ac == nil || ac.StateService == nil {
 		return nil
 	}
@@ -188,7 +196,13 @@ func (ac *AppController) RebuildConfigIfDirty(forced ...bool) error {
 	// как юзер нажмёт Connect и получит non-obvious "FATAL: ..." в логе.
 	// Ошибка → ErrorLog + popup через UIService.
 	configValid := true
-	if checkErr := validateConfigViaSingBox(ac.FileService.SingboxPath, ac.FileService.ConfigPath); checkErr != nil {
+	checkErr := validateConfigViaSingBox(ac.FileService.SingboxPath, ac.FileService.ConfigPath)
+	// Отверг config пробная версия ядра, а прежняя его принимает — виновато
+	// ядро: откат на прежнюю (core_versions.go), и конфиг валиден.
+	if checkErr != nil && ac.fallbackCoreOnCheckFailure(ac.FileService.ConfigPath, checkErr) {
+		checkErr = nil
+	}
+	if checkErr != nil {
 		debuglog.ErrorLog("RebuildConfigIfDirty: sing-box check failed: %v", checkErr)
 		if ac.UIService != nil && ac.UIService.MainWindow != nil {
 			dialogs.ShowErrorText(ac.UIService.MainWindow,
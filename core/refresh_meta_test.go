@@ -5,9 +5,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"singbox-launcher/core/services"
 	"singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
 	"singbox-launcher/internal/platform"
 )
 
@@ -234,3 +237,60 @@ func TestRefreshSubscriptionsMetaAndCache_EmptyBodyDoesNotCache(t *testing.T) {
 		t.Errorf("raw cache for empty body should not exist: %s", rawPath)
 	}
 }
+
+// TestRefreshRemoteSubscriptions — тот же пайплайн для удалённой машины:
+// тело и meta ложатся в ЕЁ каталог, локальные bin/subscriptions/ и
+// state.json не тронуты.
+func TestRefreshRemoteSubscriptions(t *testing.T) {
+	body := "vless://uuid@host:443#tokyo\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/404" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	execDir := t.TempDir()
+	ac := &AppController{FileService: &services.FileService{ExecDir: execDir}}
+
+	if _, err := ac.RefreshRemoteSubscriptions("router"); err == nil || !strings.Contains(err.Error(), "Configure") {
+		t.Errorf("missing profile: err = %v, want a hint to run Configure", err)
+	}
+
+	statePath := platform.GetWizardStatePathFor(execDir, constants.ConfigTargetRemote, "router")
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := state.New()
+	s.Connections.Sources = []state.Source{
+		{ID: "01OK", Type: state.SourceTypeSubscription, Enabled: true, URL: srv.URL},
+		{ID: "01DOWN", Type: state.SourceTypeSubscription, Enabled: true, URL: srv.URL + "/404"},
+	}
+	if err := s.Save(statePath); err != nil {
+		t.Fatalf("seed state: %v", err)
+	}
+
+	detail, err := ac.RefreshRemoteSubscriptions("router")
+	if err != nil {
+		t.Fatalf("RefreshRemoteSubscriptions: %v", err)
+	}
+	if detail != "1 of 2 subscription(s) fetched" {
+		t.Errorf("detail = %q", detail)
+	}
+	subsDir := platform.GetSubscriptionsDirFor(execDir, constants.ConfigTargetRemote, "router")
+	if raw, err := os.ReadFile(filepath.Join(subsDir, "01OK.raw")); err != nil || string(raw) != body {
+		t.Errorf("machine raw body: %q, %v", raw, err)
+	}
+	if _, err := os.Stat(platform.GetSubscriptionsDir(execDir)); !os.IsNotExist(err) {
+		t.Errorf("local subscriptions dir must stay untouched: %v", err)
+	}
+	reloaded, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := reloaded.Connections.Sources[0].Meta; m == nil || m.LastStatus != "ok" {
+		t.Errorf("machine state meta not persisted: %+v", m)
+	}
+}
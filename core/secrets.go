@@ -0,0 +1,219 @@
+package core
+
+// Шифрование секретов на диске (internal/secretstore): включение,
+// выключение и смена ключа перезаписывают все файлы с секретами — профили
+// в bin/wizard_states/ (локальные, сохранённые, машин и базовые), кеши тел
+// подписок, settings.json и реестр машин. Сначала всё читается старым ключом, затем
+// меняется ключ, затем всё пишется новым: файл, прочитанный и записанный
+// разными ключами, был бы потерян.
+//
+// Собранный config.json остаётся открытым — его читает sing-box.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
+)
+
+// SecretsStatus — включено ли шифрование, источник ключа, есть ли ключ.
+func (ac *AppController) SecretsStatus() secretstore.Status {
+	return secretstore.CurrentStatus()
+}
+
+// SetSecretsEncryption включает шифрование (или меняет ключ) и перезаписывает
+// файлы с секретами. passphrase — только для secretstore.SourcePassphrase.
+func (ac *AppController) SetSecretsEncryption(source secretstore.Source, passphrase string) error {
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	return ac.rewriteSecretFiles(func() error {
+		return secretstore.Enable(binDir, source, passphrase)
+	})
+}
+
+// DisableSecretsEncryption выключает шифрование и перезаписывает файлы
+// открытыми.
+func (ac *AppController) DisableSecretsEncryption() error {
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	return ac.rewriteSecretFiles(func() error {
+		return secretstore.Disable(binDir)
+	})
+}
+
+// UnlockSecrets принимает пароль, введённый в окне лаунчера, и поднимает
+// Debug API, который на старте ждал токен.
+func (ac *AppController) UnlockSecrets(passphrase string) error {
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	if err := secretstore.Unlock(binDir, passphrase); err != nil {
+		return err
+	}
+	debuglog.InfoLog("secrets: unlocked with the passphrase")
+	settings := locale.LoadSettings(binDir)
+	if settings.DebugAPIEnabled && settings.DebugAPIToken != "" && !ac.DebugAPIRunning() {
+		if err := ac.StartDebugAPI(settings.DebugAPIPort, settings.DebugAPIToken); err != nil {
+			debuglog.WarnLog("debug-api: failed to start: %v", err)
+		}
+	}
+	if ac.UIService != nil && ac.UIService.UpdateConfigStatusFunc != nil {
+		ac.UIService.UpdateConfigStatusFunc()
+	}
+	return nil
+}
+
+// rewriteSecretFiles читает файлы с секретами, выполняет change (смена
+// ключа) и пишет их обратно.
+//
+// Профили перекодируются без Save: содержимое и UpdatedAt не меняются, а
+// одинаковые файлы (базовый профиль и его снимок у машины) остаются
+// одинаковыми. Профили старых раскладок (без meta v6) не трогаются —
+// запечатанных полей в них быть не может, а открытые запечатает первый же
+// Save.
+func (ac *AppController) rewriteSecretFiles(change func() error) error {
+	if secretstore.Locked() {
+		return secretstore.ErrLocked
+	}
+	execDir := ac.FileService.ExecDir
+	binDir := platform.GetBinDir(execDir)
+
+	type loadedState struct {
+		path string
+		s    *state.State
+	}
+	var states []loadedState
+	err := filepath.WalkDir(platform.GetWizardStatesDir(execDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() || name == constants.ConfigFileName ||
+			!(strings.HasSuffix(name, ".json") || name == constants.BaseProfileSnapshotFileName) {
+			return nil
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var probe struct {
+			Meta struct {
+				Version int `json:"version"`
+			} `json:"meta"`
+		}
+		if json.Unmarshal(raw, &probe) != nil || probe.Meta.Version < state.SchemaVersionV6 {
+			return nil
+		}
+		s, err := state.Parse(raw)
+		if err != nil {
+			if errors.Is(err, secretstore.ErrLocked) {
+				return err
+			}
+			debuglog.DebugLog("secrets: skip %s: %v", path, err)
+			return nil
+		}
+		states = append(states, loadedState{path, s})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("secrets: read profiles: %w", err)
+	}
+	caches, err := readRawCaches(execDir)
+	if err != nil {
+		return fmt.Errorf("secrets: read subscription caches: %w", err)
+	}
+	settings := locale.LoadSettings(binDir)
+
+	registry := services.NewRemoteRegistry(execDir)
+	if err := registry.ResealSecrets(change); err != nil {
+		return err
+	}
+	for _, ls := range states {
+		data, err := ls.s.Encode()
+		if err != nil {
+			return fmt.Errorf("secrets: %s: %w", ls.path, err)
+		}
+		tmp := ls.path + ".tmp"
+		if err := os.WriteFile(tmp, data, platform.DefaultFileMode); err != nil {
+			return fmt.Errorf("secrets: %w", err)
+		}
+		if err := os.Rename(tmp, ls.path); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("secrets: %w", err)
+		}
+	}
+	for _, c := range caches {
+		if err := state.WriteRawBody(c.dir, c.id, c.body); err != nil {
+			return fmt.Errorf("secrets: %w", err)
+		}
+	}
+	if err := locale.SaveSettings(binDir, settings); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	mode := "off"
+	if secretstore.Enabled() {
+		mode = "on"
+	}
+	debuglog.InfoLog("secrets: rewrote %d profile(s), %d subscription cache(s), settings.json and the machine registry (encryption %s)", len(states), len(caches), mode)
+	return nil
+}
+
+// rawCache — тело подписки, прочитанное старым ключом.
+type rawCache struct {
+	dir, id string
+	body    []byte
+}
+
+// readRawCaches читает кеши подписок: локальный bin/subscriptions/ и
+// каталоги subscriptions/ машин под bin/wizard_states/.
+func readRawCaches(execDir string) ([]rawCache, error) {
+	dirs := []string{platform.GetSubscriptionsDir(execDir)}
+	err := filepath.WalkDir(platform.GetWizardStatesDir(execDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == constants.SubscriptionsDirName {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []rawCache
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			id, ok := strings.CutSuffix(e.Name(), ".raw")
+			if !ok || e.IsDir() {
+				continue
+			}
+			body, err := state.ReadRawBody(dir, id)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, rawCache{dir, id, body})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
+)
+
+// Включение и выключение шифрования перезаписывают и кеши тел подписок —
+// локальный и машины: в них те же учётные данные нод.
+func TestSecretsEncryptionRewritesRawCaches(t *testing.T) {
+	dir := t.TempDir()
+	ac := &AppController{FileService: &services.FileService{ExecDir: dir}}
+	if err := os.MkdirAll(platform.GetBinDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = secretstore.Init(t.TempDir()) })
+
+	const body = "trojan://RAWSECRET@host:443#n"
+	dirs := []string{
+		platform.GetSubscriptionsDir(dir),
+		platform.GetSubscriptionsDirFor(dir, "remote", "m1"),
+	}
+	for _, d := range dirs {
+		if err := state.WriteRawBody(d, "a", []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	onDisk := func(d string) string {
+		data, _ := os.ReadFile(filepath.Join(d, "a.raw"))
+		return string(data)
+	}
+
+	if err := ac.SetSecretsEncryption(secretstore.SourcePassphrase, "correct horse"); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	for _, d := range dirs {
+		if got := onDisk(d); strings.Contains(got, "RAWSECRET") || !secretstore.IsSealed(got) {
+			t.Errorf("%s not sealed: %q", d, got)
+		}
+		if got, err := state.ReadRawBody(d, "a"); err != nil || string(got) != body {
+			t.Errorf("%s read = %q (%v)", d, got, err)
+		}
+	}
+
+	if err := ac.DisableSecretsEncryption(); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	for _, d := range dirs {
+		if got := onDisk(d); got != body {
+			t.Errorf("%s after disable = %q", d, got)
+		}
+	}
+}
@@ -7,10 +7,9 @@ import (
 	"sync"
 	"time"
 
-	"fyne.io/fyne/v2"
-
 	"singbox-launcher/api"
 	"singbox-launcher/core/config"
+	"singbox-launcher/core/events"
 	"singbox-launcher/internal/ctxutil"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/platform"
@@ -95,11 +94,17 @@ type APIService struct {
 	RunningStateIsRunning func() bool
 	OnProxiesUpdated      func() // Called when proxies are updated
 	OnProxySwitched       func() // Called when proxy is switched
+	// EventBus — куда публиковать events.ProxySwitched; nil — не публиковать.
+	EventBus events.Bus
 
 	// transportOverride — альтернативный транспорт proxy-операций
 	// (daemon-режим: gRPC к lxd). nil = классический Clash HTTP.
 	// Protected by StateMutex.
 	transportOverride ProxyTransport
+
+	// closeConnsOnSwitch — рвать соединения группы после переключения её
+	// узла (см. closeConnectionsAfterSwitch). Protected by StateMutex.
+	closeConnsOnSwitch bool
 }
 
 // NewAPIService creates and initializes a new APIService instance.
@@ -523,7 +528,7 @@ func (apiSvc *APIService) AutoLoadProxies(ctx context.Context) {
 				debuglog.DebugLog("AutoLoadProxies: Result for group '%s' dropped — superseded", currentGroup)
 				return
 			}
-			fyne.Do(func() {
+			runOnUI(func() {
 				if isStale() {
 					return
 				}
@@ -585,6 +590,8 @@ func (apiSvc *APIService) SwitchProxy(group, proxyName string) error {
 	apiSvc.SetActiveProxyName(proxyName)
 	// Сохраняем последний выбранный прокси для текущей группы для автоматического переключения при следующем старте
 	apiSvc.SetLastSelectedProxyForGroup(group, proxyName)
+	apiSvc.closeConnectionsAfterSwitch(transport, group)
+	apiSvc.publishProxySwitched(transport, group, proxyName)
 
 	// Notify about proxy switch
 	if apiSvc.OnProxySwitched != nil {
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+
+	"singbox-launcher/api"
+	"singbox-launcher/internal/debuglog"
+)
+
+// ConnectionCloser — транспорт, умеющий рвать соединения ядра. Отдельно от
+// ProxyTransport: переключение группы обязано уметь любой транспорт, а обрыв
+// соединений — опциональная способность (Clash HTTP, gRPC демона и машины).
+type ConnectionCloser interface {
+	// CloseConnection обрывает одно соединение по его id.
+	CloseConnection(id string) error
+	// CloseAllConnections обрывает все соединения ядра.
+	CloseAllConnections() error
+}
+
+// connectionMatcher — транспорт, умеющий сам отобрать соединения по фильтру.
+// Есть только у Clash HTTP: gRPC-транспорты отдают соединения стримом, и
+// разового снимка для отбора у них нет.
+type connectionMatcher interface {
+	CloseConnectionsMatching(f api.ConnectionFilter) (int, error)
+}
+
+// CloseConnection implements ConnectionCloser.
+func (t ClashTransport) CloseConnection(id string) error {
+	return api.CloseConnection(t.BaseURL, t.Token, id)
+}
+
+// CloseAllConnections implements ConnectionCloser.
+func (t ClashTransport) CloseAllConnections() error {
+	return api.CloseAllConnections(t.BaseURL, t.Token)
+}
+
+// CloseConnectionsMatching обрывает соединения, подходящие под фильтр.
+func (t ClashTransport) CloseConnectionsMatching(f api.ConnectionFilter) (int, error) {
+	return api.CloseConnectionsMatching(t.BaseURL, t.Token, f)
+}
+
+// SetCloseConnectionsOnSwitch включает обрыв соединений группы после
+// переключения её узла (settings.json close_connections_on_switch).
+func (apiSvc *APIService) SetCloseConnectionsOnSwitch(enabled bool) {
+	apiSvc.StateMutex.Lock()
+	defer apiSvc.StateMutex.Unlock()
+	apiSvc.closeConnsOnSwitch = enabled
+}
+
+// CloseConnectionsOnSwitch сообщает, включён ли обрыв соединений после
+// переключения узла.
+func (apiSvc *APIService) CloseConnectionsOnSwitch() bool {
+	apiSvc.StateMutex.RLock()
+	defer apiSvc.StateMutex.RUnlock()
+	return apiSvc.closeConnsOnSwitch
+}
+
+// connectionCloser — текущий транспорт как ConnectionCloser.
+func (apiSvc *APIService) connectionCloser() (ConnectionCloser, error) {
+	t, err := apiSvc.wireTransport()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := t.(ConnectionCloser)
+	if !ok {
+		return nil, fmt.Errorf("closing connections is not supported by the current core engine")
+	}
+	return c, nil
+}
+
+// CloseConnections обрывает соединения по списку id. Останавливается на
+// первой ошибке: дальше, как правило, та же ошибка (ядро остановлено).
+func (apiSvc *APIService) CloseConnections(ids []string) error {
+	c, err := apiSvc.connectionCloser()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := c.CloseConnection(id); err != nil {
+			return fmt.Errorf("close connection %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// CloseAllConnections обрывает все соединения текущего ядра.
+func (apiSvc *APIService) CloseAllConnections() error {
+	c, err := apiSvc.connectionCloser()
+	if err != nil {
+		return err
+	}
+	return c.CloseAllConnections()
+}
+
+// CloseConnectionsMatching обрывает соединения, подходящие под фильтр, и
+// возвращает их число. Пустой фильтр — обрыв всех; число тогда -1, если
+// транспорт не умеет его посчитать (gRPC).
+func (apiSvc *APIService) CloseConnectionsMatching(f api.ConnectionFilter) (int, error) {
+	c, err := apiSvc.connectionCloser()
+	if err != nil {
+		return 0, err
+	}
+	if m, ok := c.(connectionMatcher); ok {
+		return m.CloseConnectionsMatching(f)
+	}
+	if !f.IsEmpty() {
+		return 0, fmt.Errorf("filtered close is not supported by the current core engine")
+	}
+	if err := c.CloseAllConnections(); err != nil {
+		return 0, err
+	}
+	return -1, nil
+}
+
+// closeConnectionsAfterSwitch рвёт соединения, идущие через группу, после
+// переключения её узла, если это включено в настройках.
+//
+// Без этого уже открытые сессии доживают на прежнем узле: переключение в
+// селекторе влияет только на новые соединения, и «переключил, а ничего не
+// поменялось» — типичная жалоба. Транспорт без отбора по фильтру (gRPC) рвёт
+// всё: сузить там нечем, а оставить старые сессии — хуже.
+func (apiSvc *APIService) closeConnectionsAfterSwitch(t ProxyTransport, group string) {
+	if !apiSvc.CloseConnectionsOnSwitch() {
+		return
+	}
+	if m, ok := t.(connectionMatcher); ok {
+		n, err := m.CloseConnectionsMatching(api.ConnectionFilter{Outbound: group})
+		if err != nil {
+			debuglog.WarnLog("SwitchProxy: close connections of group %q: %v", group, err)
+			return
+		}
+		debuglog.InfoLog("SwitchProxy: closed %d connection(s) of group %q", n, group)
+		return
+	}
+	c, ok := t.(ConnectionCloser)
+	if !ok {
+		return
+	}
+	if err := c.CloseAllConnections(); err != nil {
+		debuglog.WarnLog("SwitchProxy: close all connections after switching %q: %v", group, err)
+		return
+	}
+	debuglog.InfoLog("SwitchProxy: closed all connections after switching %q", group)
+}
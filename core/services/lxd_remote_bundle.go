@@ -0,0 +1,479 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	corestate "singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// Перенос машин между лаунчерами: зашифрованный пакет с записями реестра,
+// их клиентскими парами и состоянием визарда.
+//
+// Без него каждый в команде сопрягался с теми же роутерами сам: реестр и
+// ключи лежат в bin/ и по отдельности не переносятся — запись без ключа
+// бесполезна, ключ без пина опасен. Пакет несёт всё вместе и шифруется
+// паролем (scrypt → AES-256-GCM): внутри приватные ключи, то есть полный
+// доступ к каждой машине.
+//
+// Чего в пакете нет: собранного config.json, .srs и тел подписок — это
+// производные, и Configure/Deploy восстановят их у получателя под его пути
+// (то же рассуждение, что у CopyProfileFrom).
+//
+// Ключ в пакете — ТОТ ЖЕ, что у отправителя: демон видит обоих как один
+// клиент. Ротация (lxd_remote_certs.go) на любой стороне отзовёт его и у
+// другой; своё имя у демона получатель заводит повторным сопряжением.
+
+// remoteBundleFormat — метка пакета: чужой JSON отбивается до расшифровки.
+const remoteBundleFormat = "singbox-launcher/remote-bundle"
+
+// remoteBundleVersion — версия раскладки payload.
+const remoteBundleVersion = 1
+
+// MinBundlePassphrase — минимальная длина пароля пакета.
+const MinBundlePassphrase = 8
+
+// Параметры scrypt: рекомендованные для интерактивного входа (~100 мс).
+const (
+	bundleScryptN = 1 << 15
+	bundleScryptR = 8
+	bundleScryptP = 1
+)
+
+// ErrBundlePassphrase — пароль не подошёл (или пакет повреждён: GCM их не
+// различает).
+var ErrBundlePassphrase = errors.New("remote bundle: wrong passphrase or damaged file")
+
+// BundleConflict — что делать с машиной, которая уже есть в реестре (тот же
+// адрес или тот же пин сервера).
+type BundleConflict string
+
+const (
+	// BundleConflictSkip — оставить свою запись, машину из пакета пропустить.
+	BundleConflictSkip BundleConflict = "skip"
+	// BundleConflictReplace — своя запись удаляется (с ключами и состоянием)
+	// и заменяется машиной из пакета.
+	BundleConflictReplace BundleConflict = "replace"
+)
+
+// ParseBundleConflict разбирает политику; пусто — skip.
+func ParseBundleConflict(s string) (BundleConflict, error) {
+	switch c := BundleConflict(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return BundleConflictSkip, nil
+	case BundleConflictSkip, BundleConflictReplace:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (want skip or replace)", s)
+}
+
+// remoteBundleEnvelope — файл пакета. []byte в JSON — base64.
+type remoteBundleEnvelope struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// remoteBundlePayload — расшифрованное содержимое.
+type remoteBundlePayload struct {
+	CreatedAt string          `json:"created_at"`
+	Machines  []bundleMachine `json:"machines"`
+	// Profiles — базовые профили, от которых наследуют машины пакета.
+	Profiles map[string][]byte `json:"profiles,omitempty"`
+}
+
+// mapStates применяет f ко всем state.json пакета: профилям, состояниям
+// машин и снимкам их баз.
+func (p *remoteBundlePayload) mapStates(f func([]byte) ([]byte, error)) error {
+	for name, body := range p.Profiles {
+		out, err := f(body)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		p.Profiles[name] = out
+	}
+	for i := range p.Machines {
+		m := &p.Machines[i]
+		for _, b := range []*[]byte{&m.State, &m.BaseSnapshot} {
+			if *b == nil {
+				continue
+			}
+			out, err := f(*b)
+			if err != nil {
+				return fmt.Errorf("machine %q: %w", m.Entry.Name, err)
+			}
+			*b = out
+		}
+	}
+	return nil
+}
+
+// bundleMachine — одна машина: запись, пара и состояние визарда байт в
+// байт (как в CopyProfileFrom — без прогона через текущую модель).
+type bundleMachine struct {
+	Entry        RemoteDaemon `json:"entry"`
+	ClientCert   []byte       `json:"client_cert,omitempty"`
+	ClientKey    []byte       `json:"client_key,omitempty"`
+	State        []byte       `json:"state,omitempty"`
+	BaseSnapshot []byte       `json:"base_snapshot,omitempty"`
+}
+
+// BundleImportResult — исход импорта одной машины.
+type BundleImportResult struct {
+	Name string
+	Addr string
+	// ID — запись, созданная импортом; пусто, если машина пропущена.
+	ID string
+	// ConflictID — своя запись с тем же адресом или пином.
+	ConflictID string
+	Skipped    bool
+	Replaced   bool
+	// Warnings — импорт прошёл, но получателю есть что поправить руками.
+	Warnings []string
+	Err      string
+}
+
+// BundleImportReport — итог импорта.
+type BundleImportReport struct {
+	Machines []BundleImportResult
+	// ProfilesAdded — базовые профили, которых у получателя не было.
+	ProfilesAdded []string
+	// ProfilesKept — профили с тем же именем, но другим содержимым: свой
+	// остаётся, наследники перестроятся на него при следующем Configure.
+	ProfilesKept []string
+}
+
+// ExportBundle собирает зашифрованный пакет из выбранных машин.
+func (r *RemoteRegistry) ExportBundle(ids []string, passphrase string) ([]byte, error) {
+	if len(passphrase) < MinBundlePassphrase {
+		return nil, fmt.Errorf("remote bundle: passphrase must be at least %d characters", MinBundlePassphrase)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("remote bundle: no machines selected")
+	}
+	r.mu.Lock()
+	list, err := r.listLocked()
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]RemoteDaemon, len(list))
+	for _, d := range list {
+		byID[d.ID] = d
+	}
+
+	payload := remoteBundlePayload{
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Profiles:  map[string][]byte{},
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		d, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("remote registry: unknown id %q", id)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		m := bundleMachine{Entry: d}
+		dir := r.identityDir(id)
+		m.ClientCert, err = readOptional(filepath.Join(dir, "client_cert.pem"))
+		if err != nil {
+			return nil, err
+		}
+		m.ClientKey, err = readOptional(filepath.Join(dir, "client_key.pem"))
+		if err != nil {
+			return nil, err
+		}
+		// mTLS-машина без пары в пакете — запись, которая никуда не пустит.
+		if d.ServerFingerprint != "" && (m.ClientCert == nil || m.ClientKey == nil) {
+			return nil, fmt.Errorf("remote bundle: machine %q has no client key to export", d.Name)
+		}
+		if m.State, err = readOptional(platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, id)); err != nil {
+			return nil, err
+		}
+		if m.BaseSnapshot, err = readOptional(platform.GetBaseProfileSnapshotPathFor(r.execDir, id)); err != nil {
+			return nil, err
+		}
+		if name := d.BaseProfile; name != "" {
+			if _, done := payload.Profiles[name]; !done {
+				raw, err := r.GetBaseProfile(name)
+				if err != nil {
+					return nil, fmt.Errorf("remote bundle: machine %q: %w", d.Name, err)
+				}
+				payload.Profiles[name] = raw
+			}
+		}
+		payload.Machines = append(payload.Machines, m)
+	}
+
+	// Секреты профилей — открытыми: у получателя свой ключ (или его нет), а
+	// сам пакет зашифрован паролем.
+	if err := payload.mapStates(corestate.OpenSecretsRaw); err != nil {
+		return nil, fmt.Errorf("remote bundle: %w", err)
+	}
+	plain, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("remote bundle: %w", err)
+	}
+	env, err := sealBundle(plain, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	debuglog.InfoLog("remote bundle: exported %d machines, %d base profiles",
+		len(payload.Machines), len(payload.Profiles))
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// ImportBundle раскладывает пакет в реестр.
+//
+// Ошибка возвращается, только если пакет не открылся целиком (не тот
+// формат, не тот пароль). Сбой на одной машине — строка отчёта: остальные
+// импортируются.
+func (r *RemoteRegistry) ImportBundle(raw []byte, passphrase string, onConflict BundleConflict) (BundleImportReport, error) {
+	var report BundleImportReport
+	payload, err := openBundle(raw, passphrase)
+	if err != nil {
+		return report, err
+	}
+	if err := payload.mapStates(corestate.SealSecretsRaw); err != nil {
+		return report, fmt.Errorf("remote bundle: %w", err)
+	}
+
+	// Профили — до машин: наследник без своей базы при Configure упал бы в
+	// ErrBaseProfileNotFound.
+	for name, body := range payload.Profiles {
+		if ValidateBaseProfileName(name) != nil {
+			continue
+		}
+		local, err := r.GetBaseProfile(name)
+		switch {
+		case errors.Is(err, ErrBaseProfileNotFound):
+			if err := r.PutBaseProfile(name, body); err != nil {
+				return report, fmt.Errorf("remote bundle: profile %q: %w", name, err)
+			}
+			report.ProfilesAdded = append(report.ProfilesAdded, name)
+		case err != nil:
+			return report, err
+		case !bytes.Equal(bytes.TrimSpace(local), bytes.TrimSpace(body)):
+			report.ProfilesKept = append(report.ProfilesKept, name)
+		}
+	}
+
+	for _, m := range payload.Machines {
+		report.Machines = append(report.Machines, r.importBundleMachine(m, onConflict))
+	}
+	debuglog.InfoLog("remote bundle: imported %d machines (profiles added %v, kept %v)",
+		len(report.Machines), report.ProfilesAdded, report.ProfilesKept)
+	return report, nil
+}
+
+func (r *RemoteRegistry) importBundleMachine(m bundleMachine, onConflict BundleConflict) BundleImportResult {
+	e := m.Entry
+	res := BundleImportResult{Name: e.Name, Addr: e.Addr}
+	list, err := r.List()
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	for _, d := range list {
+		if strings.EqualFold(d.Addr, strings.TrimSpace(e.Addr)) ||
+			(e.ServerFingerprint != "" && strings.EqualFold(d.ServerFingerprint, e.ServerFingerprint)) {
+			res.ConflictID = d.ID
+			break
+		}
+	}
+	if res.ConflictID != "" {
+		if onConflict != BundleConflictReplace {
+			res.Skipped = true
+			return res
+		}
+		if err := r.Remove(res.ConflictID); err != nil {
+			res.Err = err.Error()
+			return res
+		}
+		res.Replaced = true
+	}
+
+	// ImportPairedDaemon копирует пару из папки — отдаём её через временную.
+	srcDir := ""
+	if m.ClientCert != nil && m.ClientKey != nil {
+		tmp, err := os.MkdirTemp("", "remote-bundle-*")
+		if err != nil {
+			res.Err = err.Error()
+			return res
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		if err := os.WriteFile(filepath.Join(tmp, "client_cert.pem"), m.ClientCert, 0o600); err != nil {
+			res.Err = err.Error()
+			return res
+		}
+		if err := os.WriteFile(filepath.Join(tmp, "client_key.pem"), m.ClientKey, 0o600); err != nil {
+			res.Err = err.Error()
+			return res
+		}
+		srcDir = tmp
+	}
+	entry, err := r.ImportPairedDaemon(e.Name, e.Addr, e.ServerFingerprint, e.Secret, srcDir)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	res.ID = entry.ID
+	if err := r.adoptBundleFields(entry.ID, e); err != nil {
+		res.Err = err.Error()
+		return res
+	}
+
+	if m.State != nil {
+		path := platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, entry.ID)
+		if err := writeFileReplacing(path, m.State); err != nil {
+			res.Err = fmt.Sprintf("write state: %v", err)
+			return res
+		}
+	}
+	if m.BaseSnapshot != nil {
+		if err := writeFileReplacing(platform.GetBaseProfileSnapshotPathFor(r.execDir, entry.ID), m.BaseSnapshot); err != nil {
+			res.Err = fmt.Sprintf("write base snapshot: %v", err)
+			return res
+		}
+	}
+	// Путь к SSH-ключу — путь на компьютере отправителя.
+	if rt := e.ConnectRoute; rt != nil && rt.Kind == RouteSSH && rt.SSHKeyFile != "" {
+		if _, err := os.Stat(rt.SSHKeyFile); err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("ssh key %s not found on this computer — fix the route", rt.SSHKeyFile))
+		}
+	}
+	if m.State != nil {
+		res.Warnings = append(res.Warnings, "open Configure before the first deploy — the built config is not carried over")
+	}
+	return res
+}
+
+// adoptBundleFields переносит поля записи, которых ImportPairedDaemon не
+// знает: платформу, маршрут, базу, политику деплоя, имя сертификата.
+func (r *RemoteRegistry) adoptBundleFields(id string, src RemoteDaemon) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		list[i].GOOS = src.GOOS
+		list[i].GOARCH = src.GOARCH
+		list[i].StateDir = src.StateDir
+		list[i].BaseProfile = src.BaseProfile
+		list[i].DeployedSHA = src.DeployedSHA
+		list[i].DeployedAt = src.DeployedAt
+		list[i].DeployPolicy = src.DeployPolicy
+		list[i].ConnectRoute = src.ConnectRoute
+		list[i].ClientName = src.ClientName
+		return r.saveLocked(list)
+	}
+	return fmt.Errorf("remote registry: unknown id %q", id)
+}
+
+// sealBundle шифрует payload паролем.
+func sealBundle(plain []byte, passphrase string) (remoteBundleEnvelope, error) {
+	env := remoteBundleEnvelope{
+		Format: remoteBundleFormat, Version: remoteBundleVersion,
+		KDF: "scrypt", N: bundleScryptN, R: bundleScryptR, P: bundleScryptP,
+		Salt: make([]byte, 16),
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return env, fmt.Errorf("remote bundle: %w", err)
+	}
+	aead, err := bundleAEAD(passphrase, env)
+	if err != nil {
+		return env, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return env, fmt.Errorf("remote bundle: %w", err)
+	}
+	// Заголовок — associated data: подменить параметры KDF незаметно нельзя.
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plain, bundleAD(env))
+	return env, nil
+}
+
+// openBundle проверяет формат и расшифровывает payload.
+func openBundle(raw []byte, passphrase string) (remoteBundlePayload, error) {
+	var payload remoteBundlePayload
+	var env remoteBundleEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.Format != remoteBundleFormat {
+		return payload, fmt.Errorf("remote bundle: not a machine bundle")
+	}
+	if env.Version != remoteBundleVersion {
+		return payload, fmt.Errorf("remote bundle: version %d is not supported by this launcher", env.Version)
+	}
+	// Параметры KDF читаются из файла — ограничиваем, чтобы чужой файл не
+	// заставил считать scrypt на гигабайты.
+	if env.KDF != "scrypt" || env.N < 2 || env.N > 1<<20 || env.R < 1 || env.R > 32 || env.P < 1 || env.P > 16 {
+		return payload, fmt.Errorf("remote bundle: unsupported key derivation parameters")
+	}
+	aead, err := bundleAEAD(passphrase, env)
+	if err != nil {
+		return payload, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return payload, ErrBundlePassphrase
+	}
+	plain, err := aead.Open(nil, env.Nonce, env.Ciphertext, bundleAD(env))
+	if err != nil {
+		return payload, ErrBundlePassphrase
+	}
+	if err := json.Unmarshal(plain, &payload); err != nil {
+		return payload, fmt.Errorf("remote bundle: %w", err)
+	}
+	return payload, nil
+}
+
+func bundleAEAD(passphrase string, env remoteBundleEnvelope) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), env.Salt, env.N, env.R, env.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("remote bundle: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("remote bundle: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func bundleAD(env remoteBundleEnvelope) []byte {
+	return fmt.Appendf(nil, "%s/%d/%s/%d/%d/%d", env.Format, env.Version, env.KDF, env.N, env.R, env.P)
+}
+
+// readOptional — содержимое файла; nil, если его нет.
+func readOptional(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return raw, err
+}
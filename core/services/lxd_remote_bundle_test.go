@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/lxdclient"
+	"singbox-launcher/internal/platform"
+)
+
+// seedBundleMachine — сопряжённая машина с парой, маршрутом и состоянием.
+func seedBundleMachine(t *testing.T, r *RemoteRegistry, name, addr, fp string) RemoteDaemon {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "identity")
+	if _, err := lxdclient.LoadOrCreateIdentity(src); err != nil {
+		t.Fatal(err)
+	}
+	d, err := r.ImportPairedDaemon(name, addr, fp, "", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPlatform(d.ID, "linux", "arm64"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetRoute(d.ID, RemoteRoute{Kind: RouteSOCKS5, ProxyURL: "127.0.0.1:1080"}); err != nil {
+		t.Fatal(err)
+	}
+	state := platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, d.ID)
+	if err := writeFileReplacing(state, []byte(profileBase)); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRemoteBundleRoundTrip(t *testing.T) {
+	from := NewRemoteRegistry(t.TempDir())
+	router := seedBundleMachine(t, from, "Router", "10.0.0.1:9091", "aa11")
+	if err := from.PutBaseProfile("home", []byte(profileBase)); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.InheritBaseProfile(router.ID, "home"); err != nil {
+		t.Fatal(err)
+	}
+	seedBundleMachine(t, from, "VPS", "203.0.113.5:9091", "bb22")
+
+	if _, err := from.ExportBundle([]string{router.ID}, "short"); err == nil {
+		t.Fatal("short passphrase must be rejected")
+	}
+	bundle, err := from.ExportBundle([]string{router.ID}, "correct horse")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	to := NewRemoteRegistry(t.TempDir())
+	if _, err := to.ImportBundle(bundle, "wrong horse!", BundleConflictSkip); !errors.Is(err, ErrBundlePassphrase) {
+		t.Fatalf("wrong passphrase: err = %v", err)
+	}
+	report, err := to.ImportBundle(bundle, "correct horse", BundleConflictSkip)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(report.Machines) != 1 || report.Machines[0].Err != "" || len(report.ProfilesAdded) != 1 {
+		t.Fatalf("report %+v", report)
+	}
+	got, ok, _ := to.Get(report.Machines[0].ID)
+	if !ok || got.Addr != router.Addr || got.ServerFingerprint != "aa11" || got.GOARCH != "arm64" ||
+		got.BaseProfile != "home" || got.ConnectRoute == nil || got.ConnectRoute.Kind != RouteSOCKS5 {
+		t.Fatalf("imported entry %+v", got)
+	}
+	// Пара та же, что у отправителя, и лежит в папке новой записи.
+	a, _ := lxdclient.LoadOrCreateIdentity(from.identityDir(router.ID))
+	b, _ := lxdclient.LoadOrCreateIdentity(to.identityDir(got.ID))
+	if a.Fingerprint != b.Fingerprint {
+		t.Error("client identity must travel with the machine")
+	}
+	state, err := os.ReadFile(platform.GetWizardStatePathFor(to.execDir, constants.ConfigTargetRemote, got.ID))
+	if err != nil || len(state) == 0 {
+		t.Fatalf("wizard state must travel: %v", err)
+	}
+	if st, err := to.BaseProfileStatus(got.ID); err != nil || st.BaseChanged {
+		t.Fatalf("inheritance must survive the move: %+v, %v", st, err)
+	}
+}
+
+// Та же машина у получателя: skip оставляет свою запись, replace — берёт
+// из пакета.
+func TestRemoteBundleConflicts(t *testing.T) {
+	from := NewRemoteRegistry(t.TempDir())
+	router := seedBundleMachine(t, from, "Router", "10.0.0.1:9091", "aa11")
+	bundle, err := from.ExportBundle([]string{router.ID}, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to := NewRemoteRegistry(t.TempDir())
+	// Другой адрес, тот же пин — та же машина.
+	mine := seedBundleMachine(t, to, "My router", "192.168.1.1:9091", "AA11")
+
+	report, err := to.ImportBundle(bundle, "correct horse", BundleConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := report.Machines[0]; !res.Skipped || res.ConflictID != mine.ID {
+		t.Fatalf("skip: %+v", res)
+	}
+	if list, _ := to.List(); len(list) != 1 || list[0].Name != "My router" {
+		t.Fatalf("skip must keep the local entry: %+v", list)
+	}
+
+	report, err = to.ImportBundle(bundle, "correct horse", BundleConflictReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := report.Machines[0]; !res.Replaced || res.ID == "" {
+		t.Fatalf("replace: %+v", res)
+	}
+	if list, _ := to.List(); len(list) != 1 || list[0].Name != "Router" || list[0].Addr != "10.0.0.1:9091" {
+		t.Fatalf("replace must swap the entry: %+v", list)
+	}
+
+	if _, err := ParseBundleConflict("merge"); err == nil {
+		t.Error("unknown policy must be rejected")
+	}
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/lxdclient"
+)
+
+// Сертификаты сопряжения: ротация клиентской пары, срок, перепиновка
+// сервера и отзыв лаунчера на всех машинах.
+//
+// Клиентская пара создаётся при сопряжении и живёт десять лет — ровно
+// столько же, сколько живёт украденный вместе с ноутбуком ключ. Ротация
+// меняет её без потери записи, отзыв убирает чужой (или свой) сертификат из
+// списка доверенных на каждой машине парка.
+//
+// Доверенных клиентов демон держит сам. Править их по сети умеет только
+// демон с /admin/clients (lxdclient/clients.go); со старым ротация идёт по
+// приглашению, а отзыв остаётся за CLI на самой машине — эту команду мы
+// отдаём пользователю готовой, а не делаем вид, что отозвали.
+
+// CertExpiryWarning — за сколько до конца срока сертификат помечается
+// истекающим: хватает на то, чтобы успеть до каждой машины парка.
+const CertExpiryWarning = 30 * 24 * time.Hour
+
+// legacyClientName — имя, под которым сопрягались до ротации. У таких
+// записей ClientName пуст, и CLI-подсказки используют его.
+const legacyClientName = "singbox-launcher"
+
+// CertStatus — сертификаты одной машины.
+type CertStatus struct {
+	// ClientName — под каким именем наш сертификат записан у демона.
+	ClientName        string
+	ClientFingerprint string
+	ClientNotAfter    time.Time
+	// PinnedServer — пин из реестра; PresentedServer — что сервер
+	// предъявил сейчас (пусто, если не достучались: см. ServerErr).
+	PinnedServer    string
+	PresentedServer string
+	ServerNotAfter  time.Time
+	ServerErr       string
+	// Clients — доверенные сертификаты демона; nil вместе с
+	// ClientsUnsupported — демон списка не отдаёт.
+	Clients            []lxdclient.EnrolledClient
+	ClientsUnsupported bool
+}
+
+// ServerMismatch — сервер показывает не тот сертификат, что запинен.
+func (s CertStatus) ServerMismatch() bool {
+	return s.PresentedServer != "" && s.PresentedServer != s.PinnedServer
+}
+
+// ClientExpiresSoon — клиентскому сертификату осталось меньше
+// CertExpiryWarning (или он уже истёк).
+func (s CertStatus) ClientExpiresSoon(now time.Time) bool {
+	return certExpiresSoon(s.ClientNotAfter, now)
+}
+
+// ServerExpiresSoon — то же для сертификата демона.
+func (s CertStatus) ServerExpiresSoon(now time.Time) bool {
+	return certExpiresSoon(s.ServerNotAfter, now)
+}
+
+func certExpiresSoon(notAfter, now time.Time) bool {
+	return !notAfter.IsZero() && notAfter.Sub(now) < CertExpiryWarning
+}
+
+// EnrolledName — имя нашего сертификата у демона машины.
+func (d RemoteDaemon) EnrolledName() string {
+	if d.ClientName != "" {
+		return d.ClientName
+	}
+	return legacyClientName
+}
+
+// ClientCertExpiry — срок клиентского сертификата машины без сети; нулевое
+// время — пары на диске нет (plain-h2c или запись ещё не сопряжена).
+func (r *RemoteRegistry) ClientCertExpiry(id string) time.Time {
+	dir := r.identityDir(id)
+	if !lxdclient.HasIdentity(dir) {
+		return time.Time{}
+	}
+	identity, err := lxdclient.LoadOrCreateIdentity(dir)
+	if err != nil {
+		return time.Time{}
+	}
+	return identity.NotAfter
+}
+
+// CertStatus собирает сертификаты машины: наш — с диска, серверный — из
+// рукопожатия, список доверенных — у демона. Недоступная машина — не
+// ошибка: заполнено то, что известно локально.
+//
+// Блокирующий сетевой вызов — звать из горутины.
+func (r *RemoteRegistry) CertStatus(ctx context.Context, id string) (CertStatus, error) {
+	cfg, err := r.clientConfig(id)
+	if err != nil {
+		return CertStatus{}, err
+	}
+	entry, _, _ := r.Get(id)
+	out := CertStatus{ClientName: entry.EnrolledName(), PinnedServer: cfg.ServerFingerprint}
+	if !cfg.TLSEnabled() {
+		return out, nil
+	}
+	out.ClientFingerprint = cfg.Identity.Fingerprint
+	out.ClientNotAfter = cfg.Identity.NotAfter
+
+	peekCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	out.PresentedServer, out.ServerNotAfter, err = lxdclient.PeekServerCert(peekCtx, cfg)
+	if err != nil {
+		out.ServerErr = err.Error()
+		return out, nil
+	}
+	if out.ServerMismatch() {
+		return out, nil
+	}
+	out.Clients, err = lxdclient.New(cfg).Clients()
+	switch {
+	case errors.Is(err, lxdclient.ErrClientsUnsupported):
+		out.ClientsUnsupported = true
+	case err != nil:
+		out.ServerErr = err.Error()
+	}
+	return out, nil
+}
+
+// RotateResult — итог ротации клиентской пары.
+type RotateResult struct {
+	Machine        RemoteDaemon
+	OldFingerprint string
+	NewFingerprint string
+	// ViaInvite — новый сертификат заведён по приглашению: демон не умеет
+	// добавлять клиентов по каналу.
+	ViaInvite bool
+	// RetireHint — старый сертификат демон не отозвал сам; команда для
+	// самой машины. Пусто — отозван.
+	RetireHint string
+}
+
+// RotateClientCert меняет клиентскую пару машины: новый ключ → демон
+// доверяет ему → проверка каналом с новым ключом → запись на диск → отзыв
+// старого.
+//
+// Порядок выбран так, чтобы на любом обрыве оставалась рабочая пара: пока
+// новый сертификат не прошёл проверку, на диске лежит старый, и запись
+// работает как раньше.
+//
+// inviteRaw нужен только старому демону без /admin/clients; его пин обязан
+// совпасть с запиненным — ротация клиента не повод молча сменить и сервер
+// (для этого RePin).
+//
+// Блокирующий сетевой вызов — звать из горутины.
+func (r *RemoteRegistry) RotateClientCert(id, inviteRaw string) (RotateResult, error) {
+	entry, ok, err := r.Get(id)
+	if err != nil {
+		return RotateResult{}, err
+	}
+	if !ok {
+		return RotateResult{}, fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	cfg, err := r.clientConfig(id)
+	if err != nil {
+		return RotateResult{}, err
+	}
+	if !cfg.TLSEnabled() {
+		return RotateResult{}, fmt.Errorf("remote rotate: %q uses plain h2c, there is no client certificate to rotate", id)
+	}
+	old := cfg.Identity
+	fresh, err := lxdclient.NewIdentity()
+	if err != nil {
+		return RotateResult{}, fmt.Errorf("remote rotate: new identity: %w", err)
+	}
+	name := launcherClientName(fresh.Fingerprint)
+	res := RotateResult{OldFingerprint: old.Fingerprint, NewFingerprint: fresh.Fingerprint}
+
+	err = lxdclient.New(cfg).AddClient(name, fresh.CertPEM)
+	if errors.Is(err, lxdclient.ErrClientsUnsupported) {
+		if strings.TrimSpace(inviteRaw) == "" {
+			return RotateResult{}, fmt.Errorf("remote rotate: %w; mint an invite on the machine (`sing-box lxd client add`) and pass it", err)
+		}
+		invite, perr := lxdclient.ParseInvite(inviteRaw)
+		if perr != nil {
+			return RotateResult{}, perr
+		}
+		if !strings.EqualFold(invite.ServerFingerprint, entry.ServerFingerprint) {
+			return RotateResult{}, fmt.Errorf("remote rotate: invite pins server %s…, the machine is pinned to %s…; re-pin or re-pair instead",
+				shortSHA(invite.ServerFingerprint), shortSHA(entry.ServerFingerprint))
+		}
+		enrollCfg := cfg
+		enrollCfg.Identity = fresh
+		err = lxdclient.New(enrollCfg).Enroll(invite.Code, name)
+		res.ViaInvite = true
+	}
+	if err != nil {
+		return RotateResult{}, fmt.Errorf("remote rotate: trust new certificate: %w", err)
+	}
+
+	freshCfg := cfg
+	freshCfg.Identity = fresh
+	freshClient := lxdclient.New(freshCfg)
+	if _, err := freshClient.Status(); err != nil {
+		// Старая пара на диске цела; новую, если демон её всё-таки
+		// записал, убираем старым ключом, чтобы не копить мусор.
+		_ = lxdclient.New(cfg).RemoveClient(fresh.Fingerprint)
+		return RotateResult{}, fmt.Errorf("remote rotate: new certificate rejected: %w", err)
+	}
+	if err := lxdclient.SaveIdentity(r.identityDir(id), fresh); err != nil {
+		return RotateResult{}, fmt.Errorf("remote rotate: %w", err)
+	}
+	oldName := entry.EnrolledName()
+	if res.Machine, err = r.setClientName(id, name); err != nil {
+		return RotateResult{}, err
+	}
+
+	// По приглашению мы здесь именно потому, что списка нет: 404 на DELETE
+	// у такого демона — «нет маршрута», а не «уже отозван».
+	err = lxdclient.ErrClientsUnsupported
+	if !res.ViaInvite {
+		err = freshClient.RemoveClient(old.Fingerprint)
+	}
+	if err != nil {
+		res.RetireHint = clientRemoveHint(oldName)
+		debuglog.WarnLog("remote rotate: %q: old certificate %s… not retired: %v", id, shortSHA(old.Fingerprint), err)
+	}
+	debuglog.InfoLog("remote rotate: %q now uses %s… (was %s…)", id, shortSHA(fresh.Fingerprint), shortSHA(old.Fingerprint))
+	return res, nil
+}
+
+// RePin меняет пин сервера машины. fingerprint — то, что пользователь
+// сверил и подтвердил; применяется, только если сервер предъявляет
+// именно его прямо сейчас. Пин на непроверенный отпечаток — это доверие
+// тому, кого никто не видел.
+//
+// Клиентская пара не трогается: демон, которому просто перевыпустили
+// серверный сертификат, нас помнит. Переустановленный — нет, и после
+// перепиновки канал упрётся в mTLS: это уже RePair.
+//
+// Блокирующий сетевой вызов — звать из горутины.
+func (r *RemoteRegistry) RePin(ctx context.Context, id, fingerprint string) error {
+	want, err := normalizeFingerprint(fingerprint)
+	if err != nil {
+		return err
+	}
+	cfg, err := r.clientConfig(id)
+	if err != nil {
+		return err
+	}
+	peekCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	got, _, err := lxdclient.PeekServerCert(peekCtx, cfg)
+	if err != nil {
+		return fmt.Errorf("remote repin: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("remote repin: server presents %s…, not %s…", shortSHA(got), shortSHA(want))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		prev := list[i].ServerFingerprint
+		list[i].ServerFingerprint = want
+		if err := r.saveLocked(list); err != nil {
+			return err
+		}
+		debuglog.InfoLog("remote repin: %q server pin %s… → %s…", id, shortSHA(prev), shortSHA(want))
+		return nil
+	}
+	return fmt.Errorf("remote registry: unknown id %q", id)
+}
+
+// RevokeResult — отзыв на одной машине.
+type RevokeResult struct {
+	ID   string
+	Name string
+	// Removed — имена отозванных сертификатов.
+	Removed []string
+	// Hint — команда для самой машины: демон отзывать по сети не умеет.
+	Hint string
+	Err  string
+}
+
+// RevokeLauncher отзывает лаунчер на всех машинах реестра.
+//
+// device пусто — ЭТОТ лаунчер: с каждой машины уходит наш сертификат, а
+// локальная пара стирается (вернуть машину — RePair). Иначе — другой
+// лаунчер, например с потерянного ноутбука: отзываются сертификаты с
+// именем device или device-<отпечаток>, то есть все его ротации. Свой
+// сертификат при этом не трогается, даже если имя совпало.
+//
+// Машины обходятся по очереди, и недоступная не останавливает остальные:
+// ноутбук потерян сейчас, и отозвать нужно везде, где получится.
+//
+// Блокирующие сетевые вызовы — звать из горутины.
+func (r *RemoteRegistry) RevokeLauncher(device string) ([]RevokeResult, error) {
+	device = strings.TrimSpace(device)
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]RevokeResult, 0, len(list))
+	for _, d := range list {
+		res := RevokeResult{ID: d.ID, Name: d.Name}
+		if err := r.revokeOn(d, device, &res); err != nil {
+			res.Err = err.Error()
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+func (r *RemoteRegistry) revokeOn(d RemoteDaemon, device string, res *RevokeResult) error {
+	cfg, err := r.clientConfig(d.ID)
+	if err != nil {
+		return err
+	}
+	if !cfg.TLSEnabled() {
+		return fmt.Errorf("plain h2c: the daemon has no client certificates")
+	}
+	own := cfg.Identity.Fingerprint
+	client := lxdclient.New(cfg)
+	clients, err := client.Clients()
+	if errors.Is(err, lxdclient.ErrClientsUnsupported) {
+		name := device
+		if name == "" {
+			name = d.EnrolledName()
+		}
+		res.Hint = clientRemoveHint(name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var targets []lxdclient.EnrolledClient
+	for _, c := range clients {
+		switch {
+		case device == "" && c.Fingerprint == own:
+			targets = append(targets, c)
+		case device != "" && c.Fingerprint != own &&
+			(c.Name == device || strings.HasPrefix(c.Name, device+"-")):
+			targets = append(targets, c)
+		}
+	}
+	for _, c := range targets {
+		if err := client.RemoveClient(c.Fingerprint); err != nil {
+			return fmt.Errorf("remove %s: %w", c.Name, err)
+		}
+		res.Removed = append(res.Removed, c.Name)
+	}
+	if device == "" {
+		if err := lxdclient.RemoveIdentity(r.identityDir(d.ID)); err != nil {
+			return fmt.Errorf("drop local identity: %w", err)
+		}
+	}
+	debuglog.InfoLog("remote revoke: %q: removed %v", d.ID, res.Removed)
+	return nil
+}
+
+// setClientName записывает имя нашего сертификата у демона.
+func (r *RemoteRegistry) setClientName(id, name string) (RemoteDaemon, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return RemoteDaemon{}, err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		list[i].ClientName = name
+		return list[i], r.saveLocked(list)
+	}
+	return RemoteDaemon{}, fmt.Errorf("remote registry: unknown id %q", id)
+}
+
+// launcherHostRe — что остаётся от имени хоста в имени сертификата.
+var launcherHostRe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// LauncherDeviceName — имя ЭТОГО лаунчера в списках клиентов демонов:
+// singbox-launcher-<хост>. По нему потерянное устройство отзывают с другого.
+func LauncherDeviceName() string {
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(strings.ToLower(host), ".")
+	host = strings.Trim(launcherHostRe.ReplaceAllString(host, "-"), "-")
+	if host == "" {
+		return legacyClientName
+	}
+	return legacyClientName + "-" + host
+}
+
+// launcherClientName — имя конкретного сертификата: устройство + начало
+// отпечатка. Уникально на каждую ротацию, поэтому CLI-отзыв старого по
+// имени не заденет новый.
+func launcherClientName(fingerprint string) string {
+	return LauncherDeviceName() + "-" + fingerprint[:8]
+}
+
+func clientRemoveHint(name string) string {
+	return "sudo sing-box lxd client remove " + name
+}
+
+// normalizeFingerprint — 64 hex-символа; двоеточия и регистр, как их
+// копируют из openssl, допускаются.
+func normalizeFingerprint(s string) (string, error) {
+	fp := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), ":", ""))
+	if _, err := hex.DecodeString(fp); err != nil || len(fp) != 64 {
+		return "", fmt.Errorf("fingerprint must be 64 hex characters (SHA-256)")
+	}
+	return fp, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"singbox-launcher/internal/lxdclient"
+)
+
+// certDaemon — демон с mTLS: пускает только сертификаты из trusted.
+// withClients=false — старый демон без /admin/clients (только enroll).
+type certDaemon struct {
+	mu          sync.Mutex
+	trusted     map[string]string // отпечаток → имя
+	code        string
+	withClients bool
+	srv         *httptest.Server
+}
+
+func startCertDaemon(t *testing.T, withClients bool) *certDaemon {
+	t.Helper()
+	d := &certDaemon{trusted: map[string]string{}, withClients: withClients, code: "one-time"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/enroll", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Code string `json:"code"`
+			Name string `json:"name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if req.Code != d.code {
+			http.Error(w, `{"error":"bad code"}`, http.StatusForbidden)
+			return
+		}
+		d.code = ""
+		d.trusted[peerFingerprint(r)] = req.Name
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/admin/status", d.guard(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"started"}`))
+	}))
+	if withClients {
+		mux.HandleFunc("/admin/clients", d.guard(func(w http.ResponseWriter, r *http.Request) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if r.Method == http.MethodPost {
+				var req struct {
+					Name    string `json:"name"`
+					CertPEM string `json:"cert_pem"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				block, _ := pem.Decode([]byte(req.CertPEM))
+				if block == nil {
+					http.Error(w, `{"error":"bad cert"}`, http.StatusBadRequest)
+					return
+				}
+				d.trusted[lxdclient.FingerprintOf(block.Bytes)] = req.Name
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			var out []map[string]string
+			for fp, name := range d.trusted {
+				out = append(out, map[string]string{"name": name, "fingerprint": fp})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"clients": out})
+		}))
+		mux.HandleFunc("/admin/clients/", d.guard(func(w http.ResponseWriter, r *http.Request) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			delete(d.trusted, strings.TrimPrefix(r.URL.Path, "/admin/clients/"))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	d.srv = httptest.NewUnstartedServer(mux)
+	d.srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	d.srv.StartTLS()
+	t.Cleanup(d.srv.Close)
+	return d
+}
+
+func (d *certDaemon) guard(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		_, ok := d.trusted[peerFingerprint(r)]
+		d.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"error":"untrusted client"}`, http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (d *certDaemon) names() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []string
+	for _, n := range d.trusted {
+		out = append(out, n)
+	}
+	return out
+}
+
+func (d *certDaemon) fingerprint() string { return lxdclient.FingerprintOf(d.srv.Certificate().Raw) }
+
+func (d *certDaemon) invite() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.code = "fresh-code"
+	return d.srv.Listener.Addr().String() + "#" + d.fingerprint() + "#" + d.code
+}
+
+func peerFingerprint(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return lxdclient.FingerprintOf(r.TLS.PeerCertificates[0].Raw)
+}
+
+// pairCertDaemon сопрягает реестр с d по приглашению — настоящим enroll.
+func pairCertDaemon(t *testing.T, r *RemoteRegistry, d *certDaemon) RemoteDaemon {
+	t.Helper()
+	d.mu.Lock()
+	d.code = "one-time"
+	d.mu.Unlock()
+	entry, err := r.Pair(d.srv.Listener.Addr().String()+"#"+d.fingerprint()+"#one-time", "box", "")
+	if err != nil {
+		t.Fatalf("pair: %v", err)
+	}
+	return entry
+}
+
+func TestRotateClientCertOverClientsAPI(t *testing.T) {
+	d := startCertDaemon(t, true)
+	r := NewRemoteRegistry(t.TempDir())
+	entry := pairCertDaemon(t, r, d)
+	if !strings.HasPrefix(entry.ClientName, LauncherDeviceName()+"-") {
+		t.Fatalf("enrolled as %q", entry.ClientName)
+	}
+
+	res, err := r.RotateClientCert(entry.ID, "")
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if res.ViaInvite || res.RetireHint != "" || res.NewFingerprint == res.OldFingerprint {
+		t.Fatalf("rotate result %+v", res)
+	}
+	// Старый сертификат отозван, новый работает и лежит на диске.
+	if names := d.names(); len(names) != 1 || names[0] != res.Machine.ClientName {
+		t.Fatalf("trusted after rotation = %v, want only %s", names, res.Machine.ClientName)
+	}
+	if h := r.Health(entry.ID); !h.Reachable {
+		t.Fatalf("health after rotation: %+v", h)
+	}
+	st, err := r.CertStatus(context.Background(), entry.ID)
+	if err != nil || st.ClientFingerprint != res.NewFingerprint || st.ServerMismatch() || len(st.Clients) != 1 {
+		t.Fatalf("cert status %+v, %v", st, err)
+	}
+	if !st.ClientExpiresSoon(st.ClientNotAfter.Add(-CertExpiryWarning / 2)) {
+		t.Fatal("expiry warning must fire inside the window")
+	}
+}
+
+// Старый демон: ротация только по приглашению, и старый сертификат
+// убирает CLI — подсказка, а не тихое «готово».
+func TestRotateClientCertViaInvite(t *testing.T) {
+	d := startCertDaemon(t, false)
+	r := NewRemoteRegistry(t.TempDir())
+	entry := pairCertDaemon(t, r, d)
+
+	if _, err := r.RotateClientCert(entry.ID, ""); !errors.Is(err, lxdclient.ErrClientsUnsupported) {
+		t.Fatalf("rotate without invite: %v", err)
+	}
+	res, err := r.RotateClientCert(entry.ID, d.invite())
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if !res.ViaInvite || res.RetireHint != "sudo sing-box lxd client remove "+entry.ClientName {
+		t.Fatalf("rotate result %+v", res)
+	}
+	if h := r.Health(entry.ID); !h.Reachable {
+		t.Fatalf("health after rotation: %+v", h)
+	}
+}
+
+func TestRePinRequiresPresentedFingerprint(t *testing.T) {
+	d := startCertDaemon(t, true)
+	r := NewRemoteRegistry(t.TempDir())
+	entry := pairCertDaemon(t, r, d)
+
+	// Имитируем перевыпуск серверного сертификата: пин разошёлся.
+	stale := strings.Repeat("ab", 32)
+	r.mu.Lock()
+	list, _ := r.listLocked()
+	list[0].ServerFingerprint = stale
+	_ = r.saveLocked(list)
+	r.mu.Unlock()
+
+	st, _ := r.CertStatus(context.Background(), entry.ID)
+	if !st.ServerMismatch() || st.PresentedServer != d.fingerprint() {
+		t.Fatalf("cert status %+v", st)
+	}
+	if err := r.RePin(context.Background(), entry.ID, strings.Repeat("cd", 32)); err == nil {
+		t.Fatal("re-pin to a fingerprint the server does not present must fail")
+	}
+	if err := r.RePin(context.Background(), entry.ID, strings.ToUpper(d.fingerprint())); err != nil {
+		t.Fatalf("re-pin: %v", err)
+	}
+	if h := r.Health(entry.ID); !h.Reachable {
+		t.Fatalf("health after re-pin: %+v", h)
+	}
+}
+
+func TestRevokeLauncher(t *testing.T) {
+	d := startCertDaemon(t, true)
+	old := startCertDaemon(t, false)
+	r := NewRemoteRegistry(t.TempDir())
+	entry := pairCertDaemon(t, r, d)
+	oldEntry := pairCertDaemon(t, r, old)
+
+	// Потерянный ноутбук: два его сертификата (до и после ротации).
+	d.mu.Lock()
+	d.trusted["f1"] = "singbox-launcher-lostbook-11111111"
+	d.trusted["f2"] = "singbox-launcher-lostbook-22222222"
+	d.trusted["f3"] = "singbox-launcher-lostbookpro-33333333"
+	d.mu.Unlock()
+
+	res, err := r.RevokeLauncher("singbox-launcher-lostbook")
+	if err != nil || len(res) != 2 {
+		t.Fatalf("revoke: %+v, %v", res, err)
+	}
+	byID := map[string]RevokeResult{}
+	for _, x := range res {
+		byID[x.ID] = x
+	}
+	if got := byID[entry.ID]; len(got.Removed) != 2 || got.Err != "" {
+		t.Fatalf("revoke on new daemon: %+v", got)
+	}
+	if got := byID[oldEntry.ID]; got.Hint != "sudo sing-box lxd client remove singbox-launcher-lostbook" {
+		t.Fatalf("revoke on old daemon: %+v", got)
+	}
+	if names := d.names(); len(names) != 2 {
+		t.Fatalf("trusted after revoke = %v (own and lostbookpro must stay)", names)
+	}
+
+	// Себя: сертификат уходит с машины, локальная пара — с диска.
+	if _, err := r.RevokeLauncher(""); err != nil {
+		t.Fatal(err)
+	}
+	if names := d.names(); len(names) != 1 || names[0] != "singbox-launcher-lostbookpro-33333333" {
+		t.Fatalf("trusted after self-revoke = %v", names)
+	}
+	if !r.ClientCertExpiry(entry.ID).IsZero() {
+		t.Fatal("local identity must be gone after self-revoke")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/lxdclient"
 	"singbox-launcher/internal/platform"
@@ -36,7 +37,8 @@ type DeployResult struct {
 
 // Deploy отправляет машине конфиг вместе с ресурсами, на которые он
 // ссылается. config == nil означает «её собственный собранный config.json»
-// (ErrBuiltConfigMissing, если его ещё нет).
+// (ErrBuiltConfigMissing, если его ещё нет; ErrProfileRebuildNeeded, если
+// базовый профиль машины менялся после его сборки).
 //
 // Порядок обязателен: сначала ресурсы, потом конфиг — конфиг ссылается на
 // `<state_dir>/resources/<name>`, и без файлов ядро на той стороне не
@@ -54,6 +56,9 @@ func (r *RemoteRegistry) Deploy(id string, config []byte) (DeployResult, error)
 			return DeployResult{}, fmt.Errorf("deploy: read %s: %w", path, err)
 		}
 		config = raw
+		if err := r.checkProfileBuilt(id); err != nil {
+			return DeployResult{}, err
+		}
 	}
 
 	resources, err := CollectDeployResources(r.execDir, id, config)
@@ -72,10 +77,34 @@ func (r *RemoteRegistry) Deploy(id string, config []byte) (DeployResult, error)
 		ResourcesUploaded: uploaded,
 		ConfigSHA:         hex.EncodeToString(sum[:]),
 	}
+	if err := r.recordDeployed(id, res.ConfigSHA); err != nil {
+		// Конфиг уже на машине; без записи сверка примет его за чужой.
+		debuglog.WarnLog("remote deploy: record deployed sha for %q: %v", id, err)
+	}
 	debuglog.InfoLog("remote deploy: %q done (%d resource(s), config %s…)", id, res.ResourcesUploaded, res.ConfigSHA[:12])
 	return res, nil
 }
 
+// DeployBy — Deploy с записью в журнал действий (core/audit): было — sha
+// конфига, отправленного машине прошлым деплоем, стало — нового.
+func (r *RemoteRegistry) DeployBy(actor audit.Actor, id string, config []byte) (DeployResult, error) {
+	target, before := id, ""
+	if d, ok, _ := r.Get(id); ok {
+		if d.Name != "" {
+			target = d.Name + " (" + id + ")"
+		}
+		before = shortSHA(d.DeployedSHA)
+	}
+	res, err := r.Deploy(id, config)
+	after := ""
+	if err == nil {
+		after = shortSHA(res.ConfigSHA)
+	}
+	audit.For(r.execDir).Append(audit.Entry{Actor: actor, Action: audit.ActionRemoteDeploy, Target: target,
+		Before: before, After: after}.WithErr(err))
+	return res, err
+}
+
 // syncResourcesCounted — тело SyncResources, возвращающее число залитых
 // файлов (нужно Deploy для ответа API). SyncResources остаётся публичной
 // обёрткой с прежней сигнатурой.
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/lxdclient"
+	"singbox-launcher/internal/platform"
+)
+
+// Сверка машины с тем, что на неё задеплоили отсюда.
+//
+// Deploy отвечает только за момент отправки, дальше машина живёт своей
+// жизнью: на неё применили другой конфиг (соседний лаунчер, curl по
+// admin-плоскости), демон откатился на last-good после падения ядра,
+// rule-set на той стороне заменили руками. Строка списка при этом
+// продолжала показывать «started», и расхождение всплывало, когда у людей за
+// роутером переставал открываться нужный сайт.
+//
+// Сверяются три хеша конфига — собранного config.json машины, последнего
+// задеплоенного отсюда (RemoteDaemon.DeployedSHA) и работающего на машине
+// (/admin/status → active_sha256) — и ресурсы, на которые ссылается
+// собранный конфиг. Фоновый прогон по расписанию и повторный деплой —
+// DriftReconciler (lxd_remote_reconciler.go).
+
+// DriftState — итог сверки машины.
+type DriftState string
+
+const (
+	// DriftInSync — на машине ровно собранный конфиг и его ресурсы.
+	DriftInSync DriftState = "in-sync"
+	// DriftPending — машина работает на том, что мы задеплоили, но конфиг
+	// с тех пор пересобран (Configure → Save) и ещё не отправлен.
+	DriftPending DriftState = "pending"
+	// DriftDiverged — на машине не то, что мы задеплоили: чужой конфиг,
+	// откат демона или подменённые ресурсы.
+	DriftDiverged DriftState = "drifted"
+	// DriftUnknown — сверить не удалось (машина не ответила).
+	DriftUnknown DriftState = "unknown"
+)
+
+// Статусы ресурса в ResourceDrift.
+const (
+	ResourceDriftMissing = "missing"
+	ResourceDriftChanged = "changed"
+)
+
+// ResourceDrift — ресурс собранного конфига, который на машине не совпал.
+type ResourceDrift struct {
+	Name string
+	// Status — ResourceDriftMissing (на машине нет) | ResourceDriftChanged
+	// (хеш другой).
+	Status string
+}
+
+// DriftReport — результат одной сверки.
+type DriftReport struct {
+	MachineID string
+	CheckedAt time.Time
+	State     DriftState
+	// Err — почему State == DriftUnknown.
+	Err string
+	// BuiltSHA / DeployedSHA / ActiveSHA — собранный, последний
+	// задеплоенный отсюда (пусто — отсюда не деплоили) и работающий конфиг;
+	// lowercase hex sha256.
+	BuiltSHA    string
+	DeployedSHA string
+	ActiveSHA   string
+	// RolledBack — расхождение объясняется откатом демона: работает
+	// last-good, и последнее применение закончилось ошибкой.
+	RolledBack bool
+	// Resources — несовпавшие ресурсы; сверяются, только когда на машине
+	// работает собранный конфиг (у другого конфига другие ресурсы).
+	Resources []ResourceDrift
+	// Redeployed / RedeployErr — что сделал DriftReconciler по политике
+	// машины. CheckDrift их не заполняет.
+	Redeployed  bool
+	RedeployErr string
+}
+
+// NeedsDeploy — машина работает не на собранном конфиге, и Deploy это
+// исправит.
+func (r DriftReport) NeedsDeploy() bool {
+	return r.State == DriftDiverged || r.State == DriftPending
+}
+
+// DeployPolicy — наблюдение за машиной после Deploy. Хранится в записи
+// реестра; нулевое значение — машину никто не трогает, ровно как до
+// появления сверки.
+type DeployPolicy struct {
+	// CheckEvery — период фоновой сверки (Go duration, не меньше
+	// DriftMinCheckEvery). Пусто — фоновой сверки нет: стучаться к чужой
+	// машине без явного согласия лаунчер не должен.
+	CheckEvery string `json:"check_every,omitempty"`
+	// AutoRedeploy — найденное сверкой расхождение или неотправленную сборку
+	// сразу деплоить. Вместе с CheckEvery это деплой по расписанию:
+	// пересобранный конфиг уезжает на ближайшей сверке.
+	AutoRedeploy bool `json:"auto_redeploy,omitempty"`
+	// RedeployOnRefresh — после обновления подписок машины сверить её и
+	// задеплоить, если она не на собранном конфиге.
+	RedeployOnRefresh bool `json:"redeploy_on_refresh,omitempty"`
+}
+
+// Пределы периода сверки. Снизу — чтобы сверка не превратилась в
+// постоянный опрос роутера; сверху — неделя уже не наблюдение.
+const (
+	DriftMinCheckEvery = time.Minute
+	DriftMaxCheckEvery = 7 * 24 * time.Hour
+)
+
+// IsZero — политика ничего не включает.
+func (p DeployPolicy) IsZero() bool {
+	return p == DeployPolicy{}
+}
+
+// Interval — период фоновой сверки; 0 — не наблюдается (или период не
+// разбирается — такой политику не сохранит SetDeployPolicy).
+func (p DeployPolicy) Interval() time.Duration {
+	if strings.TrimSpace(p.CheckEvery) == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(p.CheckEvery))
+	if err != nil || d < DriftMinCheckEvery {
+		return 0
+	}
+	return d
+}
+
+// ValidateDeployPolicy проверяет период сверки.
+func ValidateDeployPolicy(p DeployPolicy) error {
+	s := strings.TrimSpace(p.CheckEvery)
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid check interval %q: %v", s, err)
+	}
+	if d < DriftMinCheckEvery || d > DriftMaxCheckEvery {
+		return fmt.Errorf("invalid check interval %q: must be between %v and %v", s, DriftMinCheckEvery, DriftMaxCheckEvery)
+	}
+	return nil
+}
+
+// Policy — политика машины; нулевая, если не задана.
+func (d RemoteDaemon) Policy() DeployPolicy {
+	if d.DeployPolicy == nil {
+		return DeployPolicy{}
+	}
+	return *d.DeployPolicy
+}
+
+// SetDeployPolicy сохраняет политику машины; нулевая политика удаляет поле
+// из записи.
+func (r *RemoteRegistry) SetDeployPolicy(id string, p DeployPolicy) error {
+	p.CheckEvery = strings.TrimSpace(p.CheckEvery)
+	if err := ValidateDeployPolicy(p); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		if p.IsZero() {
+			list[i].DeployPolicy = nil
+		} else {
+			list[i].DeployPolicy = &p
+		}
+		return r.saveLocked(list)
+	}
+	return fmt.Errorf("remote registry: unknown id %q", id)
+}
+
+// recordDeployed запоминает, что ушло на машину последним Deploy: с этим
+// хешем CheckDrift сравнивает работающий конфиг.
+func (r *RemoteRegistry) recordDeployed(id, sha string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		list[i].DeployedSHA = sha
+		list[i].DeployedAt = time.Now().UTC().Format(time.RFC3339Nano)
+		return r.saveLocked(list)
+	}
+	return fmt.Errorf("remote registry: unknown id %q", id)
+}
+
+// CheckDrift сверяет машину с её собранным и последним задеплоенным
+// конфигом.
+//
+// Ошибка — только локальная: машины нет в реестре или конфиг ещё не
+// собирали (ErrBuiltConfigMissing). Недоступная машина — это отчёт с
+// DriftUnknown, а не ошибка: для фоновой сверки это рядовой исход.
+//
+// Блокирующие сетевые вызовы — звать из горутины.
+func (r *RemoteRegistry) CheckDrift(id string) (DriftReport, error) {
+	d, ok, err := r.Get(id)
+	if err != nil {
+		return DriftReport{}, err
+	}
+	if !ok {
+		return DriftReport{}, fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	path := platform.GetRemoteConfigPathFor(r.execDir, id)
+	built, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DriftReport{}, ErrBuiltConfigMissing
+		}
+		return DriftReport{}, fmt.Errorf("drift: read %s: %w", path, err)
+	}
+	rep := DriftReport{
+		MachineID:   id,
+		CheckedAt:   time.Now(),
+		State:       DriftUnknown,
+		BuiltSHA:    sha256Hex(built),
+		DeployedSHA: strings.ToLower(d.DeployedSHA),
+	}
+
+	client, err := r.adminClient(id)
+	if err != nil {
+		rep.Err = err.Error()
+		return rep, nil
+	}
+	status, err := client.Status()
+	if err != nil {
+		rep.Err = err.Error()
+		return rep, nil
+	}
+	rep.ActiveSHA = strings.ToLower(status.ActiveSHA)
+	if rep.ActiveSHA == "" {
+		// Демон без active_sha256 в статусе — хешируем сам работающий
+		// конфиг. Пустой ответ значит «ничего не применено».
+		if raw, cfgErr := client.ActiveConfig(); cfgErr == nil && len(raw) > 0 {
+			rep.ActiveSHA = sha256Hex(raw)
+		}
+	}
+
+	if rep.ActiveSHA != "" && rep.ActiveSHA == rep.BuiltSHA {
+		files, resErr := CollectDeployResources(r.execDir, id, built)
+		if resErr != nil {
+			rep.Err = resErr.Error()
+			return rep, nil
+		}
+		if len(files) > 0 {
+			remote, listErr := client.Resources()
+			if listErr != nil {
+				rep.Err = listErr.Error()
+				return rep, nil
+			}
+			rep.Resources = diffResources(files, remote)
+		}
+	}
+
+	switch {
+	case rep.DeployedSHA != "" && rep.ActiveSHA != rep.DeployedSHA:
+		rep.State = DriftDiverged
+		rep.RolledBack = rep.ActiveSHA != "" && status.LastError != "" &&
+			rep.ActiveSHA == strings.ToLower(status.LastGoodSHA)
+	case len(rep.Resources) > 0:
+		rep.State = DriftDiverged
+	case rep.ActiveSHA != rep.BuiltSHA:
+		// Сюда же попадает машина, на которую отсюда не деплоили: наша
+		// сборка её ещё не достигла.
+		rep.State = DriftPending
+	default:
+		rep.State = DriftInSync
+	}
+	if rep.State == DriftDiverged {
+		debuglog.InfoLog("remote drift: %q runs %s, deployed %s (rolled back: %v, resources: %d)",
+			id, shortSHA(rep.ActiveSHA), shortSHA(rep.DeployedSHA), rep.RolledBack, len(rep.Resources))
+	}
+	return rep, nil
+}
+
+// diffResources — ресурсы из files, которых на машине нет или у которых
+// другой хеш; по имени.
+func diffResources(files map[string][]byte, remote []lxdclient.Resource) []ResourceDrift {
+	have := make(map[string]string, len(remote))
+	for _, res := range remote {
+		have[res.Name] = strings.ToLower(res.SHA256)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []ResourceDrift
+	for _, name := range names {
+		sha, ok := have[name]
+		switch {
+		case !ok:
+			out = append(out, ResourceDrift{Name: name, Status: ResourceDriftMissing})
+		case sha != sha256Hex(files[name]):
+			out = append(out, ResourceDrift{Name: name, Status: ResourceDriftChanged})
+		}
+	}
+	return out
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// shortSHA — префикс хеша для логов; "-" вместо пустого.
+func shortSHA(sha string) string {
+	switch {
+	case sha == "":
+		return "-"
+	case len(sha) > 12:
+		return sha[:12] + "…"
+	default:
+		return sha
+	}
+}
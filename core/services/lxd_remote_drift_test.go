@@ -0,0 +1,139 @@
+package services
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"singbox-launcher/core/events"
+	"singbox-launcher/internal/platform"
+)
+
+// Сверка видит все три случая, ради которых заводилась: сборку, которую
+// ещё не отправили, чужой конфиг на машине и откат демона.
+func TestCheckDriftStates(t *testing.T) {
+	d := &fleetDaemon{}
+	r := seedFleet(t, []*fleetDaemon{d})
+
+	rep, err := r.CheckDrift("m0")
+	if err != nil {
+		t.Fatalf("CheckDrift: %v", err)
+	}
+	if rep.State != DriftPending || rep.DeployedSHA != "" {
+		t.Errorf("never deployed: %+v, want pending", rep)
+	}
+
+	res, err := r.Deploy("m0", nil)
+	if err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if rep, _ = r.CheckDrift("m0"); rep.State != DriftInSync || rep.DeployedSHA != res.ConfigSHA {
+		t.Errorf("after deploy: %+v, want in-sync on %s", rep, res.ConfigSHA)
+	}
+
+	d.mu.Lock()
+	d.active, d.lastGood = "0ther", "0ther"
+	d.mu.Unlock()
+	if rep, _ = r.CheckDrift("m0"); rep.State != DriftDiverged || rep.RolledBack {
+		t.Errorf("foreign config: %+v, want drifted without rollback", rep)
+	}
+	d.mu.Lock()
+	d.lastError = "core exited"
+	d.mu.Unlock()
+	if rep, _ = r.CheckDrift("m0"); rep.State != DriftDiverged || !rep.RolledBack {
+		t.Errorf("rolled back: %+v, want drifted with rollback", rep)
+	}
+
+	if _, err := r.CheckDrift("nope"); err == nil {
+		t.Error("CheckDrift of an unknown machine must fail")
+	}
+}
+
+func TestDriftReconcilerRedeploysAndHoldsRolledBackBuild(t *testing.T) {
+	d := &fleetDaemon{}
+	r := seedFleet(t, []*fleetDaemon{d})
+	bus := events.NewMemoryBus()
+	var published []events.RemoteDriftCheckedPayload
+	bus.Subscribe(events.RemoteDriftChecked, func(ev events.Event) {
+		published = append(published, ev.Payload.(events.RemoteDriftCheckedPayload))
+	})
+	c := NewDriftReconciler(r)
+	c.EventBus = bus
+
+	rep, err := c.Reconcile("m0", false)
+	if err != nil || rep.State != DriftPending || rep.Redeployed {
+		t.Fatalf("check only: rep=%+v err=%v", rep, err)
+	}
+	rep, err = c.Reconcile("m0", true)
+	if err != nil || rep.State != DriftInSync || !rep.Redeployed {
+		t.Fatalf("redeploy: rep=%+v err=%v", rep, err)
+	}
+	if len(published) != 2 || published[1].State != string(DriftInSync) || !published[1].Redeployed {
+		t.Errorf("events = %+v", published)
+	}
+
+	// Ядро упало на этой сборке, демон вернулся на last-good — ту же сборку
+	// повторно не шлём.
+	d.mu.Lock()
+	d.active, d.lastGood, d.lastError = "0ther", "0ther", "core exited"
+	d.mu.Unlock()
+	applies := countCalls(d, "POST /admin/apply")
+	rep, err = c.Reconcile("m0", true)
+	if err != nil || rep.Redeployed || !strings.Contains(rep.RedeployErr, "skipped") {
+		t.Fatalf("rolled-back build: rep=%+v err=%v", rep, err)
+	}
+	if got := countCalls(d, "POST /admin/apply"); got != applies {
+		t.Errorf("held build was applied again (%d → %d)", applies, got)
+	}
+
+	// Новая сборка снимает удержание.
+	cfg := platform.GetRemoteConfigPathFor(r.execDir, "m0")
+	if err := os.WriteFile(cfg, []byte(`{"log":{"level":"info"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rep, err = c.Reconcile("m0", true); err != nil || !rep.Redeployed || rep.State != DriftInSync {
+		t.Errorf("new build: rep=%+v err=%v", rep, err)
+	}
+	if got, ok := c.Report("m0"); !ok || got.BuiltSHA != rep.BuiltSHA {
+		t.Errorf("Report = %+v, %v", got, ok)
+	}
+}
+
+func TestDeployPolicy(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}})
+	for _, bad := range []string{"30s", "soon", "720h"} {
+		if err := r.SetDeployPolicy("m0", DeployPolicy{CheckEvery: bad}); err == nil {
+			t.Errorf("SetDeployPolicy accepted check_every=%q", bad)
+		}
+	}
+	if err := r.SetDeployPolicy("m0", DeployPolicy{CheckEvery: " 15m ", AutoRedeploy: true}); err != nil {
+		t.Fatal(err)
+	}
+	m, _, _ := r.Get("m0")
+	if pol := m.Policy(); pol.CheckEvery != "15m" || pol.Interval().Minutes() != 15 || !pol.AutoRedeploy {
+		t.Errorf("policy = %+v", pol)
+	}
+
+	// Хук обновления подписок без RedeployOnRefresh ничего не делает.
+	c := NewDriftReconciler(r)
+	if _, enabled, err := c.AfterRefresh("m0"); enabled || err != nil {
+		t.Errorf("AfterRefresh with the hook off: enabled=%v err=%v", enabled, err)
+	}
+
+	if err := r.SetDeployPolicy("m0", DeployPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, _ = r.Get("m0"); m.DeployPolicy != nil {
+		t.Errorf("zero policy must clear the field, got %+v", m.DeployPolicy)
+	}
+}
+
+func countCalls(d *fleetDaemon, call string) int {
+	n := 0
+	for _, c := range d.called() {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// Парк машин: одна и та же операция над многими записями реестра разом.
+//
+// Всё, что умеет реестр, адресует ОДНУ машину — и это правильно для строки
+// списка. Но у кого дюжина роутеров и VPS, тот после правки профиля жмёт
+// Deploy двенадцать раз подряд и следит глазами, на какой машине что
+// отвалилось. Здесь — тот же набор операций, прогоняемый по выбранным
+// машинам параллельно с ограничением, и матрица «машина × шаг» на выходе.
+//
+// Шаги — ровно те функции, что зовут кнопки строки и Debug API по одной
+// машине (Deploy, SyncResources, Stop+Start, RollbackCore): «из парка
+// деплоится не так, как кнопкой» невозможно по построению.
+
+// FleetOp — операция над машиной в прогоне по парку.
+type FleetOp string
+
+const (
+	// FleetOpRefresh — обновить тела подписок машины (её subscriptions/ и
+	// meta в state.json). Конфиг машины при этом НЕ пересобирается: сборка
+	// remote-конфига пока живёт только в визарде (Configure → Save).
+	FleetOpRefresh FleetOp = "refresh-subscriptions"
+	// FleetOpSyncResources — залить rule-set'ы, на которые ссылается
+	// собранный конфиг машины.
+	FleetOpSyncResources FleetOp = "sync-resources"
+	// FleetOpDeploy — ресурсы + собранный конфиг (services.Deploy).
+	FleetOpDeploy FleetOp = "deploy"
+	// FleetOpRestart — Stop, затем Start ядра.
+	FleetOpRestart FleetOp = "restart"
+	// FleetOpRollback — откат ядра на last-good конфиг.
+	FleetOpRollback FleetOp = "rollback"
+)
+
+// FleetOps — все операции в каноническом порядке: так их выстраивает UI,
+// когда отмечено несколько. Обновить подписки раньше деплоя, залить ресурсы
+// раньше конфига, перезапустить после.
+var FleetOps = []FleetOp{FleetOpRefresh, FleetOpSyncResources, FleetOpDeploy, FleetOpRestart, FleetOpRollback}
+
+// Пределы параллельности. Потолок — не про лаунчер, а про людей за этими
+// машинами: деплой на все роутеры сразу — это VPN, моргнувший у всех разом.
+const (
+	FleetDefaultConcurrency = 4
+	FleetMaxConcurrency     = 16
+)
+
+// Статусы шага в матрице результатов.
+const (
+	FleetStepOK      = "ok"
+	FleetStepFailed  = "failed"
+	FleetStepSkipped = "skipped"
+)
+
+// FleetRequest — что и где выполнить.
+type FleetRequest struct {
+	// IDs — машины прогона; пусто = все машины реестра.
+	IDs []string
+	// Ops — шаги, выполняемые на каждой машине по порядку. Первый упавший
+	// шаг обрывает цепочку ЭТОЙ машины: деплой после несостоявшейся заливки
+	// ресурсов поднял бы ядро без rule-set'ов.
+	Ops []FleetOp
+	// Concurrency — сколько машин обрабатывается одновременно
+	// (0 = FleetDefaultConcurrency).
+	Concurrency int
+	// StopOnFailure — после первой упавшей машины новые не начинаются
+	// (канареечная выкатка). Уже начатые доходят до конца: оборвать деплой
+	// посередине хуже, чем дать ему закончиться.
+	StopOnFailure bool
+}
+
+// FleetStep — одна клетка матрицы.
+type FleetStep struct {
+	Op       FleetOp
+	Status   string
+	Error    string
+	Detail   string
+	Duration time.Duration
+}
+
+// FleetMachineResult — строка матрицы: все шаги одной машины.
+type FleetMachineResult struct {
+	ID    string
+	Name  string
+	OK    bool
+	Steps []FleetStep
+}
+
+// Started — машина хоть что-то выполнила, а не пропущена целиком (отличает
+// «упала» от «не начиналась» в сводке прогона).
+func (m FleetMachineResult) Started() bool {
+	for _, st := range m.Steps {
+		if st.Status != FleetStepSkipped {
+			return true
+		}
+	}
+	return false
+}
+
+// FleetRefreshFunc обновляет подписки машины и возвращает краткую сводку.
+// Живёт в core (пайплайн подписок там), поэтому приходит снаружи.
+type FleetRefreshFunc func(id string) (string, error)
+
+// ErrFleetRefreshUnavailable — в прогоне есть refresh-subscriptions, а
+// обновлялку подписок вызывающий не передал.
+var ErrFleetRefreshUnavailable = errors.New("subscription refresh is not available in this context")
+
+// FleetValidationError — запрос прогона некорректен; Field называет поле
+// (machines / ops / concurrency), чтобы API мог ответить 422 с указанием на него.
+type FleetValidationError struct {
+	Field string
+	Msg   string
+}
+
+func (e *FleetValidationError) Error() string { return e.Msg }
+
+// ParseFleetOp разбирает имя операции.
+func ParseFleetOp(s string) (FleetOp, bool) {
+	op := FleetOp(strings.TrimSpace(strings.ToLower(s)))
+	for _, known := range FleetOps {
+		if op == known {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// RunFleet выполняет req по машинам парка.
+//
+// onResult (может быть nil) зовётся из рабочих горутин по мере готовности
+// каждой машины — UI рисует матрицу вживую, не дожидаясь самой медленной.
+// Возвращаемый срез — в порядке req.IDs (или реестра), а не завершения.
+//
+// Ошибка возвращается только за некорректный запрос или нечитаемый реестр;
+// сбои на машинах — данные, они в матрице.
+//
+// Деплои пишутся в журнал действий на актора из ctx (audit.WithActor;
+// без него — окно).
+//
+// Блокирующие сетевые вызовы — звать из горутины.
+func (r *RemoteRegistry) RunFleet(ctx context.Context, req FleetRequest, refresh FleetRefreshFunc, onResult func(FleetMachineResult)) ([]FleetMachineResult, error) {
+	actor := audit.ActorFrom(ctx, audit.ActorUI)
+	machines, err := r.fleetMachines(req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Ops) == 0 {
+		return nil, &FleetValidationError{Field: "ops", Msg: "no operations requested"}
+	}
+	seen := make(map[FleetOp]bool, len(req.Ops))
+	for _, op := range req.Ops {
+		if _, ok := ParseFleetOp(string(op)); !ok {
+			return nil, &FleetValidationError{Field: "ops", Msg: fmt.Sprintf("unknown operation %q", op)}
+		}
+		if seen[op] {
+			return nil, &FleetValidationError{Field: "ops", Msg: fmt.Sprintf("operation %q listed twice", op)}
+		}
+		seen[op] = true
+	}
+	if seen[FleetOpRefresh] && refresh == nil {
+		return nil, ErrFleetRefreshUnavailable
+	}
+	workers := req.Concurrency
+	if workers == 0 {
+		workers = FleetDefaultConcurrency
+	}
+	if workers < 1 || workers > FleetMaxConcurrency {
+		return nil, &FleetValidationError{Field: "concurrency",
+			Msg: fmt.Sprintf("concurrency must be between 1 and %d", FleetMaxConcurrency)}
+	}
+	if workers > len(machines) {
+		workers = len(machines)
+	}
+
+	results := make([]FleetMachineResult, len(machines))
+	var (
+		failedID atomic.Value // string: первая упавшая машина (StopOnFailure)
+		next     atomic.Int64
+		wg       sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(machines) {
+					return
+				}
+				d := machines[i]
+				var res FleetMachineResult
+				switch {
+				case ctx.Err() != nil:
+					res = skippedFleetResult(d, req.Ops, "cancelled")
+				case req.StopOnFailure && failedID.Load() != nil:
+					res = skippedFleetResult(d, req.Ops,
+						fmt.Sprintf("not started: %q failed and stop-on-failure is set", failedID.Load()))
+				default:
+					res = r.runFleetMachine(actor, d, req.Ops, refresh)
+					if !res.OK {
+						failedID.CompareAndSwap(nil, d.ID)
+					}
+				}
+				results[i] = res
+				if onResult != nil {
+					onResult(res)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, res := range results {
+		if res.OK {
+			ok++
+		}
+	}
+	debuglog.InfoLog("remote fleet: %v on %d machine(s): %d ok", req.Ops, len(results), ok)
+	return results, nil
+}
+
+// fleetMachines резолвит ID прогона в записи реестра (пусто = все).
+func (r *RemoteRegistry) fleetMachines(ids []string) ([]RemoteDaemon, error) {
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		if len(list) == 0 {
+			return nil, &FleetValidationError{Field: "machines", Msg: "no paired machines"}
+		}
+		return list, nil
+	}
+	byID := make(map[string]RemoteDaemon, len(list))
+	for _, d := range list {
+		byID[d.ID] = d
+	}
+	out := make([]RemoteDaemon, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, raw := range ids {
+		id := strings.TrimSpace(raw)
+		d, ok := byID[id]
+		if !ok {
+			return nil, &FleetValidationError{Field: "machines", Msg: fmt.Sprintf("unknown machine %q", id)}
+		}
+		// Дубликат в выборке — не ошибка, но и второй деплой на ту же
+		// машину параллельно с первым никому не нужен.
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// runFleetMachine прогоняет шаги одной машины по порядку до первого сбоя.
+func (r *RemoteRegistry) runFleetMachine(actor audit.Actor, d RemoteDaemon, ops []FleetOp, refresh FleetRefreshFunc) FleetMachineResult {
+	res := FleetMachineResult{ID: d.ID, Name: d.Name, OK: true}
+	for _, op := range ops {
+		if !res.OK {
+			res.Steps = append(res.Steps, FleetStep{Op: op, Status: FleetStepSkipped, Detail: "previous step failed"})
+			continue
+		}
+		started := time.Now()
+		detail, err := r.runFleetStep(actor, d.ID, op, refresh)
+		step := FleetStep{Op: op, Status: FleetStepOK, Detail: detail, Duration: time.Since(started)}
+		if err != nil {
+			step.Status = FleetStepFailed
+			step.Error = err.Error()
+			res.OK = false
+			debuglog.WarnLog("remote fleet: %s on %q: %v", op, d.ID, err)
+		}
+		res.Steps = append(res.Steps, step)
+	}
+	return res
+}
+
+func (r *RemoteRegistry) runFleetStep(actor audit.Actor, id string, op FleetOp, refresh FleetRefreshFunc) (string, error) {
+	switch op {
+	case FleetOpRefresh:
+		return refresh(id)
+	case FleetOpSyncResources:
+		config, err := os.ReadFile(platform.GetRemoteConfigPathFor(r.execDir, id))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", ErrBuiltConfigMissing
+			}
+			return "", err
+		}
+		files, err := CollectDeployResources(r.execDir, id, config)
+		if err != nil {
+			return "", err
+		}
+		uploaded, err := r.syncResourcesCounted(id, files)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d of %d uploaded", uploaded, len(files)), nil
+	case FleetOpDeploy:
+		dr, err := r.DeployBy(actor, id, nil)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("config %s…, %d resource(s)", dr.ConfigSHA[:12], dr.ResourcesUploaded), nil
+	case FleetOpRestart:
+		return "", r.RestartCore(id)
+	case FleetOpRollback:
+		return "", r.RollbackCore(id)
+	}
+	return "", fmt.Errorf("unknown operation %q", op)
+}
+
+func skippedFleetResult(d RemoteDaemon, ops []FleetOp, reason string) FleetMachineResult {
+	res := FleetMachineResult{ID: d.ID, Name: d.Name}
+	for _, op := range ops {
+		res.Steps = append(res.Steps, FleetStep{Op: op, Status: FleetStepSkipped, Detail: reason})
+	}
+	return res
+}
+
+// SortFleetOps выстраивает набор операций в каноническом порядке FleetOps
+// (для UI, где операции — чекбоксы без порядка).
+func SortFleetOps(ops []FleetOp) []FleetOp {
+	rank := make(map[FleetOp]int, len(FleetOps))
+	for i, op := range FleetOps {
+		rank[op] = i
+	}
+	out := append([]FleetOp(nil), ops...)
+	sort.SliceStable(out, func(i, j int) bool { return rank[out[i]] < rank[out[j]] })
+	return out
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"singbox-launcher/internal/platform"
+)
+
+// Прогон по парку проверяется на настоящем реестре и httptest-демонах:
+// параллельность, порядок шагов, обрыв цепочки машины и stop-on-failure —
+// свойства оркестровки, а не отдельных вызовов.
+
+// fleetDaemon — admin-плоскость демона. failApply = отвечать 422 на apply.
+type fleetDaemon struct {
+	failApply bool
+	delay     time.Duration
+	inFlight  *atomic.Int32
+	peak      *atomic.Int32
+
+	mu    sync.Mutex
+	calls []string
+	// active / lastGood / lastError — что отдаёт /admin/status; active
+	// выставляет удачный apply (sha256 тела).
+	active    string
+	lastGood  string
+	lastError string
+}
+
+func (d *fleetDaemon) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.calls = append(d.calls, r.Method+" "+r.URL.Path)
+		d.mu.Unlock()
+		if d.inFlight != nil && r.URL.Path == "/admin/apply" {
+			n := d.inFlight.Add(1)
+			for {
+				p := d.peak.Load()
+				if n <= p || d.peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			defer d.inFlight.Add(-1)
+		}
+		time.Sleep(d.delay)
+		switch r.URL.Path {
+		case "/admin/resources":
+			_, _ = w.Write([]byte(`{"resources":[]}`))
+		case "/admin/apply":
+			if d.failApply {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"error":"config rejected"}`))
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			d.mu.Lock()
+			d.active = sha256Hex(body)
+			d.lastGood = d.active
+			d.mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		case "/admin/status":
+			d.mu.Lock()
+			_, _ = fmt.Fprintf(w, `{"status":"started","active_sha256":%q,"last_good_sha256":%q,"last_error":%q}`,
+				d.active, d.lastGood, d.lastError)
+			d.mu.Unlock()
+		case "/admin/start", "/admin/stop", "/admin/rollback":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func (d *fleetDaemon) called() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+// seedFleet кладёт в реестр машины m0..mN-1 на их демоны и собранный конфиг
+// каждой.
+func seedFleet(t *testing.T, daemons []*fleetDaemon) *RemoteRegistry {
+	t.Helper()
+	execDir := t.TempDir()
+	var entries []string
+	for i, d := range daemons {
+		srv := httptest.NewServer(d.handler())
+		t.Cleanup(srv.Close)
+		id := fmt.Sprintf("m%d", i)
+		entries = append(entries, fmt.Sprintf(`{"id":%q,"name":%q,"addr":%q}`,
+			id, id, strings.TrimPrefix(srv.URL, "http://")))
+		cfg := platform.GetRemoteConfigPathFor(execDir, id)
+		if err := os.MkdirAll(filepath.Dir(cfg), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cfg, []byte(`{"log":{"level":"warn"}}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binDir := platform.GetBinDir(execDir)
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	raw := "[" + strings.Join(entries, ",") + "]"
+	if err := os.WriteFile(filepath.Join(binDir, "remote-daemons.json"), []byte(raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return NewRemoteRegistry(execDir)
+}
+
+func TestRunFleetDeployRestartAllMachines(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	daemons := make([]*fleetDaemon, 6)
+	for i := range daemons {
+		daemons[i] = &fleetDaemon{delay: 30 * time.Millisecond, inFlight: &inFlight, peak: &peak}
+	}
+	r := seedFleet(t, daemons)
+
+	var streamed atomic.Int32
+	results, err := r.RunFleet(context.Background(), FleetRequest{
+		Ops:         []FleetOp{FleetOpDeploy, FleetOpRestart},
+		Concurrency: 2,
+	}, nil, func(FleetMachineResult) { streamed.Add(1) })
+	if err != nil {
+		t.Fatalf("RunFleet: %v", err)
+	}
+	if len(results) != 6 || streamed.Load() != 6 {
+		t.Fatalf("results=%d streamed=%d, want 6/6", len(results), streamed.Load())
+	}
+	for i, res := range results {
+		if res.ID != fmt.Sprintf("m%d", i) {
+			t.Errorf("results[%d].ID = %q: order must follow the registry, not completion", i, res.ID)
+		}
+		if !res.OK || len(res.Steps) != 2 || res.Steps[0].Status != FleetStepOK || res.Steps[1].Status != FleetStepOK {
+			t.Errorf("%s: %+v, want two ok steps", res.ID, res)
+		}
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrent applies = %d, want ≤ 2", p)
+	}
+	// Restart = stop, затем start — и строго после apply.
+	calls := strings.Join(daemons[0].called(), ",")
+	if !strings.Contains(calls, "POST /admin/apply,POST /admin/stop,POST /admin/start") {
+		t.Errorf("m0 calls = %s, want apply → stop → start", calls)
+	}
+}
+
+// Упавший шаг обрывает цепочку своей машины; stop-on-failure при
+// concurrency=1 не даёт начаться следующим.
+func TestRunFleetStopOnFailure(t *testing.T) {
+	daemons := []*fleetDaemon{{}, {failApply: true}, {}, {}}
+	r := seedFleet(t, daemons)
+
+	results, err := r.RunFleet(context.Background(), FleetRequest{
+		IDs:           []string{"m0", "m1", "m2", "m3"},
+		Ops:           []FleetOp{FleetOpDeploy, FleetOpRestart},
+		Concurrency:   1,
+		StopOnFailure: true,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("RunFleet: %v", err)
+	}
+	if !results[0].OK {
+		t.Errorf("m0 must pass: %+v", results[0])
+	}
+	m1 := results[1]
+	if m1.OK || m1.Steps[0].Status != FleetStepFailed || m1.Steps[1].Status != FleetStepSkipped {
+		t.Errorf("m1 = %+v, want deploy failed + restart skipped", m1)
+	}
+	if !strings.Contains(m1.Steps[0].Error, "config rejected") {
+		t.Errorf("m1 error = %q, want the daemon's message", m1.Steps[0].Error)
+	}
+	for _, res := range results[2:] {
+		if res.OK || res.Started() {
+			t.Errorf("%s ran after a failure with stop-on-failure: %+v", res.ID, res)
+		}
+	}
+	if calls := daemons[2].called(); len(calls) != 0 {
+		t.Errorf("m2 daemon was contacted: %v", calls)
+	}
+
+	// Без stop-on-failure сбой одной машины не задевает остальные.
+	results, err = r.RunFleet(context.Background(), FleetRequest{
+		Ops: []FleetOp{FleetOpDeploy}, Concurrency: 1,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("RunFleet: %v", err)
+	}
+	if !results[2].OK || !results[3].OK {
+		t.Errorf("machines after the failed one must still run: %+v", results)
+	}
+}
+
+func TestRunFleetValidationAndRefresh(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}, {}})
+
+	var ve *FleetValidationError
+	cases := []struct {
+		req   FleetRequest
+		field string
+	}{
+		{FleetRequest{Ops: nil}, "ops"},
+		{FleetRequest{Ops: []FleetOp{"reboot"}}, "ops"},
+		{FleetRequest{Ops: []FleetOp{FleetOpDeploy, FleetOpDeploy}}, "ops"},
+		{FleetRequest{IDs: []string{"ghost"}, Ops: []FleetOp{FleetOpDeploy}}, "machines"},
+		{FleetRequest{Ops: []FleetOp{FleetOpDeploy}, Concurrency: FleetMaxConcurrency + 1}, "concurrency"},
+	}
+	for _, c := range cases {
+		_, err := r.RunFleet(context.Background(), c.req, nil, nil)
+		if !errors.As(err, &ve) || ve.Field != c.field {
+			t.Errorf("%+v: err = %v, want validation error on %q", c.req, err, c.field)
+		}
+	}
+	if _, err := r.RunFleet(context.Background(), FleetRequest{Ops: []FleetOp{FleetOpRefresh}}, nil, nil); !errors.Is(err, ErrFleetRefreshUnavailable) {
+		t.Errorf("refresh without a refresher: err = %v", err)
+	}
+
+	// Refresh приходит снаружи и видит каждую машину ровно раз.
+	var seen sync.Map
+	results, err := r.RunFleet(context.Background(), FleetRequest{
+		Ops: SortFleetOps([]FleetOp{FleetOpSyncResources, FleetOpRefresh}),
+	}, func(id string) (string, error) {
+		seen.Store(id, true)
+		if id == "m1" {
+			return "", errors.New("provider down")
+		}
+		return "1 of 1 subscription(s) fetched", nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("RunFleet: %v", err)
+	}
+	if results[0].Steps[0].Op != FleetOpRefresh {
+		t.Errorf("SortFleetOps must put refresh first: %+v", results[0].Steps)
+	}
+	if !results[0].OK || results[0].Steps[1].Detail != "0 of 0 uploaded" {
+		t.Errorf("m0 = %+v", results[0])
+	}
+	if results[1].OK || results[1].Steps[1].Status != FleetStepSkipped {
+		t.Errorf("m1 = %+v, want refresh failed + sync skipped", results[1])
+	}
+	for _, id := range []string{"m0", "m1"} {
+		if _, ok := seen.Load(id); !ok {
+			t.Errorf("refresh not called for %s", id)
+		}
+	}
+}
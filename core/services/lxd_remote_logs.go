@@ -0,0 +1,492 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+)
+
+// Логи машины: локально накопленная история вместо окна в живой стрим.
+//
+// /remote/machines/{id}/logs отдаёт только то, что пришло за duration, а
+// демон держит короткий буфер и теряет его при перезапуске ядра. Здесь лог
+// копится у нас, пока машину кто-то смотрит (панель логов или Debug API), и
+// фильтруется задним числом: уровень, подстрока, regex, интервал времени.
+//
+// Потоков три — те же, что у локального Log Viewer (Internal / Core / API):
+//
+//   - core — лог ядра машины (gRPC SubscribeLog, FollowLog);
+//   - launcher — строки НАШЕГО лога про эту машину (её id в кавычках или
+//     адрес): сопряжение, деплой, сверка, ротация;
+//   - api — вызовы Debug API, адресованные машине.
+//
+// У строк ядра нет своего времени — SubscribeLog его не передаёт, и метка —
+// момент получения. Буфер демона, пришедший кадром подписки, получает одну
+// метку на всех.
+
+// RemoteLogHistory — сколько строк (всех потоков) держим на машину.
+const RemoteLogHistory = 5000
+
+// RemoteLogLinger — сколько подписка Debug API живёт после последнего
+// вызова: агент спрашивает историю раз в несколько минут, и каждый раз
+// начинать её заново значило бы её не иметь.
+const RemoteLogLinger = 10 * time.Minute
+
+// remoteLogReplayMatch — сколько последних строк ядра сверяется с кадром
+// повтора, чтобы не записать буфер демона второй раз.
+const remoteLogReplayMatch = 20
+
+// RemoteLogStream — поток строки.
+type RemoteLogStream string
+
+const (
+	RemoteLogCore     RemoteLogStream = "core"
+	RemoteLogLauncher RemoteLogStream = "launcher"
+	RemoteLogAPI      RemoteLogStream = "api"
+)
+
+// RemoteLogStreams — все потоки в порядке показа.
+var RemoteLogStreams = []RemoteLogStream{RemoteLogCore, RemoteLogLauncher, RemoteLogAPI}
+
+// ParseRemoteLogStream разбирает имя потока.
+func ParseRemoteLogStream(s string) (RemoteLogStream, bool) {
+	st := RemoteLogStream(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range RemoteLogStreams {
+		if st == known {
+			return st, true
+		}
+	}
+	return "", false
+}
+
+// RemoteLogEntry — одна строка истории.
+type RemoteLogEntry struct {
+	// Seq — сквозной номер в буфере машины; растёт и после вытеснения.
+	Seq     uint64
+	Time    time.Time
+	Stream  RemoteLogStream
+	Level   debuglog.Level
+	Message string
+}
+
+// remoteLogLevel — уровень ядра (daemon.LogLevel) в шкалу debuglog: так
+// фильтр уровня один на все три потока и совпадает с локальным Log Viewer.
+func remoteLogLevel(s string) debuglog.Level {
+	switch strings.ToUpper(s) {
+	case "PANIC", "FATAL", "ERROR":
+		return debuglog.LevelError
+	case "WARN", "WARNING":
+		return debuglog.LevelWarn
+	case "DEBUG":
+		return debuglog.LevelVerbose
+	case "TRACE":
+		return debuglog.LevelTrace
+	default:
+		return debuglog.LevelInfo
+	}
+}
+
+// ParseRemoteLogLevel — error | warn | info | debug | trace.
+func ParseRemoteLogLevel(s string) (debuglog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error":
+		return debuglog.LevelError, true
+	case "warn", "warning":
+		return debuglog.LevelWarn, true
+	case "info":
+		return debuglog.LevelInfo, true
+	case "debug", "verbose":
+		return debuglog.LevelVerbose, true
+	case "trace":
+		return debuglog.LevelTrace, true
+	}
+	return debuglog.LevelOff, false
+}
+
+// RemoteLogLevelName — имя уровня для вывода (ответ API, файл).
+func RemoteLogLevelName(l debuglog.Level) string {
+	switch l {
+	case debuglog.LevelError:
+		return "error"
+	case debuglog.LevelWarn:
+		return "warn"
+	case debuglog.LevelVerbose:
+		return "debug"
+	case debuglog.LevelTrace:
+		return "trace"
+	default:
+		return "info"
+	}
+}
+
+// RemoteLogFilter — отбор строк истории. Нулевое значение пропускает всё.
+type RemoteLogFilter struct {
+	// Streams — какие потоки; пусто — все.
+	Streams []RemoteLogStream
+	// Level — самый подробный показываемый уровень (как в Log Viewer);
+	// LevelOff — все.
+	Level debuglog.Level
+	// Contains — подстрока без учёта регистра.
+	Contains string
+	Regex    *regexp.Regexp
+	// Since/Until — интервал включительно; нулевое — без границы.
+	Since, Until time.Time
+}
+
+// Match — проходит ли строка фильтр.
+func (f RemoteLogFilter) Match(e RemoteLogEntry) bool {
+	if len(f.Streams) > 0 {
+		ok := false
+		for _, s := range f.Streams {
+			if s == e.Stream {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.Level != debuglog.LevelOff && e.Level > f.Level {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(strings.ToLower(e.Message), strings.ToLower(f.Contains)) {
+		return false
+	}
+	if f.Regex != nil && !f.Regex.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// RemoteLogBuffer — история одной машины: кольцо на limit строк.
+type RemoteLogBuffer struct {
+	mu      sync.Mutex
+	entries []RemoteLogEntry
+	seq     uint64
+	limit   int
+}
+
+// NewRemoteLogBuffer создаёт буфер; limit <= 0 — RemoteLogHistory.
+func NewRemoteLogBuffer(limit int) *RemoteLogBuffer {
+	if limit <= 0 {
+		limit = RemoteLogHistory
+	}
+	return &RemoteLogBuffer{limit: limit}
+}
+
+// Append добавляет строку с текущим временем.
+func (b *RemoteLogBuffer) Append(stream RemoteLogStream, level debuglog.Level, msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.appendLocked(RemoteLogEntry{Time: time.Now(), Stream: stream, Level: level, Message: msg})
+}
+
+func (b *RemoteLogBuffer) appendLocked(e RemoteLogEntry) {
+	b.seq++
+	e.Seq = b.seq
+	b.entries = append(b.entries, e)
+	if over := len(b.entries) - b.limit; over > 0 {
+		// Копия, а не срез хвоста: иначе голова массива жила бы вечно.
+		b.entries = append([]RemoteLogEntry(nil), b.entries[over:]...)
+	}
+}
+
+// appendCore — кадр лога ядра. replay — кадр может повторять уже
+// записанное (первый кадр подписки: буфер демона; reset после обрыва):
+// совпавшее с нашим хвостом отбрасывается.
+func (b *RemoteLogBuffer) appendCore(replay bool, lines []LogLine, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if replay {
+		var tail []string
+		for i := len(b.entries) - 1; i >= 0 && len(tail) < remoteLogReplayMatch; i-- {
+			if b.entries[i].Stream == RemoteLogCore {
+				tail = append(tail, b.entries[i].Message)
+			}
+		}
+		// tail собран с конца — разворачиваем.
+		for i, j := 0, len(tail)-1; i < j; i, j = i+1, j-1 {
+			tail[i], tail[j] = tail[j], tail[i]
+		}
+		lines = lines[replayOverlap(tail, lines):]
+	}
+	for _, l := range lines {
+		b.appendLocked(RemoteLogEntry{Time: now, Stream: RemoteLogCore, Level: remoteLogLevel(l.Level), Message: l.Message})
+	}
+}
+
+// replayOverlap — сколько строк в начале кадра уже есть в хвосте истории.
+//
+// Ищется самая длинная позиция p, при которой кадр до p заканчивается тем
+// же, чем хвост: буфер демона может быть и длиннее, и короче нашего хвоста.
+// Нет совпадения — ядро перезапустилось (буфер новый) или разрыв был дольше
+// буфера демона: тогда кадр пишется целиком.
+func replayOverlap(tail []string, lines []LogLine) int {
+	if len(tail) == 0 {
+		return 0
+	}
+	for p := len(lines); p > 0; p-- {
+		m := min(p, len(tail))
+		match := true
+		for k := 0; k < m; k++ {
+			if lines[p-m+k].Message != tail[len(tail)-m+k] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return p
+		}
+	}
+	return 0
+}
+
+// Query — строки под фильтром, от старых к новым. max > 0 оставляет max
+// самых новых; truncated — что-то отрезано.
+func (b *RemoteLogBuffer) Query(f RemoteLogFilter, max int) (out []RemoteLogEntry, truncated bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.entries {
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	if max > 0 && len(out) > max {
+		return out[len(out)-max:], true
+	}
+	return out, false
+}
+
+// LastSeq — номер последней строки (0 — пусто); UI перерисовывается, только
+// когда он сдвинулся.
+func (b *RemoteLogBuffer) LastSeq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+// Len — сколько строк в истории.
+func (b *RemoteLogBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// Clear очищает историю (номера продолжаются).
+func (b *RemoteLogBuffer) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = nil
+}
+
+// WriteRemoteLog — строки в текст для файла, по одной на строку.
+func WriteRemoteLog(w io.Writer, entries []RemoteLogEntry) error {
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(w, "%s [%s] %-5s %s\n", e.Time.Format("2006-01-02 15:04:05.000"),
+			e.Stream, strings.ToUpper(RemoteLogLevelName(e.Level)), e.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoteLogHub — истории машин, которые сейчас кто-то смотрит. Одна на
+// процесс (AppController.RemoteLogs): панель UI и Debug API видят одну и ту
+// же историю, и машина подписана один раз.
+type RemoteLogHub struct {
+	registry *RemoteRegistry
+
+	mu      sync.Mutex
+	follows map[string]*remoteLogFollow
+
+	// active — снимок follows для tap'а debuglog: tap зовётся под локом
+	// debuglog и не должен брать mu (под mu может идти логирование).
+	active atomic.Pointer[[]*remoteLogFollow]
+	tapMu  sync.Mutex
+	untap  func()
+}
+
+type remoteLogFollow struct {
+	id    string
+	addr  string
+	buf   *RemoteLogBuffer
+	refs  int
+	since time.Time
+	stop  func()
+}
+
+// NewRemoteLogHub создаёт хаб над реестром.
+func NewRemoteLogHub(registry *RemoteRegistry) *RemoteLogHub {
+	return &RemoteLogHub{registry: registry, follows: map[string]*remoteLogFollow{}}
+}
+
+// Follow — история машины. Первый Follow подписывается на лог ядра,
+// последний release отписывается и забывает историю; release обязателен.
+func (h *RemoteLogHub) Follow(id string) (*RemoteLogBuffer, func(), error) {
+	d, ok, err := h.registry.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	h.mu.Lock()
+	f := h.follows[id]
+	if f == nil {
+		f = &remoteLogFollow{id: id, addr: d.Addr, buf: NewRemoteLogBuffer(0), since: time.Now()}
+		stop, oerr := h.openLocked(f)
+		if oerr != nil {
+			h.mu.Unlock()
+			return nil, nil, oerr
+		}
+		f.stop = stop
+		h.follows[id] = f
+		h.publishLocked()
+	}
+	f.refs++
+	h.mu.Unlock()
+	h.syncTap()
+
+	var once sync.Once
+	release := func() { once.Do(func() { h.release(id) }) }
+	return f.buf, release, nil
+}
+
+// Keep — Follow для Debug API: подписка отпускается сама через linger.
+func (h *RemoteLogHub) Keep(id string, linger time.Duration) (*RemoteLogBuffer, time.Time, error) {
+	buf, release, err := h.Follow(id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	time.AfterFunc(linger, release)
+	h.mu.Lock()
+	since := h.follows[id].since
+	h.mu.Unlock()
+	return buf, since, nil
+}
+
+// Restart переоткрывает подписку машины на свежем транспорте, сохраняя
+// историю: после ротации ключа, re-pair или смены маршрута старый канал
+// переподписывался бы со старым мандатом вечно. Нет подписки — no-op.
+func (h *RemoteLogHub) Restart(id string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.follows[id]
+	if f == nil {
+		return nil
+	}
+	stop, err := h.openLocked(f)
+	if err != nil {
+		return err
+	}
+	f.stop()
+	f.stop = stop
+	return nil
+}
+
+// openLocked подписывается на лог ядра машины в буфер f.
+func (h *RemoteLogHub) openLocked(f *remoteLogFollow) (func(), error) {
+	t, err := h.registry.Transport(f.id)
+	if err != nil {
+		return nil, err
+	}
+	buf := f.buf
+	cancel, err := t.FollowLog(func(replay bool, lines []LogLine) {
+		buf.appendCore(replay, lines, time.Now())
+	})
+	if err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+	return func() {
+		cancel()
+		_ = t.Close()
+	}, nil
+}
+
+func (h *RemoteLogHub) release(id string) {
+	h.mu.Lock()
+	f := h.follows[id]
+	if f == nil {
+		h.mu.Unlock()
+		return
+	}
+	f.refs--
+	if f.refs > 0 {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.follows, id)
+	h.publishLocked()
+	h.mu.Unlock()
+	f.stop()
+	h.syncTap()
+}
+
+// Note — строка в поток машины, если её историю кто-то копит; иначе no-op.
+func (h *RemoteLogHub) Note(id string, stream RemoteLogStream, level debuglog.Level, msg string) {
+	if h == nil {
+		return
+	}
+	if list := h.active.Load(); list != nil {
+		for _, f := range *list {
+			if f.id == id {
+				f.buf.Append(stream, level, msg)
+				return
+			}
+		}
+	}
+}
+
+func (h *RemoteLogHub) publishLocked() {
+	list := make([]*remoteLogFollow, 0, len(h.follows))
+	for _, f := range h.follows {
+		list = append(list, f)
+	}
+	h.active.Store(&list)
+}
+
+// syncTap держит tap debuglog ровно пока есть хоть одна история: без
+// неё debuglog не форматирует trace-строки, которые никто не читает.
+func (h *RemoteLogHub) syncTap() {
+	h.tapMu.Lock()
+	defer h.tapMu.Unlock()
+	n := 0
+	if list := h.active.Load(); list != nil {
+		n = len(*list)
+	}
+	switch {
+	case n > 0 && h.untap == nil:
+		h.untap = debuglog.AddLogTap(h.tap)
+	case n == 0 && h.untap != nil:
+		h.untap()
+		h.untap = nil
+	}
+}
+
+// tap — строка нашего лога; попадает в историю машины, если называет её
+// id в кавычках (так пишут %q реестр и окна) или её адрес.
+func (h *RemoteLogHub) tap(level debuglog.Level, line string) {
+	list := h.active.Load()
+	if list == nil {
+		return
+	}
+	for _, f := range *list {
+		if strings.Contains(line, `"`+f.id+`"`) || (f.addr != "" && strings.Contains(line, f.addr)) {
+			f.buf.Append(RemoteLogLauncher, level, line)
+		}
+	}
+}
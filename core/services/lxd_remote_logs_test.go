@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"singbox-launcher/internal/debuglog"
+)
+
+func coreLines(msgs ...string) []LogLine {
+	out := make([]LogLine, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, LogLine{Level: "INFO", Message: m})
+	}
+	return out
+}
+
+func bufferMessages(b *RemoteLogBuffer) []string {
+	entries, _ := b.Query(RemoteLogFilter{}, 0)
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Message)
+	}
+	return out
+}
+
+// Повтор буфера демона после переподписки не дублирует историю: пишется
+// только то, что идёт после совпавшего хвоста.
+func TestRemoteLogReplayDedup(t *testing.T) {
+	b := NewRemoteLogBuffer(0)
+	now := time.Now()
+	b.appendCore(true, coreLines("a", "b", "c"), now)
+	b.appendCore(false, coreLines("d"), now)
+	// Переподписка: демон шлёт свой буфер (короче нашей истории) и новое.
+	b.appendCore(true, coreLines("c", "d", "e"), now)
+	// Буфер длиннее нашего хвоста.
+	b.appendCore(true, coreLines("z", "a", "b", "c", "d", "e", "f"), now)
+	if got := strings.Join(bufferMessages(b), ","); got != "a,b,c,d,e,f" {
+		t.Fatalf("history = %s", got)
+	}
+	// Ядро перезапустилось — совпадения нет, кадр пишется целиком.
+	b.appendCore(true, coreLines("started"), now)
+	if got := bufferMessages(b); got[len(got)-1] != "started" || len(got) != 7 {
+		t.Fatalf("after restart: %v", got)
+	}
+	// Строки других потоков между строками ядра сверке не мешают.
+	b.Append(RemoteLogAPI, debuglog.LevelInfo, "GET /remote/machines/x/status")
+	b.appendCore(true, coreLines("started", "next"), now)
+	if got := bufferMessages(b); got[len(got)-1] != "next" || len(got) != 9 {
+		t.Fatalf("with api lines: %v", got)
+	}
+}
+
+func TestRemoteLogBufferCap(t *testing.T) {
+	b := NewRemoteLogBuffer(3)
+	for _, m := range []string{"1", "2", "3", "4", "5"} {
+		b.Append(RemoteLogLauncher, debuglog.LevelInfo, m)
+	}
+	if got := strings.Join(bufferMessages(b), ","); got != "3,4,5" || b.LastSeq() != 5 {
+		t.Fatalf("ring = %s, seq %d", got, b.LastSeq())
+	}
+	entries, truncated := b.Query(RemoteLogFilter{}, 2)
+	if !truncated || len(entries) != 2 || entries[0].Message != "4" {
+		t.Fatalf("max keeps the newest: %+v %v", entries, truncated)
+	}
+}
+
+func TestRemoteLogFilter(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := RemoteLogEntry{Time: base, Stream: RemoteLogCore, Level: debuglog.LevelWarn, Message: "outbound/vless[proxy]: Dial TCP failed"}
+
+	cases := []struct {
+		name string
+		f    RemoteLogFilter
+		want bool
+	}{
+		{"zero", RemoteLogFilter{}, true},
+		{"stream", RemoteLogFilter{Streams: []RemoteLogStream{RemoteLogAPI}}, false},
+		{"level passes", RemoteLogFilter{Level: debuglog.LevelInfo}, true},
+		{"level cuts", RemoteLogFilter{Level: debuglog.LevelError}, false},
+		{"contains ignores case", RemoteLogFilter{Contains: "dial tcp"}, true},
+		{"regex", RemoteLogFilter{Regex: regexp.MustCompile(`vless\[\w+\]`)}, true},
+		{"regex miss", RemoteLogFilter{Regex: regexp.MustCompile(`^inbound`)}, false},
+		{"since", RemoteLogFilter{Since: base.Add(time.Second)}, false},
+		{"until inclusive", RemoteLogFilter{Until: base}, true},
+	}
+	for _, c := range cases {
+		if got := c.f.Match(e); got != c.want {
+			t.Errorf("%s: Match = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := WriteRemoteLog(&out, []RemoteLogEntry{e}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2026-01-02 03:04:05.000 [core] WARN  outbound/vless[proxy]: Dial TCP failed\n"; out.String() != want {
+		t.Errorf("file line = %q", out.String())
+	}
+}
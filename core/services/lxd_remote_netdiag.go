@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+
+	"singbox-launcher/core/netdiag"
+	daemonpb "singbox-launcher/internal/daemonpb"
+)
+
+// Тесты качества канала и NAT через outbound удалённой машины. Меряет сам
+// демон: трафик должен идти от роутера, а не от лаунчера, иначе результат
+// описывал бы дорогу от ноутбука до роутера плюс узел.
+//
+// Стрим живёт столько, сколько идёт тест (десятки секунд), поэтому здесь не
+// rpc() с его 25-секундным дедлайном, а соединение streamConn() и ctx
+// вызывающего.
+
+// NetworkQualityTest — StartNetworkQualityTest через outbound tag машины.
+func (t *LxdRemoteTransport) NetworkQualityTest(ctx context.Context, tag string, opts netdiag.QualityOptions, onProgress func(netdiag.QualityResult)) (netdiag.QualityResult, error) {
+	conn, err := t.streamConn()
+	if err != nil {
+		return netdiag.QualityResult{Via: netdiag.ViaRemote}, err
+	}
+	return netdiag.RunDaemonQuality(ctx, daemonpb.NewStartedServiceClient(conn), netdiag.ViaRemote, tag, opts, onProgress)
+}
+
+// STUNTest — StartSTUNTest через outbound tag машины.
+func (t *LxdRemoteTransport) STUNTest(ctx context.Context, tag, server string) (netdiag.NATResult, error) {
+	conn, err := t.streamConn()
+	if err != nil {
+		return netdiag.NATResult{Via: netdiag.ViaRemote, Server: server}, err
+	}
+	return netdiag.RunDaemonNAT(ctx, daemonpb.NewStartedServiceClient(conn), netdiag.ViaRemote, tag, server)
+}
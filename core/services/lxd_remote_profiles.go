@@ -0,0 +1,404 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corestate "singbox-launcher/core/state"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// Базовые профили: общие настройки, от которых наследуют несколько машин.
+//
+// CopyProfileFrom делает разовую копию, после которой копии расходятся:
+// правило, добавленное на одном роутере, на остальных приходится повторять
+// руками. Здесь машина привязана к именованному профилю
+// (bin/wizard_states/profiles/<name>.json) и хранит только свои отличия
+// (core/state/profile_overlay.go): правка базы доезжает до всех наследников
+// при следующей сборке, а их собственные правки — TUN вкл/выкл, роль шлюза,
+// локальные источники — остаются.
+//
+// Как хранятся отличия. state.json машины остаётся её ЭФФЕКТИВНЫМ
+// состоянием: его читают визард, обновление подписок, Debug API — и ни один
+// из них не должен знать про наследование. Рядом лежит снимок базы, от
+// которой машина материализована последний раз (base_profile.snapshot).
+// Отличия машины — это diff(снимок, state.json), и при смене базы машина
+// пересобирается трёхсторонним merge: RebaseProfile(снимок, новая база,
+// state.json). Поэтому отличия не нужно отдельно фиксировать при каждом
+// сохранении: кто бы ни правил state.json, правка становится отличием сама.
+//
+// Когда база доезжает до машины. Сборка конфига удалённой машины есть только
+// в визарде (SPEC 100 §3.3), поэтому материализация идёт при открытии
+// Configure (SyncBaseProfile) — и визард сразу видит новую базу. Deploy
+// машины, чья база ушла вперёд собранного конфига, отказывается
+// (ErrProfileRebuildNeeded): тихо отправить конфиг со старой базой — ровно
+// тот дрейф, от которого наследование и спасает.
+
+// ErrBaseProfileNotFound — профиля с таким именем нет.
+var ErrBaseProfileNotFound = errors.New("base profile not found")
+
+// ErrBaseProfileInUse — профиль удаляют, пока от него наследуют машины.
+var ErrBaseProfileInUse = errors.New("base profile is inherited by machines — detach them first")
+
+// ErrProfileRebuildNeeded — базовый профиль машины менялся после сборки её
+// конфига. API отвечает 409, UI предлагает открыть Configure.
+var ErrProfileRebuildNeeded = errors.New("base profile changed since this machine's config was built — open Configure to rebuild it")
+
+// baseProfileNameRe — имя профиля = имя файла: без разделителей пути и
+// точек в начале.
+var baseProfileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// BaseProfileInfo — строка списка профилей.
+type BaseProfileInfo struct {
+	Name      string
+	UpdatedAt time.Time
+	// Machines — ID машин, наследующих профиль (порядок реестра).
+	Machines []string
+}
+
+// BaseProfileStatus — положение машины относительно её профиля.
+type BaseProfileStatus struct {
+	// Name — профиль; пусто — машина не наследует.
+	Name string
+	// BaseChanged — профиль менялся после последней материализации машины.
+	BaseChanged bool
+	// RebuildNeeded — конфиг машины собран раньше, чем она получила
+	// текущую базу (или база уже снова ушла вперёд).
+	RebuildNeeded bool
+	// Overlay — отличия машины от базы, от которой она материализована.
+	Overlay corestate.ProfileOverlay
+}
+
+// ValidateBaseProfileName проверяет имя профиля.
+func ValidateBaseProfileName(name string) error {
+	if !baseProfileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_' (up to 64)", name)
+	}
+	return nil
+}
+
+// ListBaseProfiles — все профили по имени, с наследниками.
+func (r *RemoteRegistry) ListBaseProfiles() ([]BaseProfileInfo, error) {
+	entries, err := os.ReadDir(platform.GetBaseProfilesDir(r.execDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("base profiles: %w", err)
+	}
+	machines, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []BaseProfileInfo
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || ValidateBaseProfileName(name) != nil {
+			continue
+		}
+		info := BaseProfileInfo{Name: name}
+		if fi, err := e.Info(); err == nil {
+			info.UpdatedAt = fi.ModTime()
+		}
+		for _, d := range machines {
+			if d.BaseProfile == name {
+				info.Machines = append(info.Machines, d.ID)
+			}
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// GetBaseProfile — state.json профиля.
+func (r *RemoteRegistry) GetBaseProfile(name string) ([]byte, error) {
+	if err := ValidateBaseProfileName(name); err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(platform.GetBaseProfilePath(r.execDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %q", ErrBaseProfileNotFound, name)
+		}
+		return nil, fmt.Errorf("base profile %q: %w", name, err)
+	}
+	return raw, nil
+}
+
+// PutBaseProfile создаёт или перезаписывает профиль целиком. Наследники
+// получат изменения при следующей материализации.
+func (r *RemoteRegistry) PutBaseProfile(name string, raw []byte) error {
+	if err := ValidateBaseProfileName(name); err != nil {
+		return err
+	}
+	if err := corestate.CheckProfileState(raw); err != nil {
+		return err
+	}
+	if err := writeFileReplacing(platform.GetBaseProfilePath(r.execDir, name), raw); err != nil {
+		return fmt.Errorf("base profile %q: %w", name, err)
+	}
+	debuglog.InfoLog("base profile: %q saved (%d bytes)", name, len(raw))
+	return nil
+}
+
+// SaveBaseProfileFrom публикует состояние машины как профиль name (создаёт
+// или перезаписывает).
+//
+// Обычный сценарий правки базы: открыть в визарде «ведущую» машину, поменять
+// правило, опубликовать. Если машина сама наследует этот профиль, её
+// отличия при этом становятся базой — и снимок сдвигается на новую базу,
+// иначе следующий rebase посчитал бы их отличиями дважды.
+func (r *RemoteRegistry) SaveBaseProfileFrom(name, id string) error {
+	d, ok, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	raw, err := os.ReadFile(platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("machine %q has no settings yet — configure it first", id)
+		}
+		return fmt.Errorf("base profile: read machine state: %w", err)
+	}
+	if err := r.PutBaseProfile(name, raw); err != nil {
+		return err
+	}
+	if d.BaseProfile == name {
+		return writeFileReplacing(platform.GetBaseProfileSnapshotPathFor(r.execDir, id), raw)
+	}
+	return nil
+}
+
+// DeleteBaseProfile удаляет профиль, от которого никто не наследует.
+func (r *RemoteRegistry) DeleteBaseProfile(name string) error {
+	list, err := r.ListBaseProfiles()
+	if err != nil {
+		return err
+	}
+	for _, p := range list {
+		if p.Name != name {
+			continue
+		}
+		if len(p.Machines) > 0 {
+			return fmt.Errorf("%w: %s", ErrBaseProfileInUse, strings.Join(p.Machines, ", "))
+		}
+		if err := os.Remove(platform.GetBaseProfilePath(r.execDir, name)); err != nil {
+			return fmt.Errorf("base profile %q: %w", name, err)
+		}
+		debuglog.InfoLog("base profile: %q deleted", name)
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrBaseProfileNotFound, name)
+}
+
+// InheritBaseProfile привязывает машину к профилю.
+//
+// У машины без настроек state.json становится копией базы (платформа — её
+// собственная, как в CopyProfileFrom). У настроенной машины state.json НЕ
+// меняется: всё, чем она отличается от базы, с этого момента и есть её
+// отличия, а общее с базой начинает следовать за базой.
+func (r *RemoteRegistry) InheritBaseProfile(id, name string) error {
+	base, err := r.GetBaseProfile(name)
+	if err != nil {
+		return err
+	}
+	d, ok, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	statePath := platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, id)
+	cur, err := os.ReadFile(statePath)
+	switch {
+	case os.IsNotExist(err):
+		patched, err := retargetStateJSON(base, d.Target().GOOS, d.Target().GOARCH)
+		if err != nil {
+			return fmt.Errorf("base profile: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(statePath), platform.DefaultDirMode); err != nil {
+			return fmt.Errorf("base profile: mkdir: %w", err)
+		}
+		if err := writeFileReplacing(statePath, patched); err != nil {
+			return fmt.Errorf("base profile: write machine state: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("base profile: read machine state: %w", err)
+	default:
+		// Сопоставимость проверяем сразу, а не на первом rebase: legacy
+		// state машины иначе всплыл бы ошибкой уже после смены базы.
+		if _, err := corestate.DiffProfile(base, cur); err != nil {
+			return err
+		}
+	}
+	if err := writeFileReplacing(platform.GetBaseProfileSnapshotPathFor(r.execDir, id), base); err != nil {
+		return fmt.Errorf("base profile: write snapshot: %w", err)
+	}
+	if err := r.setBaseProfile(id, name); err != nil {
+		return err
+	}
+	debuglog.InfoLog("base profile: machine %q now inherits %q", id, name)
+	return nil
+}
+
+// DetachBaseProfile отвязывает машину: её state.json остаётся как есть —
+// обычная самостоятельная копия, как после CopyProfileFrom.
+func (r *RemoteRegistry) DetachBaseProfile(id string) error {
+	if err := r.setBaseProfile(id, ""); err != nil {
+		return err
+	}
+	if err := os.Remove(platform.GetBaseProfileSnapshotPathFor(r.execDir, id)); err != nil && !os.IsNotExist(err) {
+		debuglog.WarnLog("base profile: remove snapshot of %q: %v", id, err)
+	}
+	debuglog.InfoLog("base profile: machine %q detached", id)
+	return nil
+}
+
+// SyncBaseProfile материализует машину от текущей базы: её отличия от
+// прежней базы накладываются на новую. false — база не менялась (или
+// машина не наследует), файлы не тронуты.
+func (r *RemoteRegistry) SyncBaseProfile(id string) (bool, error) {
+	d, ok, err := r.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	if d.BaseProfile == "" {
+		return false, nil
+	}
+	base, err := r.GetBaseProfile(d.BaseProfile)
+	if err != nil {
+		return false, err
+	}
+	snapPath := platform.GetBaseProfileSnapshotPathFor(r.execDir, id)
+	snap, err := os.ReadFile(snapPath)
+	if err != nil {
+		return false, fmt.Errorf("base profile: read snapshot: %w", err)
+	}
+	if bytes.Equal(snap, base) {
+		return false, nil
+	}
+	statePath := platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, id)
+	cur, err := os.ReadFile(statePath)
+	if err != nil {
+		return false, fmt.Errorf("base profile: read machine state: %w", err)
+	}
+	next, err := corestate.RebaseProfile(snap, base, cur)
+	if err != nil {
+		return false, err
+	}
+	// Сначала состояние, потом снимок: сбой между ними оставит старый
+	// снимок, и следующий Sync просто повторит rebase. Обратный порядок
+	// потерял бы изменения базы — они стали бы «отличиями» машины.
+	if err := writeFileReplacing(statePath, next); err != nil {
+		return false, fmt.Errorf("base profile: write machine state: %w", err)
+	}
+	if err := writeFileReplacing(snapPath, base); err != nil {
+		return false, fmt.Errorf("base profile: write snapshot: %w", err)
+	}
+	debuglog.InfoLog("base profile: machine %q rebased onto %q", id, d.BaseProfile)
+	return true, nil
+}
+
+// BaseProfileStatus — наследует ли машина профиль, ушла ли база вперёд и
+// чем машина от неё отличается.
+func (r *RemoteRegistry) BaseProfileStatus(id string) (BaseProfileStatus, error) {
+	d, ok, err := r.Get(id)
+	if err != nil {
+		return BaseProfileStatus{}, err
+	}
+	if !ok {
+		return BaseProfileStatus{}, fmt.Errorf("remote registry: unknown id %q", id)
+	}
+	st := BaseProfileStatus{Name: d.BaseProfile}
+	if d.BaseProfile == "" {
+		return st, nil
+	}
+	base, err := r.GetBaseProfile(d.BaseProfile)
+	if err != nil {
+		return st, err
+	}
+	snapPath := platform.GetBaseProfileSnapshotPathFor(r.execDir, id)
+	snap, err := os.ReadFile(snapPath)
+	if err != nil {
+		return st, fmt.Errorf("base profile: read snapshot: %w", err)
+	}
+	st.BaseChanged = !bytes.Equal(snap, base)
+	st.RebuildNeeded = st.BaseChanged
+	if snapInfo, err := os.Stat(snapPath); err == nil {
+		if cfgInfo, err := os.Stat(platform.GetRemoteConfigPathFor(r.execDir, id)); err == nil &&
+			cfgInfo.ModTime().Before(snapInfo.ModTime()) {
+			st.RebuildNeeded = true
+		}
+	}
+	if cur, err := os.ReadFile(platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, id)); err == nil {
+		if st.Overlay, err = corestate.DiffProfile(snap, cur); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// checkProfileBuilt — страж Deploy: конфиг наследующей машины не старше её
+// базы. Ошибка чтения статуса (нет снимка, профиль удалён руками) Deploy не
+// блокирует — она всплывёт в статусе машины, а деплой собранного конфига
+// остаётся возможным.
+func (r *RemoteRegistry) checkProfileBuilt(id string) error {
+	st, err := r.BaseProfileStatus(id)
+	if err != nil {
+		debuglog.WarnLog("remote deploy: base profile status of %q: %v", id, err)
+		return nil
+	}
+	if st.RebuildNeeded {
+		return fmt.Errorf("%w (profile %q)", ErrProfileRebuildNeeded, st.Name)
+	}
+	return nil
+}
+
+// setBaseProfile меняет привязку машины в реестре.
+func (r *RemoteRegistry) setBaseProfile(id, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		list[i].BaseProfile = name
+		return r.saveLocked(list)
+	}
+	return fmt.Errorf("remote registry: unknown id %q", id)
+}
+
+// writeFileReplacing — запись через .tmp + rename: читатель видит либо
+// старый файл, либо новый целиком.
+func writeFileReplacing(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), platform.DefaultDirMode); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, platform.DefaultFileMode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/platform"
+)
+
+const profileBase = `{
+  "meta": {"version": 6, "schema": "presets_v1", "target_platform": "linux", "target_arch": "amd64"},
+  "connections": {"sources": [], "outbounds": [], "defaults": {}},
+  "rules": [{"kind": "preset", "ref": "ru-direct", "enabled": true, "body": {"vars": {}}}],
+  "vars": [{"name": "tun", "value": "false"}],
+  "dns_options": {}
+}`
+
+// Путь наследования целиком: привязка, правка базы, rebase при
+// материализации, страж Deploy до пересборки конфига.
+func TestBaseProfileInheritSyncAndDeployGuard(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}, {}})
+	if err := r.PutBaseProfile("home", []byte(profileBase)); err != nil {
+		t.Fatalf("PutBaseProfile: %v", err)
+	}
+
+	// m0 не настроена — получает копию базы; m1 настроена и включила TUN.
+	m1State := strings.Replace(profileBase, `"value": "false"`, `"value": "true"`, 1)
+	m1Path := platform.GetWizardStatePathFor(r.execDir, constants.ConfigTargetRemote, "m1")
+	if err := os.WriteFile(m1Path, []byte(m1State), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"m0", "m1"} {
+		if err := r.InheritBaseProfile(id, "home"); err != nil {
+			t.Fatalf("InheritBaseProfile(%s): %v", id, err)
+		}
+	}
+	if got, _ := os.ReadFile(m1Path); string(got) != m1State {
+		t.Errorf("inheriting must not rewrite a configured machine's state:\n%s", got)
+	}
+	if err := r.DeleteBaseProfile("home"); !errors.Is(err, ErrBaseProfileInUse) {
+		t.Errorf("DeleteBaseProfile in use: err = %v", err)
+	}
+
+	// Свежий конфиг после привязки: деплой проходит.
+	time.Sleep(20 * time.Millisecond)
+	for _, id := range []string{"m0", "m1"} {
+		touchBuiltConfig(t, r.execDir, id)
+	}
+	if _, err := r.Deploy("m1", nil); err != nil {
+		t.Fatalf("Deploy before base change: %v", err)
+	}
+
+	// В базе появилось правило — m1 отказывается деплоить старый конфиг.
+	newBase := strings.Replace(profileBase, `"rules": [`,
+		`"rules": [{"kind": "inline", "enabled": true, "body": {"name": "Ads", "match": {"domain_suffix": ["ads.example"]}, "outbound": "block"}}, `, 1)
+	if err := r.PutBaseProfile("home", []byte(newBase)); err != nil {
+		t.Fatal(err)
+	}
+	st, err := r.BaseProfileStatus("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.BaseChanged || !st.RebuildNeeded || st.Overlay.Lists["vars"] == nil {
+		t.Errorf("status = %+v, want stale base and the TUN override", st)
+	}
+	if _, err := r.Deploy("m1", nil); !errors.Is(err, ErrProfileRebuildNeeded) {
+		t.Errorf("Deploy with a stale base: err = %v", err)
+	}
+
+	changed, err := r.SyncBaseProfile("m1")
+	if err != nil || !changed {
+		t.Fatalf("SyncBaseProfile: changed=%v err=%v", changed, err)
+	}
+	got, _ := os.ReadFile(m1Path)
+	if !strings.Contains(string(got), `"Ads"`) || !strings.Contains(string(got), `"value": "true"`) {
+		t.Errorf("rebased state must carry the base rule and keep TUN on:\n%s", got)
+	}
+	if changed, _ := r.SyncBaseProfile("m1"); changed {
+		t.Error("second sync with the same base must be a no-op")
+	}
+	// Материализована, но конфиг ещё старый — страж держит до пересборки.
+	if _, err := r.Deploy("m1", nil); !errors.Is(err, ErrProfileRebuildNeeded) {
+		t.Errorf("Deploy before rebuild: err = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	touchBuiltConfig(t, r.execDir, "m1")
+	if _, err := r.Deploy("m1", nil); err != nil {
+		t.Errorf("Deploy after rebuild: %v", err)
+	}
+
+	if err := r.DetachBaseProfile("m0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DetachBaseProfile("m1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteBaseProfile("home"); err != nil {
+		t.Errorf("DeleteBaseProfile after detach: %v", err)
+	}
+}
+
+func TestBaseProfileValidation(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}})
+	for _, name := range []string{"", "../etc", ".hidden", "a/b"} {
+		if err := r.PutBaseProfile(name, []byte(profileBase)); err == nil {
+			t.Errorf("PutBaseProfile(%q) accepted an unsafe name", name)
+		}
+	}
+	if err := r.PutBaseProfile("legacy", []byte(`{"version":4}`)); err == nil {
+		t.Error("PutBaseProfile accepted a legacy-layout state")
+	}
+	if err := r.InheritBaseProfile("m0", "missing"); !errors.Is(err, ErrBaseProfileNotFound) {
+		t.Errorf("InheritBaseProfile(missing): err = %v", err)
+	}
+}
+
+// touchBuiltConfig имитирует Save в визарде: config.json машины
+// пересобран сейчас.
+func touchBuiltConfig(t *testing.T, execDir, id string) {
+	t.Helper()
+	now := time.Now()
+	if err := os.Chtimes(platform.GetRemoteConfigPathFor(execDir, id), now, now); err != nil {
+		t.Fatal(err)
+	}
+}
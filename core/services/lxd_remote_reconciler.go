@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/events"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+// Фоновая сверка машин (CheckDrift) по их DeployPolicy.
+//
+// Один на процесс: его отчёты читают строка списка машин и Debug API, и два
+// экземпляра сверяли бы одну машину дважды. Машины без CheckEvery он не
+// трогает — показ списка и работа лаунчера по-прежнему не повод ходить к
+// чужим хостам.
+
+// driftReconcileTick — шаг планировщика. Сверка машины случается не чаще её
+// CheckEvery; тик лишь определяет, насколько точно это «не чаще».
+const driftReconcileTick = 30 * time.Second
+
+// ErrDriftCheckBusy — сверка этой машины уже идёт (фоновая, ручная или после
+// обновления подписок): вторая параллельная только задеплоила бы дважды.
+var ErrDriftCheckBusy = errors.New("drift check for this machine is already running")
+
+// DriftReconciler — планировщик сверок и хранилище их последних отчётов.
+type DriftReconciler struct {
+	registry *RemoteRegistry
+	// EventBus — куда публиковать events.RemoteDriftChecked; nil — никуда.
+	EventBus events.Bus
+
+	mu      sync.Mutex
+	reports map[string]DriftReport
+	busy    map[string]bool
+	// held — машины, откатившие автоматический деплой: id → sha сборки.
+	// Ту же сборку автоматически больше не шлём — ядро на ней падает, и
+	// повтор на каждой сверке моргал бы VPN у всех за машиной. Снимается
+	// новой сборкой или ручным Deploy.
+	held map[string]string
+}
+
+// NewDriftReconciler — планировщик над реестром. Запуск — Run.
+func NewDriftReconciler(registry *RemoteRegistry) *DriftReconciler {
+	return &DriftReconciler{
+		registry: registry,
+		reports:  make(map[string]DriftReport),
+		busy:     make(map[string]bool),
+		held:     make(map[string]string),
+	}
+}
+
+// Run — цикл планировщика; возвращается, когда ctx отменён.
+func (c *DriftReconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(driftReconcileTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if platform.IsSleeping() {
+				continue
+			}
+			c.sweep()
+		}
+	}
+}
+
+// sweep запускает сверку машин, чей период истёк.
+func (c *DriftReconciler) sweep() {
+	list, err := c.registry.List()
+	if err != nil {
+		debuglog.WarnLog("remote drift: read registry: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, d := range list {
+		pol := d.Policy()
+		every := pol.Interval()
+		if every == 0 {
+			continue
+		}
+		if rep, ok := c.Report(d.ID); ok && now.Sub(rep.CheckedAt) < every {
+			continue
+		}
+		go func(id string, redeploy bool) {
+			if _, err := c.Reconcile(id, redeploy); err != nil && !errors.Is(err, ErrDriftCheckBusy) {
+				debuglog.DebugLog("remote drift: scheduled check of %q: %v", id, err)
+			}
+		}(d.ID, pol.AutoRedeploy)
+	}
+}
+
+// Reconcile сверяет машину сейчас и, если redeploy и машина не на собранном
+// конфиге, деплоит её заново (Deploy(id, nil) — со всеми его стражами) и
+// сверяет повторно: «доехало» подтверждает только хеш с машины.
+//
+// Блокирующий — звать из горутины. Повторный деплой пишется в журнал
+// действий на планировщик; у ручной сверки свой актор — ReconcileBy.
+func (c *DriftReconciler) Reconcile(id string, redeploy bool) (DriftReport, error) {
+	return c.ReconcileBy(audit.ActorScheduler, id, redeploy)
+}
+
+// ReconcileBy — Reconcile с актором журнала действий для повторного деплоя.
+func (c *DriftReconciler) ReconcileBy(actor audit.Actor, id string, redeploy bool) (DriftReport, error) {
+	if !c.acquire(id) {
+		return DriftReport{}, ErrDriftCheckBusy
+	}
+	defer c.release(id)
+
+	rep, err := c.registry.CheckDrift(id)
+	if err != nil {
+		c.forget(id)
+		return DriftReport{}, err
+	}
+
+	// Нет свежего прошлого отчёта — значит, после него был ручной Deploy.
+	prev, hadPrev := c.Report(id)
+	c.mu.Lock()
+	heldSHA, isHeld := c.held[id]
+	if isHeld && (!hadPrev || heldSHA != rep.BuiltSHA || rep.State == DriftInSync) {
+		delete(c.held, id)
+		isHeld = false
+	}
+	c.mu.Unlock()
+
+	// Прошлая сверка задеплоила эту же сборку, а машина снова на last-good —
+	// демон её откатил.
+	if hadPrev && prev.Redeployed && prev.BuiltSHA == rep.BuiltSHA && rep.RolledBack {
+		c.mu.Lock()
+		c.held[id] = rep.BuiltSHA
+		c.mu.Unlock()
+		isHeld = true
+	}
+
+	switch {
+	case !redeploy || !rep.NeedsDeploy():
+	case isHeld:
+		rep.RedeployErr = "automatic redeploy skipped: the machine rolled this config back after the previous one"
+	default:
+		if _, derr := c.registry.DeployBy(actor, id, nil); derr != nil {
+			debuglog.WarnLog("remote drift: redeploy %q: %v", id, derr)
+			rep.RedeployErr = derr.Error()
+			break
+		}
+		debuglog.InfoLog("remote drift: %q redeployed (was %s)", id, rep.State)
+		if again, cerr := c.registry.CheckDrift(id); cerr == nil {
+			rep = again
+		} else {
+			rep.CheckedAt = time.Now()
+		}
+		rep.Redeployed = true
+	}
+
+	c.mu.Lock()
+	c.reports[id] = rep
+	c.mu.Unlock()
+	c.publish(rep)
+	return rep, nil
+}
+
+// AfterRefresh — хук обновления подписок машины: по RedeployOnRefresh
+// сверить и задеплоить заново. Конфиг обновление подписок не пересобирает,
+// поэтому уезжает собранный config.json — тот, что машина должна исполнять.
+//
+// Возвращает отчёт и true, если политика хук включает.
+func (c *DriftReconciler) AfterRefresh(id string) (DriftReport, bool, error) {
+	d, ok, err := c.registry.Get(id)
+	if err != nil || !ok || !d.Policy().RedeployOnRefresh {
+		return DriftReport{}, false, err
+	}
+	rep, err := c.ReconcileBy(audit.ActorSystem, id, true)
+	return rep, true, err
+}
+
+// Report — последний отчёт о машине. Отчёт старше последнего Deploy
+// считается устаревшим: он про то, чего на машине уже нет.
+func (c *DriftReconciler) Report(id string) (DriftReport, bool) {
+	c.mu.Lock()
+	rep, ok := c.reports[id]
+	c.mu.Unlock()
+	if !ok {
+		return DriftReport{}, false
+	}
+	if d, found, err := c.registry.Get(id); err == nil && found && d.DeployedAt != "" {
+		if at, perr := time.Parse(time.RFC3339, d.DeployedAt); perr == nil && at.After(rep.CheckedAt) {
+			return DriftReport{}, false
+		}
+	}
+	return rep, true
+}
+
+func (c *DriftReconciler) acquire(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.busy[id] {
+		return false
+	}
+	c.busy[id] = true
+	return true
+}
+
+func (c *DriftReconciler) release(id string) {
+	c.mu.Lock()
+	delete(c.busy, id)
+	c.mu.Unlock()
+}
+
+// forget — машину сверить нельзя (удалена, конфиг не собран): старый отчёт
+// вводил бы в заблуждение.
+func (c *DriftReconciler) forget(id string) {
+	c.mu.Lock()
+	delete(c.reports, id)
+	delete(c.held, id)
+	c.mu.Unlock()
+}
+
+func (c *DriftReconciler) publish(rep DriftReport) {
+	if c.EventBus == nil {
+		return
+	}
+	p := events.RemoteDriftCheckedPayload{
+		MachineID:  rep.MachineID,
+		State:      string(rep.State),
+		Redeployed: rep.Redeployed,
+		Error:      rep.Err,
+	}
+	if rep.RedeployErr != "" {
+		p.Error = rep.RedeployErr
+	}
+	c.EventBus.Publish(events.Event{Kind: events.RemoteDriftChecked, Payload: p})
+}
@@ -16,6 +16,7 @@ import (
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/lxdclient"
 	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
 )
 
 // Реестр УДАЛЁННЫХ демонов `sing-box lxd` (SPEC 097).
@@ -66,6 +67,27 @@ type RemoteDaemon struct {
 	// сборка конфига не требовала живой сети — Configure должен работать и с
 	// выключенным роутером.
 	StateDir string `json:"state_dir,omitempty"`
+	// BaseProfile — имя базового профиля, от которого машина наследует
+	// настройки (lxd_remote_profiles.go). Пусто — состояние машины своё.
+	BaseProfile string `json:"base_profile,omitempty"`
+	// DeployedSHA / DeployedAt — sha256 конфига, отправленного последним
+	// Deploy, и когда (RFC3339). С ним CheckDrift сравнивает то, что на
+	// машине работает (lxd_remote_drift.go).
+	DeployedSHA string `json:"deployed_sha,omitempty"`
+	DeployedAt  string `json:"deployed_at,omitempty"`
+	// DeployPolicy — фоновая сверка и повторный деплой; nil — выключено.
+	DeployPolicy *DeployPolicy `json:"deploy_policy,omitempty"`
+	// ConnectRoute — через что звонить демону (lxd_remote_route.go); nil —
+	// напрямую на Addr.
+	ConnectRoute *RemoteRoute `json:"route,omitempty"`
+	// ClientName — имя нашего сертификата в списке клиентов демона
+	// (lxd_remote_certs.go). Пусто у записей, сопряжённых до ротации:
+	// тогда это singbox-launcher.
+	ClientName string `json:"client_name,omitempty"`
+	// CoreVersion — версия ядра, закреплённая за машиной при SSH-bootstrap
+	// (тег форка). Пусто — та, что пиннит сборка лаунчера
+	// (constants.RequiredCoreVersion): машина обновляется вместе с ним.
+	CoreVersion string `json:"core_version,omitempty"`
 	// AddedAt — когда сопряглись (RFC3339, для UI-списка).
 	AddedAt string `json:"added_at,omitempty"`
 }
@@ -73,6 +95,13 @@ type RemoteDaemon struct {
 // remoteRegistryFile — <bin>/remote-daemons.json.
 const remoteRegistryFile = "remote-daemons.json"
 
+// RemoteRegistryFileName — имя файла реестра в bin/ (для резервной копии,
+// core/backup).
+const RemoteRegistryFileName = remoteRegistryFile
+
+// RemoteIdentitiesDirName — <bin>/remote-daemons/<id>/: клиентские пары машин.
+const RemoteIdentitiesDirName = "remote-daemons"
+
 // RemoteRegistry — файловый реестр удалённых демонов.
 //
 // Файл читается/пишется целиком: записей единицы, а атомарная перезапись
@@ -96,7 +125,7 @@ func (r *RemoteRegistry) path() string {
 // устройства означал бы, что отзыв доступа на одном роутере отзывает его
 // везде.
 func (r *RemoteRegistry) identityDir(id string) string {
-	return filepath.Join(platform.GetBinDir(r.execDir), "remote-daemons", id)
+	return filepath.Join(platform.GetBinDir(r.execDir), RemoteIdentitiesDirName, id)
 }
 
 // List возвращает сохранённые подключения, отсортированные по имени.
@@ -119,6 +148,13 @@ func (r *RemoteRegistry) listLocked() ([]RemoteDaemon, error) {
 	if err := json.Unmarshal(raw, &out); err != nil {
 		return nil, fmt.Errorf("remote registry: parse %s: %w", r.path(), err)
 	}
+	for i := range out {
+		secret, err := secretstore.Open(out[i].Secret)
+		if err != nil {
+			return nil, fmt.Errorf("remote registry: %w", err)
+		}
+		out[i].Secret = secret
+	}
 	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
 	return out, nil
 }
@@ -128,7 +164,18 @@ func (r *RemoteRegistry) saveLocked(list []RemoteDaemon) error {
 	if err := os.MkdirAll(binDir, platform.DefaultDirMode); err != nil {
 		return fmt.Errorf("remote registry: mkdir: %w", err)
 	}
-	raw, err := json.MarshalIndent(list, "", "  ")
+	// Секреты — запечатанными (internal/secretstore), в копии: list дальше
+	// живёт у вызывающего.
+	sealed := make([]RemoteDaemon, len(list))
+	copy(sealed, list)
+	for i := range sealed {
+		secret, err := secretstore.Seal(sealed[i].Secret)
+		if err != nil {
+			return fmt.Errorf("remote registry: %w", err)
+		}
+		sealed[i].Secret = secret
+	}
+	raw, err := json.MarshalIndent(sealed, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -144,6 +191,24 @@ func (r *RemoteRegistry) saveLocked(list []RemoteDaemon) error {
 	return nil
 }
 
+// ResealSecrets перечитывает реестр, вызывает change (смена ключа
+// шифрования секретов) и записывает реестр заново под тем же мьютексом.
+func (r *RemoteRegistry) ResealSecrets(change func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	if list == nil {
+		return nil
+	}
+	return r.saveLocked(list)
+}
+
 // Get возвращает запись по ID.
 func (r *RemoteRegistry) Get(id string) (RemoteDaemon, bool, error) {
 	list, err := r.List()
@@ -179,10 +244,20 @@ func (r *RemoteRegistry) Pair(inviteRaw, name, secret string) (RemoteDaemon, err
 // а работать по другому — верный способ получить «сопряглись, но не
 // подключается».
 func (r *RemoteRegistry) PairWithAddr(inviteRaw, name, addr, secret string) (RemoteDaemon, error) {
+	return r.PairVia(inviteRaw, name, addr, secret, RemoteRoute{})
+}
+
+// PairVia — PairWithAddr через маршрут (lxd_remote_route.go): машина за NAT
+// недоступна напрямую уже на enroll. Маршрут сохраняется в записи.
+func (r *RemoteRegistry) PairVia(inviteRaw, name, addr, secret string, route RemoteRoute) (RemoteDaemon, error) {
 	invite, err := lxdclient.ParseInvite(inviteRaw)
 	if err != nil {
 		return RemoteDaemon{}, err
 	}
+	dialer, err := r.routeDialer(route)
+	if err != nil {
+		return RemoteDaemon{}, fmt.Errorf("remote pair: route: %w", err)
+	}
 	if a := strings.TrimSpace(addr); a != "" {
 		if _, _, splitErr := net.SplitHostPort(a); splitErr != nil {
 			return RemoteDaemon{}, fmt.Errorf("address %q is not a valid host:port: %w", a, splitErr)
@@ -207,8 +282,10 @@ func (r *RemoteRegistry) PairWithAddr(inviteRaw, name, addr, secret string) (Rem
 		ServerFingerprint: invite.ServerFingerprint,
 		Identity:          identity,
 		Secret:            secret,
+		Dialer:            dialer,
 	})
-	if err := client.Enroll(invite.Code, "singbox-launcher"); err != nil {
+	clientName := launcherClientName(identity.Fingerprint)
+	if err := client.Enroll(invite.Code, clientName); err != nil {
 		// Ключи оставляем: повторная попытка с новым кодом переиспользует их,
 		// и на демоне не появится второй мусорный клиентский сертификат.
 		return RemoteDaemon{}, fmt.Errorf("remote pair: enroll at %s: %w", invite.Addr, err)
@@ -220,8 +297,12 @@ func (r *RemoteRegistry) PairWithAddr(inviteRaw, name, addr, secret string) (Rem
 		Addr:              invite.Addr,
 		ServerFingerprint: invite.ServerFingerprint,
 		Secret:            secret,
+		ClientName:        clientName,
 		AddedAt:           time.Now().UTC().Format(time.RFC3339),
 	}
+	if !route.IsDirect() {
+		entry.ConnectRoute = &route
+	}
 	if entry.Name == "" {
 		entry.Name = invite.Addr
 	}
@@ -292,13 +373,21 @@ func (r *RemoteRegistry) RePair(id, inviteRaw, addr, secret string) (RemoteDaemo
 	if err != nil {
 		return RemoteDaemon{}, fmt.Errorf("remote repair: identity: %w", err)
 	}
+	// Маршрут записи сохраняется: машина за jump-хостом и перевыпуск
+	// проходит через него.
+	dialer, err := r.routeDialer(list[idx].Route())
+	if err != nil {
+		return RemoteDaemon{}, fmt.Errorf("remote repair: route: %w", err)
+	}
 	client := lxdclient.New(lxdclient.Config{
 		Addr:              invite.Addr,
 		ServerFingerprint: invite.ServerFingerprint,
 		Identity:          identity,
 		Secret:            secret,
+		Dialer:            dialer,
 	})
-	if err := client.Enroll(invite.Code, "singbox-launcher"); err != nil {
+	clientName := launcherClientName(identity.Fingerprint)
+	if err := client.Enroll(invite.Code, clientName); err != nil {
 		// Реестр не трогаем: код приглашения сгорел, но прежняя запись
 		// (со старым пином) остаётся ровно такой, какой была. Перезаписать её
 		// сейчас значило бы сломать ещё и то сопряжение, которое, может быть,
@@ -309,6 +398,7 @@ func (r *RemoteRegistry) RePair(id, inviteRaw, addr, secret string) (RemoteDaemo
 	list[idx].Addr = invite.Addr
 	list[idx].ServerFingerprint = invite.ServerFingerprint
 	list[idx].Secret = secret
+	list[idx].ClientName = clientName
 	// StateDir — свойство ТОЙ стороны, и после переустановки демона он мог
 	// переехать. Забываем: следующий Health перечитает его из /admin/info.
 	// Оставить прежний значило бы собирать конфиг с путями к ресурс-стору,
@@ -484,6 +574,34 @@ func (r *RemoteRegistry) SetAddr(id, addr string) error {
 	return fmt.Errorf("remote registry: unknown id %q", id)
 }
 
+// RestoreEntries кладёт записи из резервной копии (core/backup): запись с
+// тем же ID заменяется, остальные свои остаются. ID не меняются, поэтому
+// ключи и каталоги машин копия раскладывает по тем же путям сама.
+func (r *RemoteRegistry) RestoreEntries(entries []RemoteDaemon) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]int, len(list))
+	for i, d := range list {
+		byID[d.ID] = i
+	}
+	for _, e := range entries {
+		if strings.TrimSpace(e.ID) == "" {
+			return fmt.Errorf("remote registry: restored entry %q has no id", e.Name)
+		}
+		if i, ok := byID[e.ID]; ok {
+			list[i] = e
+			continue
+		}
+		byID[e.ID] = len(list)
+		list = append(list, e)
+	}
+	return r.saveLocked(list)
+}
+
 // Remove удаляет подключение, его клиентские ключи и всё её имущество:
 // состояние визарда, снапшоты, собранный конфиг, .srs и тела подписок
 // (SPEC 098 §3.1.9).
@@ -556,6 +674,32 @@ func (r *RemoteRegistry) SetPlatform(id, goos, goarch string) error {
 	return fmt.Errorf("remote registry: unknown id %q", id)
 }
 
+// SetCoreVersion закрепляет версию ядра за машиной; пусто или версия
+// сборки лаунчера — снимает закрепление.
+func (r *RemoteRegistry) SetCoreVersion(id, version string) error {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if version == constants.RequiredCoreVersion {
+		version = ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list, err := r.listLocked()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].ID != id {
+			continue
+		}
+		if list[i].CoreVersion == version {
+			return nil
+		}
+		list[i].CoreVersion = version
+		return r.saveLocked(list)
+	}
+	return fmt.Errorf("remote registry: unknown id %q", id)
+}
+
 // SetStateDir кеширует state-каталог демона, полученный из /admin/info.
 //
 // Тихо no-op при пустом значении и при совпадении: зовётся на каждом
@@ -713,6 +857,10 @@ type RemoteHealth struct {
 	// InterruptedApply — предыдущее применение конфига прервалось (демон
 	// упал или его убили в процессе). Ядро при этом работает на last-good.
 	InterruptedApply bool
+	// Route — каким путём шёл опрос (RemoteRoute.Describe): «direct»,
+	// «ssh user@host», «local core»… Заполнен и у недоступной машины —
+	// чтобы было видно, через что не достучались.
+	Route string
 }
 
 // Health опрашивает машину: статус ядра + паспорт демона.
@@ -720,15 +868,20 @@ type RemoteHealth struct {
 // Блокирующий вызов по сети — вызывающий обязан звать из горутины, иначе
 // недоступный роутер подвесит UI на таймаут REST-клиента.
 func (r *RemoteRegistry) Health(id string) RemoteHealth {
+	route := string(RouteDirect)
+	if entry, ok, _ := r.Get(id); ok {
+		route = entry.Route().Describe()
+	}
 	client, err := r.adminClient(id)
 	if err != nil {
-		return RemoteHealth{Err: err.Error()}
+		return RemoteHealth{Err: err.Error(), Route: route}
 	}
 	status, err := client.Status()
 	if err != nil {
-		return RemoteHealth{Err: err.Error()}
+		return RemoteHealth{Err: err.Error(), Route: route}
 	}
 	out := RemoteHealth{
+		Route:            route,
 		Reachable:        true,
 		CoreStatus:       status.Status,
 		LastError:        status.LastError,
@@ -780,6 +933,16 @@ func (r *RemoteRegistry) StopCore(id string) error {
 	return nil
 }
 
+// RestartCore — Stop, затем Start одним действием (кнопка ↻ строки и шаг
+// restart прогона по парку). Между ними VPN у всех за машиной моргает, а
+// неудачный Start оставляет её без ядра — вызывающий спрашивает подтверждение.
+func (r *RemoteRegistry) RestartCore(id string) error {
+	if err := r.StopCore(id); err != nil {
+		return err
+	}
+	return r.StartCore(id)
+}
+
 // SyncResources заливает на машину rule-set'ы, на которые ссылается её
 // конфиг (SPEC 063 форка ядра).
 //
@@ -826,37 +989,31 @@ func (r *RemoteRegistry) ApplyConfig(id string, config []byte) error {
 
 // adminClient собирает REST-клиента к записи реестра (адрес + пин + ключ).
 func (r *RemoteRegistry) adminClient(id string) (*lxdclient.Client, error) {
-	entry, ok, err := r.Get(id)
+	cfg, err := r.clientConfig(id)
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("remote registry: unknown id %q", id)
-	}
-	cfg := lxdclient.Config{
-		Addr:              entry.Addr,
-		ServerFingerprint: entry.ServerFingerprint,
-		Secret:            entry.Secret,
-	}
-	if cfg.TLSEnabled() {
-		identity, idErr := lxdclient.LoadOrCreateIdentity(r.identityDir(id))
-		if idErr != nil {
-			return nil, fmt.Errorf("remote registry: identity for %q: %w", id, idErr)
-		}
-		cfg.Identity = identity
-	}
 	return lxdclient.New(cfg), nil
 }
 
 // Transport строит ProxyTransport к сохранённому подключению.
 // Вызывающий отвечает за Close, когда транспорт больше не нужен.
 func (r *RemoteRegistry) Transport(id string) (*LxdRemoteTransport, error) {
-	entry, ok, err := r.Get(id)
+	cfg, err := r.clientConfig(id)
 	if err != nil {
 		return nil, err
 	}
+	return NewLxdRemoteTransport(cfg), nil
+}
+
+// clientConfig — адрес, пин, ключ и маршрут записи реестра.
+func (r *RemoteRegistry) clientConfig(id string) (lxdclient.Config, error) {
+	entry, ok, err := r.Get(id)
+	if err != nil {
+		return lxdclient.Config{}, err
+	}
 	if !ok {
-		return nil, fmt.Errorf("remote registry: unknown id %q", id)
+		return lxdclient.Config{}, fmt.Errorf("remote registry: unknown id %q", id)
 	}
 	cfg := lxdclient.Config{
 		Addr:              entry.Addr,
@@ -866,13 +1023,16 @@ func (r *RemoteRegistry) Transport(id string) (*LxdRemoteTransport, error) {
 	// Identity нужна только TLS-каналу; plain-h2c демон (dev на loopback)
 	// авторизует Bearer-секретом.
 	if cfg.TLSEnabled() {
-		identity, err := lxdclient.LoadOrCreateIdentity(r.identityDir(id))
-		if err != nil {
-			return nil, fmt.Errorf("remote registry: identity for %q: %w", id, err)
+		identity, idErr := lxdclient.LoadOrCreateIdentity(r.identityDir(id))
+		if idErr != nil {
+			return lxdclient.Config{}, fmt.Errorf("remote registry: identity for %q: %w", id, idErr)
 		}
 		cfg.Identity = identity
 	}
-	return NewLxdRemoteTransport(cfg), nil
+	if cfg.Dialer, err = r.routeDialer(entry.Route()); err != nil {
+		return lxdclient.Config{}, fmt.Errorf("remote registry: route for %q: %w", id, err)
+	}
+	return cfg, nil
 }
 
 // uniqueRemoteID делает slug из имени (или адреса) и разводит коллизии
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"singbox-launcher/internal/platform"
+)
+
+// routeLAN — адрес машины, который имеет смысл только с дальнего конца
+// маршрута: локально он не резолвится, и напрямую её не достать.
+const routeLAN = "router.lan.invalid:19091"
+
+// hideBehindRoute прячет единственную машину парка за routeLAN и
+// возвращает её настоящий адрес — его знают только фейковые прокси.
+func hideBehindRoute(t *testing.T, r *RemoteRegistry) string {
+	t.Helper()
+	m, _, _ := r.Get("m0")
+	if err := r.SetAddr("m0", routeLAN); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Health("m0"); h.Reachable || h.Route != "direct" {
+		t.Fatalf("direct health of a hidden machine: %+v", h)
+	}
+	return m.Addr
+}
+
+// fakeProxy запоминает, куда его просили соединить.
+type fakeProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *fakeProxy) record(target string) {
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+}
+
+func (p *fakeProxy) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// startFakeSOCKS5 — SOCKS5 (RFC 1928/1929) с логином, если user не пуст.
+// Знает только routeLAN.
+func startFakeSOCKS5(t *testing.T, real, user, pass string) (string, *fakeProxy) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	p := &fakeProxy{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serveSOCKS5(conn, real, user, pass)
+		}
+	}()
+	return ln.Addr().String(), p
+}
+
+func (p *fakeProxy) serveSOCKS5(conn net.Conn, real, user, pass string) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(br, make([]byte, hdr[1])); err != nil {
+		return
+	}
+	if user == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+		readField := func() string {
+			n, _ := br.ReadByte()
+			b := make([]byte, n)
+			_, _ = io.ReadFull(br, b)
+			return string(b)
+		}
+		_, _ = br.ReadByte()
+		if readField() != user || readField() != pass {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(br, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		b := make([]byte, 4)
+		_, _ = io.ReadFull(br, b)
+		host = net.IP(b).String()
+	case 3:
+		n, _ := br.ReadByte()
+		b := make([]byte, n)
+		_, _ = io.ReadFull(br, b)
+		host = string(b)
+	default:
+		return
+	}
+	portB := make([]byte, 2)
+	_, _ = io.ReadFull(br, portB)
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portB))))
+	p.record(target)
+	if target != routeLAN {
+		_, _ = conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	up, err := net.Dial("tcp", real)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer up.Close()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go func() { _, _ = io.Copy(up, br) }()
+	_, _ = io.Copy(conn, up)
+}
+
+// startFakeHTTPProxy — HTTP-прокси с одним CONNECT до routeLAN и Basic-учёткой.
+func startFakeHTTPProxy(t *testing.T, real, auth string) (string, *fakeProxy) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProxy{}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p.record(req.Host)
+		if req.Method != http.MethodConnect || req.Host != routeLAN {
+			http.Error(w, "no", http.StatusBadGateway)
+			return
+		}
+		if req.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		up, err := net.Dial("tcp", real)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer up.Close()
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() { _, _ = io.Copy(up, brw) }()
+		_, _ = io.Copy(conn, up)
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String(), p
+}
+
+func TestRouteSOCKS5AndHTTPProxy(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}})
+	real := hideBehindRoute(t, r)
+
+	socks, socksSeen := startFakeSOCKS5(t, real, "u", "p@ss")
+	if err := r.SetRoute("m0", RemoteRoute{Kind: RouteSOCKS5, ProxyURL: "socks5://u:p%40ss@" + socks}); err != nil {
+		t.Fatal(err)
+	}
+	h := r.Health("m0")
+	if !h.Reachable || h.Route != "socks5 "+socks {
+		t.Fatalf("socks5 health: %+v", h)
+	}
+	// Имя ушло прокси как есть — резолвит дальний конец.
+	if got := socksSeen.seen(); len(got) == 0 || got[0] != routeLAN {
+		t.Errorf("socks5 targets = %v, want %s", got, routeLAN)
+	}
+
+	httpAddr, httpSeen := startFakeHTTPProxy(t, real, "Basic dTpw")
+	if err := r.SetRoute("m0", RemoteRoute{Kind: RouteHTTP, ProxyURL: "http://u:p@" + httpAddr}); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Health("m0"); !h.Reachable || h.Route != "http "+httpAddr {
+		t.Fatalf("http health: %+v", h)
+	}
+	if len(httpSeen.seen()) == 0 {
+		t.Error("http proxy was not used")
+	}
+
+	// Без учётки прокси отказывает — и ошибка говорит, какой путь не прошёл.
+	if err := r.SetRoute("m0", RemoteRoute{Kind: RouteHTTP, ProxyURL: httpAddr}); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Health("m0"); h.Reachable || !strings.Contains(h.Err, "via http "+httpAddr) {
+		t.Errorf("http without auth: %+v", h)
+	}
+
+	if err := r.SetRoute("m0", RemoteRoute{Kind: RouteDirect}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, _ := r.Get("m0"); m.ConnectRoute != nil {
+		t.Errorf("direct route must clear the field, got %+v", m.ConnectRoute)
+	}
+}
+
+func TestRouteThroughLocalCore(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}})
+	real := hideBehindRoute(t, r)
+	socks, seen := startFakeSOCKS5(t, real, "launcher", "s3cret")
+	_, port, _ := net.SplitHostPort(socks)
+
+	if err := r.SetRoute("m0", RemoteRoute{Kind: RouteCore}); err != nil {
+		t.Fatal(err)
+	}
+	// Ядро не настроено — внятная ошибка, а не попытка напрямую.
+	if h := r.Health("m0"); h.Reachable || h.Route != "local core" || !strings.Contains(h.Err, "local core") {
+		t.Fatalf("no local config: %+v", h)
+	}
+
+	cfg := `{
+  // proxy-in из шаблона; listen 0.0.0.0 — для LAN, лаунчер ходит на loopback
+  "inbounds": [
+    {"type": "tun", "tag": "tun-in"},
+    {"type": "mixed", "tag": "proxy-in", "listen": "0.0.0.0", "listen_port": ` + port + `,
+     "users": [{"username": "launcher", "password": "s3cret"}]}
+  ]
+}`
+	path := platform.GetConfigPath(r.execDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Health("m0"); !h.Reachable {
+		t.Fatalf("local core health: %+v", h)
+	}
+	if got := seen.seen(); len(got) == 0 || got[len(got)-1] != routeLAN {
+		t.Errorf("core route targets = %v", got)
+	}
+}
+
+func TestRouteSSHJump(t *testing.T) {
+	r := seedFleet(t, []*fleetDaemon{{}})
+	real := hideBehindRoute(t, r)
+
+	keyFile, clientPub := writeClientKey(t)
+	jump := &fakeMachine{lan: map[string]string{routeLAN: real}}
+	sshAddr, hostKey := startFakeSSHD(t, clientPub, jump)
+	rt := RemoteRoute{
+		Kind:       RouteSSH,
+		SSHHost:    sshAddr,
+		SSHUser:    "root",
+		SSHKeyFile: keyFile,
+		SSHHostKey: ssh.FingerprintSHA256(hostKey),
+	}
+	if err := r.SetRoute("m0", rt); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if c := sshJumps.clients[rt]; c != nil {
+			sshJumps.drop(rt, c)
+		}
+	})
+	for i := 0; i < 2; i++ {
+		if h := r.Health("m0"); !h.Reachable || !strings.HasPrefix(h.Route, "ssh root@") {
+			t.Fatalf("ssh health #%d: %+v", i, h)
+		}
+	}
+
+	// Цель, которой jump-хост не знает: отказ канала, а соединение с
+	// jump-хостом живо и остаётся в кеше.
+	c := sshJumps.clients[rt]
+	if err := r.SetAddr("m0", "nowhere.invalid:1"); err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Health("m0"); h.Reachable || !strings.Contains(h.Err, "via ssh") {
+		t.Fatalf("unknown target: %+v", h)
+	}
+	if sshJumps.clients[rt] != c {
+		t.Error("channel refusal must not drop the jump connection")
+	}
+}
+
+func TestValidateRemoteRoute(t *testing.T) {
+	bad := []RemoteRoute{
+		{Kind: "vpn"},
+		{Kind: RouteSSH, SSHUser: "root", SSHUseAgent: true},
+		{Kind: RouteSSH, SSHHost: "jump", SSHUseAgent: true},
+		{Kind: RouteSSH, SSHHost: "jump", SSHUser: "root"},
+		{Kind: RouteSOCKS5},
+		{Kind: RouteSOCKS5, ProxyURL: "http://proxy:3128"},
+		{Kind: RouteHTTP, ProxyURL: "proxy"},
+	}
+	for _, rt := range bad {
+		if err := ValidateRemoteRoute(rt); err == nil {
+			t.Errorf("ValidateRemoteRoute(%+v) accepted", rt)
+		}
+	}
+	good := []RemoteRoute{
+		{},
+		{Kind: RouteCore},
+		{Kind: RouteSSH, SSHHost: "jump:2222", SSHUser: "root", SSHUseAgent: true},
+		{Kind: RouteSOCKS5, ProxyURL: "127.0.0.1:1080"},
+		{Kind: RouteSOCKS5, ProxyURL: "socks5h://u:p@proxy:1080"},
+		{Kind: RouteHTTP, ProxyURL: "http://proxy:3128"},
+	}
+	for _, rt := range good {
+		if err := ValidateRemoteRoute(rt); err != nil {
+			t.Errorf("ValidateRemoteRoute(%+v): %v", rt, err)
+		}
+	}
+}
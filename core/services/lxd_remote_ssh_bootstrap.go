@@ -0,0 +1,604 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/lxdclient"
+)
+
+// Сопряжение с машиной через SSH (bootstrap).
+//
+// Обычный путь — пользователь сам ставит демон, запускает на машине
+// `sing-box lxd client add` и вставляет в лаунчер напечатанное приглашение.
+// Для VPS, куда у пользователя и так есть SSH, это три лишних ручных шага с
+// копированием длинной строки. Здесь лаунчер делает их сам:
+//
+//  1. входит по SSH (ключ и/или ssh-agent), ключ хоста сверяется с
+//     known_hosts или с явным пином — вслепую не доверяем;
+//  2. определяет платформу (`uname -sm`) и проверяет ядро в
+//     /usr/local/lib/sing-box-lxd — нужной версии форка оно или нет; нет —
+//     заливает сборку под GOOS/GOARCH машины (CoreBinaryFetcher);
+//  3. ставит systemd-службу `sing-box lxd` с TLS — та же раскладка, что у
+//     system-службы на Linux (core/daemon_manager_linux.go), только listen
+//     на всех интерфейсах: машина удалённая;
+//  4. берёт приглашение `lxd client add` и сопрягается (PairWithAddr) по
+//     адресу, по которому мы до машины уже достучались.
+//
+// Секрет демона рождается на машине и её не покидает: при TLS мандатом
+// служит клиентский сертификат. Привилегии — root или `sudo -n` (пароль
+// sudo лаунчер не спрашивает и не хранит). Открыть порт в файрволе машины —
+// забота пользователя: bootstrap об этом лишь сообщает ошибкой enroll.
+
+// Раскладка службы на машине — как у system-службы лаунчера на Linux.
+const (
+	sshBootstrapUnitName = "sing-box-lxd.service"
+	sshBootstrapUnitPath = "/etc/systemd/system/" + sshBootstrapUnitName
+	sshBootstrapStateDir = "/var/lib/sing-box-lxd"
+	sshBootstrapBinary   = "/usr/local/lib/sing-box-lxd/sing-box"
+
+	// SSHBootstrapDefaultPort — порт демона, если не задан: первый порт
+	// диапазона, который занимает и локальный установщик.
+	SSHBootstrapDefaultPort = 19091
+
+	sshBootstrapDialTimeout = 15 * time.Second
+)
+
+// Шаги bootstrap для прогресса (locale remote.ssh.step_<шаг>).
+const (
+	SSHStepConnect = "connect"
+	SSHStepDetect  = "detect"
+	SSHStepCore    = "core"
+	SSHStepUpload  = "upload"
+	SSHStepDaemon  = "daemon"
+	SSHStepPair    = "pair"
+)
+
+// SSHBootstrapRequest — куда и как войти и что поставить.
+type SSHBootstrapRequest struct {
+	// Host — host или host:port SSH (порт по умолчанию 22).
+	Host string
+	// User — пользователь SSH: root или с `sudo` без пароля.
+	User string
+	// KeyFile / KeyPassphrase — приватный ключ (OpenSSH/PEM). Пусто —
+	// только агент.
+	KeyFile       string
+	KeyPassphrase string
+	// UseAgent — пробовать ключи ssh-agent (SSH_AUTH_SOCK).
+	UseAgent bool
+	// HostKeySHA256 — пин ключа хоста (`SHA256:…`, как печатает
+	// ssh-keygen -lf). Пусто — проверка по KnownHostsFile.
+	HostKeySHA256 string
+	// KnownHostsFile — пусто = ~/.ssh/known_hosts.
+	KnownHostsFile string
+	// Name — имя машины в реестре; пусто — адрес.
+	Name string
+	// ListenPort — порт демона; 0 — SSHBootstrapDefaultPort. У уже
+	// установленного демона остаётся его daemon.json (и его порт).
+	ListenPort int
+	// Addr — адрес подключения к демону; пусто — SSH-хост и порт из
+	// приглашения.
+	Addr string
+	// CoreVersion — версия ядра для машины (тег форка без "v"); пусто —
+	// constants.RequiredCoreVersion. Запоминается в RemoteDaemon.CoreVersion.
+	CoreVersion string
+}
+
+// CoreBinaryFetcher отдаёт бинарь ядра version ("" —
+// constants.RequiredCoreVersion) под платформу машины. Живёт в core
+// (загрузчик ядра); nil — ядро на машине должно уже стоять.
+type CoreBinaryFetcher func(ctx context.Context, version, goos, goarch string) ([]byte, error)
+
+// SSHProvision — что bootstrap сделал на машине до сопряжения.
+type SSHProvision struct {
+	// Invite — приглашение, выданное демоном; Addr — по нему сопрягаемся.
+	Invite string
+	Addr   string
+	GOOS   string
+	GOARCH string
+	// CoreVersion — строка `sing-box version` установленного ядра;
+	// CoreUploaded — ядро залили в этот раз.
+	CoreVersion  string
+	CoreUploaded bool
+}
+
+// SSHBootstrapResult — итог BootstrapSSH.
+type SSHBootstrapResult struct {
+	SSHProvision
+	Machine RemoteDaemon
+}
+
+// SSHHostKeyError — ключ хоста не подтверждён: хоста нет в known_hosts
+// (Mismatch=false) или ключ не тот (Mismatch=true — возможна подмена).
+// Fingerprint — что предъявил сервер; пользователь сверяет его и передаёт
+// пином в HostKeySHA256.
+type SSHHostKeyError struct {
+	Host        string
+	Fingerprint string
+	Mismatch    bool
+}
+
+func (e *SSHHostKeyError) Error() string {
+	if e.Mismatch {
+		return fmt.Sprintf("ssh host key of %s does not match the known one (server presented %s)", e.Host, e.Fingerprint)
+	}
+	return fmt.Sprintf("ssh host key of %s is not known; verify and pin it: %s", e.Host, e.Fingerprint)
+}
+
+// BootstrapSSH ставит и запускает демон на машине по SSH и сопрягается с
+// ним. progress (может быть nil) получает шаги SSHStep*.
+//
+// Блокирующий (сеть, возможно скачивание ядра) — звать из горутины.
+func (r *RemoteRegistry) BootstrapSSH(ctx context.Context, req SSHBootstrapRequest, fetch CoreBinaryFetcher, progress func(step string)) (SSHBootstrapResult, error) {
+	prov, err := ProvisionOverSSH(ctx, req, fetch, progress)
+	if err != nil {
+		return SSHBootstrapResult{}, err
+	}
+	if progress != nil {
+		progress(SSHStepPair)
+	}
+	entry, err := r.PairWithAddr(prov.Invite, req.Name, prov.Addr, "")
+	if err != nil {
+		return SSHBootstrapResult{SSHProvision: prov}, err
+	}
+	if err := r.SetPlatform(entry.ID, prov.GOOS, prov.GOARCH); err != nil {
+		debuglog.WarnLog("ssh bootstrap: set platform for %q: %v", entry.ID, err)
+	}
+	// state-dir известен заранее — генерации не придётся ждать /admin/info.
+	if err := r.SetStateDir(entry.ID, sshBootstrapStateDir); err != nil {
+		debuglog.WarnLog("ssh bootstrap: set state dir for %q: %v", entry.ID, err)
+	}
+	if err := r.SetCoreVersion(entry.ID, req.CoreVersion); err != nil {
+		debuglog.WarnLog("ssh bootstrap: set core version for %q: %v", entry.ID, err)
+	}
+	if d, ok, _ := r.Get(entry.ID); ok {
+		entry = d
+	}
+	return SSHBootstrapResult{SSHProvision: prov, Machine: entry}, nil
+}
+
+// ProvisionOverSSH — шаги 1–3 bootstrap: на выходе приглашение и адрес, но
+// в реестр ничего не записано.
+func ProvisionOverSSH(ctx context.Context, req SSHBootstrapRequest, fetch CoreBinaryFetcher, progress func(step string)) (SSHProvision, error) {
+	step := func(s string) {
+		if progress != nil {
+			progress(s)
+		}
+	}
+	sshHost, sshAddr := sshTarget(req.Host)
+	if sshHost == "" {
+		return SSHProvision{}, fmt.Errorf("ssh bootstrap: host is empty")
+	}
+	if strings.TrimSpace(req.User) == "" {
+		return SSHProvision{}, fmt.Errorf("ssh bootstrap: user is empty")
+	}
+	port := req.ListenPort
+	if port == 0 {
+		port = SSHBootstrapDefaultPort
+	}
+	if port < 1 || port > 65535 {
+		return SSHProvision{}, fmt.Errorf("ssh bootstrap: listen port %d is out of range", port)
+	}
+	if a := strings.TrimSpace(req.Addr); a != "" {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			return SSHProvision{}, fmt.Errorf("address %q is not a valid host:port: %w", a, err)
+		}
+	}
+
+	step(SSHStepConnect)
+	auth, closeAuth, err := sshAuthMethods(req)
+	if err != nil {
+		return SSHProvision{}, err
+	}
+	defer closeAuth()
+	hostKey, err := sshHostKeyCallback(req)
+	if err != nil {
+		return SSHProvision{}, err
+	}
+	client, err := sshDial(ctx, sshAddr, &ssh.ClientConfig{
+		User:            strings.TrimSpace(req.User),
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         sshBootstrapDialTimeout,
+	})
+	if err != nil {
+		return SSHProvision{}, err
+	}
+	defer client.Close()
+	// Отмена ctx рвёт соединение — висящая команда вернётся с ошибкой.
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
+
+	step(SSHStepDetect)
+	uid, err := sshRun(client, "id -u", nil)
+	if err != nil {
+		return SSHProvision{}, err
+	}
+	sudo := ""
+	if strings.TrimSpace(uid) != "0" {
+		// -n: без пароля или сразу ошибка — спросить пароль нам негде.
+		sudo = "sudo -n "
+		if _, err := sshRun(client, "sudo -n true", nil); err != nil {
+			return SSHProvision{}, fmt.Errorf("ssh bootstrap: %s is not root and sudo needs a password (configure NOPASSWD or log in as root): %w", req.User, err)
+		}
+	}
+	uname, err := sshRun(client, "uname -sm", nil)
+	if err != nil {
+		return SSHProvision{}, err
+	}
+	goos, goarch, err := unamePlatform(uname)
+	if err != nil {
+		return SSHProvision{}, err
+	}
+	if goos != "linux" {
+		return SSHProvision{}, fmt.Errorf("ssh bootstrap: %s runs %s; only Linux machines with systemd are supported", sshHost, goos)
+	}
+	prov := SSHProvision{GOOS: goos, GOARCH: goarch}
+
+	step(SSHStepCore)
+	want := req.CoreVersion
+	if want == "" {
+		want = constants.RequiredCoreVersion
+	}
+	prov.CoreVersion = coreVersionOver(client)
+	if !coreVersionIs(prov.CoreVersion, want) {
+		if fetch == nil {
+			return prov, fmt.Errorf("ssh bootstrap: sing-box %s is not installed at %s and no downloader is available", want, sshBootstrapBinary)
+		}
+		step(SSHStepUpload)
+		bin, err := fetch(ctx, req.CoreVersion, goos, goarch)
+		if err != nil {
+			return prov, fmt.Errorf("ssh bootstrap: download core for %s/%s: %w", goos, goarch, err)
+		}
+		if _, err := sshRun(client, sudo+"sh -c "+sshQuote(sshUploadCommand), bytes.NewReader(bin)); err != nil {
+			return prov, fmt.Errorf("ssh bootstrap: upload core: %w", err)
+		}
+		prov.CoreUploaded = true
+		prov.CoreVersion = coreVersionOver(client)
+		if !coreVersionIs(prov.CoreVersion, want) {
+			return prov, fmt.Errorf("ssh bootstrap: uploaded core does not run on %s/%s (version output %q)", goos, goarch, prov.CoreVersion)
+		}
+		debuglog.InfoLog("ssh bootstrap: uploaded sing-box %s to %s (%s/%s)", want, sshHost, goos, goarch)
+	}
+
+	step(SSHStepDaemon)
+	out, err := sshRun(client, sudo+"sh -s", strings.NewReader(sshBootstrapScript(port, prov.CoreUploaded)))
+	if err != nil {
+		return prov, fmt.Errorf("ssh bootstrap: install daemon: %w", err)
+	}
+	invite, err := lastInvite(out)
+	if err != nil {
+		return prov, fmt.Errorf("ssh bootstrap: %w", err)
+	}
+	prov.Invite = invite
+	prov.Addr, err = bootstrapPairAddr(invite, sshHost, req.Addr)
+	if err != nil {
+		return prov, err
+	}
+	debuglog.InfoLog("ssh bootstrap: daemon on %s is up, pairing at %s", sshHost, prov.Addr)
+	return prov, nil
+}
+
+// sshTarget — хост (для адреса демона) и host:port SSH.
+func sshTarget(raw string) (host, addr string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ""
+	}
+	if h, p, err := net.SplitHostPort(raw); err == nil {
+		return h, net.JoinHostPort(h, p)
+	}
+	raw = strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+	return raw, net.JoinHostPort(raw, "22")
+}
+
+// sshAuthMethods — ключ из файла и/или агент. close закрывает соединение
+// с агентом (держим его до конца bootstrap: подписи идут при handshake).
+func sshAuthMethods(req SSHBootstrapRequest) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closeFn := func() {}
+	if path := strings.TrimSpace(req.KeyFile); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, closeFn, fmt.Errorf("ssh bootstrap: read key: %w", err)
+		}
+		var signer ssh.Signer
+		if req.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(raw, []byte(req.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(raw)
+		}
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, closeFn, fmt.Errorf("ssh bootstrap: key %s is encrypted; give its passphrase or use ssh-agent", path)
+		}
+		if err != nil {
+			return nil, closeFn, fmt.Errorf("ssh bootstrap: parse key %s: %w", path, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if req.UseAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			if len(methods) == 0 {
+				return nil, closeFn, fmt.Errorf("ssh bootstrap: ssh-agent is not running (SSH_AUTH_SOCK is empty)")
+			}
+		} else {
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				if len(methods) == 0 {
+					return nil, closeFn, fmt.Errorf("ssh bootstrap: connect to ssh-agent: %w", err)
+				}
+				debuglog.WarnLog("ssh bootstrap: ssh-agent unavailable, using the key file only: %v", err)
+			} else {
+				closeFn = func() { _ = conn.Close() }
+				methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			}
+		}
+	}
+	if len(methods) == 0 {
+		return nil, closeFn, fmt.Errorf("ssh bootstrap: no credentials: give a key file or enable ssh-agent")
+	}
+	return methods, closeFn, nil
+}
+
+// sshHostKeyCallback — пин, если задан, иначе known_hosts. Неизвестный
+// хост — SSHHostKeyError с отпечатком, а не молчаливое доверие: на этот
+// хост дальше уезжает root-служба.
+func sshHostKeyCallback(req SSHBootstrapRequest) (ssh.HostKeyCallback, error) {
+	if pin := normalizeHostKeyPin(req.HostKeySHA256); pin != "" {
+		return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			if got := ssh.FingerprintSHA256(key); got != pin {
+				return &SSHHostKeyError{Host: hostname, Fingerprint: got, Mismatch: true}
+			}
+			return nil
+		}, nil
+	}
+	path := strings.TrimSpace(req.KnownHostsFile)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ssh bootstrap: home dir: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	var known ssh.HostKeyCallback
+	if _, err := os.Stat(path); err == nil {
+		if known, err = knownhosts.New(path); err != nil {
+			return nil, fmt.Errorf("ssh bootstrap: known_hosts: %w", err)
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if known == nil {
+			return &SSHHostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key)}
+		}
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			return &SSHHostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key), Mismatch: len(keyErr.Want) > 0}
+		}
+		return err
+	}, nil
+}
+
+// normalizeHostKeyPin приводит пин к виду ssh.FingerprintSHA256.
+func normalizeHostKeyPin(pin string) string {
+	pin = strings.TrimSpace(pin)
+	if pin == "" {
+		return ""
+	}
+	pin = strings.TrimRight(strings.TrimPrefix(pin, "SHA256:"), "=")
+	return "SHA256:" + pin
+}
+
+func sshDial(ctx context.Context, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	d := net.Dialer{Timeout: cfg.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh bootstrap: connect %s: %w", addr, err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		_ = conn.Close()
+		var hkErr *SSHHostKeyError
+		if errors.As(err, &hkErr) {
+			return nil, hkErr
+		}
+		return nil, fmt.Errorf("ssh bootstrap: ssh %s: %w", addr, err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// sshRun выполняет команду и возвращает stdout; в ошибке — stderr.
+func sshRun(client *ssh.Client, cmd string, stdin io.Reader) (string, error) {
+	sess, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("ssh bootstrap: session: %w", err)
+	}
+	defer sess.Close()
+	var stdout, stderr bytes.Buffer
+	sess.Stdout = &stdout
+	sess.Stderr = &stderr
+	if stdin != nil {
+		sess.Stdin = stdin
+	}
+	if err := sess.Run(cmd); err != nil {
+		name := strings.Fields(cmd)
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = lastLine(stdout.String())
+		}
+		return stdout.String(), fmt.Errorf("%s: %w: %s", strings.Join(name[:min(len(name), 3)], " "), err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// coreVersionOver — первая строка `sing-box version` на машине; пусто, если
+// ядра нет или оно не запускается.
+func coreVersionOver(client *ssh.Client) string {
+	out, err := sshRun(client, sshQuote(sshBootstrapBinary)+" version", nil)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(firstLine(out))
+}
+
+// coreVersionIs — первая строка `sing-box version` называет именно want.
+// Не подстрока: 1.14.0-lx.2 — не 1.14.0-lx.26.
+func coreVersionIs(versionLine, want string) bool {
+	f := strings.Fields(versionLine)
+	return len(f) >= 3 && f[0] == "sing-box" && f[1] == "version" && strings.TrimPrefix(f[2], "v") == want
+}
+
+// unamePlatform переводит `uname -sm` в GOOS/GOARCH.
+func unamePlatform(uname string) (goos, goarch string, err error) {
+	f := strings.Fields(uname)
+	if len(f) < 2 {
+		return "", "", fmt.Errorf("ssh bootstrap: unexpected uname output %q", strings.TrimSpace(uname))
+	}
+	goos = strings.ToLower(f[0])
+	switch m := strings.ToLower(f[len(f)-1]); {
+	case m == "x86_64" || m == "amd64":
+		goarch = "amd64"
+	case m == "aarch64" || m == "arm64":
+		goarch = "arm64"
+	case strings.HasPrefix(m, "armv7") || strings.HasPrefix(m, "armv6"):
+		goarch = "arm"
+	case m == "i386" || m == "i686":
+		goarch = "386"
+	default:
+		return "", "", fmt.Errorf("ssh bootstrap: unsupported machine architecture %q", m)
+	}
+	return goos, goarch, nil
+}
+
+// sshUploadCommand — приём бинаря из stdin: во временный файл рядом и
+// атомарный rename, чтобы работающая служба не увидела половину файла.
+var sshUploadCommand = fmt.Sprintf("mkdir -p %s && cat > %s && chmod 0755 %s && mv -f %s %s",
+	sshQuote(filepath.Dir(sshBootstrapBinary)),
+	sshQuote(sshBootstrapBinary+".new"), sshQuote(sshBootstrapBinary+".new"),
+	sshQuote(sshBootstrapBinary+".new"), sshQuote(sshBootstrapBinary))
+
+// sshBootstrapScript — установка службы на машине. Повторный прогон
+// безопасен: существующий daemon.json (адрес, секрет, клиенты рядом) не
+// трогается, unit перезаписывается, работающая служба перезапускается,
+// только если ядро обновили. Последняя строка вывода — приглашение.
+func sshBootstrapScript(port int, restart bool) string {
+	listen := net.JoinHostPort("0.0.0.0", strconv.Itoa(port))
+	stateDir := sshQuote(sshBootstrapStateDir)
+	daemonJSON := sshQuote(sshBootstrapStateDir + "/daemon.json")
+	var b strings.Builder
+	b.WriteString("set -eu\n")
+	b.WriteString("command -v systemctl >/dev/null 2>&1 || { echo 'systemd is required' >&2; exit 3; }\n")
+	fmt.Fprintf(&b, "mkdir -p %s\nchmod 0700 %s\n", stateDir, stateDir)
+	fmt.Fprintf(&b, "if [ ! -f %s ]; then\n", daemonJSON)
+	b.WriteString("\tsecret=$(od -An -N24 -tx1 /dev/urandom | tr -d ' \\n')\n")
+	fmt.Fprintf(&b, "\t(umask 077; printf '{\"listen\":\"%%s\",\"tls\":true,\"secret\":\"%%s\"}\\n' %s \"$secret\" > %s)\n",
+		sshQuote(listen), daemonJSON)
+	b.WriteString("fi\n")
+	fmt.Fprintf(&b, "cat > %s <<'SINGBOX_LXD_UNIT'\n%sSINGBOX_LXD_UNIT\n", sshQuote(sshBootstrapUnitPath), sshBootstrapUnit)
+	b.WriteString("systemctl daemon-reload\n")
+	fmt.Fprintf(&b, "systemctl enable %s\n", sshBootstrapUnitName)
+	if restart {
+		fmt.Fprintf(&b, "systemctl restart %s\n", sshBootstrapUnitName)
+	} else {
+		fmt.Fprintf(&b, "systemctl start %s\n", sshBootstrapUnitName)
+	}
+	b.WriteString("sleep 1\n")
+	fmt.Fprintf(&b, "%s lxd client add --state-dir %s --name singbox-launcher\n", sshQuote(sshBootstrapBinary), stateDir)
+	return b.String()
+}
+
+// sshBootstrapUnit — unit system-службы; совпадает с тем, что генерирует
+// лаунчер на Linux (systemdLayout.unit), кроме подписи.
+const sshBootstrapUnit = `# Generated by singbox-launcher (SSH bootstrap). Re-run the bootstrap instead of editing.
+[Unit]
+Description=sing-box lxd daemon (singbox-launcher)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart="` + sshBootstrapBinary + `" "lxd" "--state-dir" "` + sshBootstrapStateDir + `"
+Restart=on-failure
+RestartSec=2
+LimitNOFILE=1048576
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// lastInvite — последнее приглашение в выводе (перед ним могут быть
+// сообщения systemctl).
+func lastInvite(out string) (string, error) {
+	var found string
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if _, err := lxdclient.ParseInvite(line); err == nil {
+			found = line
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("daemon printed no invite (output: %q)", lastLine(out))
+	}
+	return found, nil
+}
+
+// bootstrapPairAddr — адрес, по которому сопрягаться. Демон печатает свой
+// listen-адрес: 0.0.0.0 в приглашении нерабочий, поэтому берём SSH-хост —
+// до него мы уже достучались. Loopback значит, что на машине остался
+// daemon.json локальной установки, и снаружи демон недоступен.
+func bootstrapPairAddr(inviteRaw, sshHost, override string) (string, error) {
+	if a := strings.TrimSpace(override); a != "" {
+		return a, nil
+	}
+	invite, err := lxdclient.ParseInvite(inviteRaw)
+	if err != nil {
+		return "", err
+	}
+	host, port, _ := net.SplitHostPort(invite.Addr)
+	if lxdclient.IsLoopbackAddr(invite.Addr) {
+		return "", fmt.Errorf("ssh bootstrap: the daemon on %s listens on loopback %s (existing %s/daemon.json); change its listen to 0.0.0.0:%s or give the address explicitly",
+			sshHost, invite.Addr, sshBootstrapStateDir, port)
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return net.JoinHostPort(sshHost, port), nil
+	}
+	return invite.Addr, nil
+}
+
+func sshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"singbox-launcher/internal/constants"
+)
+
+const fakeInviteFP = "3f2a9c0000000000000000000000000000000000000000000000000000009c01"
+
+// fakeMachine — VPS за тестовым sshd: исполняет ровно те команды, которые
+// шлёт bootstrap, и помнит, что ему залили.
+type fakeMachine struct {
+	mu     sync.Mutex
+	uid    string
+	core   string
+	listen string
+	cmds   []string
+	script string
+	// lan — адреса «за» этим хостом для direct-tcpip (jump-хост маршрута):
+	// имя, как его видит хост → настоящий адрес в тесте.
+	lan map[string]string
+}
+
+func (m *fakeMachine) exec(cmd string, stdin []byte) (string, string, uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cmds = append(m.cmds, cmd)
+	cmd = strings.TrimPrefix(cmd, "sudo -n ")
+	switch {
+	case cmd == "id -u":
+		return m.uid + "\n", "", 0
+	case cmd == "true":
+		return "", "", 0
+	case cmd == "uname -sm":
+		return "Linux aarch64\n", "", 0
+	case strings.HasSuffix(cmd, "/sing-box' version"):
+		if m.core == "" {
+			return "", "sh: sing-box: not found", 127
+		}
+		return "sing-box version " + m.core + "\n\nEnvironment: go1.25\n", "", 0
+	case strings.HasPrefix(cmd, "sh -c ") && strings.Contains(cmd, "cat >"):
+		m.core = string(stdin)
+		return "", "", 0
+	case cmd == "sh -s":
+		m.script = string(stdin)
+		return "Created symlink /etc/systemd/system/multi-user.target.wants/sing-box-lxd.service.\n" +
+			m.listen + "#" + fakeInviteFP + "#K7QM-XXNP\n", "", 0
+	}
+	return "", "unexpected command: " + cmd, 1
+}
+
+// startFakeSSHD поднимает sshd в процессе: ключ хоста свой, пускает только
+// clientKey. Возвращает адрес и публичный ключ хоста.
+func startFakeSSHD(t *testing.T, clientKey ssh.PublicKey, m *fakeMachine) (string, ssh.PublicKey) {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSSH(conn, cfg, m)
+		}
+	}()
+	return ln.Addr().String(), hostSigner.PublicKey()
+}
+
+func serveFakeSSH(conn net.Conn, cfg *ssh.ServerConfig, m *fakeMachine) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() == "direct-tcpip" {
+			go m.forward(nc)
+			continue
+		}
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				_ = ssh.Unmarshal(req.Payload, &payload)
+				_ = req.Reply(true, nil)
+				stdin, _ := io.ReadAll(ch)
+				stdout, stderr, code := m.exec(payload.Command, stdin)
+				_, _ = io.WriteString(ch, stdout)
+				_, _ = io.WriteString(ch.Stderr(), stderr)
+				_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{code}))
+				return
+			}
+		}()
+	}
+}
+
+// forward — `ssh -J`: канал до адреса из lan хоста.
+func (m *fakeMachine) forward(nc ssh.NewChannel) {
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	_ = ssh.Unmarshal(nc.ExtraData(), &target)
+	m.mu.Lock()
+	real, ok := m.lan[net.JoinHostPort(target.Host, fmt.Sprint(target.Port))]
+	m.mu.Unlock()
+	if !ok {
+		_ = nc.Reject(ssh.ConnectionFailed, "no route to host")
+		return
+	}
+	up, err := net.Dial("tcp", real)
+	if err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		_ = up.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(up, ch)
+		_ = up.Close()
+	}()
+	_, _ = io.Copy(ch, up)
+	_ = ch.Close()
+}
+
+// writeClientKey — ключ клиента в файле OpenSSH, как у пользователя в ~/.ssh.
+func writeClientKey(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, signer.PublicKey()
+}
+
+func writeKnownHosts(t *testing.T, addr string, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(knownhosts.Line([]string{addr}, key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProvisionOverSSHInstallsCoreAndDaemon(t *testing.T) {
+	keyFile, clientPub := writeClientKey(t)
+	m := &fakeMachine{uid: "0", listen: "0.0.0.0:19091"}
+	addr, hostPub := startFakeSSHD(t, clientPub, m)
+	req := SSHBootstrapRequest{
+		Host:           addr,
+		User:           "root",
+		KeyFile:        keyFile,
+		KnownHostsFile: writeKnownHosts(t, addr, hostPub),
+	}
+
+	var fetched []string
+	fetch := func(_ context.Context, _, goos, goarch string) ([]byte, error) {
+		fetched = append(fetched, goos+"/"+goarch)
+		return []byte(constants.RequiredCoreVersion), nil
+	}
+	var steps []string
+	prov, err := ProvisionOverSSH(context.Background(), req, fetch, func(s string) { steps = append(steps, s) })
+	if err != nil {
+		t.Fatalf("ProvisionOverSSH: %v", err)
+	}
+	if prov.GOOS != "linux" || prov.GOARCH != "arm64" || !prov.CoreUploaded {
+		t.Errorf("provision = %+v, want linux/arm64 with an uploaded core", prov)
+	}
+	if len(fetched) != 1 || fetched[0] != "linux/arm64" {
+		t.Errorf("fetched %v, want one linux/arm64 core", fetched)
+	}
+	// 0.0.0.0 из приглашения заменяется хостом, до которого дошёл SSH.
+	if prov.Addr != "127.0.0.1:19091" || !strings.HasPrefix(prov.Invite, "0.0.0.0:19091#") {
+		t.Errorf("addr %q invite %q", prov.Addr, prov.Invite)
+	}
+	if want := "connect detect core upload daemon"; strings.Join(steps, " ") != want {
+		t.Errorf("steps = %v, want %s", steps, want)
+	}
+	for _, want := range []string{`"listen":"%s","tls":true`, "'0.0.0.0:19091'", "systemctl restart sing-box-lxd.service", "lxd client add --state-dir '/var/lib/sing-box-lxd'"} {
+		if !strings.Contains(m.script, want) {
+			t.Errorf("install script lacks %q:\n%s", want, m.script)
+		}
+	}
+
+	// Второй прогон под обычным пользователем: ядро уже нужной версии —
+	// не качаем и не рестартуем службу, всё привилегированное через sudo -n.
+	m.mu.Lock()
+	m.uid, m.cmds = "1000", nil
+	m.mu.Unlock()
+	req.User = "deploy"
+	prov, err = ProvisionOverSSH(context.Background(), req, fetch, nil)
+	if err != nil {
+		t.Fatalf("second ProvisionOverSSH: %v", err)
+	}
+	if prov.CoreUploaded || len(fetched) != 1 {
+		t.Errorf("core re-uploaded: %+v, fetched %v", prov, fetched)
+	}
+	if !strings.Contains(m.script, "systemctl start sing-box-lxd.service") {
+		t.Errorf("unchanged core must not restart the service:\n%s", m.script)
+	}
+	m.mu.Lock()
+	if last := m.cmds[len(m.cmds)-1]; last != "sudo -n sh -s" {
+		t.Errorf("install ran as %q, want it under sudo -n", last)
+	}
+	m.mu.Unlock()
+}
+
+func TestProvisionOverSSHHostKeyVerification(t *testing.T) {
+	keyFile, clientPub := writeClientKey(t)
+	m := &fakeMachine{uid: "0", core: constants.RequiredCoreVersion, listen: "0.0.0.0:19091"}
+	addr, hostPub := startFakeSSHD(t, clientPub, m)
+	req := SSHBootstrapRequest{
+		Host:           addr,
+		User:           "root",
+		KeyFile:        keyFile,
+		KnownHostsFile: filepath.Join(t.TempDir(), "missing"),
+	}
+
+	_, err := ProvisionOverSSH(context.Background(), req, nil, nil)
+	var hkErr *SSHHostKeyError
+	if !errors.As(err, &hkErr) || hkErr.Mismatch || hkErr.Fingerprint != ssh.FingerprintSHA256(hostPub) {
+		t.Fatalf("unknown host: err = %v, want SSHHostKeyError with the server's fingerprint", err)
+	}
+	if len(m.cmds) != 0 {
+		t.Errorf("commands ran on an unverified host: %v", m.cmds)
+	}
+
+	req.HostKeySHA256 = "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	if _, err = ProvisionOverSSH(context.Background(), req, nil, nil); !errors.As(err, &hkErr) || !hkErr.Mismatch {
+		t.Fatalf("wrong pin: err = %v, want a mismatch", err)
+	}
+
+	req.HostKeySHA256 = hkErr.Fingerprint
+	prov, err := ProvisionOverSSH(context.Background(), req, nil, nil)
+	if err != nil || prov.CoreUploaded {
+		t.Fatalf("pinned: prov = %+v, err = %v", prov, err)
+	}
+}
+
+func TestProvisionOverSSHRejectsLoopbackDaemon(t *testing.T) {
+	keyFile, clientPub := writeClientKey(t)
+	// daemon.json локальной установки уже на машине — снаружи демон не виден.
+	m := &fakeMachine{uid: "0", core: constants.RequiredCoreVersion, listen: "127.0.0.1:19091"}
+	addr, hostPub := startFakeSSHD(t, clientPub, m)
+	req := SSHBootstrapRequest{Host: addr, User: "root", KeyFile: keyFile, KnownHostsFile: writeKnownHosts(t, addr, hostPub)}
+	if _, err := ProvisionOverSSH(context.Background(), req, nil, nil); err == nil || !strings.Contains(err.Error(), "loopback") {
+		t.Fatalf("err = %v, want a loopback listen error", err)
+	}
+
+	// Явный адрес снимает вопрос; сопряжение по нему идёт уже через реестр,
+	// и неудачный enroll ничего в реестр не пишет.
+	req.Addr = "127.0.0.1:1"
+	r := NewRemoteRegistry(t.TempDir())
+	if _, err := r.BootstrapSSH(context.Background(), req, nil, nil); err == nil || !strings.Contains(err.Error(), "enroll") {
+		t.Fatalf("BootstrapSSH err = %v, want an enroll failure", err)
+	}
+	if list, _ := r.List(); len(list) != 0 {
+		t.Errorf("failed pairing left registry entries: %+v", list)
+	}
+}
+
+func TestUnamePlatform(t *testing.T) {
+	cases := map[string]string{
+		"Linux x86_64":  "linux/amd64",
+		"Linux aarch64": "linux/arm64",
+		"Linux armv7l":  "linux/arm",
+		"Darwin arm64":  "darwin/arm64",
+	}
+	for in, want := range cases {
+		goos, goarch, err := unamePlatform(in)
+		if err != nil || goos+"/"+goarch != want {
+			t.Errorf("unamePlatform(%q) = %s/%s, %v; want %s", in, goos, goarch, err, want)
+		}
+	}
+	if _, _, err := unamePlatform("Linux mips"); err == nil {
+		t.Error("mips must be rejected: the fork has no build for it")
+	}
+}
+
+func TestCoreVersionIs(t *testing.T) {
+	line := "sing-box version 1.14.0-lx.26\n\nEnvironment: go1.24"
+	if !coreVersionIs(line, "1.14.0-lx.26") {
+		t.Error("exact version must match")
+	}
+	// Префикс — другая версия: lx.2 не должна сойти за уже стоящую lx.26.
+	if coreVersionIs(line, "1.14.0-lx.2") {
+		t.Error("a version prefix must not match")
+	}
+	if coreVersionIs("bash: sing-box: command not found", "1.14.0-lx.26") {
+		t.Error("no core, no match")
+	}
+}
@@ -86,3 +86,37 @@ func (t *LxdRemoteTransport) SubscribeLogLines(onLine func(LogLine), onReset fun
 	}()
 	return cancelCtx, nil
 }
+
+// FollowLog — долгая подписка на лог ядра машины для панели логов
+// (lxd_remote_logs.go): переживает перезапуск ядра и обрыв канала
+// (runResilientStream).
+//
+// В отличие от SubscribeLogLines отдаёт кадры целиком: первый кадр каждой
+// подписки и reset-кадр несут буфер демона заново (replay), и отличить
+// «повтор уже виденного» от новых строк можно только по кадру, а не по
+// строке.
+func (t *LxdRemoteTransport) FollowLog(onBatch func(replay bool, lines []LogLine)) (cancel func(), err error) {
+	conn, err := t.streamConn()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	runResilientStream(ctx, "log", func() error {
+		stream, serr := daemonpb.NewStartedServiceClient(conn).SubscribeLog(ctx, &emptypb.Empty{})
+		if serr != nil {
+			return serr
+		}
+		for first := true; ; first = false {
+			batch, recvErr := stream.Recv()
+			if recvErr != nil {
+				return recvErr
+			}
+			lines := make([]LogLine, 0, len(batch.GetMessages()))
+			for _, m := range batch.GetMessages() {
+				lines = append(lines, LogLine{Level: m.GetLevel().String(), Message: m.GetMessage()})
+			}
+			onBatch(first || batch.GetReset_(), lines)
+		}
+	}, nil)
+	return cancelCtx, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"singbox-launcher/api"
+	"singbox-launcher/core/events"
 )
 
 // ProxyTransport abstracts the wire used for proxy-group operations. The
@@ -75,12 +76,33 @@ func (apiSvc *APIService) SwitchProxyVia(t ProxyTransport, group, proxyName stri
 	}
 	apiSvc.SetActiveProxyName(proxyName)
 	apiSvc.SetLastSelectedProxyForGroup(group, proxyName)
+	apiSvc.closeConnectionsAfterSwitch(t, group)
+	apiSvc.publishProxySwitched(t, group, proxyName)
 	if apiSvc.OnProxySwitched != nil {
 		apiSvc.OnProxySwitched()
 	}
 	return nil
 }
 
+// publishProxySwitched сообщает о переключении в EventBus. Remote — по типу
+// транспорта: SwitchProxyVia со вкладки Remote шлёт в ядро машины.
+func (apiSvc *APIService) publishProxySwitched(t ProxyTransport, group, proxyName string) {
+	if apiSvc.EventBus == nil {
+		return
+	}
+	_, remote := t.(*LxdRemoteTransport)
+	apiSvc.EventBus.Publish(events.Event{
+		Kind:    events.ProxySwitched,
+		Payload: events.ProxySwitchedPayload{Group: group, Proxy: proxyName, Remote: remote},
+	})
+}
+
+// Transport — транспорт локального ядра (override daemon-режима или Clash
+// API из config.json), без remote-override вкладки Servers.
+func (apiSvc *APIService) Transport() (ProxyTransport, error) {
+	return apiSvc.wireTransport()
+}
+
 // wireTransport resolves the transport APIService itself should use: the
 // override when set (daemon mode), else Clash HTTP with the current
 // config.json endpoint. Returns an error when neither is available.
@@ -0,0 +1,8 @@
+//go:build !headless
+
+package services
+
+import "fyne.io/fyne/v2"
+
+// runOnUI — запись, которую читают виджеты, идёт на UI-потоке Fyne.
+func runOnUI(f func()) { fyne.Do(f) }
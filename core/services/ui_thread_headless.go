@@ -0,0 +1,6 @@
+//go:build headless
+
+package services
+
+// runOnUI — в headless-сборке UI-потока нет, f выполняется сразу.
+func runOnUI(f func()) { f() }
@@ -8,9 +8,11 @@
 //
 // См. SPECS/038-F-C-DEBUG_API/SUB_SPEC_SNAPSHOT.md для контракта и acceptance.
 //
-// Никакой редакции секретов: вызывающий слой (debug-API через bearer-токен,
-// UI в локальной сессии) — сам и есть trust-boundary. Если шарить публично —
-// чистить руками; UI показывает warning «contains your secrets».
+// Секреты маскируются (urlredact.RedactJSONSecrets): URL подписок и URI нод
+// — до хоста, пароли, uuid, ключи и токены — целиком. Снапшот прикладывают
+// к баг-репортам, и одна забытая подписка в нём — чужой доступ к VPN.
+// Запечатанные поля (шифрование секретов) попадают как есть — без ключа
+// это шум.
 package snapshot
 
 import (
@@ -21,6 +23,7 @@ import (
 	"github.com/muhammadmuzzammil1998/jsonc"
 
 	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/urlredact"
 )
 
 // Snapshot — данные одного захвата pipeline'а.
@@ -97,7 +100,15 @@ func Build(execDir, launcherVersion, singboxVersion string) Snapshot {
 			out.Errors[f.name] = "invalid JSON"
 			continue
 		}
-		out.Files[f.name] = json.RawMessage(canonical)
+		redacted, err := urlredact.RedactJSONSecrets(canonical)
+		if err != nil {
+			if out.Errors == nil {
+				out.Errors = map[string]string{}
+			}
+			out.Errors[f.name] = "invalid JSON"
+			continue
+		}
+		out.Files[f.name] = json.RawMessage(redacted)
 	}
 
 	if len(out.Files) == 0 {
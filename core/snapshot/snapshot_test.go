@@ -164,22 +164,25 @@ func TestBuild_JSONCConfigAccepted(t *testing.T) {
 	}
 }
 
-// TestBuild_NoRedaction — pin'им policy: secrets не маскируются.
-// Если кто-то решит «добавить редакцию» — упадёт.
-func TestBuild_NoRedaction(t *testing.T) {
+// TestBuild_RedactsSecrets — секреты config.json и state.json маскируются,
+// остальное (типы, серверы, метки) остаётся для диагностики.
+func TestBuild_RedactsSecrets(t *testing.T) {
 	execDir := layout(t)
-	cfg := `{"experimental":{"clash_api":{"secret":"deadbeef-secret"}},"outbounds":[{"type":"vless","password":"my-pass","uuid":"abcd"}]}`
+	cfg := `{"experimental":{"clash_api":{"secret":"deadbeef-secret"}},"outbounds":[{"type":"vless","server":"node.example","password":"my-pass-123","uuid":"abcd-uuid-1"}]}`
 	writeFile(t, execDir, "config", []byte(cfg))
+	writeFile(t, execDir, "state", []byte(`{"meta":{"version":6},"connections":{"sources":[{"url":"https://sub.example/sub/TOKEN42","label":"Main"}]}}`))
 
 	snap := Build(execDir, "", "")
-	got := string(snap.Files["config"])
-	for _, secret := range []string{"deadbeef-secret", "my-pass", "abcd"} {
-		if !strings.Contains(got, secret) {
-			t.Errorf("secret %q must pass through unredacted; got: %s", secret, got)
+	got := string(snap.Files["config"]) + string(snap.Files["state"])
+	for _, secret := range []string{"deadbeef-secret", "my-pass-123", "abcd-uuid-1", "TOKEN42"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("secret %q must be redacted; got: %s", secret, got)
 		}
 	}
-	if strings.Contains(got, "REDACTED") {
-		t.Errorf("response must NOT contain 'REDACTED' marker")
+	for _, keep := range []string{"node.example", "vless", "sub.example", "Main"} {
+		if !strings.Contains(got, keep) {
+			t.Errorf("%q must survive redaction; got: %s", keep, got)
+		}
 	}
 }
 
@@ -1,5 +1,12 @@
 package state
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // Diff описывает «что изменилось» между двумя State.
 //
 // Логика разделения на CacheStale / ConfigStale:
@@ -28,6 +35,9 @@ type Diff struct {
 	// Метаданные правок (не влияют на dirty-флаги)
 	IDChanged      bool
 	CommentChanged bool
+	// PoliciesChanged — присмотр, сторож связности или kill switch.
+	// Применяются своими путями (supervision.go, watchdog.go, killswitch.go).
+	PoliciesChanged bool
 }
 
 // IsEmpty — никаких реальных изменений не зафиксировано.
@@ -40,7 +50,8 @@ func (d Diff) IsEmpty() bool {
 		!d.CustomRulesChanged &&
 		!d.DNSOptionsChanged &&
 		!d.IDChanged &&
-		!d.CommentChanged
+		!d.CommentChanged &&
+		!d.PoliciesChanged
 }
 
 // AffectsParser — true, если есть изменения, требующие повторного
@@ -58,3 +69,98 @@ func (d Diff) AffectsTemplate() bool {
 		d.CustomRulesChanged ||
 		d.DNSOptionsChanged
 }
+
+// Changed — стабильные ярлыки изменённых доменов, для журнала действий
+// (core/audit). Порядок фиксирован.
+func (d Diff) Changed() []string {
+	var out []string
+	for _, f := range []struct {
+		on    bool
+		label string
+	}{
+		{d.ProxiesChanged, "sources"},
+		{d.OutboundsChanged, "outbounds"},
+		{d.VarsChanged, "vars"},
+		{d.ConfigParamsChanged, "config_params"},
+		{d.SelectableRulesChanged, "selectable_rules"},
+		{d.CustomRulesChanged, "rules"},
+		{d.DNSOptionsChanged, "dns"},
+		{d.IDChanged, "id"},
+		{d.CommentChanged, "comment"},
+		{d.PoliciesChanged, "policies"},
+	} {
+		if f.on {
+			out = append(out, f.label)
+		}
+	}
+	return out
+}
+
+// Compare — что изменилось между before и after. before == nil (state.json
+// ещё не было) — изменилось всё, что в after не пусто.
+//
+// Сравнение по JSON-форме полей: пустой срез и nil считаются равными,
+// иначе загруженный с диска state и собранный в памяти различались бы на
+// ровном месте.
+func Compare(before, after *State) Diff {
+	if after == nil {
+		return Diff{}
+	}
+	if before == nil {
+		before = &State{}
+	}
+	return Diff{
+		ProxiesChanged: !sameJSON(before.Connections.Sources, after.Connections.Sources) ||
+			!sameJSON(before.ParserConfig.ParserConfig.Proxies, after.ParserConfig.ParserConfig.Proxies),
+		OutboundsChanged: !sameJSON(before.Connections.Outbounds, after.Connections.Outbounds) ||
+			!sameJSON(before.ParserConfig.ParserConfig.Outbounds, after.ParserConfig.ParserConfig.Outbounds),
+		VarsChanged:            !sameJSON(before.Vars, after.Vars),
+		ConfigParamsChanged:    !sameJSON(before.ConfigParams, after.ConfigParams),
+		SelectableRulesChanged: !sameJSON(before.SelectableRuleStates, after.SelectableRuleStates),
+		CustomRulesChanged:     !sameJSON(before.CustomRules, after.CustomRules) || !sameJSON(before.Rules, after.Rules),
+		DNSOptionsChanged:      !sameJSON(before.DNS, after.DNS) || !sameJSON(before.DNSOptions, after.DNSOptions),
+		IDChanged:              before.ID != after.ID,
+		CommentChanged:         before.Comment != after.Comment,
+		PoliciesChanged: !sameJSON(before.Supervision, after.Supervision) ||
+			!sameJSON(before.Watchdog, after.Watchdog) ||
+			!sameJSON(before.KillSwitch, after.KillSwitch),
+	}
+}
+
+// Summary — одна строка о размере state для журнала: «было/стало».
+// nil — "none".
+func Summary(s *State) string {
+	if s == nil {
+		return "none"
+	}
+	enabled := 0
+	for _, src := range s.Connections.Sources {
+		if src.Enabled {
+			enabled++
+		}
+	}
+	rules := len(s.Rules)
+	if rules == 0 {
+		rules = len(s.CustomRules)
+	}
+	return fmt.Sprintf("%d sources (%d enabled), %d outbounds, %d rules, %d vars",
+		len(s.Connections.Sources), enabled, len(s.Connections.Outbounds), rules, len(s.Vars))
+}
+
+func sameJSON(a, b any) bool {
+	ja, ea := json.Marshal(a)
+	jb, eb := json.Marshal(b)
+	if ea != nil || eb != nil {
+		return false
+	}
+	return bytes.Equal(emptyJSON(ja), emptyJSON(jb))
+}
+
+// emptyJSON сводит пустые значения к null.
+func emptyJSON(j []byte) []byte {
+	switch strings.TrimSpace(string(j)) {
+	case "[]", "{}", `""`, "0", "false":
+		return []byte("null")
+	}
+	return j
+}
@@ -0,0 +1,42 @@
+package state
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	base := New()
+	base.Connections.Sources = []Source{{ID: "a", Type: SourceTypeSubscription, Enabled: true, URL: "https://x"}}
+	base.Vars = []SettingVar{{Name: "log_level", Value: "info"}}
+
+	same := New()
+	same.Connections.Sources = []Source{{ID: "a", Type: SourceTypeSubscription, Enabled: true, URL: "https://x"}}
+	same.Vars = []SettingVar{{Name: "log_level", Value: "info"}}
+	same.ConfigParams = nil // nil и [] — одно и то же
+	if d := Compare(base, same); !d.IsEmpty() {
+		t.Errorf("equal states: %+v", d.Changed())
+	}
+
+	next := New()
+	next.Connections.Sources = []Source{{ID: "a", Type: SourceTypeSubscription, Enabled: false, URL: "https://x"}}
+	next.Vars = []SettingVar{{Name: "log_level", Value: "debug"}}
+	next.Comment = "work"
+	d := Compare(base, next)
+	if !d.AffectsParser() || !d.AffectsTemplate() {
+		t.Errorf("diff = %+v", d)
+	}
+	if got := strings.Join(d.Changed(), ","); got != "sources,vars,comment" {
+		t.Errorf("Changed = %q", got)
+	}
+
+	if d := Compare(nil, next); !d.ProxiesChanged || !d.VarsChanged {
+		t.Errorf("from nil: %+v", d)
+	}
+	if got := Summary(base); got != "1 sources (1 enabled), 0 outbounds, 0 rules, 1 vars" {
+		t.Errorf("Summary = %q", got)
+	}
+	if Summary(nil) != "none" {
+		t.Error("Summary(nil)")
+	}
+}
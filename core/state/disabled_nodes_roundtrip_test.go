@@ -77,3 +77,22 @@ func TestLegacyStateWithoutDisabledNodes(t *testing.T) {
 		t.Fatalf("legacy state must yield no marks, got %v", src.DisabledNodes)
 	}
 }
+
+// Save синхронизирует legacy-view обратно в Connections — отметки должны
+// пережить и этот круг, иначе любой Load → Save (Debug API, сохранение
+// правил) молча включал бы выключенные ноды.
+func TestDisabledNodesSurviveLegacySync(t *testing.T) {
+	s := &State{}
+	s.Connections.Sources = []Source{{
+		ID: "a", Type: SourceTypeSubscription, Enabled: true, URL: "https://example.invalid/sub",
+		DisabledNodes: map[string]int64{"aaaabbbbccccdddd": 1754400000},
+	}}
+
+	s.SyncLegacyFromConnections()
+	syncConnectionsFromLegacy(s)
+
+	got := s.Connections.Sources
+	if len(got) != 1 || got[0].ID != "a" || got[0].DisabledNodes["aaaabbbbccccdddd"] != 1754400000 {
+		t.Fatalf("sources after sync = %+v", got)
+	}
+}
@@ -56,6 +56,9 @@ type diskStateV6 struct {
 	Vars         []SettingVar         `json:"vars,omitempty"`
 	DNSOptions   DNSOptions           `json:"dns_options"`
 	WarpAccounts *WarpAccountsSection `json:"warp_accounts,omitempty"`
+	Supervision  *SupervisionPolicy   `json:"supervision,omitempty"`
+	Watchdog     *WatchdogPolicy      `json:"watchdog,omitempty"`
+	KillSwitch   *KillSwitchPolicy    `json:"kill_switch,omitempty"`
 }
 
 // WarpAccountsSection — кеш выданных Cloudflare регистраций WARP.
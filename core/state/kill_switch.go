@@ -0,0 +1,43 @@
+package state
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// KillSwitchPolicy — kill switch профиля (state.kill_switch): пока VPN
+// должен работать, системный файрвол выпускает наружу только соединения
+// самого ядра, трафик через TUN и локальную сеть. Снимается только явным
+// Stop пользователя — падение ядра и его перезапуски трафик не выпускают.
+type KillSwitchPolicy struct {
+	Enabled bool `json:"enabled"`
+	// BlockLAN — не делать исключения для локальной сети (RFC 1918,
+	// link-local, multicast). DHCP и обнаружение соседей IPv6 разрешены
+	// всегда, иначе машина теряет адрес.
+	BlockLAN bool `json:"block_lan,omitempty"`
+	// AllowCIDRs — дополнительные разрешённые адреса назначения.
+	AllowCIDRs []string `json:"allow_cidrs,omitempty"`
+}
+
+// KillSwitchFieldError — недопустимое значение поля kill switch.
+type KillSwitchFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *KillSwitchFieldError) Error() string { return e.Field + ": " + e.Message }
+
+// Validate проверяет адреса исключений.
+func (p *KillSwitchPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, c := range p.AllowCIDRs {
+		if _, err := netip.ParsePrefix(c); err != nil {
+			if _, aerr := netip.ParseAddr(c); aerr != nil {
+				return &KillSwitchFieldError{Field: "allow_cidrs", Message: fmt.Sprintf("%q is not an address or CIDR", c)}
+			}
+		}
+	}
+	return nil
+}
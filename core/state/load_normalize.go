@@ -86,3 +86,10 @@ func validUpdateRef(ref string) bool {
 	}
 	return true
 }
+
+// ValidOutboundEntryRef / ValidOutboundUpdateRef — те же правила для внешних
+// писателей (Debug API): невалидный ref отклоняется до Save, а не молча
+// выпадает на следующем Load через sanitizeOutboundRefs.
+func ValidOutboundEntryRef(ref string) bool { return validEntryRef(ref) }
+
+func ValidOutboundUpdateRef(ref string) bool { return validUpdateRef(ref) }
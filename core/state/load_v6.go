@@ -35,6 +35,9 @@ func parseCurrent(data []byte) (*State, error) {
 		Vars         []SettingVar         `json:"vars"`
 		DNSOptions   DNSOptions           `json:"dns_options"`
 		WarpAccounts *WarpAccountsSection `json:"warp_accounts"`
+		Supervision  *SupervisionPolicy   `json:"supervision"`
+		Watchdog     *WatchdogPolicy      `json:"watchdog"`
+		KillSwitch   *KillSwitchPolicy    `json:"kill_switch"`
 		// Legacy dev-shape (SPEC 053). Читаем для одноразовой in-place миграции.
 		LegacyDNS json.RawMessage `json:"dns"`
 	}
@@ -59,8 +62,14 @@ func parseCurrent(data []byte) (*State, error) {
 		Rules:              raw.Rules,
 		DNS:                dnsOpts,
 		WarpAccounts:       raw.WarpAccounts,
+		Supervision:        raw.Supervision,
+		Watchdog:           raw.Watchdog,
+		KillSwitch:         raw.KillSwitch,
 		RulesLibraryMerged: true,
 	}
+	if err := openSecrets(s); err != nil {
+		return nil, err
+	}
 	if t, err := time.Parse(time.RFC3339, raw.Meta.CreatedAt); err == nil {
 		s.CreatedAt = t
 	}
@@ -0,0 +1,580 @@
+// File profile_overlay.go — наследование состояния визарда от базового
+// профиля: отличия машины от базы и их наложение на новую базу.
+//
+// Дух тот же, что у template-diff модели SPEC 058: храним не копию, а то,
+// чем конкретная машина отличается, — тогда правка в базе доезжает до всех
+// наследников, а их собственные правки (TUN вкл/выкл, роль шлюза, локальные
+// источники) остаются на месте.
+//
+// Работа идёт на уровне JSON (canonical v6 shape), а не через Load/Save:
+// сквозной цикл прогнал бы базу и машину через миграцию и обратную
+// сериализацию, и всё, что текущая модель не знает, потерялось бы. Тот же
+// довод, что у retargetStateJSON в реестре машин.
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfileOverlay — отличия состояния машины от базового профиля.
+//
+// Lists — ключевые списки (источники по id, outbounds по tag, правила по
+// identity, vars по name, DNS-серверы и DNS-правила): отличия поэлементно.
+// Fields — всё остальное (connections.defaults, warp_accounts, ...):
+// значение машины целиком, если отличается от базы. Removed — поля, которые
+// есть в базе, но машина их убрала.
+//
+// meta в отличия не входит никогда: версия схемы, даты и target_platform —
+// свойства самой машины, не профиля.
+type ProfileOverlay struct {
+	Lists   map[string]*ProfileListDiff `json:"lists,omitempty"`
+	Fields  map[string]json.RawMessage  `json:"fields,omitempty"`
+	Removed []string                    `json:"removed,omitempty"`
+}
+
+// ProfileListDiff — отличия одного ключевого списка.
+//
+// Set — элементы машины, которых нет в базе или которые отличаются от
+// базовых (целиком, без локальных полей). Removed — ключи базовых
+// элементов, которые машина удалила. Order — полный порядок ключей машины;
+// пишется, только если он не совпадает с порядком «база + новые в конце»
+// (для правил порядок — это приоритет маршрутизации).
+type ProfileListDiff struct {
+	Set     []json.RawMessage `json:"set,omitempty"`
+	Removed []string          `json:"removed,omitempty"`
+	Order   []string          `json:"order,omitempty"`
+}
+
+// IsEmpty — машина ничем не отличается от базы.
+func (o ProfileOverlay) IsEmpty() bool {
+	return len(o.Lists) == 0 && len(o.Fields) == 0 && len(o.Removed) == 0
+}
+
+// Count — число отличий: элементов списков (изменённых, удалённых, плюс
+// один за переставленный порядок) и полей. Для строки статуса в UI.
+func (o ProfileOverlay) Count() int {
+	n := len(o.Fields) + len(o.Removed)
+	for _, ld := range o.Lists {
+		n += len(ld.Set) + len(ld.Removed)
+		if len(ld.Order) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// profileContainers — объекты верхнего уровня, которые сравниваются по
+// вложенным полям, а не целиком: иначе правка одного источника сделала бы
+// отличием весь раздел connections.
+var profileContainers = map[string]bool{"connections": true, "dns_options": true}
+
+// profileListKeys — ключевые списки и их identity.
+var profileListKeys = map[string]func(map[string]any) string{
+	"connections.sources":   func(m map[string]any) string { return jsonStr(m["id"]) },
+	"connections.outbounds": func(m map[string]any) string { return jsonStr(m["tag"]) },
+	"vars":                  func(m map[string]any) string { return jsonStr(m["name"]) },
+	"rules":                 profileRuleKey,
+	"dns_options.servers":   profileDNSServerKey,
+	"dns_options.rules":     profileDNSRuleKey,
+}
+
+// profileLocalFields — поля элементов, которые принадлежат машине, а не
+// профилю: не сравниваются и при наложении берутся из прежнего состояния
+// машины. meta источника — результат ЕЁ последнего фетча (трафик, срок,
+// число нод); иначе первое же обновление подписок сделало бы каждый
+// источник «отличием» и отрезало бы его от базы.
+var profileLocalFields = map[string][]string{
+	"connections.sources": {"meta"},
+}
+
+// profileRuleKey — identity правила на диске: kind + ref (preset) или
+// body.name (inline/srs). Совпадает по смыслу со StableRuleID, но без
+// санитизации: здесь ключ не уходит в tag, а различать имена надо точно.
+func profileRuleKey(m map[string]any) string {
+	kind := jsonStr(m["kind"])
+	if kind == string(RuleKindPreset) {
+		return kind + ":" + jsonStr(m["ref"])
+	}
+	body, _ := m["body"].(map[string]any)
+	return kind + ":" + jsonStr(body["name"])
+}
+
+// profileDNSServerKey — kind + ref (preset) или tag (template/user).
+func profileDNSServerKey(m map[string]any) string {
+	kind := jsonStr(m["kind"])
+	if kind == string(DNSServerKindPreset) {
+		return kind + ":" + jsonStr(m["ref"])
+	}
+	return kind + ":" + jsonStr(m["tag"])
+}
+
+// profileDNSRuleKey — preset по ref; у user-правила имени нет, identity —
+// само тело без enabled (переключение не делает правило другим).
+func profileDNSRuleKey(m map[string]any) string {
+	kind := jsonStr(m["kind"])
+	if kind == string(DNSRuleKindPreset) {
+		return kind + ":" + jsonStr(m["ref"])
+	}
+	body := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != "enabled" {
+			body[k] = v
+		}
+	}
+	return kind + ":" + string(canonJSON(body))
+}
+
+// DiffProfile вычисляет отличия derived от base. Оба — state.json в
+// canonical (v6) shape.
+func DiffProfile(base, derived []byte) (ProfileOverlay, error) {
+	b, err := decodeProfileDoc(base, "base")
+	if err != nil {
+		return ProfileOverlay{}, err
+	}
+	d, err := decodeProfileDoc(derived, "machine")
+	if err != nil {
+		return ProfileOverlay{}, err
+	}
+	ov := ProfileOverlay{}
+	for _, path := range profilePaths(b, d) {
+		bv, bok := profileGet(b, path)
+		dv, dok := profileGet(d, path)
+		if keyf, isList := profileListKeys[path]; isList {
+			bl, bOK := keyedItems(bv, keyf)
+			dl, dOK := keyedItems(dv, keyf)
+			if bOK && dOK {
+				if ld := diffKeyed(path, bl, dl); ld != nil {
+					if ov.Lists == nil {
+						ov.Lists = map[string]*ProfileListDiff{}
+					}
+					ov.Lists[path] = ld
+				}
+				continue
+			}
+			// Дубли ключей или не массив объектов — поэлементно не
+			// сопоставить; список уходит отличием целиком.
+		}
+		switch {
+		case dok && (!bok || !bytes.Equal(canonJSON(bv), canonJSON(dv))):
+			if ov.Fields == nil {
+				ov.Fields = map[string]json.RawMessage{}
+			}
+			ov.Fields[path] = canonJSON(dv)
+		case bok && !dok:
+			ov.Removed = append(ov.Removed, path)
+		}
+	}
+	return ov, nil
+}
+
+// ApplyProfile накладывает отличия ov на base. prev — текущее состояние
+// машины (может быть nil): из него берутся meta и локальные поля элементов.
+// Результат — state.json машины в canonical shape.
+func ApplyProfile(base []byte, ov ProfileOverlay, prev []byte) ([]byte, error) {
+	out, err := decodeProfileDoc(base, "base")
+	if err != nil {
+		return nil, err
+	}
+	var p map[string]any
+	if len(prev) > 0 {
+		if p, err = decodeProfileDoc(prev, "machine"); err != nil {
+			return nil, err
+		}
+		if meta, ok := p["meta"]; ok {
+			out["meta"] = meta
+		}
+	}
+
+	for path, ld := range ov.Lists {
+		if _, isField := ov.Fields[path]; isField {
+			continue
+		}
+		keyf := profileListKeys[path]
+		if keyf == nil {
+			return nil, fmt.Errorf("profile overlay: unknown list %q", path)
+		}
+		bv, _ := profileGet(out, path)
+		bl, ok := keyedItems(bv, keyf)
+		if !ok {
+			// База перестала быть поэлементно сопоставимой — оставляем
+			// машине её собственный список, а не теряем её правки.
+			if pv, has := profileGet(p, path); has {
+				profileSet(out, path, pv)
+			}
+			continue
+		}
+		merged, err := applyKeyed(bl, ld, keyf)
+		if err != nil {
+			return nil, fmt.Errorf("profile overlay: %s: %w", path, err)
+		}
+		profileSet(out, path, merged)
+	}
+	for path, raw := range ov.Fields {
+		v, err := decodeJSONValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("profile overlay: %s: %w", path, err)
+		}
+		profileSet(out, path, v)
+	}
+	for _, path := range ov.Removed {
+		profileDelete(out, path)
+	}
+
+	for path, fields := range profileLocalFields {
+		restoreLocalFields(out, p, path, fields)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("profile overlay: serialize: %w", err)
+	}
+	return data, nil
+}
+
+// RebaseProfile переносит машину со старой базы на новую: её отличия от
+// oldBase накладываются на newBase. Трёхсторонний merge — поэтому правки,
+// внесённые в state.json машины кем угодно (визард, Debug API), не теряются
+// и не требуют отдельного учёта отличий при каждом сохранении.
+func RebaseProfile(oldBase, newBase, machine []byte) ([]byte, error) {
+	ov, err := DiffProfile(oldBase, machine)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyProfile(newBase, ov, machine)
+}
+
+// decodeProfileDoc разбирает state.json. Legacy-раскладки (v2-v5, без
+// meta) не поддерживаются: их ключи не совпадают с v6, и сопоставление
+// молча дало бы ерунду.
+func decodeProfileDoc(raw []byte, what string) (map[string]any, error) {
+	v, err := decodeJSONValue(raw)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: parse state: %w", what, err)
+	}
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("profile %s: state is not a JSON object", what)
+	}
+	if _, ok := doc["meta"].(map[string]any); !ok {
+		return nil, fmt.Errorf("profile %s: legacy state layout — open it in the wizard and save once", what)
+	}
+	return doc, nil
+}
+
+// decodeJSONValue — json.Unmarshal с UseNumber: числа доезжают байт в байт,
+// без округления через float64.
+func decodeJSONValue(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// canonJSON — каноническая сериализация для сравнения (ключи map
+// сортируются encoding/json).
+func canonJSON(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func jsonStr(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// profilePaths — все сравниваемые пути обоих документов, отсортированные
+// (детерминированный порядок отличий). Контейнеры раскрываются на
+// вложенные поля, meta пропускается.
+func profilePaths(docs ...map[string]any) []string {
+	seen := map[string]bool{}
+	for _, doc := range docs {
+		for k, v := range doc {
+			if k == "meta" {
+				continue
+			}
+			if profileContainers[k] {
+				if m, ok := v.(map[string]any); ok {
+					for sub := range m {
+						seen[k+"."+sub] = true
+					}
+					continue
+				}
+			}
+			seen[k] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for p := range seen {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func profileGet(doc map[string]any, path string) (any, bool) {
+	if doc == nil {
+		return nil, false
+	}
+	head, tail, nested := strings.Cut(path, ".")
+	if !nested {
+		v, ok := doc[head]
+		return v, ok
+	}
+	m, ok := doc[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok := m[tail]
+	return v, ok
+}
+
+func profileSet(doc map[string]any, path string, v any) {
+	head, tail, nested := strings.Cut(path, ".")
+	if !nested {
+		doc[head] = v
+		return
+	}
+	m, ok := doc[head].(map[string]any)
+	if !ok {
+		m = map[string]any{}
+		doc[head] = m
+	}
+	m[tail] = v
+}
+
+func profileDelete(doc map[string]any, path string) {
+	head, tail, nested := strings.Cut(path, ".")
+	if !nested {
+		delete(doc, head)
+		return
+	}
+	if m, ok := doc[head].(map[string]any); ok {
+		delete(m, tail)
+	}
+}
+
+// keyedList — список, разложенный по ключам с сохранением порядка.
+type keyedList struct {
+	keys  []string
+	items map[string]map[string]any
+}
+
+// keyedItems раскладывает JSON-массив по ключам. false — не массив
+// объектов или ключи повторяются. Отсутствующий список = пустой.
+func keyedItems(v any, keyf func(map[string]any) string) (keyedList, bool) {
+	kl := keyedList{items: map[string]map[string]any{}}
+	if v == nil {
+		return kl, true
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return kl, false
+	}
+	for _, el := range arr {
+		m, ok := el.(map[string]any)
+		if !ok {
+			return kl, false
+		}
+		k := keyf(m)
+		if _, dup := kl.items[k]; dup {
+			return kl, false
+		}
+		kl.keys = append(kl.keys, k)
+		kl.items[k] = m
+	}
+	return kl, true
+}
+
+// withoutLocal — копия элемента без локальных полей списка path.
+func withoutLocal(path string, m map[string]any) map[string]any {
+	fields := profileLocalFields[path]
+	if len(fields) == 0 {
+		return m
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, f := range fields {
+		delete(out, f)
+	}
+	return out
+}
+
+// diffKeyed — отличия одного списка; nil, если их нет.
+func diffKeyed(path string, b, d keyedList) *ProfileListDiff {
+	ld := &ProfileListDiff{}
+	for _, k := range d.keys {
+		di := withoutLocal(path, d.items[k])
+		bi, inBase := b.items[k]
+		if !inBase || !bytes.Equal(canonJSON(withoutLocal(path, bi)), canonJSON(di)) {
+			ld.Set = append(ld.Set, canonJSON(di))
+		}
+	}
+	for _, k := range b.keys {
+		if _, kept := d.items[k]; !kept {
+			ld.Removed = append(ld.Removed, k)
+		}
+	}
+	// Порядок по умолчанию: базовые без удалённых, затем новые машины.
+	var natural []string
+	for _, k := range b.keys {
+		if _, kept := d.items[k]; kept {
+			natural = append(natural, k)
+		}
+	}
+	for _, k := range d.keys {
+		if _, inBase := b.items[k]; !inBase {
+			natural = append(natural, k)
+		}
+	}
+	if strings.Join(natural, "\x00") != strings.Join(d.keys, "\x00") {
+		ld.Order = append([]string(nil), d.keys...)
+	}
+	if len(ld.Set) == 0 && len(ld.Removed) == 0 && len(ld.Order) == 0 {
+		return nil
+	}
+	return ld
+}
+
+// applyKeyed накладывает отличия списка на базовый.
+//
+// Элемент, который машина правила, а база потом удалила, остаётся у машины
+// (её версия — её решение). Новые элементы базы при заданном Order встают
+// сразу за своим предшественником в базе: новое правило, добавленное в базу
+// перед catch-all, не должно оказаться после него у машины с переставленным
+// порядком.
+func applyKeyed(b keyedList, ld *ProfileListDiff, keyf func(map[string]any) string) ([]any, error) {
+	set := map[string]map[string]any{}
+	var setKeys []string
+	for _, raw := range ld.Set {
+		v, err := decodeJSONValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("overlay element is not an object")
+		}
+		k := keyf(m)
+		set[k] = m
+		setKeys = append(setKeys, k)
+	}
+	removed := map[string]bool{}
+	for _, k := range ld.Removed {
+		removed[k] = true
+	}
+
+	var keys []string
+	items := map[string]map[string]any{}
+	for _, k := range b.keys {
+		if removed[k] {
+			continue
+		}
+		keys = append(keys, k)
+		items[k] = b.items[k]
+		if m, ok := set[k]; ok {
+			items[k] = m
+		}
+	}
+	for _, k := range setKeys {
+		if _, ok := items[k]; !ok {
+			keys = append(keys, k)
+			items[k] = set[k]
+		}
+	}
+
+	if len(ld.Order) > 0 {
+		keys = reorderKeys(keys, ld.Order)
+	}
+	out := make([]any, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, items[k])
+	}
+	return out, nil
+}
+
+// reorderKeys раскладывает keys в порядке order; ключи, которых order не
+// знает (новые в базе), вставляются за своим предшественником в keys.
+func reorderKeys(keys, order []string) []string {
+	present := map[string]bool{}
+	for _, k := range keys {
+		present[k] = true
+	}
+	known := map[string]bool{}
+	var out []string
+	for _, k := range order {
+		if present[k] && !known[k] {
+			out = append(out, k)
+			known[k] = true
+		}
+	}
+	for i, k := range keys {
+		if known[k] {
+			continue
+		}
+		pos := 0
+		for j := i - 1; j >= 0; j-- {
+			if idx := indexOf(out, keys[j]); idx >= 0 {
+				pos = idx + 1
+				break
+			}
+		}
+		out = append(out[:pos], append([]string{k}, out[pos:]...)...)
+		known[k] = true
+	}
+	return out
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// restoreLocalFields переносит локальные поля элементов списка path из
+// prev в out; у элементов, которых у машины не было, они убираются (meta
+// фетча базы к этой машине отношения не имеет).
+func restoreLocalFields(out, prev map[string]any, path string, fields []string) {
+	keyf := profileListKeys[path]
+	ov, _ := profileGet(out, path)
+	arr, ok := ov.([]any)
+	if !ok {
+		return
+	}
+	pv, _ := profileGet(prev, path)
+	pl, _ := keyedItems(pv, keyf)
+	for _, el := range arr {
+		m, ok := el.(map[string]any)
+		if !ok {
+			continue
+		}
+		old := pl.items[keyf(m)]
+		for _, f := range fields {
+			if v, has := old[f]; has {
+				m[f] = v
+			} else {
+				delete(m, f)
+			}
+		}
+	}
+}
+
+// CheckProfileState проверяет, что raw годится в базовый профиль: объект
+// state.json в canonical (v6) shape.
+func CheckProfileState(raw []byte) error {
+	_, err := decodeProfileDoc(raw, "base")
+	return err
+}
@@ -0,0 +1,169 @@
+package state
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const overlayBase = `{
+  "meta": {"version": 6, "schema": "presets_v1", "target_platform": "linux", "target_arch": "amd64"},
+  "connections": {
+    "sources": [
+      {"id": "s1", "type": "subscription", "enabled": true, "url": "https://a/sub", "meta": {"nodes": 10}}
+    ],
+    "outbounds": [{"tag": "proxy-out", "type": "selector"}],
+    "defaults": {"reload": "4h"}
+  },
+  "rules": [
+    {"kind": "preset", "ref": "ru-direct", "enabled": true, "body": {"vars": {}}},
+    {"kind": "inline", "enabled": true, "body": {"name": "Work", "match": {"domain_suffix": ["corp.lan"]}, "outbound": "direct"}},
+    {"kind": "inline", "enabled": true, "body": {"name": "Catch-all", "match": {}, "outbound": "proxy-out"}}
+  ],
+  "vars": [{"name": "tun", "value": "false"}, {"name": "log_level", "value": "warn"}],
+  "dns_options": {"servers": [{"kind": "template", "tag": "dns-remote", "enabled": true}]}
+}`
+
+// machineFrom — база с правками машины: TUN включён, свой источник, meta
+// фетча своя.
+func machineFrom(t *testing.T, base string) []byte {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(base), &doc); err != nil {
+		t.Fatal(err)
+	}
+	doc["meta"].(map[string]any)["target_arch"] = "mipsle"
+	conns := doc["connections"].(map[string]any)
+	sources := conns["sources"].([]any)
+	sources[0].(map[string]any)["meta"] = map[string]any{"nodes": 7}
+	conns["sources"] = append(sources, map[string]any{"id": "local", "type": "server", "enabled": true, "uri": "vless://x"})
+	doc["vars"].([]any)[0].(map[string]any)["value"] = "true"
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDiffProfileRecordsOnlyMachineChanges(t *testing.T) {
+	ov, err := DiffProfile([]byte(overlayBase), machineFrom(t, overlayBase))
+	if err != nil {
+		t.Fatalf("DiffProfile: %v", err)
+	}
+	if len(ov.Fields) != 0 || len(ov.Removed) != 0 {
+		t.Errorf("fields=%v removed=%v, want none", ov.Fields, ov.Removed)
+	}
+	if len(ov.Lists) != 2 {
+		t.Fatalf("lists = %v, want vars and connections.sources only", ov.Lists)
+	}
+	// Источник s1 отличается только meta фетча — это не отличие от базы.
+	src := ov.Lists["connections.sources"]
+	if len(src.Set) != 1 || !strings.Contains(string(src.Set[0]), `"local"`) {
+		t.Errorf("sources diff = %s, want only the local source", src.Set)
+	}
+	if v := ov.Lists["vars"]; len(v.Set) != 1 || !strings.Contains(string(v.Set[0]), `"tun"`) {
+		t.Errorf("vars diff = %+v", v)
+	}
+
+	same, err := DiffProfile([]byte(overlayBase), []byte(overlayBase))
+	if err != nil || !same.IsEmpty() {
+		t.Errorf("identical states: overlay=%+v err=%v, want empty", same, err)
+	}
+}
+
+func TestRebaseProfileKeepsOverridesAndTakesBaseChanges(t *testing.T) {
+	machine := machineFrom(t, overlayBase)
+
+	// База: правило Work поменялось, перед catch-all добавлено новое,
+	// log_level стал info, defaults.reload — 1h.
+	newBase := strings.Replace(overlayBase, `"corp.lan"`, `"corp.lan", "corp.example"`, 1)
+	newBase = strings.Replace(newBase,
+		`{"kind": "inline", "enabled": true, "body": {"name": "Catch-all"`,
+		`{"kind": "inline", "enabled": true, "body": {"name": "Ads", "match": {"domain_suffix": ["ads.example"]}, "outbound": "block"}},
+    {"kind": "inline", "enabled": true, "body": {"name": "Catch-all"`, 1)
+	newBase = strings.Replace(newBase, `"value": "warn"`, `"value": "info"`, 1)
+	newBase = strings.Replace(newBase, `"reload": "4h"`, `"reload": "1h"`, 1)
+
+	out, err := RebaseProfile([]byte(overlayBase), []byte(newBase), machine)
+	if err != nil {
+		t.Fatalf("RebaseProfile: %v", err)
+	}
+	var got diskStateV6
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("result is not a v6 state: %v\n%s", err, out)
+	}
+
+	if got.Meta.TargetArch != "mipsle" {
+		t.Errorf("meta must stay the machine's: arch=%q", got.Meta.TargetArch)
+	}
+	var names []string
+	for _, r := range got.Rules {
+		names = append(names, StableRuleID(r))
+	}
+	if strings.Join(names, ",") != "ru-direct,Work,Ads,Catch-all" {
+		t.Errorf("rules = %v, want the base's new rule before catch-all", names)
+	}
+	if !strings.Contains(string(got.Rules[1].Body), "corp.example") {
+		t.Errorf("Work rule did not pick up the base change: %s", got.Rules[1].Body)
+	}
+	vars := map[string]string{}
+	for _, v := range got.Vars {
+		vars[v.Name] = v.Value
+	}
+	if vars["tun"] != "true" || vars["log_level"] != "info" {
+		t.Errorf("vars = %v, want tun=true (machine) and log_level=info (base)", vars)
+	}
+	if got.Connections.Defaults.Reload != "1h" {
+		t.Errorf("defaults.reload = %q, want the base's 1h", got.Connections.Defaults.Reload)
+	}
+	if len(got.Connections.Sources) != 2 || got.Connections.Sources[1].ID != "local" {
+		t.Fatalf("sources = %+v, want s1 + local", got.Connections.Sources)
+	}
+	if !strings.Contains(string(out), `"nodes": 7`) {
+		t.Errorf("source meta must stay the machine's own fetch result:\n%s", out)
+	}
+}
+
+// Машина переставила правила — её порядок сохраняется, а новое правило базы
+// встаёт за своим предшественником в базе.
+func TestRebaseProfileCustomOrder(t *testing.T) {
+	base := []byte(overlayBase)
+	machine := strings.Replace(overlayBase,
+		`{"kind": "preset", "ref": "ru-direct", "enabled": true, "body": {"vars": {}}},
+    {"kind": "inline", "enabled": true, "body": {"name": "Work", "match": {"domain_suffix": ["corp.lan"]}, "outbound": "direct"}},`,
+		`{"kind": "inline", "enabled": true, "body": {"name": "Work", "match": {"domain_suffix": ["corp.lan"]}, "outbound": "direct"}},
+    {"kind": "preset", "ref": "ru-direct", "enabled": true, "body": {"vars": {}}},`, 1)
+	ov, err := DiffProfile(base, []byte(machine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ld := ov.Lists["rules"]; ld == nil || len(ld.Set) != 0 || len(ld.Order) != 3 {
+		t.Fatalf("rules diff = %+v, want order only", ov.Lists["rules"])
+	}
+	newBase := strings.Replace(overlayBase,
+		`{"kind": "inline", "enabled": true, "body": {"name": "Work"`,
+		`{"kind": "preset", "ref": "ads", "enabled": true, "body": {"vars": {}}},
+    {"kind": "inline", "enabled": true, "body": {"name": "Work"`, 1)
+	out, err := ApplyProfile([]byte(newBase), ov, []byte(machine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got diskStateV6
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range got.Rules {
+		names = append(names, StableRuleID(r))
+	}
+	if strings.Join(names, ",") != "Work,ru-direct,ads,Catch-all" {
+		t.Errorf("rules = %v", names)
+	}
+}
+
+func TestDiffProfileRejectsLegacyLayout(t *testing.T) {
+	if _, err := DiffProfile([]byte(`{"version":4,"parser_config":{}}`), []byte(overlayBase)); err == nil ||
+		!strings.Contains(err.Error(), "legacy") {
+		t.Errorf("err = %v, want a legacy-layout error", err)
+	}
+}
@@ -1,10 +1,13 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"singbox-launcher/internal/secretstore"
 )
 
 // rawSuffix — расширение файлов кеша. id.raw.
@@ -81,6 +84,9 @@ func WriteRawBody(subsDir, id string, body []byte) error {
 	if err != nil {
 		return err
 	}
+	if body, err = SealRawBody(body); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(subsDir, rawDirMode); err != nil {
 		return fmt.Errorf("state.raw_cache: mkdir %s: %w", subsDir, err)
 	}
@@ -125,7 +131,31 @@ func ReadRawBody(subsDir, id string) ([]byte, error) {
 		}
 		return nil, fmt.Errorf("state.raw_cache: read %s: %w", target, err)
 	}
-	return body, nil
+	return OpenRawBody(body)
+}
+
+// SealRawBody — тело подписки для записи на диск: в нём те же учётные
+// данные нод, что в URI источников, поэтому при включённом шифровании
+// (internal/secretstore) оно запечатывается целиком. Выключено — как есть.
+func SealRawBody(body []byte) ([]byte, error) {
+	sealed, err := secretstore.Seal(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("state.raw_cache: %w", err)
+	}
+	return []byte(sealed), nil
+}
+
+// OpenRawBody — обратное SealRawBody; открытое тело возвращается как есть
+// (кеш, записанный до включения шифрования).
+func OpenRawBody(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(secretstore.Prefix)) {
+		return data, nil
+	}
+	plain, err := secretstore.Open(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("state.raw_cache: %w", err)
+	}
+	return []byte(plain), nil
 }
 
 // ErrRawNotFound — raw body для данного source id не существует на диске.
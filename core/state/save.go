@@ -47,7 +47,7 @@ func (s *State) Save(path string) error {
 		}
 	}
 
-	data, err := s.marshalDisk()
+	data, err := s.marshalDisk(true)
 	if err != nil {
 		return err
 	}
@@ -101,7 +101,7 @@ func (s *State) Save(path string) error {
 // SPEC 060 Phase 5: единственный write path — `marshalDiskV6` rename'нут в
 // `marshalDisk`, старый v5-marshaller удалён. Legacy `s.CustomRules` /
 // `s.DNSOptions` НЕ сериализуются — источник истины Rules / DNS.
-func (s *State) marshalDisk() ([]byte, error) {
+func (s *State) marshalDisk(sealed bool) ([]byte, error) {
 	out := diskStateV6{
 		Meta: MetaSection{
 			Version:   SchemaVersionV6,
@@ -119,6 +119,9 @@ func (s *State) marshalDisk() ([]byte, error) {
 		Vars:         s.Vars,
 		DNSOptions:   s.DNS,
 		WarpAccounts: s.WarpAccounts,
+		Supervision:  s.Supervision,
+		Watchdog:     s.Watchdog,
+		KillSwitch:   s.KillSwitch,
 	}
 	if out.Rules == nil {
 		out.Rules = []Rule{}
@@ -129,6 +132,11 @@ func (s *State) marshalDisk() ([]byte, error) {
 	if out.Connections.Outbounds == nil {
 		out.Connections.Outbounds = []configtypes.OutboundConfig{}
 	}
+	if sealed {
+		if err := sealSecrets(&out); err != nil {
+			return nil, err
+		}
+	}
 	// SetEscapeHTML(false): по умолчанию encoding/json экранирует «&», «<» и
 	// «>» в & и подобное — защита для вставки JSON в HTML-страницу,
 	// которая здесь не нужна. В state.json попадают URL подписок и строки
@@ -185,3 +193,28 @@ func maybeBackupSPEC058(path string) error {
 	_, err = io.Copy(dst, src)
 	return err
 }
+
+// Export сериализует s ровно в том виде, в каком его записал бы Save, но без
+// записи на диск и без сдвига UpdatedAt. Нужен CLI `export-state`: выгрузка
+// должна быть годным state.json, а не внутренним JSON структуры State.
+// Секреты — открытыми: выгрузка уходит за пределы установки, где нашего
+// ключа нет.
+func (s *State) Export() ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("state: Export called on nil receiver")
+	}
+	syncConnectionsFromLegacy(s)
+	return s.marshalDisk(false)
+}
+
+// Encode — байты, которые записал бы Save (секреты запечатаны, если
+// шифрование включено), без записи и без сдвига UpdatedAt. Перешифровка
+// файлов при смене ключа: содержимое не меняется, и профили машин,
+// сравниваемые байтами со снимком базы, остаются равны.
+func (s *State) Encode() ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("state: Encode called on nil receiver")
+	}
+	syncConnectionsFromLegacy(s)
+	return s.marshalDisk(true)
+}
@@ -0,0 +1,235 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"singbox-launcher/core/config/configtypes"
+	"singbox-launcher/internal/secretstore"
+	"singbox-launcher/internal/urlredact"
+)
+
+// Поля state.json с секретами шифруются на диске, когда шифрование включено
+// (internal/secretstore): URL подписки (токен в пути или query), URI и
+// config_json ноды (пароли, uuid), учётные данные в options и patch'ах
+// outbounds (глобальных и источников), ключи и токены регистраций WARP.
+// Тела подписок в кеше запечатываются целиком (raw_cache.go). В памяти
+// State всегда открытый — шифрование прозрачно для Save/Load.
+
+// sealSecrets запечатывает секреты в копии для записи; s не меняется.
+func sealSecrets(out *diskStateV6) error {
+	var err error
+	if out.Connections.Outbounds, err = mapOutboundSecrets(out.Connections.Outbounds, secretstore.Seal); err != nil {
+		return err
+	}
+	if len(out.Connections.Sources) > 0 {
+		sources := make([]Source, len(out.Connections.Sources))
+		copy(sources, out.Connections.Sources)
+		for i := range sources {
+			src := &sources[i]
+			if src.URL, err = secretstore.Seal(src.URL); err != nil {
+				return err
+			}
+			if src.URI, err = secretstore.Seal(src.URI); err != nil {
+				return err
+			}
+			if src.ConfigJSON, err = secretstore.SealJSON(src.ConfigJSON); err != nil {
+				return err
+			}
+			if src.Outbounds, err = mapOutboundSecrets(src.Outbounds, secretstore.Seal); err != nil {
+				return err
+			}
+		}
+		out.Connections.Sources = sources
+	}
+	if w := out.WarpAccounts; w != nil {
+		cp := *w
+		if w.WG != nil {
+			wg := *w.WG
+			for _, f := range []*string{&wg.PrivateKey, &wg.Token, &wg.License} {
+				if err := sealField(f); err != nil {
+					return err
+				}
+			}
+			cp.WG = &wg
+		}
+		if w.Masque != nil {
+			mq := *w.Masque
+			for _, f := range []*string{&mq.PrivateKeyDER, &mq.Token} {
+				if err := sealField(f); err != nil {
+					return err
+				}
+			}
+			cp.Masque = &mq
+		}
+		out.WarpAccounts = &cp
+	}
+	return nil
+}
+
+// openSecrets открывает запечатанные поля прочитанного State. Ключа нет —
+// ошибка с secretstore.ErrLocked: собрать конфиг из запечатанных URL нельзя.
+func openSecrets(s *State) error {
+	var err error
+	if s.Connections.Outbounds, err = mapOutboundSecrets(s.Connections.Outbounds, secretstore.Open); err != nil {
+		return err
+	}
+	for i := range s.Connections.Sources {
+		src := &s.Connections.Sources[i]
+		for _, f := range []*string{&src.URL, &src.URI} {
+			if err := openField(f); err != nil {
+				return err
+			}
+		}
+		raw, err := secretstore.OpenJSON(src.ConfigJSON)
+		if err != nil {
+			return fmt.Errorf("state: %w", err)
+		}
+		src.ConfigJSON = raw
+		if src.Outbounds, err = mapOutboundSecrets(src.Outbounds, secretstore.Open); err != nil {
+			return err
+		}
+	}
+	if w := s.WarpAccounts; w != nil {
+		var fields []*string
+		if w.WG != nil {
+			fields = append(fields, &w.WG.PrivateKey, &w.WG.Token, &w.WG.License)
+		}
+		if w.Masque != nil {
+			fields = append(fields, &w.Masque.PrivateKeyDER, &w.Masque.Token)
+		}
+		for _, f := range fields {
+			if err := openField(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mapOutboundSecrets — копия outbounds, где учётные данные в options и
+// patch'ах updates (ключи, которые urlredact считает секретами, на любой
+// глубине: password, uuid, private_key, obfs.password, ...) прошли через f.
+// Исходные map'ы не меняются: sealSecrets работает с копией State.
+func mapOutboundSecrets(in []configtypes.OutboundConfig, f func(string) (string, error)) ([]configtypes.OutboundConfig, error) {
+	if len(in) == 0 {
+		return in, nil
+	}
+	out := make([]configtypes.OutboundConfig, len(in))
+	for i, ob := range in {
+		opts, err := mapSecretValues("", ob.Options, f)
+		if err != nil {
+			return nil, fmt.Errorf("state: outbound %q: %w", ob.Tag, err)
+		}
+		ob.Options = opts.(map[string]interface{})
+		if len(ob.Updates) > 0 {
+			updates := make([]configtypes.OutboundUpdate, len(ob.Updates))
+			for j, u := range ob.Updates {
+				patch, err := mapSecretValues("", u.Patch, f)
+				if err != nil {
+					return nil, fmt.Errorf("state: outbound %q: %w", ob.Tag, err)
+				}
+				u.Patch = patch.(map[string]interface{})
+				updates[j] = u
+			}
+			ob.Updates = updates
+		}
+		out[i] = ob
+	}
+	return out, nil
+}
+
+// mapSecretValues — копия v, где непустые строки под ключами-секретами
+// прошли через f. Элементы массива наследуют ключ массива.
+func mapSecretValues(key string, v interface{}, f func(string) (string, error)) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if t == nil {
+			return t, nil
+		}
+		cp := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			c, err := mapSecretValues(k, child, f)
+			if err != nil {
+				return nil, err
+			}
+			cp[k] = c
+		}
+		return cp, nil
+	case []interface{}:
+		cp := make([]interface{}, len(t))
+		for i, child := range t {
+			c, err := mapSecretValues(key, child, f)
+			if err != nil {
+				return nil, err
+			}
+			cp[i] = c
+		}
+		return cp, nil
+	case string:
+		if t != "" && urlredact.IsSecretKey(key) {
+			return f(t)
+		}
+	}
+	return v, nil
+}
+
+func sealField(f *string) error {
+	v, err := secretstore.Seal(*f)
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	*f = v
+	return nil
+}
+
+func openField(f *string) error {
+	v, err := secretstore.Open(*f)
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	*f = v
+	return nil
+}
+
+// OpenSecretsRaw — state.json с открытыми секретами: для выгрузки за
+// пределы установки (пакет машин), где нашего ключа нет. Файл без
+// запечатанных полей и старые раскладки возвращаются байт в байт.
+func OpenSecretsRaw(raw []byte) ([]byte, error) {
+	if !bytes.Contains(raw, []byte(secretstore.Prefix)) || rawSchemaVersion(raw) < SchemaVersionV6 {
+		return raw, nil
+	}
+	s, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.Export()
+}
+
+// SealSecretsRaw — обратное OpenSecretsRaw для принятого извне state.json:
+// секреты запечатываются, если шифрование включено. Шифрование
+// детерминированное, поэтому одинаковые файлы (база и её снимок) остаются
+// одинаковыми.
+func SealSecretsRaw(raw []byte) ([]byte, error) {
+	if !secretstore.Enabled() || rawSchemaVersion(raw) < SchemaVersionV6 {
+		return raw, nil
+	}
+	s, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.Encode()
+}
+
+func rawSchemaVersion(raw []byte) int {
+	var probe struct {
+		Meta struct {
+			Version int `json:"version"`
+		} `json:"meta"`
+	}
+	if json.Unmarshal(raw, &probe) != nil {
+		return 0
+	}
+	return probe.Meta.Version
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"singbox-launcher/core/config/configtypes"
+	"singbox-launcher/internal/secretstore"
+)
+
+// С включённым шифрованием URL подписки, URI и config_json ноды, учётные
+// данные outbounds, ключ WARP и тело подписки в кеше лежат на диске
+// запечатанными, а Load и ReadRawBody отдают их открытыми; без ключа оба
+// отказывают, а не собирают конфиг из шифротекста.
+func TestSecretsSealedOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := secretstore.Enable(bin, secretstore.SourcePassphrase, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = secretstore.Disable(bin) })
+
+	p := filepath.Join(dir, "state.json")
+	s := New()
+	s.Connections.Sources = []Source{
+		{ID: "a", Type: SourceTypeSubscription, Enabled: true, URL: "https://sub.example/api/sub?token=SUBTOKEN"},
+		{ID: "b", Type: SourceTypeServer, Enabled: true, URI: "vless://UUIDSECRET@host:443#n"},
+		{ID: "c", Type: SourceTypeServer, Enabled: true, ConfigJSON: json.RawMessage(`{"type":"trojan","password":"PWSECRET"}`)},
+	}
+	s.Connections.Sources[0].Outbounds = []configtypes.OutboundConfig{{Tag: "src-ob", Type: "trojan", Options: map[string]interface{}{"password": "SRCOBSECRET"}}}
+	s.Connections.Outbounds = []configtypes.OutboundConfig{{
+		Tag: "hy", Type: "hysteria2",
+		Options: map[string]interface{}{"server": "hy.example", "password": "OBPWSECRET", "obfs": map[string]interface{}{"type": "salamander", "password": "OBFSSECRET"}},
+		Updates: []configtypes.OutboundUpdate{{Ref: configtypes.RefUser, Patch: map[string]interface{}{"options": map[string]interface{}{"password": "PATCHSECRET"}}}},
+	}}
+	s.WarpAccounts = &WarpAccountsSection{WG: &WarpWGAccount{PrivateKey: "WGKEYSECRET", PeerPublic: "peer"}}
+	if err := s.Save(p); err != nil {
+		t.Fatal(err)
+	}
+	subsDir := filepath.Join(dir, "subscriptions")
+	if err := WriteRawBody(subsDir, "a", []byte("trojan://RAWSECRET@host:443#n")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(p)
+	raw, _ := os.ReadFile(filepath.Join(subsDir, "a.raw"))
+	data = append(data, raw...)
+	for _, secret := range []string{"SUBTOKEN", "UUIDSECRET", "PWSECRET", "SRCOBSECRET", "OBPWSECRET", "OBFSSECRET", "PATCHSECRET", "WGKEYSECRET", "RAWSECRET"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("%s written in plaintext", secret)
+		}
+	}
+	if !strings.Contains(string(data), "hy.example") {
+		t.Error("non-secret outbound options sealed too")
+	}
+	if s.Connections.Sources[0].URL != "https://sub.example/api/sub?token=SUBTOKEN" || s.WarpAccounts.WG.PrivateKey != "WGKEYSECRET" ||
+		s.Connections.Outbounds[0].Options["password"] != "OBPWSECRET" {
+		t.Error("Save changed the in-memory state")
+	}
+
+	got, err := Load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Connections.Sources[0].URL != "https://sub.example/api/sub?token=SUBTOKEN" ||
+		got.Connections.Sources[1].URI != "vless://UUIDSECRET@host:443#n" ||
+		string(got.Connections.Sources[2].ConfigJSON) != `{"type":"trojan","password":"PWSECRET"}` ||
+		got.WarpAccounts.WG.PrivateKey != "WGKEYSECRET" || got.WarpAccounts.WG.PeerPublic != "peer" {
+		t.Errorf("loaded = %+v %+v", got.Connections.Sources, got.WarpAccounts.WG)
+	}
+	ob := got.Connections.Outbounds[0]
+	if ob.Options["password"] != "OBPWSECRET" || ob.Options["obfs"].(map[string]interface{})["password"] != "OBFSSECRET" ||
+		ob.Updates[0].Patch["options"].(map[string]interface{})["password"] != "PATCHSECRET" ||
+		got.Connections.Sources[0].Outbounds[0].Options["password"] != "SRCOBSECRET" {
+		t.Errorf("loaded outbounds = %+v / %+v", got.Connections.Outbounds, got.Connections.Sources[0].Outbounds)
+	}
+	if body, err := ReadRawBody(subsDir, "a"); err != nil || string(body) != "trojan://RAWSECRET@host:443#n" {
+		t.Errorf("raw body = %q (%v)", body, err)
+	}
+
+	t.Setenv(secretstore.PassphraseEnv, "")
+	if err := secretstore.Init(bin); !errors.Is(err, secretstore.ErrLocked) {
+		t.Fatalf("init: %v", err)
+	}
+	if _, err := Load(p); !errors.Is(err, secretstore.ErrLocked) {
+		t.Errorf("locked load: %v", err)
+	}
+	if _, err := ReadRawBody(subsDir, "a"); !errors.Is(err, secretstore.ErrLocked) {
+		t.Errorf("locked raw read: %v", err)
+	}
+}
@@ -141,6 +141,21 @@ type State struct {
 	// что MASQUE H2/H3 ложатся на один ключ (как в LxBox). Галочка «создать
 	// новые ключи» в визарде сбрасывает соответствующую запись.
 	WarpAccounts *WarpAccountsSection
+
+	// === Supervision ===
+
+	// Supervision — политика присмотра за ядром этого профиля: перезапуски
+	// после падения, паузы между ними и проверка живости (supervision.go).
+	// nil — встроенные значения.
+	Supervision *SupervisionPolicy
+
+	// Watchdog — сторож связности: переключение selector-групп с
+	// отвалившегося узла на живой (watchdog.go). nil — выключен.
+	Watchdog *WatchdogPolicy
+
+	// KillSwitch — блокировка трафика мимо ядра, пока VPN должен работать
+	// (kill_switch.go). nil — выключен.
+	KillSwitch *KillSwitchPolicy
 }
 
 // SelectableRuleState — выбор пользователя для правила, определённого в шаблоне.
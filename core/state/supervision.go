@@ -0,0 +1,102 @@
+package state
+
+import "fmt"
+
+// Виды проверки живости ядра (SupervisionProbe.Kind).
+const (
+	// SupervisionProbeClashAPI — Clash API отвечает на GET /version: процесс
+	// не завис. Не зависит от сети за узлом.
+	SupervisionProbeClashAPI = "clash_api"
+	// SupervisionProbeURLTest — задержка через текущий селектор
+	// (/proxies/{group}/delay): трафик реально ходит. Падает и при
+	// отвалившемся узле или сети, поэтому перезапуски по ней считаются в
+	// общий лимит попыток.
+	SupervisionProbeURLTest = "url_test"
+)
+
+// SupervisionPolicy — как лаунчер присматривает за ядром профиля
+// (state.supervision). Нулевые поля — встроенные значения: 3 попытки,
+// паузы 2 с → ×2 → до 60 с с разбросом 20 %, окно стабильности 180 с, без
+// проверки живости.
+type SupervisionPolicy struct {
+	// MaxAttempts — перезапусков подряд до отказа.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// BackoffInitialSec / BackoffMaxSec / BackoffMultiplier — пауза перед
+	// n-м перезапуском: initial × multiplier^(n-1), не больше max.
+	BackoffInitialSec int     `json:"backoff_initial_sec,omitempty"`
+	BackoffMaxSec     int     `json:"backoff_max_sec,omitempty"`
+	BackoffMultiplier float64 `json:"backoff_multiplier,omitempty"`
+	// JitterPercent — случайный разброс паузы ±%; nil — 20, 0 — без
+	// разброса.
+	JitterPercent *int `json:"jitter_percent,omitempty"`
+	// StabilitySec — сколько ядро должно проработать, чтобы счётчик
+	// падений обнулился.
+	StabilitySec int `json:"stability_sec,omitempty"`
+	// Probe — проверка живости работающего ядра; nil — выключена.
+	Probe *SupervisionProbe `json:"probe,omitempty"`
+}
+
+// SupervisionProbe — периодическая проверка, что ядро не просто живо, а
+// работает. Failures неудач подряд — ядро перезапускается как упавшее.
+type SupervisionProbe struct {
+	Kind        string `json:"kind"`
+	IntervalSec int    `json:"interval_sec,omitempty"`
+	Failures    int    `json:"failures,omitempty"`
+}
+
+// SupervisionFieldError — недопустимое значение поля политики.
+type SupervisionFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *SupervisionFieldError) Error() string { return e.Field + ": " + e.Message }
+
+// Validate проверяет границы. Нулевые значения допустимы (встроенные).
+func (p *SupervisionPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	check := func(field string, v, lo, hi int) error {
+		if v != 0 && (v < lo || v > hi) {
+			return &SupervisionFieldError{Field: field, Message: fmt.Sprintf("must be between %d and %d", lo, hi)}
+		}
+		return nil
+	}
+	for _, c := range []struct {
+		field     string
+		v, lo, hi int
+	}{
+		{"max_attempts", p.MaxAttempts, 1, 100},
+		{"backoff_initial_sec", p.BackoffInitialSec, 1, 600},
+		{"backoff_max_sec", p.BackoffMaxSec, 1, 3600},
+		{"stability_sec", p.StabilitySec, 10, 86400},
+	} {
+		if err := check(c.field, c.v, c.lo, c.hi); err != nil {
+			return err
+		}
+	}
+	if p.BackoffInitialSec != 0 && p.BackoffMaxSec != 0 && p.BackoffMaxSec < p.BackoffInitialSec {
+		return &SupervisionFieldError{Field: "backoff_max_sec", Message: "must not be less than backoff_initial_sec"}
+	}
+	if p.BackoffMultiplier != 0 && (p.BackoffMultiplier < 1 || p.BackoffMultiplier > 10) {
+		return &SupervisionFieldError{Field: "backoff_multiplier", Message: "must be between 1 and 10"}
+	}
+	if p.JitterPercent != nil && (*p.JitterPercent < 0 || *p.JitterPercent > 50) {
+		return &SupervisionFieldError{Field: "jitter_percent", Message: "must be between 0 and 50"}
+	}
+	if p.Probe != nil {
+		switch p.Probe.Kind {
+		case SupervisionProbeClashAPI, SupervisionProbeURLTest:
+		default:
+			return &SupervisionFieldError{Field: "probe.kind", Message: fmt.Sprintf("must be %q or %q", SupervisionProbeClashAPI, SupervisionProbeURLTest)}
+		}
+		if err := check("probe.interval_sec", p.Probe.IntervalSec, 5, 3600); err != nil {
+			return err
+		}
+		if err := check("probe.failures", p.Probe.Failures, 1, 20); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// state.supervision переживает Save/Load и не появляется в файле, пока не
+// задана: профили без политики остаются байт-в-байт прежними.
+func TestSupervisionSurvivesSaveLoad(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "state.json")
+	s := New()
+	if err := s.Save(p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, _ := os.ReadFile(p)
+	if strings.Contains(string(data), "supervision") {
+		t.Fatalf("empty policy written: %s", data)
+	}
+
+	jitter := 0
+	s.Supervision = &SupervisionPolicy{
+		MaxAttempts:       5,
+		BackoffInitialSec: 1,
+		BackoffMultiplier: 1.5,
+		JitterPercent:     &jitter,
+		Probe:             &SupervisionProbe{Kind: SupervisionProbeURLTest, IntervalSec: 20},
+	}
+	if err := s.Save(p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	sp := got.Supervision
+	if sp == nil || sp.MaxAttempts != 5 || sp.BackoffMultiplier != 1.5 || sp.JitterPercent == nil || *sp.JitterPercent != 0 ||
+		sp.Probe == nil || sp.Probe.Kind != SupervisionProbeURLTest || sp.Probe.IntervalSec != 20 {
+		t.Errorf("supervision = %+v", sp)
+	}
+}
+
+func TestSupervisionPolicyValidate(t *testing.T) {
+	bad := 60
+	for _, c := range []struct {
+		p     *SupervisionPolicy
+		field string
+	}{
+		{nil, ""},
+		{&SupervisionPolicy{}, ""},
+		{&SupervisionPolicy{MaxAttempts: -1}, "max_attempts"},
+		{&SupervisionPolicy{BackoffInitialSec: 30, BackoffMaxSec: 10}, "backoff_max_sec"},
+		{&SupervisionPolicy{BackoffMultiplier: 0.5}, "backoff_multiplier"},
+		{&SupervisionPolicy{JitterPercent: &bad}, "jitter_percent"},
+		{&SupervisionPolicy{StabilitySec: 5}, "stability_sec"},
+		{&SupervisionPolicy{Probe: &SupervisionProbe{Kind: "ping"}}, "probe.kind"},
+		{&SupervisionPolicy{Probe: &SupervisionProbe{Kind: SupervisionProbeClashAPI, IntervalSec: 1}}, "probe.interval_sec"},
+		{&SupervisionPolicy{Probe: &SupervisionProbe{Kind: SupervisionProbeClashAPI, IntervalSec: 10, Failures: 2}}, ""},
+	} {
+		err := c.p.Validate()
+		var fe *SupervisionFieldError
+		switch {
+		case c.field == "" && err != nil:
+			t.Errorf("%+v: unexpected %v", c.p, err)
+		case c.field != "" && (!errors.As(err, &fe) || fe.Field != c.field):
+			t.Errorf("%+v: err = %v, want field %s", c.p, err, c.field)
+		}
+	}
+}
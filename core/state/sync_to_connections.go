@@ -74,6 +74,7 @@ func syncConnectionsFromLegacy(s *State) {
 				DetourTag:               p.DetourTag,
 				DetourNodeHash:          p.DetourNodeHash,  // SPEC 101
 				DetourNodeLabel:         p.DetourNodeLabel, // SPEC 101
+				DisabledNodes:           p.DisabledNodes,   // SPEC 094 D4
 			}
 			if existing, ok := oldByURL[p.Source]; ok {
 				src.ID = existing.ID
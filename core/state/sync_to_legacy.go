@@ -28,6 +28,7 @@ func syncLegacyFromConnections(s *State) {
 				DetourTag:               src.DetourTag,
 				DetourNodeHash:          src.DetourNodeHash,  // SPEC 101
 				DetourNodeLabel:         src.DetourNodeLabel, // SPEC 101
+				DisabledNodes:           src.DisabledNodes,   // SPEC 094 D4
 			}
 			if src.Tag != nil {
 				ps.TagPrefix = src.Tag.Prefix
@@ -60,3 +61,11 @@ func syncLegacyFromConnections(s *State) {
 	}
 	s.ParserConfig.ParserConfig.Parser.Reload = s.Connections.Defaults.Reload
 }
+
+// SyncLegacyFromConnections перестраивает legacy-view (ParserConfig.Proxies /
+// Outbounds) из Connections. Нужен callsite'ам, которые мутируют Connections
+// напрямую на загруженном state: Save синхронизирует legacy → canonical, и без
+// этого вызова правка Connections была бы перетёрта старым legacy-view.
+func (s *State) SyncLegacyFromConnections() {
+	syncLegacyFromConnections(s)
+}
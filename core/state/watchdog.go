@@ -0,0 +1,67 @@
+package state
+
+import "fmt"
+
+// WatchdogPolicy — сторож связности профиля (state.watchdog): раз в
+// IntervalSec проверяет выбранный узел каждой группы из Groups и после
+// Failures неудач подряд переключает группу на лучший из остальных узлов.
+// Нулевые поля — встроенные значения: раз в 60 с, 3 неудачи, без порога
+// задержки, возврат выключен; для возврата — 3 удачи подряд и не раньше
+// чем через 300 с после переключения.
+type WatchdogPolicy struct {
+	Enabled bool `json:"enabled"`
+	// Groups — selector-группы под присмотром (теги из config.json).
+	Groups      []string `json:"groups,omitempty"`
+	IntervalSec int      `json:"interval_sec,omitempty"`
+	Failures    int      `json:"failures,omitempty"`
+	// MaxDelayMs — задержка выше порога тоже неудача; 0 — годится любой
+	// ответ.
+	MaxDelayMs int `json:"max_delay_ms,omitempty"`
+	// SwitchBack — вернуть исходный узел, когда он снова проходит проверку.
+	SwitchBack bool `json:"switch_back,omitempty"`
+	// RecoverChecks / HoldSec — гистерезис возврата: исходный узел должен
+	// пройти RecoverChecks проверок подряд, и с переключения должно пройти
+	// HoldSec, иначе группа качалась бы между узлами на каждом всплеске.
+	RecoverChecks int `json:"recover_checks,omitempty"`
+	HoldSec       int `json:"hold_sec,omitempty"`
+}
+
+// WatchdogFieldError — недопустимое значение поля политики сторожа.
+type WatchdogFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *WatchdogFieldError) Error() string { return e.Field + ": " + e.Message }
+
+// Validate проверяет границы. Нулевые значения допустимы (встроенные).
+func (p *WatchdogPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, c := range []struct {
+		field     string
+		v, lo, hi int
+	}{
+		{"interval_sec", p.IntervalSec, 10, 3600},
+		{"failures", p.Failures, 1, 20},
+		{"max_delay_ms", p.MaxDelayMs, 1, 60000},
+		{"recover_checks", p.RecoverChecks, 1, 20},
+		{"hold_sec", p.HoldSec, 1, 86400},
+	} {
+		if c.v != 0 && (c.v < c.lo || c.v > c.hi) {
+			return &WatchdogFieldError{Field: c.field, Message: fmt.Sprintf("must be between %d and %d", c.lo, c.hi)}
+		}
+	}
+	seen := make(map[string]bool, len(p.Groups))
+	for _, g := range p.Groups {
+		if g == "" || seen[g] {
+			return &WatchdogFieldError{Field: "groups", Message: "group tags must be non-empty and unique"}
+		}
+		seen[g] = true
+	}
+	if p.Enabled && len(p.Groups) == 0 {
+		return &WatchdogFieldError{Field: "groups", Message: "at least one group is required when enabled"}
+	}
+	return nil
+}
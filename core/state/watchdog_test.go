@@ -0,0 +1,66 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// state.watchdog переживает Save/Load и не пишется, пока не задан.
+func TestWatchdogSurvivesSaveLoad(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "state.json")
+	s := New()
+	if err := s.Save(p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, _ := os.ReadFile(p)
+	if strings.Contains(string(data), "watchdog") {
+		t.Fatalf("empty policy written: %s", data)
+	}
+
+	s.Watchdog = &WatchdogPolicy{Enabled: true, Groups: []string{"proxy-out", "ai"}, Failures: 2, SwitchBack: true, HoldSec: 60}
+	if err := s.Save(p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	w := got.Watchdog
+	if w == nil || !w.Enabled || len(w.Groups) != 2 || w.Groups[1] != "ai" || w.Failures != 2 || !w.SwitchBack || w.HoldSec != 60 {
+		t.Errorf("watchdog = %+v", w)
+	}
+}
+
+func TestWatchdogPolicyValidate(t *testing.T) {
+	for _, c := range []struct {
+		p     *WatchdogPolicy
+		field string
+	}{
+		{nil, ""},
+		{&WatchdogPolicy{}, ""},
+		{&WatchdogPolicy{Enabled: true, Groups: []string{"a"}, IntervalSec: 30, MaxDelayMs: 800}, ""},
+		{&WatchdogPolicy{Enabled: true}, "groups"},
+		{&WatchdogPolicy{Groups: []string{"a", "a"}}, "groups"},
+		{&WatchdogPolicy{Groups: []string{""}}, "groups"},
+		{&WatchdogPolicy{IntervalSec: 5}, "interval_sec"},
+		{&WatchdogPolicy{Failures: 21}, "failures"},
+		{&WatchdogPolicy{MaxDelayMs: -1}, "max_delay_ms"},
+		{&WatchdogPolicy{RecoverChecks: 50}, "recover_checks"},
+		{&WatchdogPolicy{HoldSec: 100000}, "hold_sec"},
+	} {
+		err := c.p.Validate()
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected %v", c.p, err)
+			}
+			continue
+		}
+		var fe *WatchdogFieldError
+		if !errors.As(err, &fe) || fe.Field != c.field {
+			t.Errorf("%+v: err = %v, want field %s", c.p, err, c.field)
+		}
+	}
+}
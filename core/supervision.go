@@ -0,0 +1,597 @@
+package core
+
+// Присмотр за ядром в classic-режиме: политика перезапусков профиля
+// (state.supervision — попытки, паузы с разбросом, окно стабильности),
+// активная проверка живости работающего ядра и журнал решений
+// logs/supervision.jsonl. Сам выбор «что делать после выхода» остаётся в
+// decideCrashAction (crash_handler.go), здесь — только параметры и учёт.
+// В daemon-режиме ядро сторожит демон, этот код его не касается.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"singbox-launcher/api"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/ctxutil"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+)
+
+const (
+	defaultBackoffInitial    = 2 * time.Second
+	defaultBackoffMax        = 60 * time.Second
+	defaultBackoffMultiplier = 2.0
+	defaultJitterPercent     = 20
+	defaultProbeInterval     = 30 * time.Second
+	defaultProbeFailures     = 3
+
+	// supervisionLogName — журнал решений в logs/, по строке JSON на событие.
+	supervisionLogName = "supervision.jsonl"
+	// supervisionLogMaxSize — при превышении файл уезжает в .1 (одна копия).
+	supervisionLogMaxSize = 1 << 20
+)
+
+// ErrSupervisionNoProfile — state.json ещё нет: политику некуда сохранить.
+var ErrSupervisionNoProfile = errors.New("supervision: no profile state yet (run the configurator first)")
+
+// Виды событий журнала присмотра.
+const (
+	SupervisionEventCrash            = "crash"             // ядро вышло не по команде пользователя
+	SupervisionEventRestart          = "restart"           // перезапуск назначен через DelayMs
+	SupervisionEventRestartCancelled = "restart_cancelled" // за время паузы ядро остановили или уже запустили
+	SupervisionEventGiveUp           = "give_up"           // попытки кончились
+	SupervisionEventStable           = "stable"            // проработало окно стабильности, счётчик обнулён
+	SupervisionEventProbeFailed      = "probe_failed"
+	SupervisionEventProbeRecovered   = "probe_recovered"
+	SupervisionEventProbeRestart     = "probe_restart" // зависшее ядро убито ради перезапуска
+	SupervisionEventProbeUnavailable = "probe_unavailable"
+	SupervisionEventPolicyChanged    = "policy_changed"
+)
+
+// SupervisionSettings — действующие значения политики (нули заменены
+// встроенными). ProbeKind "" — проверка выключена.
+type SupervisionSettings struct {
+	MaxAttempts       int
+	BackoffInitial    time.Duration
+	BackoffMax        time.Duration
+	BackoffMultiplier float64
+	JitterPercent     int
+	StabilityWindow   time.Duration
+	ProbeKind         string
+	ProbeInterval     time.Duration
+	ProbeFailures     int
+}
+
+// SupervisionEvent — одна запись журнала присмотра.
+type SupervisionEvent struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	Attempt     int       `json:"attempt,omitempty"`
+	MaxAttempts int       `json:"max_attempts,omitempty"`
+	DelayMs     int64     `json:"delay_ms,omitempty"`
+	PID         int       `json:"pid,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+}
+
+// SupervisionProbeStatus — последний результат проверки живости.
+type SupervisionProbeStatus struct {
+	Time     time.Time
+	OK       bool
+	Error    string
+	Failures int
+}
+
+// SupervisionStatus — снимок для окна «Присмотр за ядром» и Debug API.
+type SupervisionStatus struct {
+	Policy    *state.SupervisionPolicy
+	Effective SupervisionSettings
+	Attempts  int
+	Running   bool
+	// Supported — false в daemon-режиме: там перезапусками ведает демон.
+	Supported bool
+	LastProbe *SupervisionProbeStatus
+}
+
+// supervisionState — кэш политики и состояние проверки. Политика читается
+// из state.json при каждом запуске ядра и при сохранении из окна, а не на
+// каждом тике проверки.
+type supervisionState struct {
+	mu        sync.Mutex
+	policy    *state.SupervisionPolicy
+	loaded    bool
+	lastProbe *SupervisionProbeStatus
+	// pending — перезапуск ждёт паузы; Stop снимает его, и по окончании
+	// паузы ядро не поднимается. Защищён CmdMutex, а не mu.
+	pending bool
+	// healthRestart — процесс убит проверкой живости: его выход — сбой,
+	// даже если код выхода 0. Защищён CmdMutex.
+	healthRestart bool
+}
+
+// ResolveSupervisionPolicy подставляет встроенные значения вместо нулей.
+func ResolveSupervisionPolicy(p *state.SupervisionPolicy) SupervisionSettings {
+	s := SupervisionSettings{
+		MaxAttempts:       restartAttempts,
+		BackoffInitial:    defaultBackoffInitial,
+		BackoffMax:        defaultBackoffMax,
+		BackoffMultiplier: defaultBackoffMultiplier,
+		JitterPercent:     defaultJitterPercent,
+		StabilityWindow:   stabilityThreshold,
+		ProbeInterval:     defaultProbeInterval,
+		ProbeFailures:     defaultProbeFailures,
+	}
+	if p == nil {
+		return s
+	}
+	if p.MaxAttempts > 0 {
+		s.MaxAttempts = p.MaxAttempts
+	}
+	if p.BackoffInitialSec > 0 {
+		s.BackoffInitial = time.Duration(p.BackoffInitialSec) * time.Second
+	}
+	if p.BackoffMaxSec > 0 {
+		s.BackoffMax = time.Duration(p.BackoffMaxSec) * time.Second
+	}
+	if s.BackoffMax < s.BackoffInitial {
+		s.BackoffMax = s.BackoffInitial
+	}
+	if p.BackoffMultiplier >= 1 {
+		s.BackoffMultiplier = p.BackoffMultiplier
+	}
+	if p.JitterPercent != nil && *p.JitterPercent >= 0 && *p.JitterPercent <= 50 {
+		s.JitterPercent = *p.JitterPercent
+	}
+	if p.StabilitySec > 0 {
+		s.StabilityWindow = time.Duration(p.StabilitySec) * time.Second
+	}
+	if p.Probe != nil {
+		s.ProbeKind = p.Probe.Kind
+		if p.Probe.IntervalSec > 0 {
+			s.ProbeInterval = time.Duration(p.Probe.IntervalSec) * time.Second
+		}
+		if p.Probe.Failures > 0 {
+			s.ProbeFailures = p.Probe.Failures
+		}
+	}
+	return s
+}
+
+// Policy — действующие значения в схеме state.supervision (все поля
+// заполнены; Probe nil — проверка выключена).
+func (s SupervisionSettings) Policy() state.SupervisionPolicy {
+	jitter := s.JitterPercent
+	p := state.SupervisionPolicy{
+		MaxAttempts:       s.MaxAttempts,
+		BackoffInitialSec: int(s.BackoffInitial / time.Second),
+		BackoffMaxSec:     int(s.BackoffMax / time.Second),
+		BackoffMultiplier: s.BackoffMultiplier,
+		JitterPercent:     &jitter,
+		StabilitySec:      int(s.StabilityWindow / time.Second),
+	}
+	if s.ProbeKind != "" {
+		p.Probe = &state.SupervisionProbe{
+			Kind:        s.ProbeKind,
+			IntervalSec: int(s.ProbeInterval / time.Second),
+			Failures:    s.ProbeFailures,
+		}
+	}
+	return p
+}
+
+// Backoff — пауза перед attempt-м перезапуском подряд (с 1):
+// initial × multiplier^(attempt-1), не больше max, затем ±jitter %.
+// rnd возвращает [0, 1); nil — без разброса.
+func (s SupervisionSettings) Backoff(attempt int, rnd func() float64) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(s.BackoffInitial) * math.Pow(s.BackoffMultiplier, float64(attempt-1))
+	if d > float64(s.BackoffMax) || math.IsInf(d, 0) {
+		d = float64(s.BackoffMax)
+	}
+	if rnd != nil && s.JitterPercent > 0 {
+		d *= 1 + float64(s.JitterPercent)/100*(2*rnd()-1)
+	}
+	return time.Duration(d).Round(time.Millisecond)
+}
+
+// loadSupervisionPolicy читает state.supervision активного профиля и
+// обновляет кэш. Нет state.json или он битый — встроенная политика.
+func (ac *AppController) loadSupervisionPolicy() *state.SupervisionPolicy {
+	var p *state.SupervisionPolicy
+	if ac.FileService != nil {
+		s, err := state.Load(platform.GetWizardStatePath(ac.FileService.ExecDir))
+		switch {
+		case err == nil:
+			p = s.Supervision
+		case !errors.Is(err, state.ErrNotFound):
+			debuglog.WarnLog("supervision: load state: %v; using defaults", err)
+		}
+	}
+	ac.supervision.mu.Lock()
+	ac.supervision.policy, ac.supervision.loaded = p, true
+	ac.supervision.mu.Unlock()
+	return p
+}
+
+// SupervisionSettings — действующая политика (из кэша; при первом
+// обращении читается state.json).
+func (ac *AppController) SupervisionSettings() SupervisionSettings {
+	ac.supervision.mu.Lock()
+	p, loaded := ac.supervision.policy, ac.supervision.loaded
+	ac.supervision.mu.Unlock()
+	if !loaded {
+		p = ac.loadSupervisionPolicy()
+	}
+	return ResolveSupervisionPolicy(p)
+}
+
+// SupervisionStatus — политика профиля, действующие значения, счётчик
+// попыток и последняя проверка живости.
+func (ac *AppController) SupervisionStatus() SupervisionStatus {
+	p := ac.loadSupervisionPolicy()
+	st := SupervisionStatus{
+		Policy:    p,
+		Effective: ResolveSupervisionPolicy(p),
+		Supported: ac.BackendMode() != BackendDaemon,
+	}
+	ac.CmdMutex.Lock()
+	st.Attempts = ac.ConsecutiveCrashAttempts
+	st.Running = ac.RunningState != nil && ac.RunningState.IsRunning()
+	ac.CmdMutex.Unlock()
+	ac.supervision.mu.Lock()
+	if ac.supervision.lastProbe != nil {
+		lp := *ac.supervision.lastProbe
+		st.LastProbe = &lp
+	}
+	ac.supervision.mu.Unlock()
+	return st
+}
+
+// SetSupervisionPolicy проверяет и сохраняет политику в state.json
+// (load-mutate-save, как log_level.go). nil — вернуть встроенную.
+// Действует сразу: пауза и лимит — со следующего сбоя, проверка живости —
+// со следующего тика.
+func (ac *AppController) SetSupervisionPolicy(actor audit.Actor, p *state.SupervisionPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if ac.FileService == nil {
+		return errors.New("core: no controller")
+	}
+	statePath := platform.GetWizardStatePath(ac.FileService.ExecDir)
+	s, err := state.Load(statePath)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return ErrSupervisionNoProfile
+		}
+		return fmt.Errorf("load state: %w", err)
+	}
+	before := ac.StateSnapshot()
+	s.Supervision = p
+	if err := s.Save(statePath); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	ac.ApplyStateChange(actor, before)
+	ac.supervision.mu.Lock()
+	ac.supervision.policy, ac.supervision.loaded = p, true
+	ac.supervision.mu.Unlock()
+	eff := ResolveSupervisionPolicy(p)
+	ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventPolicyChanged, MaxAttempts: eff.MaxAttempts,
+		Detail: fmt.Sprintf("backoff %v→%v ×%g ±%d%%, stability %v, probe %q", eff.BackoffInitial, eff.BackoffMax,
+			eff.BackoffMultiplier, eff.JitterPercent, eff.StabilityWindow, eff.ProbeKind)})
+	return nil
+}
+
+// supervisionLogPath — logs/supervision.jsonl.
+func (ac *AppController) supervisionLogPath() string {
+	if ac.FileService == nil {
+		return ""
+	}
+	return filepath.Join(platform.GetLogsDir(ac.FileService.ExecDir), supervisionLogName)
+}
+
+// recordSupervision дописывает событие в журнал и дублирует в debuglog.
+// Ошибка записи журнала присмотр не останавливает.
+func (ac *AppController) recordSupervision(ev SupervisionEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	debuglog.InfoLog("supervision: %s attempt=%d/%d delay=%dms pid=%d %s", ev.Kind, ev.Attempt, ev.MaxAttempts, ev.DelayMs, ev.PID, ev.Detail)
+	path := ac.supervisionLogPath()
+	if path == "" {
+		return
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	ac.supervision.mu.Lock()
+	defer ac.supervision.mu.Unlock()
+	if err := appendSupervisionLog(path, line); err != nil {
+		debuglog.WarnLog("supervision: write %s: %v", path, err)
+	}
+}
+
+func appendSupervisionLog(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Size()+int64(len(line)) > supervisionLogMaxSize {
+		_ = os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, platform.DefaultFileMode)
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(append(line, '\n'))
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	return werr
+}
+
+// SupervisionLog — последние limit событий журнала, новые в конце.
+// limit <= 0 — все из текущего файла.
+func (ac *AppController) SupervisionLog(limit int) ([]SupervisionEvent, error) {
+	path := ac.supervisionLogPath()
+	if path == "" {
+		return nil, nil
+	}
+	ac.supervision.mu.Lock()
+	data, err := os.ReadFile(path)
+	ac.supervision.mu.Unlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []SupervisionEvent{}, nil
+		}
+		return nil, err
+	}
+	return parseSupervisionLog(data, limit), nil
+}
+
+// parseSupervisionLog разбирает JSONL; битые строки (обрыв записи)
+// пропускаются.
+func parseSupervisionLog(data []byte, limit int) []SupervisionEvent {
+	events := []SupervisionEvent{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var ev SupervisionEvent
+		if json.Unmarshal(sc.Bytes(), &ev) == nil && ev.Kind != "" {
+			events = append(events, ev)
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+// supervisionRand — разброс пауз; в тестах не используется (Backoff с nil).
+var supervisionRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+var supervisionRandMu sync.Mutex
+
+func supervisionJitter() float64 {
+	supervisionRandMu.Lock()
+	defer supervisionRandMu.Unlock()
+	return supervisionRand.Float64()
+}
+
+// planCrashRestartLocked записывает сбой и назначенный перезапуск и
+// возвращает паузу. Вызывается под CmdMutex после decideCrashAction.
+func (ac *AppController) planCrashRestartLocked(eff SupervisionSettings, pid int, detail string) time.Duration {
+	delay := eff.Backoff(ac.ConsecutiveCrashAttempts, supervisionJitter)
+	ac.supervision.pending = true
+	ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventCrash, Attempt: ac.ConsecutiveCrashAttempts,
+		MaxAttempts: eff.MaxAttempts, PID: pid, Detail: detail})
+	ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventRestart, Attempt: ac.ConsecutiveCrashAttempts,
+		MaxAttempts: eff.MaxAttempts, DelayMs: delay.Milliseconds()})
+	return delay
+}
+
+// waitCrashRestart выдерживает паузу без CmdMutex и решает, поднимать ли
+// ядро: false — за паузу его остановили (Stop), запустили вручную или
+// закрывают приложение.
+func (ac *AppController) waitCrashRestart(delay time.Duration) bool {
+	ctx := ac.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	slept := ctxutil.SleepWithContext(ctx, delay) == nil
+	ac.CmdMutex.Lock()
+	defer ac.CmdMutex.Unlock()
+	pending := ac.supervision.pending
+	ac.supervision.pending = false
+	if slept && pending && !ac.RunningState.IsRunning() {
+		return true
+	}
+	if slept {
+		ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventRestartCancelled, Attempt: ac.ConsecutiveCrashAttempts})
+	}
+	return false
+}
+
+// recordGiveUpLocked — попытки кончились, ядро остаётся остановленным.
+func (ac *AppController) recordGiveUpLocked(eff SupervisionSettings, pid int, detail string) {
+	ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventCrash, Attempt: eff.MaxAttempts + 1,
+		MaxAttempts: eff.MaxAttempts, PID: pid, Detail: detail})
+	ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventGiveUp, MaxAttempts: eff.MaxAttempts})
+}
+
+// takeHealthRestartLocked — был ли выход вызван проверкой живости (флаг
+// сбрасывается). Под CmdMutex.
+func (ac *AppController) takeHealthRestartLocked() bool {
+	v := ac.supervision.healthRestart
+	ac.supervision.healthRestart = false
+	return v
+}
+
+// scheduleCrashCounterReset обнуляет счётчик падений, если перезапущенное
+// ядро проработало окно стабильности политики.
+func (ac *AppController) scheduleCrashCounterReset(eff SupervisionSettings) {
+	currentAttemptCount := ac.ConsecutiveCrashAttempts
+	ctx := ac.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go func() {
+		if ctxutil.SleepWithContext(ctx, eff.StabilityWindow) != nil {
+			return
+		}
+		ac.CmdMutex.Lock()
+		defer ac.CmdMutex.Unlock()
+		if ac.RunningState.IsRunning() && ac.ConsecutiveCrashAttempts == currentAttemptCount {
+			ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventStable, Attempt: currentAttemptCount,
+				Detail: fmt.Sprintf("ran for %v", eff.StabilityWindow)})
+			ac.ConsecutiveCrashAttempts = 0
+			if ac.UIService != nil && ac.UIService.UpdateCoreStatusFunc != nil {
+				ac.UIService.UpdateCoreStatusFunc()
+			}
+		}
+	}()
+}
+
+// watchCoreHealth — проверка живости запуска pid по политике профиля.
+// Зовётся после каждого успешного запуска рядом с watchCoreTrial; политика
+// перечитывается из state.json здесь же. Живёт, пока pid — текущий
+// запуск. ProbeFailures неудач подряд — процесс убивается, Monitor
+// считает это сбоем и перезапускает по общей политике.
+func (ac *AppController) watchCoreHealth(pid int) {
+	ac.loadSupervisionPolicy()
+	ctx := ac.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go func() {
+		failures := 0
+		unavailable := false
+		for {
+			eff := ac.SupervisionSettings()
+			if ctxutil.SleepWithContext(ctx, eff.ProbeInterval) != nil {
+				return
+			}
+			ac.CmdMutex.Lock()
+			same := ac.RunningState.IsRunning() && ac.currentCorePIDLocked() == pid
+			ac.CmdMutex.Unlock()
+			if !same {
+				return
+			}
+			eff = ac.SupervisionSettings()
+			if eff.ProbeKind == "" {
+				failures, unavailable = 0, false
+				continue
+			}
+			err := ac.runSupervisionProbe(eff.ProbeKind)
+			if errors.Is(err, api.ErrPlatformInterrupt) {
+				continue // сон машины — не повод перезапускать
+			}
+			if errors.Is(err, errProbeUnavailable) {
+				if !unavailable {
+					ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventProbeUnavailable, PID: pid, Detail: eff.ProbeKind})
+				}
+				unavailable = true
+				continue
+			}
+			unavailable = false
+			if err == nil {
+				if failures > 0 {
+					ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventProbeRecovered, PID: pid,
+						Detail: fmt.Sprintf("%s ok after %d failure(s)", eff.ProbeKind, failures)})
+				}
+				failures = 0
+				ac.setLastProbe(true, nil, 0)
+				continue
+			}
+			failures++
+			ac.setLastProbe(false, err, failures)
+			ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventProbeFailed, Attempt: failures,
+				MaxAttempts: eff.ProbeFailures, PID: pid, Detail: fmt.Sprintf("%s: %v", eff.ProbeKind, err)})
+			if failures >= eff.ProbeFailures {
+				ac.killHungCore(pid, fmt.Sprintf("%s failed %d times in a row", eff.ProbeKind, failures))
+				return
+			}
+		}
+	}()
+}
+
+// errProbeUnavailable — проверку сейчас не выполнить (Clash API выключен
+// в конфиге, нет группы-селектора); это не признак зависания.
+var errProbeUnavailable = errors.New("probe unavailable")
+
+// runSupervisionProbe — одна проверка: clash_api — GET /version,
+// url_test — задержка через текущую группу-селектор.
+func (ac *AppController) runSupervisionProbe(kind string) error {
+	if ac.APIService == nil {
+		return errProbeUnavailable
+	}
+	baseURL, token, enabled := ac.APIService.GetClashAPIConfig()
+	if !enabled || baseURL == "" {
+		return errProbeUnavailable
+	}
+	switch kind {
+	case state.SupervisionProbeClashAPI:
+		return api.TestAPIConnection(baseURL, token)
+	case state.SupervisionProbeURLTest:
+		group := ac.APIService.GetSelectedClashGroup()
+		if group == "" {
+			return errProbeUnavailable
+		}
+		_, err := api.GetDelay(baseURL, token, group)
+		return err
+	}
+	return errProbeUnavailable
+}
+
+func (ac *AppController) setLastProbe(ok bool, err error, failures int) {
+	st := &SupervisionProbeStatus{Time: time.Now(), OK: ok, Failures: failures}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	ac.supervision.mu.Lock()
+	ac.supervision.lastProbe = st
+	ac.supervision.mu.Unlock()
+}
+
+// killHungCore жёстко завершает зависший запуск pid (зависший процесс
+// может не отвечать на SIGINT). Флаг healthRestart заставляет Monitor
+// считать выход сбоем даже при коде 0.
+func (ac *AppController) killHungCore(pid int, reason string) {
+	ac.CmdMutex.Lock()
+	if !ac.RunningState.IsRunning() || ac.currentCorePIDLocked() != pid {
+		ac.CmdMutex.Unlock()
+		return
+	}
+	ac.supervision.healthRestart = true
+	ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventProbeRestart, PID: pid, Detail: reason})
+	if ac.SingboxPrivilegedMode {
+		scriptPID, singboxPID, pidFile := ac.SingboxPrivilegedPID, ac.SingboxPrivilegedSingboxPID, ac.SingboxPrivilegedPIDFile
+		ac.CmdMutex.Unlock()
+		if err := platform.KillPrivilegedProcess(scriptPID, singboxPID, pidFile); err != nil {
+			debuglog.WarnLog("supervision: privileged kill failed: %v", err)
+		}
+		return
+	}
+	proc := ac.SingboxCmd.Process
+	ac.CmdMutex.Unlock()
+	if runtime.GOOS == "windows" {
+		_ = platform.KillProcessByPID(proc.Pid)
+		return
+	}
+	if err := proc.Kill(); err != nil {
+		debuglog.WarnLog("supervision: kill PID %d: %v", proc.Pid, err)
+	}
+}
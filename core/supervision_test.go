@@ -0,0 +1,93 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/platform"
+)
+
+func TestResolveSupervisionPolicyDefaults(t *testing.T) {
+	d := ResolveSupervisionPolicy(nil)
+	if d.MaxAttempts != restartAttempts || d.StabilityWindow != stabilityThreshold || d.BackoffInitial != 2*time.Second ||
+		d.ProbeKind != "" || d.JitterPercent != 20 {
+		t.Fatalf("defaults = %+v", d)
+	}
+	zero := 0
+	s := ResolveSupervisionPolicy(&state.SupervisionPolicy{
+		MaxAttempts:       7,
+		BackoffInitialSec: 90, // больше встроенного максимума — максимум подтягивается
+		JitterPercent:     &zero,
+		Probe:             &state.SupervisionProbe{Kind: state.SupervisionProbeClashAPI},
+	})
+	if s.MaxAttempts != 7 || s.BackoffMax != 90*time.Second || s.JitterPercent != 0 ||
+		s.ProbeKind != state.SupervisionProbeClashAPI || s.ProbeInterval != defaultProbeInterval {
+		t.Errorf("resolved = %+v", s)
+	}
+	if p := s.Policy(); p.Probe == nil || *p.JitterPercent != 0 || p.BackoffMaxSec != 90 {
+		t.Errorf("Policy() = %+v", p)
+	}
+}
+
+func TestSupervisionBackoff(t *testing.T) {
+	s := ResolveSupervisionPolicy(&state.SupervisionPolicy{BackoffInitialSec: 2, BackoffMaxSec: 20, BackoffMultiplier: 3})
+	for attempt, want := range map[int]time.Duration{0: 2 * time.Second, 1: 2 * time.Second, 2: 6 * time.Second, 3: 18 * time.Second, 4: 20 * time.Second, 100: 20 * time.Second} {
+		if got := s.Backoff(attempt, nil); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	// Разброс ±20 %: крайние значения rnd дают 0.8× и почти 1.2×.
+	if got := s.Backoff(2, func() float64 { return 0 }); got != 4800*time.Millisecond {
+		t.Errorf("low jitter = %v", got)
+	}
+	if got := s.Backoff(2, func() float64 { return 0.5 }); got != 6*time.Second {
+		t.Errorf("mid jitter = %v", got)
+	}
+}
+
+// Журнал пишется в logs/supervision.jsonl и читается хвостом; политика из
+// SetSupervisionPolicy сразу действует и лежит в state.json.
+func TestSupervisionPolicyAndLog(t *testing.T) {
+	dir := t.TempDir()
+	ac := &AppController{FileService: &services.FileService{ExecDir: dir}}
+
+	if err := ac.SetSupervisionPolicy(audit.ActorUI, &state.SupervisionPolicy{MaxAttempts: 4}); err != ErrSupervisionNoProfile {
+		t.Fatalf("no state: err = %v", err)
+	}
+	statePath := platform.GetWizardStatePath(dir)
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.New().Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.SetSupervisionPolicy(audit.ActorUI, &state.SupervisionPolicy{MaxAttempts: 4}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if got := ac.SupervisionSettings().MaxAttempts; got != 4 {
+		t.Errorf("MaxAttempts = %d", got)
+	}
+	if st, _ := state.Load(statePath); st == nil || st.Supervision == nil || st.Supervision.MaxAttempts != 4 {
+		t.Errorf("state.json not updated: %+v", st)
+	}
+
+	for i := 1; i <= 3; i++ {
+		ac.recordSupervision(SupervisionEvent{Kind: SupervisionEventRestart, Attempt: i, DelayMs: int64(i) * 1000})
+	}
+	events, err := ac.SupervisionLog(2)
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	if len(events) != 2 || events[0].Attempt != 2 || events[1].Attempt != 3 {
+		t.Errorf("tail = %+v", events)
+	}
+	all, _ := ac.SupervisionLog(0)
+	if len(all) != 4 || all[0].Kind != SupervisionEventPolicyChanged {
+		t.Errorf("all = %+v", all)
+	}
+}
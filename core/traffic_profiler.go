@@ -0,0 +1,179 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/platform"
+	tprof "singbox-launcher/internal/traffic"
+)
+
+// Always-on Traffic Profiler (SPEC 059) со стороны контроллера: запуск,
+// источник соединений и DNS по движку. Окно профайлера — в ui; сюда вынесено
+// всё, что нужно и headless-сборке.
+
+// ProfilerHTTPClient is a dedicated HTTP client for /connections polling.
+// Shared by the local profiler and the per-machine ones. We don't reuse api.getHTTPClient because that one's transport gets
+// reset on power-resume and we don't want to fight over it. 5s timeout
+// is well under our 1s poll interval — a stuck request gets cancelled
+// before the next tick.
+var ProfilerHTTPClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 2 * time.Second,
+		}).DialContext,
+		IdleConnTimeout:   30 * time.Second,
+		DisableKeepAlives: false,
+	},
+}
+
+// EnsureTrafficProfilerStarted spins up the always-on
+// internal/traffic.TrafficProfiler if it isn't running yet, and routes
+// poller / tailer warnings into debuglog. Safe to call repeatedly — the
+// underlying profiler is itself singleton-guarded.
+//
+// Called from main.go (GUI and headless) after the AppController is fully
+// wired so that FileService.ExecDir is known. Lives in core, not ui: the
+// headless build must start it without linking Fyne. The profiler runs whether or not the
+// Traffic Profiler window is open — recording survives window close.
+func (ac *AppController) EnsureTrafficProfilerStarted() {
+	tprof.SetPollerWarn(debuglog.WarnLog)
+	tprof.SetTailerWarn(debuglog.WarnLog)
+	p := tprof.GetInstance()
+
+	cfg := func() (string, string, bool) {
+		if ac.APIService == nil {
+			return "", "", false
+		}
+		// Daemon-режим: Clash API вырезан из конфига (управление и трафик по
+		// gRPC). Backend это сигналит через DaemonClashEndpoint. Пока трафик
+		// в daemon-режиме идёт не через Clash-поллер — возвращаем "не
+		// поллить", чтобы не биться в отсутствующий Clash-порт.
+		// (gRPC SubscribeConnections — отдельный источник, подключается ниже.)
+		if base, tok, ok := ac.DaemonClashEndpoint(); ok {
+			return base, tok, true
+		}
+		if ac.BackendMode() == BackendDaemon {
+			return "", "", false // daemon без Clash — Clash-поллер выключен
+		}
+		return ac.APIService.GetClashAPIConfig()
+	}
+	logPath := filepath.Join(platform.GetLogsDir(ac.FileService.ExecDir), constants.ChildLogFileName)
+	p.Start(cfg, logPath, ProfilerHTTPClient)
+
+	// Источник трафика по режиму: daemon → gRPC SubscribeConnections,
+	// classic → nil (Clash HTTP через cfg выше). Переустанавливается при
+	// смене backend (ac.OnBackendModeChanged).
+	applyTrafficSource(ac, p)
+	ac.SetBackendModeChangeHook(func() { applyTrafficSource(ac, p) })
+}
+
+// applyTrafficSource ставит профайлеру gRPC-источник соединений в
+// daemon-режиме, снимает (nil → Clash HTTP) в classic. Заодно переключает
+// DNS-плоскость: daemon → структурный стрим, classic → разбор лога.
+func applyTrafficSource(ac *AppController, p *tprof.TrafficProfiler) {
+	if fnAny := ac.DaemonConnSnapshotFunc(); fnAny != nil {
+		if fn, ok := fnAny.(tprof.SnapshotFunc); ok {
+			p.SetConnSnapshotFunc(fn)
+			applyDNSSource(ac, p)
+			return
+		}
+	}
+	p.SetConnSnapshotFunc(nil)
+	applyDNSSource(ac, p)
+}
+
+// localDNSSub — активная подписка локального профайлера на DNS-стрим.
+// Одна на процесс: профайлер тоже один. Снимается при уходе из daemon-режима,
+// иначе стрим пережил бы свой backend и держал бы ядро в режиме эмита.
+var (
+	localDNSMu     sync.Mutex
+	localDNSCancel func()
+)
+
+// applyDNSSource подписывает профайлер на SubscribeDNSQueries в daemon-режиме
+// и снимает подписку в classic.
+//
+// В classic источника нет by design: gRPC там отсутствует, Clash отдаёт только
+// точечный /dns/query, и единственный поток DNS — текстовый лог. Это и есть та
+// часть функционала, что доступна лишь на lxd gRPC.
+func applyDNSSource(ac *AppController, p *tprof.TrafficProfiler) {
+	localDNSMu.Lock()
+	defer localDNSMu.Unlock()
+
+	// Снимаем прежнюю подписку в любом случае: backend мог смениться, и старый
+	// стрим больше не описывает то ядро, за которым мы наблюдаем.
+	if localDNSCancel != nil {
+		localDNSCancel()
+		localDNSCancel = nil
+	}
+	p.SetDNSFromStream(false)
+
+	subAny := ac.DaemonDNSQuerySource()
+	if subAny == nil {
+		return // classic → остаётся LogTailer
+	}
+	subscribe, ok := subAny.(func(func(services.DNSQuery), func()) (func(), error))
+	if !ok {
+		debuglog.WarnLog("traffic: неожиданный тип источника DNS %T", subAny)
+		return
+	}
+	cancel, err := subscribe(
+		func(q services.DNSQuery) { p.PushEvent(DNSQueryToEvent(q)) },
+		// Ядро собрано без with_lx_command: стрима не будет. Возвращаем лог
+		// как источник — иначе DNS-плоскость исчезла бы совсем, ведь лог мы
+		// к этому моменту уже подавили.
+		func() { p.SetDNSFromStream(false) },
+	)
+	if err != nil {
+		// Демон недоступен или ядро без with_lx_command. Профайлер остаётся
+		// рабочим — DNS-плоскость просто приедет из лога, как в classic.
+		debuglog.WarnLog("traffic: dns stream: %v", err)
+		return
+	}
+	localDNSCancel = cancel
+	p.SetDNSFromStream(true)
+}
+
+// DNSQueryToEvent переводит DNS-событие машины в событие профайлера — ту же
+// форму, в какой их порождает разбор локального лога.
+func DNSQueryToEvent(q services.DNSQuery) tprof.TrafficEvent {
+	e := tprof.TrafficEvent{
+		TS:          time.Now(),
+		Kind:        tprof.EventDNSResolve,
+		Domain:      q.Domain,
+		ProcessPath: q.ProcessPath,
+		ProcessName: tprof.ProcessBase(q.ProcessPath),
+		CnameChain:  q.CNAMEs,
+	}
+	// Адреса ответа и сервер, который их дал, кладём в диагностическую
+	// строку: отдельных полей под них в модели нет, а при разборе «почему
+	// домен ушёл не туда» нужны оба.
+	var b strings.Builder
+	if q.DNSServer != "" {
+		fmt.Fprintf(&b, "server=%s ", q.DNSServer)
+	}
+	if len(q.Answers) > 0 {
+		fmt.Fprintf(&b, "answers=%s", strings.Join(q.Answers, ","))
+	}
+	e.RawLogLine = strings.TrimSpace(b.String())
+	if len(q.Answers) > 0 {
+		e.IP = q.Answers[0]
+	}
+	if q.Failed {
+		e.Kind = tprof.EventDNSFail
+		if q.Error != "" {
+			e.RawLogLine = strings.TrimSpace(e.RawLogLine + " error=" + q.Error)
+		}
+	}
+	return e
+}
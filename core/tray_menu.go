@@ -1,3 +1,5 @@
+//go:build !headless
+
 package core
 
 import (
@@ -5,6 +7,7 @@ import (
 
 	"fyne.io/fyne/v2"
 
+	"singbox-launcher/core/audit"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/dialogs"
 	"singbox-launcher/internal/locale"
@@ -42,7 +45,7 @@ func (ac *AppController) CreateTrayMenu() *fyne.Menu {
 	}
 
 	// Quit
-	menuItems = append(menuItems, fyne.NewMenuItem(locale.T("tray.quit"), ac.GracefulExit))
+	menuItems = append(menuItems, fyne.NewMenuItem(locale.T("tray.quit"), func() { ac.GracefulExit(audit.ActorTray) }))
 
 	return fyne.NewMenu("Singbox Launcher", menuItems...)
 }
@@ -56,7 +59,7 @@ func (ac *AppController) addVPNAndProxyMenuItems(menuItems []*fyne.MenuItem) []*
 	buttonState := ac.GetVPNButtonState()
 
 	if buttonState.StartEnabled {
-		menuItems = append(menuItems, fyne.NewMenuItem(locale.T("tray.start_vpn"), func() { StartSingBoxProcess() }))
+		menuItems = append(menuItems, fyne.NewMenuItem(locale.T("tray.start_vpn"), func() { StartSingBoxProcess(audit.ActorTray) }))
 	} else {
 		startItem := fyne.NewMenuItem(locale.T("tray.start_vpn"), nil)
 		startItem.Disabled = true
@@ -64,7 +67,7 @@ func (ac *AppController) addVPNAndProxyMenuItems(menuItems []*fyne.MenuItem) []*
 	}
 
 	if buttonState.StopEnabled {
-		menuItems = append(menuItems, fyne.NewMenuItem(locale.T("tray.stop_vpn"), func() { StopSingBoxProcess() }))
+		menuItems = append(menuItems, fyne.NewMenuItem(locale.T("tray.stop_vpn"), func() { StopSingBoxProcess(audit.ActorTray) }))
 	} else {
 		stopItem := fyne.NewMenuItem(locale.T("tray.stop_vpn"), nil)
 		stopItem.Disabled = true
@@ -100,7 +103,7 @@ func (ac *AppController) buildProxySubmenu(selectedGroup string) *fyne.Menu {
 			display := proxy.DisplayOrName()
 			menuItem := fyne.NewMenuItem(display, func() {
 				go func() {
-					err := ac.APIService.SwitchProxy(selectedGroup, pName)
+					err := ac.SwitchProxyBy(audit.ActorTray, selectedGroup, pName)
 					fyne.Do(func() {
 						if err != nil {
 							debuglog.ErrorLog("CreateTrayMenu: Failed to switch proxy: %v", err)
@@ -0,0 +1,83 @@
+package uiservice
+
+import (
+	"sync"
+	"time"
+)
+
+// Hooks — часть UIService без Fyne: колбэки, которые регистрирует UI-слой,
+// и простое состояние окна и трея. Вынесена отдельно ради headless-сборки
+// (тег headless): там UIService — заглушка без Fyne (ui_service_headless.go),
+// а core обращается к этим полям одинаково в обеих сборках.
+type Hooks struct {
+	// Tray menu update protection
+	TrayMenuUpdateInProgress bool
+	TrayMenuUpdateMutex      sync.Mutex
+	TrayMenuUpdateTimer      *time.Timer
+
+	// Dock icon visibility state (macOS only)
+	HideAppFromDock bool
+
+	// Callbacks for UI logic
+	RefreshAPIFunc           func()
+	ResetAPIStateFunc        func()
+	UpdateCoreStatusFunc     func()
+	UpdateConfigStatusFunc   func()
+	UpdateTrayMenuFunc       func()
+	UpdateParserProgressFunc func(progress float64, status string)
+
+	// ShowSubsResultFunc — финальный статус subscription operation
+	// (success/error). Вызывается из core/config_service.go вместо
+	// legacy `dialogs.ShowAutoHideInfo` popup'а — результат рендерится
+	// in-place под Exit-кнопкой (см. ui/core_dashboard_subs_status.go).
+	ShowSubsResultFunc func(success bool, message string)
+	// AutoPingAfterConnectFunc is scheduled by the controller ~5s after sing-box
+	// transitions into the running state, so node latency in the Servers tab is
+	// fresh by the time the user looks at it. Registered by the Servers tab;
+	// no-op until then. Controlled by StateService.IsAutoPingAfterConnectEnabled().
+	AutoPingAfterConnectFunc func()
+
+	// LxdOverride*Func — управление remote-override вкладки Servers из
+	// Debug API (SPEC 100 §3.8): то же, что кнопки Connect/Disconnect на
+	// вкладке Remote. Регистрируются UI-слоем (ui/lxd_remote_override.go);
+	// nil = UI ещё не создан или лаунчер headless — API отвечает 503.
+	LxdOverrideConnectFunc    func(id string) error
+	LxdOverrideDisconnectFunc func()
+	LxdOverrideStateFunc      func() (id, name string, active bool)
+
+	// CoreVersionsChangedFunc — активная версия ядра сменилась не по кнопке
+	// (автооткат, core/core_versions.go) или из Debug API: дашборд
+	// перечитывает версию и статус бинаря. nil — UI ещё не создан.
+	CoreVersionsChangedFunc func()
+
+	FocusOpenChildWindows func()                                     // Focus one of wizard child windows (View, Outbound Edit, rule dialog) when user clicks wizard
+	ShowUpdatePopupFunc   func(currentVersion, latestVersion string) // Called to show update popup
+
+	// Dependencies (passed from AppController)
+	RunningStateIsRunning func() bool
+	SingboxPath           string
+	// OnStateChange — опциональный callback, который вызывается при изменениях
+	// UI-связанного состояния (например, открытие/закрытие визарда).
+	// Используется для того, чтобы UI-компоненты (например, overlay) могли
+	// подстраиваться под текущее состояние без жёсткой связи между слоями.
+	OnStateChange func() // Called when UI state changes
+	// OnWindowShown — опциональный callback, который вызывается после открытия главного окна
+	// Используется для проверки обновлений при первом открытии окна после запуска с -tray
+	OnWindowShown func() // Called after main window is shown
+	// OnWindowHidden — парный к OnWindowShown: окно ушло в трей.
+	//
+	// Нужен потребителям с периодическим опросом (авто-обновление списка
+	// узлов на Remote): пока окно скрыто, их запросы уходят в никуда — данные
+	// никто не видит, а сеть и удалённая машина нагружаются.
+	OnWindowHidden func() // Called after main window is hidden to tray
+}
+
+// StopTrayMenuUpdateTimer safely stops the tray menu update timer.
+func (ui *Hooks) StopTrayMenuUpdateTimer() {
+	ui.TrayMenuUpdateMutex.Lock()
+	defer ui.TrayMenuUpdateMutex.Unlock()
+	if ui.TrayMenuUpdateTimer != nil {
+		ui.TrayMenuUpdateTimer.Stop()
+		ui.TrayMenuUpdateTimer = nil
+	}
+}
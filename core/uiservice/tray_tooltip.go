@@ -0,0 +1,36 @@
+//go:build !headless
+
+package uiservice
+
+import (
+	"sync"
+
+	"fyne.io/systray"
+)
+
+// trayTooltipDefault — подсказка трея, когда показывать нечего.
+const trayTooltipDefault = "Singbox Launcher"
+
+var (
+	trayTooltipMu   sync.Mutex
+	trayTooltipLast string
+)
+
+// SetTrayTooltip ставит подсказку иконки в трее; пустая строка — имя
+// приложения. Повтор того же текста не уходит в systray: подсказку обновляют
+// раз в секунду, а на Linux каждый вызов — это D-Bus сигнал.
+//
+// Fyne своей обёртки над подсказкой не даёт, поэтому вызов идёт в systray
+// напрямую — тот же трей, который Fyne поднимает в SetSystemTrayMenu.
+func (ui *UIService) SetTrayTooltip(text string) {
+	if text == "" {
+		text = trayTooltipDefault
+	}
+	trayTooltipMu.Lock()
+	defer trayTooltipMu.Unlock()
+	if text == trayTooltipLast {
+		return
+	}
+	trayTooltipLast = text
+	systray.SetTooltip(text)
+}
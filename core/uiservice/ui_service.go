@@ -1,9 +1,9 @@
+//go:build !headless
+
 package uiservice
 
 import (
 	"os"
-	"sync"
-	"time"
 
 	"fyne.io/systray"
 
@@ -21,7 +21,11 @@ import (
 // It encapsulates all Fyne components and UI state to reduce AppController complexity.
 // Placed in a separate package from other core services so that non-UI packages
 // (e.g. ui/wizard/business) can import core/services without pulling in Fyne.
+// Fields without Fyne types live in the embedded Hooks (hooks.go); the
+// headless build replaces this file with ui_service_headless.go.
 type UIService struct {
+	Hooks
+
 	// Fyne Components
 	Application fyne.App
 	MainWindow  fyne.Window
@@ -46,61 +50,6 @@ type UIService struct {
 	// Parser progress UI
 	ParserProgressBar *widget.ProgressBar
 	ParserStatusLabel *widget.Label
-
-	// Tray menu update protection
-	TrayMenuUpdateInProgress bool
-	TrayMenuUpdateMutex      sync.Mutex
-	TrayMenuUpdateTimer      *time.Timer
-
-	// Dock icon visibility state (macOS only)
-	HideAppFromDock bool
-
-	// Callbacks for UI logic
-	RefreshAPIFunc           func()
-	ResetAPIStateFunc        func()
-	UpdateCoreStatusFunc     func()
-	UpdateConfigStatusFunc   func()
-	UpdateTrayMenuFunc       func()
-	UpdateParserProgressFunc func(progress float64, status string)
-
-	// ShowSubsResultFunc — финальный статус subscription operation
-	// (success/error). Вызывается из core/config_service.go вместо
-	// legacy `dialogs.ShowAutoHideInfo` popup'а — результат рендерится
-	// in-place под Exit-кнопкой (см. ui/core_dashboard_subs_status.go).
-	ShowSubsResultFunc func(success bool, message string)
-	// AutoPingAfterConnectFunc is scheduled by the controller ~5s after sing-box
-	// transitions into the running state, so node latency in the Servers tab is
-	// fresh by the time the user looks at it. Registered by the Servers tab;
-	// no-op until then. Controlled by StateService.IsAutoPingAfterConnectEnabled().
-	AutoPingAfterConnectFunc func()
-
-	// LxdOverride*Func — управление remote-override вкладки Servers из
-	// Debug API (SPEC 100 §3.8): то же, что кнопки Connect/Disconnect на
-	// вкладке Remote. Регистрируются UI-слоем (ui/lxd_remote_override.go);
-	// nil = UI ещё не создан или лаунчер headless — API отвечает 503.
-	LxdOverrideConnectFunc    func(id string) error
-	LxdOverrideDisconnectFunc func()
-	LxdOverrideStateFunc      func() (id, name string, active bool)
-	FocusOpenChildWindows    func()                                     // Focus one of wizard child windows (View, Outbound Edit, rule dialog) when user clicks wizard
-	ShowUpdatePopupFunc      func(currentVersion, latestVersion string) // Called to show update popup
-
-	// Dependencies (passed from AppController)
-	RunningStateIsRunning func() bool
-	SingboxPath           string
-	// OnStateChange — опциональный callback, который вызывается при изменениях
-	// UI-связанного состояния (например, открытие/закрытие визарда).
-	// Используется для того, чтобы UI-компоненты (например, overlay) могли
-	// подстраиваться под текущее состояние без жёсткой связи между слоями.
-	OnStateChange func() // Called when UI state changes
-	// OnWindowShown — опциональный callback, который вызывается после открытия главного окна
-	// Используется для проверки обновлений при первом открытии окна после запуска с -tray
-	OnWindowShown func() // Called after main window is shown
-	// OnWindowHidden — парный к OnWindowShown: окно ушло в трей.
-	//
-	// Нужен потребителям с периодическим опросом (авто-обновление списка
-	// узлов на Remote): пока окно скрыто, их запросы уходят в никуда — данные
-	// никто не видит, а сеть и удалённая машина нагружаются.
-	OnWindowHidden func() // Called after main window is hidden to tray
 }
 
 // HideMainWindow прячет главное окно в трей, уведомляя подписчиков.
@@ -120,11 +69,11 @@ func (ui *UIService) HideMainWindow() {
 // NewUIService creates and initializes a new UIService instance.
 func NewUIService(appIconData, greyIconData, greenIconData, redIconData []byte,
 	runningStateIsRunning func() bool, singboxPath string, onStateChange func()) (*UIService, error) {
-	ui := &UIService{
+	ui := &UIService{Hooks: Hooks{
 		RunningStateIsRunning: runningStateIsRunning,
 		SingboxPath:           singboxPath,
 		OnStateChange:         onStateChange,
-	}
+	}}
 
 	// Initialize icon resources
 	ui.AppIconData = fyne.NewStaticResource("appIcon", appIconData)
@@ -255,16 +204,6 @@ func (ui *UIService) UpdateUI() {
 	})
 }
 
-// StopTrayMenuUpdateTimer safely stops the tray menu update timer.
-func (ui *UIService) StopTrayMenuUpdateTimer() {
-	ui.TrayMenuUpdateMutex.Lock()
-	defer ui.TrayMenuUpdateMutex.Unlock()
-	if ui.TrayMenuUpdateTimer != nil {
-		ui.TrayMenuUpdateTimer.Stop()
-		ui.TrayMenuUpdateTimer = nil
-	}
-}
-
 // QuitApplication quits the Fyne application.
 //
 // Fyne's glfw driver runs its tray teardown (d.trayStop) inside Quit() only
@@ -0,0 +1,35 @@
+//go:build headless
+
+package uiservice
+
+import "errors"
+
+// Headless-сборка (тег headless): UIService без Fyne. Контроллер создаётся
+// через NewHeadlessController и держит UIService == nil, так что методы ниже
+// не вызываются; заглушка нужна только чтобы core компилировался. Поля —
+// те, к которым core обращается помимо Hooks, с типами без Fyne.
+
+// ErrNoUI — сборка без окна и трея.
+var ErrNoUI = errors.New("uiservice: built without UI (headless)")
+
+// UIService — заглушка GUI-версии из ui_service.go.
+type UIService struct {
+	Hooks
+
+	Application       any
+	MainWindow        any
+	ProxiesListWidget interface{ Refresh() }
+	ListStatusLabel   interface{ SetText(string) }
+}
+
+// NewUIService в headless-сборке всегда возвращает ErrNoUI.
+func NewUIService(appIconData, greyIconData, greenIconData, redIconData []byte,
+	runningStateIsRunning func() bool, singboxPath string, onStateChange func()) (*UIService, error) {
+	return nil, ErrNoUI
+}
+
+func (ui *UIService) HideMainWindow()              {}
+func (ui *UIService) ShowMainWindowOrFocusWizard() {}
+func (ui *UIService) UpdateUI()                    {}
+func (ui *UIService) QuitApplication()             {}
+func (ui *UIService) SetTrayTooltip(text string)   {}
@@ -0,0 +1,527 @@
+package core
+
+// Сторож связности (state.watchdog): ручная selector-группа сама по себе
+// остаётся на мёртвом узле, пока это не заметит человек. Сторож раз в
+// интервал меряет задержку выбранного узла каждой группы под присмотром и
+// после нескольких неудач подряд переключает группу на самый быстрый из
+// живых узлов, запоминая исходный выбор. По желанию возвращает исходный
+// узел, когда тот оживает, — с гистерезисом, чтобы группа не качалась.
+//
+// Работает через транспорт активного движка (Clash API в classic, gRPC в
+// daemon), поэтому одинаково в обоих режимах. Журнал — в памяти: это
+// подсказка «что и почему переключилось», а не аудит.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"singbox-launcher/api"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/events"
+	"singbox-launcher/core/services"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/ctxutil"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/dialogs"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+const (
+	defaultWatchdogInterval      = 60 * time.Second
+	defaultWatchdogFailures      = 3
+	defaultWatchdogRecoverChecks = 3
+	defaultWatchdogHold          = 300 * time.Second
+
+	// watchdogTick — как часто цикл просыпается проверить, не пора ли;
+	// сам интервал проверок — из политики.
+	watchdogTick = 5 * time.Second
+	// watchdogMaxCandidates — сколько запасных узлов меряется при
+	// переключении: в подписке их бывают тысячи, а ждать надо секунды.
+	watchdogMaxCandidates = 20
+	// watchdogEventsMax — длина журнала в памяти.
+	watchdogEventsMax = 100
+)
+
+// Виды событий сторожа.
+const (
+	WatchdogEventFailover       = "failover"        // группа переведена на запасной узел
+	WatchdogEventSwitchBack     = "switch_back"     // исходный узел ожил, группа вернулась на него
+	WatchdogEventNoAlternative  = "no_alternative"  // живых запасных узлов нет, группа оставлена как есть
+	WatchdogEventManualOverride = "manual_override" // узел сменили вручную — исходный выбор забыт
+	WatchdogEventSwitchFailed   = "switch_failed"
+)
+
+// WatchdogSettings — действующие значения политики (нули заменены
+// встроенными).
+type WatchdogSettings struct {
+	Enabled       bool
+	Groups        []string
+	Interval      time.Duration
+	Failures      int
+	MaxDelayMs    int64
+	SwitchBack    bool
+	RecoverChecks int
+	Hold          time.Duration
+}
+
+// ResolveWatchdogPolicy подставляет встроенные значения вместо нулей.
+func ResolveWatchdogPolicy(p *state.WatchdogPolicy) WatchdogSettings {
+	s := WatchdogSettings{
+		Interval:      defaultWatchdogInterval,
+		Failures:      defaultWatchdogFailures,
+		RecoverChecks: defaultWatchdogRecoverChecks,
+		Hold:          defaultWatchdogHold,
+	}
+	if p == nil {
+		return s
+	}
+	s.Enabled = p.Enabled && len(p.Groups) > 0
+	s.Groups = append([]string(nil), p.Groups...)
+	s.SwitchBack = p.SwitchBack
+	s.MaxDelayMs = int64(p.MaxDelayMs)
+	if p.IntervalSec > 0 {
+		s.Interval = time.Duration(p.IntervalSec) * time.Second
+	}
+	if p.Failures > 0 {
+		s.Failures = p.Failures
+	}
+	if p.RecoverChecks > 0 {
+		s.RecoverChecks = p.RecoverChecks
+	}
+	if p.HoldSec > 0 {
+		s.Hold = time.Duration(p.HoldSec) * time.Second
+	}
+	return s
+}
+
+// WatchdogEvent — одна запись журнала сторожа.
+type WatchdogEvent struct {
+	Time   time.Time
+	Group  string
+	Kind   string
+	From   string
+	To     string
+	Detail string
+}
+
+// WatchdogGroupStatus — что сторож знает о группе.
+type WatchdogGroupStatus struct {
+	Group string
+	// Current — узел, выбранный в группе при последней проверке.
+	Current string
+	// Original — выбор пользователя до переключения; пусто — группа на
+	// своём узле.
+	Original   string
+	SwitchedAt time.Time
+	// Failures — неудач текущего узла подряд; RecoverOK — удач исходного
+	// подряд (считаются только после переключения).
+	Failures  int
+	RecoverOK int
+	LastCheck time.Time
+	LastDelay int64
+	LastError string
+}
+
+// ConnectivityWatchdog — состояние сторожа: группы и журнал. Проверки
+// делает один цикл (runConnectivityWatchdog), mu защищает от читателей
+// (окно, статус).
+type ConnectivityWatchdog struct {
+	mu     sync.Mutex
+	groups map[string]*WatchdogGroupStatus
+	events []WatchdogEvent
+	// policy — кэш state.watchdog; stale — перечитать при следующем тике
+	// (сохранили визард или окно).
+	policy *state.WatchdogPolicy
+	stale  bool
+	// lastRun — когда прошёл последний круг проверок.
+	lastRun time.Time
+	now     func() time.Time
+}
+
+func newConnectivityWatchdog() *ConnectivityWatchdog {
+	return &ConnectivityWatchdog{groups: map[string]*WatchdogGroupStatus{}, stale: true, now: time.Now}
+}
+
+// invalidate — политику перечитать из state.json на следующем тике.
+func (w *ConnectivityWatchdog) invalidate() {
+	w.mu.Lock()
+	w.stale = true
+	w.mu.Unlock()
+}
+
+// Status — снимок групп (по имени) и журнала (новые в конце).
+func (w *ConnectivityWatchdog) Status() ([]WatchdogGroupStatus, []WatchdogEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	groups := make([]WatchdogGroupStatus, 0, len(w.groups))
+	for _, g := range w.groups {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return groups, append([]WatchdogEvent(nil), w.events...)
+}
+
+func (w *ConnectivityWatchdog) record(ev WatchdogEvent) {
+	ev.Time = w.now()
+	w.events = append(w.events, ev)
+	if len(w.events) > watchdogEventsMax {
+		w.events = w.events[len(w.events)-watchdogEventsMax:]
+	}
+	debuglog.InfoLog("watchdog: %s group=%q %q → %q %s", ev.Kind, ev.Group, ev.From, ev.To, ev.Detail)
+}
+
+// forget убирает группы, которых больше нет в политике (или сторож
+// выключен, keep == nil).
+func (w *ConnectivityWatchdog) forget(keep []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	want := make(map[string]bool, len(keep))
+	for _, g := range keep {
+		want[g] = true
+	}
+	for g := range w.groups {
+		if !want[g] {
+			delete(w.groups, g)
+		}
+	}
+}
+
+// watchdogSwitch переключает узел группы; выделен, чтобы тесты обходились
+// без APIService.
+type watchdogSwitch func(group, name string) error
+
+// measure — одна проверка узла: ok, задержка и причина неудачи.
+func (s WatchdogSettings) measure(t services.ProxyTransport, name string) (bool, int64, error) {
+	delay, err := t.Delay(name)
+	if err != nil {
+		return false, 0, err
+	}
+	if delay <= 0 {
+		return false, 0, errors.New("no response")
+	}
+	if s.MaxDelayMs > 0 && delay > s.MaxDelayMs {
+		return false, delay, fmt.Errorf("delay %d ms over the %d ms limit", delay, s.MaxDelayMs)
+	}
+	return true, delay, nil
+}
+
+// checkGroup — один круг для группы: проверка текущего узла, переключение
+// после Failures неудач, возврат исходного по гистерезису. Возвращает
+// события этого круга (для уведомлений). Сон машины (ErrPlatformInterrupt)
+// ничего не меняет.
+func (w *ConnectivityWatchdog) checkGroup(t services.ProxyTransport, sw watchdogSwitch, group string, s WatchdogSettings) []WatchdogEvent {
+	proxies, now, err := t.GroupProxies(group)
+	if err != nil || now == "" {
+		// Ядро недоступно или группа ещё не выбрала узел — не вина узла.
+		return nil
+	}
+
+	w.mu.Lock()
+	st := w.groups[group]
+	if st == nil {
+		st = &WatchdogGroupStatus{Group: group}
+		w.groups[group] = st
+	}
+	var out []WatchdogEvent
+	if st.Original != "" && st.Current != "" && now != st.Current {
+		// Пока группа стояла на запасном узле, её переключили руками (или
+		// ядро перезапустилось с другим выбором): решение человека главнее.
+		ev := WatchdogEvent{Group: group, Kind: WatchdogEventManualOverride, From: st.Current, To: now}
+		w.record(ev)
+		out = append(out, ev)
+		st.Original, st.RecoverOK = "", 0
+		st.Failures = 0
+	}
+	if now != st.Current {
+		st.Failures = 0
+	}
+	st.Current = now
+	w.mu.Unlock()
+
+	ok, delay, err := s.measure(t, now)
+	if errors.Is(err, api.ErrPlatformInterrupt) {
+		return out
+	}
+
+	w.mu.Lock()
+	st.LastCheck, st.LastDelay, st.LastError = w.now(), delay, ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+	if ok {
+		st.Failures = 0
+	} else {
+		st.Failures++
+	}
+	failed := st.Failures >= s.Failures
+	original, switchedAt := st.Original, st.SwitchedAt
+	w.mu.Unlock()
+
+	switch {
+	case failed:
+		out = append(out, w.failover(t, sw, group, now, proxies, s)...)
+	case ok && original != "" && s.SwitchBack && w.now().Sub(switchedAt) >= s.Hold:
+		out = append(out, w.tryReturn(t, sw, group, now, original, s)...)
+	}
+	return out
+}
+
+// failover переводит группу с узла from на самый быстрый живой из
+// остальных.
+func (w *ConnectivityWatchdog) failover(t services.ProxyTransport, sw watchdogSwitch, group, from string, proxies []api.ProxyInfo, s WatchdogSettings) []WatchdogEvent {
+	candidates := watchdogCandidates(proxies, group, from)
+	delays := make([]int64, len(candidates))
+	sem := make(chan struct{}, max(1, api.GetPingTestAllConcurrency()))
+	var wg sync.WaitGroup
+	for i, name := range candidates {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if ok, delay, _ := s.measure(t, name); ok {
+				delays[i] = delay
+			}
+		}()
+	}
+	wg.Wait()
+	best, bestDelay := "", int64(0)
+	for i, name := range candidates {
+		if d := delays[i]; d > 0 && (best == "" || d < bestDelay) {
+			best, bestDelay = name, d
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := w.groups[group]
+	if best == "" {
+		ev := WatchdogEvent{Group: group, Kind: WatchdogEventNoAlternative, From: from,
+			Detail: fmt.Sprintf("%d failed checks", st.Failures)}
+		w.record(ev)
+		// Держим счётчик на пороге: следующий круг снова поищет запасной.
+		st.Failures = s.Failures
+		return []WatchdogEvent{ev}
+	}
+	if err := sw(group, best); err != nil {
+		ev := WatchdogEvent{Group: group, Kind: WatchdogEventSwitchFailed, From: from, To: best, Detail: err.Error()}
+		w.record(ev)
+		return []WatchdogEvent{ev}
+	}
+	if st.Original == "" {
+		st.Original = from
+	}
+	if best == st.Original {
+		st.Original = ""
+	}
+	st.Current, st.SwitchedAt, st.Failures, st.RecoverOK = best, w.now(), 0, 0
+	ev := WatchdogEvent{Group: group, Kind: WatchdogEventFailover, From: from, To: best,
+		Detail: fmt.Sprintf("%d ms", bestDelay)}
+	w.record(ev)
+	return []WatchdogEvent{ev}
+}
+
+// tryReturn меряет исходный узел и возвращает группу на него после
+// RecoverChecks удач подряд.
+func (w *ConnectivityWatchdog) tryReturn(t services.ProxyTransport, sw watchdogSwitch, group, from, original string, s WatchdogSettings) []WatchdogEvent {
+	ok, delay, _ := s.measure(t, original)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := w.groups[group]
+	if !ok {
+		st.RecoverOK = 0
+		return nil
+	}
+	st.RecoverOK++
+	if st.RecoverOK < s.RecoverChecks {
+		return nil
+	}
+	if err := sw(group, original); err != nil {
+		ev := WatchdogEvent{Group: group, Kind: WatchdogEventSwitchFailed, From: from, To: original, Detail: err.Error()}
+		w.record(ev)
+		return []WatchdogEvent{ev}
+	}
+	st.Current, st.Original, st.SwitchedAt, st.RecoverOK, st.Failures = original, "", w.now(), 0, 0
+	ev := WatchdogEvent{Group: group, Kind: WatchdogEventSwitchBack, From: from, To: original,
+		Detail: fmt.Sprintf("%d ms", delay)}
+	w.record(ev)
+	return []WatchdogEvent{ev}
+}
+
+// watchdogCandidates — запасные узлы группы: без текущего, самой группы и
+// служебных исходящих; сначала те, у кого задержка уже известна, по
+// возрастанию, не больше watchdogMaxCandidates.
+func watchdogCandidates(proxies []api.ProxyInfo, group, current string) []string {
+	var list []api.ProxyInfo
+	for _, p := range proxies {
+		if p.Name == "" || p.Name == group || p.Name == current {
+			continue
+		}
+		switch strings.ToLower(p.ClashType) {
+		case "direct", "reject", "block", "dns", "pass", "compatible":
+			continue
+		}
+		list = append(list, p)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		di, dj := list[i].Delay, list[j].Delay
+		if (di > 0) != (dj > 0) {
+			return di > 0
+		}
+		return di > 0 && di < dj
+	})
+	if len(list) > watchdogMaxCandidates {
+		list = list[:watchdogMaxCandidates]
+	}
+	names := make([]string, len(list))
+	for i, p := range list {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// watchdogPolicy — политика из кэша; после invalidate перечитывается из
+// state.json активного профиля.
+func (ac *AppController) watchdogPolicy() *state.WatchdogPolicy {
+	w := ac.Watchdog
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	p, stale := w.policy, w.stale
+	w.mu.Unlock()
+	if !stale {
+		return p
+	}
+	p = nil
+	if ac.FileService != nil {
+		s, err := state.Load(platform.GetWizardStatePath(ac.FileService.ExecDir))
+		switch {
+		case err == nil:
+			p = s.Watchdog
+		case !errors.Is(err, state.ErrNotFound):
+			debuglog.WarnLog("watchdog: load state: %v", err)
+		}
+	}
+	w.mu.Lock()
+	w.policy, w.stale = p, false
+	w.mu.Unlock()
+	return p
+}
+
+// WatchdogPolicy — сохранённая политика сторожа (nil — не задана).
+func (ac *AppController) WatchdogPolicy() *state.WatchdogPolicy {
+	return ac.watchdogPolicy()
+}
+
+// WatchdogStatus — группы под присмотром и журнал сторожа.
+func (ac *AppController) WatchdogStatus() ([]WatchdogGroupStatus, []WatchdogEvent) {
+	if ac.Watchdog == nil {
+		return nil, nil
+	}
+	return ac.Watchdog.Status()
+}
+
+// SetWatchdogPolicy проверяет и сохраняет политику в state.json
+// (load-mutate-save). Действует со следующего тика.
+func (ac *AppController) SetWatchdogPolicy(actor audit.Actor, p *state.WatchdogPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if ac.FileService == nil {
+		return errors.New("core: no controller")
+	}
+	statePath := platform.GetWizardStatePath(ac.FileService.ExecDir)
+	s, err := state.Load(statePath)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return ErrSupervisionNoProfile
+		}
+		return fmt.Errorf("load state: %w", err)
+	}
+	before := ac.StateSnapshot()
+	s.Watchdog = p
+	if err := s.Save(statePath); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	ac.ApplyStateChange(actor, before)
+	if w := ac.Watchdog; w != nil {
+		w.mu.Lock()
+		w.policy, w.stale = p, false
+		w.lastRun = time.Time{} // новая политика — проверить сразу
+		w.mu.Unlock()
+	}
+	return nil
+}
+
+// runConnectivityWatchdog — цикл сторожа на всё время жизни контроллера.
+func (ac *AppController) runConnectivityWatchdog() {
+	if ac.EventBus != nil {
+		ac.EventBus.Subscribe(events.StateChanged, func(events.Event) { ac.Watchdog.invalidate() })
+	}
+	for {
+		if ctxutil.SleepWithContext(ac.ctx, watchdogTick) != nil {
+			return
+		}
+		s := ResolveWatchdogPolicy(ac.watchdogPolicy())
+		if !s.Enabled {
+			ac.Watchdog.forget(nil)
+			continue
+		}
+		ac.Watchdog.forget(s.Groups)
+		w := ac.Watchdog
+		w.mu.Lock()
+		due := w.now().Sub(w.lastRun) >= s.Interval
+		if due {
+			w.lastRun = w.now()
+		}
+		w.mu.Unlock()
+		if !due || !ac.RunningState.IsRunning() || platform.IsSleeping() || ac.APIService == nil {
+			continue
+		}
+		t, err := ac.APIService.Transport()
+		if err != nil {
+			continue
+		}
+		for _, group := range s.Groups {
+			for _, ev := range w.checkGroup(t, ac.watchdogSwitch(t), group, s) {
+				ac.notifyWatchdog(ev)
+			}
+		}
+	}
+}
+
+// watchdogSwitch — переключение через APIService, если группа выбрана на
+// вкладке Servers (обновится список, запомнится выбор), иначе напрямую
+// через транспорт.
+func (ac *AppController) watchdogSwitch(t services.ProxyTransport) watchdogSwitch {
+	return func(group, name string) error {
+		if group == ac.APIService.GetSelectedClashGroup() {
+			return ac.SwitchProxyBy(audit.ActorScheduler, group, name)
+		}
+		err := t.SwitchProxy(group, name)
+		ac.RecordProxySwitch(audit.ActorScheduler, group, "", name, err)
+		return err
+	}
+}
+
+// notifyWatchdog — уведомление о каждом переключении.
+func (ac *AppController) notifyWatchdog(ev WatchdogEvent) {
+	var msg string
+	switch ev.Kind {
+	case WatchdogEventFailover:
+		msg = locale.Tf("servers.watchdog.notify_failover", ev.Group, ev.From, ev.To)
+	case WatchdogEventSwitchBack:
+		msg = locale.Tf("servers.watchdog.notify_switch_back", ev.Group, ev.To)
+	default:
+		return
+	}
+	if ac.hasUI() && ac.UIService.Application != nil {
+		dialogs.ShowAutoHideInfo(ac.UIService.Application, ac.UIService.MainWindow, locale.T("servers.watchdog.notify_title"), msg)
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"singbox-launcher/api"
+)
+
+// fakeWatchdogTransport — группа "g" с узлами и задержками по имени;
+// задержка 0 — узел не отвечает.
+type fakeWatchdogTransport struct {
+	mu     sync.Mutex
+	now    string
+	delays map[string]int64
+}
+
+func (f *fakeWatchdogTransport) GroupProxies(group string) ([]api.ProxyInfo, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := []api.ProxyInfo{{Name: "direct", ClashType: "Direct"}}
+	for name := range f.delays {
+		list = append(list, api.ProxyInfo{Name: name, ClashType: "VLESS"})
+	}
+	return list, f.now, nil
+}
+
+func (f *fakeWatchdogTransport) SwitchProxy(group, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = name
+	return nil
+}
+
+func (f *fakeWatchdogTransport) Delay(name string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d := f.delays[name]; d > 0 {
+		return d, nil
+	}
+	return 0, errors.New("timeout")
+}
+
+func (f *fakeWatchdogTransport) set(name string, d int64) {
+	f.mu.Lock()
+	f.delays[name] = d
+	f.mu.Unlock()
+}
+
+func lastWatchdogKinds(evs []WatchdogEvent) []string {
+	kinds := make([]string, len(evs))
+	for i, ev := range evs {
+		kinds[i] = ev.Kind
+	}
+	return kinds
+}
+
+// Переключение только после Failures неудач подряд, на самый быстрый живой
+// узел; возврат — не раньше Hold и после RecoverChecks удач подряд.
+func TestWatchdogFailoverAndSwitchBack(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newConnectivityWatchdog()
+	w.now = func() time.Time { return clock }
+	tr := &fakeWatchdogTransport{now: "a", delays: map[string]int64{"a": 0, "b": 300, "c": 120}}
+	s := ResolveWatchdogPolicy(nil)
+	s.Failures, s.SwitchBack, s.RecoverChecks, s.Hold = 2, true, 2, time.Minute
+
+	if evs := w.checkGroup(tr, tr.SwitchProxy, "g", s); len(evs) != 0 || tr.now != "a" {
+		t.Fatalf("switched after one failure: %v now=%s", lastWatchdogKinds(evs), tr.now)
+	}
+	evs := w.checkGroup(tr, tr.SwitchProxy, "g", s)
+	if len(evs) != 1 || evs[0].Kind != WatchdogEventFailover || tr.now != "c" {
+		t.Fatalf("failover: %v now=%s", lastWatchdogKinds(evs), tr.now)
+	}
+
+	// Исходный ожил, но Hold ещё не вышел — остаёмся.
+	tr.set("a", 50)
+	clock = clock.Add(30 * time.Second)
+	w.checkGroup(tr, tr.SwitchProxy, "g", s)
+	if tr.now != "c" {
+		t.Fatalf("switched back before hold: now=%s", tr.now)
+	}
+	clock = clock.Add(time.Minute)
+	if evs := w.checkGroup(tr, tr.SwitchProxy, "g", s); len(evs) != 0 || tr.now != "c" {
+		t.Fatalf("switched back after one recovery check: now=%s", tr.now)
+	}
+	evs = w.checkGroup(tr, tr.SwitchProxy, "g", s)
+	if len(evs) != 1 || evs[0].Kind != WatchdogEventSwitchBack || tr.now != "a" {
+		t.Fatalf("switch back: %v now=%s", lastWatchdogKinds(evs), tr.now)
+	}
+	groups, _ := w.Status()
+	if len(groups) != 1 || groups[0].Original != "" {
+		t.Errorf("status after switch back = %+v", groups)
+	}
+}
+
+// Ручная смена узла после переключения сбрасывает запомненный выбор;
+// без живых запасных группа остаётся на месте.
+func TestWatchdogManualOverrideAndNoAlternative(t *testing.T) {
+	w := newConnectivityWatchdog()
+	tr := &fakeWatchdogTransport{now: "a", delays: map[string]int64{"a": 0, "b": 100, "c": 0}}
+	s := ResolveWatchdogPolicy(nil)
+	s.Failures, s.SwitchBack = 1, true
+
+	w.checkGroup(tr, tr.SwitchProxy, "g", s)
+	if tr.now != "b" {
+		t.Fatalf("failover: now=%s", tr.now)
+	}
+	tr.SwitchProxy("g", "c")
+	tr.set("b", 0)
+	evs := w.checkGroup(tr, tr.SwitchProxy, "g", s)
+	kinds := lastWatchdogKinds(evs)
+	if len(kinds) != 2 || kinds[0] != WatchdogEventManualOverride || kinds[1] != WatchdogEventNoAlternative || tr.now != "c" {
+		t.Fatalf("events = %v now=%s", kinds, tr.now)
+	}
+	groups, _ := w.Status()
+	if groups[0].Original != "" {
+		t.Errorf("original kept after manual switch: %+v", groups[0])
+	}
+}
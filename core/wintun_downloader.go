@@ -19,6 +19,11 @@ import (
 // WinTunVersion is the version of wintun.dll to download
 const WinTunVersion = "0.14.1"
 
+// WinTunZipSHA256 is the SHA-256 of wintun-<WinTunVersion>.zip as published on
+// wintun.net (and of the copy in the repo's assets/). Both download sources
+// must produce exactly these bytes — bump it together with WinTunVersion.
+const WinTunZipSHA256 = "07c256185d6ee3652e09fa55c0b673e2624b565e02c4b9091c79ca7d2f24ef51"
+
 // WinTunDownloadURL is the primary URL for downloading wintun.dll (wintun.net).
 const WinTunDownloadURL = "https://www.wintun.net/builds/wintun-%s.zip"
 
@@ -78,11 +83,17 @@ func (ac *AppController) DownloadWintunDLL(ctx context.Context, progressChan cha
 	ctxFirst, cancelFirst := context.WithTimeout(ctx, 30*time.Second)
 	defer cancelFirst()
 	err := ac.downloadFileFromURL(ctxFirst, winTunGitHubFallbackURL(), zipPath, progressChan)
+	if err == nil {
+		err = checkFileSHA256(zipPath, WinTunZipSHA256)
+	}
 	if err != nil {
 		debuglog.InfoLog("DownloadWintunDLL: GitHub URL failed, trying wintun.net fallback: %v", err)
 		progressChan <- DownloadProgress{Progress: 10, Message: "Downloading wintun.dll (fallback)...", Status: "downloading"}
 		zipURL := fmt.Sprintf(WinTunDownloadURL, WinTunVersion)
 		err = ac.downloadFileFromURL(ctx, zipURL, zipPath, progressChan)
+		if err == nil {
+			err = checkFileSHA256(zipPath, WinTunZipSHA256)
+		}
 	}
 	if err != nil {
 		progressChan <- DownloadProgress{
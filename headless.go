@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/secretstore"
+)
+
+// autoStartDelay is the delay before auto-starting VPN with -start.
+const autoStartDelay = 1 * time.Second
+
+// runHeadless runs the launcher without window and tray: core supervisor,
+// subscription auto-update, Traffic Profiler and Debug API, exactly as the GUI
+// runs them, but Fyne is never initialized, so no display is needed. Control
+// goes through the Debug API or the start/stop/status subcommands. Runs until
+// SIGINT/SIGTERM, then shuts down like the tray's Quit.
+//
+// The GUI binary reaches it through -headless but still links Fyne; a binary
+// built with -tags headless (main_headless.go) has only this entry and no
+// Fyne, cgo GL or X11 dependency at all.
+func runHeadless(autoStart bool) int {
+	controller, err := core.NewHeadlessController()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "headless: %v\n", err)
+		return exitFailure
+	}
+	settings := prepareController(controller)
+	// Nothing here can ask for the passphrase: a locked store would leave the
+	// Debug API off and the profile unreadable, a daemon nobody can reach.
+	if secretstore.Locked() {
+		controller.CloseHeadless()
+		fmt.Fprintf(os.Stderr, "headless: %v\n", explainLocked(secretstore.ErrLocked))
+		return exitFailure
+	}
+	startDebugAPIFromSettings(controller, settings)
+	if !settings.DebugAPIEnabled || settings.DebugAPIToken == "" {
+		fmt.Fprintln(os.Stderr, "headless: Debug API is off in bin/settings.json — start/stop/status won't reach this instance")
+	}
+
+	// Same background services the GUI starts; they report through the
+	// Debug API (/traffic/*, /events) instead of windows.
+	controller.EnsureTrafficProfilerStarted()
+	core.CheckConfigFileExists()
+	core.CheckIfSingBoxRunningAtStartUtil()
+	core.CleanupStaleTunAtStartUtil()
+	controller.ConfirmLauncherUpdate()
+
+	if autoStart {
+		time.AfterFunc(autoStartDelay, func() {
+			debuglog.InfoLog("Auto-start: Starting VPN due to -start parameter (headless)")
+			core.StartSingBoxProcess(audit.ActorScheduler)
+		})
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	fmt.Fprintf(os.Stderr, "headless: running (pid %d)\n", os.Getpid())
+	s := <-sig
+	debuglog.InfoLog("headless: %v received, shutting down", s)
+	controller.GracefulExit(audit.ActorCLI)
+	return exitOK
+}
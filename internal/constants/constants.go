@@ -17,6 +17,10 @@ const (
 	// миграции, которая читает старый файл и переносит его владельцу.
 	LegacyRemoteConfigFileName = "remote-config.json"
 	WizardStateFileName        = "state.json"
+	// BaseProfileSnapshotFileName — копия базового профиля, от которой
+	// машина материализована последний раз (лежит в её директории). Не .json
+	// намеренно: StateStore машины перечисляет *.json как снапшоты визарда.
+	BaseProfileSnapshotFileName = "base_profile.snapshot"
 	// OutboundsCacheFileName — кеш-файл outbounds (SPEC 045 phase 5.1).
 	// Лежит в <execDir>/bin/. Scope = последний активный state. Парсер
 	// перезаписывает его при каждом успешном Update; на переключении
@@ -43,6 +47,26 @@ const (
 	// Имя короче локального rule-sets/ намеренно: путь и так длинный, а
 	// каталог лежит внутри директории машины, где двусмысленности нет.
 	RemoteRuleSetsDirName = "srs"
+	// BaseProfilesDirName — каталог базовых профилей, от которых наследуют
+	// удалённые машины: bin/wizard_states/profiles/<name>.json. Подкаталог,
+	// а не файлы рядом с state.json: StateStore пропускает подкаталоги, и
+	// профиль не всплывёт в списке именованных снапшотов локального визарда.
+	BaseProfilesDirName = "profiles"
+	// CoreStoreDirName — установленные версии ядра рядом друг с другом:
+	// bin/cores/<version>/sing-box (core/core_versions.go). Активная
+	// копируется в bin/sing-box, остальные ждут переключения или отката.
+	CoreStoreDirName = "cores"
+	// RestoreBackupsDirName — файлы, которые перезаписало восстановление
+	// резервной копии (core/backup): bin/restore-backups/<время>/<путь в bin>.
+	// Восстановление, сделанное по ошибке, откатывается копированием назад.
+	RestoreBackupsDirName = "restore-backups"
+	// DebugAPISocketDirName — каталог unix-сокета Debug API в bin/ (0700):
+	// права каталога не пускают к сокету чужих пользователей.
+	DebugAPISocketDirName = "run"
+	// DebugAPISocketFileName — сам сокет: bin/run/debug-api.sock.
+	DebugAPISocketFileName = "debug-api.sock"
+	// DebugAPIAuditLogFileName — журнал изменяющих вызовов Debug API в logs/.
+	DebugAPIAuditLogFileName = "debugapi_audit.jsonl"
 )
 
 // Config targets (SPEC 097) — для какой машины лаунчер готовит config.json.
@@ -100,6 +124,12 @@ const (
 // `windows-386-legacy-windows-7` asset. See coreReleaseRepo() in core_downloader.go.
 const SingboxCoreRepo = "Leadaxe/sing-box-lx" // core for all platforms (XHTTP + AmneziaWG)
 
+// CoreReleaseSigningKey — base64 ed25519 public key the fork signs its release
+// checksum file with (`<checksums>.sig`). Empty = no key pinned yet: a published
+// signature is then logged as unverifiable and the download is checked by
+// digest only. See core_download_verify.go.
+const CoreReleaseSigningKey = ""
+
 // Pinned sing-box core version for this launcher build (SPEC 046 / 072).
 // A fork tag `X.Y.Z-lx.N` — the fork binary prints the full tag in
 // `sing-box version`, so the strict-equality reinstall check still holds.
@@ -17,6 +17,7 @@
 // Optional internal log sink: SetInternalLogSink sets a callback that receives (level, line) for every
 // Log() call; used by the diagnostics log viewer window. The callback is invoked from any goroutine
 // and must not block (e.g. push to a channel; UI updates via fyne.Async). The viewer filters by level.
+// AddLogTap adds further receivers of the same lines without replacing the viewer sink.
 package debuglog
 
 import (
@@ -59,6 +60,8 @@ var (
 
 	internalLogSinkMu sync.RWMutex
 	internalLogSink   func(Level, string)
+	logTaps           map[int]func(Level, string)
+	nextLogTap        int
 )
 
 // defaultLevelByBuild returns LevelWarn for release builds (version from tag, no "-"),
@@ -83,11 +86,12 @@ func defaultLevelByBuild() Level {
 func Log(prefix string, level Level, format string, args ...interface{}) {
 	internalLogSinkMu.RLock()
 	sink := internalLogSink
+	hasTaps := len(logTaps) > 0
 	internalLogSinkMu.RUnlock()
 
 	// Skip only if neither file nor sink will receive this message.
 	fileLog := level <= GlobalLevel
-	viewerWants := sink != nil && level <= LevelTrace
+	viewerWants := (sink != nil || hasTaps) && level <= LevelTrace
 	if !fileLog && !viewerWants {
 		return
 	}
@@ -107,7 +111,16 @@ func Log(prefix string, level Level, format string, args ...interface{}) {
 	}
 	if viewerWants {
 		lineWithTime := fmt.Sprintf("%s %s", time.Now().Format("2006-01-02 15:04:05"), line)
-		sink(level, lineWithTime)
+		if sink != nil {
+			sink(level, lineWithTime)
+		}
+		if hasTaps {
+			internalLogSinkMu.RLock()
+			for _, tap := range logTaps {
+				tap(level, line)
+			}
+			internalLogSinkMu.RUnlock()
+		}
 	}
 }
 
@@ -125,6 +138,27 @@ func ClearInternalLogSink() {
 	SetInternalLogSink(nil)
 }
 
+// AddLogTap registers an extra receiver of (level, line) for every Log() call,
+// alongside the viewer sink; line carries no timestamp. Unlike the single
+// viewer sink, any number of taps can be active (e.g. one per open remote
+// machine log panel). The callback must not block and must not log itself.
+// Call the returned function to remove the tap.
+func AddLogTap(fn func(Level, string)) (remove func()) {
+	internalLogSinkMu.Lock()
+	defer internalLogSinkMu.Unlock()
+	if logTaps == nil {
+		logTaps = map[int]func(Level, string){}
+	}
+	nextLogTap++
+	id := nextLogTap
+	logTaps[id] = fn
+	return func() {
+		internalLogSinkMu.Lock()
+		defer internalLogSinkMu.Unlock()
+		delete(logTaps, id)
+	}
+}
+
 // DebugLog logs a debug message (LevelVerbose) with "DEBUG" prefix.
 func DebugLog(format string, args ...interface{}) {
 	Log("DEBUG", LevelVerbose, format, args...)
@@ -0,0 +1,34 @@
+//go:build headless
+
+package dialogs
+
+import "singbox-launcher/internal/debuglog"
+
+// Headless-сборка (тег headless): окон нет, диалоги, которые зовёт core,
+// уходят в debuglog. Окно и приложение — any: core передаёт их из
+// UIService, который в этой сборке тоже без Fyne.
+
+func ShowError(window any, err error) {
+	debuglog.ErrorLog("dialog: %v", err)
+}
+
+func ShowErrorText(window any, title, message string) {
+	debuglog.ErrorLog("dialog: %s: %s", title, message)
+}
+
+func ShowInfo(window any, title, message string) {
+	debuglog.InfoLog("dialog: %s: %s", title, message)
+}
+
+func ShowAutoHideInfo(app any, window any, title, message string) {
+	debuglog.InfoLog("dialog: %s: %s", title, message)
+}
+
+func ShowLinuxCapabilitiesRequired(window any, title, message, command string) {
+	debuglog.WarnLog("dialog: %s: %s (%s)", title, message, command)
+}
+
+// ShowProcessKillConfirmation без окна не спрашивает и ничего не убивает.
+func ShowProcessKillConfirmation(window any, onKill func()) {
+	debuglog.WarnLog("dialog: process kill confirmation skipped (no UI)")
+}
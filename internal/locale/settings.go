@@ -10,6 +10,7 @@ import (
 
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
 )
 
 // Settings represents the launcher settings stored in bin/settings.json.
@@ -33,6 +34,11 @@ type Settings struct {
 	// Поле появилось после field-report на 0.8.7+: на ~500 нод авто-пинг через 5с
 	// после connect перегружал TUN-стек и подвешивал игры. См. SPEC 039 §1.3.
 	AutoPingAfterConnectMaxProxies int `json:"auto_ping_after_connect_max_proxies,omitempty"`
+	// CloseConnectionsOnSwitch — после переключения узла в селекторе рвать
+	// уже открытые соединения этой группы, чтобы они переоткрылись через новый
+	// узел. По умолчанию выключено: обрыв заметен (перезагрузка видео, разрыв
+	// SSH), и молча делать это без согласия пользователя нельзя.
+	CloseConnectionsOnSwitch bool `json:"close_connections_on_switch,omitempty"`
 	// DebugAPIEnabled — пользователь явно включил локальный HTTP debug-API
 	// (127.0.0.1:9263 по умолчанию). Off by default.
 	DebugAPIEnabled bool `json:"debug_api_enabled,omitempty"`
@@ -41,6 +47,15 @@ type Settings struct {
 	DebugAPIToken string `json:"debug_api_token,omitempty"`
 	// DebugAPIPort — порт для debug-API; 0 / отсутствует означает DefaultPort.
 	DebugAPIPort int `json:"debug_api_port,omitempty"`
+	// DebugAPISocket — дополнительно слушать unix-сокет
+	// bin/run/debug-api.sock (права 0600 — только владелец). macOS и Linux.
+	DebugAPISocket bool `json:"debug_api_socket,omitempty"`
+	// DebugAPISocketOnly — TCP-порт не открывать, только сокет. Имеет смысл
+	// лишь вместе с DebugAPISocket.
+	DebugAPISocketOnly bool `json:"debug_api_socket_only,omitempty"`
+	// DebugAPITokens — именованные токены с областями (debugapi.Scope).
+	// Основной DebugAPIToken по-прежнему может всё.
+	DebugAPITokens []DebugAPINamedToken `json:"debug_api_tokens,omitempty"`
 	// LastTemplateLauncherVersion — версия лаунчера, которая в последний раз
 	// успешно скачала bin/wizard_template.json. На старте сравнивается с
 	// текущей AppVersion: если меньше → шаблон удаляется как протухший
@@ -67,11 +82,11 @@ type Settings struct {
 	// family. См. SPEC 061 §4.
 	SubscriptionDeviceModelHashed bool `json:"subscription_device_model_hashed,omitempty"`
 
-	// --- Daemon-режим ядра (macOS, lxd) -----------------------------------
+	// --- Daemon-режим ядра (macOS/Linux, lxd) -----------------------------
 	//
 	// CoreBackendMode — движок ядра: "" / "classic" — исторический spawn
 	// `sing-box run`; "daemon" — управление долгоживущим демоном
-	// `sing-box lxd` (gRPC + admin REST). Только macOS.
+	// `sing-box lxd` (gRPC + admin REST). Только macOS и Linux.
 	CoreBackendMode string `json:"core_backend_mode,omitempty"`
 	// DaemonAddress — host:port управляющего канала демона (из приглашения
 	// либо дефолт 127.0.0.1:9091 при установке службы лаунчером).
@@ -88,6 +103,10 @@ type Settings struct {
 	// лаунчера. Default false: выход из лаунчера НЕ трогает VPN — главное
 	// UX-преимущество daemon-режима.
 	DaemonStopVPNOnExit bool `json:"daemon_stop_vpn_on_exit,omitempty"`
+	// DaemonServiceScope — "" / "system" — системная служба (launchd,
+	// systemd system); "user" — unit systemd --user (Linux, без sudo и без
+	// TUN). Определяет, какие команды установки показывает лаунчер.
+	DaemonServiceScope string `json:"daemon_service_scope,omitempty"`
 
 	// SubscriptionUserAgent — пользовательский User-Agent для subscription
 	// requests. Пустая строка / отсутствие поля → fallback на
@@ -104,6 +123,29 @@ type Settings struct {
 	// applySubscriptionRequestHeaders использует custom если не пустой,
 	// иначе BuildSubscriptionUserAgent.
 	SubscriptionUserAgent string `json:"subscription_user_agent,omitempty"`
+
+	// NetDiagProbeURL / NetDiagDownloadURL / NetDiagUploadURL — серверы теста
+	// качества канала (окно «Network test»); пусто = дефолты netdiag.
+	// "-" в download/upload выключает направление. Свой сервер в сети
+	// позволяет мерить сам узел, а не дорогу до чужого CDN.
+	NetDiagProbeURL    string `json:"netdiag_probe_url,omitempty"`
+	NetDiagDownloadURL string `json:"netdiag_download_url,omitempty"`
+	NetDiagUploadURL   string `json:"netdiag_upload_url,omitempty"`
+	// NetDiagQualityConfigURL — конфиг networkQuality-сервера для демона
+	// (daemon-режим и удалённые машины); пусто = дефолт демона.
+	NetDiagQualityConfigURL string `json:"netdiag_quality_config_url,omitempty"`
+	// NetDiagSTUNServer — STUN-сервер теста NAT через узел; пусто = тот же,
+	// что на вкладке Diagnostics. Тип NAT определяется только сервером с
+	// поддержкой RFC 5780.
+	NetDiagSTUNServer string `json:"netdiag_stun_server,omitempty"`
+
+	// LauncherUpdateAuto — скачивать и готовить обновление лаунчера в фоне
+	// сразу после проверки версии на старте. Off by default: без флага
+	// лаунчер только сообщает о новой версии, ставит — по кнопке.
+	LauncherUpdateAuto bool `json:"launcher_update_auto,omitempty"`
+	// LauncherUpdateChannel — "" / "stable" — только релизы; "prerelease" —
+	// также pre-release сборки (core/launcher_update.go).
+	LauncherUpdateChannel string `json:"launcher_update_channel,omitempty"`
 }
 
 // ShouldSendHWID — true если флаг nil (default) или явно true.
@@ -169,6 +211,23 @@ func MarkTemplateInstalled(binDir, appVersion string) error {
 	return SaveSettings(binDir, s)
 }
 
+// DebugAPINamedToken — токен Debug API для одного скрипта или интеграции:
+// имя пишется в журнал вызовов, Scopes ограничивают доступ.
+type DebugAPINamedToken struct {
+	Name   string   `json:"name"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// secretFields — поля с секретами, которые шифруются на диске.
+func (s *Settings) secretFields() []*string {
+	out := []*string{&s.DebugAPIToken, &s.DaemonSecret}
+	for i := range s.DebugAPITokens {
+		out = append(out, &s.DebugAPITokens[i].Token)
+	}
+	return out
+}
+
 // LoadSettings reads settings from binDir/settings.json.
 // Returns default settings if file doesn't exist or is invalid.
 func LoadSettings(binDir string) Settings {
@@ -185,17 +244,39 @@ func LoadSettings(binDir string) Settings {
 	if s.Lang == "" {
 		s.Lang = "en"
 	}
+	// Ключа нет — значение остаётся запечатанным: SaveSettings запишет его
+	// как было, а пользователь не потеряет секрет из-за невведённого пароля.
+	for _, f := range s.secretFields() {
+		if v, err := secretstore.Open(*f); err == nil {
+			*f = v
+		} else {
+			debuglog.WarnLog("locale: settings.json: %v", err)
+		}
+	}
 	return s
 }
 
 // SaveSettings writes settings to binDir/settings.json.
 //
+// Secret fields (Debug API token, daemon secret) are sealed when at-rest
+// encryption is on (internal/secretstore); LoadSettings opens them.
+//
 // Writes are atomic: we stage to a sibling temp file then rename over the
 // real one. Protects against power loss or a crash mid-write leaving the
 // user with a zero-byte settings.json and losing language / ping / subs
 // preferences on next launch.
 func SaveSettings(binDir string, s Settings) error {
 	path := filepath.Join(binDir, "settings.json")
+	// s — копия: запечатанные секреты не попадают обратно вызывающему.
+	// Срез токенов общий с вызывающим, поэтому копируется отдельно.
+	s.DebugAPITokens = append([]DebugAPINamedToken(nil), s.DebugAPITokens...)
+	for _, f := range s.secretFields() {
+		v, err := secretstore.Seal(*f)
+		if err != nil {
+			return fmt.Errorf("locale: %w", err)
+		}
+		*f = v
+	}
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return fmt.Errorf("locale: marshal settings: %w", err)
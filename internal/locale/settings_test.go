@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"singbox-launcher/internal/secretstore"
 )
 
 // MarkTemplateInstalled is a thin wrapper over LoadSettings/SaveSettings —
@@ -86,3 +88,29 @@ func TestMarkTemplateInstalled_NoOpWhenSame(t *testing.T) {
 		t.Fatalf("expected no rewrite on idempotent call (mtime changed: %v vs %v)", st1.ModTime(), st2.ModTime())
 	}
 }
+
+// Именованные токены Debug API шифруются на диске вместе с основным, а
+// срез вызывающего остаётся открытым: SaveSettings получает его общим.
+func TestSaveSettings_SealsNamedTokens(t *testing.T) {
+	binDir := t.TempDir()
+	if err := secretstore.Enable(binDir, secretstore.SourcePassphrase, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = secretstore.Disable(binDir) })
+
+	s := Settings{Lang: "en", DebugAPIToken: "primary", DebugAPITokens: []DebugAPINamedToken{
+		{Name: "cron", Token: "cron-secret", Scopes: []string{"actions"}},
+	}}
+	if err := SaveSettings(binDir, s); err != nil {
+		t.Fatal(err)
+	}
+	if s.DebugAPITokens[0].Token != "cron-secret" {
+		t.Fatalf("caller's token sealed in place: %q", s.DebugAPITokens[0].Token)
+	}
+	if raw := readSettings(t, binDir); !secretstore.IsSealed(raw.DebugAPITokens[0].Token) || !secretstore.IsSealed(raw.DebugAPIToken) {
+		t.Fatalf("on disk: %+v", raw)
+	}
+	if got := LoadSettings(binDir); got.DebugAPITokens[0].Token != "cron-secret" || got.DebugAPITokens[0].Scopes[0] != "actions" {
+		t.Fatalf("loaded: %+v", got.DebugAPITokens)
+	}
+}
@@ -40,6 +40,10 @@ type Config struct {
 	// владеет демон, и лаунчер его не знает. Слать заголовок при mTLS
 	// безвредно (демон его игнорирует).
 	Secret string
+	// Dialer — как дозвониться до Addr (SSH-туннель, SOCKS/HTTP-прокси,
+	// своё ядро). nil — напрямую. Им ходят и REST, и gRPC; TLS и пин
+	// остаются сквозными — промежуточный узел видит только шифротекст.
+	Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 // TLSEnabled — включён ли TLS-канал (установлен пин сервера).
@@ -60,6 +64,9 @@ const restTimeout = 30 * time.Second
 // стримы gRPC живут на собственном соединении без таймаута.
 func New(cfg Config) *Client {
 	transport := &http.Transport{}
+	if cfg.Dialer != nil {
+		transport.DialContext = cfg.Dialer
+	}
 	if cfg.TLSEnabled() {
 		transport.TLSClientConfig = cfg.tlsConfig()
 	}
@@ -84,8 +91,8 @@ func (c Config) tlsConfig() *tls.Config {
 				return fmt.Errorf("lxdclient: server presented no certificate")
 			}
 			got := FingerprintOf(rawCerts[0])
-			if got != strings.ToLower(c.ServerFingerprint) {
-				return fmt.Errorf("lxdclient: server fingerprint does not match the pinned one (got %s…)", got[:12])
+			if want := strings.ToLower(c.ServerFingerprint); got != want {
+				return &FingerprintMismatchError{Want: want, Got: got}
 			}
 			return nil
 		}
@@ -96,6 +103,61 @@ func (c Config) tlsConfig() *tls.Config {
 	return conf
 }
 
+// FingerprintMismatchError — сервер предъявил не тот сертификат, что
+// запинен. Либо демон перевыпустил сертификат (переустановка, чистка
+// state-каталога), либо между нами кто-то чужой; различить это может
+// только человек, поэтому сам клиент пин не меняет никогда.
+type FingerprintMismatchError struct {
+	Want string
+	Got  string
+}
+
+func (e *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf("lxdclient: server fingerprint does not match the pinned one (got %s…)", e.Got[:12])
+}
+
+// PeekServerCert делает TLS-рукопожатие с cfg.Addr БЕЗ проверки пина и
+// возвращает отпечаток и срок предъявленного сертификата. Ни одного запроса
+// не уходит: это только «что сервер показывает сейчас» — для сверки с пином
+// и показа пользователю перед перепиновкой.
+func PeekServerCert(ctx context.Context, cfg Config) (string, time.Time, error) {
+	dial := cfg.Dialer
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	raw, err := dial(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	// Сертификат сервера ловим в колбэке, а не из ConnectionState: демон
+	// без нашего клиентского сертификата (чужой или отозванный ключ) рвёт
+	// рукопожатие уже ПОСЛЕ того, как показал свой, — а он-то нам и нужен.
+	var leaf *x509.Certificate
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) > 0 {
+				leaf, _ = x509.ParseCertificate(rawCerts[0])
+			}
+			return nil
+		},
+	}
+	if cfg.Identity != nil {
+		conf.Certificates = []tls.Certificate{cfg.Identity.TLSCert}
+	}
+	conn := tls.Client(raw, conf)
+	defer conn.Close()
+	handshakeErr := conn.HandshakeContext(ctx)
+	if leaf == nil {
+		if handshakeErr != nil {
+			return "", time.Time{}, fmt.Errorf("lxdclient: tls handshake: %w", handshakeErr)
+		}
+		return "", time.Time{}, fmt.Errorf("lxdclient: server presented no certificate")
+	}
+	return FingerprintOf(leaf.Raw), leaf.NotAfter, nil
+}
+
 func (c *Client) baseURL() string {
 	scheme := "http"
 	if c.cfg.TLSEnabled() {
@@ -531,11 +593,21 @@ func (c *Client) DialGRPC() (*grpc.ClientConn, error) {
 	if c.cfg.TLSEnabled() {
 		transportCredentials = credentials.NewTLS(c.cfg.tlsConfig())
 	}
-	return grpc.NewClient(c.cfg.Addr,
+	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(transportCredentials),
 		grpc.WithUnaryInterceptor(c.authUnaryInterceptor()),
 		grpc.WithStreamInterceptor(c.authStreamInterceptor()),
-	)
+	}
+	target := c.cfg.Addr
+	if dial := c.cfg.Dialer; dial != nil {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}))
+		// passthrough: имя резолвит дальний конец маршрута, а не лаунчер —
+		// адрес демона может иметь смысл только за jump-хостом.
+		target = "passthrough:///" + target
+	}
+	return grpc.NewClient(target, opts...)
 }
 
 func (c *Client) authUnaryInterceptor() grpc.UnaryClientInterceptor {
@@ -0,0 +1,106 @@
+package lxdclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Список доверенных клиентских сертификатов демона: GET/POST/DELETE
+// /admin/clients.
+//
+// Не путать с ClientsInfo: тот — справочник устройств ЛОКАЛЬНОЙ СЕТИ машины
+// (аренды DHCP, метки), а здесь — кто пущен в управляющий канал по mTLS.
+// Это та же таблица, что правит CLI `sing-box lxd client add/remove`, только
+// по сети: без неё ротация ключа и отзыв потерянного ноутбука требуют
+// root-доступа к каждой машине.
+//
+// Ручки новее /admin/enroll, и демон может их не знать. Отсутствие — это
+// состояние, а не сбой: ErrClientsUnsupported, и вызывающий откатывается на
+// приглашение и CLI-команду.
+
+// ErrClientsUnsupported — демон не отдаёт список клиентов (старая версия).
+var ErrClientsUnsupported = errors.New("lxdclient: daemon does not expose /admin/clients")
+
+// EnrolledClient — один доверенный клиентский сертификат.
+type EnrolledClient struct {
+	Name        string    `json:"name"`
+	Fingerprint string    `json:"fingerprint"`
+	EnrolledAt  time.Time `json:"enrolled_at"`
+}
+
+// Clients возвращает доверенные сертификаты демона.
+func (c *Client) Clients() ([]EnrolledClient, error) {
+	resp, err := c.do(http.MethodGet, "/admin/clients", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if clientsUnsupported(resp) {
+		return nil, ErrClientsUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lxdclient: clients: %s", decodeError(resp))
+	}
+	var out struct {
+		Clients []EnrolledClient `json:"clients"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("lxdclient: clients: parse: %w", err)
+	}
+	return out.Clients, nil
+}
+
+// AddClient доверяет ещё один сертификат. В отличие от Enroll кода не
+// нужно: запрос уже несёт действующий клиентский сертификат, а он и есть
+// полный мандат. Так ротация обходится без нового приглашения.
+func (c *Client) AddClient(name string, certPEM []byte) error {
+	body, err := json.Marshal(map[string]string{"name": name, "cert_pem": string(certPEM)})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPost, "/admin/clients", bytes.NewReader(body), "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if clientsUnsupported(resp) {
+		return ErrClientsUnsupported
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("lxdclient: add client: %s", decodeError(resp))
+	}
+	return nil
+}
+
+// RemoveClient отзывает сертификат по отпечатку. Отзыв собственного
+// сертификата допустим: ответ приходит по ещё открытому соединению, а
+// следующее демон уже не пустит.
+//
+// 404 здесь — «такого отпечатка нет», то есть цель уже достигнута. Отличить
+// его от старого демона без маршрута нельзя, поэтому поддержку вызывающий
+// проверяет заранее через Clients — отзыв без списка всё равно вслепую.
+func (c *Client) RemoveClient(fingerprint string) error {
+	resp, err := c.do(http.MethodDelete, "/admin/clients/"+url.PathEscape(fingerprint), nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	case http.StatusMethodNotAllowed:
+		return ErrClientsUnsupported
+	}
+	return fmt.Errorf("lxdclient: remove client: %s", decodeError(resp))
+}
+
+// clientsUnsupported — маршрута нет (404) или нет метода (405).
+func clientsUnsupported(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed
+}
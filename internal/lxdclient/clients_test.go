@@ -0,0 +1,80 @@
+package lxdclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientsRoundTrip(t *testing.T) {
+	var added, removed string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/admin/clients":
+			_, _ = w.Write([]byte(`{"clients":[{"name":"singbox-launcher-mac","fingerprint":"aa","enrolled_at":"2026-01-02T03:04:05Z"}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/admin/clients":
+			added = r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/admin/clients/"):
+			removed = strings.TrimPrefix(r.URL.Path, "/admin/clients/")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	})
+	list, err := c.Clients()
+	if err != nil || len(list) != 1 || list[0].Name != "singbox-launcher-mac" || list[0].EnrolledAt.Year() != 2026 {
+		t.Fatalf("clients = %+v, %v", list, err)
+	}
+	if err := c.AddClient("x", []byte("pem")); err != nil || added != "application/json" {
+		t.Fatalf("add: %v (content-type %q)", err, added)
+	}
+	if err := c.RemoveClient("aa"); err != nil || removed != "aa" {
+		t.Fatalf("remove: %v (path %q)", err, removed)
+	}
+}
+
+// Старый демон маршрута не знает — это ErrClientsUnsupported, а не сбой.
+func TestClientsUnsupported(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	if _, err := c.Clients(); !errors.Is(err, ErrClientsUnsupported) {
+		t.Fatalf("clients err = %v", err)
+	}
+	if err := c.AddClient("x", nil); !errors.Is(err, ErrClientsUnsupported) {
+		t.Fatalf("add err = %v", err)
+	}
+}
+
+// Чужой сертификат сервера — типизированная ошибка с обоими отпечатками;
+// PeekServerCert показывает предъявленный без всякого пина.
+func TestFingerprintMismatchAndPeek(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"started"}`))
+	}))
+	srv.TLS = &tls.Config{}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	addr := srv.Listener.Addr().String()
+	real := FingerprintOf(srv.Certificate().Raw)
+
+	got, notAfter, err := PeekServerCert(context.Background(), Config{Addr: addr})
+	if err != nil || got != real || notAfter.IsZero() {
+		t.Fatalf("peek = %s %v %v, want %s", got, notAfter, err, real)
+	}
+
+	pinned := strings.Repeat("0", 64)
+	_, err = New(Config{Addr: addr, ServerFingerprint: pinned}).Status()
+	var mismatch *FingerprintMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Got != real || mismatch.Want != pinned {
+		t.Fatalf("status err = %v", err)
+	}
+	if _, err := New(Config{Addr: addr, ServerFingerprint: real}).Status(); err != nil {
+		t.Fatalf("status with the right pin: %v", err)
+	}
+}
@@ -40,6 +40,8 @@ type Identity struct {
 	// Fingerprint — SHA-256 от DER сертификата, lowercase hex. Именно его
 	// демон пинит при enroll.
 	Fingerprint string
+	// NotAfter — срок сертификата: после него демон клиента не пустит.
+	NotAfter time.Time
 }
 
 // FingerprintOf возвращает SHA-256 отпечаток DER-представления сертификата
@@ -89,6 +91,40 @@ func LoadOrCreateIdentity(dir string) (*Identity, error) {
 	return created, nil
 }
 
+// NewIdentity создаёт новую клиентскую пару, не записывая её на диск.
+// Ротация держит её в памяти, пока демон не подтвердит, что пускает
+// новый сертификат, и только потом сохраняет (SaveIdentity).
+func NewIdentity() (*Identity, error) {
+	return generateIdentity("singbox-launcher")
+}
+
+// SaveIdentity записывает пару в dir вместо прежней. Ключ и сертификат
+// пишутся через временные файлы и rename: оборванная запись не оставит
+// ключ от одной пары рядом с сертификатом от другой.
+func SaveIdentity(dir string, id *Identity) error {
+	identityMu.Lock()
+	defer identityMu.Unlock()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("lxdclient: create identity dir: %w", err)
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{{clientKeyFile, id.KeyPEM}, {clientCertFile, id.CertPEM}}
+	for _, f := range files {
+		tmp := filepath.Join(dir, f.name+".tmp")
+		if err := os.WriteFile(tmp, f.data, 0o600); err != nil {
+			return fmt.Errorf("lxdclient: persist %s: %w", f.name, err)
+		}
+	}
+	for _, f := range files {
+		if err := os.Rename(filepath.Join(dir, f.name+".tmp"), filepath.Join(dir, f.name)); err != nil {
+			return fmt.Errorf("lxdclient: persist %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
 // HasIdentity сообщает, существует ли сохранённая клиентская пара в dir.
 func HasIdentity(dir string) bool {
 	_, certErr := os.Stat(filepath.Join(dir, clientCertFile))
@@ -116,7 +152,9 @@ func generateIdentity(commonName string) (*Identity, error) {
 	if err != nil {
 		return nil, err
 	}
-	now := time.Now()
+	// Секунды: точнее X.509 срок не хранит, и NotAfter из памяти должен
+	// совпадать с прочитанным с диска.
+	now := time.Now().UTC().Truncate(time.Second)
 	template := &x509.Certificate{
 		SerialNumber:          serial,
 		Subject:               pkix.Name{CommonName: commonName},
@@ -140,7 +178,8 @@ func generateIdentity(commonName string) (*Identity, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Identity{CertPEM: certPEM, KeyPEM: keyPEM, TLSCert: tlsCert, Fingerprint: FingerprintOf(der)}, nil
+	return &Identity{CertPEM: certPEM, KeyPEM: keyPEM, TLSCert: tlsCert,
+		Fingerprint: FingerprintOf(der), NotAfter: template.NotAfter}, nil
 }
 
 func identityFromPEM(certPEM, keyPEM []byte) (*Identity, error) {
@@ -152,5 +191,10 @@ func identityFromPEM(certPEM, keyPEM []byte) (*Identity, error) {
 	if block == nil {
 		return nil, fmt.Errorf("lxdclient: invalid client certificate PEM")
 	}
-	return &Identity{CertPEM: certPEM, KeyPEM: keyPEM, TLSCert: tlsCert, Fingerprint: FingerprintOf(block.Bytes)}, nil
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("lxdclient: parse client certificate: %w", err)
+	}
+	return &Identity{CertPEM: certPEM, KeyPEM: keyPEM, TLSCert: tlsCert,
+		Fingerprint: FingerprintOf(block.Bytes), NotAfter: cert.NotAfter}, nil
 }
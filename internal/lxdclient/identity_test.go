@@ -69,3 +69,30 @@ func TestFingerprintOfStable(t *testing.T) {
 		t.Fatal("fingerprint not 64 hex chars")
 	}
 }
+
+// SaveIdentity подменяет пару целиком: следующая загрузка видит новый
+// отпечаток и срок.
+func TestSaveIdentityReplaces(t *testing.T) {
+	dir := t.TempDir()
+	old, err := LoadOrCreateIdentity(dir)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	fresh, err := NewIdentity()
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := SaveIdentity(dir, fresh); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadOrCreateIdentity(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if loaded.Fingerprint != fresh.Fingerprint || loaded.Fingerprint == old.Fingerprint {
+		t.Fatalf("fingerprint after save = %s (old %s, new %s)", loaded.Fingerprint, old.Fingerprint, fresh.Fingerprint)
+	}
+	if !loaded.NotAfter.Equal(fresh.NotAfter) {
+		t.Fatalf("NotAfter = %v, want %v", loaded.NotAfter, fresh.NotAfter)
+	}
+}
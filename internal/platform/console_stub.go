@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package platform
+
+// AttachParentConsole — no-op вне Windows: там у процесса всегда есть
+// stdout/stderr терминала, из которого его запустили.
+func AttachParentConsole() {}
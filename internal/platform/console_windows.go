@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+package platform
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// AttachParentConsole подключает stdout/stderr к консоли родителя.
+//
+// Релизный exe собирается с -H windowsgui: у GUI-процесса нет своей консоли,
+// и вывод CLI-подкоманд, запущенных из cmd/PowerShell, уходил в никуда.
+// Если вывод перенаправлен (файл, pipe) — хэндлы уже валидны, ничего не
+// трогаем; запуск из Проводника (консоли у родителя нет) — тоже no-op.
+func AttachParentConsole() {
+	if h, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err == nil && h != 0 && h != windows.InvalidHandle {
+		return
+	}
+	attach := windows.NewLazySystemDLL("kernel32.dll").NewProc("AttachConsole")
+	const attachParentProcess = ^uintptr(0) // ATTACH_PARENT_PROCESS (DWORD -1)
+	if r, _, _ := attach.Call(attachParentProcess); r == 0 {
+		return
+	}
+	if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = out
+		os.Stderr = out
+	}
+}
@@ -65,6 +65,25 @@ func GetRemoteConfigPathFor(execDir, id string) string {
 	return filepath.Join(GetRemoteMachineDir(execDir, id), constants.ConfigFileName)
 }
 
+// GetBaseProfilesDir returns the directory of shared base profiles that
+// remote machines inherit from: <execDir>/bin/wizard_states/profiles/.
+func GetBaseProfilesDir(execDir string) string {
+	return filepath.Join(GetWizardStatesDir(execDir), constants.BaseProfilesDirName)
+}
+
+// GetBaseProfilePath returns the state file of one base profile:
+// <profiles-dir>/<name>.json. The name is validated by the caller.
+func GetBaseProfilePath(execDir, name string) string {
+	return filepath.Join(GetBaseProfilesDir(execDir), name+".json")
+}
+
+// GetBaseProfileSnapshotPathFor returns the copy of the base profile a remote
+// machine was last materialized from: <machine-dir>/base_profile.snapshot.
+// It is the common ancestor of the three-way merge when the base changes.
+func GetBaseProfileSnapshotPathFor(execDir, id string) string {
+	return filepath.Join(GetRemoteMachineDir(execDir, id), constants.BaseProfileSnapshotFileName)
+}
+
 // GetBinDir returns the path to bin directory
 func GetBinDir(execDir string) string {
 	return filepath.Join(execDir, constants.BinDirName)
@@ -196,6 +215,18 @@ func GetSubscriptionsDir(execDir string) string {
 	return filepath.Join(execDir, constants.BinDirName, constants.SubscriptionsDirName)
 }
 
+// GetRestoreBackupsDir returns the directory where a backup restore keeps the
+// files it overwrote: <execDir>/bin/restore-backups/.
+func GetRestoreBackupsDir(execDir string) string {
+	return filepath.Join(execDir, constants.BinDirName, constants.RestoreBackupsDirName)
+}
+
+// GetDebugAPISocketPath returns the Debug API Unix socket:
+// <execDir>/bin/run/debug-api.sock.
+func GetDebugAPISocketPath(execDir string) string {
+	return filepath.Join(execDir, constants.BinDirName, constants.DebugAPISocketDirName, constants.DebugAPISocketFileName)
+}
+
 // GetLogsDir returns the path to logs directory
 func GetLogsDir(execDir string) string {
 	return filepath.Join(execDir, constants.LogsDirName)
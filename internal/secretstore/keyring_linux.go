@@ -0,0 +1,181 @@
+//go:build linux
+
+package secretstore
+
+// Ключ в системной связке через Secret Service API (gnome-keyring,
+// KWallet 5.97+, KeePassXC) на сессионной шине D-Bus. Сессия — "plain":
+// шина локальная, ключ не уходит с машины. Заблокированная связка
+// открывается её собственным диалогом (Prompt), ждём его не дольше
+// keyringPromptTimeout.
+//
+// Спецификация: https://specifications.freedesktop.org/secret-service/
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretsBus           = "org.freedesktop.secrets"
+	secretsPath          = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretsDefaultAlias  = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+	secretsService       = "org.freedesktop.Secret.Service"
+	secretsItem          = "org.freedesktop.Secret.Item"
+	secretsPrompt        = "org.freedesktop.Secret.Prompt"
+	keyringApplication   = "singbox-launcher"
+	keyringPromptTimeout = 2 * time.Minute
+)
+
+// dbusSecret — структура Secret из спецификации: (oayays).
+type dbusSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+func keyringAttrs(account string) map[string]string {
+	return map[string]string{"application": keyringApplication, "account": account}
+}
+
+func keyringSession() (*dbus.Conn, dbus.ObjectPath, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	var out dbus.Variant
+	var session dbus.ObjectPath
+	if err := conn.Object(secretsBus, secretsPath).Call(secretsService+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&out, &session); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	return conn, session, nil
+}
+
+func keyringAvailable() bool {
+	conn, session, err := keyringSession()
+	if err != nil {
+		return false
+	}
+	_ = conn.Object(secretsBus, session).Call("org.freedesktop.Secret.Session.Close", 0).Err
+	return true
+}
+
+// keyringPromptWait показывает диалог связки и ждёт ответа. "/" — диалог
+// не нужен.
+func keyringPromptWait(conn *dbus.Conn, prompt dbus.ObjectPath) error {
+	if prompt == "/" || prompt == "" {
+		return nil
+	}
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+	match := []dbus.MatchOption{dbus.WithMatchObjectPath(prompt), dbus.WithMatchInterface(secretsPrompt), dbus.WithMatchMember("Completed")}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	defer func() { _ = conn.RemoveMatchSignal(match...) }()
+	if err := conn.Object(secretsBus, prompt).Call(secretsPrompt+".Prompt", 0, "").Err; err != nil {
+		return fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	timeout := time.After(keyringPromptTimeout)
+	for {
+		select {
+		case sig := <-signals:
+			if sig == nil || sig.Path != prompt || sig.Name != secretsPrompt+".Completed" {
+				continue
+			}
+			if dismissed, _ := sig.Body[0].(bool); dismissed {
+				return errors.New("secrets: the keyring unlock was dismissed")
+			}
+			return nil
+		case <-timeout:
+			return errors.New("secrets: the keyring did not answer")
+		}
+	}
+}
+
+// keyringFind — записи установки; заблокированные открываются.
+func keyringFind(conn *dbus.Conn, account string) ([]dbus.ObjectPath, error) {
+	svc := conn.Object(secretsBus, secretsPath)
+	var unlocked, locked []dbus.ObjectPath
+	if err := svc.Call(secretsService+".SearchItems", 0, keyringAttrs(account)).Store(&unlocked, &locked); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	if len(locked) > 0 {
+		var opened []dbus.ObjectPath
+		var prompt dbus.ObjectPath
+		if err := svc.Call(secretsService+".Unlock", 0, locked).Store(&opened, &prompt); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNoKeyring, err)
+		}
+		if err := keyringPromptWait(conn, prompt); err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, locked...)
+	}
+	return unlocked, nil
+}
+
+func keyringGet(account string) ([]byte, error) {
+	conn, session, err := keyringSession()
+	if err != nil {
+		return nil, err
+	}
+	items, err := keyringFind(conn, account)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("secrets: the key is missing from the system keyring")
+	}
+	var secret dbusSecret
+	if err := conn.Object(secretsBus, items[0]).Call(secretsItem+".GetSecret", 0, session).Store(&secret); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(string(secret.Value))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("secrets: the key in the system keyring is damaged")
+	}
+	return key, nil
+}
+
+func keyringSet(account string, key []byte) error {
+	conn, session, err := keyringSession()
+	if err != nil {
+		return err
+	}
+	props := map[string]dbus.Variant{
+		secretsItem + ".Label":      dbus.MakeVariant("sing-box launcher secrets key"),
+		secretsItem + ".Attributes": dbus.MakeVariant(keyringAttrs(account)),
+	}
+	secret := dbusSecret{Session: session, Value: []byte(base64.StdEncoding.EncodeToString(key)), ContentType: "text/plain"}
+	var item, prompt dbus.ObjectPath
+	if err := conn.Object(secretsBus, secretsDefaultAlias).Call("org.freedesktop.Secret.Collection.CreateItem", 0, props, secret, true).Store(&item, &prompt); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoKeyring, err)
+	}
+	return keyringPromptWait(conn, prompt)
+}
+
+func keyringDelete(account string) error {
+	conn, _, err := keyringSession()
+	if err != nil {
+		return err
+	}
+	items, err := keyringFind(conn, account)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := conn.Object(secretsBus, item).Call(secretsItem+".Delete", 0).Store(&prompt); err != nil {
+			return fmt.Errorf("%w: %v", ErrNoKeyring, err)
+		}
+		if err := keyringPromptWait(conn, prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
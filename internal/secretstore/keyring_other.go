@@ -0,0 +1,14 @@
+//go:build !linux
+
+package secretstore
+
+// Системная связка пока только на Linux (Secret Service); на остальных
+// платформах ключ — из пароля.
+
+func keyringAvailable() bool { return false }
+
+func keyringGet(string) ([]byte, error) { return nil, ErrNoKeyring }
+
+func keyringSet(string, []byte) error { return ErrNoKeyring }
+
+func keyringDelete(string) error { return ErrNoKeyring }
@@ -0,0 +1,433 @@
+// Package secretstore шифрует секреты лаунчера на диске: URL подписок и
+// ноды в state.json, ключи WARP, токен Debug API и секрет демона в
+// settings.json, секреты удалённых машин. Шифруются отдельные строковые
+// поля — файлы остаются читаемым JSON, а запечатанное значение выглядит как
+// "enc:v1:<base64>" (AES-256-GCM).
+//
+// Шифрование детерминированное: nonce — HMAC от значения (как в SIV), и
+// одно значение под одним ключом всегда даёт тот же шифротекст. Профили
+// машин сравниваются байтами (снимок базы) и по полям (отличия от базы) —
+// со случайным nonce каждое сохранение выглядело бы правкой. Цена — по
+// файлу видно, что два поля равны, но не что в них.
+//
+// Ключ один на установку. Источник — системная связка ключей (Secret
+// Service по D-Bus на Linux), где её нет — пароль (scrypt). Какой источник
+// выбран, записано в bin/secrets.json вместе с контрольным значением: по
+// нему проверяется, что ключ из связки или пароль тот же, что при
+// включении. Нет файла — шифрование выключено, поля пишутся как раньше.
+//
+// Seal/Open вызывают Save/Load state и настроек, поэтому ключ держится в
+// памяти процесса (Init на старте, Unlock — пароль от пользователя).
+package secretstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+
+	"singbox-launcher/internal/platform"
+)
+
+// Prefix — признак запечатанного значения.
+const Prefix = "enc:v1:"
+
+// MetaFileName — описание ключа в bin/.
+const MetaFileName = "secrets.json"
+
+// PassphraseEnv — пароль для -headless и CLI, где спросить его негде.
+const PassphraseEnv = "SINGBOX_LAUNCHER_SECRETS_PASSPHRASE"
+
+// MinPassphrase — минимальная длина пароля.
+const MinPassphrase = 8
+
+// Source — откуда берётся ключ.
+type Source string
+
+const (
+	SourceKeyring    Source = "keyring"
+	SourcePassphrase Source = "passphrase"
+)
+
+// Параметры scrypt — те же, что у пакета машин (~100 мс на вход).
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// checkPlain — контрольное значение: ключ подходит, если оно открывается.
+const checkPlain = "singbox-launcher/secrets"
+
+var (
+	// ErrLocked — на диске есть запечатанные значения, а ключа нет (пароль
+	// не введён или связка недоступна).
+	ErrLocked = errors.New("secrets: encrypted, enter the passphrase to unlock")
+	// ErrPassphrase — пароль не подходит.
+	ErrPassphrase = errors.New("secrets: wrong passphrase")
+	// ErrNoKeyring — системной связки ключей нет (или она не отвечает).
+	ErrNoKeyring = errors.New("secrets: system keyring is not available")
+	// ErrKeyMismatch — ключ из связки не открывает контрольное значение.
+	ErrKeyMismatch = errors.New("secrets: the key in the system keyring does not match")
+)
+
+// metaFile — bin/secrets.json. []byte в JSON — base64.
+type metaFile struct {
+	Version int    `json:"version"`
+	Source  Source `json:"source"`
+	// Только для SourcePassphrase.
+	KDF  string `json:"kdf,omitempty"`
+	N    int    `json:"n,omitempty"`
+	R    int    `json:"r,omitempty"`
+	P    int    `json:"p,omitempty"`
+	Salt []byte `json:"salt,omitempty"`
+	// Check — checkPlain, запечатанный ключом.
+	Check string `json:"check"`
+}
+
+// sealer — AEAD ключа и ключ HMAC для nonce.
+type sealer struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+var (
+	mu      sync.RWMutex
+	aead    *sealer
+	current *metaFile
+)
+
+// Status — состояние шифрования для окна настроек.
+type Status struct {
+	Enabled bool
+	Source  Source
+	// Locked — шифрование включено, но ключа в памяти нет.
+	Locked bool
+	// KeyringAvailable — системная связка отвечает (можно выбрать её).
+	KeyringAvailable bool
+}
+
+func metaPath(binDir string) string { return filepath.Join(binDir, MetaFileName) }
+
+// keyringAccount — имя записи в связке: своя у каждой установки, иначе две
+// копии лаунчера на одной машине затирали бы ключи друг друга.
+func keyringAccount(binDir string) string {
+	if abs, err := filepath.Abs(binDir); err == nil {
+		return abs
+	}
+	return binDir
+}
+
+// Init читает bin/secrets.json и достаёт ключ: из связки или из
+// PassphraseEnv. Нет файла — шифрование выключено (nil). ErrLocked —
+// включено, но ключ получить не удалось; Unlock примет пароль позже.
+func Init(binDir string) error {
+	m, err := readMeta(binDir)
+	mu.Lock()
+	aead, current = nil, m
+	mu.Unlock()
+	if err != nil || m == nil {
+		return err
+	}
+	switch m.Source {
+	case SourceKeyring:
+		key, kerr := keyringGet(keyringAccount(binDir))
+		if kerr != nil {
+			return fmt.Errorf("%w (%v)", ErrLocked, kerr)
+		}
+		return useKey(m, key, ErrKeyMismatch)
+	case SourcePassphrase:
+		if pass := os.Getenv(PassphraseEnv); pass != "" {
+			return Unlock(binDir, pass)
+		}
+		return ErrLocked
+	}
+	return fmt.Errorf("secrets: unknown key source %q in %s", m.Source, MetaFileName)
+}
+
+// Unlock открывает ключ паролем (шифрование паролем).
+func Unlock(binDir, passphrase string) error {
+	m, err := readMeta(binDir)
+	if err != nil {
+		return err
+	}
+	if m == nil || m.Source != SourcePassphrase {
+		return errors.New("secrets: not encrypted with a passphrase")
+	}
+	key, err := deriveKey(passphrase, m)
+	if err != nil {
+		return err
+	}
+	return useKey(m, key, ErrPassphrase)
+}
+
+// CurrentStatus — включено ли шифрование и есть ли ключ.
+func CurrentStatus() Status {
+	st := Status{KeyringAvailable: keyringAvailable()}
+	mu.RLock()
+	defer mu.RUnlock()
+	if current != nil {
+		st.Enabled, st.Source, st.Locked = true, current.Source, aead == nil
+	}
+	return st
+}
+
+// Enabled — шифрование включено (ключ может быть ещё не получен).
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// Locked — шифрование включено, а ключа нет.
+func Locked() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil && aead == nil
+}
+
+// Enable включает шифрование с новым ключом. Перезаписать файлы с
+// секретами — дело вызывающего (core.SetSecretsEncryption): до этого на
+// диске остаются открытые значения, а Open их просто пропускает.
+func Enable(binDir string, source Source, passphrase string) error {
+	m := &metaFile{Version: 1, Source: source}
+	var key []byte
+	switch source {
+	case SourceKeyring:
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("secrets: %w", err)
+		}
+		if err := keyringSet(keyringAccount(binDir), key); err != nil {
+			return err
+		}
+	case SourcePassphrase:
+		if len(passphrase) < MinPassphrase {
+			return fmt.Errorf("secrets: the passphrase must be at least %d characters", MinPassphrase)
+		}
+		m.KDF, m.N, m.R, m.P = "scrypt", scryptN, scryptR, scryptP
+		m.Salt = make([]byte, 16)
+		if _, err := rand.Read(m.Salt); err != nil {
+			return fmt.Errorf("secrets: %w", err)
+		}
+		var err error
+		if key, err = deriveKey(passphrase, m); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("secrets: unknown key source %q", source)
+	}
+	a, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	if m.Check, err = seal(a, checkPlain); err != nil {
+		return err
+	}
+	if err := writeMeta(binDir, m); err != nil {
+		return err
+	}
+	// Ключ прежней связки больше не нужен.
+	mu.Lock()
+	prev := current
+	aead, current = a, m
+	mu.Unlock()
+	if prev != nil && prev.Source == SourceKeyring && source != SourceKeyring {
+		_ = keyringDelete(keyringAccount(binDir))
+	}
+	return nil
+}
+
+// Disable выключает шифрование: удаляет bin/secrets.json и ключ из связки.
+// Вызывающий должен сначала прочитать файлы (пока ключ есть), а после —
+// записать их заново открытыми.
+func Disable(binDir string) error {
+	mu.Lock()
+	prev := current
+	aead, current = nil, nil
+	mu.Unlock()
+	if err := os.Remove(metaPath(binDir)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	if prev != nil && prev.Source == SourceKeyring {
+		if err := keyringDelete(keyringAccount(binDir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsSealed — значение запечатано.
+func IsSealed(s string) bool { return strings.HasPrefix(s, Prefix) }
+
+// Seal запечатывает значение, если шифрование включено и ключ есть. Пустая
+// строка, уже запечатанное значение (ключа нет — лежит как прочитано) и
+// выключенное шифрование — значение как есть.
+func Seal(plain string) (string, error) {
+	if plain == "" || IsSealed(plain) {
+		return plain, nil
+	}
+	mu.RLock()
+	a, on := aead, current != nil
+	mu.RUnlock()
+	if !on {
+		return plain, nil
+	}
+	if a == nil {
+		return "", ErrLocked
+	}
+	return seal(a, plain)
+}
+
+// Open открывает запечатанное значение; открытое возвращается как есть.
+func Open(s string) (string, error) {
+	if !IsSealed(s) {
+		return s, nil
+	}
+	mu.RLock()
+	a := aead
+	mu.RUnlock()
+	if a == nil {
+		return "", ErrLocked
+	}
+	return open(a, s)
+}
+
+// SealJSON / OpenJSON — то же для json.RawMessage (config_json ноды):
+// запечатанное значение хранится JSON-строкой.
+func SealJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	sealed, err := Seal(string(raw))
+	if err != nil || sealed == string(raw) {
+		return raw, err
+	}
+	return json.Marshal(sealed)
+}
+
+func OpenJSON(raw json.RawMessage) (json.RawMessage, error) {
+	var s string
+	if len(raw) == 0 || raw[0] != '"' || json.Unmarshal(raw, &s) != nil || !IsSealed(s) {
+		return raw, nil
+	}
+	plain, err := Open(s)
+	if err != nil {
+		return raw, err
+	}
+	return json.RawMessage(plain), nil
+}
+
+func useKey(m *metaFile, key []byte, mismatch error) error {
+	a, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	if got, err := open(a, m.Check); err != nil || got != checkPlain {
+		return mismatch
+	}
+	mu.Lock()
+	aead, current = a, m
+	mu.Unlock()
+	return nil
+}
+
+func deriveKey(passphrase string, m *metaFile) ([]byte, error) {
+	// Параметры читаются из файла — ограничиваем, как у пакета машин.
+	if m.KDF != "scrypt" || m.N < 2 || m.N > 1<<20 || m.R < 1 || m.R > 32 || m.P < 1 || m.P > 16 || len(m.Salt) < 8 {
+		return nil, errors.New("secrets: unsupported key derivation parameters")
+	}
+	key, err := scrypt.Key([]byte(passphrase), m.Salt, m.N, m.R, m.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (*sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+	// Ключ nonce — производный, не сам ключ шифрования.
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("singbox-launcher/secrets/nonce"))
+	return &sealer{aead: gcm, nonceKey: mac.Sum(nil)}, nil
+}
+
+// associatedData привязывает шифротекст к формату: значение с другим
+// префиксом не откроется.
+var associatedData = []byte(Prefix)
+
+func seal(a *sealer, plain string) (string, error) {
+	mac := hmac.New(sha256.New, a.nonceKey)
+	mac.Write([]byte(plain))
+	nonce := mac.Sum(nil)[:a.aead.NonceSize()]
+	out := a.aead.Seal(append([]byte(nil), nonce...), nonce, []byte(plain), associatedData)
+	return Prefix + base64.RawStdEncoding.EncodeToString(out), nil
+}
+
+func open(a *sealer, s string) (string, error) {
+	n := a.aead.NonceSize()
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(s, Prefix))
+	if err != nil || len(data) < n {
+		return "", errors.New("secrets: damaged value")
+	}
+	plain, err := a.aead.Open(nil, data[:n], data[n:], associatedData)
+	if err != nil {
+		return "", errors.New("secrets: damaged value or a different key")
+	}
+	return string(plain), nil
+}
+
+func readMeta(binDir string) (*metaFile, error) {
+	raw, err := os.ReadFile(metaPath(binDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+	var m metaFile
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("secrets: parse %s: %w", MetaFileName, err)
+	}
+	if m.Version != 1 {
+		return nil, fmt.Errorf("secrets: %s version %d is not supported by this launcher", MetaFileName, m.Version)
+	}
+	return &m, nil
+}
+
+func writeMeta(binDir string, m *metaFile) error {
+	if err := os.MkdirAll(binDir, platform.DefaultDirMode); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := metaPath(binDir) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	if err := os.Rename(tmp, metaPath(binDir)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("secrets: %w", err)
+	}
+	return nil
+}
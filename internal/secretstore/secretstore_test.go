@@ -0,0 +1,73 @@
+package secretstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// Пароль: значения запечатываются и открываются, новый процесс (Init)
+// без пароля заблокирован, с неверным паролем не открывается.
+func TestPassphraseSealOpen(t *testing.T) {
+	bin := t.TempDir()
+	t.Cleanup(func() { _ = Disable(bin) })
+
+	if got, _ := Seal("https://sub.example/token"); got != "https://sub.example/token" {
+		t.Fatalf("sealed without encryption: %q", got)
+	}
+	if err := Enable(bin, SourcePassphrase, "short"); err == nil {
+		t.Fatal("short passphrase accepted")
+	}
+	if err := Enable(bin, SourcePassphrase, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Seal("https://sub.example/token")
+	if err != nil || !IsSealed(sealed) {
+		t.Fatalf("seal = %q, %v", sealed, err)
+	}
+	if again, _ := Seal(sealed); again != sealed {
+		t.Error("sealed value sealed twice")
+	}
+	// Детерминированно: одинаковые профили (база и её снимок) остаются
+	// одинаковыми на диске.
+	if twice, _ := Seal("https://sub.example/token"); twice != sealed {
+		t.Error("the same value sealed differently")
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	if err := Init(bin); !errors.Is(err, ErrLocked) || !Locked() {
+		t.Fatalf("init without passphrase: %v", err)
+	}
+	if _, err := Open(sealed); !errors.Is(err, ErrLocked) {
+		t.Fatalf("open while locked: %v", err)
+	}
+	if err := Unlock(bin, "wrong horse"); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	t.Setenv(PassphraseEnv, "correct horse")
+	if err := Init(bin); err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := Open(sealed); err != nil || plain != "https://sub.example/token" {
+		t.Fatalf("open = %q, %v", plain, err)
+	}
+	if plain, _ := Open("plain value"); plain != "plain value" {
+		t.Error("plain value changed by Open")
+	}
+
+	raw := json.RawMessage(`{"type":"vless","uuid":"u"}`)
+	sealedJSON, err := SealJSON(raw)
+	if err != nil || !json.Valid(sealedJSON) || string(sealedJSON) == string(raw) {
+		t.Fatalf("SealJSON = %s, %v", sealedJSON, err)
+	}
+	if back, err := OpenJSON(sealedJSON); err != nil || string(back) != string(raw) {
+		t.Fatalf("OpenJSON = %s, %v", back, err)
+	}
+
+	if err := Disable(bin); err != nil {
+		t.Fatal(err)
+	}
+	if st := CurrentStatus(); st.Enabled {
+		t.Errorf("still enabled: %+v", st)
+	}
+}
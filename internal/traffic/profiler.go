@@ -61,6 +61,10 @@ type TrafficProfiler struct {
 	// затрагивает, она обновляется до ветвления по Kind.
 	dnsFromStream bool
 
+	// rates — скорость по outbound'ам для списка серверов; своя блокировка,
+	// читается из UI-потока на каждой перерисовке.
+	rates rateMeter
+
 	// background context for poller/tailer
 	bgCtx    context.Context
 	bgCancel context.CancelFunc
@@ -167,6 +171,7 @@ func (p *TrafficProfiler) runJoin(ctx context.Context) {
 			if !ok {
 				return
 			}
+			p.rates.observe(delta)
 			for _, e := range p.eventsFromPoller(delta) {
 				p.dispatch(e)
 			}
@@ -0,0 +1,89 @@
+package traffic
+
+import (
+	"sync"
+	"time"
+)
+
+// OutboundRate — скорость через outbound, байт в секунду.
+type OutboundRate struct {
+	Up   int64
+	Down int64
+}
+
+// rateStale — замер старше этого не отдаётся: поллер перестал присылать
+// кадры (ядро остановлено), и прошлая скорость была бы враньём.
+const rateStale = 3 * time.Second
+
+// rateMeter считает скорость по тегам outbound'ов из дельт поллера.
+//
+// Соединение учитывается под КАЖДЫМ тегом своей цепочки: и узел, и группа
+// над ним получают его байты. Так список серверов находит скорость узла по
+// его имени, а строка группы — суммарную скорость через неё.
+type rateMeter struct {
+	mu     sync.Mutex
+	rates  map[string]OutboundRate
+	lastAt time.Time
+}
+
+// observe пересчитывает скорости по одной дельте. Байты новых соединений
+// считаются целиком: всё, что они успели передать, пришлось на этот интервал.
+func (m *rateMeter) observe(d ConnDelta) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.lastAt
+	m.lastAt = d.At
+	if prev.IsZero() || !d.At.After(prev) {
+		// Первый кадр: интервала ещё нет, делить не на что.
+		m.rates = nil
+		return
+	}
+	secs := d.At.Sub(prev).Seconds()
+	sums := map[string]OutboundRate{}
+	add := func(chains []string, up, down int64) {
+		seen := make(map[string]bool, len(chains))
+		for _, tag := range chains {
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			r := sums[tag]
+			r.Up += up
+			r.Down += down
+			sums[tag] = r
+		}
+	}
+	for _, b := range d.Bytes {
+		add(b.Conn.Chains, b.UpDelta, b.DownDelta)
+	}
+	for _, c := range d.Opened {
+		add(c.Chains, c.Upload, c.Download)
+	}
+	rates := make(map[string]OutboundRate, len(sums))
+	for tag, s := range sums {
+		rates[tag] = OutboundRate{Up: int64(float64(s.Up) / secs), Down: int64(float64(s.Down) / secs)}
+	}
+	m.rates = rates
+}
+
+// snapshot — копия последних скоростей. ok=false, если замеров нет или они
+// устарели.
+func (m *rateMeter) snapshot(now time.Time) (map[string]OutboundRate, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rates == nil || now.Sub(m.lastAt) > rateStale {
+		return nil, false
+	}
+	out := make(map[string]OutboundRate, len(m.rates))
+	for k, v := range m.rates {
+		out[k] = v
+	}
+	return out, true
+}
+
+// OutboundRates — текущая скорость по тегам outbound'ов (узлам и группам).
+// Тега нет в карте — через него сейчас ничего не идёт. ok=false, пока поток
+// соединений не дал двух кадров или замолчал.
+func (p *TrafficProfiler) OutboundRates() (map[string]OutboundRate, bool) {
+	return p.rates.snapshot(time.Now())
+}
@@ -0,0 +1,40 @@
+package traffic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateMeter(t *testing.T) {
+	var m rateMeter
+	t0 := time.Now()
+	m.observe(ConnDelta{At: t0})
+	if _, ok := m.snapshot(t0); ok {
+		t.Fatal("first frame has no interval and must not report rates")
+	}
+
+	nodeA := ClashConn{ID: "a", Chains: []string{"JP-01", "proxy-out"}}
+	nodeB := ClashConn{ID: "b", Chains: []string{"NL-02", "proxy-out"}, Upload: 400, Download: 800}
+	m.observe(ConnDelta{
+		At:     t0.Add(2 * time.Second),
+		Bytes:  []ClashConnBytesDelta{{Conn: nodeA, UpDelta: 200, DownDelta: 2000}},
+		Opened: []ClashConn{nodeB},
+	})
+	rates, ok := m.snapshot(t0.Add(2 * time.Second))
+	if !ok {
+		t.Fatal("rates must be available after the second frame")
+	}
+	want := map[string]OutboundRate{
+		"JP-01":     {Up: 100, Down: 1000},
+		"NL-02":     {Up: 200, Down: 400},
+		"proxy-out": {Up: 300, Down: 1400},
+	}
+	for tag, w := range want {
+		if rates[tag] != w {
+			t.Errorf("%s = %+v, want %+v", tag, rates[tag], w)
+		}
+	}
+	if _, ok := m.snapshot(t0.Add(10 * time.Second)); ok {
+		t.Error("rates must go stale when the poller stops")
+	}
+}
@@ -0,0 +1,116 @@
+package urlredact
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// urlKeys — fields holding subscription URLs and share URIs (tokens in the
+// path, query or userinfo).
+var urlKeys = map[string]bool{"url": true, "uri": true}
+
+// secretKeys — fields whose whole value is a credential: node passwords and
+// UUIDs, WireGuard/WARP keys, API tokens and secrets.
+var secretKeys = map[string]bool{
+	"password": true, "uuid": true, "private_key": true, "private_key_der": true,
+	"pre_shared_key": true, "psk": true, "auth": true, "auth_str": true,
+	"token": true, "secret": true, "license": true, "authorization": true,
+	"debug_api_token": true, "daemon_secret": true, "obfs_password": true,
+}
+
+// IsSecretKey reports whether a JSON field named key holds a credential as
+// its whole value (password, uuid, private_key, token, ...).
+func IsSecretKey(key string) bool { return secretKeys[strings.ToLower(key)] }
+
+// RedactURL keeps the scheme, host and fragment of a URL (enough to tell
+// which provider or node it is) and masks userinfo, path and query, where
+// subscription tokens and node credentials live:
+//
+//	"https://sub.example/api/v1/sub?token=abc"  → "https://sub.example/***?***"
+//	"vless://uuid@host:443?security=reality#DE" → "vless://***@host:443?***#DE"
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		if i := strings.Index(raw, "://"); i > 0 {
+			return raw[:i+3] + "***"
+		}
+		return RedactToken(raw)
+	}
+	var b strings.Builder
+	b.WriteString(u.Scheme + "://")
+	if u.User != nil {
+		b.WriteString("***@")
+	}
+	b.WriteString(u.Host)
+	switch u.Path {
+	case "", "/":
+		b.WriteString(u.Path)
+	default:
+		b.WriteString("/***")
+	}
+	if u.RawQuery != "" {
+		b.WriteString("?***")
+	}
+	if u.Fragment != "" {
+		b.WriteString("#" + u.Fragment)
+	}
+	return b.String()
+}
+
+// RedactJSONSecrets masks credentials in a JSON document (state.json,
+// config.json, settings.json): URL fields via RedactURL, credential fields
+// via RedactToken, {"name": "...secret...", "value": ...} pairs (template
+// vars), and userinfo in any other string. Key order is not preserved.
+func RedactJSONSecrets(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactJSONValue("", v))
+}
+
+func redactJSONValue(key string, v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		secretVar := false
+		if name, ok := t["name"].(string); ok {
+			secretVar = isSecretName(name)
+		}
+		for k, child := range t {
+			if secretVar && k == "value" {
+				if s, ok := child.(string); ok && s != "" {
+					t[k] = RedactToken(s)
+					continue
+				}
+			}
+			t[k] = redactJSONValue(k, child)
+		}
+		return t
+	case []any:
+		for i, child := range t {
+			t[i] = redactJSONValue(key, child)
+		}
+		return t
+	case string:
+		lk := strings.ToLower(key)
+		switch {
+		case t == "":
+			return t
+		case urlKeys[lk]:
+			return RedactURL(t)
+		case secretKeys[lk]:
+			return RedactToken(t)
+		}
+		return RedactURLUserinfo(t)
+	}
+	return v
+}
+
+func isSecretName(name string) bool {
+	n := strings.ToLower(name)
+	return strings.Contains(n, "secret") || strings.Contains(n, "token") || strings.Contains(n, "password")
+}
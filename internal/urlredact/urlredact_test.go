@@ -1,6 +1,9 @@
 package urlredact
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestRedactURLUserinfo(t *testing.T) {
 	tests := []struct {
@@ -60,3 +63,40 @@ func TestRedactToken(t *testing.T) {
 		})
 	}
 }
+
+func TestRedactURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://sub.example/api/v1/sub?token=abc":      "https://sub.example/***?***",
+		"https://sub.example/":                          "https://sub.example/",
+		"vless://uuid@host:443?security=reality#DE":     "vless://***@host:443?***#DE",
+		"ss://YWVzLTEyOC1nY206cGFzcw@1.2.3.4:8388#node": "ss://***@1.2.3.4:8388#node",
+		"not a url token-0123456789":                    "no***89",
+		"wireguard://%zz":                               "wireguard://***",
+	} {
+		if got := RedactURL(in); got != want {
+			t.Errorf("RedactURL(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedactJSONSecrets(t *testing.T) {
+	in := `{"connections":{"sources":[{"url":"https://sub.example/s/TOKEN","label":"Main"}]},
+		"vars":[{"name":"clash_secret","value":"supersecret1"},{"name":"log_level","value":"info"}],
+		"outbounds":[{"type":"vless","server":"h","uuid":"11111111-2222","config_json":{"password":"PW123456"}}],
+		"note":"via http://u:p@proxy:8080","port":8080}`
+	out, err := RedactJSONSecrets([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	for _, leak := range []string{"TOKEN", "supersecret1", "11111111-2222", "PW123456", "u:p@"} {
+		if strings.Contains(s, leak) {
+			t.Errorf("%q leaked: %s", leak, s)
+		}
+	}
+	for _, keep := range []string{`"label":"Main"`, `"value":"info"`, `"server":"h"`, `"port":8080`, "https://sub.example/***"} {
+		if !strings.Contains(s, keep) {
+			t.Errorf("%q lost: %s", keep, s)
+		}
+	}
+}
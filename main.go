@@ -1,9 +1,12 @@
+//go:build !headless
+
 package main
 
 import (
 	_ "embed" // For embedding resource files (icons)
 	"flag"
 	"log"
+	"os"
 	"runtime"
 	"time"
 
@@ -13,11 +16,11 @@ import (
 
 	"singbox-launcher/api"
 	"singbox-launcher/core"
-	"singbox-launcher/core/services"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/state"
 	"singbox-launcher/internal/debuglog"
-	"singbox-launcher/internal/locale"
 	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
 	"singbox-launcher/ui"
 )
 
@@ -32,16 +35,19 @@ var greyIconData []byte // Icon for "off" state
 //go:embed assets/on.ico
 var greenIconData []byte // Icon for "on" state
 
-// Constants
-const (
-	autoStartDelay = 1 * time.Second // Delay before auto-starting VPN with -start parameter
-)
-
 // main is the application's entry point. It simply creates and runs the AppController.
 func main() {
+	// Scriptable subcommands (build-config, status, ...) never reach Fyne;
+	// see cli.go.
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		platform.AttachParentConsole()
+		os.Exit(runCLI(os.Args[1], os.Args[2:]))
+	}
+
 	// Parse command line arguments
 	autoStart := flag.Bool("start", false, "Automatically start VPN on launch")
 	startInTray := flag.Bool("tray", false, "Start minimized to system tray (hide window on launch)")
+	headless := flag.Bool("headless", false, "Run without window and tray (no display needed); control via Debug API or CLI subcommands")
 	glProbe := flag.Bool("gl-probe", false, "Internal: probe desktop OpenGL and exit (used by the launcher itself)")
 	flag.Parse()
 
@@ -52,6 +58,19 @@ func main() {
 		platform.RunGLProbeChild()
 	}
 
+	// Подготовленное обновление лаунчера (или откат) ставится до контроллера
+	// и окна: новая версия стартует вместо этого процесса.
+	if relaunch := core.ApplyPendingLauncherUpdate(); relaunch != "" {
+		if err := core.RelaunchLauncher(relaunch); err == nil {
+			os.Exit(0)
+		}
+	}
+
+	if *headless {
+		platform.AttachParentConsole()
+		os.Exit(runHeadless(*autoStart))
+	}
+
 	// Create the application controller. If an error occurs, print it and exit the program.
 	// Use greyIconData for red icon (no separate red icon yet)
 	controller, err := core.NewAppController(appIconData, greyIconData, greenIconData, greyIconData)
@@ -66,74 +85,19 @@ func main() {
 	// до Application.Run), пока opengl32.dll ещё не загружен в процесс.
 	platform.EnsureDesktopOpenGL(controller.FileService.ExecDir)
 
-	// Force-invalidate the wizard template if it was last installed by an
-	// older launcher version (SPEC 046). Has to run before any UI consults
-	// bin/wizard_template.json — the Core Dashboard tab's "Download Template"
-	// flow relies on the file being absent.
-	//
-	// Failure here is non-fatal: a stat/remove error means the user keeps
-	// running with the existing (possibly mismatched) template, which is a
-	// degraded but not broken state. The next manual Download Template will
-	// resync `last_template_launcher_version`.
-	if err := core.InvalidateTemplateIfStale(controller.FileService.ExecDir); err != nil {
-		debuglog.WarnLog("template: stale-check failed: %v", err)
-	}
-
-	// SPEC 098: до этой версии профиль удалённой машины был один на всех
-	// (bin/wizard_states/remote/state.json + bin/remote-config.json). Отдаём
-	// его владельцу, пока никто не начал читать новые пути — иначе первая же
-	// машина откроется с пустым состоянием, а настроенный конфиг останется
-	// лежать файлом, на который больше никто не смотрит.
-	//
-	// Non-fatal: при неудаче старые файлы остаются на месте нетронутыми.
-	if err := services.MigrateLegacyRemoteProfile(controller.FileService.ExecDir,
-		services.NewRemoteRegistry(controller.FileService.ExecDir)); err != nil {
-		debuglog.WarnLog("remote migration: %v", err)
-	}
-
-	// Load locale settings and external translations
-	binDir := platform.GetBinDir(controller.FileService.ExecDir)
-	locale.LoadExternalLocales(locale.GetLocaleDir(binDir))
-	settings := locale.LoadSettings(binDir)
-	locale.SetLang(settings.Lang)
-	if settings.PingTestURL != "" {
-		api.SetPingTestURL(settings.PingTestURL)
-	}
-	if settings.PingTestAllConcurrency != 0 {
-		api.SetPingTestAllConcurrency(settings.PingTestAllConcurrency)
-	}
-	// Honor persisted opt-out of subscription auto-update. The loop is started
-	// unconditionally later; this just flips the in-memory gate so it skips work.
-	if settings.SubscriptionAutoUpdateDisabled {
-		controller.StateService.SetAutoUpdateEnabled(false)
-		debuglog.InfoLog("Auto-update: disabled by user setting (subscription_auto_update_disabled=true)")
-	}
-	if settings.AutoPingAfterConnectDisabled {
-		controller.StateService.SetAutoPingAfterConnectEnabled(false)
-		debuglog.InfoLog("Auto-ping: disabled by user setting (auto_ping_after_connect_disabled=true)")
-	}
-	if settings.AutoPingAfterConnectMaxProxies > 0 {
-		controller.StateService.SetAutoPingMaxProxies(settings.AutoPingAfterConnectMaxProxies)
-		debuglog.InfoLog("Auto-ping: max-proxies cap overridden by user setting (auto_ping_after_connect_max_proxies=%d)", settings.AutoPingAfterConnectMaxProxies)
-	}
-	// Optional debug-API (localhost:9263 by default). Off unless user toggled
-	// it on in the Diagnostics tab; token is generated on first enable.
-	if settings.DebugAPIEnabled && settings.DebugAPIToken != "" {
-		if err := controller.StartDebugAPI(settings.DebugAPIPort, settings.DebugAPIToken); err != nil {
-			debuglog.WarnLog("debug-api: failed to start: %v", err)
-		}
-	}
-	debuglog.InfoLog("Locale: language set to %q, available: %v", locale.GetLang(), locale.Languages())
+	settings := prepareController(controller)
+	startDebugAPIFromSettings(controller, settings)
 
 	// Check launcher version on startup (always checks, popup shown on first window display)
 	controller.CheckLauncherVersionOnStartup()
+	controller.ConfirmLauncherUpdate()
 
 	// SPEC 059: spin up the always-on Traffic Profiler service. It tails
 	// sing-box.log + polls Clash /connections in the background so when
 	// the user opens the Traffic Profiler window from Diagnostics they
 	// immediately see the rolling 60s buffer instead of waiting for
 	// events. Recording sessions survive window close.
-	ui.EnsureTrafficProfilerStarted(controller)
+	controller.EnsureTrafficProfilerStarted()
 
 	// Configure the system tray if the application is running on a Desktop platform.
 	//nolint:unused // desktop is used for type assertion, even if linter can't detect it
@@ -247,7 +211,7 @@ func main() {
 					// Wait a bit for everything to initialize
 					<-time.After(autoStartDelay)
 					debuglog.InfoLog("Auto-start: Starting VPN due to -start parameter")
-					core.StartSingBoxProcess()
+					core.StartSingBoxProcess(audit.ActorScheduler)
 				}()
 			}
 
@@ -318,6 +282,12 @@ func main() {
 
 	controller.UpdateUI()
 
+	// Секреты зашифрованы паролем, а PassphraseEnv не задан — спрашиваем его
+	// до того, как пользователь запустит VPN или откроет визард.
+	if secretstore.Locked() {
+		ui.ShowSecretsUnlockDialog(controller, nil)
+	}
+
 	// Reset Clash API HTTP connections after sleep/hibernation and re-sync UI.
 	// Platform no-op where not supported (darwin/linux stubs).
 	//
@@ -409,7 +379,7 @@ func main() {
 	}
 	platform.StopPowerResumeListener()
 
-	controller.GracefulExit()
+	controller.GracefulExit(audit.ActorUI)
 
 	// Close log files through FileService
 	if controller.FileService != nil {
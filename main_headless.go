@@ -0,0 +1,36 @@
+//go:build headless
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"singbox-launcher/core"
+	"singbox-launcher/internal/platform"
+)
+
+// main of the headless build (go build -tags headless): CLI subcommands and
+// runHeadless, nothing else. Neither ui nor Fyne is compiled in, so the
+// binary starts on a server without a display or GL/X11 libraries. CI
+// checks that fyne.io stays out of this build's dependencies.
+func main() {
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		platform.AttachParentConsole()
+		os.Exit(runCLI(os.Args[1], os.Args[2:]))
+	}
+
+	autoStart := flag.Bool("start", false, "Automatically start VPN on launch")
+	// Accepted so service units written for the GUI binary keep working.
+	flag.Bool("headless", true, "Always on in this build")
+	flag.Parse()
+
+	if relaunch := core.ApplyPendingLauncherUpdate(); relaunch != "" {
+		if err := core.RelaunchLauncher(relaunch); err == nil {
+			os.Exit(0)
+		}
+	}
+
+	platform.AttachParentConsole()
+	os.Exit(runHeadless(*autoStart))
+}
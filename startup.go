@@ -0,0 +1,95 @@
+package main
+
+import (
+	"singbox-launcher/api"
+	"singbox-launcher/core"
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
+)
+
+// prepareController — общий для GUI, -headless и CLI-подкоманд старт после
+// создания контроллера: миграции файлов в bin/ и применение settings.json.
+// Возвращает загруженные настройки.
+func prepareController(controller *core.AppController) locale.Settings {
+	// Force-invalidate the wizard template if it was last installed by an
+	// older launcher version (SPEC 046). Has to run before any UI consults
+	// bin/wizard_template.json — the Core Dashboard tab's "Download Template"
+	// flow relies on the file being absent.
+	//
+	// Failure here is non-fatal: a stat/remove error means the user keeps
+	// running with the existing (possibly mismatched) template, which is a
+	// degraded but not broken state. The next manual Download Template will
+	// resync `last_template_launcher_version`.
+	if err := core.InvalidateTemplateIfStale(controller.FileService.ExecDir); err != nil {
+		debuglog.WarnLog("template: stale-check failed: %v", err)
+	}
+
+	// Ключ шифрования секретов — до первого чтения реестра машин,
+	// settings.json и профилей. Пароль без PassphraseEnv спросит окно
+	// (main.go); до этого запечатанные поля остаются запечатанными, а Load
+	// профиля отвечает ErrLocked.
+	binDir := platform.GetBinDir(controller.FileService.ExecDir)
+	if err := secretstore.Init(binDir); err != nil {
+		debuglog.WarnLog("secrets: %v", err)
+	}
+
+	// SPEC 098: до этой версии профиль удалённой машины был один на всех
+	// (bin/wizard_states/remote/state.json + bin/remote-config.json). Отдаём
+	// его владельцу, пока никто не начал читать новые пути — иначе первая же
+	// машина откроется с пустым состоянием, а настроенный конфиг останется
+	// лежать файлом, на который больше никто не смотрит.
+	//
+	// Non-fatal: при неудаче старые файлы остаются на месте нетронутыми.
+	if err := services.MigrateLegacyRemoteProfile(controller.FileService.ExecDir,
+		services.NewRemoteRegistry(controller.FileService.ExecDir)); err != nil {
+		debuglog.WarnLog("remote migration: %v", err)
+	}
+
+	// Load locale settings and external translations
+	locale.LoadExternalLocales(locale.GetLocaleDir(binDir))
+	settings := locale.LoadSettings(binDir)
+	locale.SetLang(settings.Lang)
+	if settings.PingTestURL != "" {
+		api.SetPingTestURL(settings.PingTestURL)
+	}
+	if settings.PingTestAllConcurrency != 0 {
+		api.SetPingTestAllConcurrency(settings.PingTestAllConcurrency)
+	}
+	// Honor persisted opt-out of subscription auto-update. The loop is started
+	// unconditionally later; this just flips the in-memory gate so it skips work.
+	if settings.SubscriptionAutoUpdateDisabled {
+		controller.StateService.SetAutoUpdateEnabled(false)
+		debuglog.InfoLog("Auto-update: disabled by user setting (subscription_auto_update_disabled=true)")
+	}
+	if settings.AutoPingAfterConnectDisabled {
+		controller.StateService.SetAutoPingAfterConnectEnabled(false)
+		debuglog.InfoLog("Auto-ping: disabled by user setting (auto_ping_after_connect_disabled=true)")
+	}
+	if settings.AutoPingAfterConnectMaxProxies > 0 {
+		controller.StateService.SetAutoPingMaxProxies(settings.AutoPingAfterConnectMaxProxies)
+		debuglog.InfoLog("Auto-ping: max-proxies cap overridden by user setting (auto_ping_after_connect_max_proxies=%d)", settings.AutoPingAfterConnectMaxProxies)
+	}
+	if settings.CloseConnectionsOnSwitch && controller.APIService != nil {
+		controller.APIService.SetCloseConnectionsOnSwitch(true)
+	}
+	debuglog.InfoLog("Locale: language set to %q, available: %v", locale.GetLang(), locale.Languages())
+	return settings
+}
+
+// startDebugAPIFromSettings поднимает Debug API, если он включён в settings.json.
+func startDebugAPIFromSettings(controller *core.AppController, settings locale.Settings) {
+	// Optional debug-API (localhost:9263 by default). Off unless user toggled
+	// it on in the Diagnostics tab; token is generated on first enable.
+	if settings.DebugAPIEnabled && secretstore.IsSealed(settings.DebugAPIToken) {
+		debuglog.InfoLog("debug-api: the token is encrypted, starting after the secrets are unlocked")
+		return
+	}
+	if settings.DebugAPIEnabled && settings.DebugAPIToken != "" {
+		if err := controller.StartDebugAPI(settings.DebugAPIPort, settings.DebugAPIToken); err != nil {
+			debuglog.WarnLog("debug-api: failed to start: %v", err)
+		}
+	}
+}
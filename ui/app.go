@@ -6,6 +6,7 @@ import (
 	"fyne.io/fyne/v2/driver/desktop"
 
 	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/events"
 	"singbox-launcher/core/services"
 	"singbox-launcher/internal/locale"
@@ -275,7 +276,7 @@ func (a *App) registerShortcuts() {
 	}
 	reconnect := &desktop.CustomShortcut{KeyName: fyne.KeyR, Modifier: fyne.KeyModifierShortcutDefault}
 	a.window.Canvas().AddShortcut(reconnect, func(fyne.Shortcut) {
-		core.KillSingBoxForRestart()
+		core.KillSingBoxForRestart(audit.ActorUI)
 	})
 	updateSubs := &desktop.CustomShortcut{KeyName: fyne.KeyU, Modifier: fyne.KeyModifierShortcutDefault}
 	a.window.Canvas().AddShortcut(updateSubs, func(fyne.Shortcut) {
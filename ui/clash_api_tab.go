@@ -21,6 +21,7 @@ import (
 
 	"singbox-launcher/api"
 	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/config"
 	"singbox-launcher/core/services"
 	"singbox-launcher/internal/debuglog"
@@ -721,7 +722,11 @@ func CreateProxyListPanel(ac *core.AppController, scope services.ProxyScope) *Pr
 
 		// SPEC 095 — подзаголовок из config.json. Узел, которого там нет
 		// (гонка перегенерации), просто остаётся без подзаголовка.
-		subtitleText.Text = truncateSubtitle(serversNodeSubtitle(ac, proxyInfo, panel.scope))
+		subtitle := serversNodeSubtitle(ac, proxyInfo, panel.scope)
+		if panel.scope == services.ScopeLocal {
+			subtitle = withNodeRate(subtitle, serversNodeRate(localNodeRates(), proxyInfo.Name))
+		}
+		subtitleText.Text = truncateSubtitle(subtitle)
 		subtitleText.Color = theme.Color(theme.ColorNamePlaceHolder)
 		subtitleText.Refresh()
 
@@ -794,7 +799,9 @@ func CreateProxyListPanel(ac *core.AppController, scope services.ProxyScope) *Pr
 				// Через EffectiveProxyTransport: учитывает remote-override
 				// (SPEC 064) и gRPC-транспорт daemon-режима — прямой
 				// APIService.SwitchProxy их не видит.
+				before := ac.GetActiveProxyName()
 				err := ac.APIService.SwitchProxyVia(EffectiveProxyTransportIn(ac, scope), group, proxyNameForCallback)
+				ac.RecordProxySwitch(audit.ActorUI, group, before, proxyNameForCallback, err)
 				fyne.Do(func() {
 					if err != nil {
 						ShowError(ac.UIService.MainWindow, err)
@@ -974,6 +981,9 @@ func CreateProxyListPanel(ac *core.AppController, scope services.ProxyScope) *Pr
 	}
 
 	panel.proxiesList = proxiesListWidget
+	if scope == services.ScopeLocal {
+		startServersRateTicker(proxiesListWidget)
+	}
 
 	// Переменные для отслеживания направления сортировки
 	sortNameAscending := true
@@ -1630,11 +1640,19 @@ func CreateProxyListPanel(ac *core.AppController, scope services.ProxyScope) *Pr
 	// ↻ прижата к правому краю: это действие над всей панелью (перечитать
 	// группы у ядра), а не часть выбора группы. Border разводит их по краям
 	// строки, HBox сложил бы всё встык слева.
-	groupRow := container.NewBorder(nil, nil, nil, refreshRight,
-		container.NewHBox(
-			widget.NewLabel(locale.T("servers.label_selector_group")), groupSelect, mapButton,
-		),
+	groupControls := container.NewHBox(
+		widget.NewLabel(locale.T("servers.label_selector_group")), groupSelect, mapButton,
 	)
+	// Сторож связности (core/watchdog.go) следит за локальным ядром: у
+	// удалённой машины свой лаунчер и свой state.json.
+	if scope == services.ScopeLocal {
+		watchdogButton := widget.NewButton(locale.T("servers.watchdog.button"), func() {
+			OpenWatchdogWindow(ac)
+		})
+		watchdogButton.Importance = widget.LowImportance
+		groupControls.Add(watchdogButton)
+	}
+	groupRow := container.NewBorder(nil, nil, nil, refreshRight, groupControls)
 	topControls := container.NewVBox(groupRow, widget.NewSeparator(), buttonsRow)
 
 	// Обертываем status label в контейнер с горизонтальной прокруткой
@@ -44,6 +44,10 @@ func serversProxyContextMenu(ac *core.AppController, status *widget.Label, win f
 		fyne.NewMenuItem(locale.T("servers.menu_node_info"), func() {
 			showNodeInfoWindow(ac, proxy, cfgPath)
 		}),
+		// Тест канала и NAT через этот узел — тем ядром, чья это строка.
+		fyne.NewMenuItem(locale.T("servers.menu_network_test"), func() {
+			showNetDiagWindow(ac, proxy, scope)
+		}),
 		fyne.NewMenuItem(locale.T("servers.menu_copy_server_link"), func() {
 			serversRunCopyShareURIToClipboard(ac, status, win, proxy.Name, cfgPath)
 		}),
@@ -0,0 +1,137 @@
+package components
+
+import (
+	"image/color"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Sparkline — маленький график из нескольких рядов без осей и подписей.
+//
+// Рисуется отрезками canvas.Line: Fyne не даёт ни полилиний, ни графиков, а
+// тянуть ради одной полоски на вкладке Local библиотеку графиков не стоит.
+// Все ряды делят одну шкалу (максимум по всем рядам), иначе отдача и загрузка
+// в разных масштабах выглядели бы одинаковыми по высоте.
+type Sparkline struct {
+	widget.BaseWidget
+
+	mu     sync.Mutex
+	series [][]float64
+	colors []color.Color
+	// minHeight — высота полоски; ширину задаёт контейнер.
+	minHeight float32
+}
+
+// NewSparkline создаёт график с рядами заданных цветов.
+func NewSparkline(minHeight float32, colors ...color.Color) *Sparkline {
+	s := &Sparkline{colors: colors, minHeight: minHeight, series: make([][]float64, len(colors))}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+// SetSeries заменяет данные. Рядов должно быть столько же, сколько цветов;
+// лишние отбрасываются. Вызывать из UI-потока.
+func (s *Sparkline) SetSeries(series ...[]float64) {
+	s.mu.Lock()
+	for i := range s.series {
+		if i < len(series) {
+			s.series[i] = append(s.series[i][:0], series[i]...)
+		} else {
+			s.series[i] = s.series[i][:0]
+		}
+	}
+	s.mu.Unlock()
+	s.Refresh()
+}
+
+// CreateRenderer implements fyne.Widget.
+func (s *Sparkline) CreateRenderer() fyne.WidgetRenderer {
+	bg := canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground))
+	return &sparklineRenderer{s: s, bg: bg}
+}
+
+type sparklineRenderer struct {
+	s     *Sparkline
+	bg    *canvas.Rectangle
+	lines []*canvas.Line
+	size  fyne.Size
+}
+
+func (r *sparklineRenderer) Layout(size fyne.Size) {
+	r.size = size
+	r.bg.Resize(size)
+	r.rebuild()
+}
+
+func (r *sparklineRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(60, r.s.minHeight)
+}
+
+func (r *sparklineRenderer) Refresh() {
+	r.bg.FillColor = theme.Color(theme.ColorNameInputBackground)
+	r.bg.Refresh()
+	r.rebuild()
+	canvas.Refresh(r.s)
+}
+
+// rebuild перестраивает отрезки под текущие данные и размер. Отрезки
+// переиспользуются: график обновляется раз в секунду, и новые объекты на
+// каждом кадре только нагружали бы сборщик мусора.
+func (r *sparklineRenderer) rebuild() {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	maxV := 0.0
+	for _, ser := range r.s.series {
+		for _, v := range ser {
+			if v > maxV {
+				maxV = v
+			}
+		}
+	}
+	w, h := r.size.Width, r.size.Height
+	used := 0
+	for si, ser := range r.s.series {
+		if len(ser) < 2 {
+			continue
+		}
+		step := w / float32(len(ser)-1)
+		y := func(v float64) float32 {
+			if maxV <= 0 {
+				return h - 1
+			}
+			return h - 1 - float32(v/maxV)*(h-2)
+		}
+		for i := 1; i < len(ser); i++ {
+			if used == len(r.lines) {
+				r.lines = append(r.lines, canvas.NewLine(nil))
+			}
+			ln := r.lines[used]
+			used++
+			ln.StrokeColor = r.s.colors[si]
+			ln.StrokeWidth = 1.5
+			ln.Position1 = fyne.NewPos(float32(i-1)*step, y(ser[i-1]))
+			ln.Position2 = fyne.NewPos(float32(i)*step, y(ser[i]))
+			ln.Show()
+			ln.Refresh()
+		}
+	}
+	for _, ln := range r.lines[used:] {
+		ln.Hide()
+	}
+}
+
+func (r *sparklineRenderer) Objects() []fyne.CanvasObject {
+	objs := make([]fyne.CanvasObject, 0, len(r.lines)+1)
+	objs = append(objs, r.bg)
+	for _, ln := range r.lines {
+		objs = append(objs, ln)
+	}
+	return objs
+}
+
+func (r *sparklineRenderer) Destroy() {}
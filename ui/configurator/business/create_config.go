@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"singbox-launcher/core/build"
+	"singbox-launcher/core/killswitch"
 	wizardmodels "singbox-launcher/ui/configurator/models"
 )
 
@@ -185,7 +186,12 @@ func routeConfigFromModel(model *wizardmodels.WizardModel) build.RouteConfig {
 			RuleSets:    rs.Rule.RuleSets,
 		})
 	}
+	var mark uint32
+	if model.KillSwitch != nil && model.KillSwitch.Enabled {
+		mark = killswitch.DefaultMark
+	}
 	return build.RouteConfig{
+		DefaultMark:               mark,
 		Rules:                     rules,
 		FinalOutbound:             model.SelectedFinalOutbound,
 		ExecDir:                   model.ExecDir,
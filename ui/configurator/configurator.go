@@ -45,6 +45,7 @@ import (
 	fynetooltip "github.com/dweymouth/fyne-tooltip"
 
 	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/config"
 	"singbox-launcher/core/services"
 	wizardtemplate "singbox-launcher/core/template"
@@ -96,9 +97,35 @@ func ShowConfigWizardForMachine(parent fyne.Window, machine services.RemoteDaemo
 	// файловую систему лаунчера. Пусто, если с машиной ещё ни разу не
 	// соединялись — тогда правила с наборами собрать нельзя, о чём Save
 	// скажет явно.
+	syncMachineBaseProfile(parent, machine)
 	showConfigWizardFor(parent, tgt, machine.ResourceDir())
 }
 
+// syncMachineBaseProfile материализует машину от текущего базового профиля
+// перед открытием визарда: сборка удалённой машины есть только здесь, и
+// именно здесь правка базы должна доехать до её state.json.
+//
+// Только если визард ещё не открыт: иначе rebase переписал бы state.json под
+// уже загруженной моделью, и её Save вернул бы старую базу — уже как
+// «собственные отличия» машины. Сбой не мешает открыть визард (state.json
+// цел), но говорится прямо: Deploy всё равно откажет до пересборки.
+func syncMachineBaseProfile(parent fyne.Window, machine services.RemoteDaemon) {
+	ac := core.GetController()
+	if machine.BaseProfile == "" || ac == nil || ac.FileService == nil ||
+		(ac.UIService != nil && ac.UIService.WizardWindow != nil) {
+		return
+	}
+	changed, err := services.NewRemoteRegistry(ac.FileService.ExecDir).SyncBaseProfile(machine.ID)
+	if err != nil {
+		debuglog.WarnLog("configure %q: base profile sync: %v", machine.ID, err)
+		dialog.ShowError(fmt.Errorf("%s", locale.Tf("remote.base.error", err)), parent)
+		return
+	}
+	if changed {
+		debuglog.InfoLog("configure %q: rebased onto base profile %q", machine.ID, machine.BaseProfile)
+	}
+}
+
 func showConfigWizardFor(parent fyne.Window, target wizardtemplate.TargetSpec, resourceDir string) {
 	ac := core.GetController()
 	if ac == nil {
@@ -744,7 +771,7 @@ func handleCloseButton(presenter *wizardpresentation.WizardPresenter, guiState *
 				if ac == nil {
 					return
 				}
-				if err := ac.RebuildConfigIfDirty(); err != nil {
+				if err := ac.RebuildConfigBy(audit.ActorUI, false); err != nil {
 					debuglog.WarnLog("Close→Save: auto-rebuild failed: %v", err)
 				}
 			}()
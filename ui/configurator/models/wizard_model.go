@@ -76,6 +76,18 @@ type WizardModel struct {
 	// вместо новой регистрации, поэтому MASQUE H2/H3 ложатся на один ключ.
 	WarpAccounts *corestate.WarpAccountsSection
 
+	// Supervision — политика присмотра за ядром (state.supervision).
+	// Визард её не редактирует (окно «Присмотр за ядром»), только переносит
+	// при сохранении, чтобы не потерять.
+	Supervision *corestate.SupervisionPolicy
+	// Watchdog — сторож связности (state.watchdog); так же только переносится,
+	// правится в окне «Сторож связности» вкладки Servers.
+	Watchdog *corestate.WatchdogPolicy
+	// KillSwitch — kill switch (state.kill_switch): правится в окне «Ядро →
+	// Kill switch», визард его переносит и по нему ставит route.default_mark
+	// при сборке конфига.
+	KillSwitch *corestate.KillSwitchPolicy
+
 	// ParserConfigJSON — derived: кэш сериализации `AsParserConfig()` в
 	// строку для JSON-editor виджета. Refresh в `RefreshSerializedParserConfig`
 	// после любой мутации Sources/GlobalOutbounds. Не источник истины.
@@ -41,8 +41,10 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/config"
 	"singbox-launcher/core/events"
+	"singbox-launcher/core/state"
 	"singbox-launcher/internal/constants"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/dialogs"
@@ -137,7 +139,12 @@ func (p *WizardPresenter) executeSaveOperation() {
 	p.UpdateSaveStatusText(locale.T("wizard.save.status_saving_state"))
 	p.UpdateSaveProgress(0.5)
 
-	// Step 1: persist state.json.
+	// Step 1: persist state.json. «Было» для журнала действий — до записи;
+	// remote-таргет пишет другой файл, ему снимок не нужен.
+	var before *state.State
+	if p.ConfigTarget() != constants.ConfigTargetRemote {
+		before = core.GetController().StateSnapshot()
+	}
 	statePath := p.saveStateOnly()
 	if statePath == "" {
 		// SaveCurrentState уже отлогировал ошибку. Прекращаем; finalize вернёт UI.
@@ -158,15 +165,12 @@ func (p *WizardPresenter) executeSaveOperation() {
 		if ac == nil {
 			return
 		}
+		// state.Diff против снимка до записи: точечные маркеры
+		// (StateService.ApplyDiff) и запись в журнал действий.
+		ac.ApplyStateChange(audit.ActorUI, before)
 		if ac.StateService != nil {
-			// Save means: state может быть сильно изменён. До интеграции
-			// state.Diff (фаза 4.1 завершена, но calling-side ещё не передаёт
-			// previous-state) — поднимаем оба маркера, чтобы пользователь явно
-			// решил, что нужно: только Update (источники) или Restart (шаблон).
-			//
-			// Превышение по точности — better-safe; пользователь увидит два
-			// маркера и нажмёт оба. Регрессия мелкая и осознанная; чистая
-			// per-domain logic появится в следующей итерации (Diff vs initial state).
+			// Оба маркера всё равно поднимаются — better-safe: Diff не видит
+			// правок, которые Save делает помимо state.json (кэши, SRS).
 			ac.StateService.MarkCacheStale()
 			ac.StateService.MarkConfigStale()
 		}
@@ -200,7 +204,7 @@ func (p *WizardPresenter) executeSaveOperation() {
 		// dirty маркеры на Update/Restart останутся горящими, пользователь
 		// разрулит через UI.
 		go func() {
-			if err := ac.RebuildConfigIfDirty(); err != nil {
+			if err := ac.RebuildConfigBy(audit.ActorUI, false); err != nil {
 				debuglog.WarnLog("Save: auto-rebuild after Save failed: %v", err)
 			}
 		}()
@@ -92,6 +92,9 @@ func (p *WizardPresenter) CreateStateFromModel(comment, id string) *wizardmodels
 	}
 	state.Connections.Defaults = p.model.Defaults
 	state.WarpAccounts = p.model.WarpAccounts
+	state.Supervision = p.model.Supervision
+	state.Watchdog = p.model.Watchdog
+	state.KillSwitch = p.model.KillSwitch
 
 	// Заполняем legacy ParserConfig view ради совместимости тех тестов /
 	// callsite'ов, что читают state.ParserConfig.ParserConfig.Proxies сразу
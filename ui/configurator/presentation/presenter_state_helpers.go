@@ -35,6 +35,9 @@ func (p *WizardPresenter) restoreParserConfig(stateFile *wizardmodels.WizardStat
 	p.model.GlobalOutbounds = append([]configtypes.OutboundConfig(nil), stateFile.Connections.Outbounds...)
 	p.model.Defaults = stateFile.Connections.Defaults
 	p.model.WarpAccounts = stateFile.WarpAccounts
+	p.model.Supervision = stateFile.Supervision
+	p.model.Watchdog = stateFile.Watchdog
+	p.model.KillSwitch = stateFile.KillSwitch
 
 	// Validate: на свежей миграции должна быть хотя бы пустая slice.
 	if p.model.Sources == nil {
@@ -23,6 +23,7 @@ import (
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/locale"
 	"singbox-launcher/internal/platform"
+	"singbox-launcher/internal/secretstore"
 	"singbox-launcher/ui/components"
 	wizardpresentation "singbox-launcher/ui/configurator/presentation"
 )
@@ -347,6 +348,21 @@ func readRawBodySmart(path string, displayPrefixBytes int) ([]byte, int, bool) {
 	if total == 0 {
 		return nil, 0, false
 	}
+	if sealed, full, ok := readSealedRawBody(path); sealed {
+		// Запечатанное тело (шифрование секретов) открывается только
+		// целиком; дальше — как открытое того же размера.
+		if !ok {
+			return nil, total, false
+		}
+		total = len(full)
+		if total > rawBodyFullReadLimit {
+			return full[:min(displayPrefixBytes, total)], total, true
+		}
+		if decoded, derr := subscription.DecodeSubscriptionContent(full); derr == nil && len(decoded) > 0 {
+			return decoded, total, true
+		}
+		return full, total, true
+	}
 	if total <= rawBodyFullReadLimit {
 		// Small body: read all + decode normally.
 		full, rerr := os.ReadFile(path)
@@ -378,6 +394,27 @@ func readRawBodySmart(path string, displayPrefixBytes int) ([]byte, int, bool) {
 	return buf[:n], total, true
 }
 
+// readSealedRawBody — если файл запечатан (начинается с secretstore.Prefix),
+// читает и открывает его целиком: sealed=true, ok — удалось ли открыть.
+func readSealedRawBody(path string) (sealed bool, body []byte, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, nil, false
+	}
+	head := make([]byte, len(secretstore.Prefix))
+	_, err = io.ReadFull(f, head)
+	_ = f.Close()
+	if err != nil || string(head) != secretstore.Prefix {
+		return false, nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return true, nil, false
+	}
+	body, err = corestate.OpenRawBody(data)
+	return true, body, err == nil
+}
+
 // wrapLongLines вставляет '\n' каждые `every` символов в строки, длиннее
 // этого порога. Строки с уже-имеющимися переводами оставляет как есть.
 //
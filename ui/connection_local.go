@@ -1,7 +1,7 @@
 // File connection_local.go — вкладка LOCAL окна подключения: движок
 // локального ядра.
 //
-// Радио выбирает движок: Process (classic) или Daemon (lxd, только macOS).
+// Радио выбирает движок: Process (classic) или Daemon (lxd, macOS и Linux).
 // Радио — это намерение пользователя: выбор «Daemon» показывает панель
 // демона даже когда режим ещё не включён (службу только предстоит установить
 // и сопрячь — кнопки для этого как раз на панели). Фактический движок
@@ -21,7 +21,7 @@ import (
 )
 
 // buildLocalEngineTab собирает вкладку LOCAL. daemon-панель приходит из
-// платформенного builder'а (nil вне macOS — тогда вкладка описывает только
+// платформенного builder'а (nil на Windows — тогда вкладка описывает только
 // classic-режим без переключателя).
 func buildLocalEngineTab(ac *core.AppController, win fyne.Window, onChanged func()) fyne.CanvasObject {
 	processHint := widget.NewLabel(locale.T("conn.process_hint"))
@@ -37,7 +37,7 @@ func buildLocalEngineTab(ac *core.AppController, win fyne.Window, onChanged func
 		}
 	})
 	if daemonPanel == nil {
-		// Не macOS: движок один, переключать нечего.
+		// Windows: движок один, переключать нечего.
 		return container.NewVBox(
 			sectionHeader(locale.T("conn.engine_process")),
 			processHint,
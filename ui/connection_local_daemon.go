@@ -1,4 +1,4 @@
-//go:build darwin
+//go:build darwin || linux
 
 package ui
 
@@ -28,11 +28,12 @@ func wrappedLabel(key string) *widget.Label {
 	return label
 }
 
-// buildDaemonPanel — панель daemon-движка на вкладке LOCAL (macOS).
+// buildDaemonPanel — панель daemon-движка на вкладке LOCAL (macOS, Linux).
 //
 // Все привилегированные операции — консольные: панель показывает готовые
 // sudo-команды (установка / удаление / полное удаление / пере-сопряжение /
-// kickstart) с кнопками «копировать» и «открыть в терминале». Лаунчер сам
+// kickstart) с кнопками «копировать» и «открыть в терминале» (последняя —
+// где лаунчер умеет открыть терминал, см. openTerminal). Лаунчер сам
 // ничего под root не запускает (AEWP выпилен). Сопряжение: команда печатает
 // одноразовое приглашение — пользователь вставляет его в поле ниже.
 //
@@ -68,9 +69,22 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 		return CommandRow(win, labelKey, command, true)
 	}
 
+	// Строки команд зависят от размещения службы (systemd: system/user) —
+	// при его смене пересобираются целиком, иначе поле показывало бы
+	// команду прежнего scope до первого копирования.
+	installCmdBox := container.NewVBox()
+	kickstartBox := container.NewVBox()
+	renderCommandRows := func() {
+		installCmdBox.Objects = []fyne.CanvasObject{commandRowLocal("conn.install_step_cmd", ac.DaemonInstallCommand)}
+		installCmdBox.Refresh()
+		kickstartBox.Objects = []fyne.CanvasObject{commandRowLocal("conn.cmd_kickstart", func() (string, error) { return ac.DaemonKickstartCommand(), nil })}
+		kickstartBox.Refresh()
+	}
+	renderCommandRows()
+
 	maintenanceCommands := container.NewVBox(
 		widget.NewLabelWithStyle(locale.T("conn.cmd_section"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		commandRowLocal("conn.cmd_kickstart", func() (string, error) { return ac.DaemonKickstartCommand(), nil }),
+		kickstartBox,
 	)
 
 	// --- Сопряжение по приглашению ---------------------------------------
@@ -94,7 +108,7 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 	}
 
 	secretHelp := widget.NewButton("?", func() {
-		showCommandHelpDialog(ac, win,
+		showCommandHelpDialog(win,
 			locale.T("settings.daemon_secret_placeholder"),
 			locale.T("conn.secret_help"),
 			ac.DaemonShowSecretCommand())
@@ -126,7 +140,7 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 		}()
 	})
 	pairHelp := widget.NewButton("?", func() {
-		showCommandHelpDialog(ac, win,
+		showCommandHelpDialog(win,
 			locale.T("settings.daemon_pair_btn"),
 			locale.T("settings.daemon_pair_help"),
 			ac.DaemonRepairCommand())
@@ -167,13 +181,17 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 		refreshUninstallCommand()
 		return uninstallEntry.Text, true
 	})
-	uninstallTermBtn := ttwidget.NewButtonWithIcon("", theme.ComputerIcon(), func() {
-		refreshUninstallCommand()
-		if err := ac.OpenTerminalWithCommand(uninstallEntry.Text); err != nil {
-			ShowError(win, err)
-		}
-	})
-	uninstallTermBtn.SetToolTip(locale.T("conn.cmd_terminal_tooltip"))
+	uninstallButtons := container.NewHBox(uninstallCopyBtn)
+	if openTerminal != nil {
+		uninstallTermBtn := ttwidget.NewButtonWithIcon("", theme.ComputerIcon(), func() {
+			refreshUninstallCommand()
+			if err := openTerminal(uninstallEntry.Text); err != nil {
+				ShowError(win, err)
+			}
+		})
+		uninstallTermBtn.SetToolTip(locale.T("conn.cmd_terminal_tooltip"))
+		uninstallButtons.Add(uninstallTermBtn)
+	}
 
 	uninstallSection := container.NewVBox(
 		widget.NewLabelWithStyle(locale.T("conn.uninstall_section"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
@@ -181,7 +199,7 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 		unpairBtn,
 		wrappedLabel("conn.uninstall_step_service"),
 		purgeCheck,
-		container.NewBorder(nil, nil, nil, container.NewHBox(uninstallCopyBtn, uninstallTermBtn), uninstallEntry),
+		container.NewBorder(nil, nil, nil, uninstallButtons, uninstallEntry),
 	)
 
 	// --- Прочее: адрес, stop-on-exit -------------------------------------
@@ -223,10 +241,42 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 	//    в Maintenance (lxd client add).
 	installSection := container.NewVBox(
 		widget.NewLabelWithStyle(locale.T("conn.install_section"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		commandRowLocal("conn.install_step_cmd", ac.DaemonInstallCommand),
-		wrappedLabel("conn.install_step_pair"),
-		container.NewBorder(nil, nil, nil, container.NewHBox(pairBtn, pairHelp), inviteEntry),
 	)
+	// Размещение службы — только где есть выбор (systemd). Радио меняет лишь
+	// показываемые команды: перенос установленной службы делает оператор.
+	if scopes := ac.DaemonServiceScopes(); len(scopes) > 1 {
+		labels := make([]string, len(scopes))
+		for i, scope := range scopes {
+			labels[i] = locale.T("conn.daemon_scope_" + scope)
+		}
+		scopeRadio := widget.NewRadioGroup(labels, nil)
+		scopeRadio.Required = true
+		current := ac.DaemonServiceScope()
+		for i, scope := range scopes {
+			if scope == current {
+				scopeRadio.SetSelected(labels[i])
+			}
+		}
+		scopeRadio.OnChanged = func(label string) {
+			for i, scope := range scopes {
+				if labels[i] != label || scope == ac.DaemonServiceScope() {
+					continue
+				}
+				if err := ac.SetDaemonServiceScope(scope); err != nil {
+					ShowError(win, err)
+					return
+				}
+				renderCommandRows()
+				refreshUninstallCommand()
+				refreshStatus()
+			}
+		}
+		installSection.Add(wrappedLabel("conn.daemon_scope_hint"))
+		installSection.Add(scopeRadio)
+	}
+	installSection.Add(installCmdBox)
+	installSection.Add(wrappedLabel("conn.install_step_pair"))
+	installSection.Add(container.NewBorder(nil, nil, nil, container.NewHBox(pairBtn, pairHelp), inviteEntry))
 
 	return container.NewVBox(
 		hint,
@@ -246,7 +296,7 @@ func buildDaemonPanel(ac *core.AppController, win fyne.Window, onPaired func())
 
 // renderDaemonStatusText — общий рендер статуса демона. includeLocalService
 // добавляет строки про ЛОКАЛЬНОЕ окружение (поддержка lxd установленным
-// ядром, наличие launchd-службы) — для удалённого демона они бессмысленны.
+// ядром, наличие службы launchd/systemd) — для удалённого демона они бессмысленны.
 func renderDaemonStatusText(ac *core.AppController, snap core.DaemonUIStatus, includeLocalService bool) string {
 	var b strings.Builder
 	if includeLocalService {
@@ -305,22 +355,26 @@ func renderDaemonStatusText(ac *core.AppController, snap core.DaemonUIStatus, in
 // showCommandHelpDialog — единый вид справок «текст + готовая команда»:
 // пояснение с переносом, командная строка и кнопки copy/terminal (тихий
 // фидбек галочкой). Вертикальный скролл с каноническим gutter'ом.
-func showCommandHelpDialog(ac *core.AppController, win fyne.Window, title, text, command string) {
+func showCommandHelpDialog(win fyne.Window, title, text, command string) {
 	helpText := widget.NewLabel(text)
 	helpText.Wrapping = fyne.TextWrapWord
 	cmdEntry := widget.NewEntry()
 	cmdEntry.Wrapping = fyne.TextWrapOff
 	cmdEntry.SetText(command)
 	copyBtn := NewCopyButton("conn.cmd_copy_tooltip", func() (string, bool) { return cmdEntry.Text, true })
-	termBtn := ttwidget.NewButtonWithIcon("", theme.ComputerIcon(), func() {
-		if err := ac.OpenTerminalWithCommand(cmdEntry.Text); err != nil {
-			ShowError(win, err)
-		}
-	})
-	termBtn.SetToolTip(locale.T("conn.cmd_terminal_tooltip"))
+	buttons := container.NewHBox(copyBtn)
+	if openTerminal != nil {
+		termBtn := ttwidget.NewButtonWithIcon("", theme.ComputerIcon(), func() {
+			if err := openTerminal(cmdEntry.Text); err != nil {
+				ShowError(win, err)
+			}
+		})
+		termBtn.SetToolTip(locale.T("conn.cmd_terminal_tooltip"))
+		buttons.Add(termBtn)
+	}
 	content := container.NewVBox(
 		helpText,
-		container.NewBorder(nil, nil, nil, container.NewHBox(copyBtn, termBtn), cmdEntry),
+		container.NewBorder(nil, nil, nil, buttons, cmdEntry),
 	)
 	scrolled := container.NewVScroll(container.NewBorder(nil, nil, nil,
 		components.NewScrollGutter(), content))
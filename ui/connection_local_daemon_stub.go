@@ -1,4 +1,4 @@
-//go:build !darwin
+//go:build !darwin && !linux
 
 package ui
 
@@ -8,7 +8,7 @@ import (
 	"singbox-launcher/core"
 )
 
-// buildDaemonPanel — daemon-движок (sing-box lxd) доступен только на macOS;
+// buildDaemonPanel — daemon-движок (sing-box lxd) доступен на macOS и Linux;
 // на остальных платформах вкладка LOCAL показывает только classic-движок.
 func buildDaemonPanel(_ *core.AppController, _ fyne.Window, _ func()) fyne.CanvasObject {
 	return nil
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"image/color"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/ui/components"
+)
+
+// Цвета рядов графика: отдача и загрузка. Те же оттенки, что у стрелок ↑/↓
+// в строке скорости, чтобы легенда не требовалась.
+var (
+	bandwidthUpColor   = color.NRGBA{R: 0xE0, G: 0x8A, B: 0x2E, A: 0xFF}
+	bandwidthDownColor = color.NRGBA{R: 0x2E, G: 0x8B, B: 0xE0, A: 0xFF}
+)
+
+// createBandwidthBlock — строка скорости и памяти ядра и график за последние
+// две минуты. Данные — из ac.CoreStats, который сам выбирает источник по
+// движку (Clash /traffic + /memory или gRPC SubscribeStatus демона).
+//
+// Обновляется тикером раз в секунду, а не подпиской на кадры: при остановке
+// ядра кадров нет вовсе, и только опрос замечает, что строку пора погасить.
+func (tab *CoreDashboardTab) createBandwidthBlock() fyne.CanvasObject {
+	stats := tab.controller.CoreStats
+	if stats == nil {
+		return container.NewVBox()
+	}
+	rateLabel := widget.NewLabel("")
+	memWarn := widget.NewLabel(locale.T("core.bandwidth.memory_growing"))
+	memWarn.Importance = widget.WarningImportance
+	memWarn.Wrapping = fyne.TextWrapWord
+	memWarn.Hide()
+	graph := components.NewSparkline(36, bandwidthUpColor, bandwidthDownColor)
+
+	refresh := func() {
+		last, ok := stats.Latest()
+		if !ok {
+			rateLabel.SetText(locale.T("core.bandwidth.idle"))
+			graph.SetSeries(nil, nil)
+			memWarn.Hide()
+			return
+		}
+		text := locale.Tf("core.bandwidth.rate", core.FormatRate(last.Down), core.FormatRate(last.Up))
+		if last.Memory > 0 {
+			text += " · " + locale.Tf("core.bandwidth.memory", core.FormatSize(last.Memory))
+		}
+		rateLabel.SetText(text)
+
+		hist := stats.History()
+		up := make([]float64, len(hist))
+		down := make([]float64, len(hist))
+		for i, s := range hist {
+			up[i] = float64(s.Up)
+			down[i] = float64(s.Down)
+		}
+		graph.SetSeries(up, down)
+		if stats.MemoryGrowing() {
+			memWarn.Show()
+		} else {
+			memWarn.Hide()
+		}
+	}
+	refresh()
+	go func() {
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for range tick.C {
+			fyne.Do(refresh)
+		}
+	}()
+
+	return container.NewVBox(rateLabel, graph, memWarn)
+}
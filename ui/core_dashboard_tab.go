@@ -22,6 +22,7 @@ import (
 	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
 
 	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
 	"singbox-launcher/core/events"
 	"singbox-launcher/core/state"
 	wizardtemplate "singbox-launcher/core/template"
@@ -106,6 +107,7 @@ func CreateCoreDashboardTab(ac *core.AppController) fyne.CanvasObject {
 
 	contentItems := []fyne.CanvasObject{
 		statusRow,
+		tab.createBandwidthBlock(),
 		widget.NewSeparator(),
 		coreInfo,
 		widget.NewSeparator(),
@@ -114,7 +116,7 @@ func CreateCoreDashboardTab(ac *core.AppController) fyne.CanvasObject {
 	}
 
 	// Горизонтальная линия и кнопка Exit в конце списка
-	exitButton := widget.NewButton(locale.T("core.button_exit"), ac.GracefulExit)
+	exitButton := widget.NewButton(locale.T("core.button_exit"), func() { ac.GracefulExit(audit.ActorUI) })
 	// Кнопка Exit в отдельной строке с отступом вниз
 	contentItems = append(contentItems, widget.NewLabel("")) // Отступ
 	contentItems = append(contentItems, container.NewCenter(exitButton))
@@ -196,6 +198,16 @@ func CreateCoreDashboardTab(ac *core.AppController) fyne.CanvasObject {
 		})
 	}
 
+	// Активная версия ядра сменилась (окно версий, автоматический откат):
+	// строка версии и статус бинаря на дашборде устарели.
+	tab.controller.UIService.CoreVersionsChangedFunc = func() {
+		fyne.Do(func() {
+			_ = tab.updateVersionInfo()
+			tab.updateBinaryStatus()
+		})
+		RefreshCoreVersionsWindow()
+	}
+
 	// Первоначальное обновление
 	tab.updateBinaryStatus() // Проверяет наличие бинарника и вызывает updateRunningStatus
 	_ = tab.updateVersionInfo()
@@ -228,12 +240,12 @@ func (tab *CoreDashboardTab) createStatusRow() fyne.CanvasObject {
 	// пользователь жмёт ещё раз, и второй вызов встаёт на applyMu.
 	startButton := widget.NewButton(locale.T("core.button_start"), func() {
 		tab.beginPendingOp(locale.T("core.status_starting"), true)
-		core.StartSingBoxProcess()
+		core.StartSingBoxProcess(audit.ActorUI)
 	})
 
 	stopButton := widget.NewButton(locale.T("core.button_stop"), func() {
 		tab.beginPendingOp(locale.T("core.status_stopping"), false)
-		core.StopSingBoxProcess()
+		core.StopSingBoxProcess(audit.ActorUI)
 	})
 
 	restartButton := ttwidget.NewButton("🔄", nil)
@@ -273,7 +285,7 @@ func (tab *CoreDashboardTab) createStatusRow() fyne.CanvasObject {
 		}
 		tab.restartButton.Disable()
 		tab.restartButton.Refresh()
-		core.KillSingBoxForRestart()
+		core.KillSingBoxForRestart(audit.ActorUI)
 	}
 
 	doRebuildOnly := func() {
@@ -285,7 +297,7 @@ func (tab *CoreDashboardTab) createStatusRow() fyne.CanvasObject {
 		// dirty-markers чистые. Это гарантирует пробег sing-box check и
 		// показ ошибок popup'ом (см. validateConfigViaSingBox), независимо
 		// от того успел ли config.json обновиться от прошлых правок.
-		if err := ac.RebuildConfigIfDirty(true); err != nil {
+		if err := ac.RebuildConfigBy(audit.ActorUI, true); err != nil {
 			debuglog.WarnLog("CoreDashboard: RebuildConfigIfDirty failed: %v", err)
 			ShowError(ac.UIService.MainWindow, err)
 			return
@@ -322,7 +334,7 @@ func (tab *CoreDashboardTab) createStatusRow() fyne.CanvasObject {
 			fullItem = fyne.NewMenuItem(locale.T("core.restart_menu_full"), doRestartFull)
 		} else {
 			fullItem = fyne.NewMenuItem(locale.T("core.restart_menu_full_when_stopped"), func() {
-				core.StartSingBoxProcess()
+				core.StartSingBoxProcess(audit.ActorUI)
 			})
 		}
 		menu := fyne.NewMenu("", rebuildItem, fullItem)
@@ -530,11 +542,28 @@ func (tab *CoreDashboardTab) createVersionBlock() fyne.CanvasObject {
 		tab.downloadProgress,
 	)
 
+	// Версии рядом друг с другом (core_versions.go): переключение и откат
+	// без повторной загрузки.
+	versionsBtn := widget.NewButton(locale.T("core.versions.button_open"), func() {
+		OpenCoreVersionsWindow(tab.controller)
+	})
+	// Политика перезапусков и проверка живости (core/supervision.go).
+	supervisionBtn := widget.NewButton(locale.T("core.supervision.button_open"), func() {
+		OpenSupervisionWindow(tab.controller)
+	})
+	// Kill switch (core/killswitch.go): блокировка трафика мимо ядра.
+	killSwitchBtn := widget.NewButton(locale.T("core.killswitch.button_open"), func() {
+		OpenKillSwitchWindow(tab.controller)
+	})
+
 	return container.NewHBox(
 		title,
 		layout.NewSpacer(),
 		tab.singboxStatusLabel,
 		tab.downloadContainer,
+		versionsBtn,
+		supervisionBtn,
+		killSwitchBtn,
 		tab.singboxHelpBtn,
 	)
 }
@@ -658,6 +687,7 @@ func (tab *CoreDashboardTab) selectCurrentStateSilently() {
 // логика жила inline в OnChanged — выделена чтобы переиспользовать после
 // «save current and switch» / «discard and switch» flow'ов.
 func (tab *CoreDashboardTab) performStateSwitch(selectedID string) {
+	before := tab.controller.StateSnapshot()
 	if err := tab.switchToNamedState(selectedID); err != nil {
 		debuglog.WarnLog("CoreDashboard: switchToNamedState(%q): %v", selectedID, err)
 		if tab.controller != nil && tab.controller.UIService != nil && tab.controller.UIService.MainWindow != nil {
@@ -666,6 +696,7 @@ func (tab *CoreDashboardTab) performStateSwitch(selectedID string) {
 		return
 	}
 	// state.json — копия выбранного → cache и config устарели.
+	tab.controller.ApplyStateChange(audit.ActorUI, before)
 	if tab.controller.StateService != nil {
 		tab.controller.StateService.MarkCacheStale()
 		tab.controller.StateService.MarkConfigStale()
@@ -992,6 +1023,7 @@ func (tab *CoreDashboardTab) handleDownload() {
 					// Refresh: clears binary-not-found state + button label.
 					_ = tab.updateVersionInfo()
 					tab.updateBinaryStatus()
+					RefreshCoreVersionsWindow()
 					ShowInfo(tab.controller.GetMainWindow(), locale.T("core.dialog_download_complete_title"), progress.Message)
 				case "error":
 					tab.downloadInProgress = false
@@ -1147,6 +1179,12 @@ func (tab *CoreDashboardTab) showUpdatePopup(currentVersion, latestVersion strin
 			widget.NewLabel(""),
 			downloadLink,
 		)
+		// Самообновление: скачать, проверить и поставить при перезапуске
+		// прямо из попапа (core/launcher_update.go). Ссылка остаётся для
+		// сборок, которые обновлять себя не умеют.
+		if actions := launcherUpdatePopupActions(tab.controller, latestVersion, tab.controller.UIService.MainWindow); actions != nil {
+			mainContent.Add(actions)
+		}
 
 		d := dialogs.NewCustom(locale.T("core.dialog_update_available_title"), mainContent, nil, locale.T("core.dialog_update_close"), tab.controller.UIService.MainWindow)
 
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/widget"
 
+	"singbox-launcher/core"
 	wizardtemplate "singbox-launcher/core/template"
 	"singbox-launcher/internal/constants"
 	"singbox-launcher/internal/debuglog"
@@ -21,7 +23,11 @@ import (
 func (tab *CoreDashboardTab) updateBinaryStatus() {
 	// Проверяем, существует ли бинарник
 	if _, err := tab.controller.GetInstalledCoreVersion(); err != nil {
-		tab.statusLabel.SetText(locale.T("core.status_error_not_found"))
+		if errors.Is(err, core.ErrCoreIntegrity) {
+			tab.statusLabel.SetText(locale.T("core.status_error_tampered"))
+		} else {
+			tab.statusLabel.SetText(locale.T("core.status_error_not_found"))
+		}
 		tab.statusLabel.Importance = widget.MediumImportance // Текст всегда черный
 		// UpdateUI will be called automatically by RunningState.Set() or other state changes
 		// Don't call UpdateUI() here to avoid infinite loop
@@ -105,7 +111,7 @@ func (tab *CoreDashboardTab) updateRunningStatus() {
 	// Update status label based on state
 	restartInfo := ""
 	if tab.controller.ConsecutiveCrashAttempts > 0 {
-		restartInfo = fmt.Sprintf(" [restart %d/%d]", tab.controller.ConsecutiveCrashAttempts, 3)
+		restartInfo = fmt.Sprintf(" [restart %d/%d]", tab.controller.ConsecutiveCrashAttempts, tab.controller.SupervisionSettings().MaxAttempts)
 	}
 
 	if !buttonState.BinaryExists {
@@ -300,6 +306,15 @@ func (tab *CoreDashboardTab) updateVersionInfo() error {
 		fyne.Do(func() {
 			tab.singboxStatusLabel.Importance = widget.MediumImportance
 			switch {
+			case errors.Is(err, core.ErrCoreIntegrity):
+				// Бинарь не совпадает с проверенной установкой — запускать
+				// его нельзя, переустановка возвращает проверенный.
+				tab.downloadButton.Importance = widget.HighImportance
+				tab.setSingboxState(
+					locale.T("core.singbox_status_tampered"),
+					locale.Tf("core.button_reinstall_version", required),
+					-1,
+				)
 			case err != nil:
 				// Бинарника нет — синяя «Download vX.Y.Z», подталкиваем к
 				// первичной установке.
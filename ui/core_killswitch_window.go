@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/locale"
+)
+
+// Окно «Kill switch» (core/killswitch.go): включение для профиля,
+// исключения и текущее состояние правил с кнопкой «Разблокировать».
+
+var (
+	killSwitchWindowMu sync.Mutex
+	killSwitchWindow   fyne.Window
+)
+
+// OpenKillSwitchWindow открывает окно kill switch.
+func OpenKillSwitchWindow(ac *core.AppController) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil || ac.FileService == nil {
+		return
+	}
+	killSwitchWindowMu.Lock()
+	if killSwitchWindow != nil {
+		w := killSwitchWindow
+		killSwitchWindowMu.Unlock()
+		w.Show()
+		w.RequestFocus()
+		return
+	}
+	killSwitchWindowMu.Unlock()
+
+	win := ac.UIService.Application.NewWindow(locale.T("core.killswitch.window_title"))
+	st := ac.KillSwitchStatus()
+	policy := st.Policy
+	if policy == nil {
+		policy = &state.KillSwitchPolicy{}
+	}
+
+	enabled := widget.NewCheck(locale.T("core.killswitch.enabled"), nil)
+	enabled.SetChecked(policy.Enabled)
+	allowLAN := widget.NewCheck(locale.T("core.killswitch.allow_lan"), nil)
+	allowLAN.SetChecked(!policy.BlockLAN)
+	allowList := widget.NewMultiLineEntry()
+	allowList.SetPlaceHolder("203.0.113.7\n2001:db8::/32")
+	allowList.SetText(strings.Join(policy.AllowCIDRs, "\n"))
+	allowList.SetMinRowsVisible(4)
+
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	var unblockBtn *widget.Button
+	refresh := func() {
+		st := ac.KillSwitchStatus()
+		var lines []string
+		switch {
+		case !st.Supported:
+			lines = append(lines, locale.T("core.killswitch.unsupported"))
+		case st.Armed:
+			since := st.Since.Local().Format("2006-01-02 15:04:05")
+			if st.Reason == core.KillSwitchReasonPreviousSession {
+				lines = append(lines, locale.Tf("core.killswitch.armed_previous", since))
+			} else {
+				lines = append(lines, locale.Tf("core.killswitch.armed", since))
+			}
+		default:
+			lines = append(lines, locale.T("core.killswitch.not_armed"))
+		}
+		if st.LastError != "" {
+			lines = append(lines, locale.Tf("core.killswitch.error", st.LastError))
+		}
+		status.SetText(strings.Join(lines, "\n"))
+		if st.Armed {
+			unblockBtn.Enable()
+		} else {
+			unblockBtn.Disable()
+		}
+	}
+
+	unblockBtn = widget.NewButton(locale.T("core.killswitch.button_unblock"), func() {
+		if err := ac.ReleaseKillSwitch(); err != nil {
+			status.SetText(locale.Tf("core.killswitch.error", err))
+			return
+		}
+		refresh()
+	})
+	saveBtn := widget.NewButton(locale.T("core.killswitch.button_save"), func() {
+		var cidrs []string
+		for _, line := range strings.Split(allowList.Text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				cidrs = append(cidrs, line)
+			}
+		}
+		p := &state.KillSwitchPolicy{Enabled: enabled.Checked, BlockLAN: !allowLAN.Checked, AllowCIDRs: cidrs}
+		if !p.Enabled && !p.BlockLAN && len(p.AllowCIDRs) == 0 {
+			p = nil
+		}
+		if err := ac.SetKillSwitchPolicy(audit.ActorUI, p); err != nil {
+			status.SetText(locale.Tf("core.killswitch.error", err))
+			return
+		}
+		refresh()
+		if p != nil && p.Enabled && ac.RunningState.IsRunning() && !ac.KillSwitchStatus().Armed {
+			status.SetText(status.Text + "\n" + locale.T("core.killswitch.restart_needed"))
+		}
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	if !killSwitchSupported(ac) {
+		enabled.Disable()
+	}
+
+	hint := widget.NewLabel(locale.T("core.killswitch.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint, enabled, allowLAN,
+		widget.NewLabel(locale.T("core.killswitch.allow_cidrs")), allowList,
+		container.NewHBox(saveBtn, unblockBtn), widget.NewSeparator(), status)
+	win.SetContent(container.NewVScroll(content))
+	win.Resize(fyne.NewSize(560, 520))
+	win.CenterOnScreen()
+	win.SetCloseIntercept(func() {
+		killSwitchWindowMu.Lock()
+		killSwitchWindow = nil
+		killSwitchWindowMu.Unlock()
+		win.Close()
+	})
+
+	killSwitchWindowMu.Lock()
+	killSwitchWindow = win
+	killSwitchWindowMu.Unlock()
+
+	refresh()
+	win.Show()
+}
+
+// killSwitchSupported — галочку есть смысл трогать: платформа умеет kill
+// switch, или он уже включён в профиле (пришёл с другой машины) и его надо
+// уметь выключить.
+func killSwitchSupported(ac *core.AppController) bool {
+	st := ac.KillSwitchStatus()
+	return st.Supported || (st.Policy != nil && st.Policy.Enabled)
+}
@@ -0,0 +1,282 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/locale"
+)
+
+// Окно «Присмотр за ядром» (core/supervision.go): политика перезапусков
+// профиля и журнал решений. Пустое поле — встроенное значение (оно же в
+// подсказке поля), поэтому политика по умолчанию в state.json не пишется.
+
+var (
+	supervisionWindowMu sync.Mutex
+	supervisionWindow   fyne.Window
+)
+
+// supervisionLogLimit — сколько последних событий показывает окно.
+const supervisionLogLimit = 200
+
+// OpenSupervisionWindow открывает окно присмотра за ядром.
+func OpenSupervisionWindow(ac *core.AppController) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil || ac.FileService == nil {
+		return
+	}
+	supervisionWindowMu.Lock()
+	if supervisionWindow != nil {
+		w := supervisionWindow
+		supervisionWindowMu.Unlock()
+		w.Show()
+		w.RequestFocus()
+		return
+	}
+	supervisionWindowMu.Unlock()
+
+	win := ac.UIService.Application.NewWindow(locale.T("core.supervision.window_title"))
+	defaults := core.ResolveSupervisionPolicy(nil)
+
+	intEntry := func(placeholder int) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetPlaceHolder(strconv.Itoa(placeholder))
+		return e
+	}
+	maxAttempts := intEntry(defaults.MaxAttempts)
+	backoffInitial := intEntry(int(defaults.BackoffInitial / time.Second))
+	backoffMax := intEntry(int(defaults.BackoffMax / time.Second))
+	multiplier := widget.NewEntry()
+	multiplier.SetPlaceHolder(strconv.FormatFloat(defaults.BackoffMultiplier, 'g', -1, 64))
+	jitter := intEntry(defaults.JitterPercent)
+	stability := intEntry(int(defaults.StabilityWindow / time.Second))
+
+	probeKinds := []string{
+		locale.T("core.supervision.probe_off"),
+		locale.T("core.supervision.probe_clash_api"),
+		locale.T("core.supervision.probe_url_test"),
+	}
+	probeValues := []string{"", state.SupervisionProbeClashAPI, state.SupervisionProbeURLTest}
+	probeKind := widget.NewSelect(probeKinds, nil)
+	probeInterval := intEntry(int(defaults.ProbeInterval / time.Second))
+	probeFailures := intEntry(defaults.ProbeFailures)
+
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	logList := widget.NewLabel("")
+	logList.Wrapping = fyne.TextWrapWord
+	logList.TextStyle = fyne.TextStyle{Monospace: true}
+
+	fill := func(p *state.SupervisionPolicy) {
+		setInt := func(e *widget.Entry, v int) {
+			if v == 0 {
+				e.SetText("")
+				return
+			}
+			e.SetText(strconv.Itoa(v))
+		}
+		if p == nil {
+			p = &state.SupervisionPolicy{}
+		}
+		setInt(maxAttempts, p.MaxAttempts)
+		setInt(backoffInitial, p.BackoffInitialSec)
+		setInt(backoffMax, p.BackoffMaxSec)
+		multiplier.SetText("")
+		if p.BackoffMultiplier != 0 {
+			multiplier.SetText(strconv.FormatFloat(p.BackoffMultiplier, 'g', -1, 64))
+		}
+		jitter.SetText("")
+		if p.JitterPercent != nil {
+			jitter.SetText(strconv.Itoa(*p.JitterPercent))
+		}
+		setInt(stability, p.StabilitySec)
+		probeKind.SetSelected(probeKinds[0])
+		probeInterval.SetText("")
+		probeFailures.SetText("")
+		if p.Probe != nil {
+			for i, v := range probeValues {
+				if v == p.Probe.Kind {
+					probeKind.SetSelected(probeKinds[i])
+				}
+			}
+			setInt(probeInterval, p.Probe.IntervalSec)
+			setInt(probeFailures, p.Probe.Failures)
+		}
+	}
+
+	// collect собирает политику из формы; nil — всё пусто (встроенная).
+	collect := func() (*state.SupervisionPolicy, error) {
+		parseInt := func(e *widget.Entry, field string) (int, error) {
+			t := strings.TrimSpace(e.Text)
+			if t == "" {
+				return 0, nil
+			}
+			v, err := strconv.Atoi(t)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %q is not a number", field, t)
+			}
+			return v, nil
+		}
+		p := &state.SupervisionPolicy{}
+		var err error
+		for _, f := range []struct {
+			e     *widget.Entry
+			field string
+			dst   *int
+		}{
+			{maxAttempts, "max_attempts", &p.MaxAttempts},
+			{backoffInitial, "backoff_initial_sec", &p.BackoffInitialSec},
+			{backoffMax, "backoff_max_sec", &p.BackoffMaxSec},
+			{stability, "stability_sec", &p.StabilitySec},
+		} {
+			if *f.dst, err = parseInt(f.e, f.field); err != nil {
+				return nil, err
+			}
+		}
+		if t := strings.TrimSpace(multiplier.Text); t != "" {
+			if p.BackoffMultiplier, err = strconv.ParseFloat(t, 64); err != nil {
+				return nil, fmt.Errorf("backoff_multiplier: %q is not a number", t)
+			}
+		}
+		if strings.TrimSpace(jitter.Text) != "" {
+			j, err := parseInt(jitter, "jitter_percent")
+			if err != nil {
+				return nil, err
+			}
+			p.JitterPercent = &j
+		}
+		if kind := probeValues[probeKind.SelectedIndex()]; kind != "" {
+			p.Probe = &state.SupervisionProbe{Kind: kind}
+			if p.Probe.IntervalSec, err = parseInt(probeInterval, "probe.interval_sec"); err != nil {
+				return nil, err
+			}
+			if p.Probe.Failures, err = parseInt(probeFailures, "probe.failures"); err != nil {
+				return nil, err
+			}
+		}
+		if *p == (state.SupervisionPolicy{}) {
+			return nil, nil
+		}
+		return p, nil
+	}
+
+	refresh := func() {
+		go func() {
+			st := ac.SupervisionStatus()
+			events, err := ac.SupervisionLog(supervisionLogLimit)
+			fyne.Do(func() {
+				lines := []string{locale.Tf("core.supervision.attempts", st.Attempts, st.Effective.MaxAttempts)}
+				if !st.Supported {
+					lines = append(lines, locale.T("core.supervision.daemon_mode"))
+				}
+				if lp := st.LastProbe; lp != nil {
+					if lp.OK {
+						lines = append(lines, locale.Tf("core.supervision.last_probe_ok", lp.Time.Local().Format("15:04:05")))
+					} else {
+						lines = append(lines, locale.Tf("core.supervision.last_probe_failed",
+							lp.Time.Local().Format("15:04:05"), lp.Failures, st.Effective.ProbeFailures, lp.Error))
+					}
+				}
+				status.SetText(strings.Join(lines, "\n"))
+				if err != nil {
+					logList.SetText(locale.Tf("core.supervision.error", err))
+					return
+				}
+				logList.SetText(formatSupervisionEvents(events))
+			})
+		}()
+	}
+
+	saveBtn := widget.NewButton(locale.T("core.supervision.button_save"), func() {
+		p, err := collect()
+		if err == nil {
+			err = ac.SetSupervisionPolicy(audit.ActorUI, p)
+		}
+		if err != nil {
+			status.SetText(locale.Tf("core.supervision.error", err))
+			return
+		}
+		refresh()
+	})
+	saveBtn.Importance = widget.HighImportance
+	resetBtn := widget.NewButton(locale.T("core.supervision.button_defaults"), func() {
+		if err := ac.SetSupervisionPolicy(audit.ActorUI, nil); err != nil {
+			status.SetText(locale.Tf("core.supervision.error", err))
+			return
+		}
+		fill(nil)
+		refresh()
+	})
+	refreshBtn := widget.NewButton(locale.T("core.supervision.button_refresh"), refresh)
+
+	form := widget.NewForm(
+		widget.NewFormItem(locale.T("core.supervision.max_attempts"), maxAttempts),
+		widget.NewFormItem(locale.T("core.supervision.backoff_initial"), backoffInitial),
+		widget.NewFormItem(locale.T("core.supervision.backoff_max"), backoffMax),
+		widget.NewFormItem(locale.T("core.supervision.backoff_multiplier"), multiplier),
+		widget.NewFormItem(locale.T("core.supervision.jitter"), jitter),
+		widget.NewFormItem(locale.T("core.supervision.stability"), stability),
+		widget.NewFormItem(locale.T("core.supervision.probe"), probeKind),
+		widget.NewFormItem(locale.T("core.supervision.probe_interval"), probeInterval),
+		widget.NewFormItem(locale.T("core.supervision.probe_failures"), probeFailures),
+	)
+	hint := widget.NewLabel(locale.T("core.supervision.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+
+	fill(ac.SupervisionStatus().Policy)
+
+	top := container.NewVBox(hint, form, container.NewHBox(saveBtn, resetBtn), status, widget.NewSeparator(),
+		container.NewBorder(nil, nil, widget.NewLabelWithStyle(locale.T("core.supervision.log_title"),
+			fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), refreshBtn))
+	win.SetContent(container.NewBorder(top, nil, nil, nil, container.NewVScroll(logList)))
+	win.Resize(fyne.NewSize(640, 720))
+	win.CenterOnScreen()
+	win.SetCloseIntercept(func() {
+		supervisionWindowMu.Lock()
+		supervisionWindow = nil
+		supervisionWindowMu.Unlock()
+		win.Close()
+	})
+
+	supervisionWindowMu.Lock()
+	supervisionWindow = win
+	supervisionWindowMu.Unlock()
+
+	refresh()
+	win.Show()
+}
+
+// formatSupervisionEvents — журнал построчно, новые сверху.
+func formatSupervisionEvents(events []core.SupervisionEvent) string {
+	if len(events) == 0 {
+		return locale.T("core.supervision.log_empty")
+	}
+	lines := make([]string, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		line := ev.Time.Local().Format("2006-01-02 15:04:05") + "  " + ev.Kind
+		if ev.Attempt > 0 && ev.MaxAttempts > 0 {
+			line += fmt.Sprintf(" %d/%d", ev.Attempt, ev.MaxAttempts)
+		}
+		if ev.DelayMs > 0 {
+			line += fmt.Sprintf(" in %v", time.Duration(ev.DelayMs)*time.Millisecond)
+		}
+		if ev.PID > 0 {
+			line += fmt.Sprintf(" pid=%d", ev.PID)
+		}
+		if ev.Detail != "" {
+			line += "  " + ev.Detail
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,291 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/internal/locale"
+)
+
+// Окно версий ядра (core/core_versions.go).
+//
+// Установленные версии лежат рядом, и переключение — это копия готового
+// бинаря, а не скачивание: поэтому «попробовать новую» ничего не стоит, а
+// откат на прежнюю делает сам лаунчер, если новая не приняла конфиг или
+// упала в пробный период. Окно показывает, чем версии отличаются по
+// возможностям (build tags, naive), — ради этого обычно и переключаются.
+
+var (
+	coreVersionsWindowMu sync.Mutex
+	// coreVersionsWindow — единственный экземпляр: повторное открытие
+	// фокусирует его, а откат из core перерисовывает через refresh.
+	coreVersionsWindow  fyne.Window
+	coreVersionsRefresh func()
+)
+
+// OpenCoreVersionsWindow открывает окно версий ядра.
+func OpenCoreVersionsWindow(ac *core.AppController) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil || ac.FileService == nil {
+		return
+	}
+	coreVersionsWindowMu.Lock()
+	if coreVersionsWindow != nil {
+		w := coreVersionsWindow
+		coreVersionsWindowMu.Unlock()
+		w.Show()
+		w.RequestFocus()
+		return
+	}
+	coreVersionsWindowMu.Unlock()
+
+	win := ac.UIService.Application.NewWindow(locale.T("core.versions.window_title"))
+
+	notice := widget.NewLabel("")
+	notice.Wrapping = fyne.TextWrapWord
+	notice.Hide()
+	rows := container.NewVBox()
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	// busy — идёт установка или переключение: кнопки строк на это время
+	// гаснут, чтобы не запустить второе поверх первого.
+	busy := false
+	var refresh func()
+
+	run := func(what func() error, done string) {
+		busy = true
+		refresh()
+		go func() {
+			err := what()
+			fyne.Do(func() {
+				busy = false
+				if err != nil {
+					status.SetText(locale.Tf("core.versions.error", err))
+				} else {
+					status.SetText(done)
+				}
+				refresh()
+				notifyCoreVersionsChanged(ac)
+			})
+		}()
+	}
+
+	render := func(list []core.CoreVersionInfo) {
+		rows.RemoveAll()
+		if len(list) == 0 {
+			rows.Add(widget.NewLabel(locale.T("core.versions.empty")))
+		}
+		var activeTags []string
+		for _, v := range list {
+			if v.Active {
+				activeTags = v.Tags
+			}
+		}
+		for _, v := range list {
+			v := v
+			title := widget.NewLabelWithStyle(v.Version+coreVersionBadges(v), fyne.TextAlignLeading,
+				fyne.TextStyle{Bold: v.Active})
+			details := widget.NewLabel(coreVersionDetails(v, activeTags))
+			details.Wrapping = fyne.TextWrapWord
+
+			activateBtn := widget.NewButton(locale.T("core.versions.button_activate"), func() {
+				run(func() error { return ac.ActivateCoreVersion(v.Version) },
+					locale.Tf("core.versions.activated", v.Version))
+			})
+			removeBtn := widget.NewButton(locale.T("core.versions.button_remove"), func() {
+				ShowConfirm(win, locale.T("core.versions.remove_confirm_title"),
+					locale.Tf("core.versions.remove_confirm_message", v.Version), func(ok bool) {
+						if !ok {
+							return
+						}
+						run(func() error { return ac.RemoveCoreVersion(v.Version) },
+							locale.Tf("core.versions.removed", v.Version))
+					})
+			})
+			if v.Active || busy || !ac.CoreStoreInUse() {
+				activateBtn.Disable()
+			}
+			if v.Active || busy {
+				removeBtn.Disable()
+			}
+			rows.Add(container.NewBorder(nil, nil, nil,
+				container.NewHBox(activateBtn, removeBtn),
+				container.NewVBox(title, details)))
+			rows.Add(widget.NewSeparator())
+		}
+
+		var lines []string
+		if !ac.CoreStoreInUse() {
+			lines = append(lines, locale.T("core.versions.store_not_in_use"))
+		}
+		if fb, ok := ac.LastCoreFallback(); ok {
+			lines = append(lines, locale.Tf("core.versions.last_fallback",
+				fb.At.Local().Format("2006-01-02 15:04"), fb.From, fb.To, fb.Reason))
+		}
+		notice.SetText(strings.Join(lines, "\n\n"))
+		notice.Hidden = len(lines) == 0
+		notice.Refresh()
+	}
+
+	// refresh перечитывает хранилище вне UI-потока: первый вызов может
+	// запускать `sing-box version` у каждой версии.
+	refresh = func() {
+		go func() {
+			list, err := ac.ListCoreVersions()
+			fyne.Do(func() {
+				if err != nil {
+					status.SetText(locale.Tf("core.versions.error", err))
+				}
+				render(list)
+			})
+		}()
+	}
+
+	installEntry := widget.NewEntry()
+	installEntry.SetPlaceHolder(locale.T("core.versions.install_placeholder"))
+	installProgress := widget.NewProgressBar()
+	installProgress.Hide()
+	var installBtn *widget.Button
+	installBtn = widget.NewButton(locale.T("core.versions.button_install"), func() {
+		version, err := core.NormalizeCoreVersion(installEntry.Text)
+		if err != nil {
+			status.SetText(locale.Tf("core.versions.error", err))
+			return
+		}
+		installBtn.Disable()
+		installProgress.SetValue(0)
+		installProgress.Show()
+		progressChan := make(chan core.DownloadProgress, 10)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			ac.InstallCoreVersion(ctx, version, progressChan)
+		}()
+		go func() {
+			for p := range progressChan {
+				fyne.Do(func() {
+					installProgress.SetValue(float64(p.Progress) / 100.0)
+					switch p.Status {
+					case "done":
+						status.SetText(locale.Tf("core.versions.installed", version))
+						installEntry.SetText("")
+					case "error":
+						status.SetText(locale.Tf("core.versions.error", p.Error))
+					default:
+						status.SetText(p.Message)
+					}
+				})
+			}
+			fyne.Do(func() {
+				installProgress.Hide()
+				installBtn.Enable()
+				refresh()
+			})
+		}()
+	})
+	installRow := container.NewBorder(nil, nil, nil, installBtn, installEntry)
+
+	hint := widget.NewLabel(locale.T("core.versions.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+
+	top := container.NewVBox(hint, notice)
+	bottom := container.NewVBox(widget.NewSeparator(), installRow, installProgress, status)
+	win.SetContent(container.NewBorder(top, bottom, nil, nil, container.NewVScroll(rows)))
+	win.Resize(fyne.NewSize(640, 520))
+	win.CenterOnScreen()
+	win.SetCloseIntercept(func() {
+		coreVersionsWindowMu.Lock()
+		coreVersionsWindow, coreVersionsRefresh = nil, nil
+		coreVersionsWindowMu.Unlock()
+		win.Close()
+	})
+
+	coreVersionsWindowMu.Lock()
+	coreVersionsWindow, coreVersionsRefresh = win, refresh
+	coreVersionsWindowMu.Unlock()
+
+	refresh()
+	win.Show()
+}
+
+// RefreshCoreVersionsWindow перерисовывает открытое окно версий (после
+// автоматического отката или загрузки с дашборда). Безопасно из любой
+// горутины.
+func RefreshCoreVersionsWindow() {
+	coreVersionsWindowMu.Lock()
+	refresh := coreVersionsRefresh
+	coreVersionsWindowMu.Unlock()
+	if refresh != nil {
+		fyne.Do(refresh)
+	}
+}
+
+// notifyCoreVersionsChanged — дашборд показывает активную версию, ему тоже
+// надо узнать о переключении из окна.
+func notifyCoreVersionsChanged(ac *core.AppController) {
+	if ac.UIService != nil && ac.UIService.CoreVersionsChangedFunc != nil {
+		ac.UIService.CoreVersionsChangedFunc()
+	}
+}
+
+// coreVersionBadges — пометки версии после её номера.
+func coreVersionBadges(v core.CoreVersionInfo) string {
+	var b []string
+	if v.Active {
+		b = append(b, locale.T("core.versions.badge_active"))
+	}
+	if v.Trial {
+		b = append(b, locale.T("core.versions.badge_trial"))
+	}
+	if v.Previous {
+		b = append(b, locale.T("core.versions.badge_previous"))
+	}
+	if v.Pinned {
+		b = append(b, locale.T("core.versions.badge_pinned"))
+	}
+	if len(b) == 0 {
+		return ""
+	}
+	return "   [" + strings.Join(b, ", ") + "]"
+}
+
+// coreVersionDetails — чем версия отличается от активной: build tags и
+// поддержка naive. У активной — её собственный набор тегов.
+func coreVersionDetails(v core.CoreVersionInfo, activeTags []string) string {
+	var lines []string
+	if v.Active || activeTags == nil {
+		if len(v.Tags) > 0 {
+			lines = append(lines, locale.Tf("core.versions.tags", strings.Join(v.Tags, ", ")))
+		}
+	} else {
+		added, removed := core.CoreTagsDiff(v.Tags, activeTags)
+		switch {
+		case len(added) == 0 && len(removed) == 0:
+			lines = append(lines, locale.T("core.versions.tags_same"))
+		default:
+			if len(added) > 0 {
+				lines = append(lines, locale.Tf("core.versions.tags_added", strings.Join(added, ", ")))
+			}
+			if len(removed) > 0 {
+				lines = append(lines, locale.Tf("core.versions.tags_removed", strings.Join(removed, ", ")))
+			}
+		}
+	}
+	if v.NaiveSupported {
+		lines = append(lines, locale.T("core.versions.naive_yes"))
+	} else {
+		lines = append(lines, locale.Tf("core.versions.naive_no", v.NaiveReason))
+	}
+	if v.VerifiedBy != "" {
+		lines = append(lines, fmt.Sprintf("%s %s", locale.T("core.versions.verified_by"), v.VerifiedBy))
+	}
+	return strings.Join(lines, "\n")
+}
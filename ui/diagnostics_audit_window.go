@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/internal/locale"
+)
+
+// Окно «Журнал действий» (core/audit, logs/audit.jsonl): кто и когда менял
+// состояние, пересобирал конфиг, запускал ядро, переключал прокси,
+// деплоил машины и обновлял источники. Только чтение; фильтры — вид
+// актора, действие и подстрока.
+
+var (
+	auditWindowMu sync.Mutex
+	auditWindow   fyne.Window
+)
+
+// auditLogLimit — сколько последних подходящих записей показывает окно.
+const auditLogLimit = 500
+
+// OpenAuditLogWindow открывает окно журнала действий.
+func OpenAuditLogWindow(ac *core.AppController) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil || ac.FileService == nil {
+		return
+	}
+	auditWindowMu.Lock()
+	if auditWindow != nil {
+		w := auditWindow
+		auditWindowMu.Unlock()
+		w.Show()
+		w.RequestFocus()
+		return
+	}
+	auditWindowMu.Unlock()
+
+	win := ac.UIService.Application.NewWindow(locale.T("diag.audit.window_title"))
+
+	all := locale.T("diag.audit.filter_all")
+	actorOptions := []string{all}
+	for _, a := range audit.ActorKinds {
+		actorOptions = append(actorOptions, string(a))
+	}
+	actionOptions := []string{all}
+	for _, a := range audit.Actions {
+		actionOptions = append(actionOptions, string(a))
+	}
+	actorSelect := widget.NewSelect(actorOptions, nil)
+	actorSelect.SetSelected(all)
+	actionSelect := widget.NewSelect(actionOptions, nil)
+	actionSelect.SetSelected(all)
+	textEntry := widget.NewEntry()
+	textEntry.SetPlaceHolder(locale.T("diag.audit.filter_text"))
+
+	status := widget.NewLabel("")
+	logList := widget.NewLabel("")
+	logList.Wrapping = fyne.TextWrapWord
+	logList.TextStyle = fyne.TextStyle{Monospace: true}
+
+	refresh := func() {
+		var f audit.Filter
+		if actorSelect.Selected != all {
+			f.Actor = audit.Actor(actorSelect.Selected)
+		}
+		if actionSelect.Selected != all {
+			f.Action = audit.Action(actionSelect.Selected)
+		}
+		f.Text = textEntry.Text
+		go func() {
+			entries, err := ac.AuditLog(f, auditLogLimit)
+			fyne.Do(func() {
+				if err != nil {
+					status.SetText(locale.Tf("diag.audit.error", err))
+					logList.SetText("")
+					return
+				}
+				status.SetText(locale.Tf("diag.audit.count", len(entries)))
+				logList.SetText(formatAuditEntries(entries))
+			})
+		}()
+	}
+	actorSelect.OnChanged = func(string) { refresh() }
+	actionSelect.OnChanged = func(string) { refresh() }
+	textEntry.OnSubmitted = func(string) { refresh() }
+	refreshBtn := widget.NewButton(locale.T("diag.audit.button_refresh"), refresh)
+
+	filters := widget.NewForm(
+		widget.NewFormItem(locale.T("diag.audit.filter_actor"), actorSelect),
+		widget.NewFormItem(locale.T("diag.audit.filter_action"), actionSelect),
+		widget.NewFormItem(locale.T("diag.audit.filter_search"), textEntry),
+	)
+	hint := widget.NewLabel(locale.T("diag.audit.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+
+	top := container.NewVBox(hint, filters, container.NewBorder(nil, nil, nil, refreshBtn, status), widget.NewSeparator())
+	win.SetContent(container.NewBorder(top, nil, nil, nil, container.NewVScroll(logList)))
+	win.Resize(fyne.NewSize(820, 640))
+	win.CenterOnScreen()
+	win.SetCloseIntercept(func() {
+		auditWindowMu.Lock()
+		auditWindow = nil
+		auditWindowMu.Unlock()
+		win.Close()
+	})
+
+	auditWindowMu.Lock()
+	auditWindow = win
+	auditWindowMu.Unlock()
+
+	refresh()
+	win.Show()
+}
+
+// formatAuditEntries — журнал построчно, новые сверху:
+// «время  актор  действие  цель  было → стало  ! ошибка».
+func formatAuditEntries(entries []audit.Entry) string {
+	if len(entries) == 0 {
+		return locale.T("diag.audit.empty")
+	}
+	lines := make([]string, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		line := e.Time.Local().Format("2006-01-02 15:04:05") + "  " + string(e.Actor) + "  " + string(e.Action)
+		if e.Target != "" {
+			line += "  " + e.Target
+		}
+		if e.Before != "" || e.After != "" {
+			line += "  " + e.Before + " → " + e.After
+		}
+		if e.Error != "" {
+			line += "  ! " + e.Error
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -311,6 +311,9 @@ func CreateDiagnosticsTab(ac *core.AppController) fyne.CanvasObject {
 		fyne.Do(refreshTrafficBtn)
 	})
 	refreshTrafficBtn()
+	auditLogButton := widget.NewButtonWithIcon(locale.T("diag.audit_log"), theme.HistoryIcon(), func() {
+		OpenAuditLogWindow(ac)
+	})
 	openLogsFolderButton := widget.NewButtonWithIcon(locale.T("diag.open_logs_folder"), theme.FolderOpenIcon(), func() {
 		logsDir := platform.GetLogsDir(ac.FileService.ExecDir)
 		if err := platform.OpenFolder(logsDir); err != nil {
@@ -377,6 +380,7 @@ func CreateDiagnosticsTab(ac *core.AppController) fyne.CanvasObject {
 		cleanRuleSetsButton,
 		killRow,
 		trafficProfilerBtn,
+		auditLogButton,
 		widget.NewLabel(locale.T("diag.ip_check_services")),
 		stunRow,
 		ipServicesRow,
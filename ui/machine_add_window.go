@@ -85,8 +85,12 @@ func OpenAddMachineWindow(ac *core.AppController, onAdded func()) {
 		widget.NewFormItem(locale.T("remote.add.field_addr"), addrEntry),
 		widget.NewFormItem(locale.T("remote.add.field_secret"), secretEntry),
 	)
+	// Маршрут — для машины, которая напрямую не отвечает уже на enroll
+	// (за NAT, за jump-хостом). Сохраняется в записи вместе с сопряжением.
+	routeForm := newMachineRouteForm(services.RemoteRoute{})
 	advanced := widget.NewAccordion(
 		widget.NewAccordionItem(locale.T("remote.add.advanced"), advancedForm),
+		widget.NewAccordionItem(locale.Tf("remote.route.section", locale.T("remote.route.kind_direct")), routeForm.Object()),
 	)
 
 	hint := widget.NewLabel(locale.T("remote.add.hint"))
@@ -108,6 +112,13 @@ func OpenAddMachineWindow(ac *core.AppController, onAdded func()) {
 		}
 	}
 
+	// VPS, куда есть SSH, лаунчер заводит сам — без приглашения
+	// (machine_ssh_bootstrap_window.go).
+	sshBtn := widget.NewButton(locale.T("remote.add.via_ssh"), func() {
+		win.Close()
+		OpenSSHBootstrapWindow(ac, onAdded)
+	})
+
 	status := widget.NewLabel("")
 	status.Wrapping = fyne.TextWrapWord
 
@@ -121,6 +132,11 @@ func OpenAddMachineWindow(ac *core.AppController, onAdded func()) {
 			status.SetText(locale.T("remote.add.error_empty_invite"))
 			return
 		}
+		route := routeForm.Route()
+		if err := services.ValidateRemoteRoute(route); err != nil {
+			status.SetText(locale.Tf("remote.route.error", err))
+			return
+		}
 		addBtn.Disable()
 		status.SetText(locale.T("remote.add.pairing"))
 
@@ -128,10 +144,10 @@ func OpenAddMachineWindow(ac *core.AppController, onAdded func()) {
 		// горутине: недоступная машина отвечает по таймауту.
 		go func() {
 			registry := services.NewRemoteRegistry(ac.FileService.ExecDir)
-			entry, err := registry.PairWithAddr(invite, nameEntry.Text,
-				strings.TrimSpace(addrEntry.Text), strings.TrimSpace(secretEntry.Text))
+			entry, err := registry.PairVia(invite, nameEntry.Text,
+				strings.TrimSpace(addrEntry.Text), strings.TrimSpace(secretEntry.Text), route)
 			if err == nil {
-				// Платформа — отдельным шагом: PairWithAddr её не знает, а
+				// Платформа — отдельным шагом: PairVia её не знает, а
 				// генерация конфига машины берёт GOOS/GOARCH именно отсюда.
 				if setErr := registry.SetPlatform(entry.ID, goosSelect.Selected, goarchSelect.Selected); setErr != nil {
 					debuglog.WarnLog("add machine: set platform for %q: %v", entry.ID, setErr)
@@ -162,7 +178,7 @@ func OpenAddMachineWindow(ac *core.AppController, onAdded func()) {
 	body := container.NewVBox(
 		hint,
 		inviteCmdRow,
-		docsLink,
+		container.NewHBox(docsLink, sshBtn),
 		widget.NewSeparator(),
 		form,
 		advanced,
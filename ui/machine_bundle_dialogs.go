@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+)
+
+// Перенос машин между лаунчерами (services/lxd_remote_bundle.go): экспорт —
+// из окна Fleet по отмеченным машинам, импорт — из шапки списка машин (у
+// получателя парка может ещё не быть).
+//
+// В пакете приватные ключи: пароль вводится дважды, а подсказка прямо
+// говорит, что файл — это доступ к машинам.
+
+// showExportBundleDialog — пароль, затем сохранение файла пакета.
+func showExportBundleDialog(win fyne.Window, registry *services.RemoteRegistry, ids []string) {
+	if len(ids) == 0 {
+		dialog.ShowInformation(locale.T("remote.bundle.export_title"), locale.T("remote.fleet.nothing_selected"), win)
+		return
+	}
+	pass := widget.NewPasswordEntry()
+	again := widget.NewPasswordEntry()
+	hint := widget.NewLabel(locale.Tf("remote.bundle.export_hint", len(ids)))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint, widget.NewForm(
+		widget.NewFormItem(locale.T("remote.bundle.field_passphrase"), pass),
+		widget.NewFormItem(locale.T("remote.bundle.field_repeat"), again),
+	))
+
+	dlg := dialog.NewCustomConfirm(locale.T("remote.bundle.export_title"), locale.T("remote.bundle.export_submit"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			if len(pass.Text) < services.MinBundlePassphrase {
+				dialog.ShowInformation(locale.T("remote.bundle.export_title"),
+					locale.Tf("remote.bundle.passphrase_short", services.MinBundlePassphrase), win)
+				return
+			}
+			if pass.Text != again.Text {
+				dialog.ShowInformation(locale.T("remote.bundle.export_title"), locale.T("remote.bundle.passphrase_mismatch"), win)
+				return
+			}
+			phrase := pass.Text
+			go func() {
+				bundle, err := registry.ExportBundle(ids, phrase)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, win)
+						return
+					}
+					saveBundleFile(win, bundle)
+				})
+			}()
+		}, win)
+	dlg.Resize(fyne.NewSize(460, 0))
+	dlg.Show()
+}
+
+func saveBundleFile(win fyne.Window, bundle []byte) {
+	fd := dialog.NewFileSave(func(uc fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		if uc == nil {
+			return
+		}
+		defer func() { _ = uc.Close() }()
+		if _, err := uc.Write(bundle); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		debuglog.InfoLog("remote bundle: saved to %s", uc.URI().Path())
+	}, win)
+	fd.SetFileName(fmt.Sprintf("singbox-machines-%s.json", time.Now().Format("20060102")))
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	fd.Show()
+}
+
+// showImportBundleDialog — выбор файла, пароль и политика конфликтов.
+func showImportBundleDialog(win fyne.Window, registry *services.RemoteRegistry, onDone func()) {
+	fd := dialog.NewFileOpen(func(rc fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		if rc == nil {
+			return
+		}
+		defer func() { _ = rc.Close() }()
+		raw, err := io.ReadAll(io.LimitReader(rc, 16<<20))
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		askImportBundle(win, registry, raw, onDone)
+	}, win)
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	fd.Show()
+}
+
+func askImportBundle(win fyne.Window, registry *services.RemoteRegistry, raw []byte, onDone func()) {
+	pass := widget.NewPasswordEntry()
+	skipLabel := locale.T("remote.bundle.conflict_skip")
+	replaceLabel := locale.T("remote.bundle.conflict_replace")
+	conflict := widget.NewRadioGroup([]string{skipLabel, replaceLabel}, nil)
+	conflict.SetSelected(skipLabel)
+	hint := widget.NewLabel(locale.T("remote.bundle.import_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint, widget.NewForm(
+		widget.NewFormItem(locale.T("remote.bundle.field_passphrase"), pass),
+		widget.NewFormItem(locale.T("remote.bundle.field_conflict"), conflict),
+	))
+
+	dlg := dialog.NewCustomConfirm(locale.T("remote.bundle.import_title"), locale.T("remote.bundle.import_submit"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			policy := services.BundleConflictSkip
+			if conflict.Selected == replaceLabel {
+				policy = services.BundleConflictReplace
+			}
+			phrase := pass.Text
+			go func() {
+				report, err := registry.ImportBundle(raw, phrase, policy)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, win)
+						return
+					}
+					dialog.ShowInformation(locale.T("remote.bundle.import_title"), bundleReportText(report), win)
+					if onDone != nil {
+						onDone()
+					}
+				})
+			}()
+		}, win)
+	dlg.Resize(fyne.NewSize(460, 0))
+	dlg.Show()
+}
+
+// bundleReportText — итог импорта построчно, в стиле отчёта отзыва.
+func bundleReportText(report services.BundleImportReport) string {
+	var b strings.Builder
+	for _, m := range report.Machines {
+		switch {
+		case m.Err != "":
+			fmt.Fprintf(&b, "✗ %s: %s\n", m.Name, m.Err)
+		case m.Skipped:
+			fmt.Fprintf(&b, "• %s: %s\n", m.Name, locale.Tf("remote.bundle.skipped", m.ConflictID))
+		case m.Replaced:
+			fmt.Fprintf(&b, "✓ %s: %s\n", m.Name, locale.T("remote.bundle.replaced"))
+		default:
+			fmt.Fprintf(&b, "✓ %s: %s\n", m.Name, locale.T("remote.bundle.imported"))
+		}
+		for _, w := range m.Warnings {
+			fmt.Fprintf(&b, "   ⚠ %s\n", w)
+		}
+	}
+	if len(report.ProfilesAdded) > 0 {
+		b.WriteString(locale.Tf("remote.bundle.profiles_added", strings.Join(report.ProfilesAdded, ", ")) + "\n")
+	}
+	if len(report.ProfilesKept) > 0 {
+		b.WriteString(locale.Tf("remote.bundle.profiles_kept", strings.Join(report.ProfilesKept, ", ")) + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,278 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+)
+
+// Сертификаты сопряжения машины (services/lxd_remote_certs.go): срок
+// нашего ключа, серверный пин, ротация и перепиновка — секция окна правки.
+// Отзыв по всему парку — в окне Fleet (showRevokeLauncherDialog): он не
+// про одну машину.
+//
+// Перепиновка никогда не делается сама. Разошедшийся пин — это либо
+// перевыпущенный сертификат демона, либо кто-то посередине, и различить
+// их может только человек, сверив отпечаток на самой машине.
+
+// certDate — дата срока; пусто — «не знаем».
+func certDate(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Local().Format("2006-01-02")
+}
+
+// machineEditCerts — секция сертификатов окна правки.
+func machineEditCerts(win fyne.Window, registry *services.RemoteRegistry,
+	d services.RemoteDaemon, reload func()) fyne.CanvasObject {
+
+	info := widget.NewLabel("")
+	info.Wrapping = fyne.TextWrapWord
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	// Приглашение нужно только демону без /admin/clients; пустое поле —
+	// ротация по каналу.
+	inviteEntry := widget.NewMultiLineEntry()
+	inviteEntry.SetPlaceHolder(locale.T("remote.certs.invite_placeholder"))
+	inviteEntry.Wrapping = fyne.TextWrapBreak
+	inviteEntry.SetMinRowsVisible(2)
+
+	var checkBtn, rotateBtn, repinBtn *widget.Button
+	var presented string
+
+	check := func() {
+		checkBtn.Disable()
+		status.SetText(locale.T("remote.certs.checking"))
+		go func() {
+			st, err := registry.CertStatus(context.Background(), d.ID)
+			fyne.Do(func() {
+				checkBtn.Enable()
+				if err != nil {
+					status.SetText(locale.Tf("remote.certs.error", err))
+					return
+				}
+				status.SetText("")
+				info.SetText(certStatusText(st))
+				presented = ""
+				repinBtn.Hide()
+				if st.ServerMismatch() {
+					presented = st.PresentedServer
+					repinBtn.Show()
+				}
+			})
+		}()
+	}
+	checkBtn = widget.NewButton(locale.T("remote.certs.check"), check)
+
+	rotateBtn = widget.NewButton(locale.T("remote.certs.rotate"), func() {
+		invite := strings.TrimSpace(inviteEntry.Text)
+		ShowConfirm(win, locale.T("remote.certs.rotate_title"),
+			locale.Tf("remote.certs.rotate_body", d.Name), func(ok bool) {
+				if !ok {
+					return
+				}
+				rotateBtn.Disable()
+				status.SetText(locale.T("remote.certs.rotating"))
+				go func() {
+					res, err := registry.RotateClientCert(d.ID, invite)
+					fyne.Do(func() {
+						rotateBtn.Enable()
+						if err != nil {
+							debuglog.WarnLog("edit machine: rotate %q: %v", d.ID, err)
+							status.SetText(locale.Tf("remote.certs.error", err))
+							return
+						}
+						// Открытый канал держит старый ключ — как после re-pair.
+						if id, _, ok := GetLxdRemoteOverride(); ok && id == d.ID {
+							CloseMachineProfiler(d.ID)
+							CloseMachineHostWindow(d.ID)
+						}
+						RestartMachineLogStream(d.ID)
+						inviteEntry.SetText("")
+						msg := locale.Tf("remote.certs.rotated", res.NewFingerprint[:12])
+						if res.RetireHint != "" {
+							msg += "\n" + locale.Tf("remote.certs.retire_hint", res.RetireHint)
+						}
+						status.SetText(msg)
+						reload()
+						check()
+					})
+				}()
+			})
+	})
+
+	repinBtn = widget.NewButton(locale.T("remote.certs.repin"), func() {
+		fp := presented
+		if fp == "" {
+			return
+		}
+		// Подтверждение с ОБОИМИ отпечатками: сверять пользователь будет
+		// с выводом на самой машине, а не с нашим словом.
+		current, _, _ := registry.Get(d.ID)
+		ShowConfirm(win, locale.T("remote.certs.repin_title"),
+			locale.Tf("remote.certs.repin_body", d.Name, current.ServerFingerprint, fp), func(ok bool) {
+				if !ok {
+					return
+				}
+				repinBtn.Disable()
+				go func() {
+					err := registry.RePin(context.Background(), d.ID, fp)
+					fyne.Do(func() {
+						repinBtn.Enable()
+						if err != nil {
+							debuglog.WarnLog("edit machine: re-pin %q: %v", d.ID, err)
+							status.SetText(locale.Tf("remote.certs.error", err))
+							return
+						}
+						debuglog.InfoLog("edit machine: %q re-pinned to %s…", d.ID, fp[:12])
+						RestartMachineLogStream(d.ID)
+						status.SetText(locale.T("remote.certs.repinned"))
+						reload()
+						check()
+					})
+				}()
+			})
+	})
+	repinBtn.Importance = widget.DangerImportance
+	repinBtn.Hide()
+
+	expiry := registry.ClientCertExpiry(d.ID)
+	info.SetText(locale.Tf("remote.certs.client_line", d.EnrolledName(), certDate(expiry)))
+
+	hint := widget.NewLabel(locale.T("remote.certs.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	inner := container.NewVBox(
+		hint,
+		info,
+		widget.NewForm(widget.NewFormItem(locale.T("remote.add.field_invite"), inviteEntry)),
+		status,
+		container.NewBorder(nil, nil, nil, container.NewHBox(checkBtn, repinBtn, rotateBtn)),
+	)
+	soon := !expiry.IsZero() && time.Until(expiry) < services.CertExpiryWarning
+	title := locale.T("remote.certs.section")
+	if soon {
+		title = locale.Tf("remote.certs.section_expiring", certDate(expiry))
+	}
+	acc := widget.NewAccordion(widget.NewAccordionItem(title, inner))
+	// Истекающий ключ — повод открыть секцию сразу.
+	if soon {
+		acc.Open(0)
+	}
+	return acc
+}
+
+// certStatusText — сводка CertStatus для секции.
+func certStatusText(st services.CertStatus) string {
+	now := time.Now()
+	var b strings.Builder
+	b.WriteString(locale.Tf("remote.certs.client_line", st.ClientName, certDate(st.ClientNotAfter)))
+	if st.ClientExpiresSoon(now) {
+		b.WriteString("  ⚠ " + locale.T("remote.certs.expiring"))
+	}
+	b.WriteString("\n")
+	switch {
+	case st.ServerErr != "":
+		b.WriteString(locale.Tf("remote.certs.server_error", st.ServerErr))
+	case st.ServerMismatch():
+		b.WriteString(locale.Tf("remote.certs.server_mismatch", shortFP(st.PinnedServer), shortFP(st.PresentedServer)))
+	case st.PresentedServer != "":
+		b.WriteString(locale.Tf("remote.certs.server_line", shortFP(st.PresentedServer), certDate(st.ServerNotAfter)))
+		if st.ServerExpiresSoon(now) {
+			b.WriteString("  ⚠ " + locale.T("remote.certs.expiring"))
+		}
+	}
+	switch {
+	case st.ClientsUnsupported:
+		b.WriteString("\n" + locale.T("remote.certs.clients_unsupported"))
+	case st.Clients != nil:
+		names := make([]string, 0, len(st.Clients))
+		for _, c := range st.Clients {
+			name := c.Name
+			if c.Fingerprint == st.ClientFingerprint {
+				name += " " + locale.T("remote.certs.self")
+			}
+			names = append(names, name)
+		}
+		b.WriteString("\n" + locale.Tf("remote.certs.clients", strings.Join(names, ", ")))
+	}
+	return b.String()
+}
+
+func shortFP(fp string) string {
+	if len(fp) > 16 {
+		return fp[:16] + "…"
+	}
+	return fp
+}
+
+// showRevokeLauncherDialog — отзыв лаунчера на всех машинах парка.
+//
+// Пустое имя — ЭТОТ лаунчер (ноутбук уходит из обращения): сертификаты
+// снимаются с машин, а локальные ключи стираются. Имя другого устройства —
+// его потеряли: снимаются все его сертификаты, включая прошлые ротации.
+func showRevokeLauncherDialog(win fyne.Window, registry *services.RemoteRegistry, onDone func()) {
+	deviceEntry := widget.NewEntry()
+	deviceEntry.SetPlaceHolder(locale.Tf("remote.revoke.device_placeholder", services.LauncherDeviceName()))
+	hint := widget.NewLabel(locale.T("remote.revoke.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint,
+		widget.NewForm(widget.NewFormItem(locale.T("remote.revoke.field_device"), deviceEntry)))
+
+	dlg := dialog.NewCustomConfirm(locale.T("remote.revoke.title"), locale.T("remote.revoke.submit"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			device := strings.TrimSpace(deviceEntry.Text)
+			body := locale.Tf("remote.revoke.confirm_other", device)
+			if device == "" {
+				body = locale.T("remote.revoke.confirm_self")
+			}
+			ShowConfirm(win, locale.T("remote.revoke.title"), body, func(ok bool) {
+				if !ok {
+					return
+				}
+				go func() {
+					results, err := registry.RevokeLauncher(device)
+					fyne.Do(func() {
+						if err != nil {
+							dialog.ShowError(err, win)
+							return
+						}
+						var b strings.Builder
+						for _, res := range results {
+							switch {
+							case res.Err != "":
+								fmt.Fprintf(&b, "✗ %s: %s\n", res.Name, res.Err)
+							case res.Hint != "":
+								fmt.Fprintf(&b, "⚠ %s: %s\n", res.Name, locale.Tf("remote.revoke.run_on_machine", res.Hint))
+							case len(res.Removed) == 0:
+								fmt.Fprintf(&b, "• %s: %s\n", res.Name, locale.T("remote.revoke.nothing"))
+							default:
+								fmt.Fprintf(&b, "✓ %s: %s\n", res.Name, strings.Join(res.Removed, ", "))
+							}
+						}
+						debuglog.InfoLog("fleet: revoke %q done on %d machines", device, len(results))
+						dialog.ShowInformation(locale.T("remote.revoke.title"), strings.TrimRight(b.String(), "\n"), win)
+						if onDone != nil {
+							onDone()
+						}
+					})
+				}()
+			})
+		}, win)
+	dlg.Resize(fyne.NewSize(480, 0))
+	dlg.Show()
+}
@@ -1,8 +1,11 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"fyne.io/fyne/v2"
@@ -31,8 +34,13 @@ import (
 //
 //	Паспорт   — «как эту машину зовут и куда стучаться».
 //	Re-pair   — «канал сломался; как починить, не потеряв настройки».
+//	Route     — «напрямую машина не отвечает; через что до неё дойти».
+//	Certs     — «ключу пора на смену, или сервер показывает не тот
+//	             сертификат».
 //	Copy from — «настройки уже собраны на соседней машине; как не делать
 //	             это второй раз руками».
+//	Base      — «и чтобы правка на одной доезжала до остальных».
+//	Watch     — «и чтобы было видно, если на машине оказалось не то».
 
 // OpenEditMachineWindow открывает окно правки машины.
 // onChanged зовётся после любой применённой правки (перечитать список).
@@ -56,7 +64,15 @@ func OpenEditMachineWindow(ac *core.AppController, registry *services.RemoteRegi
 		widget.NewSeparator(),
 		machineEditRePair(win, registry, d, reload),
 		widget.NewSeparator(),
+		machineEditRoute(win, registry, d, reload),
+		widget.NewSeparator(),
+		machineEditCerts(win, registry, d, reload),
+		widget.NewSeparator(),
 		machineEditCopyProfile(ac, win, registry, d, reload),
+		widget.NewSeparator(),
+		machineEditBaseProfile(ac, win, registry, d, reload),
+		widget.NewSeparator(),
+		machineEditDeployWatch(ac, registry, d, reload),
 	)
 	win.SetContent(container.NewPadded(components.WrapInScrollWithGutter(body)))
 	win.Resize(fyne.NewSize(560, 640))
@@ -187,6 +203,7 @@ func machineEditRePair(win fyne.Window, registry *services.RemoteRegistry,
 							CloseMachineProfiler(d.ID)
 							CloseMachineHostWindow(d.ID)
 						}
+						RestartMachineLogStream(d.ID)
 						debuglog.InfoLog("edit machine: re-paired %q at %s", entry.Name, entry.Addr)
 						status.SetText(locale.Tf("remote.repair.done", entry.Addr))
 						inviteEntry.SetText("")
@@ -310,6 +327,327 @@ func machineEditCopyProfile(ac *core.AppController, win fyne.Window,
 	)
 }
 
+// machineEditBaseProfile — наследование базового профиля
+// (services/lxd_remote_profiles.go).
+//
+// В отличие от Copy from, связь постоянная: машина хранит только свои
+// отличия, остальное следует за базой. Отсюда же базу и правят — публикуя
+// настроенную машину: отдельного визарда для профиля нет, и заводить его
+// значило бы второй редактор тех же настроек.
+func machineEditBaseProfile(ac *core.AppController, win fyne.Window,
+	registry *services.RemoteRegistry, d services.RemoteDaemon, reload func()) fyne.CanvasObject {
+
+	hint := widget.NewLabel(locale.T("remote.base.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	state := widget.NewLabel("")
+	state.Wrapping = fyne.TextWrapWord
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	profileSelect := widget.NewSelect(nil, nil)
+	profileSelect.PlaceHolder = locale.T("remote.base.placeholder")
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder(locale.T("remote.base.name_placeholder"))
+
+	var (
+		inheritBtn, detachBtn, publishBtn *widget.Button
+		profiles                          []services.BaseProfileInfo
+	)
+	current := d.BaseProfile
+
+	// refresh перечитывает профили и положение машины: после любого
+	// действия строка статуса должна говорить правду, а не то, что было при
+	// открытии окна.
+	refresh := func() {
+		var err error
+		if profiles, err = registry.ListBaseProfiles(); err != nil {
+			debuglog.WarnLog("edit machine: list base profiles: %v", err)
+		}
+		names := make([]string, 0, len(profiles))
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		profileSelect.Options = names
+		profileSelect.Refresh()
+
+		st, err := registry.BaseProfileStatus(d.ID)
+		current = st.Name
+		switch {
+		case err != nil:
+			state.SetText(locale.Tf("remote.base.error", err))
+		case st.Name == "":
+			state.SetText(locale.T("remote.base.status_none"))
+		case st.RebuildNeeded:
+			state.SetText(locale.Tf("remote.base.status_inherits", st.Name, st.Overlay.Count()) +
+				"\n" + locale.T("remote.base.status_stale"))
+		default:
+			state.SetText(locale.Tf("remote.base.status_inherits", st.Name, st.Overlay.Count()))
+		}
+		if current != "" {
+			profileSelect.SetSelected(current)
+			if nameEntry.Text == "" {
+				nameEntry.SetText(current)
+			}
+			detachBtn.Enable()
+		} else {
+			detachBtn.Disable()
+		}
+		if len(names) == 0 {
+			profileSelect.Disable()
+			inheritBtn.Disable()
+			status.SetText(locale.T("remote.base.no_profiles"))
+		} else {
+			profileSelect.Enable()
+			inheritBtn.Enable()
+		}
+		if machineHasProfile(ac, d.ID) {
+			publishBtn.Enable()
+		} else {
+			publishBtn.Disable()
+		}
+	}
+
+	report := func(err error, okText string) {
+		refresh()
+		if err != nil {
+			debuglog.WarnLog("edit machine: base profile of %q: %v", d.ID, err)
+			status.SetText(locale.Tf("remote.base.error", err))
+			return
+		}
+		status.SetText(okText)
+		reload()
+	}
+
+	inheritBtn = widget.NewButton(locale.T("remote.base.inherit"), func() {
+		name := profileSelect.Selected
+		if name == "" {
+			status.SetText(locale.T("remote.base.error_no_profile"))
+			return
+		}
+		report(registry.InheritBaseProfile(d.ID, name), locale.Tf("remote.base.inherited", name))
+	})
+	detachBtn = widget.NewButton(locale.T("remote.base.detach"), func() {
+		report(registry.DetachBaseProfile(d.ID), locale.T("remote.base.detached"))
+	})
+	publishBtn = widget.NewButton(locale.T("remote.base.publish"), func() {
+		name := strings.TrimSpace(nameEntry.Text)
+		if err := services.ValidateBaseProfileName(name); err != nil {
+			status.SetText(locale.Tf("remote.base.error", err))
+			return
+		}
+		publish := func() {
+			report(registry.SaveBaseProfileFrom(name, d.ID), locale.Tf("remote.base.published", name))
+		}
+		// Перезапись базы, которую наследуют другие машины, меняет и их —
+		// спрашиваем. Новый профиль или профиль без наследников — не о чем.
+		for _, p := range profiles {
+			if p.Name == name && len(p.Machines) > 0 {
+				dialog.ShowConfirm(locale.T("remote.base.confirm_publish_title"),
+					locale.Tf("remote.base.confirm_publish_body", name, len(p.Machines)),
+					func(ok bool) {
+						if ok {
+							publish()
+						}
+					}, win)
+				return
+			}
+		}
+		publish()
+	})
+	refresh()
+
+	inner := container.NewVBox(
+		hint,
+		state,
+		container.NewBorder(nil, nil, nil, container.NewHBox(inheritBtn, detachBtn), profileSelect),
+		container.NewBorder(nil, nil, nil, publishBtn, nameEntry),
+		status,
+	)
+	return widget.NewAccordion(
+		widget.NewAccordionItem(locale.T("remote.base.section"), inner),
+	)
+}
+
+// driftCheckEveryOptions — периоды сверки в селекте; произвольный период,
+// заданный через Debug API, добавляется к ним при открытии окна.
+var driftCheckEveryOptions = []string{"5m", "15m", "1h", "6h", "24h"}
+
+// machineEditRoute — путь до управляющего канала (services.RemoteRoute).
+//
+// Check проверяет путь до демона, не сохраняя: прокси, который не пускает,
+// лучше увидеть до того, как машина в списке станет недоступной. Незнакомый
+// jump-хост подтверждается так же, как в окне SSH bootstrap, — отпечаток
+// уходит в пин.
+func machineEditRoute(win fyne.Window, registry *services.RemoteRegistry,
+	d services.RemoteDaemon, reload func()) fyne.CanvasObject {
+
+	routeForm := newMachineRouteForm(d.Route())
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	var checkBtn *widget.Button
+	var check func()
+	check = func() {
+		rt := routeForm.Route()
+		if err := services.ValidateRemoteRoute(rt); err != nil {
+			status.SetText(locale.Tf("remote.route.error", err))
+			return
+		}
+		checkBtn.Disable()
+		status.SetText(locale.T("remote.route.checking"))
+		go func() {
+			err := registry.ProbeRoute(context.Background(), rt, d.Addr)
+			fyne.Do(func() {
+				checkBtn.Enable()
+				var hkErr *services.SSHHostKeyError
+				switch {
+				case errors.As(err, &hkErr) && !hkErr.Mismatch:
+					status.SetText("")
+					ShowConfirm(win, locale.T("remote.ssh.trust_title"),
+						locale.Tf("remote.ssh.trust_body", hkErr.Host, hkErr.Fingerprint), func(ok bool) {
+							if ok {
+								routeForm.sshPin.SetText(hkErr.Fingerprint)
+								check()
+							}
+						})
+				case err != nil:
+					status.SetText(locale.Tf("remote.route.error", err))
+				default:
+					status.SetText(locale.Tf("remote.route.check_ok", rt.Describe()))
+				}
+			})
+		}()
+	}
+	checkBtn = widget.NewButton(locale.T("remote.route.check"), check)
+
+	saveBtn := widget.NewButton(locale.T("dialog.button_save"), func() {
+		rt := routeForm.Route()
+		if err := registry.SetRoute(d.ID, rt); err != nil {
+			debuglog.WarnLog("edit machine: route of %q: %v", d.ID, err)
+			status.SetText(locale.Tf("remote.route.error", err))
+			return
+		}
+		debuglog.InfoLog("edit machine: %q now reached via %s", d.ID, rt.Describe())
+		status.SetText(locale.Tf("remote.route.saved", rt.Describe()))
+		reload()
+	})
+
+	inner := container.NewVBox(
+		routeForm.Object(),
+		status,
+		container.NewBorder(nil, nil, nil, container.NewHBox(checkBtn, saveBtn)),
+	)
+	item := widget.NewAccordionItem(locale.Tf("remote.route.section", d.Route().Describe()), inner)
+	acc := widget.NewAccordion(item)
+	// Заданный маршрут — не штатный случай, его видно сразу.
+	if !d.Route().IsDirect() {
+		acc.Open(0)
+	}
+	return acc
+}
+
+// machineEditDeployWatch — политика наблюдения за машиной после Deploy
+// (services/lxd_remote_drift.go): период фоновой сверки и повторный деплой.
+//
+// Политика сохраняется сразу при изменении любого поля: отдельная кнопка
+// Save здесь — лишний шаг, который легко забыть, закрыв окно.
+func machineEditDeployWatch(ac *core.AppController, registry *services.RemoteRegistry,
+	d services.RemoteDaemon, reload func()) fyne.CanvasObject {
+
+	hint := widget.NewLabel(locale.T("remote.drift.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	pol := d.Policy()
+	off := locale.T("remote.drift.every_off")
+	options := append([]string{off}, driftCheckEveryOptions...)
+	if pol.CheckEvery != "" && !slices.Contains(options, pol.CheckEvery) {
+		options = append(options, pol.CheckEvery)
+	}
+
+	// loading — поля расставляются из записи реестра; их OnChanged в это
+	// время не должны сохранять то же самое обратно.
+	loading := true
+	everySelect := widget.NewSelect(options, nil)
+	autoCheck := widget.NewCheck(locale.T("remote.drift.auto_redeploy"), nil)
+	refreshCheck := widget.NewCheck(locale.T("remote.drift.on_refresh"), nil)
+
+	save := func() {
+		if loading {
+			return
+		}
+		next := services.DeployPolicy{
+			AutoRedeploy:      autoCheck.Checked,
+			RedeployOnRefresh: refreshCheck.Checked,
+		}
+		if everySelect.Selected != off {
+			next.CheckEvery = everySelect.Selected
+		}
+		if err := registry.SetDeployPolicy(d.ID, next); err != nil {
+			debuglog.WarnLog("edit machine: deploy policy of %q: %v", d.ID, err)
+			status.SetText(locale.Tf("remote.drift.error", err))
+			return
+		}
+		status.SetText(locale.T("remote.drift.saved"))
+		reload()
+	}
+	everySelect.OnChanged = func(string) { save() }
+	autoCheck.OnChanged = func(bool) { save() }
+	refreshCheck.OnChanged = func(bool) { save() }
+
+	if pol.CheckEvery != "" {
+		everySelect.SetSelected(pol.CheckEvery)
+	} else {
+		everySelect.SetSelected(off)
+	}
+	autoCheck.SetChecked(pol.AutoRedeploy)
+	refreshCheck.SetChecked(pol.RedeployOnRefresh)
+	loading = false
+
+	if ac.RemoteDrift != nil {
+		if rep, ok := ac.RemoteDrift.Report(d.ID); ok {
+			status.SetText(driftSummary(rep))
+		}
+	}
+
+	// Check now сверяет без повторного деплоя: ручная проверка отвечает на
+	// вопрос «что там», а деплой — это кнопка Deploy в строке машины.
+	var checkBtn *widget.Button
+	checkBtn = widget.NewButton(locale.T("remote.drift.check_now"), func() {
+		if ac.RemoteDrift == nil {
+			return
+		}
+		checkBtn.Disable()
+		status.SetText(locale.T("remote.drift.checking"))
+		go func() {
+			rep, err := ac.RemoteDrift.Reconcile(d.ID, false)
+			fyne.Do(func() {
+				checkBtn.Enable()
+				if err != nil {
+					status.SetText(locale.Tf("remote.drift.error", err))
+					return
+				}
+				status.SetText(driftSummary(rep))
+			})
+		}()
+	})
+	if ac.RemoteDrift == nil {
+		checkBtn.Disable()
+	}
+
+	inner := container.NewVBox(
+		hint,
+		container.NewBorder(nil, nil, widget.NewLabel(locale.T("remote.drift.check_every")), checkBtn, everySelect),
+		autoCheck,
+		refreshCheck,
+		status,
+	)
+	return widget.NewAccordion(
+		widget.NewAccordionItem(locale.T("remote.drift.section"), inner),
+	)
+}
+
 // machineHasProfile — есть ли у машины сохранённое состояние визарда.
 //
 // Проверяется файл, а не «подключались ли когда-нибудь»: копировать можно
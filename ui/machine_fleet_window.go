@@ -0,0 +1,294 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	fynetooltip "github.com/dweymouth/fyne-tooltip"
+	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/ui/components"
+)
+
+// Окно парка: одна операция (или цепочка) над многими машинами разом.
+//
+// Строка списка управляет ОДНОЙ машиной. У кого дюжина роутеров, тот после
+// правки профиля жмёт Deploy двенадцать раз и глазами ищет, где отвалилось.
+// Здесь выбираются машины и шаги, прогон идёт параллельно с ограничением
+// (services.RunFleet), а результат — матрица «машина × шаг», заполняемая по
+// мере готовности.
+
+// fleetConcurrencyChoices — варианты параллельности. 1 — канареечная
+// выкатка: с «остановиться на первом сбое» вторая машина не тронется, пока
+// первая не прошла.
+var fleetConcurrencyChoices = []string{"1", "2", "4", "8", "16"}
+
+// OpenFleetWindow открывает окно прогона по парку. onDone зовётся после
+// прогона — список машин перечитывает состояние.
+func OpenFleetWindow(ac *core.AppController, onDone func()) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil {
+		return
+	}
+	registry := services.NewRemoteRegistry(ac.FileService.ExecDir)
+	machines, err := registry.List()
+	if err != nil {
+		dialog.ShowError(err, ac.UIService.MainWindow)
+		return
+	}
+	if len(machines) == 0 {
+		dialog.ShowInformation(locale.T("remote.fleet.title"), locale.T("remote.machines.empty"), ac.UIService.MainWindow)
+		return
+	}
+	win := ac.UIService.Application.NewWindow(locale.T("remote.fleet.title"))
+
+	// Машины: по умолчанию отмечены все — «на весь парк» самый частый случай.
+	machineChecks := make([]*widget.Check, len(machines))
+	machineBox := container.NewVBox()
+	for i, d := range machines {
+		machineChecks[i] = widget.NewCheck(fmt.Sprintf("%s  (%s)", d.Name, d.Addr), nil)
+		machineChecks[i].SetChecked(true)
+		machineBox.Add(machineChecks[i])
+	}
+	allCheck := widget.NewCheck(locale.T("remote.fleet.select_all"), func(on bool) {
+		for _, c := range machineChecks {
+			c.SetChecked(on)
+		}
+	})
+	allCheck.SetChecked(true)
+
+	// Шаги: чекбоксы без порядка, выполняются в каноническом (SortFleetOps).
+	opChecks := make(map[services.FleetOp]*widget.Check, len(services.FleetOps))
+	opBox := container.NewVBox()
+	for _, op := range services.FleetOps {
+		c := widget.NewCheck(fleetOpLabel(op), nil)
+		opChecks[op] = c
+		opBox.Add(c)
+	}
+	opChecks[services.FleetOpDeploy].SetChecked(true)
+
+	concurrency := widget.NewSelect(fleetConcurrencyChoices, nil)
+	concurrency.SetSelected(strconv.Itoa(services.FleetDefaultConcurrency))
+	stopOnFailure := widget.NewCheck(locale.T("remote.fleet.stop_on_failure"), nil)
+
+	matrix := container.NewVBox()
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+
+	var (
+		runBtn *widget.Button
+		cancel context.CancelFunc
+	)
+	closeBtn := widget.NewButton(locale.T("dialog.close"), func() { win.Close() })
+	win.SetOnClosed(func() {
+		// Закрытие окна не обрывает начатые машины — только не даёт
+		// начаться остальным (тот же принцип, что у stop-on-failure).
+		if cancel != nil {
+			cancel()
+		}
+	})
+
+	start := func(ids []string, ops []services.FleetOp, workers int) {
+		runBtn.Disable()
+		cells := renderFleetMatrix(matrix, machines, ids, ops)
+		summary.SetText(locale.T("remote.fleet.running"))
+		ctx, c := context.WithCancel(audit.WithActor(context.Background(), audit.ActorUI))
+		cancel = c
+		stop := stopOnFailure.Checked
+		go func() {
+			results, err := registry.RunFleet(ctx, services.FleetRequest{
+				IDs:           ids,
+				Ops:           ops,
+				Concurrency:   workers,
+				StopOnFailure: stop,
+			}, ac.RefreshRemoteSubscriptions, func(res services.FleetMachineResult) {
+				fyne.Do(func() { fillFleetRow(cells[res.ID], res) })
+			})
+			fyne.Do(func() {
+				runBtn.Enable()
+				if err != nil {
+					debuglog.WarnLog("fleet: %v", err)
+					summary.SetText("")
+					dialog.ShowError(err, win)
+					return
+				}
+				var ok, failed, skipped int
+				for _, res := range results {
+					switch {
+					case res.OK:
+						ok++
+					case res.Started():
+						failed++
+					default:
+						skipped++
+					}
+				}
+				summary.SetText(locale.Tf("remote.fleet.summary", ok, failed, skipped))
+				if onDone != nil {
+					onDone()
+				}
+			})
+		}()
+	}
+
+	runBtn = widget.NewButton(locale.T("remote.fleet.run"), func() {
+		var ids []string
+		for i, c := range machineChecks {
+			if c.Checked {
+				ids = append(ids, machines[i].ID)
+			}
+		}
+		var ops []services.FleetOp
+		for _, op := range services.FleetOps {
+			if opChecks[op].Checked {
+				ops = append(ops, op)
+			}
+		}
+		if len(ids) == 0 || len(ops) == 0 {
+			dialog.ShowInformation(locale.T("remote.fleet.title"), locale.T("remote.fleet.nothing_selected"), win)
+			return
+		}
+		ops = services.SortFleetOps(ops)
+		workers, _ := strconv.Atoi(concurrency.Selected)
+		names := make([]string, len(ops))
+		for i, op := range ops {
+			names[i] = fleetOpLabel(op)
+		}
+		// Подтверждение обязательно: restart, rollback и deploy рвут VPN у
+		// всех, кто ходит через эти машины, — и здесь это сразу много машин.
+		dialog.ShowConfirm(locale.T("remote.fleet.confirm_title"),
+			locale.Tf("remote.fleet.confirm_body", strings.Join(names, " → "), len(ids), workers),
+			func(ok bool) {
+				if ok {
+					start(ids, ops, workers)
+				}
+			}, win)
+	})
+	runBtn.Importance = widget.HighImportance
+
+	// Отзыв — не шаг прогона: он не про выбранные машины, а про лаунчер, и
+	// снимается со ВСЕГО парка.
+	revokeBtn := widget.NewButton(locale.T("remote.revoke.open"), func() {
+		showRevokeLauncherDialog(win, registry, onDone)
+	})
+
+	// Экспорт — по отмеченным машинам: тот же выбор, что у прогона.
+	exportBtn := widget.NewButton(locale.T("remote.bundle.export_open"), func() {
+		var ids []string
+		for i, c := range machineChecks {
+			if c.Checked {
+				ids = append(ids, machines[i].ID)
+			}
+		}
+		showExportBundleDialog(win, registry, ids)
+	})
+
+	options := container.NewVBox(
+		widget.NewLabelWithStyle(locale.T("remote.fleet.machines"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		allCheck,
+		machineBox,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle(locale.T("remote.fleet.ops"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		opBox,
+		widget.NewSeparator(),
+		container.NewHBox(widget.NewLabel(locale.T("remote.fleet.concurrency")), concurrency),
+		stopOnFailure,
+	)
+	footer := container.NewBorder(nil, nil, container.NewHBox(revokeBtn, exportBtn), container.NewHBox(runBtn, closeBtn), summary)
+	split := container.NewHSplit(
+		components.WrapInScrollWithGutter(options),
+		components.WrapInScrollWithGutter(matrix),
+	)
+	split.SetOffset(0.35)
+	body := container.NewBorder(nil, container.NewVBox(widget.NewSeparator(), footer), nil, nil, split)
+
+	win.SetContent(fynetooltip.AddWindowToolTipLayer(container.NewPadded(body), win.Canvas()))
+	win.Resize(fyne.NewSize(900, 520))
+	win.CenterOnScreen()
+	win.Show()
+}
+
+// fleetOpLabel — человеческое имя операции.
+func fleetOpLabel(op services.FleetOp) string {
+	switch op {
+	case services.FleetOpRefresh:
+		return locale.T("remote.fleet.op_refresh")
+	case services.FleetOpSyncResources:
+		return locale.T("remote.fleet.op_sync")
+	case services.FleetOpDeploy:
+		return locale.T("remote.fleet.op_deploy")
+	case services.FleetOpRestart:
+		return locale.T("remote.fleet.op_restart")
+	case services.FleetOpRollback:
+		return locale.T("remote.fleet.op_rollback")
+	}
+	return string(op)
+}
+
+// renderFleetMatrix рисует пустую матрицу прогона (все клетки «ждёт») и
+// возвращает клетки по ID машины — их заполняет fillFleetRow.
+func renderFleetMatrix(matrix *fyne.Container, machines []services.RemoteDaemon, ids []string, ops []services.FleetOp) map[string][]*ttwidget.Label {
+	names := make(map[string]string, len(machines))
+	for _, d := range machines {
+		names[d.ID] = d.Name
+	}
+	matrix.RemoveAll()
+	head := container.NewGridWithColumns(len(ops) + 1)
+	head.Add(widget.NewLabelWithStyle(locale.T("remote.fleet.machine"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	for _, op := range ops {
+		head.Add(widget.NewLabelWithStyle(fleetOpLabel(op), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	}
+	matrix.Add(head)
+	matrix.Add(widget.NewSeparator())
+
+	cells := make(map[string][]*ttwidget.Label, len(ids))
+	for _, id := range ids {
+		row := container.NewGridWithColumns(len(ops) + 1)
+		row.Add(widget.NewLabel(names[id]))
+		for range ops {
+			cell := ttwidget.NewLabel(locale.T("remote.fleet.cell_pending"))
+			cells[id] = append(cells[id], cell)
+			row.Add(cell)
+		}
+		matrix.Add(row)
+	}
+	matrix.Refresh()
+	return cells
+}
+
+// fillFleetRow заполняет строку матрицы результатом машины. Подробности
+// (текст ошибки, хеш конфига) — в подсказке клетки: в сетке им тесно.
+func fillFleetRow(cells []*ttwidget.Label, res services.FleetMachineResult) {
+	for i, step := range res.Steps {
+		if i >= len(cells) {
+			return
+		}
+		var text, tip string
+		switch step.Status {
+		case services.FleetStepOK:
+			text = locale.T("remote.fleet.cell_ok")
+			tip = step.Detail
+		case services.FleetStepFailed:
+			text = locale.T("remote.fleet.cell_failed")
+			tip = step.Error
+		default:
+			text = locale.T("remote.fleet.cell_skipped")
+			tip = step.Detail
+		}
+		if step.Duration > 0 {
+			text = fmt.Sprintf("%s  %.1fs", text, step.Duration.Seconds())
+		}
+		cells[i].SetText(text)
+		cells[i].SetToolTip(tip)
+	}
+}
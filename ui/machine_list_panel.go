@@ -16,6 +16,8 @@ import (
 	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
 
 	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/events"
 	"singbox-launcher/core/services"
 	"singbox-launcher/internal/debuglog"
 	"singbox-launcher/internal/locale"
@@ -114,10 +116,21 @@ func CreateMachineListPanel(ac *core.AppController, proxies *ProxyListPanel) fyn
 	})
 	addBtn.Importance = widget.MediumImportance
 
+	// Парк: операции над многими машинами разом (deploy, restart, откат…).
+	fleetBtn := widget.NewButton(locale.T("remote.fleet.button"), func() {
+		OpenFleetWindow(ac, p.Reload)
+	})
+
+	// Импорт пакета машин из другого лаунчера — здесь, а не в окне Fleet:
+	// у получателя парка может ещё не быть.
+	importBtn := widget.NewButton(locale.T("remote.bundle.import_open"), func() {
+		showImportBundleDialog(ac.UIService.MainWindow, p.registry, p.Reload)
+	})
+
 	header := container.NewBorder(nil, nil,
 		widget.NewLabelWithStyle(locale.T("remote.machines.title"), fyne.TextAlignLeading,
 			fyne.TextStyle{Bold: true}),
-		addBtn,
+		container.NewHBox(importBtn, fleetBtn, addBtn),
 	)
 
 	scroll := container.NewVScroll(p.list)
@@ -138,6 +151,13 @@ func CreateMachineListPanel(ac *core.AppController, proxies *ProxyListPanel) fyn
 	OnOverrideChanged(func() {
 		fyne.Do(p.redrawRows)
 	})
+	// Итог фоновой сверки приходит без участия пользователя — строка машины
+	// должна показать его сразу, а не при следующем клике.
+	if ac.EventBus != nil {
+		ac.EventBus.Subscribe(events.RemoteDriftChecked, func(_ events.Event) {
+			fyne.Do(p.redrawRows)
+		})
+	}
 	return p.container
 }
 
@@ -204,7 +224,18 @@ func (p *machineListPanel) buildRow(d services.RemoteDaemon, active bool) fyne.C
 		fyne.TextStyle{Bold: active})
 
 	tgt := d.Target()
-	meta := widget.NewLabel(fmt.Sprintf("%s/%s   %s", tgt.GOOS, tgt.GOARCH, d.Addr))
+	metaText := fmt.Sprintf("%s/%s   %s", tgt.GOOS, tgt.GOARCH, d.Addr)
+	// Наследование профиля видно прямо в строке: иначе непонятно, почему
+	// правка на соседней машине вдруг доехала и до этой.
+	if d.BaseProfile != "" {
+		metaText += "   " + locale.Tf("remote.machines.meta_base", d.BaseProfile)
+	}
+	// Своя версия ядра (SSH-bootstrap) — тоже в строке: на ней отличия в
+	// поддерживаемых outbound'ах между машинами и объясняются.
+	if d.CoreVersion != "" {
+		metaText += "   " + locale.Tf("remote.machines.meta_core", d.CoreVersion)
+	}
+	meta := widget.NewLabel(metaText)
 
 	// Connect/Disconnect — напротив АДРЕСА: кнопка про канал именно к нему,
 	// а не про ядро на той стороне (тем занимается Start/Stop у статуса).
@@ -249,6 +280,9 @@ func (p *machineListPanel) buildRow(d services.RemoteDaemon, active bool) fyne.C
 		container.NewHBox(editBtn, removeBtn), name)
 
 	rows := []fyne.CanvasObject{nameRow, metaRow}
+	if drift := p.driftLine(d); drift != nil {
+		rows = append(rows, drift)
+	}
 
 	if !connected {
 		// До Connect строка показывает только паспорт машины. Ни статуса, ни
@@ -356,6 +390,11 @@ func (p *machineListPanel) buildRow(d services.RemoteDaemon, active bool) fyne.C
 	hostBtn := widget.NewButton(locale.T("remote.more.host"), func() {
 		OpenMachineHostWindow(p.ac, d)
 	})
+	// Логи — третьим: они отвечают на «что случилось», когда профайлер и
+	// телеметрия показывают только «что сейчас».
+	logsBtn := widget.NewButton(locale.T("remote.more.logs"), func() {
+		OpenMachineLogWindow(p.ac, d)
+	})
 	// Своя кнопка вместо Accordion: тот в горизонтальном ряду растягивает
 	// свою шторку на всю ширину и уводит содержимое вбок — заголовок и
 	// раскрытый блок оказываются в одной строке. Здесь стрелка остаётся
@@ -386,7 +425,7 @@ func (p *machineListPanel) buildRow(d services.RemoteDaemon, active bool) fyne.C
 		// Раскрытие с анимацией высоты: мгновенный скачок содержимого не
 		// показывает, ЧТО раскрылось, — глаз теряет связь между стрелкой и
 		// появившимся блоком.
-		rows = append(rows, newRevealBox(container.NewHBox(profilerBtn, hostBtn)))
+		rows = append(rows, newRevealBox(container.NewHBox(profilerBtn, hostBtn, logsBtn)))
 	}
 	rows = append(rows, widget.NewSeparator())
 	return container.NewVBox(rows...)
@@ -494,6 +533,7 @@ func (p *machineListPanel) showHealthDetails(d services.RemoteDaemon, h services
 	rows := [][2]string{
 		{locale.T("remote.info.machine"), d.Name},
 		{locale.T("remote.info.addr"), d.Addr},
+		{locale.T("remote.info.route"), h.Route},
 		{locale.T("remote.info.platform"), fmt.Sprintf("%s/%s", d.Target().GOOS, d.Target().GOARCH)},
 		{locale.T("remote.info.daemon_version"), h.Version},
 		{locale.T("remote.info.core_status"), h.CoreStatus},
@@ -501,6 +541,15 @@ func (p *machineListPanel) showHealthDetails(d services.RemoteDaemon, h services
 		{locale.T("remote.info.active_sha"), h.ActiveSHA},
 		{locale.T("remote.info.last_good_sha"), h.LastGoodSHA},
 	}
+	// Срок клиентского ключа — с диска, без сети: виден и у недоступной
+	// машины, которой, может быть, как раз из-за него и нет.
+	if exp := p.registry.ClientCertExpiry(d.ID); !exp.IsZero() {
+		value := certDate(exp)
+		if time.Until(exp) < services.CertExpiryWarning {
+			value = locale.Tf("remote.info.client_cert_expiring", value)
+		}
+		rows = append(rows, [2]string{locale.T("remote.info.client_cert"), value})
+	}
 	if h.InterruptedApply {
 		rows = append(rows, [2]string{locale.T("remote.info.interrupted"), locale.T("remote.info.interrupted_yes")})
 	}
@@ -681,9 +730,10 @@ func (p *machineListPanel) removeMachine(d services.RemoteDaemon) {
 			if !ok {
 				return
 			}
-			// Машины не станет — её профайлер и телеметрия тоже должны уйти.
+			// Машины не станет — её профайлер, телеметрия и логи тоже должны уйти.
 			CloseMachineProfiler(d.ID)
 			CloseMachineHostWindow(d.ID)
+			CloseMachineLogWindow(d.ID)
 			activeID, _, _ := GetLxdRemoteOverride()
 			if activeID == d.ID {
 				// Снимаем выбор до удаления: иначе левая колонка осталась бы
@@ -768,10 +818,7 @@ func (p *machineListPanel) restartCore(d services.RemoteDaemon) {
 				return
 			}
 			go func() {
-				err := p.registry.StopCore(d.ID)
-				if err == nil {
-					err = p.registry.StartCore(d.ID)
-				}
+				err := p.registry.RestartCore(d.ID)
 				// Как в togglePower: состояние перечитываем, а не домысливаем —
 				// ядро могло не подняться.
 				h := p.registry.Health(d.ID)
@@ -810,6 +857,14 @@ func (p *machineListPanel) deployTo(d services.RemoteDaemon) {
 			locale.Tf("remote.machines.deploy_missing", d.Name), p.ac.UIService.MainWindow)
 		return
 	}
+	// Тот же страж, что у Deploy(id, nil): сюда конфиг приходит байтами, и
+	// services его базу не проверяет — иначе кнопка отправила бы конфиг,
+	// собранный до правки базового профиля.
+	if st, err := p.registry.BaseProfileStatus(d.ID); err == nil && st.RebuildNeeded {
+		dialog.ShowInformation(locale.T("servers.power.deploy_title"),
+			locale.Tf("remote.machines.deploy_profile_stale", d.Name, st.Name), p.ac.UIService.MainWindow)
+		return
+	}
 	dialog.ShowConfirm(
 		locale.T("servers.power.deploy_title"),
 		locale.Tf("servers.power.deploy_body", d.Name, len(config)),
@@ -821,7 +876,7 @@ func (p *machineListPanel) deployTo(d services.RemoteDaemon) {
 				// Вся цепочка (ресурсы строго раньше конфига) — в
 				// services.Deploy: её же зовёт Debug API, поэтому «через API
 				// деплоится не так, как кнопкой» невозможно (SPEC 100).
-				_, deployErr := p.registry.Deploy(d.ID, config)
+				_, deployErr := p.registry.DeployBy(audit.ActorUI, d.ID, config)
 				fyne.Do(func() {
 					if deployErr != nil {
 						debuglog.WarnLog("machine list: deploy %q: %v", d.ID, deployErr)
@@ -836,6 +891,54 @@ func (p *machineListPanel) deployTo(d services.RemoteDaemon) {
 		}, p.ac.UIService.MainWindow)
 }
 
+// driftLine — итог последней сверки машины с задеплоенным на неё конфигом
+// (services.DriftReconciler); nil, если сверки не было или всё совпало.
+//
+// Видна и без Connect: сверку пользователь включил сам в окне машины, и её
+// результат — ровно то, ради чего включал.
+func (p *machineListPanel) driftLine(d services.RemoteDaemon) fyne.CanvasObject {
+	if p.ac.RemoteDrift == nil {
+		return nil
+	}
+	rep, ok := p.ac.RemoteDrift.Report(d.ID)
+	if !ok || (rep.State == services.DriftInSync && rep.RedeployErr == "") {
+		return nil
+	}
+	lbl := widget.NewLabel(driftSummary(rep))
+	lbl.Wrapping = fyne.TextWrapWord
+	if rep.State == services.DriftDiverged || rep.RedeployErr != "" {
+		lbl.Importance = widget.WarningImportance
+	}
+	return lbl
+}
+
+// driftSummary — отчёт сверки человеческим языком: что не так и что с этим
+// сделала политика машины.
+func driftSummary(rep services.DriftReport) string {
+	var text string
+	switch {
+	case rep.State == services.DriftUnknown:
+		text = locale.Tf("remote.drift.unknown", rep.Err)
+	case rep.State == services.DriftPending:
+		text = locale.T("remote.drift.pending")
+	case rep.RolledBack:
+		text = locale.T("remote.drift.rolled_back")
+	case rep.State == services.DriftDiverged && len(rep.Resources) > 0 && rep.ActiveSHA == rep.BuiltSHA:
+		text = locale.Tf("remote.drift.resources", len(rep.Resources))
+	case rep.State == services.DriftDiverged:
+		text = locale.T("remote.drift.drifted")
+	default:
+		text = locale.T("remote.drift.in_sync")
+	}
+	switch {
+	case rep.RedeployErr != "":
+		text += " " + locale.Tf("remote.drift.redeploy_failed", rep.RedeployErr)
+	case rep.Redeployed:
+		text += " " + locale.T("remote.drift.redeployed")
+	}
+	return text
+}
+
 // redrawRows перерисовывает строки из кеша health, не трогая реестр.
 func (p *machineListPanel) redrawRows() {
 	list, err := p.registry.List()
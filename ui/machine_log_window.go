@@ -0,0 +1,371 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+)
+
+// Панель логов машины (services/lxd_remote_logs.go).
+//
+// Не живой хвост, а история: строки копятся у нас, пока окно открыто, и
+// фильтры применяются ко всему накопленному — «что было с ядром час назад»
+// отвечается поиском, а не ожиданием повтора. Потоки и уровни — те же, что
+// во вкладках локального Log Viewer, чтобы не учить второй набор.
+//
+// Соединение не требуется, в отличие от профайлера: лог идёт своим
+// каналом хаба, и смотреть его имеет смысл как раз у машины, к которой
+// подключиться не получается.
+
+// machineLogRefresh — период перерисовки; строки копятся и без неё.
+const machineLogRefresh = time.Second
+
+// machineLogTimeLayout — формат полей «с/по» в диалоге сохранения.
+const machineLogTimeLayout = "2006-01-02 15:04:05"
+
+type machineLogWindow struct {
+	win fyne.Window
+	hub *services.RemoteLogHub
+}
+
+var (
+	machineLogWindowsMu sync.Mutex
+	// machineLogWindows — по окну на машину: повторное открытие фокусирует
+	// существующее, а две машины можно смотреть рядом.
+	machineLogWindows = map[string]*machineLogWindow{}
+)
+
+// OpenMachineLogWindow открывает панель логов машины.
+func OpenMachineLogWindow(ac *core.AppController, d services.RemoteDaemon) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil || ac.RemoteLogs == nil {
+		return
+	}
+
+	machineLogWindowsMu.Lock()
+	if w, ok := machineLogWindows[d.ID]; ok {
+		machineLogWindowsMu.Unlock()
+		w.win.Show()
+		w.win.RequestFocus()
+		return
+	}
+	machineLogWindowsMu.Unlock()
+
+	hub := ac.RemoteLogs
+	buf, release, err := hub.Follow(d.ID)
+	if err != nil {
+		ShowErrorText(ac.UIService.MainWindow, d.Name, locale.Tf("remote.logs.error", err))
+		return
+	}
+	startedAt := time.Now()
+
+	win := ac.UIService.Application.NewWindow(locale.Tf("remote.logs.window_title", d.Name))
+
+	var (
+		shown     []services.RemoteLogEntry
+		lastSeq   uint64
+		pauseSeq  uint64 // 0 — не на паузе
+		filterErr string
+	)
+
+	streamChecks := map[services.RemoteLogStream]*widget.Check{}
+	levelNames := []string{locale.T("log.level_error"), locale.T("log.level_warn"), locale.T("log.level_info"), locale.T("log.level_verbose"), locale.T("log.level_trace")}
+	levelByIndex := []debuglog.Level{debuglog.LevelError, debuglog.LevelWarn, debuglog.LevelInfo, debuglog.LevelVerbose, debuglog.LevelTrace}
+	levelSelect := widget.NewSelect(levelNames, nil)
+	search := widget.NewEntry()
+	search.SetPlaceHolder(locale.T("remote.logs.search_placeholder"))
+	regexCheck := widget.NewCheck(locale.T("remote.logs.regex"), nil)
+	status := widget.NewLabel("")
+
+	// currentFilter — фильтр из контролов; ошибка regex не роняет панель, а
+	// показывается в статусе до исправления.
+	currentFilter := func() (services.RemoteLogFilter, error) {
+		var f services.RemoteLogFilter
+		for _, st := range services.RemoteLogStreams {
+			if streamChecks[st].Checked {
+				f.Streams = append(f.Streams, st)
+			}
+		}
+		if len(f.Streams) == 0 {
+			// Ни одного потока — пустой список, а не «все» (нулевой фильтр).
+			f.Streams = []services.RemoteLogStream{""}
+		}
+		for i, n := range levelNames {
+			if n == levelSelect.Selected {
+				f.Level = levelByIndex[i]
+			}
+		}
+		text := strings.TrimSpace(search.Text)
+		if regexCheck.Checked && text != "" {
+			re, err := regexp.Compile(text)
+			if err != nil {
+				return f, err
+			}
+			f.Regex = re
+		} else {
+			f.Contains = text
+		}
+		return f, nil
+	}
+
+	list := widget.NewList(
+		func() int { return len(shown) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			// id 0 = самая новая строка, как в локальном Log Viewer.
+			idx := len(shown) - 1 - int(id)
+			if idx < 0 {
+				return
+			}
+			e := shown[idx]
+			o.(*widget.Label).SetText(fmt.Sprintf("%s [%s] %s %s",
+				e.Time.Format("15:04:05"), e.Stream, levelColor(e.Level), e.Message))
+		},
+	)
+
+	updateStatus := func() {
+		switch {
+		case filterErr != "":
+			status.SetText(locale.Tf("remote.logs.bad_regex", filterErr))
+		case pauseSeq != 0:
+			status.SetText(locale.Tf("remote.logs.paused", buf.LastSeq()-pauseSeq))
+		default:
+			status.SetText(locale.Tf("remote.logs.status", len(shown), buf.Len(), startedAt.Format("15:04")))
+		}
+	}
+
+	// redraw перечитывает историю под фильтром. На паузе показ замирает на
+	// последней строке до паузы, но фильтры по-прежнему применяются к тому,
+	// что было видно.
+	redraw := func() {
+		f, err := currentFilter()
+		if err != nil {
+			filterErr = err.Error()
+			updateStatus()
+			return
+		}
+		filterErr = ""
+		entries, _ := buf.Query(f, 0)
+		if pauseSeq != 0 {
+			cut := len(entries)
+			for cut > 0 && entries[cut-1].Seq > pauseSeq {
+				cut--
+			}
+			entries = entries[:cut]
+		}
+		shown = entries
+		list.Refresh()
+		updateStatus()
+	}
+
+	for _, st := range services.RemoteLogStreams {
+		c := widget.NewCheck(locale.T("remote.logs.stream_"+string(st)), func(bool) { redraw() })
+		c.SetChecked(true)
+		streamChecks[st] = c
+	}
+	levelSelect.OnChanged = func(string) { redraw() }
+	levelSelect.SetSelected(locale.T("log.level_trace"))
+	search.OnChanged = func(string) { redraw() }
+	regexCheck.OnChanged = func(bool) { redraw() }
+
+	var pauseBtn *widget.Button
+	pauseBtn = widget.NewButton(locale.T("remote.logs.pause"), func() {
+		if pauseSeq == 0 {
+			pauseSeq = buf.LastSeq()
+			if pauseSeq == 0 {
+				// Пауза на пустой истории: номер 0 занят под «не на паузе».
+				return
+			}
+			pauseBtn.SetText(locale.T("remote.logs.resume"))
+		} else {
+			pauseSeq = 0
+			pauseBtn.SetText(locale.T("remote.logs.pause"))
+		}
+		redraw()
+	})
+	clearBtn := widget.NewButton(locale.T("remote.logs.clear"), func() {
+		buf.Clear()
+		redraw()
+	})
+	saveBtn := widget.NewButton(locale.T("remote.logs.save"), func() {
+		f, err := currentFilter()
+		if err != nil {
+			ShowErrorText(win, locale.T("remote.logs.save_title"), locale.Tf("remote.logs.bad_regex", err))
+			return
+		}
+		showSaveMachineLogDialog(win, d, buf, f, shown)
+	})
+
+	top := container.NewVBox(
+		container.NewHBox(
+			streamChecks[services.RemoteLogCore], streamChecks[services.RemoteLogLauncher], streamChecks[services.RemoteLogAPI],
+			widget.NewSeparator(),
+			widget.NewLabel(locale.T("log.level_label")), levelSelect,
+		),
+		container.NewBorder(nil, nil, nil, regexCheck, search),
+	)
+	bottom := container.NewBorder(nil, nil, nil,
+		container.NewHBox(pauseBtn, clearBtn, saveBtn), status)
+
+	stopCh := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			close(stopCh)
+			release()
+		})
+	}
+	go func() {
+		t := time.NewTicker(machineLogRefresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-t.C:
+				// Перерисовка только при новых строках: перечитывать 5000
+				// строк под regex раз в секунду впустую незачем.
+				if seq := buf.LastSeq(); seq != lastSeq {
+					lastSeq = seq
+					fyne.Do(func() {
+						if pauseSeq != 0 {
+							updateStatus()
+							return
+						}
+						redraw()
+					})
+				}
+			}
+		}
+	}()
+
+	win.SetContent(container.NewBorder(top, bottom, nil, nil, list))
+	win.Resize(fyne.NewSize(820, 560))
+	win.CenterOnScreen()
+	win.SetCloseIntercept(func() {
+		stop()
+		machineLogWindowsMu.Lock()
+		delete(machineLogWindows, d.ID)
+		machineLogWindowsMu.Unlock()
+		win.Close()
+	})
+
+	machineLogWindowsMu.Lock()
+	machineLogWindows[d.ID] = &machineLogWindow{win: win, hub: hub}
+	machineLogWindowsMu.Unlock()
+
+	redraw()
+	win.Show()
+}
+
+// CloseMachineLogWindow закрывает панель логов машины. Зовётся при удалении
+// машины: её подписка иначе стучалась бы по адресу, которого в реестре нет.
+func CloseMachineLogWindow(id string) {
+	machineLogWindowsMu.Lock()
+	w, ok := machineLogWindows[id]
+	machineLogWindowsMu.Unlock()
+	if !ok {
+		return
+	}
+	fyne.Do(func() { w.win.Close() })
+}
+
+// RestartMachineLogStream переподписывает открытую панель на новый мандат
+// машины (ротация ключа, re-pair) — история при этом остаётся.
+func RestartMachineLogStream(id string) {
+	machineLogWindowsMu.Lock()
+	w, ok := machineLogWindows[id]
+	machineLogWindowsMu.Unlock()
+	if !ok {
+		return
+	}
+	go func() {
+		if err := w.hub.Restart(id); err != nil {
+			debuglog.WarnLog("machine log: restart %q: %v", id, err)
+		}
+	}()
+}
+
+// showSaveMachineLogDialog — интервал времени, затем файл. Интервал
+// заполнен по видимому списку, фильтры панели действуют и на файл.
+func showSaveMachineLogDialog(win fyne.Window, d services.RemoteDaemon, buf *services.RemoteLogBuffer,
+	f services.RemoteLogFilter, shown []services.RemoteLogEntry) {
+	from := widget.NewEntry()
+	to := widget.NewEntry()
+	from.SetPlaceHolder(machineLogTimeLayout)
+	to.SetPlaceHolder(machineLogTimeLayout)
+	if len(shown) > 0 {
+		from.SetText(shown[0].Time.Format(machineLogTimeLayout))
+		// Секунда вперёд: поле без миллисекунд, а последняя строка внутри
+		// своей секунды не должна выпасть из файла.
+		to.SetText(shown[len(shown)-1].Time.Add(time.Second).Format(machineLogTimeLayout))
+	}
+	form := widget.NewForm(
+		widget.NewFormItem(locale.T("remote.logs.field_from"), from),
+		widget.NewFormItem(locale.T("remote.logs.field_to"), to),
+	)
+	dlg := dialog.NewCustomConfirm(locale.T("remote.logs.save_title"), locale.T("remote.logs.save"),
+		locale.T("dialog.button_cancel"), form, func(ok bool) {
+			if !ok {
+				return
+			}
+			var err error
+			if f.Since, err = parseMachineLogTime(from.Text); err == nil {
+				f.Until, err = parseMachineLogTime(to.Text)
+			}
+			if err != nil {
+				ShowErrorText(win, locale.T("remote.logs.save_title"), locale.Tf("remote.logs.bad_time", machineLogTimeLayout))
+				return
+			}
+			entries, _ := buf.Query(f, 0)
+			if len(entries) == 0 {
+				ShowInfo(win, locale.T("remote.logs.save_title"), locale.T("remote.logs.save_empty"))
+				return
+			}
+			saveMachineLogFile(win, d, entries)
+		}, win)
+	dlg.Resize(fyne.NewSize(420, 0))
+	dlg.Show()
+}
+
+// parseMachineLogTime — местное время из поля; пустое — без границы.
+func parseMachineLogTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(machineLogTimeLayout, s, time.Local)
+}
+
+func saveMachineLogFile(win fyne.Window, d services.RemoteDaemon, entries []services.RemoteLogEntry) {
+	fd := dialog.NewFileSave(func(uc fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		if uc == nil {
+			return
+		}
+		defer func() { _ = uc.Close() }()
+		if err := services.WriteRemoteLog(uc, entries); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		debuglog.InfoLog("machine log: %d lines of %q saved to %s", len(entries), d.ID, uc.URI().Path())
+	}, win)
+	fd.SetFileName(fmt.Sprintf("%s-log-%s.log", d.ID, time.Now().Format("20060102-1504")))
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".log", ".txt"}))
+	fd.Show()
+}
@@ -1,10 +1,7 @@
 package ui
 
 import (
-	"fmt"
-	"strings"
 	"sync"
-	"time"
 
 	"singbox-launcher/core"
 	"singbox-launcher/core/services"
@@ -77,13 +74,13 @@ func OpenMachineProfiler(ac *core.AppController, d services.RemoteDaemon) {
 	// форка), где сервер, ответы и признак отказа уже разложены по полям.
 	// Clash-конфиг тоже пуст: к удалённой машине Clash API не применяется
 	// by design, весь обмен идёт по gRPC.
-	p.Start(func() (string, string, bool) { return "", "", false }, "", profilerHTTPClient)
+	p.Start(func() (string, string, bool) { return "", "", false }, "", core.ProfilerHTTPClient)
 	p.SetConnSnapshotFunc(tprof.SnapshotFunc(snapshot))
 
 	// DNS-события: отдельный стрим, события подаются профайлеру тем же путём,
 	// что у локального дают строки лога.
 	dnsCancel, dnsErr := transport.SubscribeDNSQueries(func(q services.DNSQuery) {
-		p.PushEvent(dnsQueryToEvent(q))
+		p.PushEvent(core.DNSQueryToEvent(q))
 	})
 	if dnsErr == nil {
 		// Лога машины у нас нет вовсе, так что двоиться нечему; флаг ставим
@@ -191,40 +188,6 @@ func CloseMachineProfiler(id string) {
 	}
 }
 
-// dnsQueryToEvent переводит DNS-событие машины в событие профайлера — ту же
-// форму, в какой их порождает разбор локального лога.
-func dnsQueryToEvent(q services.DNSQuery) tprof.TrafficEvent {
-	e := tprof.TrafficEvent{
-		TS:          time.Now(),
-		Kind:        tprof.EventDNSResolve,
-		Domain:      q.Domain,
-		ProcessPath: q.ProcessPath,
-		ProcessName: tprof.ProcessBase(q.ProcessPath),
-		CnameChain:  q.CNAMEs,
-	}
-	// Адреса ответа и сервер, который их дал, кладём в диагностическую
-	// строку: отдельных полей под них в модели нет, а при разборе «почему
-	// домен ушёл не туда» нужны оба.
-	var b strings.Builder
-	if q.DNSServer != "" {
-		fmt.Fprintf(&b, "server=%s ", q.DNSServer)
-	}
-	if len(q.Answers) > 0 {
-		fmt.Fprintf(&b, "answers=%s", strings.Join(q.Answers, ","))
-	}
-	e.RawLogLine = strings.TrimSpace(b.String())
-	if len(q.Answers) > 0 {
-		e.IP = q.Answers[0]
-	}
-	if q.Failed {
-		e.Kind = tprof.EventDNSFail
-		if q.Error != "" {
-			e.RawLogLine = strings.TrimSpace(e.RawLogLine + " error=" + q.Error)
-		}
-	}
-	return e
-}
-
 // statusStore — последняя сводка ядра по машинам.
 //
 // Стрим статуса живёт вместе с окном, а читают его из UI-потока при
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"os"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/locale"
+)
+
+// Форма пути до управляющего канала машины (services.RemoteRoute).
+//
+// Одна на два окна: в окне добавления маршрут нужен уже для enroll (машина
+// за NAT напрямую не отвечает и на сопряжение), в окне правки — чтобы
+// сменить его у заведённой машины. Поля показываются только те, что нужны
+// выбранному виду: SSH-хост у прокси смысла не имеет и наоборот.
+
+// machineRouteKinds — порядок вариантов в выпадающем списке.
+var machineRouteKinds = []services.RemoteRouteKind{
+	services.RouteDirect,
+	services.RouteSSH,
+	services.RouteSOCKS5,
+	services.RouteHTTP,
+	services.RouteCore,
+}
+
+type machineRouteForm struct {
+	kindSelect *widget.Select
+	byLabel    map[string]services.RemoteRouteKind
+
+	sshHost  *widget.Entry
+	sshUser  *widget.Entry
+	sshKey   *widget.Entry
+	sshAgent *widget.Check
+	sshPin   *widget.Entry
+	proxyURL *widget.Entry
+
+	sshBox   *fyne.Container
+	proxyBox *fyne.Container
+	hint     *widget.Label
+	content  *fyne.Container
+}
+
+// newMachineRouteForm строит форму, заполненную маршрутом rt.
+func newMachineRouteForm(rt services.RemoteRoute) *machineRouteForm {
+	f := &machineRouteForm{byLabel: map[string]services.RemoteRouteKind{}}
+
+	labels := make([]string, 0, len(machineRouteKinds))
+	for _, k := range machineRouteKinds {
+		label := locale.T("remote.route.kind_" + string(k))
+		labels = append(labels, label)
+		f.byLabel[label] = k
+	}
+
+	f.sshHost = widget.NewEntry()
+	f.sshHost.SetPlaceHolder(locale.T("remote.ssh.host_placeholder"))
+	f.sshHost.SetText(rt.SSHHost)
+	f.sshUser = widget.NewEntry()
+	f.sshUser.SetText(rt.SSHUser)
+	f.sshKey = widget.NewEntry()
+	f.sshKey.SetPlaceHolder("~/.ssh/id_ed25519")
+	f.sshKey.SetText(rt.SSHKeyFile)
+	f.sshAgent = widget.NewCheck(locale.T("remote.ssh.use_agent"), nil)
+	f.sshAgent.SetChecked(rt.SSHUseAgent)
+	f.sshPin = widget.NewEntry()
+	f.sshPin.SetPlaceHolder("SHA256:…")
+	f.sshPin.SetText(rt.SSHHostKey)
+	if rt.Kind != services.RouteSSH {
+		// Новый маршрут — те же умолчания, что у SSH bootstrap.
+		f.sshUser.SetText("root")
+		f.sshKey.SetText(defaultSSHKeyFile())
+		f.sshAgent.SetChecked(os.Getenv("SSH_AUTH_SOCK") != "")
+	}
+	f.proxyURL = widget.NewEntry()
+	f.proxyURL.SetPlaceHolder(locale.T("remote.route.proxy_placeholder"))
+	f.proxyURL.SetText(rt.ProxyURL)
+
+	f.sshBox = container.NewVBox(widget.NewForm(
+		widget.NewFormItem(locale.T("remote.route.field_jump_host"), f.sshHost),
+		widget.NewFormItem(locale.T("remote.ssh.field_user"), f.sshUser),
+		widget.NewFormItem(locale.T("remote.ssh.field_key"), f.sshKey),
+		widget.NewFormItem("", f.sshAgent),
+		widget.NewFormItem(locale.T("remote.ssh.field_host_key"), f.sshPin),
+	))
+	f.proxyBox = container.NewVBox(widget.NewForm(
+		widget.NewFormItem(locale.T("remote.route.field_proxy"), f.proxyURL),
+	))
+	f.hint = widget.NewLabel("")
+	f.hint.Wrapping = fyne.TextWrapWord
+
+	f.kindSelect = widget.NewSelect(labels, func(string) { f.refresh() })
+	kind := rt.Kind
+	if kind == "" {
+		kind = services.RouteDirect
+	}
+	f.kindSelect.SetSelected(locale.T("remote.route.kind_" + string(kind)))
+
+	f.content = container.NewVBox(
+		widget.NewForm(widget.NewFormItem(locale.T("remote.route.field_kind"), f.kindSelect)),
+		f.sshBox,
+		f.proxyBox,
+		f.hint,
+	)
+	f.refresh()
+	return f
+}
+
+func (f *machineRouteForm) kind() services.RemoteRouteKind {
+	if k, ok := f.byLabel[f.kindSelect.Selected]; ok {
+		return k
+	}
+	return services.RouteDirect
+}
+
+// refresh показывает поля выбранного вида и его подсказку.
+func (f *machineRouteForm) refresh() {
+	if f.sshBox == nil {
+		return
+	}
+	k := f.kind()
+	f.sshBox.Hidden = k != services.RouteSSH
+	f.proxyBox.Hidden = k != services.RouteSOCKS5 && k != services.RouteHTTP
+	f.hint.SetText(locale.T("remote.route.hint_" + string(k)))
+	f.sshBox.Refresh()
+	f.proxyBox.Refresh()
+}
+
+// Object — форма для вставки в окно.
+func (f *machineRouteForm) Object() fyne.CanvasObject {
+	return f.content
+}
+
+// Route — маршрут из полей; поля чужих видов не попадают в запись.
+func (f *machineRouteForm) Route() services.RemoteRoute {
+	rt := services.RemoteRoute{Kind: f.kind()}
+	switch rt.Kind {
+	case services.RouteSSH:
+		rt.SSHHost = strings.TrimSpace(f.sshHost.Text)
+		rt.SSHUser = strings.TrimSpace(f.sshUser.Text)
+		rt.SSHKeyFile = expandHome(strings.TrimSpace(f.sshKey.Text))
+		rt.SSHUseAgent = f.sshAgent.Checked
+		rt.SSHHostKey = strings.TrimSpace(f.sshPin.Text)
+	case services.RouteSOCKS5, services.RouteHTTP:
+		rt.ProxyURL = strings.TrimSpace(f.proxyURL.Text)
+	}
+	return rt
+}
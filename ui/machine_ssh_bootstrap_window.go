@@ -0,0 +1,218 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/ui/components"
+)
+
+// Окно добавления машины через SSH (services.RemoteRegistry.BootstrapSSH).
+//
+// Для VPS, куда пользователь и так ходит по SSH: лаунчер сам ставит ядро и
+// службу `sing-box lxd` и сопрягается — без ручного копирования
+// приглашения. Открывается из окна добавления машины; отдельное окно по той
+// же причине, что и оно: форма высокая.
+//
+// Ключ хоста проверяется по ~/.ssh/known_hosts. Незнакомый хост не
+// принимается молча: показываем отпечаток, и только подтверждение
+// пользователя превращает его в пин для повторной попытки.
+
+// OpenSSHBootstrapWindow открывает окно. onAdded — как у
+// OpenAddMachineWindow.
+func OpenSSHBootstrapWindow(ac *core.AppController, onAdded func()) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil {
+		return
+	}
+	win := ac.UIService.Application.NewWindow(locale.T("remote.ssh.window_title"))
+
+	hostEntry := widget.NewEntry()
+	hostEntry.SetPlaceHolder(locale.T("remote.ssh.host_placeholder"))
+	userEntry := widget.NewEntry()
+	userEntry.SetText("root")
+
+	keyEntry := widget.NewEntry()
+	keyEntry.SetPlaceHolder("~/.ssh/id_ed25519")
+	keyEntry.SetText(defaultSSHKeyFile())
+	passEntry := widget.NewPasswordEntry()
+	passEntry.SetPlaceHolder(locale.T("remote.ssh.passphrase_placeholder"))
+	agentCheck := widget.NewCheck(locale.T("remote.ssh.use_agent"), nil)
+	agentCheck.SetChecked(os.Getenv("SSH_AUTH_SOCK") != "")
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder(locale.T("remote.add.name_placeholder"))
+
+	// Пин ключа хоста: заполняется подтверждением незнакомого хоста или
+	// вручную (вывод `ssh-keygen -lf` на самой машине).
+	pinEntry := widget.NewEntry()
+	pinEntry.SetPlaceHolder("SHA256:…")
+	portEntry := widget.NewEntry()
+	portEntry.SetPlaceHolder(strconv.Itoa(services.SSHBootstrapDefaultPort))
+	addrEntry := widget.NewEntry()
+	addrEntry.SetPlaceHolder(locale.T("remote.ssh.addr_placeholder"))
+	// Версия ядра машины: по умолчанию — закреплённая этой сборкой, в списке
+	// ещё установленные у нас (core_versions.go). Любой другой тег тоже
+	// можно вписать — его скачает FetchCoreBinaryFor под платформу машины.
+	coreEntry := widget.NewSelectEntry([]string{constants.RequiredCoreVersion})
+	coreEntry.SetText(constants.RequiredCoreVersion)
+	go func() {
+		list, err := ac.ListCoreVersions()
+		if err != nil {
+			return
+		}
+		opts := []string{constants.RequiredCoreVersion}
+		for _, v := range list {
+			if v.Version != constants.RequiredCoreVersion {
+				opts = append(opts, v.Version)
+			}
+		}
+		fyne.Do(func() { coreEntry.SetOptions(opts) })
+	}()
+
+	form := widget.NewForm(
+		widget.NewFormItem(locale.T("remote.add.field_name"), nameEntry),
+		widget.NewFormItem(locale.T("remote.ssh.field_host"), hostEntry),
+		widget.NewFormItem(locale.T("remote.ssh.field_user"), userEntry),
+		widget.NewFormItem(locale.T("remote.ssh.field_key"), keyEntry),
+		widget.NewFormItem(locale.T("remote.ssh.field_passphrase"), passEntry),
+		widget.NewFormItem("", agentCheck),
+	)
+	advanced := widget.NewAccordion(widget.NewAccordionItem(locale.T("remote.ssh.advanced"),
+		widget.NewForm(
+			widget.NewFormItem(locale.T("remote.ssh.field_host_key"), pinEntry),
+			widget.NewFormItem(locale.T("remote.ssh.field_port"), portEntry),
+			widget.NewFormItem(locale.T("remote.add.field_addr"), addrEntry),
+			widget.NewFormItem(locale.T("remote.ssh.field_core_version"), coreEntry),
+		)))
+
+	hint := widget.NewLabel(locale.T("remote.ssh.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	cancelBtn := widget.NewButton(locale.T("dialog.button_cancel"), func() { win.Close() })
+	runBtn := widget.NewButton(locale.T("remote.ssh.submit"), nil)
+	runBtn.Importance = widget.HighImportance
+
+	var run func()
+	run = func() {
+		host := strings.TrimSpace(hostEntry.Text)
+		if host == "" || strings.TrimSpace(userEntry.Text) == "" {
+			status.SetText(locale.T("remote.ssh.error_empty"))
+			return
+		}
+		port := 0
+		if p := strings.TrimSpace(portEntry.Text); p != "" {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 || n > 65535 {
+				status.SetText(locale.T("remote.ssh.error_port"))
+				return
+			}
+			port = n
+		}
+		req := services.SSHBootstrapRequest{
+			Host:          host,
+			User:          strings.TrimSpace(userEntry.Text),
+			KeyFile:       expandHome(strings.TrimSpace(keyEntry.Text)),
+			KeyPassphrase: passEntry.Text,
+			UseAgent:      agentCheck.Checked,
+			HostKeySHA256: strings.TrimSpace(pinEntry.Text),
+			Name:          nameEntry.Text,
+			ListenPort:    port,
+			Addr:          strings.TrimSpace(addrEntry.Text),
+			CoreVersion:   strings.TrimPrefix(strings.TrimSpace(coreEntry.Text), "v"),
+		}
+		runBtn.Disable()
+		status.SetText(locale.T("remote.ssh.step_connect"))
+
+		// Сеть, sudo на той стороне и, возможно, скачивание ядра — в
+		// горутине; шаги видны в статусе.
+		go func() {
+			registry := services.NewRemoteRegistry(ac.FileService.ExecDir)
+			res, err := registry.BootstrapSSH(context.Background(), req, ac.FetchCoreBinaryFor, func(step string) {
+				fyne.Do(func() { status.SetText(locale.T("remote.ssh.step_" + step)) })
+			})
+			fyne.Do(func() {
+				runBtn.Enable()
+				var hkErr *services.SSHHostKeyError
+				switch {
+				case errors.As(err, &hkErr) && !hkErr.Mismatch:
+					status.SetText("")
+					ShowConfirm(win, locale.T("remote.ssh.trust_title"),
+						locale.Tf("remote.ssh.trust_body", hkErr.Host, hkErr.Fingerprint), func(ok bool) {
+							if !ok {
+								return
+							}
+							pinEntry.SetText(hkErr.Fingerprint)
+							run()
+						})
+				case err != nil:
+					debuglog.WarnLog("ssh bootstrap: %v", err)
+					status.SetText(locale.Tf("remote.ssh.error", err))
+				default:
+					debuglog.InfoLog("ssh bootstrap: added %q (%s, %s/%s, core uploaded: %v)",
+						res.Machine.Name, res.Machine.Addr, res.GOOS, res.GOARCH, res.CoreUploaded)
+					if onAdded != nil {
+						onAdded()
+					}
+					win.Close()
+				}
+			})
+		}()
+	}
+	runBtn.OnTapped = run
+
+	body := container.NewVBox(
+		hint,
+		widget.NewSeparator(),
+		form,
+		advanced,
+		status,
+		container.NewBorder(nil, nil, nil, container.NewHBox(cancelBtn, runBtn)),
+	)
+	win.SetContent(container.NewPadded(components.WrapInScrollWithGutter(body)))
+	win.Resize(fyne.NewSize(520, 600))
+	win.CenterOnScreen()
+	win.Show()
+}
+
+// defaultSSHKeyFile — первый из стандартных ключей пользователя, что есть
+// на диске; пусто — только агент.
+func defaultSSHKeyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		path := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// expandHome раскрывает ведущий `~/` — так путь к ключу пишут руками.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
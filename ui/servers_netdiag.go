@@ -0,0 +1,374 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/api"
+	"singbox-launcher/core"
+	"singbox-launcher/core/netdiag"
+	"singbox-launcher/core/services"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+// Окно «Network test» — качество канала и тип NAT через конкретный узел.
+//
+// Открывается из контекстного меню строки, как и «Info». Тест идёт через
+// ядро той области, из которой открыли строку: узел удалённой машины меряет
+// её демон (трафик от роутера, а не от ноутбука), локальный — свой движок
+// (classic поднимает временный зонд, daemon просит демона).
+//
+// Результаты копятся в истории узла (ac.NetDiag) — их видно здесь же, вместе
+// со сводкой по всем протестированным узлам той же машины.
+
+// showNetDiagWindow открывает окно теста сети для proxy.
+func showNetDiagWindow(ac *core.AppController, proxy api.ProxyInfo, scope services.ProxyScope) {
+	if ac == nil || ac.FileService == nil || ac.NetDiag == nil {
+		return
+	}
+	target := core.NetDiagTarget{Tag: proxy.Name, ConfigPath: effectiveNodeConfigPath(ac, scope)}
+	engine := locale.T("netdiag.via_classic")
+	if ac.BackendMode() == core.BackendDaemon {
+		engine = locale.T("netdiag.via_daemon")
+	}
+	if scope == services.ScopeRemote {
+		id, name, active := GetLxdRemoteOverride()
+		tr, ok := lxdOverrideTransportForID(id)
+		if !active || !ok {
+			ShowErrorText(ac.UIService.MainWindow, locale.T("netdiag.title_short"), locale.T("netdiag.no_machine"))
+			return
+		}
+		target.Machine, target.Remote = id, tr
+		engine = locale.Tf("netdiag.via_remote", name)
+	}
+	daemonSide := target.Remote != nil || ac.BackendMode() == core.BackendDaemon
+
+	win := fyne.CurrentApp().NewWindow(locale.Tf("netdiag.title", proxy.DisplayOrName()))
+	ctx, cancel := context.WithCancel(context.Background())
+	win.SetOnClosed(cancel)
+
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	st := locale.LoadSettings(binDir)
+	probeEntry := netDiagEntry(st.NetDiagProbeURL, netdiag.DefaultProbeURL)
+	downEntry := netDiagEntry(st.NetDiagDownloadURL, netdiag.DefaultDownloadURL)
+	upEntry := netDiagEntry(st.NetDiagUploadURL, netdiag.DefaultUploadURL)
+	configEntry := netDiagEntry(st.NetDiagQualityConfigURL, locale.T("netdiag.config_url_default"))
+	stunEntry := netDiagEntry(st.NetDiagSTUNServer, effectiveSTUNServer())
+
+	serversForm := widget.NewForm()
+	if daemonSide {
+		// Демон сам выбирает серверы networkQuality по своему конфигу: URL
+		// замеров лаунчера ему не передаются.
+		serversForm.Append(locale.T("netdiag.config_url"), configEntry)
+	} else {
+		serversForm.Append(locale.T("netdiag.probe_url"), probeEntry)
+		serversForm.Append(locale.T("netdiag.download_url"), downEntry)
+		serversForm.Append(locale.T("netdiag.upload_url"), upEntry)
+	}
+	serversForm.Append(locale.T("netdiag.stun_server"), stunEntry)
+	serversHint := widget.NewLabel(locale.T("netdiag.servers_hint"))
+	serversHint.Wrapping = fyne.TextWrapWord
+	servers := widget.NewAccordion(widget.NewAccordionItem(
+		locale.T("netdiag.section_servers"), container.NewVBox(serversForm, serversHint)))
+
+	// saveServers — load-mutate-save: окно живёт долго, и перезаписывать
+	// settings.json снимком на момент открытия значило бы затереть то, что
+	// за это время поменяли на вкладке Settings.
+	saveServers := func() {
+		s := locale.LoadSettings(binDir)
+		s.NetDiagProbeURL = strings.TrimSpace(probeEntry.Text)
+		s.NetDiagDownloadURL = strings.TrimSpace(downEntry.Text)
+		s.NetDiagUploadURL = strings.TrimSpace(upEntry.Text)
+		s.NetDiagQualityConfigURL = strings.TrimSpace(configEntry.Text)
+		s.NetDiagSTUNServer = strings.TrimSpace(stunEntry.Text)
+		if err := locale.SaveSettings(binDir, s); err != nil {
+			debuglog.WarnLog("netdiag: save settings: %v", err)
+		}
+	}
+
+	status := widget.NewLabel(locale.T("netdiag.idle"))
+	status.Wrapping = fyne.TextWrapWord
+	history := container.NewVBox()
+	compare := container.NewVBox()
+	refresh := func() {
+		rec, _ := ac.NetDiag.Get(core.NetDiagKey(target))
+		fillNetDiagHistory(history, rec)
+		fillNetDiagCompare(compare, ac.NetDiag.All(), target.Machine)
+	}
+	refresh()
+
+	var (
+		runMu     sync.Mutex
+		runCancel context.CancelFunc
+	)
+	var qualityBtn, natBtn, stopBtn *widget.Button
+	setRunning := func(running bool) {
+		if running {
+			qualityBtn.Disable()
+			natBtn.Disable()
+			stopBtn.Enable()
+			return
+		}
+		qualityBtn.Enable()
+		natBtn.Enable()
+		stopBtn.Disable()
+	}
+	// run запускает один тест в фоне; второй параллельно не нужен — два
+	// теста через один узел мешали бы друг другу и врали оба.
+	run := func(label string, body func(ctx context.Context) string) {
+		saveServers()
+		runCtx, stop := context.WithCancel(ctx)
+		runMu.Lock()
+		runCancel = stop
+		runMu.Unlock()
+		setRunning(true)
+		status.SetText(label)
+		go func() {
+			defer stop()
+			line := body(runCtx)
+			fyne.Do(func() {
+				setRunning(false)
+				if runCtx.Err() != nil && ctx.Err() == nil {
+					status.SetText(locale.T("netdiag.cancelled"))
+					return
+				}
+				status.SetText(line)
+				refresh()
+			})
+		}()
+	}
+
+	qualityBtn = widget.NewButton(locale.T("netdiag.run_quality"), func() {
+		opts := netdiag.QualityOptions{
+			ProbeURL:    strings.TrimSpace(probeEntry.Text),
+			DownloadURL: strings.TrimSpace(downEntry.Text),
+			UploadURL:   strings.TrimSpace(upEntry.Text),
+			ConfigURL:   strings.TrimSpace(configEntry.Text),
+		}
+		run(locale.T("netdiag.running_quality"), func(ctx context.Context) string {
+			res, err := ac.RunNetQuality(ctx, target, opts, func(r netdiag.QualityResult) {
+				line := locale.T("netdiag.running_quality") + " " + netDiagQualitySummary(r)
+				fyne.Do(func() {
+					if ctx.Err() == nil {
+						status.SetText(line)
+					}
+				})
+			})
+			if err != nil && res.LatencyMs == 0 && res.DownloadBps == 0 {
+				return locale.Tf("netdiag.failed", err.Error())
+			}
+			return netDiagQualitySummary(res)
+		})
+	})
+	natBtn = widget.NewButton(locale.T("netdiag.run_nat"), func() {
+		server := strings.TrimSpace(stunEntry.Text)
+		if server == "" {
+			server = effectiveSTUNServer()
+		}
+		run(locale.T("netdiag.running_nat"), func(ctx context.Context) string {
+			res, err := ac.RunNATTest(ctx, target, server)
+			if err != nil && !res.UDPReachable {
+				return locale.Tf("netdiag.failed", err.Error())
+			}
+			return netDiagNATSummary(res)
+		})
+	})
+	stopBtn = widget.NewButton(locale.T("netdiag.stop"), func() {
+		runMu.Lock()
+		if runCancel != nil {
+			runCancel()
+		}
+		runMu.Unlock()
+	})
+	stopBtn.Disable()
+
+	body := container.NewVBox(
+		sectionHeader(locale.T("servers.node_info_section_general")),
+		infoRow(locale.T("servers.node_info_tag"), proxy.Name),
+		infoRow(locale.T("netdiag.engine"), engine),
+		servers,
+		container.NewHBox(qualityBtn, natBtn, stopBtn),
+		status,
+		sectionHeader(locale.T("netdiag.section_history")),
+		history,
+		sectionHeader(locale.T("netdiag.section_compare")),
+		compare,
+	)
+	win.SetContent(withScrollGutter(body))
+	win.Resize(fyne.NewSize(620, 560))
+	win.CenterOnScreen()
+	win.Show()
+}
+
+// netDiagEntry — поле адреса с дефолтом в placeholder: пустое значит
+// «по умолчанию», и так видно, какое именно.
+func netDiagEntry(value, placeholder string) *widget.Entry {
+	e := widget.NewEntry()
+	e.SetPlaceHolder(placeholder)
+	e.SetText(value)
+	return e
+}
+
+// fillNetDiagHistory — прогоны узла, свежие сверху.
+func fillNetDiagHistory(box *fyne.Container, rec netdiag.Record) {
+	box.RemoveAll()
+	type line struct {
+		at   int64
+		text string
+	}
+	var lines []line
+	for _, q := range rec.Quality {
+		lines = append(lines, line{q.At.UnixNano(), q.At.Format("2006-01-02 15:04") + "  " + netDiagQualitySummary(q)})
+	}
+	for _, n := range rec.NAT {
+		lines = append(lines, line{n.At.UnixNano(), n.At.Format("2006-01-02 15:04") + "  " + netDiagNATSummary(n)})
+	}
+	if len(lines) == 0 {
+		box.Add(widget.NewLabel(locale.T("netdiag.no_results")))
+		return
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].at > lines[j].at })
+	for _, l := range lines {
+		lbl := widget.NewLabel(l.text)
+		lbl.Wrapping = fyne.TextWrapWord
+		box.Add(lbl)
+	}
+}
+
+// fillNetDiagCompare — последний удачный прогон каждого узла той же машины,
+// от быстрых к медленным: ради этого списка тест и запускают по нескольким
+// узлам подряд.
+func fillNetDiagCompare(box *fyne.Container, all []netdiag.Record, machine string) {
+	box.RemoveAll()
+	rows := netDiagCompareRows(all, machine)
+	if len(rows) == 0 {
+		box.Add(widget.NewLabel(locale.T("netdiag.no_results")))
+		return
+	}
+	for _, r := range rows {
+		box.Add(infoRow(r[0], r[1]))
+	}
+}
+
+// netDiagCompareRows — пары (тег, сводка) для сравнения узлов машины machine.
+func netDiagCompareRows(all []netdiag.Record, machine string) [][2]string {
+	type row struct {
+		tag     string
+		latency float64
+		text    string
+	}
+	var rows []row
+	for _, rec := range all {
+		if rec.Machine != machine {
+			continue
+		}
+		q, okQ := rec.LatestQuality()
+		n, okN := rec.LatestNAT()
+		if !okQ && !okN {
+			continue
+		}
+		var parts []string
+		if okQ {
+			parts = append(parts, netDiagQualitySummary(q))
+		}
+		if okN {
+			if t := n.NATType(); t != "" {
+				parts = append(parts, locale.Tf("netdiag.nat_type", t))
+			} else if !n.UDPReachable {
+				parts = append(parts, locale.T("netdiag.udp_blocked"))
+			}
+		}
+		lat := q.LatencyMs
+		if !okQ || lat == 0 {
+			// Без задержки — в конец списка.
+			lat = 1e12
+		}
+		rows = append(rows, row{rec.Tag, lat, strings.Join(parts, " · ")})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].latency != rows[j].latency {
+			return rows[i].latency < rows[j].latency
+		}
+		return rows[i].tag < rows[j].tag
+	})
+	out := make([][2]string, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, [2]string{r.tag, r.text})
+	}
+	return out
+}
+
+// netDiagQualitySummary — «42 ms ± 3.1 · loss 0% · ↓ 87.4 Mbit/s ↑ 21.0
+// Mbit/s». Неизмеренные метрики (нулевые) пропускаются: у демона нет
+// джиттера, у лаунчера — RPM.
+func netDiagQualitySummary(r netdiag.QualityResult) string {
+	var parts []string
+	if r.LatencyMs > 0 {
+		lat := fmt.Sprintf("%.0f ms", r.LatencyMs)
+		if r.JitterMs > 0 {
+			lat += fmt.Sprintf(" ± %.1f", r.JitterMs)
+		}
+		parts = append(parts, lat)
+	}
+	if r.LatencyMs > 0 && r.Via == netdiag.ViaClassic {
+		parts = append(parts, locale.Tf("netdiag.loss", fmt.Sprintf("%.0f%%", r.LossPercent)))
+	}
+	var speed []string
+	if r.DownloadBps > 0 {
+		speed = append(speed, "↓ "+netdiag.FormatBitrate(r.DownloadBps))
+	}
+	if r.UploadBps > 0 {
+		speed = append(speed, "↑ "+netdiag.FormatBitrate(r.UploadBps))
+	}
+	if len(speed) > 0 {
+		parts = append(parts, strings.Join(speed, " "))
+	}
+	if r.DownloadRPM > 0 || r.UploadRPM > 0 {
+		parts = append(parts, fmt.Sprintf("RPM %d/%d", r.DownloadRPM, r.UploadRPM))
+	}
+	if r.Error != "" {
+		parts = append(parts, locale.Tf("netdiag.failed", r.Error))
+	}
+	if len(parts) == 0 {
+		return locale.T("netdiag.no_data")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// netDiagNATSummary — «UDP ok · 203.0.113.7:41000 · NAT: full cone».
+func netDiagNATSummary(r netdiag.NATResult) string {
+	if !r.UDPReachable {
+		if r.Error != "" {
+			return locale.T("netdiag.udp_blocked") + " · " + r.Error
+		}
+		return locale.T("netdiag.udp_blocked")
+	}
+	parts := []string{locale.T("netdiag.udp_ok")}
+	if r.ExternalAddr != "" {
+		parts = append(parts, r.ExternalAddr)
+	}
+	if r.LatencyMs > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ms", r.LatencyMs))
+	}
+	switch t := r.NATType(); {
+	case t != "":
+		parts = append(parts, locale.Tf("netdiag.nat_type", t))
+	case !r.NATTypeSupported:
+		parts = append(parts, locale.T("netdiag.nat_type_unsupported"))
+	default:
+		parts = append(parts, locale.Tf("netdiag.nat_type", r.Mapping.String()+" / "+r.Filtering.String()))
+	}
+	if r.Error != "" {
+		parts = append(parts, r.Error)
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"singbox-launcher/core/netdiag"
+)
+
+// Окно «Network test» — сводки результатов и сравнение узлов.
+
+func TestNetDiagQualitySummarySkipsUnmeasured(t *testing.T) {
+	daemon := netDiagQualitySummary(netdiag.QualityResult{
+		Via: netdiag.ViaDaemon, LatencyMs: 41.6, DownloadBps: 87_400_000, DownloadRPM: 900, UploadRPM: 700,
+	})
+	if strings.Contains(daemon, "±") {
+		t.Fatalf("daemon has no jitter, got %q", daemon)
+	}
+	for _, want := range []string{"42 ms", "↓ 87.4 Mbit/s", "RPM 900/700"} {
+		if !strings.Contains(daemon, want) {
+			t.Fatalf("summary %q lacks %q", daemon, want)
+		}
+	}
+	if strings.Contains(daemon, "↑") {
+		t.Fatalf("upload was not measured, got %q", daemon)
+	}
+
+	classic := netDiagQualitySummary(netdiag.QualityResult{Via: netdiag.ViaClassic, LatencyMs: 20, JitterMs: 1.25})
+	if !strings.Contains(classic, "20 ms ± 1.2") && !strings.Contains(classic, "20 ms ± 1.3") {
+		t.Fatalf("classic summary = %q", classic)
+	}
+}
+
+func TestNetDiagNATSummary(t *testing.T) {
+	full := netDiagNATSummary(netdiag.NATResult{
+		UDPReachable: true, ExternalAddr: "203.0.113.7:41000", NATTypeSupported: true,
+		Mapping: netdiag.NATEndpointIndependent, Filtering: netdiag.NATEndpointIndependent,
+	})
+	if !strings.Contains(full, "203.0.113.7:41000") || !strings.Contains(full, "full cone") {
+		t.Fatalf("summary = %q", full)
+	}
+	blocked := netDiagNATSummary(netdiag.NATResult{Error: "no STUN response"})
+	if !strings.Contains(blocked, "no STUN response") {
+		t.Fatalf("summary = %q", blocked)
+	}
+}
+
+func TestNetDiagCompareRowsSortedByLatencyPerMachine(t *testing.T) {
+	now := time.Now()
+	all := []netdiag.Record{
+		{Key: "a", Tag: "slow", Quality: []netdiag.QualityResult{{At: now, LatencyMs: 120}}},
+		{Key: "b", Tag: "fast", Quality: []netdiag.QualityResult{{At: now, LatencyMs: 15}}},
+		{Key: "c", Tag: "nat-only", NAT: []netdiag.NATResult{{At: now, UDPReachable: true}}},
+		{Key: "r/d", Tag: "router", Machine: "r", Quality: []netdiag.QualityResult{{At: now, LatencyMs: 5}}},
+		{Key: "e", Tag: "failed", Quality: []netdiag.QualityResult{{At: now, Error: "timeout"}}},
+	}
+	rows := netDiagCompareRows(all, "")
+	var tags []string
+	for _, r := range rows {
+		tags = append(tags, r[0])
+	}
+	if got := strings.Join(tags, ","); got != "fast,slow,nat-only" {
+		t.Fatalf("rows = %s", got)
+	}
+	if rows := netDiagCompareRows(all, "r"); len(rows) != 1 || rows[0][0] != "router" {
+		t.Fatalf("machine rows = %v", rows)
+	}
+}
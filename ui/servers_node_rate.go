@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	tprof "singbox-launcher/internal/traffic"
+)
+
+// Скорость через узел в подзаголовке списка серверов (только Local).
+//
+// Источник — профайлер трафика: он и так раз в секунду снимает соединения
+// своего ядра (Clash /connections или gRPC демона) и считает по ним скорость
+// каждого тега цепочки. Для удалённой машины такого потока в списке нет, и
+// строка там остаётся прежней.
+
+// serversRateIdle — ниже этой скорости узел считается простаивающим, и
+// подпись не показывается: фоновые keepalive'ы в десятки байт только мигали бы.
+const serversRateIdle = 1024
+
+// serversNodeRate — «↓ 1.2 MB/s ↑ 30 KB/s» для узла; "" — через него сейчас
+// ничего заметного не идёт.
+func serversNodeRate(rates map[string]tprof.OutboundRate, name string) string {
+	r, ok := rates[name]
+	if !ok || r.Up+r.Down < serversRateIdle {
+		return ""
+	}
+	return "↓ " + core.FormatRate(r.Down) + " ↑ " + core.FormatRate(r.Up)
+}
+
+// withNodeRate дописывает скорость к подзаголовку, ужимая сам подзаголовок:
+// вместе они обязаны уложиться в serversSubtitleMaxRunes, иначе строка
+// вытолкнет кнопки за край.
+func withNodeRate(subtitle, rate string) string {
+	if rate == "" {
+		return truncateSubtitle(subtitle)
+	}
+	room := serversSubtitleMaxRunes - len([]rune(rate)) - 2
+	if subtitle == "" || room < 4 {
+		return rate
+	}
+	return truncateRunes(subtitle, room) + "  " + rate
+}
+
+// localNodeRates — текущие скорости по тегам своего ядра; nil — замеров нет.
+func localNodeRates() map[string]tprof.OutboundRate {
+	rates, ok := tprof.GetInstance().OutboundRates()
+	if !ok {
+		return nil
+	}
+	return rates
+}
+
+// startServersRateTicker перерисовывает список раз в секунду, пока есть что
+// показывать, и ещё раз — когда скорость пропала, чтобы стереть подписи.
+// Без замеров (ядро остановлено) список не трогается вовсе.
+func startServersRateTicker(list *widget.List) {
+	go func() {
+		hadRate := false
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for range tick.C {
+			has := len(localNodeRates()) > 0
+			need := has || hadRate
+			hadRate = has
+			if need {
+				fyne.Do(list.Refresh)
+			}
+		}
+	}()
+}
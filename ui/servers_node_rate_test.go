@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tprof "singbox-launcher/internal/traffic"
+)
+
+func TestServersNodeRate(t *testing.T) {
+	rates := map[string]tprof.OutboundRate{
+		"JP-01": {Up: 30 * 1024, Down: 1258291},
+		"idle":  {Up: 10, Down: 20},
+	}
+	if got := serversNodeRate(rates, "JP-01"); got != "↓ 1.2 MB/s ↑ 30 KB/s" {
+		t.Errorf("JP-01 = %q", got)
+	}
+	if got := serversNodeRate(rates, "idle"); got != "" {
+		t.Errorf("keepalive-level traffic must not show, got %q", got)
+	}
+	if got := serversNodeRate(nil, "JP-01"); got != "" {
+		t.Errorf("no measurements must give no rate, got %q", got)
+	}
+}
+
+func TestWithNodeRateFitsSubtitleLimit(t *testing.T) {
+	rate := "↓ 1.2 MB/s ↑ 30 KB/s"
+	long := strings.Repeat("vless·ws·Reality·", 4)
+	got := withNodeRate(long, rate)
+	if n := len([]rune(got)); n > serversSubtitleMaxRunes {
+		t.Errorf("subtitle with rate is %d runes, limit %d: %q", n, serversSubtitleMaxRunes, got)
+	}
+	if !strings.HasSuffix(got, rate) {
+		t.Errorf("rate must stay intact: %q", got)
+	}
+	if got := withNodeRate("", rate); got != rate {
+		t.Errorf("empty subtitle: %q", got)
+	}
+	if got := withNodeRate("vless·ws", ""); got != "vless·ws" {
+		t.Errorf("no rate must leave subtitle as is: %q", got)
+	}
+}
@@ -0,0 +1,210 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/config"
+	"singbox-launcher/core/state"
+	"singbox-launcher/internal/locale"
+)
+
+// Окно «Автопереключение» вкладки Servers (core/watchdog.go): какие
+// selector-группы сторож держит на живом узле и как часто проверяет.
+// Пустое поле — встроенное значение (оно же в подсказке поля).
+
+var (
+	watchdogWindowMu sync.Mutex
+	watchdogWindow   fyne.Window
+)
+
+// OpenWatchdogWindow открывает окно сторожа связности.
+func OpenWatchdogWindow(ac *core.AppController) {
+	if ac == nil || ac.UIService == nil || ac.UIService.Application == nil || ac.FileService == nil {
+		return
+	}
+	watchdogWindowMu.Lock()
+	if watchdogWindow != nil {
+		w := watchdogWindow
+		watchdogWindowMu.Unlock()
+		w.Show()
+		w.RequestFocus()
+		return
+	}
+	watchdogWindowMu.Unlock()
+
+	win := ac.UIService.Application.NewWindow(locale.T("servers.watchdog.window_title"))
+	defaults := core.ResolveWatchdogPolicy(nil)
+	policy := ac.WatchdogPolicy()
+	if policy == nil {
+		policy = &state.WatchdogPolicy{}
+	}
+
+	intEntry := func(placeholder string, v int) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetPlaceHolder(placeholder)
+		if v != 0 {
+			e.SetText(strconv.Itoa(v))
+		}
+		return e
+	}
+	enabled := widget.NewCheck(locale.T("servers.watchdog.enabled"), nil)
+	enabled.SetChecked(policy.Enabled)
+
+	// Группы — из config.json; сохранённые, которых в конфиге уже нет,
+	// тоже показываем, чтобы их можно было снять.
+	groupOptions, _, _ := config.GetSelectorGroupsFromConfig(ac.FileService.ConfigPath)
+	for _, g := range policy.Groups {
+		if !slices.Contains(groupOptions, g) {
+			groupOptions = append(groupOptions, g)
+		}
+	}
+	groups := widget.NewCheckGroup(groupOptions, nil)
+	groups.SetSelected(append([]string(nil), policy.Groups...))
+
+	interval := intEntry(strconv.Itoa(int(defaults.Interval/time.Second)), policy.IntervalSec)
+	failures := intEntry(strconv.Itoa(defaults.Failures), policy.Failures)
+	maxDelay := intEntry(locale.T("servers.watchdog.max_delay_any"), policy.MaxDelayMs)
+	switchBack := widget.NewCheck(locale.T("servers.watchdog.switch_back"), nil)
+	switchBack.SetChecked(policy.SwitchBack)
+	recoverChecks := intEntry(strconv.Itoa(defaults.RecoverChecks), policy.RecoverChecks)
+	hold := intEntry(strconv.Itoa(int(defaults.Hold/time.Second)), policy.HoldSec)
+
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	logList := widget.NewLabel("")
+	logList.Wrapping = fyne.TextWrapWord
+	logList.TextStyle = fyne.TextStyle{Monospace: true}
+
+	collect := func() (*state.WatchdogPolicy, error) {
+		p := &state.WatchdogPolicy{
+			Enabled:    enabled.Checked,
+			Groups:     append([]string(nil), groups.Selected...),
+			SwitchBack: switchBack.Checked,
+		}
+		for _, f := range []struct {
+			e     *widget.Entry
+			field string
+			dst   *int
+		}{
+			{interval, "interval_sec", &p.IntervalSec},
+			{failures, "failures", &p.Failures},
+			{maxDelay, "max_delay_ms", &p.MaxDelayMs},
+			{recoverChecks, "recover_checks", &p.RecoverChecks},
+			{hold, "hold_sec", &p.HoldSec},
+		} {
+			t := strings.TrimSpace(f.e.Text)
+			if t == "" {
+				continue
+			}
+			v, err := strconv.Atoi(t)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", f.field, t)
+			}
+			*f.dst = v
+		}
+		if !p.Enabled && len(p.Groups) == 0 && !p.SwitchBack && p.IntervalSec == 0 && p.Failures == 0 &&
+			p.MaxDelayMs == 0 && p.RecoverChecks == 0 && p.HoldSec == 0 {
+			return nil, nil
+		}
+		return p, nil
+	}
+
+	refresh := func() {
+		groupsStatus, events := ac.WatchdogStatus()
+		lines := make([]string, 0, len(groupsStatus))
+		for _, g := range groupsStatus {
+			line := locale.Tf("servers.watchdog.group_status", g.Group, g.Current, g.Failures)
+			if g.Original != "" {
+				line += "  " + locale.Tf("servers.watchdog.group_original", g.Original)
+			}
+			if g.LastError != "" {
+				line += "  " + g.LastError
+			} else if g.LastDelay > 0 {
+				line += fmt.Sprintf("  %d ms", g.LastDelay)
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			lines = append(lines, locale.T("servers.watchdog.no_status"))
+		}
+		status.SetText(strings.Join(lines, "\n"))
+		logList.SetText(formatWatchdogEvents(events))
+	}
+
+	saveBtn := widget.NewButton(locale.T("servers.watchdog.button_save"), func() {
+		p, err := collect()
+		if err == nil {
+			err = ac.SetWatchdogPolicy(audit.ActorUI, p)
+		}
+		if err != nil {
+			status.SetText(locale.Tf("servers.watchdog.error", err))
+			return
+		}
+		refresh()
+	})
+	saveBtn.Importance = widget.HighImportance
+	refreshBtn := widget.NewButton(locale.T("servers.watchdog.button_refresh"), refresh)
+
+	form := widget.NewForm(
+		widget.NewFormItem(locale.T("servers.watchdog.interval"), interval),
+		widget.NewFormItem(locale.T("servers.watchdog.failures"), failures),
+		widget.NewFormItem(locale.T("servers.watchdog.max_delay"), maxDelay),
+		widget.NewFormItem("", switchBack),
+		widget.NewFormItem(locale.T("servers.watchdog.recover_checks"), recoverChecks),
+		widget.NewFormItem(locale.T("servers.watchdog.hold"), hold),
+	)
+	hint := widget.NewLabel(locale.T("servers.watchdog.hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	groupsLabel := widget.NewLabelWithStyle(locale.T("servers.watchdog.groups"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	top := container.NewVBox(hint, enabled, groupsLabel, groups, form, saveBtn, status, widget.NewSeparator(),
+		container.NewBorder(nil, nil, widget.NewLabelWithStyle(locale.T("servers.watchdog.log_title"),
+			fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), refreshBtn))
+	win.SetContent(container.NewBorder(top, nil, nil, nil, container.NewVScroll(logList)))
+	win.Resize(fyne.NewSize(600, 720))
+	win.CenterOnScreen()
+	win.SetCloseIntercept(func() {
+		watchdogWindowMu.Lock()
+		watchdogWindow = nil
+		watchdogWindowMu.Unlock()
+		win.Close()
+	})
+
+	watchdogWindowMu.Lock()
+	watchdogWindow = win
+	watchdogWindowMu.Unlock()
+
+	refresh()
+	win.Show()
+}
+
+// formatWatchdogEvents — журнал сторожа построчно, новые сверху.
+func formatWatchdogEvents(events []core.WatchdogEvent) string {
+	if len(events) == 0 {
+		return locale.T("servers.watchdog.log_empty")
+	}
+	lines := make([]string, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		line := ev.Time.Local().Format("2006-01-02 15:04:05") + "  " + ev.Kind + "  " + ev.Group
+		if ev.From != "" || ev.To != "" {
+			line += ": " + ev.From + " → " + ev.To
+		}
+		if ev.Detail != "" {
+			line += "  (" + ev.Detail + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
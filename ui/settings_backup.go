@@ -0,0 +1,260 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/audit"
+	"singbox-launcher/core/backup"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/locale"
+)
+
+// Резервная копия профиля (core/backup): создание — выбор частей и пароль
+// дважды, восстановление — файл, пароль, затем выбор из того, что в копии
+// есть.
+
+// backupFileLimit — предел читаемого файла копии (тела подписок и .srs).
+const backupFileLimit = 256 << 20
+
+// buildBackupBlock — блок вкладки Settings.
+func buildBackupBlock(ac *core.AppController) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(locale.T("settings.section_backup"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	hint := widget.NewLabel(locale.T("settings.backup_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	createBtn := widget.NewButton(locale.T("settings.backup_create"), func() {
+		showCreateBackupDialog(ac)
+	})
+	restoreBtn := widget.NewButton(locale.T("settings.backup_restore"), func() {
+		showRestoreBackupDialog(ac)
+	})
+	return container.NewVBox(title, hint, container.NewHBox(createBtn, restoreBtn))
+}
+
+func backupCategoryLabel(c backup.Category) string {
+	return locale.T("settings.backup_cat_" + string(c))
+}
+
+// backupCategoryChecks — галочки по категориям; все отмечены.
+func backupCategoryChecks(cats []backup.Category, label func(backup.Category) string) (*fyne.Container, func() []backup.Category) {
+	box := container.NewVBox()
+	checks := make([]*widget.Check, len(cats))
+	for i, c := range cats {
+		checks[i] = widget.NewCheck(label(c), nil)
+		checks[i].SetChecked(true)
+		box.Add(checks[i])
+	}
+	return box, func() []backup.Category {
+		var out []backup.Category
+		for i, ch := range checks {
+			if ch.Checked {
+				out = append(out, cats[i])
+			}
+		}
+		return out
+	}
+}
+
+// showCreateBackupDialog — части, пароль, затем сохранение файла.
+func showCreateBackupDialog(ac *core.AppController) {
+	win := ac.UIService.MainWindow
+	title := locale.T("settings.backup_create_title")
+	checks, selected := backupCategoryChecks(backup.AllCategories, backupCategoryLabel)
+	pass := widget.NewPasswordEntry()
+	again := widget.NewPasswordEntry()
+	hint := widget.NewLabel(locale.T("settings.backup_create_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint, checks, widget.NewForm(
+		widget.NewFormItem(locale.T("settings.backup_field_passphrase"), pass),
+		widget.NewFormItem(locale.T("settings.backup_field_repeat"), again),
+	))
+	dlg := dialog.NewCustomConfirm(title, locale.T("settings.backup_create_submit"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			cats := selected()
+			switch {
+			case len(cats) == 0:
+				dialog.ShowInformation(title, locale.T("settings.backup_nothing_selected"), win)
+				return
+			case len(pass.Text) < backup.MinPassphrase:
+				dialog.ShowInformation(title, locale.Tf("settings.backup_passphrase_short", backup.MinPassphrase), win)
+				return
+			case pass.Text != again.Text:
+				dialog.ShowInformation(title, locale.T("settings.backup_passphrase_mismatch"), win)
+				return
+			}
+			phrase := pass.Text
+			go func() {
+				raw, _, err := ac.CreateBackup(phrase, cats)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, win)
+						return
+					}
+					saveBackupFile(win, raw)
+				})
+			}()
+		}, win)
+	dlg.Resize(fyne.NewSize(480, 0))
+	dlg.Show()
+}
+
+func saveBackupFile(win fyne.Window, raw []byte) {
+	fd := dialog.NewFileSave(func(uc fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		if uc == nil {
+			return
+		}
+		defer func() { _ = uc.Close() }()
+		if _, err := uc.Write(raw); err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		debuglog.InfoLog("backup: saved to %s", uc.URI().Path())
+		dialog.ShowInformation(locale.T("settings.backup_create_title"), locale.T("settings.backup_saved"), win)
+	}, win)
+	fd.SetFileName(fmt.Sprintf("singbox-launcher-backup-%s.json", time.Now().Format("20060102")))
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	fd.Show()
+}
+
+// showRestoreBackupDialog — выбор файла, затем пароль.
+func showRestoreBackupDialog(ac *core.AppController) {
+	win := ac.UIService.MainWindow
+	fd := dialog.NewFileOpen(func(rc fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		if rc == nil {
+			return
+		}
+		defer func() { _ = rc.Close() }()
+		raw, err := io.ReadAll(io.LimitReader(rc, backupFileLimit))
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
+		}
+		askBackupPassphrase(ac, raw)
+	}, win)
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	fd.Show()
+}
+
+func askBackupPassphrase(ac *core.AppController, raw []byte) {
+	win := ac.UIService.MainWindow
+	title := locale.T("settings.backup_restore_title")
+	pass := widget.NewPasswordEntry()
+	content := widget.NewForm(widget.NewFormItem(locale.T("settings.backup_field_passphrase"), pass))
+	dlg := dialog.NewCustomConfirm(title, locale.T("settings.backup_open"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			phrase := pass.Text
+			go func() {
+				m, err := ac.InspectBackup(raw, phrase)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, win)
+						return
+					}
+					askRestoreCategories(ac, raw, phrase, m)
+				})
+			}()
+		}, win)
+	pass.OnSubmitted = func(string) { dlg.Confirm() }
+	dlg.Resize(fyne.NewSize(420, 0))
+	dlg.Show()
+	win.Canvas().Focus(pass)
+}
+
+// askRestoreCategories — что восстановить из того, что в копии есть.
+func askRestoreCategories(ac *core.AppController, raw []byte, phrase string, m backup.Manifest) {
+	win := ac.UIService.MainWindow
+	title := locale.T("settings.backup_restore_title")
+	files := map[backup.Category]int{}
+	var cats []backup.Category
+	for _, ci := range m.Categories {
+		if ci.Files > 0 {
+			cats = append(cats, ci.Category)
+			files[ci.Category] = ci.Files
+		}
+	}
+	if len(cats) == 0 {
+		dialog.ShowInformation(title, locale.T("settings.backup_empty"), win)
+		return
+	}
+	checks, selected := backupCategoryChecks(cats, func(c backup.Category) string {
+		return locale.Tf("settings.backup_cat_files", backupCategoryLabel(c), files[c])
+	})
+	created := m.CreatedAt
+	if t, err := time.Parse(time.RFC3339, m.CreatedAt); err == nil {
+		created = t.Local().Format("2006-01-02 15:04")
+	}
+	info := widget.NewLabel(locale.Tf("settings.backup_restore_info", created, m.LauncherVersion, m.OS))
+	info.Wrapping = fyne.TextWrapWord
+	hint := widget.NewLabel(locale.T("settings.backup_restore_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	dlg := dialog.NewCustomConfirm(title, locale.T("settings.backup_restore_submit"),
+		locale.T("dialog.button_cancel"), container.NewVBox(info, checks, hint), func(ok bool) {
+			if !ok {
+				return
+			}
+			chosen := selected()
+			if len(chosen) == 0 {
+				dialog.ShowInformation(title, locale.T("settings.backup_nothing_selected"), win)
+				return
+			}
+			go func() {
+				report, err := ac.RestoreBackup(audit.ActorUI, raw, phrase, chosen)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, win)
+						return
+					}
+					dialog.ShowInformation(title, backupReportText(report), win)
+				})
+			}()
+		}, win)
+	dlg.Resize(fyne.NewSize(480, 0))
+	dlg.Show()
+}
+
+// backupReportText — итог восстановления построчно, в стиле отчёта
+// импорта машин.
+func backupReportText(report backup.Report) string {
+	var b strings.Builder
+	for _, cr := range report.Categories {
+		fmt.Fprintf(&b, "✓ %s\n", locale.Tf("settings.backup_cat_files", backupCategoryLabel(cr.Category), cr.Files))
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(&b, "✗ %s\n", e)
+	}
+	for _, w := range report.Warnings {
+		fmt.Fprintf(&b, "⚠ %s\n", w)
+	}
+	if report.SavedTo != "" {
+		b.WriteString(locale.Tf("settings.backup_saved_to", report.SavedTo) + "\n")
+	}
+	for _, cr := range report.Categories {
+		if cr.Category == backup.CategorySettings && cr.Files > 0 {
+			b.WriteString(locale.T("settings.backup_restart_hint") + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,238 @@
+package ui
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/core/debugapi"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/dialogs"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/platform"
+)
+
+// Доступ к Debug API: unix-сокет и именованные токены с областями
+// (core/debugapi/access.go). Каждое изменение сразу пишется в settings.json
+// и, если API запущен, перезапускает его.
+
+// applyDebugAPIAccess сохраняет изменение и перезапускает работающий API.
+// Не поднялся — настройки возвращаются к прежним, API — к прежнему виду.
+func applyDebugAPIAccess(ac *core.AppController, change func(*locale.Settings)) error {
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	prev := locale.LoadSettings(binDir)
+	cur := locale.LoadSettings(binDir)
+	change(&cur)
+	if err := locale.SaveSettings(binDir, cur); err != nil {
+		return err
+	}
+	if !ac.DebugAPIRunning() {
+		return nil
+	}
+	err := ac.StartDebugAPI(cur.DebugAPIPort, cur.DebugAPIToken)
+	if err == nil {
+		return nil
+	}
+	debuglog.WarnLog("settings.debug_api: restart with new access settings: %v", err)
+	if serr := locale.SaveSettings(binDir, prev); serr != nil {
+		debuglog.WarnLog("settings.debug_api: restore settings: %v", serr)
+	}
+	if rerr := ac.StartDebugAPI(prev.DebugAPIPort, prev.DebugAPIToken); rerr != nil {
+		debuglog.ErrorLog("settings.debug_api: restart with previous settings: %v", rerr)
+	}
+	return err
+}
+
+// showDebugAPIAccessDialog — сокет и токены; onChanged обновляет статус
+// блока Debug API.
+func showDebugAPIAccessDialog(ac *core.AppController, onChanged func()) {
+	win := ac.UIService.MainWindow
+	binDir := platform.GetBinDir(ac.FileService.ExecDir)
+	st := locale.LoadSettings(binDir)
+
+	socketHint := widget.NewLabel(locale.Tf("diag.debug_api_socket_hint", platform.GetDebugAPISocketPath(ac.FileService.ExecDir)))
+	socketHint.Wrapping = fyne.TextWrapWord
+	socketOnly := widget.NewCheck(locale.T("diag.debug_api_socket_only"), nil)
+	socketOnly.SetChecked(st.DebugAPISocket && st.DebugAPISocketOnly)
+	socketCheck := widget.NewCheck(locale.T("diag.debug_api_socket"), nil)
+	socketCheck.SetChecked(st.DebugAPISocket)
+	if !st.DebugAPISocket {
+		socketOnly.Disable()
+	}
+	if !debugapi.SocketSupported {
+		socketCheck.Disable()
+		socketOnly.Disable()
+		socketHint.SetText(locale.T("diag.debug_api_socket_unsupported"))
+	}
+	// Флажок, вернувшийся после ошибки, не должен снова сохранять.
+	applying := false
+	socketCheck.OnChanged = func(on bool) {
+		if applying {
+			return
+		}
+		err := applyDebugAPIAccess(ac, func(s *locale.Settings) {
+			s.DebugAPISocket = on
+			if !on {
+				s.DebugAPISocketOnly = false
+			}
+		})
+		applying = true
+		if err != nil {
+			socketCheck.SetChecked(!on)
+			ShowError(win, err)
+		} else if !on {
+			socketOnly.SetChecked(false)
+		}
+		applying = false
+		if socketCheck.Checked {
+			socketOnly.Enable()
+		} else {
+			socketOnly.Disable()
+		}
+		onChanged()
+	}
+	socketOnly.OnChanged = func(on bool) {
+		if applying {
+			return
+		}
+		if err := applyDebugAPIAccess(ac, func(s *locale.Settings) { s.DebugAPISocketOnly = on }); err != nil {
+			applying = true
+			socketOnly.SetChecked(!on)
+			applying = false
+			ShowError(win, err)
+		}
+		onChanged()
+	}
+
+	tokensBox := container.NewVBox()
+	var refreshTokens func()
+	refreshTokens = func() {
+		tokensBox.RemoveAll()
+		cur := locale.LoadSettings(binDir)
+		if len(cur.DebugAPITokens) == 0 {
+			tokensBox.Add(widget.NewLabel(locale.T("diag.debug_api_tokens_none")))
+		}
+		for _, t := range cur.DebugAPITokens {
+			t := t
+			label := widget.NewLabel(locale.Tf("diag.debug_api_token_row", t.Name, strings.Join(t.Scopes, ", ")))
+			copyBtn := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+				ac.UIService.Application.Clipboard().SetContent(t.Token)
+				dialogs.ShowAutoHideInfo(ac.UIService.Application, win,
+					locale.T("diag.debug_api_copied_title"), locale.Tf("diag.debug_api_token_copied", t.Name))
+			})
+			removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				ShowConfirm(win, locale.T("diag.debug_api_token_remove_title"),
+					locale.Tf("diag.debug_api_token_remove_body", t.Name), func(ok bool) {
+						if !ok {
+							return
+						}
+						err := applyDebugAPIAccess(ac, func(s *locale.Settings) {
+							kept := s.DebugAPITokens[:0]
+							for _, x := range s.DebugAPITokens {
+								if x.Name != t.Name {
+									kept = append(kept, x)
+								}
+							}
+							s.DebugAPITokens = kept
+						})
+						if err != nil {
+							ShowError(win, err)
+						}
+						refreshTokens()
+					})
+			})
+			tokensBox.Add(container.NewBorder(nil, nil, nil, container.NewHBox(copyBtn, removeBtn), label))
+		}
+		tokensBox.Refresh()
+	}
+	refreshTokens()
+
+	addBtn := widget.NewButtonWithIcon(locale.T("diag.debug_api_token_add"), theme.ContentAddIcon(), func() {
+		showAddDebugAPITokenDialog(ac, refreshTokens)
+	})
+	tokensHint := widget.NewLabel(locale.T("diag.debug_api_tokens_hint"))
+	tokensHint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(locale.T("diag.debug_api_socket_title"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		socketHint, socketCheck, socketOnly,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle(locale.T("diag.debug_api_tokens_title"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		tokensHint, tokensBox, container.NewHBox(addBtn),
+	)
+	dlg := dialog.NewCustom(locale.T("diag.debug_api_access_title"), locale.T("dialog.close"),
+		container.NewVScroll(content), win)
+	dlg.Resize(fyne.NewSize(560, 520))
+	dlg.Show()
+}
+
+// showAddDebugAPITokenDialog — имя и области; токен создаётся, сохраняется
+// и сразу копируется в буфер обмена.
+func showAddDebugAPITokenDialog(ac *core.AppController, onDone func()) {
+	win := ac.UIService.MainWindow
+	title := locale.T("diag.debug_api_token_add_title")
+	name := widget.NewEntry()
+	name.SetPlaceHolder("grafana")
+	checks := make([]*widget.Check, len(debugapi.AllScopes))
+	scopesBox := container.NewVBox()
+	for i, sc := range debugapi.AllScopes {
+		checks[i] = widget.NewCheck(locale.T("diag.debug_api_scope_"+string(sc)), nil)
+		scopesBox.Add(checks[i])
+	}
+	checks[0].SetChecked(true)
+	content := container.NewVBox(
+		widget.NewForm(widget.NewFormItem(locale.T("diag.debug_api_token_name"), name)),
+		scopesBox,
+	)
+	dlg := dialog.NewCustomConfirm(title, locale.T("diag.debug_api_token_create"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			n := strings.TrimSpace(name.Text)
+			var scopes []string
+			for i, ch := range checks {
+				if ch.Checked {
+					scopes = append(scopes, string(debugapi.AllScopes[i]))
+				}
+			}
+			binDir := platform.GetBinDir(ac.FileService.ExecDir)
+			switch {
+			case !debugapi.ValidTokenName(n):
+				dialog.ShowInformation(title, locale.T("diag.debug_api_token_name_invalid"), win)
+				return
+			case len(scopes) == 0:
+				dialog.ShowInformation(title, locale.T("diag.debug_api_token_no_scopes"), win)
+				return
+			}
+			for _, t := range locale.LoadSettings(binDir).DebugAPITokens {
+				if t.Name == n {
+					dialog.ShowInformation(title, locale.Tf("diag.debug_api_token_name_taken", n), win)
+					return
+				}
+			}
+			secret, err := debugapi.GenerateToken()
+			if err != nil {
+				ShowError(win, err)
+				return
+			}
+			if err := applyDebugAPIAccess(ac, func(s *locale.Settings) {
+				s.DebugAPITokens = append(s.DebugAPITokens, locale.DebugAPINamedToken{Name: n, Token: secret, Scopes: scopes})
+			}); err != nil {
+				ShowError(win, err)
+				return
+			}
+			ac.UIService.Application.Clipboard().SetContent(secret)
+			dialogs.ShowAutoHideInfo(ac.UIService.Application, win,
+				locale.T("diag.debug_api_copied_title"), locale.Tf("diag.debug_api_token_copied", n))
+			onDone()
+		}, win)
+	dlg.Resize(fyne.NewSize(420, 0))
+	dlg.Show()
+	win.Canvas().Focus(name)
+}
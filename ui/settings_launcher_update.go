@@ -0,0 +1,247 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/internal/constants"
+	"singbox-launcher/internal/debuglog"
+	"singbox-launcher/internal/dialogs"
+	"singbox-launcher/internal/locale"
+)
+
+// buildLauncherUpdateBlock — секция «Обновление лаунчера» в Settings:
+// автоподготовка, канал, ручная проверка и установка, перезапуск и откат
+// (core/launcher_update.go). Сама замена бинаря — на следующем старте.
+func buildLauncherUpdateBlock(ac *core.AppController, binDir string) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(locale.T("settings.section_launcher_update"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	hint := widget.NewLabel(locale.T("settings.launcher_update_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	progress := widget.NewProgressBar()
+	progress.Hide()
+
+	st := locale.LoadSettings(binDir)
+	autoCheck := widget.NewCheck(locale.T("settings.launcher_update_auto"), func(on bool) {
+		cur := locale.LoadSettings(binDir)
+		cur.LauncherUpdateAuto = on
+		if err := locale.SaveSettings(binDir, cur); err != nil {
+			debuglog.WarnLog("settings_tab: save launcher_update_auto: %v", err)
+		}
+	})
+	autoCheck.SetChecked(st.LauncherUpdateAuto)
+
+	channels := []string{
+		locale.T("settings.launcher_update_channel_stable"),
+		locale.T("settings.launcher_update_channel_prerelease"),
+	}
+	channelSelect := widget.NewSelect(channels, nil)
+	if core.NormalizeLauncherChannel(st.LauncherUpdateChannel) == core.LauncherChannelPrerelease {
+		channelSelect.SetSelected(channels[1])
+	} else {
+		channelSelect.SetSelected(channels[0])
+	}
+
+	var found *core.LauncherRelease
+	var checkBtn, installBtn, restartBtn, rollbackBtn *widget.Button
+
+	// refresh — кнопки и строка состояния по записи обновления; msg — итог
+	// последнего действия, если был.
+	refresh := func(msg string) {
+		s := ac.LauncherUpdateStatus()
+		text := locale.Tf("settings.launcher_update_running", constants.AppVersion)
+		switch {
+		case s.Unsupported != "":
+			text += "\n" + locale.Tf("settings.launcher_update_unsupported", s.Unsupported)
+		case s.Phase == core.LauncherUpdateStaged:
+			text += "\n" + locale.Tf("settings.launcher_update_staged", s.Version, s.VerifiedBy)
+		case s.Phase == core.LauncherUpdateApplied:
+			text += "\n" + locale.Tf("settings.launcher_update_applied", s.FromVersion, s.Version)
+		case s.Phase == core.LauncherUpdateRollback:
+			text += "\n" + locale.T("settings.launcher_update_rollback_pending")
+		case s.Phase == core.LauncherUpdateRolledBack:
+			text += "\n" + locale.Tf("settings.launcher_update_rolled_back", s.Version, s.Reason)
+		}
+		if msg != "" {
+			text += "\n" + msg
+		}
+		status.SetText(text)
+
+		checkBtn.Enable()
+		installBtn.Disable()
+		restartBtn.Disable()
+		rollbackBtn.Disable()
+		if s.Unsupported != "" || s.Busy {
+			checkBtn.Disable()
+			return
+		}
+		if found != nil && s.Phase != core.LauncherUpdateStaged {
+			installBtn.Enable()
+		}
+		if s.Phase == core.LauncherUpdateStaged || s.Phase == core.LauncherUpdateRollback {
+			restartBtn.Enable()
+		}
+		if s.CanRollback && s.Phase != core.LauncherUpdateRollback {
+			rollbackBtn.Enable()
+		}
+	}
+
+	channelSelect.OnChanged = func(sel string) {
+		cur := locale.LoadSettings(binDir)
+		cur.LauncherUpdateChannel = core.LauncherChannelStable
+		if sel == channels[1] {
+			cur.LauncherUpdateChannel = core.LauncherChannelPrerelease
+		}
+		if err := locale.SaveSettings(binDir, cur); err != nil {
+			debuglog.WarnLog("settings_tab: save launcher_update_channel: %v", err)
+		}
+		found = nil
+		refresh("")
+	}
+
+	checkBtn = widget.NewButton(locale.T("settings.launcher_update_check"), func() {
+		checkBtn.Disable()
+		status.SetText(locale.T("settings.launcher_update_checking"))
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), core.NetworkRequestTimeout)
+			defer cancel()
+			rel, err := ac.CheckLauncherUpdate(ctx)
+			fyne.Do(func() {
+				found = rel
+				switch {
+				case err != nil:
+					refresh(locale.Tf("settings.launcher_update_error", err))
+				case rel == nil:
+					refresh(locale.Tf("settings.launcher_update_latest", constants.AppVersion))
+				default:
+					refresh(locale.Tf("settings.launcher_update_available", rel.Version, rel.Asset))
+				}
+			})
+		}()
+	})
+	installBtn = widget.NewButton(locale.T("settings.launcher_update_install"), func() {
+		if found == nil {
+			return
+		}
+		installBtn.Disable()
+		checkBtn.Disable()
+		stageLauncherUpdate(ac, found, progress, func(err error) {
+			if err != nil {
+				refresh(locale.Tf("settings.launcher_update_error", err))
+				return
+			}
+			refresh("")
+		})
+	})
+	restartBtn = widget.NewButton(locale.T("settings.launcher_update_restart"), func() {
+		if err := ac.RestartLauncher(); err != nil {
+			refresh(locale.Tf("settings.launcher_update_error", err))
+		}
+	})
+	rollbackBtn = widget.NewButton(locale.T("settings.launcher_update_rollback"), func() {
+		ShowConfirm(ac.UIService.MainWindow, locale.T("settings.launcher_update_rollback_confirm_title"),
+			locale.T("settings.launcher_update_rollback_confirm_message"), func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := ac.RequestLauncherRollback(); err != nil {
+					refresh(locale.Tf("settings.launcher_update_error", err))
+					return
+				}
+				refresh("")
+			})
+	})
+	refresh("")
+
+	channelRow := container.NewBorder(nil, nil, widget.NewLabel(locale.T("settings.launcher_update_channel")), nil, channelSelect)
+	buttons := container.NewHBox(checkBtn, installBtn, restartBtn, rollbackBtn)
+	return container.NewVBox(title, hint, autoCheck, channelRow, buttons, progress, status)
+}
+
+// stageLauncherUpdate скачивает и готовит rel, показывая прогресс в bar;
+// done вызывается в UI-потоке с итогом. Общая для настроек и попапа.
+func stageLauncherUpdate(ac *core.AppController, rel *core.LauncherRelease, bar *widget.ProgressBar, done func(error)) {
+	bar.SetValue(0)
+	bar.Show()
+	progressChan := make(chan core.DownloadProgress, 10)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		ac.StageLauncherUpdate(ctx, rel, progressChan)
+	}()
+	go func() {
+		var last error
+		for p := range progressChan {
+			if p.Error != nil {
+				last = p.Error
+			}
+			fyne.Do(func() { bar.SetValue(float64(p.Progress) / 100.0) })
+		}
+		fyne.Do(func() {
+			bar.Hide()
+			done(last)
+		})
+	}()
+}
+
+// launcherUpdatePopupActions — кнопки попапа о новой версии: «Установить»
+// (скачать и подготовить) и затем «Перезапустить». nil — самообновление
+// для этой сборки недоступно, остаётся ссылка на GitHub.
+func launcherUpdatePopupActions(ac *core.AppController, latestVersion string, w fyne.Window) fyne.CanvasObject {
+	s := ac.LauncherUpdateStatus()
+	if s.Unsupported != "" {
+		return nil
+	}
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	bar := widget.NewProgressBar()
+	bar.Hide()
+	var installBtn, restartBtn *widget.Button
+	restartBtn = widget.NewButton(locale.T("core.dialog_update_restart"), func() {
+		if err := ac.RestartLauncher(); err != nil {
+			dialogs.ShowError(w, err)
+		}
+	})
+	installBtn = widget.NewButton(locale.T("core.dialog_update_install"), func() {
+		installBtn.Disable()
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), core.NetworkRequestTimeout)
+			defer cancel()
+			rel, err := ac.CheckLauncherUpdate(ctx)
+			if err == nil && rel == nil {
+				err = errors.New(locale.Tf("settings.launcher_update_latest", constants.AppVersion))
+			}
+			fyne.Do(func() {
+				if err != nil {
+					status.SetText(locale.Tf("settings.launcher_update_error", err))
+					installBtn.Enable()
+					return
+				}
+				stageLauncherUpdate(ac, rel, bar, func(err error) {
+					if err != nil {
+						status.SetText(locale.Tf("settings.launcher_update_error", err))
+						installBtn.Enable()
+						return
+					}
+					status.SetText(locale.Tf("core.dialog_update_ready", rel.Version))
+					installBtn.Hide()
+					restartBtn.Show()
+				})
+			})
+		}()
+	})
+	if s.Phase == core.LauncherUpdateStaged && s.Version == latestVersion {
+		status.SetText(locale.Tf("core.dialog_update_ready", s.Version))
+		installBtn.Hide()
+	} else {
+		restartBtn.Hide()
+	}
+	return container.NewVBox(container.NewHBox(installBtn, restartBtn), bar, status)
+}
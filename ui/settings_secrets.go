@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"errors"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"singbox-launcher/core"
+	"singbox-launcher/internal/locale"
+	"singbox-launcher/internal/secretstore"
+)
+
+// Шифрование секретов на диске (core/secrets.go): источник ключа —
+// системная связка или пароль; смена перезаписывает файлы с секретами.
+
+// buildSecretsBlock — блок вкладки Settings.
+func buildSecretsBlock(ac *core.AppController) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(locale.T("settings.section_secrets"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	hint := widget.NewLabel(locale.T("settings.secrets_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	var keyringBtn, passphraseBtn, offBtn, unlockBtn *widget.Button
+	refresh := func(msg string) {
+		st := ac.SecretsStatus()
+		var text string
+		switch {
+		case st.Locked:
+			text = locale.T("settings.secrets_locked")
+		case st.Enabled && st.Source == secretstore.SourceKeyring:
+			text = locale.T("settings.secrets_on_keyring")
+		case st.Enabled:
+			text = locale.T("settings.secrets_on_passphrase")
+		default:
+			text = locale.T("settings.secrets_off")
+		}
+		if !st.KeyringAvailable {
+			text += "\n" + locale.T("settings.secrets_no_keyring")
+		}
+		if msg != "" {
+			text += "\n" + msg
+		}
+		status.SetText(text)
+
+		keyringBtn.Disable()
+		passphraseBtn.Disable()
+		offBtn.Disable()
+		unlockBtn.Hide()
+		if st.Locked {
+			unlockBtn.Show()
+			return
+		}
+		if st.KeyringAvailable && !(st.Enabled && st.Source == secretstore.SourceKeyring) {
+			keyringBtn.Enable()
+		}
+		passphraseBtn.Enable()
+		if st.Enabled {
+			offBtn.Enable()
+		}
+	}
+	// run — смена режима в фоне: диалог связки и scrypt не держат UI.
+	run := func(op func() error, done string) {
+		keyringBtn.Disable()
+		passphraseBtn.Disable()
+		offBtn.Disable()
+		go func() {
+			err := op()
+			fyne.Do(func() {
+				if err != nil {
+					refresh(locale.Tf("settings.secrets_error", err))
+					return
+				}
+				refresh(locale.T(done))
+			})
+		}()
+	}
+
+	keyringBtn = widget.NewButton(locale.T("settings.secrets_use_keyring"), func() {
+		run(func() error { return ac.SetSecretsEncryption(secretstore.SourceKeyring, "") }, "settings.secrets_done_on")
+	})
+	passphraseBtn = widget.NewButton(locale.T("settings.secrets_use_passphrase"), func() {
+		askNewSecretsPassphrase(ac.UIService.MainWindow, func(phrase string) {
+			run(func() error { return ac.SetSecretsEncryption(secretstore.SourcePassphrase, phrase) }, "settings.secrets_done_on")
+		})
+	})
+	offBtn = widget.NewButton(locale.T("settings.secrets_turn_off"), func() {
+		ShowConfirm(ac.UIService.MainWindow, locale.T("settings.section_secrets"), locale.T("settings.secrets_off_confirm"), func(ok bool) {
+			if ok {
+				run(ac.DisableSecretsEncryption, "settings.secrets_done_off")
+			}
+		})
+	})
+	unlockBtn = widget.NewButton(locale.T("settings.secrets_unlock"), func() {
+		ShowSecretsUnlockDialog(ac, func() { refresh("") })
+	})
+	refresh("")
+
+	buttons := container.NewHBox(keyringBtn, passphraseBtn, offBtn, unlockBtn)
+	return container.NewVBox(title, hint, buttons, status)
+}
+
+// askNewSecretsPassphrase — новый пароль дважды.
+func askNewSecretsPassphrase(win fyne.Window, onOK func(string)) {
+	pass := widget.NewPasswordEntry()
+	again := widget.NewPasswordEntry()
+	hint := widget.NewLabel(locale.T("settings.secrets_passphrase_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint, widget.NewForm(
+		widget.NewFormItem(locale.T("settings.secrets_field_passphrase"), pass),
+		widget.NewFormItem(locale.T("settings.secrets_field_repeat"), again),
+	))
+	dlg := dialog.NewCustomConfirm(locale.T("settings.section_secrets"), locale.T("settings.secrets_use_passphrase_submit"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			if len(pass.Text) < secretstore.MinPassphrase {
+				dialog.ShowInformation(locale.T("settings.section_secrets"),
+					locale.Tf("settings.secrets_passphrase_short", secretstore.MinPassphrase), win)
+				return
+			}
+			if pass.Text != again.Text {
+				dialog.ShowInformation(locale.T("settings.section_secrets"), locale.T("settings.secrets_passphrase_mismatch"), win)
+				return
+			}
+			onOK(pass.Text)
+		}, win)
+	dlg.Resize(fyne.NewSize(460, 0))
+	dlg.Show()
+}
+
+// ShowSecretsUnlockDialog спрашивает пароль шифрования секретов. Показывается
+// на старте, если ключ из пароля, и из блока настроек; неверный пароль —
+// диалог открывается снова. onDone — после успешной разблокировки.
+func ShowSecretsUnlockDialog(ac *core.AppController, onDone func()) {
+	if ac == nil || ac.UIService == nil || ac.UIService.MainWindow == nil {
+		return
+	}
+	win := ac.UIService.MainWindow
+	pass := widget.NewPasswordEntry()
+	hint := widget.NewLabel(locale.T("settings.secrets_unlock_hint"))
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(hint, widget.NewForm(
+		widget.NewFormItem(locale.T("settings.secrets_field_passphrase"), pass),
+	))
+	dlg := dialog.NewCustomConfirm(locale.T("settings.secrets_unlock_title"), locale.T("settings.secrets_unlock"),
+		locale.T("dialog.button_cancel"), content, func(ok bool) {
+			if !ok {
+				return
+			}
+			phrase := pass.Text
+			go func() {
+				err := ac.UnlockSecrets(phrase)
+				fyne.Do(func() {
+					switch {
+					case errors.Is(err, secretstore.ErrPassphrase):
+						ShowSecretsUnlockDialog(ac, onDone)
+						dialog.ShowInformation(locale.T("settings.secrets_unlock_title"), locale.T("settings.secrets_wrong_passphrase"), win)
+					case err != nil:
+						dialog.ShowError(err, win)
+					default:
+						if onDone != nil {
+							onDone()
+						}
+					}
+				})
+			}()
+		}, win)
+	pass.OnSubmitted = func(string) { dlg.Confirm() }
+	dlg.Resize(fyne.NewSize(420, 0))
+	dlg.Show()
+	win.Canvas().Focus(pass)
+}
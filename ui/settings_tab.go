@@ -76,6 +76,21 @@ func BuildSettingsContent(ac *core.AppController) fyne.CanvasObject {
 		}
 	}
 
+	// Обрыв соединений после смены узла: без него открытые сессии доживают
+	// на прежнем узле, и переключение «не действует» до их закрытия.
+	closeOnSwitchCheck := widget.NewCheck(locale.T("settings.close_conns_on_switch_label"), nil)
+	closeOnSwitchCheck.SetChecked(ac.APIService != nil && ac.APIService.CloseConnectionsOnSwitch())
+	closeOnSwitchCheck.OnChanged = func(enabled bool) {
+		if ac.APIService != nil {
+			ac.APIService.SetCloseConnectionsOnSwitch(enabled)
+		}
+		st := locale.LoadSettings(binDir)
+		st.CloseConnectionsOnSwitch = enabled
+		if err := locale.SaveSettings(binDir, st); err != nil {
+			debuglog.WarnLog("settings_tab: save close_connections_on_switch: %v", err)
+		}
+	}
+
 	// --- Subscription User-Agent override -----------------------------------
 	// Empty entry → fetcher falls back to BuildSubscriptionUserAgent (the
 	// default UA shown as the placeholder). Reset button just clears the
@@ -226,6 +241,15 @@ func BuildSettingsContent(ac *core.AppController) fyne.CanvasObject {
 	// языком и идентификацией подписки.
 	debugAPIBlock := buildDebugAPIRow(ac)
 
+	// ---- Обновление лаунчера (core/launcher_update.go) ---------------------
+	launcherUpdateBlock := buildLauncherUpdateBlock(ac, binDir)
+
+	// ---- Шифрование секретов (core/secrets.go) -----------------------------
+	secretsBlock := buildSecretsBlock(ac)
+
+	// ---- Резервная копия профиля (core/backup) -----------------------------
+	backupBlock := buildBackupBlock(ac)
+
 	// Language first so the two subscription sections (Subscriptions +
 	// Subscription identification) sit together instead of being split by the
 	// Language block.
@@ -235,6 +259,7 @@ func BuildSettingsContent(ac *core.AppController) fyne.CanvasObject {
 		widget.NewSeparator(),
 		connTitle,
 		autoPingCheck,
+		closeOnSwitchCheck,
 		widget.NewSeparator(),
 		subsTitle,
 		autoUpdateCheck,
@@ -244,6 +269,12 @@ func BuildSettingsContent(ac *core.AppController) fyne.CanvasObject {
 		subIDTitle,
 		subIDBlock,
 		widget.NewSeparator(),
+		launcherUpdateBlock,
+		widget.NewSeparator(),
+		secretsBlock,
+		widget.NewSeparator(),
+		backupBlock,
+		widget.NewSeparator(),
 		debugAPIBlock,
 	)
 	return content
@@ -415,12 +446,18 @@ func buildDebugAPIRow(ac *core.AppController) fyne.CanvasObject {
 	status := widget.NewLabel("")
 	status.Wrapping = fyne.TextWrapWord
 	refreshStatus := func() {
-		addr := ac.DebugAPIAddr()
-		if addr == "" {
+		if !ac.DebugAPIRunning() {
 			status.SetText(locale.T("diag.debug_api_off"))
-		} else {
-			status.SetText(locale.Tf("diag.debug_api_on", addr))
+			return
+		}
+		var where []string
+		if addr := ac.DebugAPIAddr(); addr != "" {
+			where = append(where, addr)
+		}
+		if sock := ac.DebugAPISocketPath(); sock != "" {
+			where = append(where, "unix:"+sock)
 		}
+		status.SetText(locale.Tf("diag.debug_api_on", strings.Join(where, ", ")))
 	}
 	refreshStatus()
 
@@ -450,7 +487,12 @@ func buildDebugAPIRow(ac *core.AppController) fyne.CanvasObject {
 	copyCardBtn.OnTapped = func() {
 		cur := locale.LoadSettings(binDir)
 		addr := ac.DebugAPIAddr()
-		if addr == "" || cur.DebugAPIToken == "" {
+		if cur.DebugAPIToken == "" {
+			return
+		}
+		if addr == "" {
+			// Только сокет: карточка с base_url для агента не получится.
+			dialog.ShowInformation(locale.T("diag.debug_api_copy_card"), locale.T("diag.debug_api_card_no_tcp"), ac.UIService.MainWindow)
 			return
 		}
 		coreVer, _ := ac.GetInstalledCoreVersion()
@@ -494,7 +536,7 @@ func buildDebugAPIRow(ac *core.AppController) fyne.CanvasObject {
 					debuglog.WarnLog("settings.debug_api: save regenerated token: %v", err)
 				}
 				// Restart the live listener so it serves the new token.
-				if ac.DebugAPIAddr() != "" {
+				if ac.DebugAPIRunning() {
 					ac.StopDebugAPI()
 					if err := ac.StartDebugAPI(cur.DebugAPIPort, tok); err != nil {
 						debuglog.ErrorLog("settings.debug_api: restart after regen failed: %v", err)
@@ -594,12 +636,17 @@ func buildDebugAPIRow(ac *core.AppController) fyne.CanvasObject {
 	portLabel := widget.NewLabel(locale.T("diag.debug_api_port_label"))
 	portRow := container.NewBorder(nil, nil, portLabel, copyCardBtn, portEntry)
 
+	// Сокет и именованные токены — в отдельном диалоге, чтобы блок не рос.
+	accessBtn := widget.NewButtonWithIcon(locale.T("diag.debug_api_access"), theme.AccountIcon(), func() {
+		showDebugAPIAccessDialog(ac, refreshStatus)
+	})
+
 	row := container.NewVBox(
 		title,
 		hint,
 		container.NewHBox(check, copyTokenBtn, regenTokenBtn),
 		portRow,
-		status,
+		container.NewBorder(nil, nil, nil, accessBtn, status),
 	)
 	return row
 }
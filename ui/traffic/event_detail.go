@@ -8,8 +8,10 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
+	"singbox-launcher/internal/locale"
 	tprof "singbox-launcher/internal/traffic"
 )
 
@@ -59,6 +61,15 @@ func showEventDetail(parent fyne.Window, e tprof.TrafficEvent) {
 // dev необязателен: у своего ядра трафик идёт от процессов, справочника
 // устройств там нет, и блок просто не рисуется.
 func showEventDetailWithDevice(parent fyne.Window, e tprof.TrafficEvent, dev *DeviceInfo) {
+	showEventDetailWithClose(parent, e, dev, nil)
+}
+
+// showEventDetailWithClose — окно деталей с кнопкой обрыва соединения.
+//
+// closeConns == nil или событие без ConnID (DNS) — кнопки нет. Соединение
+// могло закрыться само, пока окно открыто; ядро ответит ошибкой, и она уйдёт
+// в лог, а не в лицо пользователю: результат тот же — соединения нет.
+func showEventDetailWithClose(parent fyne.Window, e tprof.TrafficEvent, dev *DeviceInfo, closeConns func(ids []string)) {
 	if parent == nil {
 		return
 	}
@@ -72,6 +83,15 @@ func showEventDetailWithDevice(parent fyne.Window, e tprof.TrafficEvent, dev *De
 	scroll := container.NewScroll(body)
 	scroll.SetMinSize(fyne.NewSize(560, 360))
 	d := dialog.NewCustom("Event detail", "Close", scroll, parent)
+	if closeConns != nil && e.ConnID != "" {
+		id := e.ConnID
+		killBtn := widget.NewButtonWithIcon(locale.T("traffic.conns.close_one"), theme.CancelIcon(), func() {
+			closeConns([]string{id})
+			d.Hide()
+		})
+		killBtn.Importance = widget.DangerImportance
+		d.SetButtons([]fyne.CanvasObject{killBtn, widget.NewButton("Close", d.Hide)})
+	}
 	d.Resize(fyne.NewSize(620, 440))
 	d.Show()
 }
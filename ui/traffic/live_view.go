@@ -1,6 +1,7 @@
 package traffic
 
 import (
+	"fmt"
 	"net"
 	"strings"
 	"sync"
@@ -8,6 +9,7 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 
@@ -169,7 +171,7 @@ func buildLiveView(deps WindowDeps) *liveView {
 		if ok {
 			// Карточка устройства и здесь: строка Live несёт адрес клиента,
 			// а «кто это» отвечает справочник машины.
-			showEventDetailWithDevice(parentWindowOf(deps), e, deviceFor(deps, e.SourceAddr))
+			showEventDetailWithClose(parentWindowOf(deps), e, deviceFor(deps, e.SourceAddr), deps.CloseConns)
 		}
 	}
 
@@ -230,6 +232,34 @@ func buildLiveView(deps WindowDeps) *liveView {
 		layout.NewSpacer(),
 		pauseBtn, clearBtn,
 	)
+	// Close matching — обрыв всего, что сейчас видно под фильтрами и ещё
+	// открыто. Типичный случай: сменил узел, отфильтровал youtube и добил
+	// сессии, которые иначе так и доживали бы на старом маршруте.
+	if deps.CloseConns != nil && deps.Profiler != nil {
+		closeBtn := widget.NewButton(locale.T("traffic.conns.close_matching"), func() {
+			v.mu.Lock()
+			idxs := v.filteredIndices()
+			events := make([]tprof.TrafficEvent, 0, len(idxs))
+			for _, i := range idxs {
+				events = append(events, v.events[i])
+			}
+			v.mu.Unlock()
+			ids, ok := liveConnIDsOf(deps.Profiler, events)
+			if !ok || len(ids) == 0 {
+				return
+			}
+			dialog.ShowConfirm(
+				locale.T("traffic.conns.close_matching"),
+				fmt.Sprintf(locale.T("traffic.conns.close_matching_confirm"), len(ids)),
+				func(yes bool) {
+					if yes {
+						deps.CloseConns(ids)
+					}
+				}, parentWindowOf(deps))
+		})
+		closeBtn.Importance = widget.LowImportance
+		filterRow.Add(closeBtn)
+	}
 
 	// Три списка из вкладки By client: клиент, outbound, правило. Чипы выше
 	// отбирают ВИД события, эти — маршрут, и заменить одно другим нельзя.
@@ -13,6 +13,7 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
+	"singbox-launcher/internal/locale"
 	tprof "singbox-launcher/internal/traffic"
 	"singbox-launcher/ui/components"
 )
@@ -31,6 +32,7 @@ type perProcessView struct {
 	deps            WindowDeps
 	targetLabel     *widget.Label
 	startStopBtn    *widget.Button
+	closeConnsBtn   *widget.Button
 	statusLine      *widget.Label
 	subTabs         *container.AppTabs
 	liveItems       []tprof.TrafficEvent
@@ -285,7 +287,25 @@ func buildPerProcessView(deps WindowDeps, onRefresh func()) *perProcessView {
 
 	v.body = container.NewStack()
 
-	toolbar := container.NewBorder(nil, nil, v.targetLabel, v.startStopBtn, nil)
+	// Обрыв соединений записываемого процесса: только пока идёт запись —
+	// у сохранённой сессии соединения давно закрыты.
+	right := fyne.CanvasObject(v.startStopBtn)
+	if deps.CloseConns != nil {
+		v.closeConnsBtn = widget.NewButtonWithIcon(locale.T("traffic.conns.close_process"), theme.CancelIcon(), func() {
+			v.mu.Lock()
+			events := append([]tprof.TrafficEvent(nil), v.liveItems...)
+			v.mu.Unlock()
+			ids, ok := liveConnIDsOf(deps.Profiler, events)
+			if !ok || len(ids) == 0 {
+				return
+			}
+			deps.CloseConns(ids)
+		})
+		v.closeConnsBtn.Importance = widget.LowImportance
+		v.closeConnsBtn.Disable()
+		right = container.NewHBox(v.closeConnsBtn, v.startStopBtn)
+	}
+	toolbar := container.NewBorder(nil, nil, v.targetLabel, right, nil)
 	header := container.NewVBox(toolbar, v.statusLine, widget.NewSeparator())
 	v.Content = container.NewBorder(header, nil, nil, nil, v.body)
 
@@ -305,6 +325,9 @@ func buildPerProcessView(deps WindowDeps, onRefresh func()) *perProcessView {
 		if active != nil {
 			v.startStopBtn.SetText("STOP")
 			v.startStopBtn.SetIcon(theme.MediaStopIcon())
+			if v.closeConnsBtn != nil {
+				v.closeConnsBtn.Enable()
+			}
 			v.liveItems = active.Events()
 			v.domainsData = active.AggregateDomains()
 			sort.Slice(v.domainsData, func(i, j int) bool {
@@ -326,6 +349,9 @@ func buildPerProcessView(deps WindowDeps, onRefresh func()) *perProcessView {
 		} else {
 			v.startStopBtn.SetText("Pick process & START")
 			v.startStopBtn.SetIcon(theme.MediaPlayIcon())
+			if v.closeConnsBtn != nil {
+				v.closeConnsBtn.Disable()
+			}
 			// If a saved session is currently being shown (target set
 			// while idle), keep sub-tabs visible; else show idle list.
 			if v.target == "" {
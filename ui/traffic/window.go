@@ -63,9 +63,10 @@ type WindowDeps struct {
 	// IP:port из потока соединений.
 	RemoteMachine bool
 
-	// CloseConns рвёт соединения по их id. Есть только у remote-окна:
-	// на роутере это осмысленное действие — после смены правила устройство
-	// иначе доживает на прежних сессиях и идёт старым маршрутом.
+	// CloseConns рвёт соединения по их id. nil — обрыв недоступен, и окно
+	// прячет кнопки. На роутере это нужно после смены правила (устройство
+	// иначе доживает на прежних сессиях и идёт старым маршрутом), у своего
+	// ядра — после смены узла или чтобы добить зависшее соединение.
 	CloseConns func(ids []string)
 
 	// ClientsInfo — справочник «IP → устройство» локальной сети машины
@@ -344,11 +345,8 @@ type connCounter struct {
 }
 
 // attachConnCounter кладёт справа от вкладок число открытых соединений и
-// кнопку обрыва.
-//
-// Только для окна машины: рвать соединения своего ядра из профайлера нечем —
-// CloseConns есть лишь у remote-окна, где это осмысленное действие после
-// смены правила.
+// кнопку обрыва. Без CloseConns не рисуется вовсе: счётчик без действия
+// рядом только занимал бы полосу вкладок.
 func (m *Manager) attachConnCounter(deps WindowDeps, win fyne.Window, tabs *container.AppTabs) *connCounter {
 	if deps.CloseConns == nil || deps.Profiler == nil {
 		return nil
@@ -411,6 +409,32 @@ func (m *Manager) attachConnCounter(deps WindowDeps, win fyne.Window, tabs *cont
 	}
 }
 
+// liveConnIDsOf — id соединений из событий, которые ядро держит открытыми
+// прямо сейчас, без повторов.
+//
+// События закрытия несут id уже мёртвых соединений, и слать ядру обрыв по
+// ним — заведомо пустые запросы. ok=false, пока поток не дал первого кадра.
+func liveConnIDsOf(p *tprof.TrafficProfiler, events []tprof.TrafficEvent) ([]string, bool) {
+	live, ok := p.LiveConnIDs()
+	if !ok {
+		return nil, false
+	}
+	open := make(map[string]bool, len(live))
+	for _, id := range live {
+		open[id] = true
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range events {
+		if e.ConnID == "" || seen[e.ConnID] || !open[e.ConnID] {
+			continue
+		}
+		seen[e.ConnID] = true
+		out = append(out, e.ConnID)
+	}
+	return out, true
+}
+
 // overlayOnTabs кладёт элементы в правый край полосы вкладок.
 //
 // Поверх вкладок, а не рядом: AppTabs занимает всю ширину, и в Border справа